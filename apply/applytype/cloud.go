@@ -15,38 +15,42 @@
 package applytype
 
 import (
-	"bytes"
 	"fmt"
 
 	"github.com/pkg/errors"
 
 	"github.com/alibaba/sealer/client/k8s"
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/filesystem"
+	"github.com/alibaba/sealer/image"
 	"github.com/alibaba/sealer/infra"
 	"github.com/alibaba/sealer/logger"
-	"github.com/alibaba/sealer/runtime"
+	"github.com/alibaba/sealer/pkg/runtime"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils"
 	"github.com/alibaba/sealer/utils/ssh"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"sigs.k8s.io/yaml"
 )
 
-const ApplyCluster = "chmod +x %s && %s apply -f %s"
-
 type CloudApplier struct {
 	ClusterCurrent *v1.Cluster
 	ClusterDesired *v1.Cluster
 	Client         *k8s.Client
+	// infraDeleted are the hosts the cloud provider deleted by scaling down the count.
+	infraDeleted []string
+	// provider is the cloud provider which scaled the infra.
+	provider infra.Interface
 }
 
+// ScaleDownNodes deletes the nodes of the hosts the cloud provider deleted, it returns false if there are hosts
+// removed from the ip pool, which are deleted and reset by applyHosts as they are still there.
 func (c *CloudApplier) ScaleDownNodes() (isScaleDown bool, err error) {
-	logger.Info("desired master %s, current master %s, desired nodes %s, current nodes %s", c.ClusterDesired.Spec.Masters.Count,
-		c.ClusterCurrent.Spec.Masters.Count,
-		c.ClusterDesired.Spec.Nodes.Count,
-		c.ClusterCurrent.Spec.Nodes.Count)
-	if c.ClusterDesired.Spec.Masters.Count >= c.ClusterCurrent.Spec.Masters.Count &&
-		c.ClusterDesired.Spec.Nodes.Count >= c.ClusterCurrent.Spec.Nodes.Count {
+	logger.Info("desired master %d, current master %d, desired nodes %d, current nodes %d", len(c.ClusterDesired.Spec.Masters.IPList),
+		len(c.ClusterCurrent.Spec.Masters.IPList),
+		len(c.ClusterDesired.Spec.Nodes.IPList),
+		len(c.ClusterCurrent.Spec.Nodes.IPList))
+	if len(c.ClusterDesired.Spec.Masters.IPList) >= len(c.ClusterCurrent.Spec.Masters.IPList) &&
+		len(c.ClusterDesired.Spec.Nodes.IPList) >= len(c.ClusterCurrent.Spec.Nodes.IPList) {
 		return false, nil
 	}
 
@@ -56,10 +60,11 @@ func (c *CloudApplier) ScaleDownNodes() (isScaleDown bool, err error) {
 		return false, fmt.Errorf("should not scale up and down at same time")
 	}
 
-	if err := DeleteNodes(c.Client, append(mastersToDelete, nodesToDelete...)); err != nil {
+	toDelete := append(mastersToDelete, nodesToDelete...)
+	if err := DeleteNodes(c.Client, utils.ReduceIPList(toDelete, c.infraDeleted)); err != nil {
 		return false, err
 	}
-	return true, nil
+	return len(utils.RemoveIPList(toDelete, c.infraDeleted)) == 0, nil
 }
 
 func (c *CloudApplier) Apply() error {
@@ -70,7 +75,7 @@ func (c *CloudApplier) Apply() error {
 	}
 	// first time to apply: create new cluster.
 	if !utils.IsFileExist(common.DefaultKubeConfigFile()) {
-		return c.runRemoteApply()
+		return c.applyHosts()
 	}
 	err = c.fillClusterCurrent()
 	if err != nil {
@@ -87,11 +92,7 @@ func (c *CloudApplier) Apply() error {
 		return nil
 	}
 	// scale up
-	err = c.runRemoteApply()
-	if err != nil {
-		return err
-	}
-	return nil
+	return c.applyHosts()
 }
 
 func (c *CloudApplier) Delete() error {
@@ -116,20 +117,43 @@ func (c *CloudApplier) Delete() error {
 
 func (c *CloudApplier) scaleInfra() error {
 	logger.Info("start to scale the cluster infra")
+	// the cloud provider reconciles the instances by count, without the baremetal hosts in the ip pool.
+	detachIPPool(c.ClusterDesired)
+	provisioned := append(append([]string{}, c.ClusterDesired.Spec.Masters.IPList...), c.ClusterDesired.Spec.Nodes.IPList...)
 	cloudProvider, err := infra.NewDefaultProvider(c.ClusterDesired)
 	if err != nil {
+		attachIPPool(c.ClusterDesired)
 		return err
 	}
 	if cloudProvider == nil {
+		attachIPPool(c.ClusterDesired)
 		return fmt.Errorf("new cloud provider failed")
 	}
+	c.provider = cloudProvider
 	err = cloudProvider.Apply()
+	c.infraDeleted = utils.RemoveIPList(provisioned, append(c.ClusterDesired.Spec.Masters.IPList, c.ClusterDesired.Spec.Nodes.IPList...))
+	attachIPPool(c.ClusterDesired)
 	if err != nil {
 		return err
 	}
 	return utils.SaveClusterfile(c.ClusterDesired)
 }
 
+// detachIPPool removes the hosts in the ip pool from the ip list of masters and nodes.
+func detachIPPool(cluster *v1.Cluster) {
+	for _, hosts := range []*v1.Hosts{&cluster.Spec.Masters, &cluster.Spec.Nodes} {
+		hosts.IPList = utils.RemoveIPList(hosts.IPList, hosts.IPPool)
+	}
+}
+
+// attachIPPool appends the hosts in the ip pool to the ip list of masters and nodes, after the provisioned ones,
+// so master0 is always an instance of the cloud provider.
+func attachIPPool(cluster *v1.Cluster) {
+	for _, hosts := range []*v1.Hosts{&cluster.Spec.Masters, &cluster.Spec.Nodes} {
+		hosts.IPList = utils.AppendIPList(hosts.IPList, hosts.IPPool)
+	}
+}
+
 func (c *CloudApplier) fillClusterCurrent() error {
 	client, err := k8s.Newk8sClient()
 	if err != nil {
@@ -149,77 +173,45 @@ func (c *CloudApplier) fillClusterCurrent() error {
 	return nil
 }
 
-func (c *CloudApplier) runRemoteApply() error {
-	client, err := ssh.NewSSHClientWithCluster(c.ClusterDesired)
-	if err != nil {
-		return fmt.Errorf("prepare cluster ssh client failed %v", err)
-	}
-
-	err = generateTmpClusterfile(c.ClusterDesired)
-	if err != nil {
-		return fmt.Errorf("failed to generate TmpClusterfile, %v", err)
-	}
-	defer func() {
-		if err := utils.CleanFiles(common.TmpClusterfile); err != nil {
-			logger.Error("failed to clean %s, err: %v", common.TmpClusterfile, err)
-		}
-	}()
-
-	err = runtime.PreInitMaster0(client.SSH, client.Host)
-	if err != nil {
+// applyHosts applies the cluster on the hosts created by the cloud provider from here as a baremetal one, so no
+// sealer binary is sent to them, the hosts in the private network are reached through the EIP of master0.
+func (c *CloudApplier) applyHosts() error {
+	cluster := c.ClusterDesired.DeepCopy()
+	cluster.Spec.Provider = common.BAREMETAL
+	hosts := append(append([]string{}, cluster.Spec.Masters.IPList...), cluster.Spec.Nodes.IPList...)
+	if err := infra.WaitHostsReady(c.provider, ssh.NewSSHByCluster(cluster), hosts...); err != nil {
 		return err
 	}
-
-	err = client.SSH.CmdAsync(client.Host, fmt.Sprintf(ApplyCluster, common.RemoteSealerPath, common.RemoteSealerPath, common.TmpClusterfile))
+	fs, err := filesystem.NewFilesystem()
 	if err != nil {
 		return err
 	}
-
-	err = runtime.GetKubectlAndKubeconfig(client.SSH, client.Host)
-	if err != nil {
-		return fmt.Errorf("failed to copy kubeconfig and kubectl %v", err)
-	}
-	return nil
-}
-
-func generateTmpClusterfile(cluster *v1.Cluster) error {
-	cluster.Spec.Provider = common.BAREMETAL
-	clusterfile := cluster.GetAnnotationsByKey(common.ClusterfileName)
-	data, err := yaml.Marshal(cluster)
-	if err != nil {
-		return fmt.Errorf("failed to marshal cluster, %v", err)
-	}
-	if clusterfile == "" {
-		return utils.WriteFile(common.TmpClusterfile, data)
-	}
-	appendData := [][]byte{data}
-	plugins, err := utils.DecodePlugins(clusterfile)
+	imgSvc, err := image.NewImageService()
 	if err != nil {
 		return err
 	}
-	for _, plugin := range plugins {
-		data, err := yaml.Marshal(plugin)
-		if err != nil {
-			return fmt.Errorf("failed to marshal plugin, %v", err)
-		}
-		appendData = append(appendData, []byte("---\n"), data)
+	applier := &Applier{
+		ClusterDesired: cluster,
+		ImageManager:   imgSvc,
+		FileSystem:     fs,
+	}
+	err = applier.Apply()
+	// the baremetal one is saved by the applier, Clusterfile keeps the cloud provider to scale the infra later.
+	if saveErr := utils.SaveClusterfile(c.ClusterDesired); saveErr != nil && err == nil {
+		err = saveErr
 	}
-
-	configs, err := utils.DecodeConfigs(clusterfile)
 	if err != nil {
 		return err
 	}
-	for _, config := range configs {
-		data, err := yaml.Marshal(config)
-		if err != nil {
-			return fmt.Errorf("failed to marshal config, %v", err)
-		}
-		appendData = append(appendData, []byte("---\n"), data)
-	}
+	return useEIPForAPIServer(cluster)
+}
 
-	err = utils.WriteFile(common.TmpClusterfile, bytes.Join(appendData, []byte("")))
-	if err != nil {
-		return err
+// useEIPForAPIServer points the kubeconfig to the EIP of master0 here, instead of its private IP set by the applier,
+// which is not reachable out of the private network.
+func useEIPForAPIServer(cluster *v1.Cluster) error {
+	eip := cluster.GetAnnotationsByKey(common.Eip)
+	if eip == "" {
+		return nil
 	}
-	return nil
+	return runtime.PointKubeconfigToHost(common.DefaultKubeConfigFile(), eip, common.APIServerDomain)
 }
@@ -0,0 +1,214 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client is the Go API to apply and delete clusters by sealer in other programs.
+//
+//	c, err := client.NewClient(client.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	return c.Apply(ctx, cluster)
+//
+// The ssh of hosts, the hosts provisioning and the image service of registry can be replaced by Options.
+// A cluster is applied or deleted by one client at a time in a process, the clients of different clusters run
+// concurrently. The proxy of cluster is set to its hosts only, the outbound HTTP calls of the process, like pulling
+// the CloudImage, use the proxy environment of the process.
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/alibaba/sealer/apply/v2/applydriver"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/filesystem"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// Provisioner creates the hosts of cluster before it is applied and releases them after it is deleted,
+// like the infra of a cloud provider. It sets the hosts of cluster when provisioning.
+type Provisioner interface {
+	Provision(ctx context.Context, cluster *v2.Cluster) error
+	Release(ctx context.Context, cluster *v2.Cluster) error
+}
+
+type Options struct {
+	// SSH returns the ssh client of the hosts of cluster, the ssh in Clusterfile is used if it is nil.
+	SSH ssh.Provider
+	// Provisioner creates the hosts of cluster, the hosts in cluster are used as they are if it is nil.
+	Provisioner Provisioner
+	// ImageService pulls the CloudImage of cluster from registry.
+	ImageService image.Service
+	ImageStore   store.ImageStore
+	FileSystem   filesystem.Interface
+	// Documents are applied with cluster like the other documents of Clusterfile, such as Config and Plugin.
+	Documents []interface{}
+}
+
+type Client struct {
+	opts Options
+	// newDriver returns the applier of cluster, it is replaced in tests.
+	newDriver func(cluster *v2.Cluster) applydriver.Interface
+}
+
+var (
+	// busy holds the clusters being applied or deleted by the clients of the process, the ssh provider and the
+	// context of remote commands are bound to the name of cluster.
+	busyLock sync.Mutex
+	busy     = map[string]bool{}
+)
+
+func acquire(clusterName string) error {
+	busyLock.Lock()
+	defer busyLock.Unlock()
+	if busy[clusterName] {
+		return fmt.Errorf("cluster %s is being applied or deleted by another client", clusterName)
+	}
+	busy[clusterName] = true
+	return nil
+}
+
+func release(clusterName string) {
+	busyLock.Lock()
+	defer busyLock.Unlock()
+	delete(busy, clusterName)
+}
+
+// NewClient returns the client of opts, the unset services of opts are the default ones of sealer.
+func NewClient(opts Options) (*Client, error) {
+	var err error
+	if opts.ImageService == nil {
+		if opts.ImageService, err = image.NewImageService(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ImageStore == nil {
+		if opts.ImageStore, err = store.NewDefaultImageStore(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.FileSystem == nil {
+		if opts.FileSystem, err = filesystem.NewFilesystem(); err != nil {
+			return nil, err
+		}
+	}
+	c := &Client{opts: opts}
+	c.newDriver = c.defaultDriver
+	return c, nil
+}
+
+func (c *Client) defaultDriver(cluster *v2.Cluster) applydriver.Interface {
+	return &applydriver.Applier{
+		ClusterDesired: cluster,
+		ImageManager:   c.opts.ImageService,
+		FileSystem:     c.opts.FileSystem,
+		ImageStore:     c.opts.ImageStore,
+	}
+}
+
+// Apply creates cluster, or scales, upgrades and installs applications to it if it exists.
+func (c *Client) Apply(ctx context.Context, cluster *v2.Cluster) error {
+	cluster = cluster.DeepCopy()
+	if c.opts.Provisioner != nil {
+		if err := c.opts.Provisioner.Provision(ctx, cluster); err != nil {
+			return fmt.Errorf("failed to provision hosts of cluster %s: %v", cluster.Name, err)
+		}
+	}
+	applier, cleanup, err := c.newApplier(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return applier.Apply(ctx)
+}
+
+// Delete deletes cluster and releases its hosts.
+func (c *Client) Delete(ctx context.Context, cluster *v2.Cluster) error {
+	cluster = cluster.DeepCopy()
+	applier, cleanup, err := c.newApplier(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err = applier.Delete(ctx); err != nil {
+		return err
+	}
+	if c.opts.Provisioner != nil {
+		if err = c.opts.Provisioner.Release(ctx, cluster); err != nil {
+			return fmt.Errorf("failed to release hosts of cluster %s: %v", cluster.Name, err)
+		}
+	}
+	return nil
+}
+
+// newApplier writes cluster and the documents to a Clusterfile read by the phases of apply, and replaces
+// the ssh of cluster until cleanup is called. The phases of the applier stop once ctx is done.
+func (c *Client) newApplier(ctx context.Context, cluster *v2.Cluster) (applydriver.Interface, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if cluster.Name == "" || len(cluster.Spec.Hosts) == 0 {
+		return nil, nil, fmt.Errorf("name and hosts of cluster are required")
+	}
+	if err := acquire(cluster.Name); err != nil {
+		return nil, nil, err
+	}
+	dir, err := writeClusterfile(cluster, c.opts.Documents)
+	if err != nil {
+		release(cluster.Name)
+		return nil, nil, err
+	}
+	cluster.SetAnnotations(common.ClusterfileName, filepath.Join(dir, common.DefaultClusterFileName))
+	if c.opts.SSH != nil {
+		ssh.RegisterProvider(cluster.Name, c.opts.SSH)
+	}
+
+	cleanup := func() {
+		if c.opts.SSH != nil {
+			ssh.UnregisterProvider(cluster.Name)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Warn("failed to remove %s: %v", dir, err)
+		}
+		release(cluster.Name)
+	}
+	return c.newDriver(cluster), cleanup, nil
+}
+
+// writeClusterfile writes the Clusterfile to a private dir out of the dirs of sealer, which are pruned by
+// sealer image prune, and returns the dir.
+func writeClusterfile(cluster *v2.Cluster, documents []interface{}) (string, error) {
+	data, err := utils.MarshalConfigsYaml(append([]interface{}{cluster}, documents...)...)
+	if err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir("", "sealer-client-")
+	if err != nil {
+		return "", err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, common.DefaultClusterFileName), data, common.FileMode0600); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
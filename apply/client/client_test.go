@@ -0,0 +1,259 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alibaba/sealer/apply/v2/applydriver"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// fakeSSH records the commands run on the hosts, the other methods of ssh.Interface are not used.
+type fakeSSH struct {
+	ssh.Interface
+	lock sync.Mutex
+	cmds []string
+}
+
+func (f *fakeSSH) CmdAsync(host string, cmd ...string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.cmds = append(f.cmds, host+": "+strings.Join(cmd, " "))
+	return nil
+}
+
+func (f *fakeSSH) provider(hostIP string, cluster *v2.Cluster) (ssh.Interface, error) {
+	return f, nil
+}
+
+// fakeDriver runs a command on every host of cluster by the ssh of client, like the phases of applier.
+type fakeDriver struct {
+	applydriver.Interface
+	cluster *v2.Cluster
+	// block makes Apply wait until it is closed
+	block       chan struct{}
+	started     chan struct{}
+	clusterfile string
+	err         error
+}
+
+func (d *fakeDriver) run(ctx context.Context, cmd string) error {
+	if d.started != nil {
+		close(d.started)
+	}
+	if d.block != nil {
+		<-d.block
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(d.cluster.GetAnnotationsByKey(common.ClusterfileName))
+	if err != nil {
+		return err
+	}
+	d.clusterfile = string(data)
+	for _, ip := range append(d.cluster.GetMasterIPList(), d.cluster.GetNodeIPList()...) {
+		client, err := ssh.GetHostSSHClient(ip, d.cluster)
+		if err != nil {
+			return err
+		}
+		if err = client.CmdAsync(ip, cmd); err != nil {
+			return err
+		}
+	}
+	return d.err
+}
+
+func (d *fakeDriver) Apply(ctx context.Context) error {
+	return d.run(ctx, "kubeadm init")
+}
+
+func (d *fakeDriver) Delete(ctx context.Context) error {
+	return d.run(ctx, "kubeadm reset -f")
+}
+
+type fakeProvisioner struct {
+	hosts    []v2.Host
+	released bool
+}
+
+func (p *fakeProvisioner) Provision(ctx context.Context, cluster *v2.Cluster) error {
+	cluster.Spec.Hosts = p.hosts
+	return nil
+}
+
+func (p *fakeProvisioner) Release(ctx context.Context, cluster *v2.Cluster) error {
+	p.released = true
+	return nil
+}
+
+func newTestCluster(name string) *v2.Cluster {
+	cluster := &v2.Cluster{}
+	cluster.Name = name
+	cluster.Spec.Image = "kubernetes:v1.19.8"
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{common.MASTER}},
+		{IPS: []string{"192.168.0.3"}, Roles: []string{common.NODE}},
+	}
+	return cluster
+}
+
+func newTestClient(opts Options, driver *fakeDriver) *Client {
+	return &Client{opts: opts, newDriver: func(cluster *v2.Cluster) applydriver.Interface {
+		driver.cluster = cluster
+		return driver
+	}}
+}
+
+func TestClient_Apply(t *testing.T) {
+	fake := &fakeSSH{}
+	driver := &fakeDriver{}
+	c := newTestClient(Options{SSH: fake.provider, Documents: []interface{}{map[string]string{"kind": "Config"}}}, driver)
+
+	cluster := newTestCluster("my-cluster")
+	cluster.Spec.Proxy.HTTPProxy = "http://proxy.example.com:3128"
+	env := os.Getenv(runtime.EnvHTTPProxy)
+	if err := c.Apply(context.Background(), cluster); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"192.168.0.2: kubeadm init", "192.168.0.3: kubeadm init"}
+	if !reflect.DeepEqual(fake.cmds, want) {
+		t.Errorf("commands = %v, want %v", fake.cmds, want)
+	}
+	if !strings.Contains(driver.clusterfile, "name: my-cluster") || !strings.Contains(driver.clusterfile, "kind: Config") {
+		t.Errorf("expected cluster and documents in Clusterfile, got %s", driver.clusterfile)
+	}
+	if got := os.Getenv(runtime.EnvHTTPProxy); got != env {
+		t.Errorf("expected the proxy env of process unchanged, got %s", got)
+	}
+	if _, err := os.Stat(driver.cluster.GetAnnotationsByKey(common.ClusterfileName)); !os.IsNotExist(err) {
+		t.Errorf("expected Clusterfile removed after apply, got %v", err)
+	}
+	if cluster.GetAnnotationsByKey(common.ClusterfileName) != "" {
+		t.Errorf("expected cluster of caller unchanged")
+	}
+
+	// the ssh provider is unregistered after apply, the ssh of Clusterfile is used again.
+	client, err := ssh.GetHostSSHClient("192.168.0.2", cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(*fakeSSH); ok {
+		t.Errorf("expected fake ssh unregistered after apply")
+	}
+}
+
+func TestClient_ApplyCanceled(t *testing.T) {
+	fake := &fakeSSH{}
+	provisioner := &fakeProvisioner{hosts: newTestCluster("").Spec.Hosts}
+	driver := &fakeDriver{}
+	c := newTestClient(Options{SSH: fake.provider, Provisioner: provisioner}, driver)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Apply(ctx, newTestCluster("my-cluster")); err != context.Canceled {
+		t.Errorf("Apply() error = %v, want %v", err, context.Canceled)
+	}
+
+	// ctx canceled while the cluster is being applied reaches the applier
+	ctx, cancel = context.WithCancel(context.Background())
+	driver.block, driver.started = make(chan struct{}), make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Apply(ctx, newTestCluster("my-cluster"))
+	}()
+	<-driver.started
+	cancel()
+	close(driver.block)
+	if err := <-errCh; err != context.Canceled {
+		t.Errorf("Apply() error = %v, want %v", err, context.Canceled)
+	}
+	if len(fake.cmds) != 0 {
+		t.Errorf("expected no command run after canceled, got %v", fake.cmds)
+	}
+}
+
+func TestClient_ApplySameCluster(t *testing.T) {
+	driver := &fakeDriver{block: make(chan struct{}), started: make(chan struct{})}
+	c := newTestClient(Options{SSH: (&fakeSSH{}).provider}, driver)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Apply(context.Background(), newTestCluster("my-cluster"))
+	}()
+	<-driver.started
+
+	fake := &fakeSSH{}
+	other := newTestClient(Options{SSH: fake.provider}, &fakeDriver{})
+	if err := other.Apply(context.Background(), newTestCluster("my-cluster")); err == nil {
+		t.Errorf("expected error of applying the cluster being applied by another client")
+	}
+	if err := other.Delete(context.Background(), newTestCluster("my-cluster")); err == nil {
+		t.Errorf("expected error of deleting the cluster being applied by another client")
+	}
+	if err := other.Apply(context.Background(), newTestCluster("other-cluster")); err != nil {
+		t.Errorf("Apply() of another cluster error = %v", err)
+	}
+	if len(fake.cmds) != 2 {
+		t.Errorf("expected commands of other-cluster only, got %v", fake.cmds)
+	}
+
+	close(driver.block)
+	if err := <-errCh; err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := other.Apply(context.Background(), newTestCluster("my-cluster")); err != nil {
+		t.Errorf("Apply() after the other client done error = %v", err)
+	}
+}
+
+func TestClient_Delete(t *testing.T) {
+	tests := []struct {
+		name         string
+		driverErr    error
+		wantCmds     []string
+		wantReleased bool
+		wantErr      bool
+	}{
+		{"deleted", nil, []string{"192.168.0.2: kubeadm reset -f", "192.168.0.3: kubeadm reset -f"}, true, false},
+		{"failed", fmt.Errorf("reset failed"), []string{"192.168.0.2: kubeadm reset -f", "192.168.0.3: kubeadm reset -f"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSSH{}
+			provisioner := &fakeProvisioner{}
+			c := newTestClient(Options{SSH: fake.provider, Provisioner: provisioner}, &fakeDriver{err: tt.driverErr})
+			if err := c.Delete(context.Background(), newTestCluster("my-cluster")); (err != nil) != tt.wantErr {
+				t.Fatalf("Delete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(fake.cmds, tt.wantCmds) {
+				t.Errorf("commands = %v, want %v", fake.cmds, tt.wantCmds)
+			}
+			if provisioner.released != tt.wantReleased {
+				t.Errorf("released = %v, want %v", provisioner.released, tt.wantReleased)
+			}
+		})
+	}
+}
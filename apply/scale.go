@@ -22,6 +22,7 @@ import (
 	"github.com/alibaba/sealer/apply/applytype"
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/scaling"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils"
 )
@@ -58,6 +59,9 @@ func NewScaleApplierFromArgs(clusterfile string, scaleArgs *common.RunArgs, flag
 }
 
 func Join(cluster *v1.Cluster, scalingArgs *common.RunArgs) error {
+	if err := validateScaleArgs(cluster, scalingArgs, common.JoinSubCmd); err != nil {
+		return err
+	}
 	switch cluster.Spec.Provider {
 	case common.BAREMETAL:
 		return joinBaremetalNodes(cluster, scalingArgs)
@@ -66,8 +70,17 @@ func Join(cluster *v1.Cluster, scalingArgs *common.RunArgs) error {
 	case common.CONTAINER:
 		return joinInfraNodes(cluster, scalingArgs)
 	default:
-		return fmt.Errorf(" clusterfile provider type is not found ！")
+		return fmt.Errorf("provider %q of the Clusterfile is not supported", cluster.Spec.Provider)
+	}
+}
+
+// validateScaleArgs checks scaleArgs against cluster by the rules of its provider.
+func validateScaleArgs(cluster *v1.Cluster, scaleArgs *common.RunArgs, action string) error {
+	req, err := scaling.NewScaleRequest(action, scaleArgs)
+	if err != nil {
+		return err
 	}
+	return req.Validate(scaling.ClusterOfV1(cluster))
 }
 
 func joinBaremetalNodes(cluster *v1.Cluster, scaleArgs *common.RunArgs) error {
@@ -88,16 +101,53 @@ func joinBaremetalNodes(cluster *v1.Cluster, scaleArgs *common.RunArgs) error {
 	return nil
 }
 
+// joinInfraNodes scales up the cloud provisioned hosts by count, and joins the baremetal hosts by ip list to the
+// ip pool, the masters and nodes can be joined either way in one cluster.
 func joinInfraNodes(cluster *v1.Cluster, scaleArgs *common.RunArgs) error {
-	if (!IsNumber(scaleArgs.Nodes) && scaleArgs.Nodes != "") || (!IsNumber(scaleArgs.Masters) && scaleArgs.Masters != "") {
-		return fmt.Errorf(" Parameter error: The number of join masters or nodes that must be submitted to use cloud service！")
+	if err := scaleInfraHosts(&cluster.Spec.Masters, scaleArgs.Masters, true, true); err != nil {
+		return err
+	}
+	return scaleInfraHosts(&cluster.Spec.Nodes, scaleArgs.Nodes, true, false)
+}
+
+// scaleInfraHosts changes Count of hosts if arg is a number, or the ip pool of hosts if arg is an ip list.
+func scaleInfraHosts(hosts *v1.Hosts, arg string, join, master bool) error {
+	if arg == "" {
+		return nil
+	}
+	if IsNumber(arg) {
+		num := StrToInt(arg)
+		if !join {
+			num = -num
+		}
+		count := StrToInt(hosts.Count) + num
+		// master0 runs sealer to install the cluster, it must be provisioned by the cloud provider.
+		if count < 0 || master && count < 1 {
+			return fmt.Errorf("parameter error: the number of clean masters or nodes that must be less than definition in Clusterfile")
+		}
+		hosts.Count = strconv.Itoa(count)
+		return nil
 	}
-	if scaleArgs.Masters != "" && IsNumber(scaleArgs.Masters) {
-		cluster.Spec.Masters.Count = strconv.Itoa(StrToInt(cluster.Spec.Masters.Count) + StrToInt(scaleArgs.Masters))
+	if err := utils.AssemblyIPList(&arg); err != nil {
+		return err
+	}
+	if !IsIPList(arg) {
+		return fmt.Errorf(" Parameter error: submit the number of hosts provisioned by cloud service or the iplist of baremetal hosts！")
+	}
+	ipList := removeIPListDuplicatesAndEmpty(strings.Split(arg, ","))
+	if join {
+		if in := utils.ReduceIPList(ipList, hosts.IPList); len(in) != 0 {
+			return fmt.Errorf("join hosts %v already in the current cluster", in)
+		}
+		hosts.IPPool = utils.AppendIPList(hosts.IPPool, ipList)
+		hosts.IPList = utils.AppendIPList(hosts.IPList, ipList)
+		return nil
 	}
-	if scaleArgs.Nodes != "" && IsNumber(scaleArgs.Nodes) {
-		cluster.Spec.Nodes.Count = strconv.Itoa(StrToInt(cluster.Spec.Nodes.Count) + StrToInt(scaleArgs.Nodes))
+	if notIn := utils.RemoveIPList(ipList, hosts.IPPool); len(notIn) != 0 {
+		return fmt.Errorf("hosts %v are not in the ip pool, delete the hosts provisioned by cloud service by number", notIn)
 	}
+	hosts.IPPool = utils.RemoveIPList(hosts.IPPool, ipList)
+	hosts.IPList = utils.RemoveIPList(hosts.IPList, ipList)
 	return nil
 }
 
@@ -123,6 +173,9 @@ func removeIPListDuplicatesAndEmpty(ipList []string) []string {
 }
 
 func Delete(cluster *v1.Cluster, scaleArgs *common.RunArgs) error {
+	if err := validateScaleArgs(cluster, scaleArgs, common.DeleteSubCmd); err != nil {
+		return err
+	}
 	switch cluster.Spec.Provider {
 	case common.BAREMETAL:
 		return deleteBaremetalNodes(cluster, scaleArgs)
@@ -131,7 +184,7 @@ func Delete(cluster *v1.Cluster, scaleArgs *common.RunArgs) error {
 	case common.CONTAINER:
 		return deleteInfraNodes(cluster, scaleArgs)
 	default:
-		return fmt.Errorf(" clusterfile provider type is not found ！")
+		return fmt.Errorf("provider %q of the Clusterfile is not supported", cluster.Spec.Provider)
 	}
 }
 
@@ -153,23 +206,13 @@ func deleteBaremetalNodes(cluster *v1.Cluster, scaleArgs *common.RunArgs) error
 	return nil
 }
 
+// deleteInfraNodes scales down the cloud provisioned hosts by count, and deletes the baremetal hosts in the ip pool
+// by ip list.
 func deleteInfraNodes(cluster *v1.Cluster, scaleArgs *common.RunArgs) error {
-	if (!IsNumber(scaleArgs.Nodes) && scaleArgs.Nodes != "") || (!IsNumber(scaleArgs.Masters) && scaleArgs.Masters != "") {
-		return fmt.Errorf(" Parameter error: The number of join masters or nodes that must be submitted to use cloud service！")
-	}
-	if scaleArgs.Masters != "" && IsNumber(scaleArgs.Masters) {
-		cluster.Spec.Masters.Count = strconv.Itoa(StrToInt(cluster.Spec.Masters.Count) - StrToInt(scaleArgs.Masters))
-		if StrToInt(cluster.Spec.Masters.Count) <= 0 {
-			return fmt.Errorf("parameter error: the number of clean masters or nodes that must be less than definition in Clusterfile")
-		}
-	}
-	if scaleArgs.Nodes != "" && IsNumber(scaleArgs.Nodes) {
-		cluster.Spec.Nodes.Count = strconv.Itoa(StrToInt(cluster.Spec.Nodes.Count) - StrToInt(scaleArgs.Nodes))
-		if StrToInt(cluster.Spec.Nodes.Count) <= 0 {
-			return fmt.Errorf("parameter error: the number of clean masters or nodes that must be less than definition in Clusterfile")
-		}
+	if err := scaleInfraHosts(&cluster.Spec.Masters, scaleArgs.Masters, false, true); err != nil {
+		return err
 	}
-	return nil
+	return scaleInfraHosts(&cluster.Spec.Nodes, scaleArgs.Nodes, false, false)
 }
 
 func returnFilteredIPList(clusterIPList []string, toBeDeletedIPList []string) (res []string) {
@@ -16,10 +16,12 @@ package apply
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
 )
 
 func TestNewCleanApplierFromArgs(t *testing.T) {
@@ -142,3 +144,43 @@ func Test_returnFilteredIPList(t *testing.T) {
 		})
 	}
 }
+
+func TestScaleInfraHostsHybrid(t *testing.T) {
+	cluster := &v1.Cluster{Spec: v1.ClusterSpec{
+		Provider: common.AliCloud,
+		Masters:  v1.Hosts{Count: "1", IPList: []string{"172.16.0.1"}},
+		Nodes:    v1.Hosts{Count: "2", IPList: []string{"172.16.0.2", "172.16.0.3", "172.16.0.110"}, IPPool: []string{"172.16.0.110"}},
+	}}
+	if err := Join(cluster, &common.RunArgs{Masters: "2", Nodes: "172.16.0.111-172.16.0.112"}); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Spec.Masters.Count != "3" || cluster.Spec.Nodes.Count != "2" {
+		t.Errorf("count of masters %s and nodes %s, want 3 and 2", cluster.Spec.Masters.Count, cluster.Spec.Nodes.Count)
+	}
+	if !reflect.DeepEqual(cluster.Spec.Nodes.IPPool, []string{"172.16.0.110", "172.16.0.112", "172.16.0.111"}) {
+		t.Errorf("ip pool of nodes = %v", cluster.Spec.Nodes.IPPool)
+	}
+	if len(cluster.Spec.Nodes.IPList) != 5 {
+		t.Errorf("ip list of nodes = %v", cluster.Spec.Nodes.IPList)
+	}
+	if err := Join(cluster, &common.RunArgs{Nodes: "172.16.0.2"}); err == nil {
+		t.Error("joining a host in the cluster should fail")
+	}
+
+	if err := Delete(cluster, &common.RunArgs{Nodes: "172.16.0.2"}); err == nil {
+		t.Error("deleting a provisioned host by ip should fail")
+	}
+	if err := Delete(cluster, &common.RunArgs{Masters: "3"}); err == nil {
+		t.Error("deleting all provisioned masters should fail")
+	}
+	if err := Delete(cluster, &common.RunArgs{Nodes: "172.16.0.110,172.16.0.111"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cluster.Spec.Nodes.IPPool, []string{"172.16.0.112"}) ||
+		!reflect.DeepEqual(cluster.Spec.Nodes.IPList, []string{"172.16.0.2", "172.16.0.3", "172.16.0.112"}) {
+		t.Errorf("ip pool %v and ip list %v of nodes after deleting", cluster.Spec.Nodes.IPPool, cluster.Spec.Nodes.IPList)
+	}
+	if err := Delete(cluster, &common.RunArgs{Nodes: "2"}); err != nil || cluster.Spec.Nodes.Count != "0" {
+		t.Errorf("deleting all provisioned nodes, count %s, error %v", cluster.Spec.Nodes.Count, err)
+	}
+}
@@ -25,17 +25,19 @@ import (
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image"
 	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 )
 
 func NewApplierFromFile(clusterfile string) (applydriver.Interface, error) {
 	clusterData, err := ioutil.ReadFile(filepath.Clean(clusterfile))
 	if err != nil {
-		return nil, err
+		return nil, result.Wrap(result.CategoryValidation, "", err)
 	}
 	cluster, err := GetClusterFromDataCompatV1(string(clusterData))
 	if err != nil {
-		return nil, err
+		return nil, result.Wrap(result.CategoryValidation, "", err)
 	}
 	cluster.SetAnnotations(common.ClusterfileName, clusterfile)
 	return NewApplier(cluster)
@@ -58,6 +60,10 @@ func NewApplier(cluster *v2.Cluster) (applydriver.Interface, error) {
 }*/
 
 func NewDefaultApplier(cluster *v2.Cluster) (applydriver.Interface, error) {
+	// before pulling the image, which is the first outbound HTTP call.
+	if err := runtime.SetProxyEnv(cluster); err != nil {
+		return nil, err
+	}
 	imgSvc, err := image.NewImageService()
 	if err != nil {
 		return nil, err
@@ -14,7 +14,25 @@
 
 package applydriver
 
+import (
+	"context"
+
+	"github.com/alibaba/sealer/pkg/clusterdiff"
+)
+
+// Interface applies or deletes the desired cluster, the running phase is aborted once ctx is done.
 type Interface interface {
-	Apply() error
-	Delete() error
+	Apply(ctx context.Context) error
+	Delete(ctx context.Context) error
+	// Takeover brings the running cluster not created by sealer under its management without resetting the hosts.
+	Takeover(ctx context.Context) error
+	// Restore rebuilds the cluster on the hosts of ClusterDesired from the backup extracted to dir.
+	Restore(ctx context.Context, dir string) error
+	// RecoverMaster0 makes the first master of ClusterDesired master0 in place of the lost one.
+	RecoverMaster0(ctx context.Context, lost string) error
+	// Resume continues the operation on ClusterDesired stopped with the machine running it, from its checkpoint
+	// on master0.
+	Resume(ctx context.Context) error
+	// Plan returns what Apply would do to the cluster, without doing it.
+	Plan() (*clusterdiff.Plan, error)
 }
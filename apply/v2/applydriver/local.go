@@ -15,6 +15,7 @@
 package applydriver
 
 import (
+	"context"
 	"fmt"
 
 	v2 "github.com/alibaba/sealer/types/api/v2"
@@ -23,7 +24,15 @@ import (
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image/store"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/checker"
+	"github.com/alibaba/sealer/pkg/checkpoint"
+	"github.com/alibaba/sealer/pkg/clusterdiff"
+	"github.com/alibaba/sealer/pkg/deprecation"
 	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/staticpod"
+	"github.com/alibaba/sealer/pkg/systemd"
+	"github.com/alibaba/sealer/pkg/tracing"
+	"github.com/alibaba/sealer/pkg/webhook"
 
 	"github.com/pkg/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,6 +41,7 @@ import (
 	"github.com/alibaba/sealer/image"
 	"github.com/alibaba/sealer/pkg/filesystem"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
 )
 
 // Applier cloud builder using cloud provider to build a cluster image
@@ -44,25 +54,199 @@ type Applier struct {
 	ImageStore     store.ImageStore
 }
 
-func (c *Applier) Delete() (err error) {
+func (c *Applier) Delete(ctx context.Context) (err error) {
+	ctx, end := tracing.Start(ctx, "delete", tracing.Attr("cluster", c.ClusterDesired.Name))
+	defer func() {
+		end(err)
+	}()
+	defer ssh.BindContext(c.ClusterDesired.Name, ctx)()
 	t := metav1.Now()
 	c.ClusterDesired.DeletionTimestamp = &t
-	return c.deleteCluster()
+	return c.deleteCluster(ctx)
 }
 
 // Apply different actions between ClusterDesired and ClusterCurrent.
-func (c *Applier) Apply() (err error) {
-	// first time to init cluster
-	if !utils.IsFileExist(common.DefaultKubeConfigFile()) {
-		if err = c.initCluster(); err != nil {
+func (c *Applier) Apply(ctx context.Context) (err error) {
+	ctx, end := tracing.Start(ctx, "apply", tracing.Attr("cluster", c.ClusterDesired.Name), tracing.Attr("image", c.ClusterDesired.Spec.Image))
+	hosts := append(c.ClusterDesired.GetMasterIPList(), c.ClusterDesired.GetNodeIPList()...)
+	webhook.Notify(c.ClusterDesired, webhook.ApplyStarted, hosts, nil)
+	defer func() {
+		end(err)
+		if err != nil {
+			webhook.Notify(c.ClusterDesired, webhook.ApplyFailed, hosts, err)
+		} else {
+			webhook.Notify(c.ClusterDesired, webhook.ApplySucceeded, hosts, nil)
+		}
+	}()
+	// the remote commands on the hosts of cluster are canceled with ctx.
+	defer ssh.BindContext(c.ClusterDesired.Name, ctx)()
+	defer observeRegistryProxies(c.ClusterDesired)
+	// read before the Clusterfile is saved by this apply.
+	applied := appliedImages(c.ClusterDesired.Name)
+	// first time to init cluster, or resume the creation of it.
+	if cp := processor.ResumingFrom(ctx); !utils.IsFileExist(common.DefaultKubeConfigFile()) || cp != nil && cp.Operation == processor.OperationCreate {
+		applied = nil
+		if err = c.initCluster(ctx); err != nil {
+			return err
+		}
+		// the labels and taints of the hosts are set after the nodes created.
+		if err = c.reconcileHosts(ctx, &v2.Cluster{}); err != nil {
 			return err
 		}
 	} else {
-		if err = c.reconcileCluster(); err != nil {
+		if err = c.reconcileCluster(ctx); err != nil {
+			return err
+		}
+	}
+
+	if utils.NotIn(c.ClusterDesired.Spec.Image, applied) {
+		applied = append(applied, c.ClusterDesired.Spec.Image)
+	}
+	c.ClusterDesired.Status.AppliedImages = applied
+	return utils.SaveClusterInfoToFile(c.ClusterDesired, c.ClusterDesired.Name)
+}
+
+// Resume continues the operation on ClusterDesired stopped with the machine running it, maybe another one, from
+// the checkpoint on master0: the phases completed before are skipped, and the state of the cluster kept here is
+// fetched from master0 if it was initialized.
+func (c *Applier) Resume(ctx context.Context) error {
+	cluster := c.ClusterDesired
+	master0 := cluster.GetMaster0Ip()
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return err
+	}
+	cp, err := checkpoint.LoadRemote(client, master0, cluster.Name)
+	if err != nil {
+		return err
+	}
+	if cp == nil {
+		return fmt.Errorf("no checkpoint of cluster %s is on master0 %s, there is nothing to resume", cluster.Name, master0)
+	}
+	if cp.Image != cluster.Spec.Image {
+		return fmt.Errorf("the checkpoint of cluster %s is of image %s, but %s is in Clusterfile", cluster.Name, cp.Image, cluster.Spec.Image)
+	}
+	logger.Info("resume the %s of cluster %s at phase %s, the completed phases are %v", cp.Operation, cluster.Name, cp.Phase, cp.CompletedPhases)
+	if len(cp.FailedHosts) > 0 {
+		logger.Info("phase %s failed on %v", cp.Phase, cp.FailedHosts)
+	}
+	if cp.Operation != processor.OperationCreate || utils.InList("Init", cp.CompletedPhases) {
+		if err = runtime.RestoreLocalState(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName)); err != nil {
+			return fmt.Errorf("failed to restore the state of cluster %s from master0: %v", cluster.Name, err)
+		}
+	}
+	return c.Apply(processor.WithResume(ctx, cp))
+}
+
+// Takeover installs the missing sealer bits on the hosts of the running cluster, which must be the hosts of
+// ClusterDesired, the changes of the Clusterfile are applied by Apply afterwards.
+func (c *Applier) Takeover(ctx context.Context) (err error) {
+	ctx, end := tracing.Start(ctx, "takeover", tracing.Attr("cluster", c.ClusterDesired.Name), tracing.Attr("image", c.ClusterDesired.Spec.Image))
+	defer func() {
+		end(err)
+	}()
+	defer ssh.BindContext(c.ClusterDesired.Name, ctx)()
+	if !utils.IsFileExist(common.DefaultKubeConfigFile()) {
+		return fmt.Errorf("no running cluster to take over, %s is not found", common.DefaultKubeConfigFile())
+	}
+	client, err := k8s.Newk8sClient()
+	if err != nil {
+		return err
+	}
+	c.Client = client
+	if err = c.fillClusterCurrent(); err != nil {
+		return err
+	}
+	mj, md := utils.GetDiffHosts(c.ClusterCurrent.GetMasterIPList(), c.ClusterDesired.GetMasterIPList())
+	nj, nd := utils.GetDiffHosts(c.ClusterCurrent.GetNodeIPList(), c.ClusterDesired.GetNodeIPList())
+	if len(mj) > 0 || len(md) > 0 || len(nj) > 0 || len(nd) > 0 {
+		return fmt.Errorf("the hosts in Clusterfile differ from the nodes of the running cluster, masters +%v -%v, nodes +%v -%v, "+
+			"take over the running hosts first, then apply to join or delete hosts", mj, md, nj, nd)
+	}
+
+	if err = c.mountClusterImage(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.unMountClusterImage(); err != nil {
+			logger.Warn("failed to umount image %s, %v", c.ClusterDesired.ClusterName, err)
+		}
+	}()
+
+	logger.Info("Start to take over this cluster")
+	takeoverProcessor, err := processor.NewTakeoverProcessor(c.FileSystem)
+	if err != nil {
+		return err
+	}
+	if err = takeoverProcessor.Execute(ctx, c.ClusterDesired); err != nil {
+		return err
+	}
+	logger.Info("Succeeded in taking over this cluster")
+
+	c.ClusterDesired.Status.AppliedImages = []string{c.ClusterDesired.Spec.Image}
+	return utils.SaveClusterInfoToFile(c.ClusterDesired, c.ClusterDesired.Name)
+}
+
+// Restore resets the hosts of ClusterDesired and rebuilds the cluster from the backup extracted to dir, the
+// sealer state of the backup must be restored to the local host before.
+func (c *Applier) Restore(ctx context.Context, dir string) (err error) {
+	ctx, end := tracing.Start(ctx, "restore", tracing.Attr("cluster", c.ClusterDesired.Name), tracing.Attr("image", c.ClusterDesired.Spec.Image))
+	defer func() {
+		end(err)
+	}()
+	defer ssh.BindContext(c.ClusterDesired.Name, ctx)()
+	// the kubeconfig of the lost cluster is replaced by the one of the restored cluster.
+	if runtime.KubeconfigHasContext(common.DefaultKubeConfigFile(), c.ClusterDesired.Name) {
+		if err = runtime.RemoveKubeconfigContext(common.DefaultKubeConfigFile(), c.ClusterDesired.Name); err != nil {
 			return err
 		}
 	}
 
+	logger.Info("Start to restore the cluster from backup")
+	restoreProcessor, err := processor.NewRestoreProcessor(dir)
+	if err != nil {
+		return err
+	}
+	if err = restoreProcessor.Execute(ctx, c.ClusterDesired); err != nil {
+		return err
+	}
+	if err = c.reconcileHosts(ctx, &v2.Cluster{}); err != nil {
+		return err
+	}
+	logger.Info("Succeeded in restoring the cluster")
+
+	c.ClusterDesired.Status.AppliedImages = []string{c.ClusterDesired.Spec.Image}
+	return utils.SaveClusterInfoToFile(c.ClusterDesired, c.ClusterDesired.Name)
+}
+
+// RecoverMaster0 promotes the first master of ClusterDesired to master0 of the running cluster, in place of the
+// lost one, which is not in ClusterDesired.
+func (c *Applier) RecoverMaster0(ctx context.Context, lost string) (err error) {
+	ctx, end := tracing.Start(ctx, "recover-master0", tracing.Attr("cluster", c.ClusterDesired.Name), tracing.Attr("lost", lost))
+	defer func() {
+		end(err)
+	}()
+	defer ssh.BindContext(c.ClusterDesired.Name, ctx)()
+	if err = c.mountClusterImage(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.unMountClusterImage(); err != nil {
+			logger.Warn("failed to umount image %s, %v", c.ClusterDesired.ClusterName, err)
+		}
+	}()
+
+	logger.Info("Start to promote %s to master0 in place of %s", c.ClusterDesired.GetMaster0Ip(), lost)
+	recoverProcessor, err := processor.NewRecoverMaster0Processor(lost)
+	if err != nil {
+		return err
+	}
+	if err = recoverProcessor.Execute(ctx, c.ClusterDesired); err != nil {
+		return err
+	}
+	logger.Info("Succeeded in promoting %s to master0", c.ClusterDesired.GetMaster0Ip())
+
+	c.ClusterDesired.Status.AppliedImages = appliedImages(c.ClusterDesired.Name)
 	return utils.SaveClusterInfoToFile(c.ClusterDesired, c.ClusterDesired.Name)
 }
 
@@ -84,6 +268,9 @@ func (c *Applier) mountClusterImage() error {
 	if err != nil {
 		return err
 	}
+	if err = deprecation.CheckImage(imageName); err != nil {
+		return err
+	}
 	err = c.FileSystem.MountImage(c.ClusterDesired)
 	if err != nil {
 		return err
@@ -95,7 +282,7 @@ func (c *Applier) unMountClusterImage() error {
 	return c.FileSystem.UnMountImage(c.ClusterDesired)
 }
 
-func (c *Applier) reconcileCluster() error {
+func (c *Applier) reconcileCluster(ctx context.Context) error {
 	client, err := k8s.Newk8sClient()
 	if err != nil {
 		return err
@@ -115,29 +302,99 @@ func (c *Applier) reconcileCluster() error {
 		}
 	}()
 
-	baseImage, err := c.ImageStore.GetByName(c.ClusterDesired.Spec.Image)
+	baseImage, err := runtime.GetClusterImage(c.ImageStore, c.ClusterDesired)
 	if err != nil {
 		return fmt.Errorf("failed to get base image err: %s", err)
 	}
 	// if no rootfs ,try to install applications
 	if !withRootfs(baseImage) {
-		return c.installApp()
+		return c.installApp(ctx)
 	}
 
 	mj, md := utils.GetDiffHosts(c.ClusterCurrent.GetMasterIPList(), c.ClusterDesired.GetMasterIPList())
 	nj, nd := utils.GetDiffHosts(c.ClusterCurrent.GetNodeIPList(), c.ClusterDesired.GetNodeIPList())
 
-	if err := c.scaleCluster(mj, md, nj, nd); err != nil {
+	if err := c.scaleCluster(ctx, mj, md, nj, nd); err != nil {
 		return err
 	}
 
-	if err := c.upgradeCluster(mj, nj); err != nil {
+	previous, err := previousCluster(c.ClusterDesired.Name)
+	if err != nil {
+		logger.Warn("failed to load the cluster last applied, skip reconciling the changes of hosts: %v", err)
+	} else if err := c.reconcileHosts(ctx, previous); err != nil {
+		return err
+	}
+
+	if err := c.upgradeCluster(ctx, mj, nj); err != nil {
+		return err
+	}
+
+	// the systemd units and static pods of CloudImage follow the image and the hosts on every apply.
+	hosts := append(c.ClusterDesired.GetMasterIPList(), c.ClusterDesired.GetNodeIPList()...)
+	if err := systemd.Install(c.ClusterDesired, hosts); err != nil {
+		return fmt.Errorf("failed to install systemd units: %v", err)
+	}
+	if err := staticpod.Apply(c.ClusterDesired, hosts); err != nil {
+		return fmt.Errorf("failed to apply static pods: %v", err)
+	}
+	return nil
+}
+
+// reconcileHosts applies the changes of the hosts from previous to the desired cluster, like env, labels,
+// taints and kubernetes configs.
+func (c *Applier) reconcileHosts(ctx context.Context, previous *v2.Cluster) error {
+	changes := clusterdiff.Diff(previous, c.ClusterDesired)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	logger.Info("Start to reconcile the changes of hosts")
+	reconcileProcessor, err := processor.NewReconcileProcessor(c.FileSystem, previous, changes)
+	if err != nil {
+		return err
+	}
+	if err := reconcileProcessor.Execute(ctx, c.ClusterDesired); err != nil {
 		return err
 	}
+
+	logger.Info("Succeeded in reconciling the changes of hosts")
 	return nil
 }
 
-func (c *Applier) scaleCluster(mj, md, nj, nd []string) error {
+// Plan returns the hosts to join and delete and the changes of the hosts applying ClusterDesired does.
+// The kubernetes version of the image is not checked, as the image is not pulled.
+func (c *Applier) Plan() (*clusterdiff.Plan, error) {
+	if !utils.IsFileExist(common.DefaultKubeConfigFile()) {
+		return &clusterdiff.Plan{
+			JoinMasters: c.ClusterDesired.GetMasterIPList(),
+			JoinNodes:   c.ClusterDesired.GetNodeIPList(),
+			Image:       c.ClusterDesired.Spec.Image,
+			Changes:     clusterdiff.Diff(&v2.Cluster{}, c.ClusterDesired),
+		}, nil
+	}
+	client, err := k8s.Newk8sClient()
+	if err != nil {
+		return nil, err
+	}
+	c.Client = client
+	if err := c.fillClusterCurrent(); err != nil {
+		return nil, err
+	}
+	plan := &clusterdiff.Plan{}
+	plan.JoinMasters, plan.DeleteMasters = utils.GetDiffHosts(c.ClusterCurrent.GetMasterIPList(), c.ClusterDesired.GetMasterIPList())
+	plan.JoinNodes, plan.DeleteNodes = utils.GetDiffHosts(c.ClusterCurrent.GetNodeIPList(), c.ClusterDesired.GetNodeIPList())
+	previous, err := previousCluster(c.ClusterDesired.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the cluster last applied: %v", err)
+	}
+	if previous.Spec.Image != c.ClusterDesired.Spec.Image {
+		plan.Image = c.ClusterDesired.Spec.Image
+	}
+	plan.Changes = clusterdiff.Diff(previous, c.ClusterDesired)
+	return plan, nil
+}
+
+func (c *Applier) scaleCluster(ctx context.Context, mj, md, nj, nd []string) error {
 	if len(mj) == 0 && len(md) == 0 && len(nj) == 0 && len(nd) == 0 {
 		return nil
 	}
@@ -159,17 +416,22 @@ func (c *Applier) scaleCluster(mj, md, nj, nd []string) error {
 	} else {
 		cluster = c.ClusterDesired
 	}
-	err = scaleProcessor.Execute(cluster)
+	err = scaleProcessor.Execute(ctx, cluster)
 	if err != nil {
 		return err
 	}
 
 	logger.Info("Succeeded in scaling this cluster")
+	if scaleProcessor.(processor.ScaleProcessor).IsScaleUp {
+		webhook.Notify(c.ClusterDesired, webhook.NodesJoined, append(mj, nj...), nil)
+	} else {
+		webhook.Notify(c.ClusterDesired, webhook.NodesDeleted, append(md, nd...), nil)
+	}
 
 	return nil
 }
 
-func (c *Applier) upgradeCluster(mj, nj []string) error {
+func (c *Applier) upgradeCluster(ctx context.Context, mj, nj []string) error {
 	// use k8sClient to fetch current cluster version.
 	info, err := c.Client.GetClusterVersion()
 	if err != nil {
@@ -188,13 +450,16 @@ func (c *Applier) upgradeCluster(mj, nj []string) error {
 	if info.GitVersion == clusterMetadata.Version {
 		return nil
 	}
+	if err = c.checkUpgrade(info.GitVersion); err != nil {
+		return err
+	}
 
 	logger.Info("Start to upgrade this cluster from version(%s) to version(%s)", info.GitVersion, clusterMetadata.Version)
 	upgradeProcessor, err := processor.NewUpgradeProcessor(c.FileSystem, runtimeInterface, mj, nj)
 	if err != nil {
 		return err
 	}
-	err = upgradeProcessor.Execute(c.ClusterDesired)
+	err = upgradeProcessor.Execute(ctx, c.ClusterDesired)
 	if err != nil {
 		return err
 	}
@@ -204,7 +469,21 @@ func (c *Applier) upgradeCluster(mj, nj []string) error {
 	return nil
 }
 
-func (c *Applier) installApp() error {
+// checkUpgrade checks the cluster of version can be upgraded to CloudImage, and all hosts
+// meet the requirements in its Metadata.
+func (c *Applier) checkUpgrade(version string) error {
+	metadata, err := runtime.LoadMetadata(common.DefaultMountCloudImageDir(c.ClusterDesired.Name))
+	if err != nil || metadata == nil {
+		return err
+	}
+	if !VersionCompatible(version, metadata.UpgradeFrom) {
+		return fmt.Errorf("failed to upgrade this cluster from version(%s), CloudImage only supports upgrading from %s", version, metadata.UpgradeFrom)
+	}
+	hosts := append(c.ClusterDesired.GetMasterIPList(), c.ClusterDesired.GetNodeIPList()...)
+	return checker.RunCheckList([]checker.Interface{checker.NewMetadataChecker(hosts, metadata)}, c.ClusterDesired, checker.PhasePre)
+}
+
+func (c *Applier) installApp(ctx context.Context) error {
 	rootfs := common.DefaultMountCloudImageDir(c.ClusterDesired.Name)
 	// use k8sClient to fetch current cluster version.
 	info, err := c.Client.GetClusterVersion()
@@ -221,12 +500,15 @@ func (c *Applier) installApp() error {
 			return fmt.Errorf("incompatible application version, need: %s", clusterMetadata.KubeVersion)
 		}
 	}
+	if err = runtime.CheckDependencies(clusterMetadata, appliedImages(c.ClusterDesired.Name)); err != nil {
+		return err
+	}
 
 	installProcessor, err := processor.NewInstallProcessor(c.FileSystem)
 	if err != nil {
 		return err
 	}
-	err = installProcessor.Execute(c.ClusterDesired)
+	err = installProcessor.Execute(ctx, c.ClusterDesired)
 	if err != nil {
 		return err
 	}
@@ -234,14 +516,14 @@ func (c *Applier) installApp() error {
 	return nil
 }
 
-func (c *Applier) initCluster() error {
+func (c *Applier) initCluster(ctx context.Context) error {
 	logger.Info("Start to create a new cluster")
 	createProcessor, err := processor.NewCreateProcessor()
 	if err != nil {
 		return err
 	}
 
-	if err := createProcessor.Execute(c.ClusterDesired); err != nil {
+	if err := createProcessor.Execute(ctx, c.ClusterDesired); err != nil {
 		return err
 	}
 
@@ -250,18 +532,19 @@ func (c *Applier) initCluster() error {
 	return nil
 }
 
-func (c *Applier) deleteCluster() error {
+func (c *Applier) deleteCluster(ctx context.Context) error {
 	logger.Info("Start to delete current cluster")
 	deleteProcessor, err := processor.NewDeleteProcessor()
 	if err != nil {
 		return err
 	}
 
-	if err := deleteProcessor.Execute(c.ClusterDesired); err != nil {
+	if err := deleteProcessor.Execute(ctx, c.ClusterDesired); err != nil {
 		return err
 	}
 
 	logger.Info("Succeeded in deleting current cluster")
+	webhook.Notify(c.ClusterDesired, webhook.ClusterDeleted, append(c.ClusterDesired.GetMasterIPList(), c.ClusterDesired.GetNodeIPList()...), nil)
 
 	return nil
 }
@@ -25,7 +25,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/metrics"
+	"github.com/alibaba/sealer/pkg/runtime"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
 )
 
 const MasterRoleLabel = "node-role.kubernetes.io/master"
@@ -102,6 +105,20 @@ func VersionCompatible(version, constraint string) bool {
 	return c.Check(v)
 }
 
+// appliedImages returns the images applied to the cluster in order, which are recorded in the Clusterfile
+// saved by the last apply, the image of a Clusterfile saved by an older sealer is taken as the only one.
+func appliedImages(clusterName string) []string {
+	cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+	if err != nil {
+		return nil
+	}
+	images := cluster.Status.AppliedImages
+	if cluster.Spec.Image != "" && utils.NotIn(cluster.Spec.Image, images) {
+		images = append(images, cluster.Spec.Image)
+	}
+	return images
+}
+
 func withRootfs(image *v1.Image) bool {
 	layer0 := image.Spec.Layers[0]
 	if layer0.Value == ". ." && layer0.Type == common.COPYCOMMAND {
@@ -109,3 +126,34 @@ func withRootfs(image *v1.Image) bool {
 	}
 	return false
 }
+
+// observeRegistryProxies records the hits and misses of the registry proxies of cluster, if metrics are enabled.
+func observeRegistryProxies(cluster *v2.Cluster) {
+	if !metrics.Enabled() {
+		return
+	}
+	cf := runtime.GetRegistryConfig(common.DefaultTheClusterRootfsDir(cluster.Name), cluster.GetMaster0Ip())
+	if len(cf.Proxies) == 0 {
+		return
+	}
+	ip, _ := utils.GetSSHHostIPAndPort(cf.IP)
+	client, err := ssh.GetHostSSHClient(ip, cluster)
+	if err != nil {
+		logger.Warn("failed to get the metrics of registry proxies: %v", err)
+		return
+	}
+	for _, p := range cf.Proxies {
+		blobs, manifests, err := cf.ProxyStats(client, p.Upstream)
+		if err != nil {
+			logger.Warn("%v", err)
+			continue
+		}
+		metrics.ObserveRegistryProxy(cluster.Name, p.Upstream, "blobs", blobs.Hits, blobs.Misses)
+		metrics.ObserveRegistryProxy(cluster.Name, p.Upstream, "manifests", manifests.Hits, manifests.Misses)
+	}
+}
+
+// previousCluster returns the cluster last applied, which is saved in the cluster work dir.
+func previousCluster(clusterName string) (*v2.Cluster, error) {
+	return utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+}
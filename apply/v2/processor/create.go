@@ -15,20 +15,36 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 
 	v2 "github.com/alibaba/sealer/types/api/v2"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/pkg/checker"
+	"github.com/alibaba/sealer/pkg/component"
 	"github.com/alibaba/sealer/pkg/config"
+	"github.com/alibaba/sealer/pkg/deprecation"
 	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/firewall"
 	"github.com/alibaba/sealer/pkg/guest"
+	"github.com/alibaba/sealer/pkg/hostprep"
+	"github.com/alibaba/sealer/pkg/p2p"
 	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/pkg/preload"
+	"github.com/alibaba/sealer/pkg/registries"
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/staticpod"
+	"github.com/alibaba/sealer/pkg/systemd"
+	"github.com/alibaba/sealer/pkg/timesync"
 	"github.com/alibaba/sealer/utils"
 )
 
+// OperationCreate is the operation of the checkpoints of CreateProcessor.
+const OperationCreate = "create"
+
 type CreateProcessor struct {
 	ImageManager image.Service
 	FileSystem   filesystem.Interface
@@ -36,9 +52,12 @@ type CreateProcessor struct {
 	Guest        guest.Interface
 	Config       config.Interface
 	Plugins      plugin.Plugins
+	// pluginsLoaded is whether the plugins of rootfs are loaded, which is done at PreInit, or at the later phases
+	// if PreInit is skipped on resume.
+	pluginsLoaded bool
 }
 
-func (c *CreateProcessor) Execute(cluster *v2.Cluster) error {
+func (c *CreateProcessor) Execute(ctx context.Context, cluster *v2.Cluster) error {
 	runTime, err := runtime.NewDefaultRuntime(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName))
 	if err != nil {
 		return fmt.Errorf("failed to init runtime, %v", err)
@@ -54,26 +73,32 @@ func (c *CreateProcessor) Execute(cluster *v2.Cluster) error {
 		return err
 	}
 
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return runPipeline(ctx, OperationCreate, cluster, pipLine)
 }
 func (c *CreateProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
 	var todoList []func(cluster *v2.Cluster) error
 	todoList = append(todoList,
 		c.GetPhasePluginFunc(plugin.PhaseOriginally),
 		c.MountImage,
+		c.Preflight,
 		c.RunConfig,
+		c.PrepareHosts,
+		c.OpenPorts,
+		c.SyncTime,
 		c.MountRootfs,
+		c.VerifyComponents,
+		c.InstallUnits,
+		c.PreloadImages,
+		c.ConfigureP2P,
+		c.ConfigureRegistries,
 		c.GetPhasePluginFunc(plugin.PhasePreInit),
 		c.Init,
+		c.DeployP2P,
 		c.Join,
+		c.ApplyStaticPods,
 		c.GetPhasePluginFunc(plugin.PhasePreGuest),
 		c.RunGuest,
+		c.RecordApps,
 		c.UnMountImage,
 		c.GetPhasePluginFunc(plugin.PhasePostInstall),
 	)
@@ -83,13 +108,29 @@ func (c *CreateProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, erro
 func (c *CreateProcessor) MountImage(cluster *v2.Cluster) error {
 	err := c.ImageManager.PullIfNotExist(cluster.Spec.Image)
 	if err != nil {
-		return err
+		return result.Wrap(result.CategoryRuntime, "PullImage", err)
+	}
+	if err = deprecation.CheckImage(cluster.Spec.Image); err != nil {
+		return result.Wrap(result.CategoryPreflight, "CheckDeprecation", err)
 	}
-	return c.FileSystem.MountImage(cluster)
+	return result.Wrap(result.CategoryRuntime, "MountImage", c.FileSystem.MountImage(cluster))
+}
+
+// Preflight checks the hosts meet the requirements in the Metadata of CloudImage, and the image has no
+// dependency as nothing is applied to a new cluster yet.
+func (c *CreateProcessor) Preflight(cluster *v2.Cluster) error {
+	metadata, err := runtime.LoadMetadata(common.DefaultMountCloudImageDir(cluster.Name))
+	if err != nil {
+		return result.Wrap(result.CategoryPreflight, "LoadMetadata", err)
+	}
+	if err = runtime.CheckDependencies(metadata, nil); err != nil {
+		return result.Wrap(result.CategoryPreflight, "CheckDependencies", err)
+	}
+	return checkMetadata(cluster, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...))
 }
 
 func (c *CreateProcessor) RunConfig(cluster *v2.Cluster) error {
-	return c.Config.Dump(cluster.GetAnnotationsByKey(common.ClusterfileName))
+	return result.Wrap(result.CategoryRuntime, "RunConfig", c.Config.Dump(cluster.GetAnnotationsByKey(common.ClusterfileName)))
 }
 
 func (c *CreateProcessor) MountRootfs(cluster *v2.Cluster) error {
@@ -98,27 +139,89 @@ func (c *CreateProcessor) MountRootfs(cluster *v2.Cluster) error {
 	if utils.NotInIPList(regConfig.IP, hosts) {
 		hosts = append(hosts, regConfig.IP)
 	}
-	return c.FileSystem.MountRootfs(cluster, hosts, true)
+	return result.Wrap(result.CategoryRuntime, "MountRootfs", c.FileSystem.MountRootfs(cluster, hosts, true))
+}
+
+// VerifyComponents checks the components in the rootfs of all hosts against the sha256 digests pinned in CloudImage.
+func (c *CreateProcessor) VerifyComponents(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryRuntime, "VerifyComponents", component.VerifyHosts(cluster, hosts))
+}
+
+// InstallUnits installs and starts the systemd units of CloudImage on all hosts.
+func (c *CreateProcessor) InstallUnits(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryRuntime, "InstallUnits", systemd.Install(cluster, hosts))
+}
+
+// PreloadImages imports the image tarballs shipped with rootfs on all hosts, if image preload is enabled in Clusterfile.
+func (c *CreateProcessor) PreloadImages(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryRuntime, "PreloadImages", preload.Import(cluster, hosts))
+}
+
+// ConfigureP2P configs containerd of all hosts to pull images through the P2P agent, if p2p is enabled in Clusterfile.
+func (c *CreateProcessor) ConfigureP2P(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryRuntime, "ConfigureP2P", p2p.ConfigureHosts(cluster, hosts))
+}
+
+// ConfigureRegistries configs the private registries in Clusterfile on all hosts, with their credentials and mirrors.
+func (c *CreateProcessor) ConfigureRegistries(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryRuntime, "ConfigureRegistries", registries.ConfigureHosts(cluster, hosts))
+}
+
+// DeployP2P applies the P2P manifest of CloudImage after init, so the agents run on the hosts before they are joined.
+func (c *CreateProcessor) DeployP2P(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "DeployP2P", p2p.Deploy(cluster))
+}
+
+// PrepareHosts applies the drifted items of the host preparation in Clusterfile, like sysctls, swap and disks,
+// and checks the disk of etcd on masters.
+func (c *CreateProcessor) PrepareHosts(cluster *v2.Cluster) error {
+	return prepareHosts(cluster, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...))
+}
+
+// OpenPorts opens the ports kubernetes needs in the firewall of all hosts, if it is enabled in Clusterfile.
+func (c *CreateProcessor) OpenPorts(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryPreflight, "OpenPorts", firewall.Open(cluster, hosts))
+}
+
+// SyncTime sets up chrony on all hosts and checks them synchronized, if time sync is enabled in Clusterfile.
+func (c *CreateProcessor) SyncTime(cluster *v2.Cluster) error {
+	return syncTime(cluster, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...))
 }
 
 func (c *CreateProcessor) Init(cluster *v2.Cluster) error {
-	return c.Runtime.Init(cluster)
+	return result.Wrap(result.CategoryRuntime, "Init", c.Runtime.Init(cluster))
 }
 
 func (c *CreateProcessor) Join(cluster *v2.Cluster) error {
 	err := c.Runtime.JoinMasters(cluster.GetMasterIPList()[1:])
 	if err != nil {
-		return err
+		return result.Wrap(result.CategoryRuntime, "JoinMasters", err)
 	}
 	err = c.Runtime.JoinNodes(cluster.GetNodeIPList())
 	if err != nil {
-		return err
+		return result.Wrap(result.CategoryRuntime, "JoinNodes", err)
 	}
 	return nil
 }
 
+// ApplyStaticPods installs the static pod manifests of CloudImage on the hosts matching their roles.
+func (c *CreateProcessor) ApplyStaticPods(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryRuntime, "ApplyStaticPods", staticpod.Apply(cluster, hosts))
+}
+
 func (c *CreateProcessor) RunGuest(cluster *v2.Cluster) error {
-	return c.Guest.Apply(cluster)
+	return result.Wrap(result.CategoryGuest, "RunGuest", c.Guest.Apply(cluster))
+}
+func (c *CreateProcessor) RecordApps(cluster *v2.Cluster) error {
+	recordApps(cluster)
+	return nil
 }
 func (c *CreateProcessor) UnMountImage(cluster *v2.Cluster) error {
 	return c.FileSystem.UnMountImage(cluster)
@@ -131,13 +234,45 @@ func (c *CreateProcessor) initPlugin(cluster *v2.Cluster) error {
 
 func (c *CreateProcessor) GetPhasePluginFunc(phase plugin.Phase) func(cluster *v2.Cluster) error {
 	return func(cluster *v2.Cluster) error {
-		if phase == plugin.PhasePreInit {
+		// plugins before init usually check the hosts, so their failures are preflight failures.
+		category := result.CategoryRuntime
+		if phase == plugin.PhaseOriginally || phase == plugin.PhasePreInit {
+			category = result.CategoryPreflight
+		}
+		if phase == plugin.PhasePreInit || !c.pluginsLoaded && phase != plugin.PhaseOriginally {
 			if err := c.Plugins.Load(); err != nil {
-				return err
+				return result.Wrap(category, "LoadPlugin", err)
 			}
+			c.pluginsLoaded = true
 		}
-		return c.Plugins.Run(cluster, phase)
+		return result.Wrap(category, "Plugin"+string(phase), c.Plugins.Run(cluster, phase))
+	}
+}
+
+// checkMetadata checks hosts meet the kernel, cgroup and CRI requirements in the Metadata of the mounted CloudImage.
+func checkMetadata(cluster *v2.Cluster, hosts []string) error {
+	metadata, err := runtime.LoadMetadata(common.DefaultMountCloudImageDir(cluster.Name))
+	if err != nil {
+		return result.Wrap(result.CategoryPreflight, "LoadMetadata", err)
+	}
+	err = checker.RunCheckList([]checker.Interface{checker.NewMetadataChecker(hosts, metadata)}, cluster, checker.PhasePre)
+	return result.Wrap(result.CategoryPreflight, "CheckMetadata", err)
+}
+
+func prepareHosts(cluster *v2.Cluster, hosts []string) error {
+	if err := hostprep.Apply(cluster, hosts); err != nil {
+		return result.Wrap(result.CategoryPreflight, "PrepareHosts", err)
+	}
+	err := checker.RunCheckList([]checker.Interface{checker.NewEtcdDiskChecker(hosts)}, cluster, checker.PhasePre)
+	return result.Wrap(result.CategoryPreflight, "CheckEtcdDisk", err)
+}
+
+func syncTime(cluster *v2.Cluster, hosts []string) error {
+	if err := timesync.Setup(cluster, hosts); err != nil {
+		return result.Wrap(result.CategoryPreflight, "SyncTime", err)
 	}
+	err := checker.RunCheckList([]checker.Interface{checker.NewTimeSyncChecker(hosts)}, cluster, checker.PhasePre)
+	return result.Wrap(result.CategoryPreflight, "CheckTimeSync", err)
 }
 
 func NewCreateProcessor() (Interface, error) {
@@ -15,56 +15,66 @@
 package processor
 
 import (
+	"context"
 	"fmt"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
 
 	"github.com/alibaba/sealer/pkg/plugin"
 
 	"github.com/alibaba/sealer/common"
 
 	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/firewall"
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/pkg/runtime"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 )
 
 type DeleteProcessor struct {
 	FileSystem filesystem.Interface
+	Runtime    runtime.Interface
 }
 
 // Execute :according to the different of desired cluster to delete cluster.
-func (d DeleteProcessor) Execute(cluster *v2.Cluster) (err error) {
+func (d DeleteProcessor) Execute(ctx context.Context, cluster *v2.Cluster) (err error) {
 	runTime, err := runtime.NewDefaultRuntime(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName))
 	if err != nil {
 		return fmt.Errorf("failed to init runtime, %v", err)
 	}
-
-	err = runTime.Reset()
-	if err != nil {
-		return err
-	}
+	d.Runtime = runTime
 
 	pipLine, err := d.GetPipeLine()
 	if err != nil {
 		return err
 	}
 
-	for _, f := range pipLine {
-		if err = f(cluster); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return runPipeline(ctx, "delete", cluster, pipLine)
 }
 func (d DeleteProcessor) GetPipeLine() ([]func(cluster *v2.Cluster) error, error) {
 	var todoList []func(cluster *v2.Cluster) error
 	todoList = append(todoList,
+		d.ClosePorts,
+		d.Reset,
 		d.UnMountRootfs,
 		d.UnMountImage,
+		d.Prune,
 		d.CleanFS,
 	)
 	return todoList, nil
 }
 
+// ClosePorts closes the ports opened in the firewall of all hosts, before the rootfs is removed.
+func (d DeleteProcessor) ClosePorts(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	return result.Wrap(result.CategoryRuntime, "ClosePorts", firewall.Close(cluster, hosts))
+}
+
+func (d DeleteProcessor) Reset(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "Reset", d.Runtime.Reset())
+}
+
 func (d DeleteProcessor) UnMountRootfs(cluster *v2.Cluster) error {
 	return d.FileSystem.UnMountRootfs(cluster)
 }
@@ -72,6 +82,22 @@ func (d DeleteProcessor) UnMountImage(cluster *v2.Cluster) error {
 	return d.FileSystem.UnMountImage(cluster)
 }
 
+// Prune removes the data dirs by the cleanup level, and reports the disk usage of each host.
+func (d DeleteProcessor) Prune(cluster *v2.Cluster) error {
+	usages, err := runtime.Prune(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName))
+	for _, u := range usages {
+		if u.Host == "" {
+			continue
+		}
+		left := "nothing"
+		if len(u.Left) > 0 {
+			left = strings.Join(u.Left, ", ")
+		}
+		logger.Info("disk usage of %s: %s available on /, left: %s", u.Host, u.Available, left)
+	}
+	return result.Wrap(result.CategoryRuntime, "Prune", err)
+}
+
 func (d DeleteProcessor) CleanFS(cluster *v2.Cluster) error {
 	if err := d.FileSystem.Clean(cluster); err != nil {
 		return err
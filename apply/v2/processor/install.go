@@ -15,8 +15,14 @@
 package processor
 
 import (
+	"context"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/app"
 	"github.com/alibaba/sealer/pkg/filesystem"
 	"github.com/alibaba/sealer/pkg/guest"
+	"github.com/alibaba/sealer/pkg/result"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 )
 
@@ -26,27 +32,56 @@ type InstallProcessor struct {
 }
 
 // Execute :according to the different of desired cluster to install app on cluster.
-func (i InstallProcessor) Execute(cluster *v2.Cluster) error {
-	err := i.MountRootfs(cluster)
-	if err != nil {
-		return err
-	}
-	err = i.Install(cluster)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (i InstallProcessor) Execute(ctx context.Context, cluster *v2.Cluster) error {
+	return runPipeline(ctx, "install", cluster, []func(cluster *v2.Cluster) error{
+		i.MountRootfs,
+		i.Install,
+		i.RecordApps,
+	})
 }
 
 func (i InstallProcessor) MountRootfs(cluster *v2.Cluster) error {
 	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
 	//initFlag : no need to do init cmd like installing docker service and so on.
-	return i.FileSystem.MountRootfs(cluster, hosts, false)
+	return result.Wrap(result.CategoryRuntime, "MountRootfs", i.FileSystem.MountRootfs(cluster, hosts, false))
 }
 
 func (i InstallProcessor) Install(cluster *v2.Cluster) error {
-	return i.Guest.Apply(cluster)
+	return result.Wrap(result.CategoryGuest, "Install", i.Guest.Apply(cluster))
+}
+
+func (i InstallProcessor) RecordApps(cluster *v2.Cluster) error {
+	recordApps(cluster)
+	return nil
+}
+
+// recordApps records the manifests and charts of the mounted CloudImage for sealer app list and rollback,
+// the apps are installed already, so the failure is only warned.
+func recordApps(cluster *v2.Cluster) {
+	if err := doRecordApps(cluster); err != nil {
+		logger.Warn("failed to record the apps of %s, they can not be rolled back: %v", cluster.Spec.Image, err)
+	}
+}
+
+func doRecordApps(cluster *v2.Cluster) error {
+	rootfs := common.DefaultMountCloudImageDir(cluster.Name)
+	bundles, err := guest.SelectAppBundles(cluster, rootfs)
+	if err != nil {
+		return err
+	}
+	// all the apps are recorded if the CMD of CloudImage runs, otherwise only the ones of the selected bundles.
+	var paths []string
+	if len(bundles) > 0 {
+		paths = []string{}
+	}
+	for _, b := range bundles {
+		paths = append(paths, b.Paths...)
+	}
+	store, err := app.NewStore()
+	if err != nil {
+		return err
+	}
+	return store.Record(rootfs, cluster.Spec.Image, paths)
 }
 
 func NewInstallProcessor(fs filesystem.Interface) (Interface, error) {
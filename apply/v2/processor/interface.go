@@ -14,9 +14,13 @@
 
 package processor
 
-import v2 "github.com/alibaba/sealer/types/api/v2"
+import (
+	"context"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
 
 type Interface interface {
-	// Execute :according to the different of desired cluster to do cluster apply.
-	Execute(cluster *v2.Cluster) error
+	// Execute :according to the different of desired cluster to do cluster apply, it is aborted once ctx is done.
+	Execute(ctx context.Context, cluster *v2.Cluster) error
 }
@@ -0,0 +1,211 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/checkpoint"
+	"github.com/alibaba/sealer/pkg/metrics"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/timeout"
+	"github.com/alibaba/sealer/pkg/tracing"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// phaseTimeouts are the timeouts of the phases which may hang on a broken host.
+var phaseTimeouts = map[string]timeout.Kind{
+	"MountRootfs":   timeout.ImageDistribution,
+	"PreloadImages": timeout.ImageDistribution,
+	"Init":          timeout.Init,
+	"Join":          timeout.Join,
+	"scaleUp":       timeout.Join,
+}
+
+// localPhases build the state on the machine running sealer, like the mounted image and the dumped configs, so
+// they run again on resume even if they were completed, maybe by another machine.
+var localPhases = map[string]bool{
+	"MountImage":   true,
+	"RunConfig":    true,
+	"UnMountImage": true,
+}
+
+type resumeKey struct{}
+
+// WithResume returns the ctx the pipelines run with resume from cp: the phases before its step are skipped if it
+// is of the same operation, image and hosts, except the local ones.
+func WithResume(ctx context.Context, cp *checkpoint.Checkpoint) context.Context {
+	return context.WithValue(ctx, resumeKey{}, cp)
+}
+
+// ResumingFrom returns the checkpoint the pipelines run with ctx resume from, nil if they are not resumed.
+func ResumingFrom(ctx context.Context) *checkpoint.Checkpoint {
+	cp, _ := ctx.Value(resumeKey{}).(*checkpoint.Checkpoint)
+	return cp
+}
+
+// resumeStep returns the step the operation on cluster resumes from, 0 if it runs from the start.
+func resumeStep(ctx context.Context, operation string, cluster *v2.Cluster, hosts []string) int {
+	cp := ResumingFrom(ctx)
+	if cp == nil {
+		return 0
+	}
+	if cp.Operation != operation || cp.Image != cluster.Spec.Image || !reflect.DeepEqual(cp.Hosts, hosts) {
+		logger.Info("the checkpoint of cluster %s is of %s on %v with %s, run the %s from the start",
+			cluster.Name, cp.Operation, cp.Hosts, cp.Image, operation)
+		return 0
+	}
+	return cp.Step
+}
+
+// runPipeline runs the phases of operation on cluster in order. Before each phase, it records the progress as the
+// checkpoint of cluster here and on master0, so the operation stopped with this machine is resumed by another one.
+// Once ctx is done or the phase times out, it stops at the running phase, whose remote commands are canceled, and
+// records where it is aborted for the next run to resume.
+func runPipeline(ctx context.Context, operation string, cluster *v2.Cluster, pipeline []func(cluster *v2.Cluster) error) (err error) {
+	ctx, end := tracing.Start(ctx, operation, tracing.Attr("cluster", cluster.Name), tracing.Attr("image", cluster.Spec.Image))
+	defer func() {
+		end(err)
+	}()
+
+	if cp, err := checkpoint.Load(cluster.Name); err != nil {
+		logger.Warn("failed to load checkpoint of cluster %s: %v", cluster.Name, err)
+	} else if cp != nil && cp.AbortedAt.IsZero() {
+		logger.Info("resume the %s of cluster %s stopped at phase %s started at %s", cp.Operation, cluster.Name, cp.Phase, cp.UpdatedAt.Format(time.RFC3339))
+	} else if cp != nil {
+		logger.Info("resume the %s of cluster %s aborted at phase %s at %s", cp.Operation, cluster.Name, cp.Phase, cp.AbortedAt.Format(time.RFC3339))
+	}
+
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	skip := resumeStep(ctx, operation, cluster, hosts)
+	var completed []string
+	for i, f := range pipeline {
+		phase := phaseName(f)
+		if i < skip && !localPhases[phase] {
+			logger.Info("skip phase %s of %s completed before", phase, operation)
+			completed = append(completed, phase)
+			continue
+		}
+		saveCheckpoint(cluster, &checkpoint.Checkpoint{
+			Operation:       operation,
+			Image:           cluster.Spec.Image,
+			Phase:           phase,
+			Step:            i,
+			CompletedPhases: completed,
+			Hosts:           hosts,
+			UpdatedAt:       time.Now(),
+		})
+		aborted, err := runPhase(ctx, operation, cluster, phase, f)
+		if err == nil {
+			completed = append(completed, phase)
+			continue
+		}
+		var e *result.Error
+		if errors.As(err, &e) && e.Phase != "" {
+			phase = e.Phase
+		}
+		cp := &checkpoint.Checkpoint{
+			Operation:       operation,
+			Image:           cluster.Spec.Image,
+			Phase:           phase,
+			Step:            i,
+			CompletedPhases: completed,
+			Hosts:           hosts,
+			UpdatedAt:       time.Now(),
+		}
+		if e != nil {
+			cp.FailedHosts = e.Hosts
+		}
+		if aborted == nil {
+			// a failed phase is run again by the next apply, from here or another machine with --resume.
+			saveCheckpoint(cluster, cp)
+			return err
+		}
+		cp.Reason = aborted.Error()
+		cp.AbortedAt = time.Now()
+		saveCheckpoint(cluster, cp)
+		return result.Wrap(result.CategoryRuntime, phase, fmt.Errorf("%s of cluster %s is aborted at phase %s: %w", operation, cluster.Name, phase, err))
+	}
+
+	if client, err := ssh.GetHostSSHClient(cluster.GetMaster0Ip(), cluster); err == nil {
+		if err = checkpoint.RemoveRemote(client, cluster.GetMaster0Ip(), cluster.Name); err != nil {
+			logger.Debug("failed to remove checkpoint of cluster %s on master0: %v", cluster.Name, err)
+		}
+	}
+	return checkpoint.Remove(cluster.Name)
+}
+
+// saveCheckpoint records cp here and on master0, the failures are warned only as they do not stop the operation.
+func saveCheckpoint(cluster *v2.Cluster, cp *checkpoint.Checkpoint) {
+	if err := checkpoint.Save(cluster.Name, cp); err != nil {
+		logger.Warn("%v", err)
+	}
+	master0 := cluster.GetMaster0Ip()
+	if master0 == "" {
+		return
+	}
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err == nil {
+		err = checkpoint.SaveRemote(client, master0, cluster.Name, cp)
+	}
+	if err != nil {
+		logger.Warn("failed to save checkpoint of cluster %s on master0 %s: %v", cluster.Name, master0, err)
+	}
+}
+
+// runPhase runs f with the timeout of phase, aborted is the error of the context if f is canceled or timed out.
+func runPhase(ctx context.Context, operation string, cluster *v2.Cluster, phase string, f func(cluster *v2.Cluster) error) (aborted, err error) {
+	ctx = metrics.WithPhase(ctx, cluster.Name, phase)
+	if kind, ok := phaseTimeouts[phase]; ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout.Of(cluster, kind))
+		defer cancel()
+	}
+	ctx, end := tracing.Start(ctx, phase, tracing.Attr("cluster", cluster.Name), tracing.Attr("operation", operation))
+	defer func() {
+		end(err)
+	}()
+	defer ssh.BindContext(cluster.Name, ctx)()
+	if err = ctx.Err(); err != nil {
+		return err, err
+	}
+	start := time.Now()
+	err = f(cluster)
+	metrics.ObservePhase(cluster.Name, operation, phase, time.Since(start))
+	aborted = ctx.Err()
+	if aborted == context.DeadlineExceeded {
+		err = fmt.Errorf("phase %s timed out after %s: %w", phase, timeout.Of(cluster, phaseTimeouts[phase]), err)
+	}
+	return aborted, err
+}
+
+// phaseName returns the method name of f, like MountRootfs of (*CreateProcessor).MountRootfs, and
+// GetPhasePluginFunc of the func it returns.
+func phaseName(f func(cluster *v2.Cluster) error) string {
+	name := strings.TrimSuffix(goruntime.FuncForPC(reflect.ValueOf(f).Pointer()).Name(), "-fm")
+	if i := strings.LastIndex(name, ".func"); i >= 0 {
+		name = name[:i]
+	}
+	return name[strings.LastIndex(name, ".")+1:]
+}
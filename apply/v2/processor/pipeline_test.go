@@ -0,0 +1,209 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/alibaba/sealer/pkg/checkpoint"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// fakeSSH records the commands run and the files copied to the hosts.
+type fakeSSH struct {
+	ssh.Interface
+	lock   sync.Mutex
+	cmds   []string
+	copies []string
+}
+
+func (f *fakeSSH) CmdAsync(host string, cmds ...string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.cmds = append(f.cmds, host+": "+strings.Join(cmds, " && "))
+	return nil
+}
+
+func (f *fakeSSH) Copy(host, localPath, remotePath string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.copies = append(f.copies, host+": "+remotePath)
+	return nil
+}
+
+func (f *fakeSSH) provider(hostIP string, cluster *v2.Cluster) (ssh.Interface, error) {
+	return f, nil
+}
+
+// fakePhases records the phases run, the phase named fail fails.
+type fakePhases struct {
+	ran  []string
+	fail string
+}
+
+func (p *fakePhases) run(phase string) error {
+	p.ran = append(p.ran, phase)
+	if phase == p.fail {
+		return fmt.Errorf("failed to run %s", phase)
+	}
+	return nil
+}
+
+func (p *fakePhases) MountImage(cluster *v2.Cluster) error   { return p.run("MountImage") }
+func (p *fakePhases) MountRootfs(cluster *v2.Cluster) error  { return p.run("MountRootfs") }
+func (p *fakePhases) Init(cluster *v2.Cluster) error         { return p.run("Init") }
+func (p *fakePhases) Join(cluster *v2.Cluster) error         { return p.run("Join") }
+func (p *fakePhases) UnMountImage(cluster *v2.Cluster) error { return p.run("UnMountImage") }
+
+func (p *fakePhases) pipeline() []func(cluster *v2.Cluster) error {
+	return []func(cluster *v2.Cluster) error{p.MountImage, p.MountRootfs, p.Init, p.Join, p.UnMountImage}
+}
+
+func newTestCluster(t *testing.T) *v2.Cluster {
+	// the checkpoints are saved in the home dir.
+	home, origin := t.TempDir(), os.Getenv("HOME")
+	homedir.DisableCache = true
+	os.Setenv("HOME", home)
+	t.Cleanup(func() {
+		os.Setenv("HOME", origin)
+		homedir.DisableCache = false
+	})
+
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	cluster.Spec.Image = "kubernetes:v1.19.8"
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+		{IPS: []string{"192.168.0.3"}, Roles: []string{"node"}},
+	}
+	return cluster
+}
+
+func TestResumeStep(t *testing.T) {
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	cluster.Spec.Image = "kubernetes:v1.19.8"
+	hosts := []string{"192.168.0.2", "192.168.0.3"}
+	tests := []struct {
+		name string
+		cp   *checkpoint.Checkpoint
+		want int
+	}{
+		{"no checkpoint", nil, 0},
+		{"same operation", &checkpoint.Checkpoint{Operation: "create", Image: "kubernetes:v1.19.8", Hosts: hosts, Step: 3}, 3},
+		{"another operation", &checkpoint.Checkpoint{Operation: "upgrade", Image: "kubernetes:v1.19.8", Hosts: hosts, Step: 3}, 0},
+		{"another image", &checkpoint.Checkpoint{Operation: "create", Image: "kubernetes:v1.20.4", Hosts: hosts, Step: 3}, 0},
+		{"another hosts", &checkpoint.Checkpoint{Operation: "create", Image: "kubernetes:v1.19.8", Hosts: hosts[:1], Step: 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.cp != nil {
+				ctx = WithResume(ctx, tt.cp)
+			}
+			if got := resumeStep(ctx, "create", cluster, hosts); got != tt.want {
+				t.Errorf("resumeStep() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPipeline_resume(t *testing.T) {
+	hosts := []string{"192.168.0.2", "192.168.0.3"}
+	tests := []struct {
+		name string
+		cp   *checkpoint.Checkpoint
+		want []string
+	}{
+		{"from the start", nil, []string{"MountImage", "MountRootfs", "Init", "Join", "UnMountImage"}},
+		// the local phases run again, the others before the step are skipped.
+		{"skip", &checkpoint.Checkpoint{Operation: "create", Image: "kubernetes:v1.19.8", Hosts: hosts, Phase: "Join", Step: 3},
+			[]string{"MountImage", "Join", "UnMountImage"}},
+		{"image mismatch", &checkpoint.Checkpoint{Operation: "create", Image: "kubernetes:v1.20.4", Hosts: hosts, Phase: "Join", Step: 3},
+			[]string{"MountImage", "MountRootfs", "Init", "Join", "UnMountImage"}},
+		{"operation mismatch", &checkpoint.Checkpoint{Operation: "upgrade", Image: "kubernetes:v1.19.8", Hosts: hosts, Phase: "Join", Step: 3},
+			[]string{"MountImage", "MountRootfs", "Init", "Join", "UnMountImage"}},
+		{"host mismatch", &checkpoint.Checkpoint{Operation: "create", Image: "kubernetes:v1.19.8", Hosts: hosts[:1], Phase: "Join", Step: 3},
+			[]string{"MountImage", "MountRootfs", "Init", "Join", "UnMountImage"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newTestCluster(t)
+			fake := &fakeSSH{}
+			ssh.RegisterProvider(cluster.Name, fake.provider)
+			defer ssh.UnregisterProvider(cluster.Name)
+
+			ctx := context.Background()
+			if tt.cp != nil {
+				ctx = WithResume(ctx, tt.cp)
+			}
+			phases := &fakePhases{}
+			if err := runPipeline(ctx, "create", cluster, phases.pipeline()); err != nil {
+				t.Fatalf("runPipeline() error = %v", err)
+			}
+			if !reflect.DeepEqual(phases.ran, tt.want) {
+				t.Errorf("phases run = %v, want %v", phases.ran, tt.want)
+			}
+			if cp, err := checkpoint.Load(cluster.Name); err != nil || cp != nil {
+				t.Errorf("expected checkpoint removed after success, got %+v, %v", cp, err)
+			}
+			want := "192.168.0.2: rm -f '" + checkpoint.RemotePath(cluster.Name) + "'"
+			if len(fake.cmds) == 0 || fake.cmds[len(fake.cmds)-1] != want {
+				t.Errorf("expected checkpoint removed on master0 by %s, got %v", want, fake.cmds)
+			}
+		})
+	}
+}
+
+func TestRunPipeline_failed(t *testing.T) {
+	cluster := newTestCluster(t)
+	fake := &fakeSSH{}
+	ssh.RegisterProvider(cluster.Name, fake.provider)
+	defer ssh.UnregisterProvider(cluster.Name)
+
+	phases := &fakePhases{fail: "Init"}
+	if err := runPipeline(context.Background(), "create", cluster, phases.pipeline()); err == nil {
+		t.Fatalf("expected runPipeline() failed at Init")
+	}
+	cp, err := checkpoint.Load(cluster.Name)
+	if err != nil || cp == nil {
+		t.Fatalf("expected checkpoint saved, got %v, %v", cp, err)
+	}
+	if cp.Phase != "Init" || cp.Step != 2 || !reflect.DeepEqual(cp.CompletedPhases, []string{"MountImage", "MountRootfs"}) ||
+		!reflect.DeepEqual(cp.Hosts, []string{"192.168.0.2", "192.168.0.3"}) || !cp.AbortedAt.IsZero() {
+		t.Errorf("unexpected checkpoint %+v", cp)
+	}
+	if want := "192.168.0.2: " + checkpoint.RemotePath(cluster.Name) + ".tmp"; fake.copies[len(fake.copies)-1] != want {
+		t.Errorf("expected checkpoint saved to master0 %s, got %v", want, fake.copies)
+	}
+
+	// the next run resumes from the failed phase.
+	phases = &fakePhases{}
+	if err := runPipeline(WithResume(context.Background(), cp), "create", cluster, phases.pipeline()); err != nil {
+		t.Fatalf("runPipeline() error = %v", err)
+	}
+	if want := []string{"MountImage", "Init", "Join", "UnMountImage"}; !reflect.DeepEqual(phases.ran, want) {
+		t.Errorf("phases run = %v, want %v", phases.ran, want)
+	}
+}
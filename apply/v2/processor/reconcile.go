@@ -0,0 +1,189 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alibaba/sealer/client/k8s"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/clusterdiff"
+	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/pkg/registries"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// ReconcileProcessor applies the changes of the hosts in the Clusterfile besides joining and deleting them.
+type ReconcileProcessor struct {
+	FileSystem filesystem.Interface
+	// Previous is the cluster last applied, its labels and taints are replaced by the desired ones.
+	Previous *v2.Cluster
+	Changes  []clusterdiff.Change
+}
+
+// Execute runs the actions of the changes on their hosts, the actions of the same kind run in one phase.
+func (r ReconcileProcessor) Execute(ctx context.Context, cluster *v2.Cluster) error {
+	return runPipeline(ctx, "reconcile", cluster, []func(cluster *v2.Cluster) error{
+		r.CheckSSH,
+		r.RenderConfigs,
+		r.RestartKubelet,
+		r.RegenerateControlPlane,
+		r.UpdateNodes,
+		r.ConfigureRegistries,
+		r.RerunPlugins,
+		r.WarnManual,
+	})
+}
+
+// hostsOf returns the hosts of the changes with action.
+func (r ReconcileProcessor) hostsOf(action clusterdiff.Action) []string {
+	var hosts []string
+	for _, c := range r.Changes {
+		if utils.NotIn(string(action), actionsOf(c)) {
+			continue
+		}
+		for _, host := range c.Hosts {
+			if utils.NotIn(host, hosts) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+func actionsOf(c clusterdiff.Change) []string {
+	var actions []string
+	for _, a := range c.Actions {
+		actions = append(actions, string(a))
+	}
+	return actions
+}
+
+func (r ReconcileProcessor) CheckSSH(cluster *v2.Cluster) error {
+	for _, host := range r.hostsOf(clusterdiff.ActionCheckSSH) {
+		client, err := ssh.GetHostSSHClient(host, cluster)
+		if err == nil {
+			err = client.Ping(host)
+		}
+		if err != nil {
+			return result.Wrap(result.CategoryPreflight, "CheckSSH", fmt.Errorf("failed to connect %s with the new ssh config: %v", host, err))
+		}
+	}
+	return nil
+}
+
+func (r ReconcileProcessor) RenderConfigs(cluster *v2.Cluster) error {
+	hosts := r.hostsOf(clusterdiff.ActionRenderConfigs)
+	if len(hosts) == 0 {
+		return nil
+	}
+	return result.Wrap(result.CategoryRuntime, "RenderConfigs", r.FileSystem.MountRootfs(cluster, hosts, false))
+}
+
+func (r ReconcileProcessor) RestartKubelet(cluster *v2.Cluster) error {
+	hosts := r.hostsOf(clusterdiff.ActionRestartKubelet)
+	if len(hosts) == 0 {
+		return nil
+	}
+	return result.Wrap(result.CategoryRuntime, "RestartKubelet", runtime.ConfigKubeletExtraArgs(cluster, hosts))
+}
+
+func (r ReconcileProcessor) RegenerateControlPlane(cluster *v2.Cluster) error {
+	masters := r.hostsOf(clusterdiff.ActionRegenerateControlPlane)
+	if len(masters) == 0 {
+		return nil
+	}
+	return result.Wrap(result.CategoryRuntime, "RegenerateControlPlane",
+		runtime.RegenerateControlPlane(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName), masters))
+}
+
+func (r ReconcileProcessor) UpdateNodes(cluster *v2.Cluster) error {
+	hosts := r.hostsOf(clusterdiff.ActionUpdateNodes)
+	if len(hosts) == 0 {
+		return nil
+	}
+	client, err := k8s.Newk8sClient()
+	if err != nil {
+		return result.Wrap(result.CategoryRuntime, "UpdateNodes", err)
+	}
+	for _, host := range hosts {
+		var old v2.Host
+		if r.Previous != nil {
+			old = clusterdiff.HostOf(r.Previous, host)
+		}
+		desired := clusterdiff.HostOf(cluster, host)
+		err := client.ReconcileNodeLabelsAndTaints(host, old.Labels, desired.Labels, old.Taints, desired.Taints)
+		if err != nil {
+			return result.Wrap(result.CategoryRuntime, "UpdateNodes", err)
+		}
+	}
+	return nil
+}
+
+// ConfigureRegistries writes the registries of Clusterfile to the hosts, and removes the ones deleted from it.
+func (r ReconcileProcessor) ConfigureRegistries(cluster *v2.Cluster) error {
+	hosts := r.hostsOf(clusterdiff.ActionConfigureRegistries)
+	if len(hosts) == 0 {
+		return nil
+	}
+	return result.Wrap(result.CategoryRuntime, "ConfigureRegistries", registries.Reconcile(cluster, hosts))
+}
+
+// RerunPlugins runs the PostInstall plugins on the changed hosts only.
+func (r ReconcileProcessor) RerunPlugins(cluster *v2.Cluster) error {
+	hosts := r.hostsOf(clusterdiff.ActionRerunPlugins)
+	if len(hosts) == 0 {
+		return nil
+	}
+	changed := cluster.DeepCopy()
+	changed.Spec.Hosts = nil
+	for _, host := range cluster.Spec.Hosts {
+		if host.IPS = utils.ReduceIPList(host.IPS, hosts); len(host.IPS) > 0 {
+			changed.Spec.Hosts = append(changed.Spec.Hosts, host)
+		}
+	}
+	plugins := plugin.NewPlugins(cluster.Name)
+	if err := plugins.Dump(cluster.GetAnnotationsByKey(common.ClusterfileName)); err != nil {
+		return result.Wrap(result.CategoryRuntime, "LoadPlugin", err)
+	}
+	if err := plugins.Load(); err != nil {
+		return result.Wrap(result.CategoryRuntime, "LoadPlugin", err)
+	}
+	return result.Wrap(result.CategoryRuntime, "Plugin"+string(plugin.PhasePostInstall), plugins.Run(changed, plugin.PhasePostInstall))
+}
+
+func (r ReconcileProcessor) WarnManual(cluster *v2.Cluster) error {
+	for _, c := range r.Changes {
+		if utils.InList(string(clusterdiff.ActionManual), actionsOf(c)) {
+			logger.Warn("%s, sealer does not apply it to the running cluster", c.Detail)
+		}
+	}
+	return nil
+}
+
+func NewReconcileProcessor(fs filesystem.Interface, previous *v2.Cluster, changes []clusterdiff.Change) (Interface, error) {
+	return ReconcileProcessor{
+		FileSystem: fs,
+		Previous:   previous,
+		Changes:    changes,
+	}, nil
+}
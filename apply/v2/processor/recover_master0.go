@@ -0,0 +1,52 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/firewall"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+// RecoverMaster0Processor makes the first master of the cluster master0 in place of the Lost one, which is
+// removed from the cluster already.
+type RecoverMaster0Processor struct {
+	Lost string
+}
+
+func (r RecoverMaster0Processor) Execute(ctx context.Context, cluster *v2.Cluster) error {
+	return runPipeline(ctx, "recover-master0", cluster, []func(cluster *v2.Cluster) error{
+		r.OpenPorts,
+		r.PromoteMaster0,
+	})
+}
+
+// OpenPorts opens the port of registry on the new master0, the other ports are opened when it joined.
+func (r RecoverMaster0Processor) OpenPorts(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryPreflight, "OpenPorts", firewall.Open(cluster, []string{cluster.GetMaster0Ip()}))
+}
+
+func (r RecoverMaster0Processor) PromoteMaster0(cluster *v2.Cluster) error {
+	err := runtime.PromoteMaster0(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName), r.Lost)
+	return result.Wrap(result.CategoryRuntime, "PromoteMaster0", err)
+}
+
+func NewRecoverMaster0Processor(lost string) (Interface, error) {
+	return RecoverMaster0Processor{Lost: lost}, nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/backup"
+	"github.com/alibaba/sealer/pkg/config"
+	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+// RestoreProcessor rebuilds the cluster from the backup extracted to Dir: the hosts are reset and installed
+// like creating, master0 is initialized by the CAs of the backup, then its etcd is restored from the snapshot
+// before joining the other masters and nodes. The guest is not run, as the apps are restored with etcd.
+type RestoreProcessor struct {
+	*CreateProcessor
+	Dir string
+}
+
+func (r *RestoreProcessor) Execute(ctx context.Context, cluster *v2.Cluster) error {
+	runTime, err := runtime.NewDefaultRuntime(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName))
+	if err != nil {
+		return fmt.Errorf("failed to init runtime, %v", err)
+	}
+	r.Runtime = runTime
+	r.Config = config.NewConfiguration(cluster.Name)
+	if err := r.initPlugin(cluster); err != nil {
+		return err
+	}
+
+	return runPipeline(ctx, "restore", cluster, []func(cluster *v2.Cluster) error{
+		r.MountImage,
+		r.Reset,
+		r.Preflight,
+		r.RunConfig,
+		r.PrepareHosts,
+		r.OpenPorts,
+		r.SyncTime,
+		r.MountRootfs,
+		r.VerifyComponents,
+		r.InstallUnits,
+		r.PreloadImages,
+		r.ConfigureP2P,
+		r.GetPhasePluginFunc(plugin.PhasePreInit),
+		r.Init,
+		r.RestoreEtcd,
+		r.RestoreRegistry,
+		r.RemoveStaleNodes,
+		r.DeployP2P,
+		r.Join,
+		r.ApplyStaticPods,
+		r.UnMountImage,
+		r.GetPhasePluginFunc(plugin.PhasePostInstall),
+	})
+}
+
+// Reset cleans the hosts, the nodes of the lost cluster are joined again.
+func (r *RestoreProcessor) Reset(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "Reset", r.Runtime.Reset())
+}
+
+func (r *RestoreProcessor) RestoreEtcd(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "RestoreEtcd", backup.RestoreEtcd(cluster, r.Dir))
+}
+
+// RestoreRegistry pushes the images in the backup to the registry started by Init.
+func (r *RestoreProcessor) RestoreRegistry(cluster *v2.Cluster) error {
+	err := runtime.ImportRegistryData(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName), r.Dir)
+	return result.Wrap(result.CategoryRuntime, "RestoreRegistry", err)
+}
+
+// RemoveStaleNodes deletes the nodes restored with etcd except master0, the other hosts join again by Join.
+func (r *RestoreProcessor) RemoveStaleNodes(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "RemoveStaleNodes", backup.RemoveStaleNodes(cluster))
+}
+
+func NewRestoreProcessor(dir string) (Interface, error) {
+	c, err := NewCreateProcessor()
+	if err != nil {
+		return nil, err
+	}
+	return &RestoreProcessor{CreateProcessor: c.(*CreateProcessor), Dir: dir}, nil
+}
@@ -15,11 +15,20 @@
 package processor
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/component"
 	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/firewall"
+	"github.com/alibaba/sealer/pkg/p2p"
+	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/pkg/preload"
+	"github.com/alibaba/sealer/pkg/registries"
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/systemd"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 )
 
@@ -34,7 +43,7 @@ type ScaleProcessor struct {
 }
 
 // Execute :according to the different of desired cluster to scale cluster.
-func (s ScaleProcessor) Execute(cluster *v2.Cluster) error {
+func (s ScaleProcessor) Execute(ctx context.Context, cluster *v2.Cluster) error {
 	/*
 		1. master scale up + master scale up :support
 		2. master scale down + master scale down :support
@@ -48,14 +57,58 @@ func (s ScaleProcessor) Execute(cluster *v2.Cluster) error {
 	s.Runtime = runTime
 
 	if s.IsScaleUp {
-		return s.ScaleUp(cluster)
+		return runPipeline(ctx, "scale up", cluster, []func(cluster *v2.Cluster) error{s.scaleUp})
 	}
-	return s.ScaleDown()
+	return runPipeline(ctx, "scale down", cluster, []func(cluster *v2.Cluster) error{s.scaleDown})
+}
+
+func (s ScaleProcessor) scaleUp(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "ScaleUp", s.ScaleUp(cluster))
+}
+
+func (s ScaleProcessor) scaleDown(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "ScaleDown", s.ScaleDown(cluster))
 }
 
 func (s ScaleProcessor) ScaleUp(cluster *v2.Cluster) error {
 	hosts := append(s.MastersToJoin, s.NodesToJoin...)
-	err := s.FileSystem.MountRootfs(cluster, hosts, true)
+	err := checkMetadata(cluster, hosts)
+	if err != nil {
+		return err
+	}
+	err = prepareHosts(cluster, hosts)
+	if err != nil {
+		return err
+	}
+	err = result.Wrap(result.CategoryPreflight, "OpenPorts", firewall.Open(cluster, hosts))
+	if err != nil {
+		return err
+	}
+	err = syncTime(cluster, hosts)
+	if err != nil {
+		return err
+	}
+	err = s.FileSystem.MountRootfs(cluster, hosts, true)
+	if err != nil {
+		return err
+	}
+	err = result.Wrap(result.CategoryRuntime, "VerifyComponents", component.VerifyHosts(cluster, hosts))
+	if err != nil {
+		return err
+	}
+	err = result.Wrap(result.CategoryRuntime, "InstallUnits", systemd.Install(cluster, hosts))
+	if err != nil {
+		return err
+	}
+	err = result.Wrap(result.CategoryRuntime, "PreloadImages", preload.Import(cluster, hosts))
+	if err != nil {
+		return err
+	}
+	err = result.Wrap(result.CategoryRuntime, "ConfigureP2P", p2p.ConfigureHosts(cluster, hosts))
+	if err != nil {
+		return err
+	}
+	err = result.Wrap(result.CategoryRuntime, "ConfigureRegistries", registries.ConfigureHosts(cluster, hosts))
 	if err != nil {
 		return err
 	}
@@ -67,11 +120,16 @@ func (s ScaleProcessor) ScaleUp(cluster *v2.Cluster) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return plugin.ScaleLoadBalancer(cluster, s.MastersToJoin, nil)
 }
 
-func (s ScaleProcessor) ScaleDown() error {
-	err := s.Runtime.DeleteMasters(s.MastersToDelete)
+func (s ScaleProcessor) ScaleDown(cluster *v2.Cluster) error {
+	// deregister masters before deleting them, so the load balancer stops sending requests to them.
+	err := plugin.ScaleLoadBalancer(cluster, nil, s.MastersToDelete)
+	if err != nil {
+		return err
+	}
+	err = s.Runtime.DeleteMasters(s.MastersToDelete)
 	if err != nil {
 		return err
 	}
@@ -79,7 +137,7 @@ func (s ScaleProcessor) ScaleDown() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return result.Wrap(result.CategoryRuntime, "ClosePorts", firewall.Close(cluster, append(s.MastersToDelete, s.NodesToDelete...)))
 }
 
 func NewScaleProcessor(fs filesystem.Interface, masterToJoin, masterToDelete, nodeToJoin, nodeToDelete []string) (Interface, error) {
@@ -0,0 +1,58 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// TakeoverProcessor brings the hosts of a running cluster not created by sealer under its management,
+// the hosts are not reset and init.sh is not run on them.
+type TakeoverProcessor struct {
+	FileSystem filesystem.Interface
+}
+
+func (t TakeoverProcessor) Execute(ctx context.Context, cluster *v2.Cluster) error {
+	return runPipeline(ctx, "takeover", cluster, []func(cluster *v2.Cluster) error{
+		t.MountRootfs,
+		t.Takeover,
+	})
+}
+
+// MountRootfs sends rootfs to all hosts and the registry host without running init.sh, which installs
+// the container runtime and kubelet they already have.
+func (t TakeoverProcessor) MountRootfs(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	regConfig := runtime.GetRegistryConfig(common.DefaultTheClusterRootfsDir(cluster.Name), cluster.GetMaster0Ip())
+	if utils.NotInIPList(regConfig.IP, hosts) {
+		hosts = append(hosts, regConfig.IP)
+	}
+	return result.Wrap(result.CategoryRuntime, "MountRootfs", t.FileSystem.MountRootfs(cluster, hosts, false))
+}
+
+func (t TakeoverProcessor) Takeover(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "Takeover", runtime.Takeover(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName)))
+}
+
+func NewTakeoverProcessor(fs filesystem.Interface) (Interface, error) {
+	return TakeoverProcessor{FileSystem: fs}, nil
+}
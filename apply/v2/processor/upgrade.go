@@ -15,10 +15,13 @@
 package processor
 
 import (
+	"context"
+
 	v2 "github.com/alibaba/sealer/types/api/v2"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/pkg/runtime"
 	"github.com/alibaba/sealer/utils"
 )
@@ -31,17 +34,11 @@ type UpgradeProcessor struct {
 }
 
 // Execute :according to the different of desired cluster to upgrade cluster.
-func (u UpgradeProcessor) Execute(cluster *v2.Cluster) error {
-	err := u.MountRootfs(cluster)
-	if err != nil {
-		return err
-	}
-	err = u.Upgrade()
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (u UpgradeProcessor) Execute(ctx context.Context, cluster *v2.Cluster) error {
+	return runPipeline(ctx, "upgrade", cluster, []func(cluster *v2.Cluster) error{
+		u.MountRootfs,
+		u.Upgrade,
+	})
 }
 
 func (u UpgradeProcessor) MountRootfs(cluster *v2.Cluster) error {
@@ -53,11 +50,11 @@ func (u UpgradeProcessor) MountRootfs(cluster *v2.Cluster) error {
 	if utils.NotInIPList(regConfig.IP, hosts) {
 		hosts = append(hosts, regConfig.IP)
 	}
-	return u.FileSystem.MountRootfs(cluster, hosts, false)
+	return result.Wrap(result.CategoryRuntime, "MountRootfs", u.FileSystem.MountRootfs(cluster, hosts, false))
 }
 
-func (u UpgradeProcessor) Upgrade() error {
-	return u.Runtime.Upgrade()
+func (u UpgradeProcessor) Upgrade(cluster *v2.Cluster) error {
+	return result.Wrap(result.CategoryRuntime, "Upgrade", u.Runtime.Upgrade())
 }
 
 func NewUpgradeProcessor(fs filesystem.Interface, rt runtime.Interface, masterToJoin, nodeToJoin []string) (Interface, error) {
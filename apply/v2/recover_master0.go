@@ -0,0 +1,116 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/backup"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// RecoverMaster0 rebuilds the lost master0 of the cluster in clusterfile on newIP. If newIP is a master, it is
+// promoted to master0. Otherwise the first master left is promoted, and newIP joins as a master in place of
+// the lost one. The images pushed to the registry are restored from the archive of sealer backup if it is set.
+func RecoverMaster0(ctx context.Context, clusterfile, newIP, backupFile string) error {
+	cluster, err := utils.GetClusterFromFile(clusterfile)
+	if err != nil {
+		return err
+	}
+	lost := cluster.GetMaster0Ip()
+	promoted, err := promotedMaster(cluster, newIP)
+	if err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+	recovered := promoteMaster(cluster, lost, promoted)
+
+	applier, err := NewApplier(recovered)
+	if err != nil {
+		return err
+	}
+	if err := applier.RecoverMaster0(ctx, lost); err != nil {
+		return err
+	}
+	if backupFile != "" {
+		dir, _, err := backup.Open(backupFile)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		logger.Info("Start to restore the images of registry from %s", backupFile)
+		if err := runtime.ImportRegistryData(recovered, clusterfile, dir); err != nil {
+			return err
+		}
+	}
+	if promoted == newIP {
+		return nil
+	}
+
+	logger.Info("Start to join new host %s as master", newIP)
+	joined := recovered.DeepCopy()
+	joined.Spec.Hosts[0].IPS = append(joined.Spec.Hosts[0].IPS, newIP)
+	if err := applyCluster(ctx, joined); err != nil {
+		return fmt.Errorf("master0 is recovered on %s, but failed to join new host %s: %v", promoted, newIP, err)
+	}
+	return nil
+}
+
+// promotedMaster returns the master to promote to master0, which is newIP if it is a master, or the first master
+// left if newIP is a new host.
+func promotedMaster(cluster *v2.Cluster, newIP string) (string, error) {
+	if !utils.CheckIP(newIP) {
+		return "", fmt.Errorf("invalid new ip %s", newIP)
+	}
+	masters := cluster.GetMasterIPList()
+	if newIP == masters[0] {
+		return "", fmt.Errorf("new ip %s is the lost master0", newIP)
+	}
+	if len(masters) < 2 {
+		return "", fmt.Errorf("no master is left to recover master0 from, run sealer restore with a backup instead")
+	}
+	if utils.InList(newIP, cluster.GetNodeIPList()) {
+		return "", fmt.Errorf("new ip %s is a node, it must be a master or a new host", newIP)
+	}
+	if utils.InList(newIP, masters) {
+		return newIP, nil
+	}
+	return masters[1], nil
+}
+
+// promoteMaster returns a copy of cluster without the lost master0, and promoted is the first host, which
+// keeps the roles, ssh and env of its host group.
+func promoteMaster(cluster *v2.Cluster, lost, promoted string) *v2.Cluster {
+	recovered := cluster.DeepCopy()
+	var first v2.Host
+	var hosts []v2.Host
+	for _, h := range recovered.Spec.Hosts {
+		if utils.InList(promoted, h.IPS) {
+			first = *h.DeepCopy()
+		}
+		h.IPS = returnFilteredIPList(h.IPS, []string{lost, promoted})
+		if len(h.IPS) > 0 {
+			hosts = append(hosts, h)
+		}
+	}
+	first.IPS = []string{promoted}
+	recovered.Spec.Hosts = append([]v2.Host{first}, hosts...)
+	return recovered
+}
@@ -0,0 +1,72 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestRecoverMaster0Hosts(t *testing.T) {
+	cluster := &v2.Cluster{Spec: v2.ClusterSpec{Hosts: []v2.Host{
+		{IPS: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, Roles: []string{common.MASTER}, Env: []string{"key=value"}},
+		{IPS: []string{"10.0.0.5"}, Roles: []string{common.NODE}},
+	}}}
+	tests := []struct {
+		name    string
+		newIP   string
+		want    string
+		wantErr bool
+	}{
+		{"promote master", "10.0.0.3", "10.0.0.3", false},
+		{"new host", "10.0.0.8", "10.0.0.2", false},
+		{"lost master0", "10.0.0.1", "", true},
+		{"node", "10.0.0.5", "", true},
+		{"invalid ip", "10.0.0", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := promotedMaster(cluster, tt.newIP)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("promotedMaster() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("promotedMaster() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	recovered := promoteMaster(cluster, "10.0.0.1", "10.0.0.3")
+	if got := recovered.GetMasterIPList(); !reflect.DeepEqual(got, []string{"10.0.0.3", "10.0.0.2"}) {
+		t.Errorf("masters = %v, want [10.0.0.3 10.0.0.2]", got)
+	}
+	if got := recovered.GetNodeIPList(); !reflect.DeepEqual(got, []string{"10.0.0.5"}) {
+		t.Errorf("nodes = %v, want [10.0.0.5]", got)
+	}
+	if !reflect.DeepEqual(recovered.Spec.Hosts[0].Env, []string{"key=value"}) {
+		t.Errorf("master0 does not keep the env of its group: %v", recovered.Spec.Hosts[0].Env)
+	}
+	if len(cluster.Spec.Hosts[0].IPS) != 3 {
+		t.Errorf("promoteMaster() changes the cluster: %v", cluster.Spec.Hosts[0].IPS)
+	}
+
+	single := &v2.Cluster{Spec: v2.ClusterSpec{Hosts: []v2.Host{{IPS: []string{"10.0.0.1"}, Roles: []string{common.MASTER}}}}}
+	if _, err := promotedMaster(single, "10.0.0.8"); err == nil {
+		t.Error("promotedMaster() of a single master should fail")
+	}
+}
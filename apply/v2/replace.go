@@ -0,0 +1,173 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/alibaba/sealer/client/k8s"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/timeout"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	hostnameLabel      = "kubernetes.io/hostname"
+	unschedulableTaint = "node.kubernetes.io/unschedulable"
+)
+
+// ParseReplaceArg parses "10.0.0.5=10.0.0.8" into the old and new host ip.
+func ParseReplaceArg(arg string) (oldIP, newIP string, err error) {
+	ips := strings.Split(arg, "=")
+	if len(ips) != 2 || !utils.CheckIP(ips[0]) || !utils.CheckIP(ips[1]) {
+		return "", "", result.Wrap(result.CategoryValidation, "", fmt.Errorf("invalid replace parameter %s, it should be OLD_IP=NEW_IP", arg))
+	}
+	return ips[0], ips[1], nil
+}
+
+// Replace joins newIP from the same image with the same labels and taints of the host oldIP, drains and removes
+// oldIP, and saves the result to the Clusterfile. If any step fails, the cluster is rolled back to its original
+// state: the new host is removed and the old one is uncordoned, even if the replacement is aborted with ctx.
+func Replace(ctx context.Context, clusterfile, oldIP, newIP string) error {
+	cluster := &v2.Cluster{}
+	if err := utils.UnmarshalYamlFile(clusterfile, cluster); err != nil {
+		return err
+	}
+	joined, err := addReplaceHost(cluster, oldIP, newIP)
+	if err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+
+	client, err := k8s.Newk8sClient()
+	if err != nil {
+		return err
+	}
+	oldNode, err := client.GetNodeByIP(oldIP)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Start to join new host %s", newIP)
+	if err := applyCluster(ctx, joined); err != nil {
+		rollbackReplace(client, cluster, oldNode.Name)
+		return fmt.Errorf("failed to join new host %s: %v", newIP, err)
+	}
+	if err := copyNodeSchedulingAttrs(client, oldNode, newIP); err != nil {
+		rollbackReplace(client, cluster, oldNode.Name)
+		return err
+	}
+
+	logger.Info("Start to drain node %s(%s)", oldNode.Name, oldIP)
+	if err := client.DrainNode(oldNode.Name, timeout.Of(cluster, timeout.Drain)); err != nil {
+		rollbackReplace(client, cluster, oldNode.Name)
+		return fmt.Errorf("failed to drain node %s: %v", oldNode.Name, err)
+	}
+
+	logger.Info("Start to delete old host %s", oldIP)
+	if err := applyCluster(ctx, removeReplacedHost(joined, oldIP)); err != nil {
+		rollbackReplace(client, cluster, oldNode.Name)
+		return fmt.Errorf("failed to delete old host %s: %v", oldIP, err)
+	}
+	logger.Info("Succeeded in replacing host %s with %s", oldIP, newIP)
+	return nil
+}
+
+func applyCluster(ctx context.Context, cluster *v2.Cluster) error {
+	applier, err := NewApplier(cluster)
+	if err != nil {
+		return err
+	}
+	return applier.Apply(ctx)
+}
+
+// rollbackReplace reapplies the original cluster, which removes the new host and joins the old one again if it was
+// deleted, and uncordons the old node.
+func rollbackReplace(client *k8s.Client, original *v2.Cluster, oldNode string) {
+	logger.Warn("replace failed, start to roll back")
+	if err := applyCluster(context.Background(), original); err != nil {
+		logger.Error("failed to roll back cluster hosts: %v", err)
+	}
+	if err := client.CordonNode(oldNode, false); err != nil {
+		logger.Error("failed to uncordon node %s: %v", oldNode, err)
+	}
+}
+
+func copyNodeSchedulingAttrs(client *k8s.Client, from *v1.Node, toIP string) error {
+	to, err := client.GetNodeByIP(toIP)
+	if err != nil {
+		return err
+	}
+	if to.Labels == nil {
+		to.Labels = map[string]string{}
+	}
+	for k, v := range from.Labels {
+		if k == hostnameLabel {
+			continue
+		}
+		to.Labels[k] = v
+	}
+	for _, taint := range from.Spec.Taints {
+		if taint.Key == unschedulableTaint || hasTaint(to.Spec.Taints, taint) {
+			continue
+		}
+		to.Spec.Taints = append(to.Spec.Taints, taint)
+	}
+	if _, err := client.UpdateNode(to); err != nil {
+		return fmt.Errorf("failed to copy labels and taints to node %s: %v", to.Name, err)
+	}
+	return nil
+}
+
+func hasTaint(taints []v1.Taint, taint v1.Taint) bool {
+	for _, t := range taints {
+		if t.Key == taint.Key && t.Effect == taint.Effect {
+			return true
+		}
+	}
+	return false
+}
+
+// addReplaceHost returns a copy of cluster with newIP added to the host group of oldIP,
+// so the new host inherits the roles, ssh and env of the old one.
+func addReplaceHost(cluster *v2.Cluster, oldIP, newIP string) (*v2.Cluster, error) {
+	if oldIP == cluster.GetMaster0Ip() {
+		return nil, fmt.Errorf("master0 machine cannot be replaced")
+	}
+	if utils.InList(newIP, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)) {
+		return nil, fmt.Errorf("new host %s is already in the current cluster", newIP)
+	}
+	joined := cluster.DeepCopy()
+	for i := range joined.Spec.Hosts {
+		if utils.InList(oldIP, joined.Spec.Hosts[i].IPS) {
+			joined.Spec.Hosts[i].IPS = append(joined.Spec.Hosts[i].IPS, newIP)
+			return joined, nil
+		}
+	}
+	return nil, fmt.Errorf("old host %s is not in the current cluster", oldIP)
+}
+
+func removeReplacedHost(cluster *v2.Cluster, oldIP string) *v2.Cluster {
+	removed := cluster.DeepCopy()
+	for i := range removed.Spec.Hosts {
+		removed.Spec.Hosts[i].IPS = returnFilteredIPList(removed.Spec.Hosts[i].IPS, []string{oldIP})
+	}
+	return removed
+}
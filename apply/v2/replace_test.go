@@ -0,0 +1,63 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestAddReplaceHost(t *testing.T) {
+	cluster := &v2.Cluster{Spec: v2.ClusterSpec{Hosts: []v2.Host{
+		{IPS: []string{"10.0.0.1", "10.0.0.2"}, Roles: []string{common.MASTER}},
+		{IPS: []string{"10.0.0.5"}, Roles: []string{common.NODE}, Env: []string{"key=value"}},
+	}}}
+	tests := []struct {
+		name    string
+		oldIP   string
+		newIP   string
+		want    []string
+		wantErr bool
+	}{
+		{"replace node", "10.0.0.5", "10.0.0.8", []string{"10.0.0.5", "10.0.0.8"}, false},
+		{"replace master0", "10.0.0.1", "10.0.0.8", nil, true},
+		{"new host in cluster", "10.0.0.5", "10.0.0.2", nil, true},
+		{"old host not in cluster", "10.0.0.9", "10.0.0.8", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := addReplaceHost(cluster, tt.oldIP, tt.newIP)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addReplaceHost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Spec.Hosts[1].IPS, tt.want) {
+				t.Errorf("addReplaceHost() = %v, want %v", got.Spec.Hosts[1].IPS, tt.want)
+			}
+			if len(cluster.Spec.Hosts[1].IPS) != 1 {
+				t.Errorf("addReplaceHost() modified the original cluster")
+			}
+			removed := removeReplacedHost(got, tt.oldIP)
+			if !reflect.DeepEqual(removed.Spec.Hosts[1].IPS, []string{tt.newIP}) {
+				t.Errorf("removeReplacedHost() = %v, want %v", removed.Spec.Hosts[1].IPS, []string{tt.newIP})
+			}
+		})
+	}
+}
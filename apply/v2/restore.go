@@ -0,0 +1,65 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/backup"
+	"github.com/alibaba/sealer/pkg/result"
+)
+
+// Restore rebuilds the cluster in the backup archive on the hosts of its Clusterfile, and the masters are
+// replaced by masters if they are given, for the masters of the lost cluster are gone.
+func Restore(ctx context.Context, archive string, masters []string) error {
+	dir, manifest, err := backup.Open(archive)
+	if err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+	defer os.RemoveAll(dir)
+	logger.Info("Start to restore cluster %s of image %s backed up at %s", manifest.Cluster, manifest.Image, manifest.CreatedAt)
+
+	if err := backup.RestoreState(dir, manifest.Cluster); err != nil {
+		return err
+	}
+	// the Clusterfile in the work dir keeps the kubeadm config and the other documents of the cluster.
+	clusterfile := common.GetClusterWorkClusterfile(manifest.Cluster)
+	if _, err := os.Stat(clusterfile); err != nil {
+		clusterfile = filepath.Join(dir, backup.Clusterfile)
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(clusterfile))
+	if err != nil {
+		return err
+	}
+	cluster, err := GetClusterFromDataCompatV1(string(data))
+	if err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+	if err := backup.ReplaceMasters(cluster, masters); err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+	cluster.SetAnnotations(common.ClusterfileName, clusterfile)
+
+	applier, err := NewApplier(cluster)
+	if err != nil {
+		return err
+	}
+	return applier.Restore(ctx, dir)
+}
@@ -20,9 +20,11 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/pkg/runtime"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
 
 	"github.com/alibaba/sealer/apply/v2/applydriver"
 
@@ -98,6 +100,50 @@ func (c *ClusterArgs) SetClusterArgs() error {
 	return err
 }
 
+// SetNetworkingArgs sets --podcidr and --svccidr to spec.kubernetes.networking of cluster, which override the subnets
+// in the kubeadm configs of Clusterfile and CloudImage.
+func (c *ClusterArgs) SetNetworkingArgs() error {
+	for _, cidr := range []string{c.runArgs.PodCidr, c.runArgs.SvcCidr} {
+		if cidr == "" {
+			continue
+		}
+		if _, err := utils.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid cidr %s: %v", cidr, err)
+		}
+	}
+	if c.runArgs.PodCidr != "" {
+		c.cluster.Spec.Kubernetes.Networking.PodSubnet = c.runArgs.PodCidr
+	}
+	if c.runArgs.SvcCidr != "" {
+		c.cluster.Spec.Kubernetes.Networking.ServiceSubnet = c.runArgs.SvcCidr
+	}
+	return nil
+}
+
+// singleNodeTaints remove the taints kubeadm sets on the master, so that it runs the workloads of a single-node cluster.
+var singleNodeTaints = []string{
+	"node-role.kubernetes.io/master:NoSchedule-",
+	"node-role.kubernetes.io/control-plane:NoSchedule-",
+}
+
+// SetSingleNodeArgs makes the current machine the only master of cluster, which is schedulable. There is no node
+// behind lvscare, the registry is on the master, and the commands to it run natively without ssh.
+func (c *ClusterArgs) SetSingleNodeArgs() error {
+	if c.runArgs.Masters != "" || c.runArgs.Nodes != "" {
+		return fmt.Errorf("--single can not be used with --masters or --nodes")
+	}
+	ip, err := utilnet.ChooseHostInterface()
+	if err != nil {
+		return fmt.Errorf("failed to get the IP of the current machine: %v", err)
+	}
+	c.runArgs.Masters = ip.String()
+	if err := c.SetClusterArgs(); err != nil {
+		return err
+	}
+	c.cluster.Spec.Hosts[0].Taints = append(c.cluster.Spec.Hosts[0].Taints, singleNodeTaints...)
+	return nil
+}
+
 func GetClusterFileByImageName(imageName string) (*v2.Cluster, error) {
 	clusterFile, err := image.GetClusterFileFromImageManifest(imageName)
 	if err != nil {
@@ -120,10 +166,10 @@ func GetClusterFromDataCompatV1(data string) (*v2.Cluster, error) {
 		}
 		var hosts []v2.Host
 		if len(c1.Spec.Masters.IPList) != 0 {
-			hosts = append(hosts, v2.Host{IPS: c1.Spec.Masters.IPList, Roles: []string{common.MASTER}})
+			hosts = append(hosts, v2.Host{IPS: c1.Spec.Masters.IPList, Roles: []string{common.MASTER}, Disks: convertDataDisks(c1.Spec.Masters)})
 		}
 		if len(c1.Spec.Nodes.IPList) != 0 {
-			hosts = append(hosts, v2.Host{IPS: c1.Spec.Nodes.IPList, Roles: []string{common.NODE}})
+			hosts = append(hosts, v2.Host{IPS: c1.Spec.Nodes.IPList, Roles: []string{common.NODE}, Disks: convertDataDisks(c1.Spec.Nodes)})
 		}
 		cluster.APIVersion = typeV2
 		cluster.Spec.SSH = c1.Spec.SSH
@@ -144,21 +190,49 @@ func GetClusterFromDataCompatV1(data string) (*v2.Cluster, error) {
 	return cluster, nil
 }
 
+// convertDataDisks returns the disks the cloud provider attached to hosts to mount when preparing them,
+// the ones without path or device are skipped.
+func convertDataDisks(hosts v1.Hosts) []v2.DiskSpec {
+	var disks []v2.DiskSpec
+	for _, d := range hosts.Disks {
+		if d.Path == "" || d.Device == "" {
+			continue
+		}
+		disks = append(disks, v2.DiskSpec{Device: d.Device, Path: d.Path, FSType: d.FSType})
+	}
+	return disks
+}
+
 func NewApplierFromArgs(imageName string, runArgs *common.RunArgs) (applydriver.Interface, error) {
 	cluster, err := GetClusterFileByImageName(imageName)
 	if err != nil {
 		return nil, err
 	}
-	if runArgs.Nodes == "" && runArgs.Masters == "" {
-		return NewApplier(cluster)
+	if len(runArgs.Cmd) > 0 {
+		cluster.Spec.Guest.Cmd = runArgs.Cmd
+	}
+	if len(runArgs.Apps) > 0 {
+		cluster.Spec.Guest.Apps = runArgs.Apps
 	}
 	c := &ClusterArgs{
 		cluster:   cluster,
 		imageName: imageName,
 		runArgs:   runArgs,
 	}
+	if err := c.SetNetworkingArgs(); err != nil {
+		return nil, result.Wrap(result.CategoryValidation, "", err)
+	}
+	if runArgs.Single {
+		if err := c.SetSingleNodeArgs(); err != nil {
+			return nil, result.Wrap(result.CategoryValidation, "", err)
+		}
+		return NewApplier(c.cluster)
+	}
+	if runArgs.Nodes == "" && runArgs.Masters == "" {
+		return NewApplier(cluster)
+	}
 	if err := c.SetClusterArgs(); err != nil {
-		return nil, err
+		return nil, result.Wrap(result.CategoryValidation, "", err)
 	}
 	return NewApplier(c.cluster)
 }
@@ -15,10 +15,12 @@
 package apply
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
 )
 
@@ -108,3 +110,44 @@ func TestAssemblyIPList(t *testing.T) {
 		})
 	}
 }
+
+func TestGetClusterFromDataCompatV1Disks(t *testing.T) {
+	data := `apiVersion: zlink.aliyun.com/v1alpha1
+kind: Cluster
+metadata:
+  name: my-cluster
+spec:
+  image: kubernetes:v1.19.8
+  masters:
+    ipList: [192.168.0.2]
+    disks:
+    - size: "100"
+      path: /var/lib/etcd
+      device: /dev/vdc
+    - size: "200"
+      device: /dev/vdd
+  nodes:
+    ipList: [192.168.0.3]
+    disks:
+    - size: "500"
+      path: /var/lib/containerd
+      fsType: ext4
+      device: /dev/vdb
+`
+	cluster, err := GetClusterFromDataCompatV1(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]v2.DiskSpec{
+		{{Device: "/dev/vdc", Path: "/var/lib/etcd"}},
+		{{Device: "/dev/vdb", Path: "/var/lib/containerd", FSType: "ext4"}},
+	}
+	if len(cluster.Spec.Hosts) != len(want) {
+		t.Fatalf("hosts = %v, want masters and nodes", cluster.Spec.Hosts)
+	}
+	for i, host := range cluster.Spec.Hosts {
+		if !reflect.DeepEqual(host.Disks, want[i]) {
+			t.Errorf("disks of %v = %v, want %v", host.IPS, host.Disks, want[i])
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package apply
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -22,6 +23,8 @@ import (
 	"github.com/alibaba/sealer/apply/v2/applydriver"
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/scaling"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
 )
@@ -30,10 +33,7 @@ import (
 func NewScaleApplierFromArgs(clusterfile string, scaleArgs *common.RunArgs, flag string) (applydriver.Interface, error) {
 	cluster := &v2.Cluster{}
 	if err := utils.UnmarshalYamlFile(clusterfile, cluster); err != nil {
-		return nil, err
-	}
-	if scaleArgs.Nodes == "" && scaleArgs.Masters == "" {
-		return nil, fmt.Errorf("the node or master parameter was not committed")
+		return nil, result.Wrap(result.CategoryValidation, "", err)
 	}
 
 	var err error
@@ -44,7 +44,7 @@ func NewScaleApplierFromArgs(clusterfile string, scaleArgs *common.RunArgs, flag
 		err = Delete(cluster, scaleArgs)
 	}
 	if err != nil {
-		return nil, err
+		return nil, result.Wrap(result.CategoryValidation, "", err)
 	}
 
 	/*	if err := utils.MarshalYamlToFile(clusterfile, cluster); err != nil {
@@ -68,21 +68,25 @@ func Join(cluster *v2.Cluster, scalingArgs *common.RunArgs) error {
 		default:
 			return fmt.Errorf(" clusterfile provider type is not found ！")
 		}*/
+	if err := validateScaleArgs(cluster, scalingArgs, common.JoinSubCmd); err != nil {
+		return err
+	}
 	return joinBaremetalNodes(cluster, scalingArgs)
 }
 
+// validateScaleArgs checks scaleArgs against the hosts of cluster, the autoscaler validates its requests the same way.
+func validateScaleArgs(cluster *v2.Cluster, scaleArgs *common.RunArgs, action string) error {
+	req, err := scaling.NewScaleRequest(action, scaleArgs)
+	if err != nil {
+		return err
+	}
+	return req.Validate(scaling.ClusterOfV2(cluster))
+}
+
 func joinBaremetalNodes(cluster *v2.Cluster, scaleArgs *common.RunArgs) error {
 	if err := PreProcessIPList(scaleArgs); err != nil {
 		return err
 	}
-	if (!IsIPList(scaleArgs.Nodes) && scaleArgs.Nodes != "") || (!IsIPList(scaleArgs.Masters) && scaleArgs.Masters != "") {
-		return fmt.Errorf(" Parameter error: The current mode should submit iplist！")
-	}
-	// join nodes cannot be in the current cluster
-	if len(utils.ReduceIPList(removeIPListDuplicatesAndEmpty(strings.Split(scaleArgs.Masters, ",")), cluster.GetMasterIPList())) != 0 ||
-		len(utils.ReduceIPList(removeIPListDuplicatesAndEmpty(strings.Split(scaleArgs.Nodes, ",")), cluster.GetNodeIPList())) != 0 {
-		return fmt.Errorf("join nodes already in the current cluster")
-	}
 
 	if scaleArgs.Masters != "" && IsIPList(scaleArgs.Masters) {
 		for i := 0; i < len(cluster.Spec.Hosts); i++ {
@@ -135,6 +139,9 @@ func removeIPListDuplicatesAndEmpty(ipList []string) []string {
 }
 
 func Delete(cluster *v2.Cluster, scaleArgs *common.RunArgs) error {
+	if err := validateScaleArgs(cluster, scaleArgs, common.DeleteSubCmd); err != nil {
+		return err
+	}
 	return deleteBaremetalNodes(cluster, scaleArgs)
 }
 
@@ -142,18 +149,6 @@ func deleteBaremetalNodes(cluster *v2.Cluster, scaleArgs *common.RunArgs) error
 	if err := PreProcessIPList(scaleArgs); err != nil {
 		return err
 	}
-	if (!IsIPList(scaleArgs.Nodes) && scaleArgs.Nodes != "") || (!IsIPList(scaleArgs.Masters) && scaleArgs.Masters != "") {
-		return fmt.Errorf(" Parameter error: The current mode should submit iplist！")
-	}
-	//delete node must be in the current cluster
-	if len(utils.RemoveIPList(removeIPListDuplicatesAndEmpty(strings.Split(scaleArgs.Masters, ",")), cluster.GetMasterIPList())) != 0 ||
-		len(utils.RemoveIPList(removeIPListDuplicatesAndEmpty(strings.Split(scaleArgs.Nodes, ",")), cluster.GetNodeIPList())) != 0 {
-		return fmt.Errorf("delete nodes are not in the current cluster")
-	}
-	//master0 machine cannot be deleted
-	if utils.InList(cluster.GetMaster0Ip(), strings.Split(scaleArgs.Masters, ",")) {
-		return fmt.Errorf("master0 machine cannot be deleted")
-	}
 	if scaleArgs.Masters != "" && IsIPList(scaleArgs.Masters) {
 		for i := range cluster.Spec.Hosts {
 			if utils.InList(common.MASTER, cluster.Spec.Hosts[i].Roles) {
@@ -179,3 +174,35 @@ func returnFilteredIPList(clusterIPList []string, toBeDeletedIPList []string) (r
 	}
 	return
 }
+
+// DeleteHosts deletes the masters and nodes of args from the cluster in clusterfile. If master0 is one of them,
+// args.Promote is promoted to master0 first, then master0 is reset like a lost one.
+func DeleteHosts(ctx context.Context, clusterfile string, args *common.RunArgs) error {
+	cluster, err := utils.GetClusterFromFile(clusterfile)
+	if err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+	req, err := scaling.NewScaleRequest(common.DeleteSubCmd, args)
+	if err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+	if err := req.Validate(scaling.ClusterOfV2(cluster)); err != nil {
+		return result.Wrap(result.CategoryValidation, "", err)
+	}
+
+	master0 := cluster.GetMaster0Ip()
+	if utils.InList(master0, req.Masters.IPList) {
+		logger.Info("Start to promote %s to master0 before deleting master0 %s", args.Promote, master0)
+		if err := RecoverMaster0(ctx, clusterfile, args.Promote, ""); err != nil {
+			return err
+		}
+		if args.Masters = strings.Join(utils.RemoveIPList(req.Masters.IPList, []string{master0}), ","); args.Masters == "" && args.Nodes == "" {
+			return nil
+		}
+	}
+	applier, err := NewScaleApplierFromArgs(clusterfile, args, common.DeleteSubCmd)
+	if err != nil {
+		return err
+	}
+	return applier.Apply(ctx)
+}
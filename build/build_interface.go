@@ -15,6 +15,8 @@
 package build
 
 import (
+	"fmt"
+
 	"github.com/alibaba/sealer/common"
 )
 
@@ -23,6 +25,12 @@ type Interface interface {
 }
 
 func NewBuilder(config *Config) (Interface, error) {
+	if len(config.Platforms) > 0 {
+		if config.BuildType != common.LiteBuild && config.BuildType != "" {
+			return nil, fmt.Errorf("build type %s does not support --platform, only lite build does", config.BuildType)
+		}
+		return NewPlatformBuilder(config)
+	}
 	switch config.BuildType {
 	case common.AliCloudBuild:
 		return NewCloudBuilder(config)
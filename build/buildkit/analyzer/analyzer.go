@@ -0,0 +1,67 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+// Analyzer finds the container images referenced by the files copied into the CloudImage,
+// so that they are pulled into the registry dir of the image at build time.
+type Analyzer interface {
+	Name() string
+	// Match returns true if the analyzer handles the file or dir.
+	Match(path string, info os.FileInfo) bool
+	ListImages(path string) ([]string, error)
+}
+
+var analyzers = []Analyzer{chartAnalyzer{}, manifestAnalyzer{}, imageListAnalyzer{}}
+
+// Register adds an analyzer, the analyzers registered earlier take precedence.
+func Register(a Analyzer) {
+	analyzers = append(analyzers, a)
+}
+
+// Scan walks the file or dir of root and returns the images found by the analyzers,
+// a dir matched by an analyzer, like a helm chart, is not walked into.
+func Scan(root string) ([]string, error) {
+	var images []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, a := range analyzers {
+			if !a.Match(path, info) {
+				continue
+			}
+			list, err := a.ListImages(path)
+			if err != nil {
+				return fmt.Errorf("%s analyzer failed to list images of %s: %v", a.Name(), path, err)
+			}
+			logger.Debug("%s analyzer found images %v in %s", a.Name(), list, path)
+			images = append(images, list...)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return nil
+	})
+	return images, err
+}
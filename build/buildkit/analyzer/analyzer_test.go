@@ -0,0 +1,89 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-analyzer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"dashboard.yaml":   "spec:\n  containers:\n  - image: kubernetesui/dashboard:v2.2.0\n",
+		"app/deploy.yml":   "      image: \"nginx:1.21\"\n",
+		"app/imageList":    "busybox:1.28\n",
+		"app/README.md":    "image: ignored:latest\n",
+		"app/values.txt":   "",
+		"app/sub/cm.yaml":  "data:\n  key: value\n",
+		"imageExcludeList": "# comment\nnginx\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	images, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	sort.Strings(images)
+	want := []string{"busybox:1.28", "kubernetesui/dashboard:v2.2.0", "nginx:1.21"}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("Scan() got = %v, want %v", images, want)
+	}
+
+	excludes, err := LoadExcludes(filepath.Join(dir, ExcludeListFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Filter(images, excludes); !reflect.DeepEqual(got, want[:2]) {
+		t.Errorf("Filter() got = %v, want %v", got, want[:2])
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	tests := []struct {
+		image    string
+		patterns []string
+		want     bool
+	}{
+		{"nginx:1.21", []string{"nginx"}, true},
+		{"nginx@sha256:abc", []string{"nginx"}, true},
+		{"nginx-ingress:1.0", []string{"nginx"}, false},
+		{"docker.io/library/nginx:1.21", []string{"docker.io/library/*"}, true},
+		{"quay.io/coreos/etcd:v3", []string{"docker.io/library/*"}, false},
+		{"busybox", nil, false},
+	}
+	for _, tt := range tests {
+		if got := Excluded(tt.image, tt.patterns); got != tt.want {
+			t.Errorf("Excluded(%s, %v) = %v, want %v", tt.image, tt.patterns, got, tt.want)
+		}
+	}
+}
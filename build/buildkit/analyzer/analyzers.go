@@ -0,0 +1,82 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/build/buildkit/buildlayer/layerutils/charts"
+	manifest "github.com/alibaba/sealer/build/buildkit/buildlayer/layerutils/manifests"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// ImageListFile lists the images line by line.
+	ImageListFile = "imageList"
+	chartMetadata = "Chart.yaml"
+)
+
+// chartAnalyzer renders the helm chart with default values.
+type chartAnalyzer struct{}
+
+func (chartAnalyzer) Name() string {
+	return "chart"
+}
+
+func (chartAnalyzer) Match(path string, info os.FileInfo) bool {
+	return info.IsDir() && utils.IsFileExist(filepath.Join(path, chartMetadata))
+}
+
+func (chartAnalyzer) ListImages(path string) ([]string, error) {
+	c, err := charts.NewCharts()
+	if err != nil {
+		return nil, err
+	}
+	return c.ListImages(path)
+}
+
+// manifestAnalyzer decodes the image fields of kubernetes yaml.
+type manifestAnalyzer struct{}
+
+func (manifestAnalyzer) Name() string {
+	return "manifest"
+}
+
+func (manifestAnalyzer) Match(path string, info os.FileInfo) bool {
+	return !info.IsDir() && utils.YamlMatcher(path)
+}
+
+func (manifestAnalyzer) ListImages(path string) ([]string, error) {
+	m, err := manifest.NewManifests()
+	if err != nil {
+		return nil, err
+	}
+	return m.ListImages(path)
+}
+
+type imageListAnalyzer struct{}
+
+func (imageListAnalyzer) Name() string {
+	return "imageList"
+}
+
+func (imageListAnalyzer) Match(path string, info os.FileInfo) bool {
+	return !info.IsDir() && info.Name() == ImageListFile
+}
+
+func (imageListAnalyzer) ListImages(path string) ([]string, error) {
+	return utils.ReadLines(path)
+}
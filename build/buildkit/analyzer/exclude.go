@@ -0,0 +1,71 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzer
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/alibaba/sealer/utils"
+)
+
+// ExcludeListFile in the build context lists the image patterns not to be pulled, one per line:
+// "nginx" excludes all tags of nginx, and glob like "docker.io/library/*" is supported.
+const ExcludeListFile = "imageExcludeList"
+
+// LoadExcludes reads the exclude list, it is empty if the file does not exist.
+func LoadExcludes(file string) ([]string, error) {
+	if !utils.IsFileExist(file) {
+		return nil, nil
+	}
+	lines, err := utils.ReadLines(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image exclude list %s: %v", file, err)
+	}
+	var patterns []string
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		patterns = append(patterns, l)
+	}
+	return patterns, nil
+}
+
+// Excluded returns true if image matches one of the patterns.
+func Excluded(image string, patterns []string) bool {
+	for _, p := range patterns {
+		if image == p || strings.HasPrefix(image, p+":") || strings.HasPrefix(image, p+"@") {
+			return true
+		}
+		if ok, err := path.Match(p, image); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter drops the excluded images.
+func Filter(images []string, patterns []string) []string {
+	var res []string
+	for _, image := range images {
+		if !Excluded(image, patterns) {
+			res = append(res, image)
+		}
+	}
+	return res
+}
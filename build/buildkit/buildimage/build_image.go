@@ -16,19 +16,27 @@ package buildimage
 
 import (
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/alibaba/sealer/utils"
 
 	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/sbom"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 
 	"github.com/alibaba/sealer/build/buildkit/buildinstruction"
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/platform"
 	"github.com/alibaba/sealer/image/store"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/component"
 	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/version"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"sigs.k8s.io/yaml"
 )
@@ -40,26 +48,63 @@ const (
 // BuildImage this struct aims to provide image object in build stage
 // include handle layers,save build images to system.
 type BuildImage struct {
-	RawImage        *v1.Image
-	BaseLayers      []v1.Layer
-	NewLayers       []v1.Layer
+	RawImage   *v1.Image
+	BaseLayers []v1.Layer
+	NewLayers  []v1.Layer
+	// Stages are the builder stages and images referenced by COPY --from, built before the final stage.
+	Stages []BuildStage
+	// Vars are the ARG and ENV of kubefile, they are rendered into the copied manifests.
+	Vars            map[string]string
 	ImageStore      store.ImageStore
 	LayerStore      store.LayerStore
 	ImageService    image.Service
 	RootfsMountInfo *buildinstruction.MountTarget
 	ImageMataData   runtime.Metadata
+	// Platform is the target platform of the image, empty means the platform of base image.
+	Platform v1.Platform
+	// Provenance is recorded in the SBOM of the image.
+	Provenance sbom.Provenance
 }
 
 func (b BuildImage) ExecBuild(ctx Context) error {
-	var (
-		execCtx    buildinstruction.ExecContext
-		newLayers  = b.NewLayers
-		baseLayers = b.BaseLayers
-	)
+	// the layers of each stage, keyed by stage name and index.
+	stageLayers := map[string][]v1.Layer{}
+	for _, stage := range b.Stages {
+		layers := stage.BaseLayers
+		if len(stage.NewLayers) > 0 {
+			logger.Info("run build stage: %s", stage)
+			var err error
+			// builder stages have no rootfs, the layer handlers only apply to the final stage.
+			if layers, err = b.execLayers(ctx, stage.BaseLayers, stage.NewLayers, "", stageLayers); err != nil {
+				return fmt.Errorf("failed to build stage %s: %v", stage, err)
+			}
+		}
+		for _, name := range stage.Names() {
+			stageLayers[name] = layers
+		}
+	}
+
+	var tempRoot string
+	if b.RootfsMountInfo != nil {
+		tempRoot = b.RootfsMountInfo.GetMountTarget()
+	}
+	if _, err := b.execLayers(ctx, b.BaseLayers, b.NewLayers, tempRoot, stageLayers); err != nil {
+		return err
+	}
+
+	logger.Info("exec all build instructs success !")
+	return nil
+}
+
+// execLayers runs newLayers on baseLayers and sets the id of each new layer,
+// returns all the layers of the stage.
+func (b BuildImage) execLayers(ctx Context, baseLayers, newLayers []v1.Layer, rootfs string, stageLayers map[string][]v1.Layer) ([]v1.Layer, error) {
+	var execCtx buildinstruction.ExecContext
+	baseLayers = append([]v1.Layer{}, baseLayers...)
 	if ctx.UseCache {
-		execCtx = buildinstruction.NewExecContext(ctx.BuildType, ctx.BuildContext, b.ImageService, b.LayerStore)
+		execCtx = buildinstruction.NewExecContext(ctx.BuildType, ctx.BuildContext, baseLayers, b.ImageService, b.LayerStore)
 	} else {
-		execCtx = buildinstruction.NewExecContextWithoutCache(ctx.BuildType, ctx.BuildContext, b.LayerStore)
+		execCtx = buildinstruction.NewExecContextWithoutCache(ctx.BuildType, ctx.BuildContext, baseLayers, b.LayerStore)
 	}
 	for i := 0; i < len(newLayers); i++ {
 		//we are to set layer id for each new layers.
@@ -69,28 +114,27 @@ func (b BuildImage) ExecBuild(ctx Context) error {
 		if ctx.BuildType == common.LiteBuild && layer.Type == common.CMDCOMMAND {
 			continue
 		}
-		var tempRoot string
-		if b.RootfsMountInfo != nil {
-			tempRoot = b.RootfsMountInfo.GetMountTarget()
-		}
 		//run layer instruction exec to get layer id and cache id
 		ic := buildinstruction.InstructionContext{
 			BaseLayers:   baseLayers,
 			CurrentLayer: layer,
-			Rootfs:       tempRoot,
+			Rootfs:       rootfs,
+			Stages:       stageLayers,
+			Vars:         b.Vars,
 		}
 		inst, err := buildinstruction.NewInstruction(ic)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		out, err := inst.Exec(execCtx)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		// update current layer cache status for next cache
+		// update current layer cache status for next cache, once a layer
+		// missed the cache, the later layers are rebuilt but still recorded.
+		execCtx.ParentID = out.ParentID
 		if execCtx.ContinueCache {
-			execCtx.ParentID = out.ParentID
 			execCtx.ContinueCache = out.ContinueCache
 		}
 		layer.ID = out.LayerID
@@ -100,9 +144,7 @@ func (b BuildImage) ExecBuild(ctx Context) error {
 
 		baseLayers = append(baseLayers, *layer)
 	}
-
-	logger.Info("exec all build instructs success !")
-	return nil
+	return baseLayers, nil
 }
 
 func (b BuildImage) genNewLayer(layerType, layerValue, filepath string) (v1.Layer, error) {
@@ -145,11 +187,42 @@ func (b BuildImage) checkImageMetadata() error {
 
 	return nil
 }
+
+// checkComponents verifies the components of the built rootfs against the sha256 digests pinned in its
+// component manifest, the missing ones are downloaded to the rootfs cache layer.
+func (b BuildImage) checkComponents() error {
+	target, err := buildinstruction.NewMountTarget("", "", buildinstruction.GetBaseLayersPath(append(b.BaseLayers, b.NewLayers...)))
+	if err != nil {
+		return err
+	}
+	err = target.TempMount()
+	if err != nil {
+		return err
+	}
+	defer target.CleanUp()
+
+	m, err := component.LoadManifest(target.GetMountTarget())
+	if err != nil || m == nil {
+		return err
+	}
+	arch := b.ImageMataData.Arch
+	if !platform.IsZero(b.Platform) {
+		arch = b.Platform.Architecture
+	}
+	if arch == "" {
+		arch = runtime.GetCloudImagePlatform(target.GetMountTarget()).Architecture
+	}
+	return m.Verify(target.GetMountTarget(), b.RootfsMountInfo.GetMountTarget(), arch)
+}
+
 func (b BuildImage) SaveBuildImage(name string, opts SaveOpts) error {
 	err := b.checkImageMetadata()
 	if err != nil {
 		return err
 	}
+	if err = b.checkComponents(); err != nil {
+		return fmt.Errorf("failed to verify components, err: %v", err)
+	}
 
 	cluster, err := b.getImageCluster()
 	if err != nil {
@@ -167,6 +240,12 @@ func (b BuildImage) SaveBuildImage(name string, opts SaveOpts) error {
 	}
 
 	b.RawImage.Spec.Layers = layers
+	if !platform.IsZero(b.Platform) {
+		b.RawImage.Spec.Platform = b.Platform
+	}
+	if err = b.setSBOM(name); err != nil {
+		return fmt.Errorf("failed to generate SBOM, err: %v", err)
+	}
 
 	err = b.updateImageIDAndSaveImage(name)
 	if err != nil {
@@ -177,6 +256,29 @@ func (b BuildImage) SaveBuildImage(name string, opts SaveOpts) error {
 	return nil
 }
 
+// setSBOM lists the components of the built rootfs and saves them with the provenance to image annotation.
+func (b BuildImage) setSBOM(name string) error {
+	components, err := sbom.Generate(b.RootfsMountInfo.GetMountTarget())
+	if err != nil {
+		return err
+	}
+	provenance := b.Provenance
+	provenance.BuildTime = time.Now()
+	if !platform.IsZero(b.Platform) {
+		provenance.Platform = platform.Format(b.Platform)
+	} else if b.ImageMataData.Arch != "" {
+		provenance.Platform = platform.Format(platform.Normalize(v1.Platform{
+			Architecture: b.ImageMataData.Arch,
+			Variant:      b.ImageMataData.Variant,
+		}))
+	}
+	return sbom.SetToImage(&sbom.SBOM{
+		Image:      name,
+		Provenance: provenance,
+		Components: components,
+	}, b.RawImage)
+}
+
 func (b BuildImage) collectLayers(opts SaveOpts) ([]v1.Layer, error) {
 	var layers []v1.Layer
 
@@ -239,11 +341,22 @@ func (b BuildImage) Cleanup() error {
 	return nil
 }
 
-func NewBuildImage(kubefileName string) (Interface, error) {
-	rawImage, err := InitImageSpec(kubefileName)
+// NewBuildImage returns the image to build from kubefile, a multi-platform base image
+// is resolved to the image of platform p, or the host platform if p is empty.
+func NewBuildImage(kubefileName string, buildArgs map[string]string, p v1.Platform) (Interface, error) {
+	provenance, err := newProvenance(kubefileName, buildArgs)
+	if err != nil {
+		return nil, err
+	}
+	if !platform.IsZero(p) {
+		buildArgs = withPlatformArgs(buildArgs, p)
+	}
+	kubefile, err := ParseKubefile(kubefileName, buildArgs)
 	if err != nil {
 		return nil, err
 	}
+	rawImage := kubefile.Image
+	provenance.BaseImage = rawImage.Spec.Layers[0].Value
 
 	layerStore, err := store.NewDefaultLayerStore()
 	if err != nil {
@@ -262,30 +375,24 @@ func NewBuildImage(kubefileName string) (Interface, error) {
 
 	var (
 		layer0    = rawImage.Spec.Layers[0]
-		baseImage *v1.Image
 		mountInfo *buildinstruction.MountTarget
 	)
 
 	// and the layer 0 must be from layer
-	if layer0.Value == common.ImageScratch {
-		// give an empty image
-		baseImage = &v1.Image{}
-	} else {
-		if err = service.PullIfNotExist(layer0.Value); err != nil {
-			return nil, fmt.Errorf("failed to pull baseImage: %v", err)
-		}
-		baseImage, err = imageStore.GetByName(layer0.Value)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get base image err: %s", err)
-		}
+	baseLayers, err := getBaseLayers(service, imageStore, layer0.Value, p)
+	if err != nil {
+		return nil, err
 	}
-
-	baseLayers := append([]v1.Layer{}, baseImage.Spec.Layers...)
 	newLayers := append([]v1.Layer{}, rawImage.Spec.Layers[1:]...)
 	if len(baseLayers)+len(newLayers) > maxLayerDeep {
 		return nil, errors.New("current number of layers exceeds 128 layers")
 	}
 
+	buildStages, err := newBuildStages(service, imageStore, kubefile.Stages, p)
+	if err != nil {
+		return nil, err
+	}
+
 	mountInfo, err = GetRootfsMountInfo(baseLayers)
 	if err != nil {
 		return nil, err
@@ -299,6 +406,14 @@ func NewBuildImage(kubefileName string) (Interface, error) {
 	if meta != nil {
 		md = *meta
 	}
+	// the container images are pulled for the arch of metadata, so set it before build.
+	if !platform.IsZero(p) && (md.Arch != p.Architecture || md.Variant != p.Variant) {
+		md.Arch, md.Variant = p.Architecture, p.Variant
+		mf := filepath.Join(mountInfo.GetMountTarget(), common.DefaultMetadataName)
+		if err = utils.MarshalJSONToFile(mf, md); err != nil {
+			return nil, fmt.Errorf("failed to set image Metadata file, err: %v", err)
+		}
+	}
 	return &BuildImage{
 		RawImage:        rawImage,
 		ImageStore:      imageStore,
@@ -306,7 +421,27 @@ func NewBuildImage(kubefileName string) (Interface, error) {
 		ImageService:    service,
 		BaseLayers:      baseLayers,
 		NewLayers:       newLayers,
+		Stages:          buildStages,
+		Vars:            kubefile.Vars,
 		RootfsMountInfo: mountInfo,
 		ImageMataData:   md,
+		Platform:        p,
+		Provenance:      provenance,
 	}, nil
 }
+
+func newProvenance(kubefileName string, buildArgs map[string]string) (sbom.Provenance, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(kubefileName))
+	if err != nil {
+		return sbom.Provenance{}, fmt.Errorf("failed to load kubefile: %v", err)
+	}
+	provenance := sbom.Provenance{
+		BuilderVersion: version.Get().GitVersion,
+		KubefileDigest: digest.FromBytes(data).String(),
+	}
+	for k := range buildArgs {
+		provenance.BuildArgs = append(provenance.BuildArgs, k)
+	}
+	sort.Strings(provenance.BuildArgs)
+	return provenance, nil
+}
@@ -0,0 +1,50 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildimage
+
+import (
+	"github.com/alibaba/sealer/image/platform"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// the build args set automatically for the target platform, like docker buildx.
+const (
+	argTargetPlatform = "TARGETPLATFORM"
+	argTargetOS       = "TARGETOS"
+	argTargetArch     = "TARGETARCH"
+	argTargetVariant  = "TARGETVARIANT"
+)
+
+func isPlatformArg(key string) bool {
+	switch key {
+	case argTargetPlatform, argTargetOS, argTargetArch, argTargetVariant:
+		return true
+	}
+	return false
+}
+
+// withPlatformArgs returns buildArgs with the platform args of p, the args given by user take precedence.
+func withPlatformArgs(buildArgs map[string]string, p v1.Platform) map[string]string {
+	args := map[string]string{
+		argTargetPlatform: platform.Format(p),
+		argTargetOS:       p.OS,
+		argTargetArch:     p.Architecture,
+		argTargetVariant:  p.Variant,
+	}
+	for k, v := range buildArgs {
+		args[k] = v
+	}
+	return args
+}
@@ -0,0 +1,212 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildimage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/sealer/build/buildkit/buildlayer"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// Stage is a FROM section of Kubefile, all stages but the last one are builder
+// stages, their layers are only used by COPY --from of the later stages.
+type Stage struct {
+	// Name is set by FROM image AS name, the stage can be referenced by index as well.
+	Name      string
+	Index     int
+	BaseImage string
+	// Layers are the instructions of the stage without FROM.
+	Layers []v1.Layer
+}
+
+func (s Stage) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return strconv.Itoa(s.Index)
+}
+
+func (s Stage) hasName(name string) bool {
+	return name == strconv.Itoa(s.Index) || (s.Name != "" && name == s.Name)
+}
+
+// parseFromValue parses "kubernetes:v1.19.8 AS builder".
+func parseFromValue(value string) (image, name string, err error) {
+	fields := strings.Fields(value)
+	switch {
+	case len(fields) == 1:
+		return fields[0], "", nil
+	case len(fields) == 3 && strings.EqualFold(fields[1], "as"):
+		return fields[0], fields[2], nil
+	}
+	return "", "", fmt.Errorf("invalid FROM %s, should be FROM image [AS name]", value)
+}
+
+// ParseStages splits the layers of Kubefile into build stages.
+func ParseStages(layers []v1.Layer) ([]Stage, error) {
+	if len(layers) == 0 || layers[0].Type != common.FROMCOMMAND {
+		return nil, fmt.Errorf("first line of kubefile must start with %s", common.FROMCOMMAND)
+	}
+
+	var stages []Stage
+	for _, layer := range layers {
+		if layer.Type == common.FROMCOMMAND {
+			image, name, err := parseFromValue(layer.Value)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range stages {
+				if name != "" && s.hasName(name) {
+					return nil, fmt.Errorf("duplicated stage name %s", name)
+				}
+			}
+			stages = append(stages, Stage{Name: name, Index: len(stages), BaseImage: image})
+			continue
+		}
+
+		current := &stages[len(stages)-1]
+		if layer.Type == common.COPYCOMMAND {
+			from, rest := buildlayer.ParseCopyFrom(layer.Value)
+			if len(strings.Fields(rest)) != 2 {
+				return nil, fmt.Errorf("invalid COPY %s, should be COPY [--from=stage] src dest", layer.Value)
+			}
+			if from != "" && current.hasName(from) {
+				return nil, fmt.Errorf("stage %s can not copy from itself", from)
+			}
+		}
+		current.Layers = append(current.Layers, layer)
+	}
+
+	for i := range stages {
+		for _, ref := range copyFromRefs(stages, i) {
+			for _, s := range stages[i:] {
+				if s.hasName(ref) {
+					return nil, fmt.Errorf("stage %s must be defined before copying from it", ref)
+				}
+			}
+		}
+	}
+	return stages, nil
+}
+
+// copyFromRefs returns the --from references of stage which are not the previous
+// stages, they are taken as image names.
+func copyFromRefs(stages []Stage, index int) []string {
+	var refs []string
+	for _, layer := range stages[index].Layers {
+		if layer.Type != common.COPYCOMMAND {
+			continue
+		}
+		from, _ := buildlayer.ParseCopyFrom(layer.Value)
+		if from == "" {
+			continue
+		}
+		var isStage bool
+		for _, s := range stages[:index] {
+			if s.hasName(from) {
+				isStage = true
+				break
+			}
+		}
+		if !isStage {
+			refs = append(refs, from)
+		}
+	}
+	return refs
+}
+
+// BuildStage is a builder stage or an image referenced by COPY --from.
+type BuildStage struct {
+	Stage
+	BaseLayers []v1.Layer
+	NewLayers  []v1.Layer
+}
+
+// Names returns the names COPY --from may use to reference the stage.
+func (s BuildStage) Names() []string {
+	if s.Index < 0 {
+		return []string{s.Name}
+	}
+	names := []string{strconv.Itoa(s.Index)}
+	if s.Name != "" {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// getBaseLayers returns the layers of image name, a multi-platform image is resolved to the image of platform p.
+func getBaseLayers(service image.Service, imageStore store.ImageStore, name string, p v1.Platform) ([]v1.Layer, error) {
+	if name == common.ImageScratch {
+		// give an empty image
+		return nil, nil
+	}
+	if err := service.PullIfNotExist(name); err != nil {
+		return nil, fmt.Errorf("failed to pull baseImage: %v", err)
+	}
+	baseImage, err := imageStore.GetByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get base image err: %s", err)
+	}
+	if platform.IsZero(p) {
+		p = platform.Default()
+	}
+	if baseImage, err = platform.Resolve(imageStore, baseImage, p); err != nil {
+		return nil, err
+	}
+	return append([]v1.Layer{}, baseImage.Spec.Layers...), nil
+}
+
+// newBuildStages prepares the builder stages and the images referenced by COPY --from in build order.
+func newBuildStages(service image.Service, imageStore store.ImageStore, stages []Stage, p v1.Platform) ([]BuildStage, error) {
+	var (
+		buildStages []BuildStage
+		images      = map[string]bool{}
+	)
+	for i, stage := range stages {
+		for _, ref := range copyFromRefs(stages, i) {
+			if images[ref] {
+				continue
+			}
+			layers, err := getBaseLayers(service, imageStore, ref, p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get image %s of COPY --from: %v", ref, err)
+			}
+			images[ref] = true
+			buildStages = append(buildStages, BuildStage{Stage: Stage{Name: ref, Index: -1}, BaseLayers: layers})
+		}
+
+		// the final stage is built on rootfs.
+		if i == len(stages)-1 {
+			break
+		}
+		baseLayers, err := getBaseLayers(service, imageStore, stage.BaseImage, p)
+		if err != nil {
+			return nil, err
+		}
+		newLayers := append([]v1.Layer{}, stage.Layers...)
+		if len(baseLayers)+len(newLayers) > maxLayerDeep {
+			return nil, fmt.Errorf("current number of layers of stage %d exceeds %d layers", i, maxLayerDeep)
+		}
+		buildStages = append(buildStages, BuildStage{Stage: stage, BaseLayers: baseLayers, NewLayers: newLayers})
+	}
+	return buildStages, nil
+}
@@ -0,0 +1,114 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildimage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestParseStages(t *testing.T) {
+	layer := func(layerType, value string) v1.Layer {
+		return v1.Layer{Type: layerType, Value: value}
+	}
+	tests := []struct {
+		name    string
+		layers  []v1.Layer
+		want    []Stage
+		refs    []string
+		wantErr bool
+	}{
+		{
+			"single stage",
+			[]v1.Layer{layer(common.FROMCOMMAND, "kubernetes:v1.19.8"), layer(common.COPYCOMMAND, ". .")},
+			[]Stage{{BaseImage: "kubernetes:v1.19.8", Layers: []v1.Layer{layer(common.COPYCOMMAND, ". .")}}},
+			nil,
+			false,
+		},
+		{
+			"copy from builder stage and image",
+			[]v1.Layer{
+				layer(common.FROMCOMMAND, "scratch AS builder"),
+				layer(common.RUNCOMMAND, "make"),
+				layer(common.FROMCOMMAND, "kubernetes:v1.19.8"),
+				layer(common.COPYCOMMAND, "--from=builder /out bin"),
+				layer(common.COPYCOMMAND, "--from=0 /out bin"),
+				layer(common.COPYCOMMAND, "--from=helm:v3 /helm bin"),
+			},
+			[]Stage{
+				{Name: "builder", BaseImage: "scratch", Layers: []v1.Layer{layer(common.RUNCOMMAND, "make")}},
+				{Index: 1, BaseImage: "kubernetes:v1.19.8", Layers: []v1.Layer{
+					layer(common.COPYCOMMAND, "--from=builder /out bin"),
+					layer(common.COPYCOMMAND, "--from=0 /out bin"),
+					layer(common.COPYCOMMAND, "--from=helm:v3 /helm bin"),
+				}},
+			},
+			[]string{"helm:v3"},
+			false,
+		},
+		{
+			"copy from itself",
+			[]v1.Layer{layer(common.FROMCOMMAND, "scratch as builder"), layer(common.COPYCOMMAND, "--from=builder a b")},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"copy from later stage",
+			[]v1.Layer{
+				layer(common.FROMCOMMAND, "scratch"),
+				layer(common.COPYCOMMAND, "--from=final a b"),
+				layer(common.FROMCOMMAND, "scratch AS final"),
+			},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"duplicated stage name",
+			[]v1.Layer{layer(common.FROMCOMMAND, "scratch AS a"), layer(common.FROMCOMMAND, "scratch AS a")},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"first line is not from",
+			[]v1.Layer{layer(common.COPYCOMMAND, ". .")},
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStages(tt.layers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStages() got = %+v, want %+v", got, tt.want)
+			}
+			if refs := copyFromRefs(got, len(got)-1); !reflect.DeepEqual(refs, tt.refs) {
+				t.Errorf("copyFromRefs() got = %v, want %v", refs, tt.refs)
+			}
+		})
+	}
+}
@@ -34,8 +34,26 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
-// InitImageSpec init default Image metadata
-func InitImageSpec(kubefile string) (*v1.Image, error) {
+// Kubefile is the parsed kubefile whose ARG and ENV are substituted.
+type Kubefile struct {
+	// Image is the final stage of kubefile.
+	Image  *v1.Image
+	Stages []Stage
+	// Vars are the ARG and ENV of kubefile.
+	Vars map[string]string
+}
+
+// InitImageSpec init default Image metadata, the layers are the final stage of kubefile.
+func InitImageSpec(kubefile string, buildArgs map[string]string) (*v1.Image, error) {
+	kf, err := ParseKubefile(kubefile, buildArgs)
+	if err != nil {
+		return nil, err
+	}
+	return kf.Image, nil
+}
+
+// ParseKubefile returns the image of the final stage and all stages of kubefile.
+func ParseKubefile(kubefile string, buildArgs map[string]string) (*Kubefile, error) {
 	kubeFile, err := utils.ReadAll(kubefile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load kubefile: %v", err)
@@ -46,12 +64,19 @@ func InitImageSpec(kubefile string) (*v1.Image, error) {
 		return nil, fmt.Errorf("failed to parse kubefile, image is nil")
 	}
 
-	layer0 := rawImage.Spec.Layers[0]
-	if layer0.Type != common.FROMCOMMAND {
-		return nil, fmt.Errorf("first line of kubefile must start with %s", common.FROMCOMMAND)
+	layers, vars, err := expandLayers(rawImage.Spec.Layers, buildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	stages, err := ParseStages(layers)
+	if err != nil {
+		return nil, err
 	}
 
-	return rawImage, nil
+	final := stages[len(stages)-1]
+	rawImage.Spec.Layers = append([]v1.Layer{{Type: common.FROMCOMMAND, Value: final.BaseImage}}, final.Layers...)
+	return &Kubefile{Image: rawImage, Stages: stages, Vars: vars}, nil
 }
 
 func setClusterFileToImage(cluster *v2.Cluster, image *v1.Image) error {
@@ -126,6 +151,9 @@ func CacheDockerImage(base string, newLayers []v1.Layer) bool {
 			layer.Type == common.CMDCOMMAND {
 			return true
 		}
+		if from, _ := buildlayer.ParseCopyFrom(layer.Value); from != "" {
+			continue
+		}
 		ht := buildlayer.GetCopyLayerHandlerType(buildlayer.ParseCopyLayerContent(layer.Value))
 		if ht != "" {
 			return true
@@ -0,0 +1,91 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildimage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/alibaba/sealer/build/buildkit/buildlayer"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// splitVar parses "KEY=VALUE", ENV also accepts "KEY VALUE".
+func splitVar(value string, allowSpace bool) (key, val string, hasValue bool, err error) {
+	sep := strings.IndexByte(value, '=')
+	if sp := strings.IndexAny(value, " \t"); allowSpace && sp >= 0 && (sep < 0 || sp < sep) {
+		sep = sp
+	}
+	key = value
+	if sep >= 0 {
+		key, val, hasValue = value[:sep], strings.TrimSpace(value[sep+1:]), true
+	}
+	key = strings.TrimSpace(key)
+	if !varNamePattern.MatchString(key) {
+		return "", "", false, fmt.Errorf("invalid variable name %q", key)
+	}
+	if len(val) >= 2 {
+		if c := val[len(val)-1]; val[0] == c && (c == '"' || c == '\'') {
+			val = val[1 : len(val)-1]
+		}
+	}
+	return key, val, hasValue, nil
+}
+
+// expandLayers substitutes the ARG and ENV of kubefile into the later instructions,
+// buildArgs override the default value of ARG. The returned layers have no ARG and ENV.
+func expandLayers(layers []v1.Layer, buildArgs map[string]string) ([]v1.Layer, map[string]string, error) {
+	var (
+		res      []v1.Layer
+		vars     = map[string]string{}
+		declared = map[string]bool{}
+	)
+	for _, layer := range layers {
+		switch layer.Type {
+		case common.ARGCOMMAND:
+			key, val, _, err := splitVar(layer.Value, false)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid ARG %s: %v", layer.Value, err)
+			}
+			declared[key] = true
+			if arg, ok := buildArgs[key]; ok {
+				vars[key] = arg
+				continue
+			}
+			vars[key] = buildlayer.ExpandVars(val, vars)
+		case common.ENVCOMMAND:
+			key, val, hasValue, err := splitVar(layer.Value, true)
+			if err != nil || !hasValue {
+				return nil, nil, fmt.Errorf("invalid ENV %s, should be ENV KEY=VALUE", layer.Value)
+			}
+			vars[key] = buildlayer.ExpandVars(val, vars)
+		default:
+			layer.Value = buildlayer.ExpandVars(layer.Value, vars)
+			res = append(res, layer)
+		}
+	}
+
+	for key := range buildArgs {
+		if !declared[key] && !isPlatformArg(key) {
+			logger.Warn("build arg %s is not declared by ARG in kubefile, ignore it", key)
+		}
+	}
+	return res, vars, nil
+}
@@ -0,0 +1,179 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildimage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestSplitVar(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		allowSpace bool
+		key        string
+		val        string
+		hasValue   bool
+		wantErr    bool
+	}{
+		{"key only", "VERSION", false, "VERSION", "", false, false},
+		{"key value", "VERSION=v1.19.8", false, "VERSION", "v1.19.8", true, false},
+		{"empty value", "VERSION=", false, "VERSION", "", true, false},
+		{"double quoted", `MSG="hello world"`, false, "MSG", "hello world", true, false},
+		{"single quoted", `MSG='hello world'`, false, "MSG", "hello world", true, false},
+		{"unmatched quote", `MSG="hello`, false, "MSG", `"hello`, true, false},
+		{"value with equal", "OPTS=a=b", false, "OPTS", "a=b", true, false},
+		{"space not allowed", "MSG hello", false, "", "", false, true},
+		{"env key value", "MSG hello world", true, "MSG", "hello world", true, false},
+		{"env quoted value", `MSG "hello world"`, true, "MSG", "hello world", true, false},
+		{"env equal after space", "MSG a=b", true, "MSG", "a=b", true, false},
+		{"env key=value with space", "MSG=hello world", true, "MSG", "hello world", true, false},
+		{"invalid name", "1VERSION=v1", false, "", "", false, true},
+		{"empty name", "=v1", false, "", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, val, hasValue, err := splitVar(tt.value, tt.allowSpace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitVar(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if key != tt.key || val != tt.val || hasValue != tt.hasValue {
+				t.Errorf("splitVar(%q) = %q, %q, %v, want %q, %q, %v", tt.value, key, val, hasValue, tt.key, tt.val, tt.hasValue)
+			}
+		})
+	}
+}
+
+func TestExpandLayers(t *testing.T) {
+	layer := func(layerType, value string) v1.Layer {
+		return v1.Layer{Type: layerType, Value: value}
+	}
+	tests := []struct {
+		name      string
+		layers    []v1.Layer
+		buildArgs map[string]string
+		want      []v1.Layer
+		vars      map[string]string
+		wantErr   bool
+	}{
+		{
+			"arg default",
+			[]v1.Layer{layer(common.ARGCOMMAND, "VERSION=v1.19.8"), layer(common.RUNCOMMAND, "echo $VERSION")},
+			nil,
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo v1.19.8")},
+			map[string]string{"VERSION": "v1.19.8"},
+			false,
+		},
+		{
+			"build arg overrides default",
+			[]v1.Layer{layer(common.ARGCOMMAND, "VERSION=v1.19.8"), layer(common.RUNCOMMAND, "echo ${VERSION}")},
+			map[string]string{"VERSION": "v1.20.0"},
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo v1.20.0")},
+			map[string]string{"VERSION": "v1.20.0"},
+			false,
+		},
+		{
+			"arg without default",
+			[]v1.Layer{layer(common.ARGCOMMAND, "VERSION"), layer(common.RUNCOMMAND, "echo $VERSION")},
+			nil,
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo ")},
+			map[string]string{"VERSION": ""},
+			false,
+		},
+		{
+			"env key value",
+			[]v1.Layer{layer(common.ENVCOMMAND, "MSG hello world"), layer(common.RUNCOMMAND, "echo $MSG")},
+			nil,
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo hello world")},
+			map[string]string{"MSG": "hello world"},
+			false,
+		},
+		{
+			"quoted env refers to arg",
+			[]v1.Layer{
+				layer(common.ARGCOMMAND, "REGISTRY=sea.hub:5000"),
+				layer(common.ENVCOMMAND, `IMAGE="${REGISTRY}/pause:3.2"`),
+				layer(common.COPYCOMMAND, "$IMAGE.tar images"),
+			},
+			nil,
+			[]v1.Layer{layer(common.COPYCOMMAND, "sea.hub:5000/pause:3.2.tar images")},
+			map[string]string{"REGISTRY": "sea.hub:5000", "IMAGE": "sea.hub:5000/pause:3.2"},
+			false,
+		},
+		{
+			"env overrides arg",
+			[]v1.Layer{
+				layer(common.ARGCOMMAND, "VERSION=v1"),
+				layer(common.RUNCOMMAND, "echo $VERSION"),
+				layer(common.ENVCOMMAND, "VERSION=v2"),
+				layer(common.RUNCOMMAND, "echo $VERSION"),
+			},
+			nil,
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo v1"), layer(common.RUNCOMMAND, "echo v2")},
+			map[string]string{"VERSION": "v2"},
+			false,
+		},
+		{
+			"undeclared var is kept",
+			[]v1.Layer{layer(common.ARGCOMMAND, "VERSION=v1"), layer(common.RUNCOMMAND, "echo $HOME ${PATH} $VERSION")},
+			nil,
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo $HOME ${PATH} v1")},
+			map[string]string{"VERSION": "v1"},
+			false,
+		},
+		{
+			"undeclared build arg is ignored",
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo $VERSION")},
+			map[string]string{"VERSION": "v2"},
+			[]v1.Layer{layer(common.RUNCOMMAND, "echo $VERSION")},
+			map[string]string{},
+			false,
+		},
+		{
+			"invalid arg",
+			[]v1.Layer{layer(common.ARGCOMMAND, "1VERSION=v1")},
+			nil,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"env without value",
+			[]v1.Layer{layer(common.ENVCOMMAND, "VERSION")},
+			nil,
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, vars, err := expandLayers(tt.layers, tt.buildArgs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandLayers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandLayers() layers = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(vars, tt.vars) {
+				t.Errorf("expandLayers() vars = %v, want %v", vars, tt.vars)
+			}
+		})
+	}
+}
@@ -84,11 +84,20 @@ func (c CmdInstruction) Exec(execContext ExecContext) (out Out, err error) {
 
 	// cmd do not contain layer ,so no need to calculate layer
 	if c.rawLayer.Type == common.CMDCOMMAND {
+		// cmd changes no file of the image, the later layers may still hit the cache.
+		hitCache = execContext.ContinueCache
+		chainID = recordCache(execContext, c.rawLayer, "")
 		return out, nil
 	}
 
 	out.LayerID, err = execContext.LayerStore.RegisterLayerForBuilder(c.mounter.GetMountUpper())
-	return out, err
+	if err != nil {
+		return out, err
+	}
+	layer := c.rawLayer
+	layer.ID = out.LayerID
+	chainID = recordCache(execContext, layer, "")
+	return out, nil
 }
 
 func NewCmdInstruction(ctx InstructionContext) (*CmdInstruction, error) {
@@ -100,7 +109,7 @@ func NewCmdInstruction(ctx InstructionContext) (*CmdInstruction, error) {
 
 	return &CmdInstruction{
 		mounter:      *target,
-		layerHandler: buildlayer.ParseLayerContent(ctx.Rootfs, ctx.CurrentLayer),
+		layerHandler: buildlayer.ParseLayerContent(ctx.Rootfs, ctx.CurrentLayer, ctx.Vars),
 		cmdValue:     ctx.CurrentLayer.Value,
 		rawLayer:     *ctx.CurrentLayer,
 	}, nil
@@ -19,6 +19,7 @@ import (
 	"github.com/alibaba/sealer/image"
 	"github.com/alibaba/sealer/image/cache"
 	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/opencontainers/go-digest"
 )
@@ -33,6 +34,8 @@ type ExecContext struct {
 	//static method to do cache
 	CacheSvc cache.Service
 	Prober   image.Prober
+	//record the new layers for later builds
+	ChainStore cache.ChainStore
 	//used to gen layer
 	LayerStore store.LayerStore
 }
@@ -42,6 +45,10 @@ type InstructionContext struct {
 	CurrentLayer *v1.Layer
 	BaseLayers   []v1.Layer
 	Rootfs       string
+	// Stages are the layers of the built stages, keyed by stage name and index.
+	Stages map[string][]v1.Layer
+	// Vars are the ARG and ENV of kubefile.
+	Vars map[string]string
 }
 
 type Out struct {
@@ -62,28 +69,43 @@ func NewInstruction(ic InstructionContext) (Interface, error) {
 	return nil, nil
 }
 
-func NewExecContextWithoutCache(buildType, buildContext string, layerStore store.LayerStore) ExecContext {
-	return ExecContext{
+// NewExecContextWithoutCache never reuses layers, but still records the new layers for later builds.
+func NewExecContextWithoutCache(buildType, buildContext string, baseLayers []v1.Layer, layerStore store.LayerStore) ExecContext {
+	execCtx := ExecContext{
 		LayerStore:   layerStore,
 		BuildContext: buildContext,
 		BuildType:    buildType,
 	}
+	fs, err := store.NewFSStoreBackend()
+	if err != nil {
+		logger.Warn("failed to init store backend, discard cache, err: %s", err)
+		return execCtx
+	}
+	chainStore, err := cache.NewImageStore(fs, layerStore)
+	if err != nil {
+		logger.Warn("failed to init chain store, discard cache, err: %s", err)
+		return execCtx
+	}
+	// the cache chain starts from the base image, different base images share no cache.
+	parentID, err := chainStore.ChainID(baseLayers)
+	if err != nil {
+		logger.Warn("failed to calculate chain id of base layers, discard cache, err: %s", err)
+		return execCtx
+	}
+	execCtx.ChainStore = chainStore
+	execCtx.ParentID = parentID
+	return execCtx
 }
 
-func NewExecContext(buildType, buildContext string, imageService image.Service, layerStore store.LayerStore) ExecContext {
+func NewExecContext(buildType, buildContext string, baseLayers []v1.Layer, imageService image.Service, layerStore store.LayerStore) ExecContext {
+	execCtx := NewExecContextWithoutCache(buildType, buildContext, baseLayers, layerStore)
 	chainSvc, err := cache.NewService()
-	if err != nil {
-		return ExecContext{}
+	if err != nil || execCtx.ChainStore == nil {
+		return execCtx
 	}
 
-	prober := image.NewImageProber(imageService, true)
-	return ExecContext{
-		LayerStore:    layerStore,
-		BuildContext:  buildContext,
-		BuildType:     buildType,
-		CacheSvc:      chainSvc,
-		ParentID:      cache.ChainID(""),
-		Prober:        prober,
-		ContinueCache: true,
-	}
+	execCtx.CacheSvc = chainSvc
+	execCtx.Prober = image.NewImageProber(imageService, false)
+	execCtx.ContinueCache = true
+	return execCtx
 }
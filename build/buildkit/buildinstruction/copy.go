@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image/store"
@@ -32,8 +33,12 @@ import (
 )
 
 type CopyInstruction struct {
-	src          string
-	dest         string
+	src  string
+	dest string
+	// from is the stage or image of COPY --from, fromLayers are its layers.
+	from         string
+	fromLayers   []v1.Layer
+	vars         map[string]string
 	rawLayer     v1.Layer
 	layerHandler buildlayer.LayerHandler
 	fs           store.Backend
@@ -60,14 +65,31 @@ func (c CopyInstruction) Exec(execContext ExecContext) (out Out, err error) {
 		out.ParentID = chainID
 	}()
 
+	srcRoot := execContext.BuildContext
+	if c.from != "" {
+		srcMounter, err := NewMountTarget("", "", GetBaseLayersPath(c.fromLayers))
+		if err != nil {
+			return out, err
+		}
+		if err = srcMounter.TempMount(); err != nil {
+			return out, fmt.Errorf("failed to mount stage %s: %v", c.from, err)
+		}
+		defer srcMounter.CleanUp()
+		srcRoot = srcMounter.GetMountTarget()
+	}
+
 	// specially for copy command, we would generate digest of src file as cacheID.
 	// for every copy, we will hard link (make digest consistent) the copy source files, and generate a digest for those files
 	// and use the cacheID try if it can hit the cache
 
-	cacheID, err = GenerateSourceFilesDigest(filepath.Join(execContext.BuildContext, c.src))
+	cacheID, err = GenerateSourceFilesDigest(filepath.Join(srcRoot, c.src))
 	if err != nil {
 		logger.Warn("failed to generate src digest, discard cache, err: %s", err)
 	}
+	// the rendered manifests change with the vars.
+	if cacheID != "" && c.rendersManifests() {
+		cacheID = digest.FromString(cacheID.String() + varsString(c.vars))
+	}
 
 	if execContext.ContinueCache {
 		hitCache, layerID, chainID = tryCache(execContext.ParentID, c.rawLayer, execContext.CacheSvc, execContext.Prober, cacheID)
@@ -89,10 +111,15 @@ func (c CopyInstruction) Exec(execContext ExecContext) (out Out, err error) {
 		return out, fmt.Errorf("failed to set temp rootfs %s to system $PATH : %v", c.mounter.GetMountTarget(), err)
 	}
 
-	err = c.copyFiles(execContext.BuildContext, c.src, c.dest, c.mounter.GetMountTarget())
+	err = c.copyFiles(srcRoot, c.src, c.dest, c.mounter.GetMountTarget())
 	if err != nil {
 		return out, fmt.Errorf("failed to copy files to temp dir %s, err: %v", c.mounter.GetMountTarget(), err)
 	}
+	if c.rendersManifests() {
+		if err = renderManifests(filepath.Join(c.mounter.GetMountUpper(), buildlayer.IsCopyToManifests), c.vars); err != nil {
+			return out, fmt.Errorf("failed to render manifests, err: %v", err)
+		}
+	}
 	// if we come here, its new layer need set cacheid .
 	layerID, err = execContext.LayerStore.RegisterLayerForBuilder(c.mounter.GetMountUpper())
 	if err != nil {
@@ -101,6 +128,9 @@ func (c CopyInstruction) Exec(execContext ExecContext) (out Out, err error) {
 	if setErr := c.SetCacheID(layerID, cacheID.String()); setErr != nil {
 		logger.Warn("set cache failed layer: %v, err: %v", c.rawLayer, err)
 	}
+	layer := c.rawLayer
+	layer.ID = layerID
+	chainID = recordCache(execContext, layer, cacheID)
 
 	out.LayerID = layerID
 	return out, nil
@@ -117,6 +147,12 @@ func (c CopyInstruction) copyFiles(buildContext, rawSrcFileName, rawDstFileName,
 	return fsutil.Copy(context.TODO(), buildContext, rawSrcFileName, dstRoot, filepath.Base(rawSrcFileName))
 }
 
+// rendersManifests returns true if the ARG and ENV are substituted into the files copied to manifests.
+func (c CopyInstruction) rendersManifests() bool {
+	dest := strings.TrimPrefix(c.dest, "./")
+	return len(c.vars) > 0 && strings.HasPrefix(strings.TrimPrefix(dest, "/"), buildlayer.IsCopyToManifests)
+}
+
 // SetCacheID This function only has meaning for copy layers
 func (c CopyInstruction) SetCacheID(layerID digest.Digest, cID string) error {
 	return c.fs.SetMetadata(layerID, common.CacheID, []byte(cID))
@@ -133,12 +169,25 @@ func NewCopyInstruction(ctx InstructionContext) (*CopyInstruction, error) {
 		return nil, err
 	}
 	src, dest := buildlayer.ParseCopyLayerContent(ctx.CurrentLayer.Value)
+	from, _ := buildlayer.ParseCopyFrom(ctx.CurrentLayer.Value)
+	fromLayers, ok := ctx.Stages[from]
+	if from != "" && !ok {
+		return nil, fmt.Errorf("failed to find stage or image %s of COPY --from", from)
+	}
+
+	var layerHandler buildlayer.LayerHandler
+	if ctx.Rootfs != "" {
+		layerHandler = buildlayer.ParseLayerContent(ctx.Rootfs, ctx.CurrentLayer, ctx.Vars)
+	}
 	return &CopyInstruction{
 		fs:           fs,
 		mounter:      *target,
-		layerHandler: buildlayer.ParseLayerContent(ctx.Rootfs, ctx.CurrentLayer),
+		layerHandler: layerHandler,
 		rawLayer:     *ctx.CurrentLayer,
 		src:          src,
 		dest:         dest,
+		from:         from,
+		fromLayers:   fromLayers,
+		vars:         ctx.Vars,
 	}, nil
 }
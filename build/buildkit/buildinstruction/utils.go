@@ -16,15 +16,20 @@ package buildinstruction
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/alibaba/sealer/build/buildkit/buildlayer"
 	"github.com/alibaba/sealer/image"
 	"github.com/alibaba/sealer/image/cache"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
 	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
 	"github.com/alibaba/sealer/utils/archive"
 	"github.com/opencontainers/go-digest"
 )
@@ -48,10 +53,32 @@ func tryCache(parentID cache.ChainID,
 	if err != nil {
 		return false, "", ""
 	}
-	fmt.Println("chain id ", cID)
+	logger.Debug("chain id %s", cID)
 	return true, cacheLayerID, cID
 }
 
+// recordCache records the layer built on the parent chain of execContext,
+// returns the chain id of the layer which is the parent of the next layer.
+func recordCache(execContext ExecContext, layer v1.Layer, srcFilesDgst digest.Digest) cache.ChainID {
+	if execContext.ChainStore == nil {
+		return ""
+	}
+	svc, err := cache.NewService()
+	if err != nil {
+		return ""
+	}
+	cacheLayer := svc.NewCacheLayer(layer, srcFilesDgst)
+	chainID, err := cacheLayer.ChainID(execContext.ParentID)
+	if err != nil {
+		logger.Warn("failed to calculate chain id for %+v, err: %s", layer, err)
+		return ""
+	}
+	if err = execContext.ChainStore.AddChainLayer(chainID, layer); err != nil {
+		logger.Warn("failed to record build cache for %+v, err: %s", layer, err)
+	}
+	return chainID
+}
+
 func paresCopyDestPath(rawDstFileName, tempBuildDir string) string {
 	// pares copy dest,default workdir is rootfs
 	//copy . . = $rootfs
@@ -89,3 +116,36 @@ func GetBaseLayersPath(layers []v1.Layer) (res []string) {
 	}
 	return res
 }
+
+// renderManifests substitutes ${KEY} of the ARG and ENV in the yaml files under dir.
+func renderManifests(dir string, vars map[string]string) error {
+	if !utils.IsExist(dir) {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !utils.YamlMatcher(info.Name()) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		rendered := buildlayer.ExpandBracedVars(string(data), vars)
+		if rendered == string(data) {
+			return nil
+		}
+		return ioutil.WriteFile(path, []byte(rendered), info.Mode())
+	})
+}
+
+func varsString(vars map[string]string) string {
+	var kv []string
+	for k, v := range vars {
+		kv = append(kv, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(kv)
+	return strings.Join(kv, "\n")
+}
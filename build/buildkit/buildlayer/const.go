@@ -24,4 +24,9 @@ const (
 	ChartHandler        = "chart"
 	YamlHandler         = "yaml"
 	OfflineImageHandler = "offlineImage"
+	// ManifestsHandler handles the dir copied to manifests.
+	ManifestsHandler = "manifests"
+
+	// CopyFromFlag copies files from a previous build stage or an image: COPY --from=builder /out bin
+	CopyFromFlag = "--from="
 )
@@ -18,11 +18,9 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/alibaba/sealer/build/buildkit/analyzer"
 	"github.com/alibaba/sealer/build/buildkit/buildlayer/imagepuller"
-
-	"github.com/alibaba/sealer/build/buildkit/buildlayer/layerutils"
-	"github.com/alibaba/sealer/build/buildkit/buildlayer/layerutils/charts"
-	manifest "github.com/alibaba/sealer/build/buildkit/buildlayer/layerutils/manifests"
+	"github.com/alibaba/sealer/logger"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils"
 )
@@ -30,7 +28,7 @@ import (
 // layer handler : implement some ops form the content of each layer
 // instruction handler:  implement the execution of instructions
 
-// 1,copy imageList manifests : read the images and fetch them.
+// 1,copy imageList, yaml or charts : find the images by analyzers and fetch them.
 // 2,copy nginx.tar registry: load the offline images and push to registry.
 // 3,some cmd or run instruction need to do something from the layer content.
 
@@ -38,112 +36,44 @@ type CopyLayer struct {
 	Src    string
 	Dest   string
 	Rootfs string
+	// Vars are the ARG and ENV of kubefile, substituted into the image names.
+	Vars map[string]string
 }
 
-type HandleImageList struct {
+// HandleAnalyzer pulls the images found by the build analyzers in the copied files,
+// the images matching the exclude list of build context are skipped.
+type HandleAnalyzer struct {
 	puller imagepuller.Processor
+	src    string
+	vars   map[string]string
 }
 
-func (h HandleImageList) LayerValueHandler(buildContext string, layer v1.Layer) error {
-	imageListFilePath := filepath.Join(buildContext, "imageList")
-	images, err := h.parseRawImageList(imageListFilePath)
-	if err != nil {
-		return err
-	}
-
-	if len(images) == 0 {
-		return nil
-	}
-	// do pull
-	return h.puller.Pull(images)
-}
-
-func (h HandleImageList) parseRawImageList(imageListFilePath string) ([]string, error) {
-	if !utils.IsExist(imageListFilePath) {
-		return nil, fmt.Errorf("file imageList is not exist")
+func (h HandleAnalyzer) LayerValueHandler(buildContext string, layer v1.Layer) error {
+	srcPath := filepath.Join(buildContext, h.src)
+	if !utils.IsExist(srcPath) {
+		return fmt.Errorf("file %s is not exist", h.src)
 	}
-
-	images, err := utils.ReadLines(imageListFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file content %s:%v", imageListFilePath, err)
-	}
-	return FormatImages(images), nil
-}
-
-type HandleYamlImageList struct {
-	YamlHandler layerutils.Interface
-	puller      imagepuller.Processor
-	src         string
-}
-
-func (h HandleYamlImageList) LayerValueHandler(buildContext string, layer v1.Layer) error {
-	yamlFilePath := filepath.Join(buildContext, h.src)
-	images, err := h.parseYamlImages(yamlFilePath)
+	images, err := analyzer.Scan(srcPath)
 	if err != nil {
 		return err
 	}
-	if len(images) == 0 {
-		return nil
-	}
-	return h.puller.Pull(images)
-}
-
-func (h HandleYamlImageList) parseYamlImages(yamlFilePath string) ([]string, error) {
-	yamlImages, err := h.YamlHandler.ListImages(yamlFilePath)
-
-	if err != nil {
-		return nil, err
-	}
-	return FormatImages(yamlImages), nil
-}
 
-type HandleChartImageList struct {
-	ChartHandler layerutils.Interface
-	puller       imagepuller.Processor
-	src          string
-}
-
-func (h HandleChartImageList) LayerValueHandler(buildContext string, layer v1.Layer) error {
-	chartFilePath := filepath.Join(buildContext, h.src)
-	images, err := h.parseChartImages(chartFilePath)
+	excludes, err := analyzer.LoadExcludes(filepath.Join(buildContext, analyzer.ExcludeListFile))
 	if err != nil {
 		return err
 	}
+	images = analyzer.Filter(FormatImages(expandImages(images, h.vars)), excludes)
 	if len(images) == 0 {
 		return nil
 	}
+	logger.Info("pull images found in %s: %v", h.src, images)
 	return h.puller.Pull(images)
 }
 
-func (h HandleChartImageList) parseChartImages(chartFilePath string) ([]string, error) {
-	chartImages, err := h.ChartHandler.ListImages(chartFilePath)
-
-	if err != nil {
-		return nil, err
-	}
-	return FormatImages(chartImages), nil
-}
-
-func NewYamlHandler(lc CopyLayer) *HandleYamlImageList {
-	m, _ := manifest.NewManifests()
-	return &HandleYamlImageList{
-		puller:      imagepuller.NewPuller(lc.Rootfs),
-		YamlHandler: m,
-		src:         lc.Src,
-	}
-}
-
-func NewChartHandler(lc CopyLayer) *HandleChartImageList {
-	c, _ := charts.NewCharts()
-	return &HandleChartImageList{
-		puller:       imagepuller.NewPuller(lc.Rootfs),
-		src:          lc.Src,
-		ChartHandler: c,
-	}
-}
-
-func NewImageListHandler(lc CopyLayer) *HandleImageList {
-	return &HandleImageList{
+func NewAnalyzerHandler(lc CopyLayer) *HandleAnalyzer {
+	return &HandleAnalyzer{
 		puller: imagepuller.NewPuller(lc.Rootfs),
+		src:    lc.Src,
+		vars:   lc.Vars,
 	}
 }
@@ -20,10 +20,14 @@ import (
 )
 
 // ParseLayerContent :init different layer handler to exchanging due to the layer content
-func ParseLayerContent(rootfs string, layer *v1.Layer) LayerHandler {
+func ParseLayerContent(rootfs string, layer *v1.Layer, vars map[string]string) LayerHandler {
 	if layer.Type != common.COPYCOMMAND {
 		return nil
 	}
+	// the files copied from other stages are taken as they are.
+	if from, _ := ParseCopyFrom(layer.Value); from != "" {
+		return nil
+	}
 	src, dest := ParseCopyLayerContent(layer.Value)
 	// parse copy attr
 	ht := GetCopyLayerHandlerType(src, dest)
@@ -35,16 +39,13 @@ func ParseLayerContent(rootfs string, layer *v1.Layer) LayerHandler {
 		Src:    src,
 		Dest:   dest,
 		Rootfs: rootfs,
+		Vars:   vars,
 	}
 
 	switch ht {
-	// imageList;yaml,chart
-	case ImageListHandler:
-		return NewImageListHandler(cl)
-	case YamlHandler:
-		return NewYamlHandler(cl)
-	case ChartHandler:
-		return NewChartHandler(cl)
+	// imageList;yaml,chart,manifests dir
+	case ImageListHandler, YamlHandler, ChartHandler, ManifestsHandler:
+		return NewAnalyzerHandler(cl)
 	}
 	return nil
 }
@@ -63,12 +63,14 @@ func GetCopyLayerHandlerType(src, dest string) string {
 		if utils.YamlMatcher(src) {
 			return YamlHandler
 		}
+		return ManifestsHandler
 	}
 
 	return ""
 }
 
 func ParseCopyLayerContent(layerValue string) (src, dst string) {
+	_, layerValue = ParseCopyFrom(layerValue)
 	dst = strings.Fields(layerValue)[1]
 	for _, p := range []string{"./", "/"} {
 		dst = strings.TrimPrefix(dst, p)
@@ -76,3 +78,18 @@ func ParseCopyLayerContent(layerValue string) (src, dst string) {
 	src = strings.Fields(layerValue)[0]
 	return
 }
+
+// ParseCopyFrom splits the --from flag of copy layer: "--from=builder /out bin"
+// returns "builder" and "/out bin", from is empty if the layer copies from build context.
+func ParseCopyFrom(layerValue string) (from, rest string) {
+	layerValue = strings.TrimSpace(layerValue)
+	if !strings.HasPrefix(layerValue, CopyFromFlag) {
+		return "", layerValue
+	}
+	ss := strings.SplitN(layerValue, " ", 2)
+	from = strings.TrimPrefix(ss[0], CopyFromFlag)
+	if len(ss) == 2 {
+		rest = strings.TrimSpace(ss[1])
+	}
+	return from, rest
+}
@@ -0,0 +1,60 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildlayer
+
+import (
+	"regexp"
+)
+
+var (
+	varPattern       = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+	bracedVarPattern = regexp.MustCompile(`\$\{(\w+)\}`)
+)
+
+// ExpandVars substitutes $KEY and ${KEY} in s with the ARG and ENV of kubefile,
+// the undeclared ones are kept, so that the shell variables of RUN still work.
+func ExpandVars(s string, vars map[string]string) string {
+	return expand(varPattern, s, vars)
+}
+
+// ExpandBracedVars only substitutes ${KEY}, it is used for the files like manifests.
+func ExpandBracedVars(s string, vars map[string]string) string {
+	return expand(bracedVarPattern, s, vars)
+}
+
+func expand(pattern *regexp.Regexp, s string, vars map[string]string) string {
+	if len(vars) == 0 {
+		return s
+	}
+	return pattern.ReplaceAllStringFunc(s, func(match string) string {
+		sub := pattern.FindStringSubmatch(match)
+		key := sub[1]
+		if key == "" && len(sub) > 2 {
+			key = sub[2]
+		}
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+func expandImages(images []string, vars map[string]string) []string {
+	var res []string
+	for _, image := range images {
+		res = append(res, ExpandVars(image, vars))
+	}
+	return res
+}
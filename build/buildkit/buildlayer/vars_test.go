@@ -0,0 +1,61 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildlayer
+
+import "testing"
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"VERSION": "v1.19.8", "REGISTRY": "sea.hub:5000", "EMPTY": ""}
+	tests := []struct {
+		name string
+		s    string
+		vars map[string]string
+		want string
+	}{
+		{"plain", "echo $VERSION", vars, "echo v1.19.8"},
+		{"braced", "${REGISTRY}/pause:3.2", vars, "sea.hub:5000/pause:3.2"},
+		{"braced before word", "${VERSION}_amd64", vars, "v1.19.8_amd64"},
+		{"plain takes the whole word", "$VERSION_amd64", vars, "$VERSION_amd64"},
+		{"empty value", "a${EMPTY}b", vars, "ab"},
+		{"undeclared kept", "echo $HOME ${PATH}", vars, "echo $HOME ${PATH}"},
+		{"quoted", `echo "$VERSION" '${VERSION}'`, vars, `echo "v1.19.8" 'v1.19.8'`},
+		{"no vars", "echo $VERSION", nil, "echo $VERSION"},
+		{"dollar only", "echo $ and ${}", vars, "echo $ and ${}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpandVars(tt.s, tt.vars); got != tt.want {
+				t.Errorf("ExpandVars(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandBracedVars(t *testing.T) {
+	vars := map[string]string{"VERSION": "v1.19.8"}
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"image: app:${VERSION}", "image: app:v1.19.8"},
+		{"image: app:$VERSION", "image: app:$VERSION"},
+		{"image: app:${TAG}", "image: app:${TAG}"},
+	}
+	for _, tt := range tests {
+		if got := ExpandBracedVars(tt.s, vars); got != tt.want {
+			t.Errorf("ExpandBracedVars(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -24,6 +24,8 @@ import (
 
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/alibaba/sealer/build/buildkit"
 
@@ -42,6 +44,7 @@ type Builder struct {
 	BuildType          string
 	NoCache            bool
 	NoBase             bool
+	BuildArgs          map[string]string
 	Provider           string
 	TmpClusterFilePath string
 	ImageNamed         reference.Named
@@ -72,7 +75,7 @@ func (c *Builder) Build(name string, context string, kubefileName string) error
 	}
 	c.Context = absContext
 
-	image, err := buildimage.InitImageSpec(absKubeFile)
+	image, err := buildimage.InitImageSpec(absKubeFile, c.BuildArgs)
 	if err != nil {
 		return err
 	}
@@ -154,6 +157,9 @@ func (c *Builder) ApplyInfra() (err error) {
 	if err := infraManager.Apply(); err != nil {
 		return fmt.Errorf("failed to apply infra :%v", err)
 	}
+	if err := c.waitBuildHostReady(infraManager); err != nil {
+		return err
+	}
 
 	c.Cluster.Spec.Provider = common.BAREMETAL
 	if err := utils.MarshalYamlToFile(c.TmpClusterFilePath, c.Cluster); err != nil {
@@ -163,6 +169,22 @@ func (c *Builder) ApplyInfra() (err error) {
 	return c.initBuildSSH()
 }
 
+// waitBuildHostReady waits for the instance of the build host running and booted, it is master0 reached by the EIP
+// for ali cloud.
+func (c *Builder) waitBuildHostReady(provider infra.Interface) error {
+	host := c.Cluster.Spec.Masters.IPList[0]
+	if c.Provider == common.AliCloud {
+		host = c.Cluster.GetAnnotationsByKey(common.Eip)
+		if host == "" {
+			return fmt.Errorf("get cluster EIP failed")
+		}
+	}
+	if err := infra.WaitHostsReady(provider, ssh.NewSSHByCluster(c.Cluster), host); err != nil {
+		return fmt.Errorf("build host is not ready: %v", err)
+	}
+	return nil
+}
+
 func (c *Builder) initBuildSSH() error {
 	// init ssh client
 	c.Cluster.Spec.Provider = c.Provider
@@ -189,7 +211,7 @@ func (c *Builder) SendBuildContext() error {
 // RemoteLocalBuild run sealer build remotely
 func (c *Builder) RemoteLocalBuild() (err error) {
 	// apply k8s cluster first
-	apply := fmt.Sprintf("%s apply -f %s", common.RemoteSealerPath, c.TmpClusterFilePath)
+	apply := fmt.Sprintf("%s apply -f %s", RemoteSealer, c.TmpClusterFilePath)
 	err = c.SSH.CmdAsync(c.RemoteHostIP, apply)
 	if err != nil {
 		return fmt.Errorf("failed to run remote apply:%v", err)
@@ -200,7 +222,7 @@ func (c *Builder) RemoteLocalBuild() (err error) {
 func (c *Builder) runBuildCommands() (err error) {
 	// run local build command
 	workdir := fmt.Sprintf(common.DefaultWorkDir, c.Cluster.Name)
-	build := fmt.Sprintf(common.BuildClusterCmd, common.RemoteSealerPath,
+	build := fmt.Sprintf(common.BuildClusterCmd, RemoteSealer,
 		filepath.Base(c.KubeFileName), c.ImageNamed.Raw(), common.LocalBuild, ".")
 	if c.NoBase {
 		build = fmt.Sprintf("%s %s", build, "--base=false")
@@ -208,9 +230,17 @@ func (c *Builder) runBuildCommands() (err error) {
 	if c.NoCache {
 		build = fmt.Sprintf("%s %s", build, "--no-cache=true")
 	}
+	var args []string
+	for k, v := range c.BuildArgs {
+		args = append(args, fmt.Sprintf("--build-arg '%s'", strings.ReplaceAll(k+"="+v, "'", `'\''`)))
+	}
+	sort.Strings(args)
+	if len(args) > 0 {
+		build = fmt.Sprintf("%s %s", build, strings.Join(args, " "))
+	}
 
 	if c.Provider == common.AliCloud {
-		push := fmt.Sprintf(common.PushImageCmd, common.RemoteSealerPath,
+		push := fmt.Sprintf(common.PushImageCmd, RemoteSealer,
 			c.ImageNamed.Raw())
 		build = fmt.Sprintf("%s && %s", build, push)
 	}
@@ -20,18 +20,52 @@ import (
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
-	"github.com/alibaba/sealer/runtime"
 	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
 )
 
+const (
+	// RemoteSealer is the sealer on the build host, which runs the apply and build there. If there is none, the one
+	// built for the platform of the build host is sent by sendSealer, instead of the running one here.
+	RemoteSealer      = "sealer"
+	RemoteCheckSealer = "command -v " + RemoteSealer
+)
+
+// prepareBuildHost makes sure there is sealer on the build host, and sends the cluster file and the registry login info to it.
+func (c *Builder) prepareBuildHost() error {
+	err := ssh.WaitSSHReady(c.SSH, 6, c.RemoteHostIP)
+	if err != nil {
+		return fmt.Errorf("build host is not ready: %v", err)
+	}
+	if _, err = c.SSH.Cmd(c.RemoteHostIP, RemoteCheckSealer); err != nil {
+		if err = c.sendSealer(); err != nil {
+			return err
+		}
+	}
+
+	err = c.SSH.Copy(c.RemoteHostIP, c.TmpClusterFilePath, c.TmpClusterFilePath)
+	if err != nil {
+		return fmt.Errorf("send cluster file to remote host %s failed:%v", c.RemoteHostIP, err)
+	}
+	logger.Info("send cluster file to %s success !", c.RemoteHostIP)
+
+	authFile := common.DefaultRegistryAuthConfigDir()
+	if !utils.IsFileExist(authFile) {
+		logger.Warn("failed to find %s, if image registry is private, please login first", authFile)
+		return nil
+	}
+	err = c.SSH.Copy(c.RemoteHostIP, authFile, common.DefaultRegistryAuthDir)
+	if err != nil {
+		return fmt.Errorf("failed to send register config %s to remote host %s err: %v", authFile, c.RemoteHostIP, err)
+	}
+	logger.Info("send register info to %s success !", c.RemoteHostIP)
+	return nil
+}
+
 //sendBuildContext:send local build context to remote server
 func (c *Builder) sendBuildContext() (err error) {
-	// if remote cluster already exist,no need to pre init master0
-	if !c.SSH.IsFileExist(c.RemoteHostIP, common.RemoteSealerPath) {
-		err = runtime.PreInitMaster0(c.SSH, c.RemoteHostIP)
-		if err != nil {
-			return fmt.Errorf("failed to prepare cluster env %v", err)
-		}
+	if err = c.prepareBuildHost(); err != nil {
+		return fmt.Errorf("failed to prepare cluster env %v", err)
 	}
 	tarFileName := fmt.Sprintf(common.TmpTarFile, utils.GenUniqueID(32))
 	err = tarBuildContext(c.KubeFileName, c.Context, tarFileName)
@@ -0,0 +1,123 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// RemoteSealerInstallPath is where the sealer for the build host is installed, if there is none in its PATH.
+	RemoteSealerInstallPath = "/usr/local/bin/" + RemoteSealer
+	RemoteInstallSealer     = "chmod +x %[1]s && mv -f %[1]s %[2]s"
+	RemoteGetMachine        = "uname -m"
+	// DigestSuffix is the suffix of the file next to a sealer binary with its sha256, like the output of sha256sum.
+	DigestSuffix = ".sha256"
+)
+
+// SealerBinaryName returns the name of the sealer binary built for p, like sealer-linux-arm64 or sealer-linux-arm-v7,
+// which are bundled next to the running sealer by the multi-arch release.
+func SealerBinaryName(p v1.Platform) string {
+	name := fmt.Sprintf("%s-%s-%s", common.ExecBinaryFileName, p.OS, p.Architecture)
+	if p.Variant != "" {
+		name += "-" + p.Variant
+	}
+	return name
+}
+
+// LookupSealerBinary returns the sealer binary in dir built for platform p: the one named by SealerBinaryName,
+// or the running one named sealer if it is built for p. The digest of the binary is verified if it has one.
+func LookupSealerBinary(dir string, self, p v1.Platform) (string, error) {
+	candidates := []string{filepath.Join(dir, SealerBinaryName(p))}
+	if platform.Match(self, p) {
+		candidates = append(candidates, filepath.Join(dir, common.ExecBinaryFileName))
+	}
+	for _, bin := range candidates {
+		if !utils.IsFileExist(bin) {
+			continue
+		}
+		if err := verifyDigest(bin); err != nil {
+			return "", err
+		}
+		return bin, nil
+	}
+	return "", fmt.Errorf("no sealer built for %s is found, put %s in %s", platform.Format(p), SealerBinaryName(p), dir)
+}
+
+// verifyDigest checks the sha256 of bin with the one in its digest file, it does nothing if there is no digest file.
+func verifyDigest(bin string) error {
+	data, err := ioutil.ReadFile(filepath.Clean(bin + DigestSuffix))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("digest file %s is empty", bin+DigestSuffix)
+	}
+	f, err := os.Open(filepath.Clean(bin))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != strings.ToLower(fields[0]) {
+		return fmt.Errorf("sha256 of %s is %s, but %s is in %s", bin, got, fields[0], bin+DigestSuffix)
+	}
+	return nil
+}
+
+// sendSealer installs the sealer built for the platform of the build host on it.
+func (c *Builder) sendSealer() error {
+	machine, err := c.SSH.CmdToString(c.RemoteHostIP, RemoteGetMachine, "")
+	if err != nil {
+		return fmt.Errorf("failed to get the machine hardware of build host %s: %v", c.RemoteHostIP, err)
+	}
+	p, err := platform.FromMachine(machine)
+	if err != nil {
+		return fmt.Errorf("build host %s: %v", c.RemoteHostIP, err)
+	}
+	bin, err := LookupSealerBinary(filepath.Dir(utils.ExecutableFilePath()), platform.Default(), p)
+	if err != nil {
+		return err
+	}
+	tmp := RemoteSealerInstallPath + ".tmp"
+	if err = c.SSH.Copy(c.RemoteHostIP, bin, tmp); err != nil {
+		return fmt.Errorf("failed to send %s to build host %s: %v", bin, c.RemoteHostIP, err)
+	}
+	if err = c.SSH.CmdAsync(c.RemoteHostIP, fmt.Sprintf(RemoteInstallSealer, tmp, RemoteSealerInstallPath)); err != nil {
+		return err
+	}
+	logger.Info("send %s built for %s to %s success !", filepath.Base(bin), platform.Format(p), c.RemoteHostIP)
+	return nil
+}
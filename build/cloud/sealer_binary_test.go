@@ -0,0 +1,69 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestLookupSealerBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string]string{
+		"sealer":                     "amd64",
+		"sealer-linux-arm64":         "arm64",
+		"sealer-linux-arm-v7":        "arm",
+		"sealer-linux-arm64.sha256":  "f69162950f235e3cdbbad33f1f912d1a504be90d8a37d002c735d6f3e3882265  sealer-linux-arm64\n",
+		"sealer-linux-arm-v7.sha256": "0000000000000000000000000000000000000000000000000000000000000000  sealer-linux-arm-v7\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	amd64 := v1.Platform{OS: "linux", Architecture: "amd64"}
+	arm64 := v1.Platform{OS: "linux", Architecture: "arm64"}
+
+	tests := []struct {
+		name    string
+		self, p v1.Platform
+		want    string
+		wantErr bool
+	}{
+		{"running one", amd64, amd64, "sealer", false},
+		{"bundled one with digest", amd64, arm64, "sealer-linux-arm64", false},
+		{"running one of another os", v1.Platform{OS: "darwin", Architecture: "amd64"}, amd64, "", true},
+		{"digest mismatch", amd64, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, "", true},
+		{"not found", arm64, v1.Platform{OS: "linux", Architecture: "s390x"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LookupSealerBinary(dir, tt.self, tt.p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LookupSealerBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != filepath.Join(dir, tt.want) {
+				t.Errorf("LookupSealerBinary() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -14,9 +14,16 @@
 
 package build
 
+import v1 "github.com/alibaba/sealer/types/api/v1"
+
 type Config struct {
 	BuildType string
 	NoCache   bool
 	NoBase    bool
 	ImageName string
+	// BuildArgs override the ARG of kubefile.
+	BuildArgs map[string]string
+	// Platforms are the target platforms, an image is built for each platform
+	// and they are referenced by a multi-platform image if more than one given.
+	Platforms []v1.Platform
 }
@@ -19,12 +19,15 @@ import (
 	"github.com/alibaba/sealer/build/buildkit/buildimage"
 	"github.com/alibaba/sealer/image/reference"
 	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
 )
 
 type Builder struct {
 	BuildType    string
 	NoCache      bool
 	NoBase       bool
+	BuildArgs    map[string]string
+	Platform     v1.Platform
 	ImageNamed   reference.Named
 	Context      string
 	KubeFileName string
@@ -50,7 +53,7 @@ func (l *Builder) Build(name string, context string, kubefileName string) error
 	}
 	l.Context = absContext
 
-	bi, err := buildimage.NewBuildImage(absKubeFile)
+	bi, err := buildimage.NewBuildImage(absKubeFile, l.BuildArgs, l.Platform)
 	if err != nil {
 		return err
 	}
@@ -23,6 +23,7 @@ import (
 	"github.com/alibaba/sealer/client/k8s"
 	"github.com/alibaba/sealer/image/reference"
 	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils"
 )
 
@@ -31,6 +32,7 @@ type Builder struct {
 	BuildType    string
 	NoCache      bool
 	NoBase       bool
+	BuildArgs    map[string]string
 	ImageNamed   reference.Named
 	Context      string
 	KubeFileName string
@@ -73,7 +75,7 @@ func (l *Builder) InitBuilder(name string, context string, kubefileName string)
 		return err
 	}
 
-	bi, err := buildimage.NewBuildImage(absKubeFile)
+	bi, err := buildimage.NewBuildImage(absKubeFile, l.BuildArgs, v1.Platform{})
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,93 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"fmt"
+
+	"github.com/alibaba/sealer/build/lite"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// PlatformBuilder builds an image for each platform, and a multi-platform
+// image which references them is saved with the given name.
+type PlatformBuilder struct {
+	Config    *Config
+	Platforms []v1.Platform
+}
+
+func (p *PlatformBuilder) Build(name string, context string, kubefileName string) error {
+	named, err := reference.ParseToNamed(name)
+	if err != nil {
+		return err
+	}
+
+	if len(p.Platforms) == 1 {
+		return p.newBuilder(p.Platforms[0]).Build(named.Raw(), context, kubefileName)
+	}
+
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return err
+	}
+
+	var manifests []v1.ImageManifest
+	for _, pf := range p.Platforms {
+		platformName, err := platform.ImageName(named.Raw(), pf)
+		if err != nil {
+			return err
+		}
+		logger.Info("start to build image %s for platform %s", platformName, platform.Format(pf))
+		if err = p.newBuilder(pf).Build(platformName, context, kubefileName); err != nil {
+			return fmt.Errorf("failed to build image for platform %s: %v", platform.Format(pf), err)
+		}
+		img, err := imageStore.GetByName(platformName)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, v1.ImageManifest{Platform: pf, Name: platformName, ID: img.Spec.ID})
+	}
+
+	index, err := platform.NewIndexImage(named.Raw(), manifests)
+	if err != nil {
+		return err
+	}
+	if err = imageStore.Save(index, named.Raw()); err != nil {
+		return fmt.Errorf("failed to save multi-platform image %s: %v", named.Raw(), err)
+	}
+	logger.Info("save multi-platform image %s to image system success !", named.Raw())
+	return nil
+}
+
+func (p *PlatformBuilder) newBuilder(pf v1.Platform) Interface {
+	return &lite.Builder{
+		BuildType: p.Config.BuildType,
+		NoCache:   p.Config.NoCache,
+		NoBase:    p.Config.NoBase,
+		BuildArgs: p.Config.BuildArgs,
+		Platform:  pf,
+	}
+}
+
+func NewPlatformBuilder(config *Config) (Interface, error) {
+	return &PlatformBuilder{
+		Config:    config,
+		Platforms: config.Platforms,
+	}, nil
+}
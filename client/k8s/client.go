@@ -21,6 +21,7 @@ import (
 	"github.com/alibaba/sealer/common"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
@@ -87,6 +88,39 @@ func (c *Client) DeleteNode(name string) error {
 	return nil
 }
 
+// GetConfigMap returns the ConfigMap name in namespace, nil if it does not exist.
+func (c *Client) GetConfigMap(namespace, name string) (*v1.ConfigMap, error) {
+	cm, err := c.client.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get configmap %s/%s", namespace, name)
+	}
+	return cm, nil
+}
+
+// ApplyConfigMap creates cm, or updates it if it exists.
+func (c *Client) ApplyConfigMap(cm *v1.ConfigMap) error {
+	_, err := c.client.CoreV1().ConfigMaps(cm.Namespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = c.client.CoreV1().ConfigMaps(cm.Namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to apply configmap %s/%s", cm.Namespace, cm.Name)
+	}
+	return nil
+}
+
+// DeleteConfigMap deletes the ConfigMap name in namespace, it is not an error if it does not exist.
+func (c *Client) DeleteConfigMap(namespace, name string) error {
+	err := c.client.CoreV1().ConfigMaps(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete configmap %s/%s", namespace, name)
+	}
+	return nil
+}
+
 func (c *Client) listNamespaces() (*v1.NamespaceList, error) {
 	namespaceList, err := c.client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
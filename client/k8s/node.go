@@ -0,0 +1,225 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// GetNodeByIP returns the node whose internal ip is the given ip.
+func (c *Client) GetNodeByIP(ip string) (*v1.Node, error) {
+	nodes, err := c.ListNodes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes.Items {
+		for _, addr := range nodes.Items[i].Status.Addresses {
+			if addr.Type == v1.NodeInternalIP && addr.Address == ip {
+				return &nodes.Items[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("failed to find node with ip %s", ip)
+}
+
+// ParseTaint parses the taint in the form of key[=value]:effect, like dedicated=ingress:NoSchedule.
+func ParseTaint(s string) (v1.Taint, error) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return v1.Taint{}, fmt.Errorf("invalid taint %s, must be key[=value]:effect", s)
+	}
+	taint := v1.Taint{Effect: v1.TaintEffect(s[i+1:])}
+	switch taint.Effect {
+	case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+	default:
+		return v1.Taint{}, fmt.Errorf("invalid taint %s, effect must be one of %s, %s and %s", s,
+			v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute)
+	}
+	kv := strings.SplitN(s[:i], "=", 2)
+	taint.Key = kv[0]
+	if len(kv) == 2 {
+		taint.Value = kv[1]
+	}
+	return taint, nil
+}
+
+// parseTaintChange parses the taint to set, or the one to remove ending with -, like kubectl taint:
+// node-role.kubernetes.io/master:NoSchedule-.
+func parseTaintChange(s string) (taint v1.Taint, remove bool, err error) {
+	if strings.HasSuffix(s, "-") {
+		s, remove = strings.TrimSuffix(s, "-"), true
+	}
+	taint, err = ParseTaint(s)
+	return taint, remove, err
+}
+
+// ReconcileNodeLabelsAndTaints replaces oldLabels and oldTaints of the node with ip by newLabels and newTaints,
+// the labels and taints not in the old ones, like those set by kubernetes or users, are kept.
+func (c *Client) ReconcileNodeLabelsAndTaints(ip string, oldLabels, newLabels map[string]string, oldTaints, newTaints []string) error {
+	node, err := c.GetNodeByIP(ip)
+	if err != nil {
+		return err
+	}
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	for k := range oldLabels {
+		delete(node.Labels, k)
+	}
+	for k, v := range newLabels {
+		node.Labels[k] = v
+	}
+	taints, err := reconcileTaints(node.Spec.Taints, oldTaints, newTaints)
+	if err != nil {
+		return err
+	}
+	node.Spec.Taints = taints
+	if _, err := c.UpdateNode(node); err != nil {
+		return errors.Wrapf(err, "failed to update labels and taints of node %s", node.Name)
+	}
+	return nil
+}
+
+// reconcileTaints removes oldTaints from taints and sets newTaints, the ones of newTaints ending with - are removed
+// instead, no matter who set them.
+func reconcileTaints(taints []v1.Taint, oldTaints, newTaints []string) ([]v1.Taint, error) {
+	remove := map[string]bool{}
+	for _, t := range oldTaints {
+		taint, removal, err := parseTaintChange(t)
+		if err != nil {
+			return nil, err
+		}
+		// a removal applied before has nothing to restore.
+		if !removal {
+			remove[taint.Key+":"+string(taint.Effect)] = true
+		}
+	}
+	var add []v1.Taint
+	for _, t := range newTaints {
+		taint, removal, err := parseTaintChange(t)
+		if err != nil {
+			return nil, err
+		}
+		if removal {
+			remove[taint.Key+":"+string(taint.Effect)] = true
+		} else {
+			add = append(add, taint)
+		}
+	}
+
+	var result []v1.Taint
+	for _, t := range taints {
+		if !remove[t.Key+":"+string(t.Effect)] {
+			result = append(result, t)
+		}
+	}
+	for _, taint := range add {
+		replaced := false
+		for i := range result {
+			if result[i].Key == taint.Key && result[i].Effect == taint.Effect {
+				result[i], replaced = taint, true
+			}
+		}
+		if !replaced {
+			result = append(result, taint)
+		}
+	}
+	return result, nil
+}
+
+// CordonNode marks the node unschedulable, or schedulable again when unschedulable is false.
+func (c *Client) CordonNode(name string, unschedulable bool) error {
+	node, err := c.client.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %s", name)
+	}
+	if node.Spec.Unschedulable == unschedulable {
+		return nil
+	}
+	node.Spec.Unschedulable = unschedulable
+	_, err = c.UpdateNode(node)
+	return err
+}
+
+// DrainNode cordons the node, evicts all pods on it except daemonset and static pods, and waits up to
+// timeout for them to be deleted.
+func (c *Client) DrainNode(name string, timeout time.Duration) error {
+	if err := c.CordonNode(name, true); err != nil {
+		return err
+	}
+	pods, err := c.podsToEvict(name)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		eviction := &policyv1beta1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		}
+		if err := c.client.PolicyV1beta1().Evictions(pod.Namespace).Evict(context.TODO(), eviction); err != nil {
+			return errors.Wrapf(err, "failed to evict pod %s/%s", pod.Namespace, pod.Name)
+		}
+	}
+	err = wait.PollImmediate(2*time.Second, timeout, func() (bool, error) {
+		left, err := c.podsToEvict(name)
+		return len(left) == 0, err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to wait for the pods on node %s evicted in %s", name, timeout)
+	}
+	return nil
+}
+
+func (c *Client) podsToEvict(node string) ([]v1.Pod, error) {
+	pods, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pods on node %s", node)
+	}
+	var res []v1.Pod
+	for _, pod := range pods.Items {
+		if needEvict(pod) {
+			res = append(res, pod)
+		}
+	}
+	return res, nil
+}
+
+func needEvict(pod v1.Pod) bool {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return false
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
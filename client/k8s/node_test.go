@@ -0,0 +1,73 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileTaints(t *testing.T) {
+	master := v1.Taint{Key: "node-role.kubernetes.io/master", Effect: v1.TaintEffectNoSchedule}
+	ingress := v1.Taint{Key: "dedicated", Value: "ingress", Effect: v1.TaintEffectNoSchedule}
+	tests := []struct {
+		name      string
+		taints    []v1.Taint
+		oldTaints []string
+		newTaints []string
+		want      []v1.Taint
+	}{
+		{
+			name:      "set taints and keep the ones of kubernetes",
+			taints:    []v1.Taint{master},
+			newTaints: []string{"dedicated=ingress:NoSchedule"},
+			want:      []v1.Taint{master, ingress},
+		},
+		{
+			name:      "replace taints last applied",
+			taints:    []v1.Taint{master, ingress},
+			oldTaints: []string{"dedicated=ingress:NoSchedule"},
+			want:      []v1.Taint{master},
+		},
+		{
+			name:      "remove the taint of kubernetes",
+			taints:    []v1.Taint{master},
+			newTaints: []string{"node-role.kubernetes.io/master:NoSchedule-", "node-role.kubernetes.io/control-plane:NoSchedule-"},
+			want:      nil,
+		},
+		{
+			name:      "removal applied before restores nothing",
+			taints:    nil,
+			oldTaints: []string{"node-role.kubernetes.io/master:NoSchedule-"},
+			want:      nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reconcileTaints(tt.taints, tt.oldTaints, tt.newTaints)
+			if err != nil {
+				t.Fatalf("reconcileTaints() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reconcileTaints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := reconcileTaints(nil, nil, []string{"dedicated-"}); err == nil {
+		t.Errorf("reconcileTaints() with invalid taint error = nil")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/utils"
+)
+
+// SystemNamespaces are the namespaces of kubernetes and the CNI of the base CloudImages, their workloads
+// are not listed by ListUserWorkloads.
+var SystemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease", "tigera-operator", "calico-system", "calico-apiserver"}
+
+// ListUserWorkloads lists the deployments, statefulsets, daemonsets and the pods without controller out of
+// SystemNamespaces, like: deployment default/nginx.
+func (c *Client) ListUserWorkloads() ([]string, error) {
+	ctx, opts := context.TODO(), metav1.ListOptions{}
+	var workloads []string
+	add := func(kind string, meta metav1.ObjectMeta) {
+		if utils.NotIn(meta.Namespace, SystemNamespaces) {
+			workloads = append(workloads, fmt.Sprintf("%s %s/%s", kind, meta.Namespace, meta.Name))
+		}
+	}
+
+	deployments, err := c.client.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list deployments")
+	}
+	for _, d := range deployments.Items {
+		add("deployment", d.ObjectMeta)
+	}
+	statefulSets, err := c.client.AppsV1().StatefulSets(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list statefulsets")
+	}
+	for _, s := range statefulSets.Items {
+		add("statefulset", s.ObjectMeta)
+	}
+	daemonSets, err := c.client.AppsV1().DaemonSets(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list daemonsets")
+	}
+	for _, d := range daemonSets.Items {
+		add("daemonset", d.ObjectMeta)
+	}
+	pods, err := c.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pods")
+	}
+	for i := range pods.Items {
+		if metav1.GetControllerOf(&pods.Items[i]) == nil {
+			add("pod", pods.Items[i].ObjectMeta)
+		}
+	}
+	return workloads, nil
+}
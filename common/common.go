@@ -31,6 +31,7 @@ const (
 	RUNCOMMAND         = "RUN"
 	CMDCOMMAND         = "CMD"
 	ENVCOMMAND         = "ENV"
+	ARGCOMMAND         = "ARG"
 	BaseImageLayerType = "BASE"
 	RootfsLayerValue   = "rootfs cache"
 )
@@ -41,6 +42,7 @@ const (
 	DefaultTmpDir                 = "/var/lib/sealer/tmp"
 	DefaultLiteBuildUpper         = "/var/lib/sealer/tmp/lite_build_upper"
 	DefaultLogDir                 = "/var/lib/sealer/log"
+	DefaultHostLogDir             = "/var/lib/sealer/logs"
 	DefaultClusterFileName        = "Clusterfile"
 	DefaultClusterRootfsDir       = "/var/lib/sealer/data"
 	DefaultClusterInitBashFile    = "/var/lib/sealer/data/%s/scripts/init.sh"
@@ -48,6 +50,12 @@ const (
 	TarGzSuffix                   = ".tar.gz"
 	YamlSuffix                    = ".yaml"
 	ImageAnnotationForClusterfile = "sea.aliyun.com/ClusterFile"
+	ImageAnnotationForSBOM        = "sea.aliyun.com/SBOM"
+	ImageAnnotationForVerified    = "sea.aliyun.com/VerifiedDigest"
+	ImageAnnotationForCompression = "sea.aliyun.com/Compression"
+	ImageAnnotationForDeprecated  = "sea.aliyun.com/Deprecated"
+	ImageAnnotationForEndOfLife   = "sea.aliyun.com/EndOfLife"
+	ImageAnnotationForReplacedBy  = "sea.aliyun.com/ReplacedBy"
 	RawClusterfile                = "/var/lib/sealer/Clusterfile"
 	TmpClusterfile                = "/tmp/Clusterfile"
 	DefaultRegistryHostName       = "registry.cn-qingdao.aliyuncs.com"
@@ -57,7 +65,6 @@ const (
 	KubectlPath                   = "/usr/bin/kubectl"
 	EtcHosts                      = "/etc/hosts"
 	ClusterWorkDir                = "/root/.sealer/%s"
-	RemoteSealerPath              = "/usr/local/bin/sealer"
 	DefaultCloudProvider          = AliCloud
 	ClusterfileName               = "ClusterfileName"
 	CacheID                       = "cacheID"
@@ -117,6 +124,7 @@ const (
 const (
 	FileMode0755 = 0755
 	FileMode0644 = 0644
+	FileMode0600 = 0600
 )
 
 const APIServerDomain = "apiserver.cluster.local"
@@ -147,6 +155,11 @@ func GetClusterWorkClusterfile(clusterName string) string {
 	return filepath.Join(GetClusterWorkDir(clusterName), "Clusterfile")
 }
 
+// GetClusterOrphansFile is out of the work dir of cluster, so the hosts skipped by deleting the cluster are kept.
+func GetClusterOrphansFile(clusterName string) string {
+	return filepath.Join(GetHomeDir(), ".sealer", clusterName+".orphans.json")
+}
+
 func DefaultRegistryAuthConfigDir() string {
 	return filepath.Join(GetHomeDir(), ".docker/config.json")
 }
@@ -25,4 +25,14 @@ type RunArgs struct {
 	SvcCidr    string
 	Provider   string
 	CustomEnv  []string
+	// Cmd overrides the CMD of image
+	Cmd []string
+	// Apps are the app bundles of image to install instead of its CMD
+	Apps []string
+	// Single runs a single-node cluster on the current machine, its master runs the workloads
+	Single bool
+	// Promote is the master promoted to master0 when master0 is deleted
+	Promote string
+	// Force deletes the masters even if the cluster is left without high availability
+	Force bool
 }
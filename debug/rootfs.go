@@ -0,0 +1,173 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/commit"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/mount"
+)
+
+// Rootfs is the rootfs of a CloudImage mounted locally, which is what apply distributes to the hosts.
+type Rootfs struct {
+	// Dir is the merged rootfs.
+	Dir string
+	// Upper holds the changes made to Dir if it is mounted by overlay.
+	Upper   string
+	driver  mount.Interface
+	mounted bool
+}
+
+// MountRootfs mounts the layers of img to a temporary dir.
+func MountRootfs(img *v1.Image) (*Rootfs, error) {
+	layers, err := image.GetImageLayerDirs(img)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := utils.MkTmpdir()
+	if err != nil {
+		return nil, err
+	}
+	upper, err := utils.MkTmpdir()
+	if err != nil {
+		utils.CleanDir(dir)
+		return nil, err
+	}
+	r := &Rootfs{Dir: dir, Upper: upper, driver: mount.NewMountDriver()}
+	if err = r.driver.Mount(dir, upper, layers...); err != nil {
+		utils.CleanDirs(dir, upper)
+		return nil, err
+	}
+	r.mounted = true
+	return r, nil
+}
+
+// Overlay returns whether the changes made to the rootfs are kept in Upper, the layers are copied to the
+// rootfs instead if overlay is not supported.
+func (r *Rootfs) Overlay() bool {
+	_, copied := r.driver.(*mount.Default)
+	return !copied
+}
+
+// Changed returns whether any file of the rootfs is changed.
+func (r *Rootfs) Changed() bool {
+	files, err := ioutil.ReadDir(r.Upper)
+	return err == nil && len(files) != 0
+}
+
+// Commit unmounts the rootfs and saves the changes in Upper as a new layer on top of img, the new image is
+// saved as name.
+func (r *Rootfs) Commit(img *v1.Image, name string) error {
+	if !r.Overlay() {
+		return fmt.Errorf("the changes of rootfs can not be committed, overlay is not supported on this host")
+	}
+	if err := r.unmount(); err != nil {
+		return err
+	}
+	return commit.CommitLayer(img, r.Upper, name)
+}
+
+// Unmount unmounts the rootfs and removes its dirs, the changes are discarded unless they are committed.
+func (r *Rootfs) Unmount() {
+	if err := r.unmount(); err != nil {
+		logger.Warn(err)
+	}
+	utils.CleanDirs(r.Dir, r.Upper)
+}
+
+func (r *Rootfs) unmount() error {
+	if !r.mounted {
+		return nil
+	}
+	if err := r.driver.Unmount(r.Dir); err != nil {
+		return fmt.Errorf("failed to unmount %s: %v", r.Dir, err)
+	}
+	r.mounted = false
+	return nil
+}
+
+// Shell runs cmd in the rootfs, or an interactive shell if cmd is empty. The shell is the one of $SHELL of
+// this host, PS1 tells the rootfs of which image it is in.
+func (r *Rootfs) Shell(name string, cmd []string) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	var c *exec.Cmd
+	if len(cmd) == 0 {
+		c = exec.Command(shell) // #nosec
+	} else {
+		c = exec.Command(cmd[0], cmd[1:]...) // #nosec
+	}
+	c.Dir = r.Dir
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Env = append(os.Environ(), "ROOTFS="+r.Dir, fmt.Sprintf(`PS1=[%s] \w # `, name))
+	return c.Run()
+}
+
+// PrintTree prints the files under root like tree, the dirs deeper than depth are not expanded.
+func PrintTree(w io.Writer, root string, depth int) error {
+	fmt.Fprintln(w, ".")
+	dirs, files, err := printTree(w, root, "", depth)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%d directories, %d files\n", dirs, files)
+	return nil
+}
+
+func printTree(w io.Writer, dir, prefix string, depth int) (dirs, files int, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+	for i, info := range infos {
+		branch, indent := "├── ", "│   "
+		if i == len(infos)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if !info.IsDir() {
+			files++
+			fmt.Fprintf(w, "%s%s%s (%s)\n", prefix, branch, info.Name(), utils.FormatSize(info.Size()))
+			continue
+		}
+		dirs++
+		if depth == 1 {
+			fmt.Fprintf(w, "%s%s%s/ ...\n", prefix, branch, info.Name())
+			continue
+		}
+		fmt.Fprintf(w, "%s%s%s/\n", prefix, branch, info.Name())
+		d, f, err := printTree(w, filepath.Join(dir, info.Name()), prefix+indent, depth-1)
+		if err != nil {
+			return 0, 0, err
+		}
+		dirs, files = dirs+d, files+f
+	}
+	return dirs, files, nil
+}
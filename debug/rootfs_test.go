@@ -0,0 +1,79 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrintTree(t *testing.T) {
+	root, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for path, content := range map[string]string{
+		"Metadata":                     "{}",
+		"bin/kubeadm":                  "kubeadm",
+		"scripts/init.sh":              "#!/bin/bash",
+		"manifests/calico/calico.yaml": "",
+	} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		depth int
+		want  string
+	}{
+		{1, `.
+├── Metadata (2.00B)
+├── bin/ ...
+├── manifests/ ...
+└── scripts/ ...
+
+3 directories, 1 files
+`},
+		{2, `.
+├── Metadata (2.00B)
+├── bin/
+│   └── kubeadm (7.00B)
+├── manifests/
+│   └── calico/ ...
+└── scripts/
+    └── init.sh (11.00B)
+
+4 directories, 3 files
+`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := PrintTree(&out, root, tt.depth); err != nil {
+			t.Fatalf("PrintTree() error = %v", err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("PrintTree() depth %d =\n%s\nwant\n%s", tt.depth, out.String(), tt.want)
+		}
+	}
+}
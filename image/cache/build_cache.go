@@ -0,0 +1,88 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+)
+
+// buildCacheFile records the layers built by sealer build keyed by chain id,
+// the chains of saved images are restored from the images themselves.
+var buildCacheFile = filepath.Join(common.DefaultImageMetaRootDir, "buildcache.json")
+
+func (cs *chainStore) restoreBuildCache() {
+	cs.Lock()
+	defer cs.Unlock()
+
+	data, err := ioutil.ReadFile(buildCacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("failed to read build cache %s, err: %v", buildCacheFile, err)
+		}
+		return
+	}
+	records := map[ChainID]v1.Layer{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		logger.Warn("failed to decode build cache %s, err: %v", buildCacheFile, err)
+		return
+	}
+	for id, layer := range records {
+		if _, ok := cs.chains[id]; ok {
+			continue
+		}
+		// the layers removed by rmi or prune are dropped.
+		if cs.ls.Get(store.LayerID(layer.ID)) == nil {
+			continue
+		}
+		cs.chains[id] = &chainItem{layer: layer, chainID: id}
+	}
+}
+
+func (cs *chainStore) AddChainLayer(id ChainID, layer v1.Layer) error {
+	if id == "" || layer.ID == "" {
+		return nil
+	}
+	cs.Lock()
+	defer cs.Unlock()
+	cs.chains[id] = &chainItem{layer: layer, chainID: id}
+
+	records := map[ChainID]v1.Layer{}
+	for chainID, item := range cs.chains {
+		if item.layer.ID != "" {
+			records[chainID] = item.layer
+		}
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(buildCacheFile), common.FileMode0755); err != nil {
+		return err
+	}
+	if err := utils.AtomicWriteFile(buildCacheFile, data, common.FileMode0644); err != nil {
+		return fmt.Errorf("failed to write build cache %s: %v", buildCacheFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// fakeBackend keeps the cache ids of COPY layers, the other methods of store.Backend are not used.
+type fakeBackend struct {
+	store.Backend
+	cacheIDs map[digest.Digest]string
+}
+
+func (f *fakeBackend) ListImages() ([][]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) GetMetadata(id digest.Digest, key string) ([]byte, error) {
+	if cacheID, ok := f.cacheIDs[id]; ok && key == common.CacheID {
+		return []byte(cacheID), nil
+	}
+	return nil, fmt.Errorf("no metadata %s of %s", key, id)
+}
+
+// fakeLayerStore has the layers of ids, the other methods of store.LayerStore are not used.
+type fakeLayerStore struct {
+	store.LayerStore
+	ids map[store.LayerID]bool
+}
+
+func (f *fakeLayerStore) Get(id store.LayerID) store.Layer {
+	if f.ids[id] {
+		return &store.ROLayer{}
+	}
+	return nil
+}
+
+func newTestChainStore(t *testing.T, layers ...v1.Layer) *chainStore {
+	origin := buildCacheFile
+	buildCacheFile = filepath.Join(t.TempDir(), "buildcache.json")
+	t.Cleanup(func() {
+		buildCacheFile = origin
+	})
+	ls := &fakeLayerStore{ids: map[store.LayerID]bool{}}
+	for _, l := range layers {
+		ls.ids[store.LayerID(l.ID)] = true
+	}
+	return &chainStore{
+		chains: map[ChainID]*chainItem{},
+		fs:     &fakeBackend{cacheIDs: map[digest.Digest]string{}},
+		ls:     ls,
+	}
+}
+
+func layerID(s string) digest.Digest {
+	return digest.FromString(s)
+}
+
+func mustChainID(t *testing.T, layers ...Layer) ChainID {
+	id, err := CalculateCacheID(layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestChainStore_ChainID(t *testing.T) {
+	base := v1.Layer{ID: layerID("base"), Type: common.COPYCOMMAND, Value: "rootfs ."}
+	otherBase := v1.Layer{ID: layerID("other base"), Type: common.COPYCOMMAND, Value: "rootfs ."}
+	run := v1.Layer{ID: layerID("run"), Type: common.RUNCOMMAND, Value: "kubectl apply -f dashboard.yaml"}
+	copyLayer := v1.Layer{ID: layerID("copy"), Type: common.COPYCOMMAND, Value: "dashboard.yaml manifests"}
+
+	cs := newTestChainStore(t)
+	cs.fs.(*fakeBackend).cacheIDs[copyLayer.ID] = "sha256:sources"
+	baseCache := Layer{CacheID: base.ID.String(), Type: base.Type, Value: base.Value}
+	tests := []struct {
+		name   string
+		layers []v1.Layer
+		want   ChainID
+	}{
+		// the layers pulled from registry have no cache id, they are keyed by content.
+		{"base", []v1.Layer{base}, mustChainID(t, baseCache)},
+		// RUN is keyed by the command on its parent, not the layer it built.
+		{"run", []v1.Layer{base, run}, mustChainID(t, baseCache, Layer{Type: run.Type, Value: run.Value})},
+		{"run built again", []v1.Layer{base, {ID: layerID("run again"), Type: run.Type, Value: run.Value}},
+			mustChainID(t, baseCache, Layer{Type: run.Type, Value: run.Value})},
+		// COPY is keyed by the digest of the source files.
+		{"copy", []v1.Layer{base, copyLayer}, mustChainID(t, baseCache, Layer{CacheID: "sha256:sources", Type: copyLayer.Type, Value: copyLayer.Value})},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cs.ChainID(tt.layers)
+			if err != nil {
+				t.Fatalf("ChainID() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ChainID() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// the same instructions on different bases never share a cache.
+	a, _ := cs.ChainID([]v1.Layer{base, run, copyLayer})
+	b, _ := cs.ChainID([]v1.Layer{otherBase, run, copyLayer})
+	if a == b {
+		t.Errorf("expected different chain ids of different bases, got %s", a)
+	}
+}
+
+func TestChainStore_BuildCache(t *testing.T) {
+	base := v1.Layer{ID: layerID("base"), Type: common.COPYCOMMAND, Value: "rootfs ."}
+	kept := v1.Layer{ID: layerID("kept"), Type: common.RUNCOMMAND, Value: "echo kept"}
+	removed := v1.Layer{ID: layerID("removed"), Type: common.RUNCOMMAND, Value: "echo removed"}
+	cs := newTestChainStore(t, base, kept)
+	keptChain, _ := cs.ChainID([]v1.Layer{base, kept})
+	removedChain, _ := cs.ChainID([]v1.Layer{base, removed})
+	for id, layer := range map[ChainID]v1.Layer{keptChain: kept, removedChain: removed} {
+		if err := cs.AddChainLayer(id, layer); err != nil {
+			t.Fatalf("AddChainLayer() error = %v", err)
+		}
+	}
+	if err := cs.AddChainLayer("", kept); err != nil {
+		t.Errorf("AddChainLayer() without chain id error = %v", err)
+	}
+	if _, err := ioutil.ReadFile(buildCacheFile); err != nil {
+		t.Fatalf("expected build cache saved: %v", err)
+	}
+
+	// a new process restores the cache, without the layers removed by rmi or prune.
+	restored := &chainStore{chains: map[ChainID]*chainItem{}, fs: cs.fs, ls: cs.ls}
+	restored.restoreBuildCache()
+	if len(restored.chains) != 1 {
+		t.Errorf("restored chains = %v, want the one of kept layer only", restored.chains)
+	}
+	layer, err := restored.GetChainLayer(keptChain)
+	if err != nil || layer.ID != kept.ID {
+		t.Errorf("GetChainLayer() = %v, %v, want %s", layer, err, kept.ID)
+	}
+	if _, err = restored.GetChainLayer(removedChain); err == nil {
+		t.Errorf("expected no cache of removed layer")
+	}
+
+	// the layer removed after the cache restored is not used.
+	delete(cs.ls.(*fakeLayerStore).ids, store.LayerID(kept.ID))
+	if _, err = restored.GetChainLayer(keptChain); err == nil {
+		t.Errorf("expected no cache of layer removed after restored")
+	}
+}
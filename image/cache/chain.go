@@ -30,8 +30,8 @@ import (
 var imageChain *chainStore
 var once sync.Once
 
-//ChainID is caculated from a series of serialized cache layers. The layers cacheID
-// is "", but the COPY layer.
+//ChainID is caculated from a series of serialized cache layers. The cacheID of RUN
+// and CMD layers is "", COPY layer uses the digest of source files, the others use layer id.
 // same ChainID indicates that same entire file system.
 type ChainID digest.Digest
 
@@ -59,6 +59,10 @@ func (id ImageID) Digest() digest.Digest {
 type ChainStore interface {
 	Images() map[ImageID]*v1.Image
 	GetChainLayer(id ChainID) (v1.Layer, error)
+	// ChainID returns the chain id of layers, like the base layers of a build.
+	ChainID(layers []v1.Layer) (ChainID, error)
+	// AddChainLayer records a layer built on chain id, so the later builds can reuse it without saving an image.
+	AddChainLayer(id ChainID, layer v1.Layer) error
 }
 
 type chainItem struct {
@@ -82,6 +86,7 @@ func NewImageStore(fs store.Backend, ls store.LayerStore) (ChainStore, error) {
 		}
 
 		imageChain.restore()
+		imageChain.restoreBuildCache()
 	})
 	return imageChain, nil
 }
@@ -137,6 +142,10 @@ func (cs *chainStore) GetChainLayer(id ChainID) (v1.Layer, error) {
 	defer cs.RUnlock()
 
 	if imagemeta, ok := cs.chains[id]; ok {
+		// the layer may be removed after the chain is restored.
+		if imagemeta.layer.ID != "" && cs.ls.Get(store.LayerID(imagemeta.layer.ID)) == nil {
+			return v1.Layer{}, errors.Errorf("layer %s of chain id %s is removed", imagemeta.layer.ID, id)
+		}
 		return imagemeta.layer, nil
 	}
 
@@ -172,18 +181,37 @@ func (cs *chainStore) Images() map[ImageID]*v1.Image {
 
 func (cs *chainStore) newCacheLayer(layer *v1.Layer) (*Layer, error) {
 	var cacheLayer = Layer{Type: layer.Type, Value: layer.Value}
-	// only copy layer needs the cache id.
-	if layer.Type != common.COPYCOMMAND {
+	switch layer.Type {
+	case common.RUNCOMMAND, common.CMDCOMMAND:
+		// the same command on the same chain is cached, like docker.
 		return &cacheLayer, nil
+	case common.COPYCOMMAND:
+		// copy layer uses the digest of source files as cache id.
+		cacheIDBytes, err := cs.fs.GetMetadata(layer.ID, common.CacheID)
+		if err == nil {
+			// TODO maybe we should validate the cacheid over digest
+			cacheLayer.CacheID = string(cacheIDBytes)
+			return &cacheLayer, nil
+		}
+		// the layers pulled from registry have no cache id.
 	}
+	// the others are keyed by content, different base images share no cache.
+	cacheLayer.CacheID = layer.ID.String()
+	return &cacheLayer, nil
+}
 
-	cacheIDBytes, err := cs.fs.GetMetadata(layer.ID, common.CacheID)
-	if err != nil {
-		return nil, err
+func (cs *chainStore) ChainID(layers []v1.Layer) (ChainID, error) {
+	var chainID ChainID
+	for i := range layers {
+		cacheLayer, err := cs.newCacheLayer(&layers[i])
+		if err != nil {
+			return "", err
+		}
+		if chainID, err = cacheLayer.ChainID(chainID); err != nil {
+			return "", err
+		}
 	}
-	// TODO maybe we should validate the cacheid over digest
-	cacheLayer.CacheID = string(cacheIDBytes)
-	return &cacheLayer, nil
+	return chainID, nil
 }
 
 func CalculateCacheID(cacheLayers []Layer) (ChainID, error) {
@@ -183,7 +183,6 @@ func (d DefaultImageService) Login(RegistryURL, RegistryUsername, RegistryPasswd
 
 func (d DefaultImageService) Delete(imageArg string) error {
 	var (
-		images        []*v1.Image
 		image         *v1.Image
 		imageTagCount int
 		imageID       string
@@ -231,17 +230,9 @@ func (d DefaultImageService) Delete(imageArg string) error {
 	logger.Info("untag image %s succeeded", image.Spec.ID)
 
 	for _, value := range imageMetadataMap {
-		tmpImage, err := imageStore.GetByID(value.ID)
-		if err != nil {
-			continue
-		}
 		if value.ID == imageID {
 			imageTagCount++
-			if imageTagCount > 1 {
-				continue
-			}
 		}
-		images = append(images, tmpImage)
 	}
 	if imageTagCount != 1 && !d.ForceDeleteImage {
 		return nil
@@ -252,41 +243,42 @@ func (d DefaultImageService) Delete(imageArg string) error {
 		return err
 	}
 
-	layer2ImageNames := layer2ImageMap(images)
 	// TODO: find a atomic way to delete layers and image
+	layerRefs, err := layerReferences(imageStore, image.Spec.ID)
+	if err != nil {
+		return err
+	}
 	layerStore, err := store.NewDefaultLayerStore()
 	if err != nil {
 		return err
 	}
 
+	var reclaimed int64
 	for _, layer := range image.Spec.Layers {
+		if layer.ID == "" {
+			continue
+		}
 		layerID := store.LayerID(layer.ID)
-		if isLayerDeletable(layer2ImageNames, layerID) {
-			err = layerStore.Delete(layerID)
-			if err != nil {
-				// print log and continue to delete other layers of the image
-				logger.Error("Fail to delete image %s's layer %s", image.Spec.ID, layerID)
-			}
+		if refs := layerRefs[layerID]; refs > 0 {
+			logger.Debug("layer %s is shared by %d images, skip deleting it", layerID, refs)
+			continue
+		}
+		size := layerSize(layerStore, layerID)
+		if err = layerStore.Delete(layerID); err != nil {
+			// print log and continue to delete other layers of the image
+			logger.Error("Fail to delete image %s's layer %s", image.Spec.ID, layerID)
+			continue
 		}
+		reclaimed += size
 	}
 
-	logger.Info("image %s delete success", image.Spec.ID)
-	return nil
-}
-
-func isLayerDeletable(layer2ImageNames map[store.LayerID][]string, layerID store.LayerID) bool {
-	return len(layer2ImageNames[layerID]) <= 1
-}
+	logger.Info("image %s delete success, reclaimed %s", image.Spec.ID, utils.FormatSize(reclaimed))
 
-// layer2ImageMap accepts a directory parameter which contains image metadata.
-// It reads these metadata and saves the layer and image relationship in a map.
-func layer2ImageMap(images []*v1.Image) map[store.LayerID][]string {
-	var layer2ImageNames = make(map[store.LayerID][]string)
-	for _, image := range images {
-		for _, layer := range image.Spec.Layers {
-			layerID := store.LayerID(layer.ID)
-			layer2ImageNames[layerID] = append(layer2ImageNames[layerID], image.Spec.ID)
+	// the images of each platform go along with the multi-platform image.
+	for _, m := range image.Spec.Manifests {
+		if err = d.Delete(m.Name); err != nil {
+			logger.Warn("failed to delete image %s of platform %s: %v", m.Name, m.Platform.Architecture, err)
 		}
 	}
-	return layer2ImageNames
+	return nil
 }
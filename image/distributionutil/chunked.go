@@ -0,0 +1,393 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributionutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	dockerTransport "github.com/docker/distribution/registry/client/transport"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	progressInterval = 512 * 1024
+
+	uploadStateSuffix = ".upload"
+	partialSuffix     = ".partial"
+)
+
+// retryInterval is the interval before the first retry of a chunk, it grows on each retry.
+var retryInterval = time.Second
+
+// blobCacheDir keeps the compressed layers to be pushed and the partially
+// pulled blobs, so that an interrupted push or pull resumes from where it stopped.
+var blobCacheDir = filepath.Join(common.DefaultTmpDir, "blobs")
+
+// errUploadUnknown means the upload session is expired or cleaned by registry.
+var errUploadUnknown = fmt.Errorf("blob upload unknown")
+
+// errOffsetUnknown means the offset committed by registry can not be told from the Range of the upload status.
+var errOffsetUnknown = fmt.Errorf("blob upload offset unknown")
+
+// uploadState is saved after each chunk uploaded.
+type uploadState struct {
+	Repository string `json:"repository"`
+	Location   string `json:"location"`
+}
+
+// chunkedUploader uploads a blob by PATCH requests of Config.ChunkSize, the
+// upload location is saved to resume the upload from the offset committed by
+// registry after failure.
+type chunkedUploader struct {
+	repo      *registryRepository
+	config    Config
+	id        string
+	dgst      digest.Digest
+	path      string
+	size      int64
+	statePath string
+}
+
+func newChunkedUploader(repo *registryRepository, config Config, id string, dgst digest.Digest, path string, size int64) *chunkedUploader {
+	return &chunkedUploader{
+		repo:      repo,
+		config:    config,
+		id:        id,
+		dgst:      dgst,
+		path:      path,
+		size:      size,
+		statePath: path + "-" + digest.FromString(repo.Named().String()).Hex()[:12] + uploadStateSuffix,
+	}
+}
+
+func (u *chunkedUploader) Upload(ctx context.Context) error {
+	f, err := os.Open(filepath.Clean(u.path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	location, offset := u.resume(ctx)
+	if location == "" {
+		if location, err = u.start(ctx); err != nil {
+			return err
+		}
+	}
+	for offset < u.size {
+		err = utils.Retry(u.config.retries(), retryInterval, func() error {
+			end := offset + u.config.chunkSize()
+			if end > u.size {
+				end = u.size
+			}
+			next, err := u.patch(ctx, location, f, offset, end)
+			if err == nil {
+				location, offset = next, end
+				u.saveState(location)
+				return nil
+			}
+			logger.Debug("failed to upload chunk %d-%d of %s: %v", offset, end, u.dgst, err)
+			// the chunk may be partially accepted, sync the offset with registry.
+			next, committed, serr := u.status(ctx, location)
+			switch {
+			case serr == errUploadUnknown || serr == errOffsetUnknown:
+				if location, serr = u.start(ctx); serr == nil {
+					offset = 0
+				}
+			case serr == nil:
+				location, offset = next, committed
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload blob %s: %v", u.dgst, err)
+		}
+	}
+	return utils.Retry(u.config.retries(), retryInterval, func() error {
+		return u.put(ctx, location)
+	})
+}
+
+// Clean removes the upload state after the blob committed.
+func (u *chunkedUploader) Clean() {
+	if err := os.Remove(u.statePath); err != nil && !os.IsNotExist(err) {
+		logger.Warn("failed to remove upload state %s: %v", u.statePath, err)
+	}
+}
+
+// resume returns the saved upload location and the offset committed by registry,
+// the location is empty if there is nothing to resume.
+func (u *chunkedUploader) resume(ctx context.Context) (string, int64) {
+	data, err := ioutil.ReadFile(u.statePath)
+	if err != nil {
+		return "", 0
+	}
+	var state uploadState
+	if err = json.Unmarshal(data, &state); err != nil || state.Repository != u.repo.Named().String() {
+		return "", 0
+	}
+	location, offset, err := u.status(ctx, state.Location)
+	if err != nil || offset > u.size {
+		return "", 0
+	}
+	if offset > 0 {
+		progress.Messagef(u.config.ProgressOutput, u.id, "resuming upload from %s", utils.FormatSize(offset))
+	}
+	return location, offset
+}
+
+func (u *chunkedUploader) saveState(location string) {
+	data, _ := json.Marshal(uploadState{Repository: u.repo.Named().String(), Location: location})
+	if err := ioutil.WriteFile(u.statePath, data, common.FileMode0644); err != nil {
+		logger.Debug("failed to save upload state of %s: %v", u.dgst, err)
+	}
+}
+
+func (u *chunkedUploader) start(ctx context.Context) (string, error) {
+	uploadURL, err := u.repo.ub.BuildBlobUploadURL(u.repo.Named())
+	if err != nil {
+		return "", err
+	}
+	resp, err := u.do(ctx, http.MethodPost, uploadURL, nil, 0, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return "", unexpectedStatus(resp)
+	}
+	location, err := resolveLocation(uploadURL, resp.Header.Get("Location"))
+	if err == nil {
+		u.saveState(location)
+	}
+	return location, err
+}
+
+func (u *chunkedUploader) patch(ctx context.Context, location string, f *os.File, start, end int64) (string, error) {
+	body := newProgressReader(io.NewSectionReader(f, start, end-start), u.config.ProgressOutput, u.id, "pushing", start, u.size)
+	resp, err := u.do(ctx, http.MethodPatch, location, body, end-start, map[string]string{
+		"Content-Type":  "application/octet-stream",
+		"Content-Range": fmt.Sprintf("%d-%d", start, end-1),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return "", unexpectedStatus(resp)
+	}
+	return resolveLocation(location, resp.Header.Get("Location"))
+}
+
+// status returns the upload location and the offset committed by registry.
+func (u *chunkedUploader) status(ctx context.Context, location string) (string, int64, error) {
+	resp, err := u.do(ctx, http.MethodGet, location, nil, 0, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", 0, errUploadUnknown
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return "", 0, unexpectedStatus(resp)
+	}
+	next, err := resolveLocation(location, resp.Header.Get("Location"))
+	if err != nil {
+		return "", 0, err
+	}
+	// Range is 0-<last byte>, registry returns 0-0 for both nothing and the first byte uploaded.
+	// The upload is restarted then, which loses one byte at most.
+	rng := strings.SplitN(resp.Header.Get("Range"), "-", 2)
+	if len(rng) != 2 {
+		return next, 0, nil
+	}
+	last, err := strconv.ParseInt(rng[1], 10, 64)
+	if err != nil {
+		return next, 0, nil
+	}
+	if last == 0 {
+		return next, 0, errOffsetUnknown
+	}
+	return next, last + 1, nil
+}
+
+func (u *chunkedUploader) put(ctx context.Context, location string) error {
+	lu, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	values := lu.Query()
+	values.Set("digest", u.dgst.String())
+	lu.RawQuery = values.Encode()
+
+	resp, err := u.do(ctx, http.MethodPut, lu.String(), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return unexpectedStatus(resp)
+	}
+	return nil
+}
+
+func (u *chunkedUploader) do(ctx context.Context, method, url string, body io.Reader, size int64, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return u.repo.client.Do(req)
+}
+
+func resolveLocation(base, location string) (string, error) {
+	if location == "" {
+		return "", fmt.Errorf("no Location in the response of %s", base)
+	}
+	bu, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	lu, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	return bu.ResolveReference(lu).String(), nil
+}
+
+func unexpectedStatus(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %s of %s %s: %s", resp.Status, resp.Request.Method, resp.Request.URL.Path, strings.TrimSpace(string(body)))
+}
+
+// fetchBlob downloads the blob of dgst to path, the download resumes from the
+// size of path by range request after failure.
+func fetchBlob(ctx context.Context, repo *registryRepository, config Config, id string, dgst digest.Digest, size int64, path string) error {
+	bs := repo.Blobs(ctx)
+	return utils.Retry(config.retries(), retryInterval, func() error {
+		f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_APPEND, common.FileMode0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		offset := info.Size()
+		if offset > size {
+			if err = f.Truncate(0); err != nil {
+				return err
+			}
+			offset = 0
+		}
+		if offset == size {
+			return nil
+		}
+
+		rs, err := bs.Open(ctx, dgst)
+		if err != nil {
+			return err
+		}
+		defer rs.Close()
+		if offset > 0 {
+			progress.Messagef(config.ProgressOutput, id, "resuming download from %s", utils.FormatSize(offset))
+			_, err = rs.Seek(offset, io.SeekStart)
+		}
+		if err == nil {
+			_, err = io.Copy(f, newProgressReader(rs, config.ProgressOutput, id, "pulling", offset, size))
+		}
+		// the range request fails on seeking or reading.
+		if err == dockerTransport.ErrWrongCodeForByteRange {
+			// registry does not support range request, download from the beginning.
+			if terr := f.Truncate(0); terr != nil {
+				return terr
+			}
+		}
+		if err != nil {
+			logger.Debug("failed to download blob %s: %v", dgst, err)
+			return err
+		}
+		if info, err = f.Stat(); err != nil {
+			return err
+		}
+		if info.Size() != size {
+			return fmt.Errorf("size of blob %s is %d, expect %d", dgst, info.Size(), size)
+		}
+		return nil
+	})
+}
+
+// fileDigest returns the sha256 digest and size of file.
+func fileDigest(path string) (digest.Digest, int64, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(digester.Hash(), f)
+	if err != nil {
+		return "", 0, err
+	}
+	return digester.Digest(), size, nil
+}
+
+// progressReader reports the progress of the whole blob while reading a part of it.
+type progressReader struct {
+	in      io.Reader
+	out     progress.Output
+	id      string
+	action  string
+	current int64
+	total   int64
+	last    int64
+}
+
+func newProgressReader(in io.Reader, out progress.Output, id, action string, start, total int64) *progressReader {
+	return &progressReader{in: in, out: out, id: id, action: action, current: start, last: start, total: total}
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.in.Read(buf)
+	p.current += int64(n)
+	if p.current-p.last >= progressInterval || p.current == p.total || err != nil {
+		p.last = p.current
+		if werr := p.out.WriteProgress(progress.Progress{ID: p.id, Action: p.action, Current: p.current, Total: p.total}); werr != nil {
+			logger.Debug("failed to write progress: %v", werr)
+		}
+	}
+	return n, err
+}
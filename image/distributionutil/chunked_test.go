@@ -0,0 +1,340 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributionutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/progress"
+	"github.com/opencontainers/go-digest"
+)
+
+const testRepoName = "library/test"
+
+// fakeRegistry serves the blob upload and download of the registry API, the hooks inject the failures.
+type fakeRegistry struct {
+	lock    sync.Mutex
+	uploads map[string][]byte
+	blobs   map[digest.Digest][]byte
+	nextID  int
+	// patches records the Content-Range of the PATCH requests.
+	patches []string
+	// onPatch handles the PATCH of upload id with data before it is committed, it returns the number of bytes
+	// committed and the status code, zero for the default.
+	onPatch func(id string, data []byte) (int, int)
+	// noRange makes the download ignore the Range requests.
+	noRange bool
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{uploads: map[string][]byte{}, blobs: map[digest.Digest][]byte{}}
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	uploadPrefix := "/v2/" + testRepoName + "/blobs/uploads/"
+	blobPrefix := "/v2/" + testRepoName + "/blobs/"
+	switch {
+	case r.URL.Path == "/v2/":
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	case r.URL.Path == uploadPrefix && r.Method == http.MethodPost:
+		f.nextID++
+		id := fmt.Sprintf("upload-%d", f.nextID)
+		f.uploads[id] = nil
+		w.Header().Set("Location", uploadPrefix+id)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(r.URL.Path, uploadPrefix):
+		f.serveUpload(w, r, strings.TrimPrefix(r.URL.Path, uploadPrefix))
+	case strings.HasPrefix(r.URL.Path, blobPrefix):
+		data, ok := f.blobs[digest.Digest(strings.TrimPrefix(r.URL.Path, blobPrefix))]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if f.noRange {
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			_, _ = w.Write(data)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeRegistry) serveUpload(w http.ResponseWriter, r *http.Request, id string) {
+	uploaded, ok := f.uploads[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	location := r.URL.Path
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Location", location)
+		last := len(uploaded) - 1
+		if last < 0 {
+			last = 0
+		}
+		w.Header().Set("Range", fmt.Sprintf("0-%d", last))
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPatch:
+		f.patches = append(f.patches, r.Header.Get("Content-Range"))
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Content-Range"), "%d-%d", &start, &end); err != nil || start != len(uploaded) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		committed, code := len(data), http.StatusAccepted
+		if f.onPatch != nil {
+			if n, c := f.onPatch(id, data); c != 0 {
+				committed, code = n, c
+			}
+		}
+		if _, ok := f.uploads[id]; ok {
+			f.uploads[id] = append(uploaded, data[:committed]...)
+		}
+		w.Header().Set("Location", location)
+		w.WriteHeader(code)
+	case http.MethodPut:
+		dgst := digest.Digest(r.URL.Query().Get("digest"))
+		if digest.FromBytes(uploaded) != dgst {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.blobs[dgst] = uploaded
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestRepository(t *testing.T, registry *fakeRegistry) *registryRepository {
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	origin := retryInterval
+	retryInterval = time.Millisecond
+	t.Cleanup(func() {
+		retryInterval = origin
+	})
+	repo, err := NewRepository(context.Background(), types.AuthConfig{}, testRepoName, registryConfig{Domain: server.URL, NonSSL: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo.(*registryRepository)
+}
+
+func newTestBlob(t *testing.T, size int) (string, []byte) {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	path := filepath.Join(t.TempDir(), "blob")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func testConfig() Config {
+	return Config{ProgressOutput: progress.DiscardOutput(), ChunkSize: 10, Retries: 3}
+}
+
+func TestChunkedUploader_Upload(t *testing.T) {
+	tests := []struct {
+		name string
+		// onPatch injects the failure of the n-th PATCH, counted from 1.
+		onPatch func(r *fakeRegistry, n int, id string, data []byte) (int, int)
+		patches []string
+	}{
+		{
+			name:    "chunks",
+			patches: []string{"0-9", "10-19", "20-24"},
+		},
+		{
+			// the offset is synced with the registry, the rest of the chunk is uploaded.
+			name: "partial patch",
+			onPatch: func(r *fakeRegistry, n int, id string, data []byte) (int, int) {
+				if n == 2 {
+					return 4, http.StatusInternalServerError
+				}
+				return 0, 0
+			},
+			patches: []string{"0-9", "10-19", "14-23", "24-24"},
+		},
+		{
+			// the upload expired by registry is restarted from the beginning.
+			name: "upload unknown",
+			onPatch: func(r *fakeRegistry, n int, id string, data []byte) (int, int) {
+				if n == 2 {
+					delete(r.uploads, id)
+					return 0, http.StatusNotFound
+				}
+				return 0, 0
+			},
+			patches: []string{"0-9", "10-19", "0-9", "10-19", "20-24"},
+		},
+		{
+			// the Range 0-0 may be the first byte, the upload is restarted instead of patching from 0 again.
+			name: "one byte committed",
+			onPatch: func(r *fakeRegistry, n int, id string, data []byte) (int, int) {
+				if n == 1 {
+					return 1, http.StatusInternalServerError
+				}
+				return 0, 0
+			},
+			patches: []string{"0-9", "0-9", "10-19", "20-24"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newFakeRegistry()
+			if tt.onPatch != nil {
+				n := 0
+				registry.onPatch = func(id string, data []byte) (int, int) {
+					n++
+					return tt.onPatch(registry, n, id, data)
+				}
+			}
+			repo := newTestRepository(t, registry)
+			path, data := newTestBlob(t, 25)
+			dgst := digest.FromBytes(data)
+			u := newChunkedUploader(repo, testConfig(), "blob", dgst, path, int64(len(data)))
+			if err := u.Upload(context.Background()); err != nil {
+				t.Fatalf("Upload() error = %v", err)
+			}
+			if !bytes.Equal(registry.blobs[dgst], data) {
+				t.Errorf("expected the blob committed to registry")
+			}
+			if strings.Join(registry.patches, ",") != strings.Join(tt.patches, ",") {
+				t.Errorf("patches = %v, want %v", registry.patches, tt.patches)
+			}
+		})
+	}
+}
+
+func TestChunkedUploader_resume(t *testing.T) {
+	registry := newFakeRegistry()
+	repo := newTestRepository(t, registry)
+	path, data := newTestBlob(t, 25)
+	dgst := digest.FromBytes(data)
+
+	// the former push stopped after the first chunk.
+	registry.onPatch = func(id string, data []byte) (int, int) {
+		if len(registry.patches) == 2 {
+			return 0, http.StatusServiceUnavailable
+		}
+		return 0, 0
+	}
+	config := testConfig()
+	config.Retries = 1
+	u := newChunkedUploader(repo, config, "blob", dgst, path, int64(len(data)))
+	if err := u.Upload(context.Background()); err == nil {
+		t.Fatalf("expected the first upload failed")
+	}
+	if _, err := os.Stat(u.statePath); err != nil {
+		t.Fatalf("expected the upload state saved: %v", err)
+	}
+
+	registry.onPatch = nil
+	registry.patches = nil
+	u = newChunkedUploader(repo, testConfig(), "blob", dgst, path, int64(len(data)))
+	if err := u.Upload(context.Background()); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if want := "10-19,20-24"; strings.Join(registry.patches, ",") != want {
+		t.Errorf("patches = %v, want %v resumed from the saved state", registry.patches, want)
+	}
+	if !bytes.Equal(registry.blobs[dgst], data) {
+		t.Errorf("expected the blob committed to registry")
+	}
+	u.Clean()
+	if _, err := os.Stat(u.statePath); !os.IsNotExist(err) {
+		t.Errorf("expected the upload state removed, got %v", err)
+	}
+
+	// the state of another repository is not resumed.
+	if err := ioutil.WriteFile(u.statePath, []byte(`{"repository":"docker.io/library/other","location":"/v2/library/other/blobs/uploads/1"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if location, offset := u.resume(context.Background()); location != "" || offset != 0 {
+		t.Errorf("resume() = %s, %d, want nothing to resume", location, offset)
+	}
+}
+
+func TestFetchBlob(t *testing.T) {
+	_, data := newTestBlob(t, 25)
+	dgst := digest.FromBytes(data)
+	tests := []struct {
+		name    string
+		partial int
+		noRange bool
+	}{
+		{"new", 0, false},
+		{"resume", 10, false},
+		// the registry does not support the range request, the partial blob is downloaded again.
+		{"no range support", 10, true},
+		{"larger than blob", 30, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newFakeRegistry()
+			registry.blobs[dgst] = data
+			registry.noRange = tt.noRange
+			repo := newTestRepository(t, registry)
+			path := filepath.Join(t.TempDir(), dgst.Hex()+partialSuffix)
+			partial := append([]byte{}, data...)
+			if tt.partial > len(data) {
+				partial = append(partial, make([]byte, tt.partial-len(data))...)
+			}
+			if err := ioutil.WriteFile(path, partial[:tt.partial], 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := fetchBlob(context.Background(), repo, testConfig(), "blob", dgst, int64(len(data)), path); err != nil {
+				t.Fatalf("fetchBlob() error = %v", err)
+			}
+			got, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("fetched blob = %v, want %v", got, data)
+			}
+		})
+	}
+
+	registry := newFakeRegistry()
+	repo := newTestRepository(t, registry)
+	err := fetchBlob(context.Background(), repo, testConfig(), "blob", dgst, int64(len(data)), filepath.Join(t.TempDir(), "blob"))
+	if err == nil || !strings.Contains(err.Error(), distribution.ErrBlobUnknown.Error()) {
+		t.Errorf("fetchBlob() of unknown blob error = %v", err)
+	}
+}
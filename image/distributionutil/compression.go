@@ -0,0 +1,56 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributionutil
+
+import (
+	"github.com/docker/distribution/manifest/schema2"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils/archive"
+)
+
+// MediaTypeLayerZstd is the media type of zstd compressed layer.
+const MediaTypeLayerZstd = "application/vnd.oci.image.layer.v1.tar+zstd"
+
+var blobSuffixes = map[archive.Compression]string{
+	archive.Gzip: ".tar.gz",
+	archive.Zstd: ".tar.zst",
+}
+
+// imageCompression returns the layer compression set by sealer build --compression, default is gzip.
+func imageCompression(image *v1.Image) (archive.Compression, error) {
+	return archive.ParseCompression(image.Annotations[common.ImageAnnotationForCompression])
+}
+
+func layerMediaType(c archive.Compression) string {
+	if c == archive.Zstd {
+		return MediaTypeLayerZstd
+	}
+	return schema2.MediaTypeLayer
+}
+
+// layerCompression returns the compression of layer media type, it is empty
+// for unknown media type, and then the compression is detected from the blob.
+func layerCompression(mediaType string) archive.Compression {
+	switch mediaType {
+	case MediaTypeLayerZstd:
+		return archive.Zstd
+	case schema2.MediaTypeLayer, ocispecs.MediaTypeImageLayerGzip:
+		return archive.Gzip
+	}
+	return ""
+}
@@ -23,10 +23,43 @@ import (
 	"github.com/alibaba/sealer/image/store"
 )
 
+const (
+	defaultChunkSize      = 16 * 1024 * 1024
+	defaultRetries        = 5
+	defaultMaxConcurrency = 3
+)
+
 type Config struct {
 	LayerStore     store.LayerStore
 	ProgressOutput progress.Output
 	Named          reference.Named
+	// ChunkSize is the size of each request of layer upload, default is 16MB.
+	ChunkSize int64
+	// Retries is the retry times of each chunk of layer upload and download, default is 5.
+	Retries int
+	// MaxConcurrency is the max number of layers pushed or pulled in parallel, default is 3.
+	MaxConcurrency int
+}
+
+func (c Config) chunkSize() int64 {
+	if c.ChunkSize <= 0 {
+		return defaultChunkSize
+	}
+	return c.ChunkSize
+}
+
+func (c Config) retries() int {
+	if c.Retries <= 0 {
+		return defaultRetries
+	}
+	return c.Retries
+}
+
+func (c Config) maxConcurrency() int {
+	if c.MaxConcurrency <= 0 {
+		return defaultMaxConcurrency
+	}
+	return c.MaxConcurrency
 }
 
 type registryConfig struct {
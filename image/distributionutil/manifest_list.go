@@ -0,0 +1,109 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributionutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/opencontainers/go-digest"
+)
+
+// MediaTypeManifestList is the media type of the manifest list of a multi-platform image.
+const MediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+func init() {
+	manifestListFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		m := new(DeserializedManifestList)
+		err := m.UnmarshalJSON(b)
+		if err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		dgst := digest.FromBytes(b)
+		return m, distribution.Descriptor{Digest: dgst, Size: int64(len(b)), MediaType: MediaTypeManifestList}, err
+	}
+	err := distribution.RegisterManifestSchema(MediaTypeManifestList, manifestListFunc)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to register manifest: %s", err))
+	}
+}
+
+// ManifestList references one manifest per platform.
+type ManifestList struct {
+	manifest.Versioned
+
+	// Manifests are the manifests of each platform, the Platform of descriptor must be set.
+	Manifests []distribution.Descriptor `json:"manifests"`
+}
+
+// DeserializedManifestList wraps ManifestList with its canonical payload.
+type DeserializedManifestList struct {
+	ManifestList
+
+	canonical []byte
+}
+
+// NewManifestList returns the manifest list of descriptors.
+func NewManifestList(descriptors []distribution.Descriptor) (*DeserializedManifestList, error) {
+	m := ManifestList{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 2,
+			MediaType:     MediaTypeManifestList,
+		},
+		Manifests: descriptors,
+	}
+
+	var (
+		deserialized DeserializedManifestList
+		err          error
+	)
+	deserialized.ManifestList = m
+	deserialized.canonical, err = json.MarshalIndent(&m, "", "   ")
+	return &deserialized, err
+}
+
+func (m DeserializedManifestList) References() []distribution.Descriptor {
+	return m.Manifests
+}
+
+func (m *DeserializedManifestList) UnmarshalJSON(b []byte) error {
+	m.canonical = make([]byte, len(b))
+	copy(m.canonical, b)
+
+	var list ManifestList
+	if err := json.Unmarshal(m.canonical, &list); err != nil {
+		return err
+	}
+	if list.MediaType != MediaTypeManifestList {
+		return fmt.Errorf("mediaType in manifest list should be '%s' not '%s'", MediaTypeManifestList, list.MediaType)
+	}
+	m.ManifestList = list
+	return nil
+}
+
+func (m *DeserializedManifestList) MarshalJSON() ([]byte, error) {
+	if len(m.canonical) > 0 {
+		return m.canonical, nil
+	}
+	return nil, errors.New("JSON representation not initialized in DeserializedManifestList")
+}
+
+func (m DeserializedManifestList) Payload() (string, []byte, error) {
+	return m.MediaType, m.canonical, nil
+}
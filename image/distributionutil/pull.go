@@ -18,8 +18,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema2"
@@ -27,8 +27,12 @@ import (
 	"github.com/opencontainers/go-digest"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/platform"
 	"github.com/alibaba/sealer/image/reference"
 	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/sign"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils/archive"
 )
@@ -40,20 +44,135 @@ type Puller interface {
 type ImagePuller struct {
 	config     Config
 	repository distribution.Repository
+	imageStore store.ImageStore
 }
 
+// Pull pulls the image of named, for a multi-platform image the images of all
+// platforms are pulled and saved, the returned image references them.
 func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1.Image, error) {
+	manifest, err := puller.getRemoteManifest(ctx, named)
+	if err != nil {
+		return nil, err
+	}
+
+	verifiedDigest, err := puller.verify(ctx, named, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var image *v1.Image
+	switch m := manifest.(type) {
+	case *schema2.DeserializedManifest:
+		image, err = puller.pullImage(ctx, named, m.Manifest)
+	case *DeserializedManifestList:
+		image, err = puller.pullIndex(ctx, named, m.ManifestList, verifiedDigest)
+	default:
+		return nil, fmt.Errorf("unsupported manifest type %T of image %s", manifest, named.RepoTag())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if verifiedDigest != "" {
+		if image.Annotations == nil {
+			image.Annotations = make(map[string]string)
+		}
+		image.Annotations[common.ImageAnnotationForVerified] = verifiedDigest.String()
+		if err = sign.RecordVerified(image, verifiedDigest); err != nil {
+			return nil, fmt.Errorf("failed to record the verified digest of image %s: %v", named.Raw(), err)
+		}
+	}
+	return image, nil
+}
+
+// verify checks the signature of manifest if trusted keys are configured,
+// returns the verified manifest digest, empty if it needs no verification.
+func (puller *ImagePuller) verify(ctx context.Context, named reference.Named, manifest distribution.Manifest) (digest.Digest, error) {
+	keys, err := sign.TrustedKeys()
+	if err != nil {
+		return "", fmt.Errorf("failed to load trusted keys: %v", err)
+	}
+	if len(keys) == 0 {
+		return "", nil
+	}
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return "", err
+	}
+	manifestDigest := digest.FromBytes(payload)
+	signatures, err := GetSignatures(ctx, puller.repository, manifestDigest)
+	if err == nil {
+		err = sign.Verify(keys, signatures, named.Domain()+"/"+named.Repo(), manifestDigest)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to verify image %s: %v, use --insecure-skip-verify to skip it", named.Raw(), err)
+	}
+	return manifestDigest, nil
+}
+
+// pullIndex pulls the images of all platforms of manifestList, they are verified
+// by verifiedDigest of manifestList if it is not empty.
+func (puller *ImagePuller) pullIndex(ctx context.Context, named reference.Named, manifestList ManifestList, verifiedDigest digest.Digest) (*v1.Image, error) {
+	ms, err := puller.repository.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests []v1.ImageManifest
+	for _, descriptor := range manifestList.Manifests {
+		if descriptor.Platform == nil {
+			continue
+		}
+		p := platform.Normalize(v1.Platform{
+			OS:           descriptor.Platform.OS,
+			Architecture: descriptor.Platform.Architecture,
+			Variant:      descriptor.Platform.Variant,
+			OSVersion:    descriptor.Platform.OSVersion,
+		})
+		manifest, err := ms.Get(ctx, descriptor.Digest)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := manifest.(*schema2.DeserializedManifest)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse manifest of platform %s to DeserializedManifest", platform.Format(p))
+		}
+		image, err := puller.pullImage(ctx, named, m.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		platformName, err := platform.ImageName(named.Raw(), p)
+		if err != nil {
+			return nil, err
+		}
+		if verifiedDigest != "" {
+			if err = sign.RecordVerified(image, verifiedDigest); err != nil {
+				return nil, fmt.Errorf("failed to record the verified digest of image %s: %v", platformName, err)
+			}
+		}
+		if err = puller.imageStore.Save(*image, platformName); err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, v1.ImageManifest{Platform: p, Name: platformName, ID: image.Spec.ID})
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no platform found in manifest list of image %s", named.RepoTag())
+	}
+
+	index, err := platform.NewIndexImage(named.Raw(), manifests)
+	if err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+func (puller *ImagePuller) pullImage(ctx context.Context, named reference.Named, manifest schema2.Manifest) (*v1.Image, error) {
 	var (
 		layerStore = puller.config.LayerStore
 		layers     = []v1.Layer{}
 		eg         *errgroup.Group
+		sem        = make(chan struct{}, puller.config.maxConcurrency())
 	)
 
-	manifest, err := puller.getRemoteManifest(ctx, named)
-	if err != nil {
-		return nil, err
-	}
-
 	v1Image, err := puller.getRemoteImageMetadata(ctx, manifest.Config.Digest)
 	if err != nil {
 		return nil, err
@@ -80,6 +199,8 @@ func (puller *ImagePuller) Pull(ctx context.Context, named reference.Named) (*v1
 		// we take hash of layer as real layer id,  hash of descriptor is just
 		// a identifier for remote data
 		eg.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
 			// roLayer now does not exist, new one
 			// descriptor.Size is temp size for this layer
 			// real size will be set within downloadLayer
@@ -124,48 +245,62 @@ func (puller *ImagePuller) downloadLayer(ctx context.Context, layer store.Layer,
 		return nil
 	}
 
-	bs := repo.Blobs(ctx)
-	layerReader, err := bs.Open(ctx, descriptor.Digest)
+	registryRepo, ok := repo.(*registryRepository)
+	if !ok {
+		return fmt.Errorf("repository %s does not support resumable download", repo.Named())
+	}
+	if err = os.MkdirAll(blobCacheDir, common.FileMode0755); err != nil {
+		return err
+	}
+	// the partial blob is kept on failure, the next pull resumes from it.
+	blobPath := filepath.Join(blobCacheDir, descriptor.Digest.Hex()+partialSuffix)
+	if err = fetchBlob(ctx, registryRepo, puller.config, layer.SimpleID(), descriptor.Digest, descriptor.Size, blobPath); err != nil {
+		progress.Update(progressOut, layer.SimpleID(), "pull failed")
+		return err
+	}
+	defer func() {
+		if err := os.Remove(blobPath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("failed to remove blob %s: %v", blobPath, err)
+		}
+	}()
+
+	dgst, _, err := fileDigest(blobPath)
 	if err != nil {
 		return err
 	}
-	defer layerReader.Close()
+	if dgst != descriptor.Digest {
+		return fmt.Errorf("digest verified failed for %s", layer.ID())
+	}
 
-	digester := digest.Canonical.Digester()
-	layerDownloadReader := ioutil.NopCloser(io.TeeReader(layerReader, digester.Hash()))
-	progressReader := progress.NewProgressReader(layerDownloadReader, progressOut, descriptor.Size, layer.SimpleID(), "pulling")
-	size, err := archive.Decompress(progressReader, backend.LayerDataDir(layer.ID().ToDigest()), archive.Options{Compress: true})
+	blob, err := os.Open(filepath.Clean(blobPath))
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	progress.Update(progressOut, layer.SimpleID(), "extracting")
+	size, err := archive.Decompress(blob, backend.LayerDataDir(layer.ID().ToDigest()), archive.Options{
+		Compress:    true,
+		Compression: layerCompression(descriptor.MediaType),
+	})
 	if err != nil {
 		progress.Update(progressOut, layer.SimpleID(), err.Error())
 		return err
 	}
 	// update rolayer size for storing the info under layerdb
 	layer.SetSize(size)
-	if digester.Digest() != descriptor.Digest {
-		return fmt.Errorf("digest verified failed for %s", layer.ID())
-	}
 	progress.Update(progressOut, layer.SimpleID(), "pull completed")
 	return nil
 }
 
 // TODO make a manifest store do this job
-func (puller *ImagePuller) getRemoteManifest(context context.Context, named reference.Named) (schema2.Manifest, error) {
+func (puller *ImagePuller) getRemoteManifest(context context.Context, named reference.Named) (distribution.Manifest, error) {
 	repo := puller.repository
 	ms, err := repo.Manifests(context)
 	if err != nil {
-		return schema2.Manifest{}, err
-	}
-
-	manifest, err := ms.Get(context, "", distribution.WithTagOption{Tag: named.Tag()})
-	if err != nil {
-		return schema2.Manifest{}, err
+		return nil, err
 	}
 
-	_, ok := manifest.(*schema2.DeserializedManifest)
-	if !ok {
-		return schema2.Manifest{}, fmt.Errorf("failed to parse manifest %s to DeserializedManifest", named.RepoTag())
-	}
-	return manifest.(*schema2.DeserializedManifest).Manifest, nil
+	return ms.Get(context, "", distribution.WithTagOption{Tag: named.Tag()})
 }
 
 // not docker image, get sealer image metadata
@@ -187,8 +322,14 @@ func NewPuller(named reference.Named, config Config) (Puller, error) {
 		return nil, err
 	}
 
+	is, err := store.NewDefaultImageStore()
+	if err != nil {
+		return nil, err
+	}
+
 	return &ImagePuller{
 		repository: repo,
 		config:     config,
+		imageStore: is,
 	}, nil
 }
@@ -19,17 +19,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/docker/docker/pkg/progress"
 	"github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/platform"
 	"github.com/alibaba/sealer/image/reference"
 	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
 	v1 "github.com/alibaba/sealer/types/api/v1"
 	"github.com/alibaba/sealer/utils/archive"
 )
@@ -45,18 +51,81 @@ type ImagePusher struct {
 }
 
 func (pusher *ImagePusher) Push(ctx context.Context, named reference.Named) error {
+	image, err := pusher.imageStore.GetByName(named.Raw())
+	if err != nil {
+		return err
+	}
+	compression, err := imageCompression(image)
+	if err != nil {
+		return err
+	}
+	if platform.IsIndex(image) {
+		return pusher.pushIndex(ctx, named, image, compression)
+	}
+	_, err = pusher.pushImage(ctx, named, image, compression)
+	return err
+}
+
+// pushIndex pushes the image of each platform with the platform tag, then
+// the manifest list which references them is pushed with the tag of named.
+// The compression of index is used if the platform image has no compression set.
+func (pusher *ImagePusher) pushIndex(ctx context.Context, named reference.Named, index *v1.Image, compression archive.Compression) error {
+	var descriptors []distribution.Descriptor
+	for _, m := range index.Spec.Manifests {
+		image, err := pusher.imageStore.GetByName(m.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get image %s of platform %s: %v", m.Name, platform.Format(m.Platform), err)
+		}
+		platformName, err := platform.ImageName(named.Raw(), m.Platform)
+		if err != nil {
+			return err
+		}
+		platformNamed, err := reference.ParseToNamed(platformName)
+		if err != nil {
+			return err
+		}
+		platformCompression := compression
+		if _, ok := image.Annotations[common.ImageAnnotationForCompression]; ok {
+			if platformCompression, err = imageCompression(image); err != nil {
+				return err
+			}
+		}
+		descriptor, err := pusher.pushImage(ctx, platformNamed, image, platformCompression)
+		if err != nil {
+			return err
+		}
+		descriptor.Platform = &ocispecs.Platform{
+			OS:           m.Platform.OS,
+			Architecture: m.Platform.Architecture,
+			Variant:      m.Platform.Variant,
+			OSVersion:    m.Platform.OSVersion,
+		}
+		descriptors = append(descriptors, descriptor)
+	}
+
+	manifestList, err := NewManifestList(descriptors)
+	if err != nil {
+		return err
+	}
+	ms, err := pusher.repository.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = ms.Put(ctx, manifestList, distribution.WithTag(named.Tag()))
+	return err
+}
+
+// pushImage pushes the layers compressed by compression and manifest of image,
+// returns the descriptor of the manifest.
+func (pusher *ImagePusher) pushImage(ctx context.Context, named reference.Named, image *v1.Image, compression archive.Compression) (distribution.Descriptor, error) {
 	var (
 		layerStore   = pusher.config.LayerStore
 		pushedLayers = map[string]distribution.Descriptor{}
 		pushMux      sync.Mutex
 		eg           *errgroup.Group
+		sem          = make(chan struct{}, pusher.config.maxConcurrency())
 	)
 
-	image, err := pusher.imageStore.GetByName(named.Raw())
-	if err != nil {
-		return err
-	}
-
 	eg, _ = errgroup.WithContext(context.Background())
 	for _, l := range image.Spec.Layers {
 		if l.ID == "" {
@@ -64,17 +133,19 @@ func (pusher *ImagePusher) Push(ctx context.Context, named reference.Named) erro
 		}
 		err := l.ID.Validate()
 		if err != nil {
-			return fmt.Errorf("layer hash %s validate failed, err: %s", l.ID, err)
+			return distribution.Descriptor{}, fmt.Errorf("layer hash %s validate failed, err: %s", l.ID, err)
 		}
 
 		// this scope value, safe to pass into eg.Go
 		roLayer := layerStore.Get(store.LayerID(l.ID))
 		if roLayer == nil {
-			return fmt.Errorf("failed to put image %s, layer %s not exists locally", named.Raw(), l.ID.String())
+			return distribution.Descriptor{}, fmt.Errorf("failed to put image %s, layer %s not exists locally", named.Raw(), l.ID.String())
 		}
 
 		eg.Go(func() error {
-			layerDescriptor, layerErr := pusher.uploadLayer(ctx, roLayer)
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			layerDescriptor, layerErr := pusher.uploadLayer(ctx, roLayer, compression)
 			if layerErr != nil {
 				return layerErr
 			}
@@ -86,9 +157,9 @@ func (pusher *ImagePusher) Push(ctx context.Context, named reference.Named) erro
 			return layerStore.AddDistributionMetadata(roLayer.ID(), named, layerDescriptor.Digest)
 		})
 	}
-	err = eg.Wait()
+	err := eg.Wait()
 	if err != nil {
-		return fmt.Errorf("failed to push layers of %s, err: %s", named.Raw(), err)
+		return distribution.Descriptor{}, fmt.Errorf("failed to push layers of %s, err: %s", named.Raw(), err)
 	}
 
 	// for making descriptors have same order with image layers
@@ -108,26 +179,29 @@ func (pusher *ImagePusher) Push(ctx context.Context, named reference.Named) erro
 		layerDescriptors = append(layerDescriptors, layerDescriptor)
 	}
 	if len(layerDescriptors) != len(pushedLayers) {
-		return errors.New("failed to push image, the number of layerDescriptors and pushedLayers mismatch")
+		return distribution.Descriptor{}, errors.New("failed to push image, the number of layerDescriptors and pushedLayers mismatch")
 	}
 	// push sealer image metadata to registry
 	configJSON, err := pusher.putManifestConfig(ctx, *image)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
 	return pusher.putManifest(ctx, configJSON, named, layerDescriptors)
 }
 
-func (pusher *ImagePusher) uploadLayer(ctx context.Context, roLayer store.Layer) (distribution.Descriptor, error) {
+func (pusher *ImagePusher) uploadLayer(ctx context.Context, roLayer store.Layer, compression archive.Compression) (distribution.Descriptor, error) {
 	var (
-		err                      error
-		layerContentStream       io.ReadCloser
 		repo                     = pusher.repository
 		progressChanOut          = pusher.config.ProgressOutput
 		layerDistributionDigests = roLayer.DistributionMetadata()
 	)
 
+	registryRepo, ok := repo.(*registryRepository)
+	if !ok {
+		return distribution.Descriptor{}, fmt.Errorf("repository %s does not support chunked upload", repo.Named())
+	}
+
 	bs := repo.Blobs(ctx)
 	// if layerDistributionDigests is empty, we take the layer inexistence in the registry
 	// check all candidates
@@ -142,51 +216,77 @@ func (pusher *ImagePusher) uploadLayer(ctx context.Context, roLayer store.Layer)
 		}
 	}
 
-	// pack layer files into tar.gz
+	// pack layer files into compressed tar
 	progress.Update(progressChanOut, roLayer.SimpleID(), "preparing")
-	layerContentStream, err = roLayer.TarStream()
+	blobPath, layerContentDigest, realSize, err := prepareLayerBlob(roLayer, compression)
 	if err != nil {
-		return distribution.Descriptor{}, errors.Errorf("failed to get tar stream for layer %s, err: %s", roLayer.ID(), err)
+		return distribution.Descriptor{}, errors.Errorf("failed to compress layer %s, err: %s", roLayer.ID(), err)
+	}
+	// the blob may be committed by an interrupted push.
+	if remoteLayerDescriptor, err := bs.Stat(ctx, layerContentDigest); err == nil {
+		progress.Message(progressChanOut, roLayer.SimpleID(), "already exists")
+		cleanLayerBlob(blobPath)
+		remoteLayerDescriptor.MediaType = layerMediaType(compression)
+		return remoteLayerDescriptor, nil
 	}
-	//progress.NewProgressReader will close layerContentStream
-	progressReader := progress.NewProgressReader(layerContentStream, progressChanOut, roLayer.Size(), roLayer.SimpleID(), "pushing")
-	uploadStream, _ := archive.GzipCompress(progressReader)
-	defer func() {
-		err := layerContentStream.Close()
-		if err != nil {
-			return
-		}
-		err = uploadStream.Close()
-		if err != nil {
-			return
-		}
-	}()
 
-	layerUploader, err := bs.Create(ctx)
-	if err != nil {
+	uploader := newChunkedUploader(registryRepo, pusher.config, roLayer.SimpleID(), layerContentDigest, blobPath, realSize)
+	if err = uploader.Upload(ctx); err != nil {
 		progress.Update(progressChanOut, roLayer.SimpleID(), "push failed")
-		return distribution.Descriptor{}, err
+		return distribution.Descriptor{}, fmt.Errorf("failed to upload layer %s, err: %s", roLayer.ID(), err)
 	}
-	defer layerUploader.Close()
+	uploader.Clean()
+	cleanLayerBlob(blobPath)
 
-	// calculate hash of layer content stream
-	digester := digest.Canonical.Digester()
-	tee := io.TeeReader(uploadStream, digester.Hash())
-	realSize, err := layerUploader.ReadFrom(tee)
+	progress.Update(progressChanOut, roLayer.SimpleID(), "push completed")
+	return buildBlobs(layerContentDigest, realSize, layerMediaType(compression)), nil
+}
+
+// prepareLayerBlob compresses the layer to the blob cache, the compressed blob
+// is kept until the push succeeds, so that a retried push uploads the same content.
+func prepareLayerBlob(roLayer store.Layer, compression archive.Compression) (string, digest.Digest, int64, error) {
+	blobPath := filepath.Join(blobCacheDir, roLayer.ID().ToDigest().Hex()+blobSuffixes[compression])
+	if dgst, size, err := fileDigest(blobPath); err == nil {
+		return blobPath, dgst, size, nil
+	}
+	if err := os.MkdirAll(blobCacheDir, common.FileMode0755); err != nil {
+		return "", "", 0, err
+	}
+
+	layerContentStream, err := roLayer.TarStream()
 	if err != nil {
-		return distribution.Descriptor{}, fmt.Errorf("failed to upload layer %s, err: %s", roLayer.ID(), err)
+		return "", "", 0, err
+	}
+	defer layerContentStream.Close()
+	compressed, err := archive.CompressStream(layerContentStream, compression)
+	if err != nil {
+		return "", "", 0, err
 	}
+	defer compressed.Close()
 
-	layerContentDigest := digester.Digest()
-	if _, err = layerUploader.Commit(ctx, distribution.Descriptor{Digest: layerContentDigest}); err != nil {
-		return distribution.Descriptor{}, fmt.Errorf("failed to commit layer to registry, err: %s", err)
+	tmpPath := blobPath + partialSuffix
+	f, err := os.OpenFile(filepath.Clean(tmpPath), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, common.FileMode0644)
+	if err != nil {
+		return "", "", 0, err
+	}
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(f, digester.Hash()), compressed)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", "", 0, err
 	}
+	return blobPath, digester.Digest(), size, os.Rename(tmpPath, blobPath)
+}
 
-	progress.Update(progressChanOut, roLayer.SimpleID(), "push completed")
-	return buildBlobs(layerContentDigest, realSize, roLayer.MediaType()), nil
+func cleanLayerBlob(blobPath string) {
+	if err := os.Remove(blobPath); err != nil && !os.IsNotExist(err) {
+		logger.Warn("failed to remove layer blob %s: %v", blobPath, err)
+	}
 }
 
-func (pusher *ImagePusher) putManifest(ctx context.Context, configJSON []byte, named reference.Named, layerDescriptors []distribution.Descriptor) error {
+func (pusher *ImagePusher) putManifest(ctx context.Context, configJSON []byte, named reference.Named, layerDescriptors []distribution.Descriptor) (distribution.Descriptor, error) {
 	var (
 		bs   = &blobService{descriptors: map[digest.Digest]distribution.Descriptor{}}
 		repo = pusher.repository
@@ -201,23 +301,30 @@ func (pusher *ImagePusher) putManifest(ctx context.Context, configJSON []byte, n
 	for _, d := range layerDescriptors {
 		err := manifestBuilder.AppendReference(d)
 		if err != nil {
-			return err
+			return distribution.Descriptor{}, err
 		}
 	}
 
 	manifest, err := manifestBuilder.Build(ctx)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
 	ms, err := repo.Manifests(ctx)
 	if err != nil {
-		return err
+		return distribution.Descriptor{}, err
 	}
 
 	putOptions := []distribution.ManifestServiceOption{distribution.WithTag(named.Tag())}
-	_, err = ms.Put(ctx, manifest, putOptions...)
-	return err
+	dgst, err := ms.Put(ctx, manifest, putOptions...)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	return buildBlobs(dgst, int64(len(payload)), mediaType), nil
 }
 
 func (pusher *ImagePusher) putManifestConfig(ctx context.Context, image v1.Image) ([]byte, error) {
@@ -25,6 +25,7 @@ import (
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/reference"
+	v2 "github.com/docker/distribution/registry/api/v2"
 	dockerRegistryClient "github.com/docker/distribution/registry/client"
 	dockerAuth "github.com/docker/distribution/registry/client/auth"
 	dockerTransport "github.com/docker/distribution/registry/client/transport"
@@ -104,7 +105,27 @@ func NewRepository(ctx context.Context, authConfig types.AuthConfig, repoName st
 		return nil, err
 	}
 
-	return dockerRegistryClient.NewRepository(repoNameRef, rurl.String(), tr)
+	repo, err := dockerRegistryClient.NewRepository(repoNameRef, rurl.String(), tr)
+	if err != nil {
+		return nil, err
+	}
+	ub, err := v2.NewURLBuilderFromString(rurl.String(), false)
+	if err != nil {
+		return nil, err
+	}
+	return &registryRepository{
+		Repository: repo,
+		client:     &http.Client{Transport: tr},
+		ub:         ub,
+	}, nil
+}
+
+// registryRepository keeps the authorized client of repository, which is used
+// by chunked blob upload as the client of distribution does not support resuming.
+type registryRepository struct {
+	distribution.Repository
+	client *http.Client
+	ub     *v2.URLBuilder
 }
 
 type existingTokenHandler struct {
@@ -0,0 +1,154 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distributionutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/pkg/sign"
+)
+
+// the signature is stored like cosign: a manifest tagged sha256-<hex>.sig,
+// each layer of it is a signed payload with the signature in annotation.
+const (
+	SignatureMediaType  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+	signatureTagSuffix  = ".sig"
+	emptyConfig         = "{}"
+	// CertificateAnnotation carries the Fulcio certificate of the keyless signatures of cosign.
+	CertificateAnnotation = "dev.sigstore.cosign/certificate"
+)
+
+// SignatureTag returns the tag of the signatures of manifestDigest.
+func SignatureTag(manifestDigest digest.Digest) string {
+	return fmt.Sprintf("%s-%s%s", manifestDigest.Algorithm(), manifestDigest.Hex(), signatureTagSuffix)
+}
+
+// GetManifestDigest returns the digest of the manifest of named in the registry.
+func GetManifestDigest(ctx context.Context, repo distribution.Repository, named reference.Named) (digest.Digest, error) {
+	descriptor, err := repo.Tags(ctx).Get(ctx, named.Tag())
+	if err != nil {
+		return "", fmt.Errorf("failed to get manifest of %s: %v", named.RepoTag(), err)
+	}
+	return descriptor.Digest, nil
+}
+
+// GetSignatures returns the signatures of manifestDigest stored in repo.
+func GetSignatures(ctx context.Context, repo distribution.Repository, manifestDigest digest.Digest) ([]sign.Signature, error) {
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ms.Get(ctx, "", distribution.WithTagOption{Tag: SignatureTag(manifestDigest)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signatures of %s: %v", manifestDigest, err)
+	}
+
+	var (
+		signatures []sign.Signature
+		bs         = repo.Blobs(ctx)
+	)
+	for _, d := range manifest.References() {
+		if d.MediaType != SignatureMediaType || d.Annotations[SignatureAnnotation] == "" {
+			continue
+		}
+		p, err := bs.Get(ctx, d.Digest)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, sign.Signature{
+			Payload:     p,
+			Signature:   d.Annotations[SignatureAnnotation],
+			Certificate: d.Annotations[CertificateAnnotation],
+		})
+	}
+	return signatures, nil
+}
+
+// PutSignature adds signature to the signatures of manifestDigest in repo.
+func PutSignature(ctx context.Context, repo distribution.Repository, manifestDigest digest.Digest, signature sign.Signature) error {
+	var (
+		bs     = repo.Blobs(ctx)
+		layers []distribution.Descriptor
+	)
+	ms, err := repo.Manifests(ctx)
+	if err != nil {
+		return err
+	}
+	// keep the signatures signed by other keys.
+	if existing, err := ms.Get(ctx, "", distribution.WithTagOption{Tag: SignatureTag(manifestDigest)}); err == nil {
+		for _, d := range existing.References() {
+			if d.MediaType == SignatureMediaType {
+				layers = append(layers, d)
+			}
+		}
+	}
+
+	layer, err := bs.Put(ctx, SignatureMediaType, signature.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to upload signature payload: %v", err)
+	}
+	layer.MediaType = SignatureMediaType
+	layer.Annotations = map[string]string{SignatureAnnotation: signature.Signature}
+	layers = append(layers, layer)
+
+	config, err := bs.Put(ctx, schema2.MediaTypeImageConfig, []byte(emptyConfig))
+	if err != nil {
+		return err
+	}
+	config.MediaType = schema2.MediaTypeImageConfig
+
+	manifest, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = ms.Put(ctx, manifest, distribution.WithTag(SignatureTag(manifestDigest)))
+	return err
+}
+
+// Sign signs the manifest of named in the registry with key.
+func Sign(ctx context.Context, named reference.Named, keyFile string) (digest.Digest, error) {
+	key, err := sign.LoadPrivateKey(keyFile)
+	if err != nil {
+		return "", err
+	}
+	repo, err := NewV2Repository(named, "push", "pull")
+	if err != nil {
+		return "", err
+	}
+	manifestDigest, err := GetManifestDigest(ctx, repo, named)
+	if err != nil {
+		return "", err
+	}
+	payload, err := sign.NewPayload(named.Domain()+"/"+named.Repo(), manifestDigest)
+	if err != nil {
+		return "", err
+	}
+	sig, err := sign.Sign(key, payload)
+	if err != nil {
+		return "", err
+	}
+	return manifestDigest, PutSignature(ctx, repo, manifestDigest, sign.Signature{Payload: payload, Signature: sig})
+}
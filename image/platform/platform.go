@@ -0,0 +1,230 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/image/store"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/opencontainers/go-digest"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DefaultOS   = "linux"
+	DefaultArch = "amd64"
+)
+
+// the machine hardware names reported by `uname -m`.
+var machines = map[string]v1.Platform{
+	"x86_64":      {OS: DefaultOS, Architecture: "amd64"},
+	"amd64":       {OS: DefaultOS, Architecture: "amd64"},
+	"aarch64":     {OS: DefaultOS, Architecture: "arm64"},
+	"arm64":       {OS: DefaultOS, Architecture: "arm64"},
+	"armv7l":      {OS: DefaultOS, Architecture: "arm", Variant: "v7"},
+	"armv6l":      {OS: DefaultOS, Architecture: "arm", Variant: "v6"},
+	"ppc64le":     {OS: DefaultOS, Architecture: "ppc64le"},
+	"s390x":       {OS: DefaultOS, Architecture: "s390x"},
+	"riscv64":     {OS: DefaultOS, Architecture: "riscv64"},
+	"loongarch64": {OS: DefaultOS, Architecture: "loong64"},
+}
+
+// Default returns the platform of the current host.
+func Default() v1.Platform {
+	return Normalize(v1.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH})
+}
+
+// IsZero reports whether no platform is specified.
+func IsZero(p v1.Platform) bool {
+	return p.Architecture == ""
+}
+
+// Parse parses a platform like "linux/amd64" or "linux/arm/v7", the os defaults to linux.
+func Parse(s string) (v1.Platform, error) {
+	var p v1.Platform
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "/")
+	switch len(parts) {
+	case 1:
+		p.OS, p.Architecture = DefaultOS, parts[0]
+	case 2:
+		p.OS, p.Architecture = parts[0], parts[1]
+	case 3:
+		p.OS, p.Architecture, p.Variant = parts[0], parts[1], parts[2]
+	default:
+		return p, fmt.Errorf("invalid platform %q, must be os/arch[/variant]", s)
+	}
+	if p.OS == "" || p.Architecture == "" {
+		return p, fmt.Errorf("invalid platform %q, must be os/arch[/variant]", s)
+	}
+	if p.OS != DefaultOS {
+		return p, fmt.Errorf("unsupported os %s of platform %q, only linux is supported", p.OS, s)
+	}
+	return Normalize(p), nil
+}
+
+// ParseList parses comma separated platforms, duplicates are removed.
+func ParseList(s string) ([]v1.Platform, error) {
+	var (
+		platforms []v1.Platform
+		seen      = map[string]bool{}
+	)
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		p, err := Parse(item)
+		if err != nil {
+			return nil, err
+		}
+		if seen[Format(p)] {
+			continue
+		}
+		seen[Format(p)] = true
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// Normalize fills the default os and variant, so that platforms could be compared directly.
+func Normalize(p v1.Platform) v1.Platform {
+	if m, ok := machines[p.Architecture]; ok && p.Architecture != m.Architecture {
+		p.Architecture = m.Architecture
+		if p.Variant == "" {
+			p.Variant = m.Variant
+		}
+	}
+	if p.OS == "" {
+		p.OS = DefaultOS
+	}
+	switch p.Architecture {
+	case "arm64":
+		// v8 is the only variant of arm64 in use.
+		if p.Variant == "v8" {
+			p.Variant = ""
+		}
+	case "arm":
+		if p.Variant == "" {
+			p.Variant = "v7"
+		}
+	}
+	return p
+}
+
+// Format returns the platform in os/arch[/variant] form.
+func Format(p v1.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Match reports whether the two platforms are the same one.
+func Match(a, b v1.Platform) bool {
+	a, b = Normalize(a), Normalize(b)
+	return a.OS == b.OS && a.Architecture == b.Architecture && a.Variant == b.Variant
+}
+
+// FromMachine converts the output of `uname -m` to a platform.
+func FromMachine(machine string) (v1.Platform, error) {
+	p, ok := machines[strings.TrimSpace(machine)]
+	if !ok {
+		return p, fmt.Errorf("unsupported machine hardware %q", machine)
+	}
+	return p, nil
+}
+
+// ImageName returns the name of the image built for platform p, it is tagged
+// in the same repo as name with the platform as tag suffix, like kubernetes:v1.19.8-arm64.
+func ImageName(name string, p v1.Platform) (string, error) {
+	named, err := reference.ParseToNamed(name)
+	if err != nil {
+		return "", err
+	}
+	suffix := p.Architecture
+	if p.Variant != "" {
+		suffix += "-" + p.Variant
+	}
+	return strings.TrimSuffix(named.Raw(), ":"+named.Tag()) + ":" + named.Tag() + "-" + suffix, nil
+}
+
+// NewIndexImage returns a multi-platform image which references the given platform images.
+func NewIndexImage(name string, manifests []v1.ImageManifest) (v1.Image, error) {
+	manifests = append([]v1.ImageManifest{}, manifests...)
+	sort.Slice(manifests, func(i, j int) bool {
+		return Format(manifests[i].Platform) < Format(manifests[j].Platform)
+	})
+	image := v1.Image{}
+	image.Kind = "Image"
+	image.Name = name
+	image.Spec.Manifests = manifests
+
+	data, err := yaml.Marshal(image)
+	if err != nil {
+		return image, err
+	}
+	image.Spec.ID = digest.FromBytes(data).Hex()
+	return image, nil
+}
+
+// IsIndex reports whether the image is a multi-platform image.
+func IsIndex(image *v1.Image) bool {
+	return image != nil && len(image.Spec.Manifests) > 0
+}
+
+// Select returns the manifest of image index for platform p.
+func Select(manifests []v1.ImageManifest, p v1.Platform) (v1.ImageManifest, error) {
+	var supported []string
+	for _, m := range manifests {
+		if Match(m.Platform, p) {
+			return m, nil
+		}
+		supported = append(supported, Format(m.Platform))
+	}
+	return v1.ImageManifest{}, fmt.Errorf("no image for platform %s, supported platforms are %s",
+		Format(p), strings.Join(supported, ","))
+}
+
+// Resolve returns the image for platform p if image is a multi-platform image,
+// otherwise the image itself is returned.
+func Resolve(imageStore store.ImageStore, image *v1.Image, p v1.Platform) (*v1.Image, error) {
+	if !IsIndex(image) {
+		return image, nil
+	}
+	m, err := Select(image.Spec.Manifests, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve image %s: %v", image.Name, err)
+	}
+	return imageStore.GetByName(m.Name)
+}
+
+// ResolveDefault resolves a multi-platform image to the image of the host platform, or the
+// first platform if the host one is not built. It is used to read the content shared by all
+// platforms, like Clusterfile.
+func ResolveDefault(imageStore store.ImageStore, image *v1.Image) (*v1.Image, error) {
+	if !IsIndex(image) {
+		return image, nil
+	}
+	m, err := Select(image.Spec.Manifests, Default())
+	if err != nil {
+		m = image.Spec.Manifests[0]
+	}
+	return imageStore.GetByName(m.Name)
+}
@@ -0,0 +1,91 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package platform
+
+import (
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"single", "linux/amd64", []string{"linux/amd64"}, false},
+		{"multi", "linux/amd64,linux/arm64", []string{"linux/amd64", "linux/arm64"}, false},
+		{"arch only", "arm64", []string{"linux/arm64"}, false},
+		{"arm64 v8 is default variant", "linux/arm64/v8,linux/arm64", []string{"linux/arm64"}, false},
+		{"arm default variant", "linux/arm", []string{"linux/arm/v7"}, false},
+		{"alias", "linux/x86_64", []string{"linux/amd64"}, false},
+		{"unsupported os", "windows/amd64", nil, true},
+		{"invalid", "linux/arm/v7/x", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseList(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseList() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if Format(got[i]) != tt.want[i] {
+					t.Errorf("ParseList()[%d] = %s, want %s", i, Format(got[i]), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestImageName(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform v1.Platform
+		want     string
+	}{
+		{"kubernetes:v1.19.8", v1.Platform{OS: "linux", Architecture: "amd64"}, "kubernetes:v1.19.8-amd64"},
+		{"registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes", v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"},
+			"registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:latest-arm-v7"},
+		{"localhost:5000/kubernetes:v1", v1.Platform{OS: "linux", Architecture: "arm64"}, "localhost:5000/kubernetes:v1-arm64"},
+	}
+	for _, tt := range tests {
+		got, err := ImageName(tt.name, tt.platform)
+		if err != nil {
+			t.Fatalf("ImageName(%s) error: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("ImageName(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	manifests := []v1.ImageManifest{
+		{Platform: v1.Platform{OS: "linux", Architecture: "amd64"}, Name: "k8s:v1-amd64"},
+		{Platform: v1.Platform{OS: "linux", Architecture: "arm64"}, Name: "k8s:v1-arm64"},
+	}
+	m, err := Select(manifests, v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"})
+	if err != nil || m.Name != "k8s:v1-arm64" {
+		t.Errorf("Select() = %v, %v, want k8s:v1-arm64", m, err)
+	}
+	if _, err = Select(manifests, v1.Platform{OS: "linux", Architecture: "ppc64le"}); err == nil {
+		t.Errorf("Select() of unsupported platform should fail")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+// the layers and tmp dirs modified within pruneGracePeriod may be used by a
+// running build, they are kept by prune.
+const pruneGracePeriod = time.Hour
+
+type PruneReport struct {
+	Layers    []string
+	Dirs      []string
+	Reclaimed int64
+}
+
+// Prune removes the layers not referenced by any image and the temporary dirs
+// under /var/lib/sealer/tmp which are not mounted.
+func Prune() (*PruneReport, error) {
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return nil, err
+	}
+	layerStore, err := store.NewDefaultLayerStore()
+	if err != nil {
+		return nil, err
+	}
+	layerRefs, err := layerReferences(imageStore)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{}
+	layerIDs, err := listLayerIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, layerID := range layerIDs {
+		layerDir := filepath.Join(common.DefaultLayerDir, layerID.ToDigest().Hex())
+		if layerRefs[layerID] > 0 || modifiedWithin(layerDir, pruneGracePeriod) {
+			continue
+		}
+		size, _ := utils.GetFileSize(layerDir)
+		if err = deleteLayer(layerStore, layerID); err != nil {
+			logger.Warn("failed to delete layer %s: %v", layerID, err)
+			continue
+		}
+		report.Layers = append(report.Layers, layerID.String())
+		report.Reclaimed += size
+	}
+
+	dirs, err := ioutil.ReadDir(common.DefaultTmpDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	mounts, err := mountedPaths()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(common.DefaultTmpDir, dir.Name())
+		if time.Since(dir.ModTime()) < pruneGracePeriod || isMounted(mounts, path) {
+			logger.Debug("skip pruning %s which may be in use", path)
+			continue
+		}
+		size, _ := utils.GetFileSize(path)
+		if err = os.RemoveAll(path); err != nil {
+			logger.Warn("failed to remove %s: %v", path, err)
+			continue
+		}
+		report.Dirs = append(report.Dirs, path)
+		report.Reclaimed += size
+	}
+	return report, nil
+}
+
+// layerReferences counts the images referencing each layer, the images of
+// excludes are not counted.
+func layerReferences(imageStore store.ImageStore, excludes ...string) (map[store.LayerID]int, error) {
+	imageMetadataMap, err := imageStore.GetImageMetadataMap()
+	if err != nil {
+		return nil, err
+	}
+	// an image may have several names.
+	imageIDs := map[string]bool{}
+	for _, metadata := range imageMetadataMap {
+		if utils.NotIn(metadata.ID, excludes) {
+			imageIDs[metadata.ID] = true
+		}
+	}
+
+	layerRefs := map[store.LayerID]int{}
+	for id := range imageIDs {
+		img, err := imageStore.GetByID(id)
+		if err != nil {
+			logger.Debug("failed to get image %s: %v", id, err)
+			continue
+		}
+		for _, layer := range img.Spec.Layers {
+			if layer.ID != "" {
+				layerRefs[store.LayerID(layer.ID)]++
+			}
+		}
+	}
+	return layerRefs, nil
+}
+
+func layerSize(layerStore store.LayerStore, layerID store.LayerID) int64 {
+	if layer := layerStore.Get(layerID); layer != nil {
+		return layer.Size()
+	}
+	return 0
+}
+
+// listLayerIDs lists the layers of layer data dir, including the ones whose
+// layerdb metadata is lost.
+func listLayerIDs() ([]store.LayerID, error) {
+	dirs, err := ioutil.ReadDir(common.DefaultLayerDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var layerIDs []store.LayerID
+	for _, dir := range dirs {
+		dgst := digest.NewDigestFromHex(digest.SHA256.String(), dir.Name())
+		if !dir.IsDir() || dgst.Validate() != nil {
+			continue
+		}
+		layerIDs = append(layerIDs, store.LayerID(dgst))
+	}
+	return layerIDs, nil
+}
+
+func deleteLayer(layerStore store.LayerStore, layerID store.LayerID) error {
+	if err := layerStore.Delete(layerID); err != nil {
+		return err
+	}
+	// layer store skips the layer without layerdb metadata.
+	return os.RemoveAll(filepath.Join(common.DefaultLayerDir, layerID.ToDigest().Hex()))
+}
+
+// mountedPaths returns the mount points and the dirs used by overlay mounts.
+func mountedPaths() ([]string, error) {
+	data, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		paths = append(paths, fields[1])
+		for _, opt := range strings.Split(fields[3], ",") {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "lowerdir", "upperdir", "workdir":
+				paths = append(paths, strings.Split(kv[1], ":")...)
+			}
+		}
+	}
+	return paths, nil
+}
+
+func modifiedWithin(path string, d time.Duration) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) < d
+}
+
+func isMounted(mounts []string, path string) bool {
+	for _, m := range mounts {
+		if m == path || strings.HasPrefix(m, path+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -22,6 +22,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/platform"
 	"github.com/alibaba/sealer/image/store"
 	"github.com/alibaba/sealer/logger"
 	v1 "github.com/alibaba/sealer/types/api/v1"
@@ -77,6 +78,8 @@ func GetClusterFileFromImageManifest(imageName string) (string, error) {
 			return "", fmt.Errorf("failed to find image %s: %v", imageName, err)
 		}
 		image = &imageMetadata
+	} else if image, err = platform.ResolveDefault(is, image); err != nil {
+		return "", err
 	}
 	clusterFile, ok := image.Annotations[common.ImageAnnotationForClusterfile]
 	if !ok {
@@ -114,6 +117,9 @@ func GetFileFromBaseImage(imageName string, paths ...string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if image, err = platform.ResolveDefault(is, image); err != nil {
+		return "", err
+	}
 
 	layers, err := GetImageLayerDirs(image)
 	if err != nil {
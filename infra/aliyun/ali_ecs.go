@@ -15,10 +15,13 @@
 package aliyun
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -164,6 +167,29 @@ func (a *AliProvider) GetInstanceStatus(instanceID string) (instanceStatus strin
 	return response.InstanceStatuses.InstanceStatus[0].Status, nil
 }
 
+// InstanceStatus returns the status of the instance with the private IP or EIP host, like Pending, Starting and
+// Running, the host which is not an instance of the cluster, like the one in the ip pool, is reported running.
+func (a *AliProvider) InstanceStatus(host string) (string, bool, error) {
+	request := ecs.CreateDescribeInstancesRequest()
+	request.Scheme = Scheme
+	request.RegionId = a.Config.RegionID
+	request.VpcId = a.Cluster.Annotations[VpcID]
+	if host == a.Cluster.Annotations[Eip] {
+		request.EipAddresses = fmt.Sprintf(`["%s"]`, host)
+	} else {
+		request.PrivateIpAddresses = fmt.Sprintf(`["%s"]`, host)
+	}
+	response := ecs.CreateDescribeInstancesResponse()
+	if err := a.RetryEcsRequest(request, response); err != nil {
+		return "", false, fmt.Errorf("get instance status of %s failed, error :%v", host, err)
+	}
+	if len(response.Instances.Instance) == 0 {
+		return "", true, nil
+	}
+	status := response.Instances.Instance[0].Status
+	return status, status == Running, nil
+}
+
 func (a *AliProvider) PoweroffInstance(instanceID string) error {
 	request := ecs.CreateStopInstancesRequest()
 	request.Scheme = Scheme
@@ -374,14 +400,38 @@ func CreateDescribeInstancesTag(tags map[string]string) (instanceTags []ecs.Desc
 	return
 }
 
-func CreateInstanceDataDisk(dataDisks []string) (instanceDisks []ecs.RunInstancesDataDisk) {
+func CreateInstanceDataDisk(dataDisks []string, disks []v1.DataDisk) (instanceDisks []ecs.RunInstancesDataDisk) {
 	for _, v := range dataDisks {
 		instanceDisks = append(instanceDisks,
 			ecs.RunInstancesDataDisk{Size: v, Category: AliCloudEssd})
 	}
+	for _, d := range disks {
+		category := d.Category
+		if category == "" {
+			category = AliCloudEssd
+		}
+		instanceDisks = append(instanceDisks,
+			ecs.RunInstancesDataDisk{Size: d.Size, Category: category, DeleteWithInstance: "true"})
+	}
 	return
 }
 
+// SetDataDiskDevices sets the devices of the disks of hosts, the data disks are attached to the instances
+// as /dev/vdb, /dev/vdc and so on in order, after the ones of DataDisks.
+func SetDataDiskDevices(hosts *v1.Hosts) error {
+	for i := range hosts.Disks {
+		index := len(hosts.DataDisks) + i
+		if index >= MaxDataDisks {
+			return fmt.Errorf("at most %d data disks can be attached to an instance", MaxDataDisks)
+		}
+		if _, err := strconv.Atoi(hosts.Disks[i].Size); err != nil {
+			return fmt.Errorf("invalid size %s of data disk, must be in GiB like 100", hosts.Disks[i].Size)
+		}
+		hosts.Disks[i].Device = fmt.Sprintf("/dev/vd%c", 'b'+index)
+	}
+	return nil
+}
+
 func (a *AliProvider) GetAvailableResource(cores int, memory float64) (instanceType []string, err error) {
 	request := ecs.CreateDescribeAvailableResourceRequest()
 	request.Scheme = Scheme
@@ -436,8 +486,10 @@ func (a *AliProvider) RunInstances(instanceRole string, count int) error {
 	tag[Role] = instanceRole
 	instancesTag := CreateInstanceTag(tag)
 
-	dataDisks := instances.DataDisks
-	datadisk := CreateInstanceDataDisk(dataDisks)
+	if err := SetDataDiskDevices(instances); err != nil {
+		return err
+	}
+	datadisk := CreateInstanceDataDisk(instances.DataDisks, instances.Disks)
 
 	request := ecs.CreateRunInstancesRequest()
 	request.Scheme = Scheme
@@ -450,6 +502,13 @@ func (a *AliProvider) RunInstances(instanceRole string, count int) error {
 	request.DataDisk = &datadisk
 	request.Amount = requests.NewInteger(count)
 	request.Tag = &instancesTag
+	if instanceRole == Node {
+		userData, err := a.getNodeUserData()
+		if err != nil {
+			return err
+		}
+		request.UserData = userData
+	}
 
 	//response, err := d.Client.RunInstances(request)
 	response := ecs.CreateRunInstancesResponse()
@@ -469,6 +528,19 @@ func (a *AliProvider) RunInstances(instanceRole string, count int) error {
 	return nil
 }
 
+// getNodeUserData returns the base64 encoded user-data of nodes, empty if not set.
+func (a *AliProvider) getNodeUserData() (string, error) {
+	path := a.Cluster.GetAnnotationsByKey(NodeUserData)
+	if path == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read node user-data: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
 func (a *AliProvider) AuthorizeSecurityGroup(securityGroupID, portRange string) bool {
 	request := ecs.CreateAuthorizeSecurityGroupRequest()
 	request.Scheme = Scheme
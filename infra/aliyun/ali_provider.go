@@ -76,6 +76,7 @@ const (
 	Master                     = "master"
 	Node                       = "node"
 	Stopped                    = "Stopped"
+	Running                    = "Running"
 	AvailableTypeStatus        = "WithStock"
 	Bandwidth                  = "100"
 	Digits                     = "0123456789"
@@ -97,8 +98,10 @@ const (
 	AliRegionID                = AliDomain + RegionID
 	AliMasterIDs               = AliDomain + "MasterIDs"
 	AliNodeIDs                 = AliDomain + "NodeIDs"
+	NodeUserData               = AliDomain + "NodeUserData"
 	DefaultRegionID            = "cn-chengdu"
 	AliCloudEssd               = "cloud_essd"
+	MaxDataDisks               = 16
 	TryTimes                   = 10
 	TrySleepTime               = time.Second
 	JustGetInstanceInfo        = ""
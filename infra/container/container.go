@@ -17,6 +17,7 @@ package container
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -212,6 +213,11 @@ func (a *ApplyProvider) applyToJoin(toJoinNumber int, role string) ([]string, er
 			}
 			opts.Mount = append(opts.Mount, sealerMount)
 		}
+		hosts := a.Cluster.Spec.Nodes
+		if role == MASTER {
+			hosts = a.Cluster.Spec.Masters
+		}
+		opts.Mount = append(opts.Mount, a.dataDiskMounts(hosts)...)
 
 		containerID, err := a.Provider.RunContainer(opts)
 		if err != nil {
@@ -234,6 +240,29 @@ func (a *ApplyProvider) applyToJoin(toJoinNumber int, role string) ([]string, er
 	return toJoinIPList, nil
 }
 
+// dataDiskMounts returns the volumes of the disks of hosts with path, which are removed with the container.
+// The disks are not attached as devices to the containers, so the devices are left empty and sealer does not mount them.
+func (a *ApplyProvider) dataDiskMounts(hosts v1.Hosts) []mount.Mount {
+	var mounts []mount.Mount
+	for _, d := range hosts.Disks {
+		if d.Path == "" {
+			continue
+		}
+		target := d.Path
+		if target == common.RegistryDirName {
+			target = filepath.Join(common.DefaultTheClusterRootfsDir(a.Cluster.Name), common.RegistryDirName)
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Target: target,
+			VolumeOptions: &mount.VolumeOptions{
+				DriverConfig: &mount.Driver{Name: "local"},
+			},
+		})
+	}
+	return mounts
+}
+
 func (a *ApplyProvider) changeDefaultPasswd(containerIP string) error {
 	if a.Cluster.Spec.SSH.Passwd == "" {
 		return nil
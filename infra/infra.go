@@ -20,6 +20,7 @@ import (
 	"github.com/alibaba/sealer/infra/aliyun"
 	"github.com/alibaba/sealer/infra/container"
 	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils/ssh"
 )
 
 type Interface interface {
@@ -28,6 +29,22 @@ type Interface interface {
 	Apply() error
 }
 
+// InstanceStatusGetter is implemented by the providers knowing the status of the instances they created.
+type InstanceStatusGetter interface {
+	// InstanceStatus returns the status of the instance of host and if it is running.
+	InstanceStatus(host string) (status string, running bool, err error)
+}
+
+// WaitHostsReady waits for the instances of hosts running if provider knows their status, reachable by ssh and
+// cloud-init done, so the hosts still booting are not failed on.
+func WaitHostsReady(provider Interface, client ssh.Interface, hosts ...string) error {
+	opts := ssh.ReadyOptions{WaitCloudInit: true}
+	if getter, ok := provider.(InstanceStatusGetter); ok {
+		opts.InstanceStatus = getter.InstanceStatus
+	}
+	return ssh.WaitHostsReady(client, opts, hosts...)
+}
+
 func NewDefaultProvider(cluster *v1.Cluster) (Interface, error) {
 	switch cluster.Spec.Provider {
 	case aliyun.AliCloud:
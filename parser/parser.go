@@ -26,7 +26,7 @@ import (
 	"github.com/alibaba/sealer/version"
 )
 
-var validLayer = []string{"FROM", "COPY", "RUN", "CMD"}
+var validLayer = []string{"FROM", "COPY", "RUN", "CMD", "ARG", "ENV"}
 
 type Interface interface {
 	Parse(kubeFile []byte) *v1.Image
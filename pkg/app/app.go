@@ -0,0 +1,239 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+type Kind string
+
+const (
+	Manifest Kind = "manifest"
+	Chart    Kind = "chart"
+)
+
+const (
+	manifestsDir = "manifests"
+	chartsDir    = "charts"
+	chartFile    = "Chart.yaml"
+	// MaxRevisions is the number of revisions kept of each app, the older ones are dropped with their content.
+	MaxRevisions = 10
+)
+
+var invalidNameChars = regexp.MustCompile(`[^-._a-zA-Z0-9]+`)
+
+// Source is a manifest under manifests or a helm chart under charts of rootfs, which the CMD of CloudImage applies.
+type Source struct {
+	Name    string
+	Kind    Kind
+	Version string
+	// Path is relative to rootfs
+	Path     string
+	Checksum string
+}
+
+// Revision is a version of app applied to the cluster.
+type Revision struct {
+	Revision int    `json:"revision"`
+	Version  string `json:"version,omitempty"`
+	Checksum string `json:"checksum"`
+	// Image is the CloudImage the app is applied from
+	Image     string    `json:"image"`
+	Path      string    `json:"path"`
+	AppliedAt time.Time `json:"appliedAt"`
+	// RollbackOf is the revision restored by this one, zero if it is applied by CloudImage
+	RollbackOf int `json:"rollbackOf,omitempty"`
+	// Content is the ConfigMap the manifest or chart is kept in for rollback, empty if it is too large to keep
+	Content string `json:"content,omitempty"`
+}
+
+// App is the history of a manifest or chart applied to the cluster, the revisions are in the applied order.
+type App struct {
+	Name      string     `json:"name"`
+	Kind      Kind       `json:"kind"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// Current returns the last applied revision of app.
+func (a *App) Current() *Revision {
+	if len(a.Revisions) == 0 {
+		return nil
+	}
+	return &a.Revisions[len(a.Revisions)-1]
+}
+
+// Previous returns the last revision before the current one which differs from it, nil if there is none.
+func (a *App) Previous() *Revision {
+	current := a.Current()
+	if current == nil {
+		return nil
+	}
+	for i := len(a.Revisions) - 2; i >= 0; i-- {
+		if a.Revisions[i].Checksum != current.Checksum {
+			return &a.Revisions[i]
+		}
+	}
+	return nil
+}
+
+// Get returns the revision numbered n, nil if it is not kept.
+func (a *App) Get(n int) *Revision {
+	for i := range a.Revisions {
+		if a.Revisions[i].Revision == n {
+			return &a.Revisions[i]
+		}
+	}
+	return nil
+}
+
+// add appends rev as the next revision of app, and returns the revisions dropped beyond MaxRevisions.
+func (a *App) add(rev Revision) (dropped []Revision) {
+	rev.Revision = 1
+	if current := a.Current(); current != nil {
+		rev.Revision = current.Revision + 1
+	}
+	a.Revisions = append(a.Revisions, rev)
+	if n := len(a.Revisions) - MaxRevisions; n > 0 {
+		dropped = append(dropped, a.Revisions[:n]...)
+		a.Revisions = a.Revisions[n:]
+	}
+	return dropped
+}
+
+// Discover lists the manifests and the helm charts in rootfs, the name of a manifest is its path under
+// manifests without the extension, and the name of a chart is the one in its Chart.yaml.
+func Discover(rootfs string) ([]Source, error) {
+	var sources []Source
+	dir := filepath.Join(rootfs, manifestsDir)
+	if _, err := os.Stat(dir); err == nil {
+		err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(path)
+			if !info.Mode().IsRegular() || (ext != ".yaml" && ext != ".yml") {
+				return nil
+			}
+			checksum, err := fileChecksum(path)
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(rootfs, path)
+			name, _ := filepath.Rel(dir, strings.TrimSuffix(path, ext))
+			sources = append(sources, Source{Name: sanitize(name), Kind: Manifest, Path: rel, Checksum: checksum})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	charts, err := ioutil.ReadDir(filepath.Join(rootfs, chartsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, c := range charts {
+		path := filepath.Join(rootfs, chartsDir, c.Name())
+		data, err := ioutil.ReadFile(filepath.Join(path, chartFile))
+		if err != nil {
+			// not a chart, like the packed charts.
+			continue
+		}
+		var chart struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if err = yaml.Unmarshal(data, &chart); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", filepath.Join(path, chartFile), err)
+		}
+		checksum, err := dirChecksum(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(rootfs, path)
+		sources = append(sources, Source{Name: sanitize(chart.Name), Kind: Chart, Version: chart.Version, Path: rel, Checksum: checksum})
+	}
+	return sources, nil
+}
+
+// filterSources returns the sources at or under paths.
+func filterSources(sources []Source, paths []string) []Source {
+	var filtered []Source
+	for _, src := range sources {
+		for _, p := range paths {
+			p = filepath.Clean(p)
+			if src.Path == p || strings.HasPrefix(src.Path, p+string(filepath.Separator)) {
+				filtered = append(filtered, src)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// sanitize makes name a valid key of ConfigMap, like: ingress/nginx => ingress-nginx.
+func sanitize(name string) string {
+	return invalidNameChars.ReplaceAllString(filepath.ToSlash(name), "-")
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dirChecksum hashes the relative paths and the contents of the files in dir in lexical order.
+func dirChecksum(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if _, err = io.WriteString(h, filepath.ToSlash(rel)+"\x00"); err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
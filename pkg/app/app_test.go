@@ -0,0 +1,99 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "sealer-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+	writeFile(t, filepath.Join(rootfs, "manifests", "dashboard.yaml"), "kind: Deployment")
+	writeFile(t, filepath.Join(rootfs, "manifests", "ingress", "nginx.yml"), "kind: DaemonSet")
+	writeFile(t, filepath.Join(rootfs, "manifests", "imageList"), "nginx:1.19")
+	writeFile(t, filepath.Join(rootfs, "charts", "redis", "Chart.yaml"), "name: redis\nversion: 1.2.0")
+	writeFile(t, filepath.Join(rootfs, "charts", "redis", "templates", "svc.yaml"), "kind: Service")
+
+	sources, err := Discover(rootfs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Source{
+		{Name: "dashboard", Kind: Manifest, Path: "manifests/dashboard.yaml"},
+		{Name: "ingress-nginx", Kind: Manifest, Path: "manifests/ingress/nginx.yml"},
+		{Name: "redis", Kind: Chart, Version: "1.2.0", Path: "charts/redis"},
+	}
+	if len(sources) != len(want) {
+		t.Fatalf("Discover() = %+v, want %+v", sources, want)
+	}
+	for i, s := range sources {
+		if s.Name != want[i].Name || s.Kind != want[i].Kind || s.Version != want[i].Version || s.Path != want[i].Path || s.Checksum == "" {
+			t.Errorf("Discover()[%d] = %+v, want %+v", i, s, want[i])
+		}
+	}
+
+	checksum := sources[2].Checksum
+	writeFile(t, filepath.Join(rootfs, "charts", "redis", "templates", "svc.yaml"), "kind: Service\nspec: {}")
+	if sources, _ = Discover(rootfs); sources[2].Checksum == checksum {
+		t.Errorf("the checksum of chart is not changed with its templates")
+	}
+}
+
+func TestApp_Revisions(t *testing.T) {
+	a := &App{Name: "redis", Kind: Chart}
+	if a.Previous() != nil {
+		t.Errorf("Previous() of app without revisions is not nil")
+	}
+	a.add(Revision{Version: "1.0.0", Checksum: "a"})
+	a.add(Revision{Version: "1.1.0", Checksum: "b"})
+	if prev := a.Previous(); prev == nil || prev.Revision != 1 {
+		t.Errorf("Previous() = %+v, want revision 1", prev)
+	}
+	// rolled back to revision 1, the previous one is revision 2.
+	a.add(Revision{Version: "1.0.0", Checksum: "a", RollbackOf: 1})
+	if cur, prev := a.Current(), a.Previous(); cur.Revision != 3 || prev == nil || prev.Revision != 2 {
+		t.Errorf("Current() = %+v, Previous() = %+v, want revision 3 and 2", cur, prev)
+	}
+
+	var dropped []Revision
+	for i := 0; i < MaxRevisions; i++ {
+		dropped = append(dropped, a.add(Revision{Checksum: "c"})...)
+	}
+	if len(a.Revisions) != MaxRevisions || len(dropped) != 3 || dropped[0].Revision != 1 {
+		t.Errorf("kept %d revisions, dropped %+v, want %d kept and revision 1 to 3 dropped", len(a.Revisions), dropped, MaxRevisions)
+	}
+	if a.Get(1) != nil || a.Get(13).Checksum != "c" {
+		t.Errorf("Get() returns a dropped revision or misses the current one")
+	}
+	if a.Previous() != nil {
+		t.Errorf("Previous() = %+v, want nil as all kept revisions are the same", a.Previous())
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	remoteRollbackDir      = "/tmp/sealer-app-rollback"
+	RemoteApplyManifestCmd = "kubectl apply -f %s"
+	RemoteUpgradeChartCmd  = "helm upgrade --install %s %s --namespace %s --reuse-values"
+)
+
+// RollbackOptions selects the revision to restore, and the helm release of a chart.
+type RollbackOptions struct {
+	// Revision to restore, the previous one by default
+	Revision int
+	// Namespace of the helm release, which is named after the chart
+	Namespace string
+}
+
+// Rollback applies the kept manifest or chart of a former revision of app name on master0 of cluster,
+// by kubectl apply or helm upgrade, and records it as the current revision.
+func Rollback(cluster *v2.Cluster, name string, opts RollbackOptions) (*Revision, error) {
+	store, err := NewStore()
+	if err != nil {
+		return nil, err
+	}
+	apps, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	var a *App
+	for i := range apps {
+		if apps[i].Name == name {
+			a = &apps[i]
+		}
+	}
+	if a == nil {
+		return nil, fmt.Errorf("app %s not found, run sealer app list to get the apps", name)
+	}
+	target := a.Previous()
+	if opts.Revision > 0 {
+		target = a.Get(opts.Revision)
+	}
+	if target == nil {
+		return nil, fmt.Errorf("app %s has no revision to roll back to", name)
+	}
+	content, err := store.Content(target)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempDir("", "sealer-app")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	file, cmd := filepath.Join(remoteRollbackDir, name+".yaml"), RemoteApplyManifestCmd
+	if a.Kind == Chart {
+		file = filepath.Join(remoteRollbackDir, name+".tgz")
+		cmd = fmt.Sprintf(RemoteUpgradeChartCmd, name, file, opts.Namespace)
+	} else {
+		cmd = fmt.Sprintf(cmd, file)
+	}
+	local := filepath.Join(tmp, filepath.Base(file))
+	if err = ioutil.WriteFile(local, content, 0600); err != nil {
+		return nil, err
+	}
+
+	master0 := runtime.GetMaster0Ip(cluster)
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if err = client.Copy(master0, local, file); err != nil {
+		return nil, fmt.Errorf("failed to copy %s of revision %d to %s: %v", name, target.Revision, master0, err)
+	}
+	logger.Info("rolling back %s %s to revision %d of %s", a.Kind, name, target.Revision, target.Image)
+	if err = client.CmdAsync(master0, cmd, "rm -f "+file); err != nil {
+		return nil, fmt.Errorf("failed to roll back %s to revision %d: %v", name, target.Revision, err)
+	}
+	return store.AddRollback(name, *target)
+}
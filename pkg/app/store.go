@@ -0,0 +1,249 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/client/k8s"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils/archive"
+)
+
+const (
+	// IndexConfigMap in kube-system holds the history of all apps, keyed by their names.
+	IndexConfigMap = "sealer-apps"
+	// ContentConfigMapPrefix is the prefix of the ConfigMaps the manifests and charts are kept in, named by checksum.
+	ContentConfigMapPrefix = "sealer-app-"
+	manifestKey            = "manifest.yaml"
+	chartKey               = "chart.tgz"
+	// maxContentSize keeps the ConfigMap of content within the 1MiB limit of etcd.
+	maxContentSize = 900 * 1024
+)
+
+// Store keeps the history of apps and their content in the ConfigMaps of kube-system.
+type Store struct {
+	client *k8s.Client
+}
+
+func NewStore() (*Store, error) {
+	client, err := k8s.Newk8sClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{client: client}, nil
+}
+
+// List returns the apps sorted by name.
+func (s *Store) List() ([]App, error) {
+	apps, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var list []App
+	for _, a := range apps {
+		list = append(list, *a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// Record adds a revision to each app in rootfs of image which is new or changed since the last apply,
+// only the apps under paths relative to rootfs are recorded unless paths is nil.
+func (s *Store) Record(rootfs, image string, paths []string) error {
+	sources, err := Discover(rootfs)
+	if err != nil {
+		return err
+	}
+	if paths != nil {
+		sources = filterSources(sources, paths)
+	}
+	apps, err := s.load()
+	if err != nil {
+		return err
+	}
+	var dropped []Revision
+	for _, src := range sources {
+		a, ok := apps[src.Name]
+		if !ok {
+			a = &App{Name: src.Name, Kind: src.Kind}
+			apps[src.Name] = a
+		}
+		rev := Revision{
+			Version:   src.Version,
+			Checksum:  src.Checksum,
+			Image:     image,
+			Path:      src.Path,
+			AppliedAt: time.Now(),
+		}
+		if current := a.Current(); current != nil && current.Checksum == rev.Checksum {
+			continue
+		}
+		if rev.Content, err = s.saveContent(rootfs, src); err != nil {
+			return err
+		}
+		dropped = append(dropped, a.add(rev)...)
+		logger.Info("app %s is applied as revision %d from %s", a.Name, a.Current().Revision, image)
+	}
+	return s.save(apps, dropped)
+}
+
+// Content returns the manifest or the packed chart kept for rev.
+func (s *Store) Content(rev *Revision) ([]byte, error) {
+	if rev.Content == "" {
+		return nil, fmt.Errorf("the content of revision %d is not kept as it is larger than %d bytes", rev.Revision, maxContentSize)
+	}
+	cm, err := s.client.GetConfigMap(metav1.NamespaceSystem, rev.Content)
+	if err != nil {
+		return nil, err
+	}
+	if cm == nil {
+		return nil, fmt.Errorf("configmap %s of revision %d not found", rev.Content, rev.Revision)
+	}
+	if data, ok := cm.BinaryData[chartKey]; ok {
+		return data, nil
+	}
+	return []byte(cm.Data[manifestKey]), nil
+}
+
+// AddRollback records rev of the app name is restored as its current revision.
+func (s *Store) AddRollback(name string, rev Revision) (*Revision, error) {
+	apps, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	a, ok := apps[name]
+	if !ok {
+		return nil, fmt.Errorf("app %s not found", name)
+	}
+	rev.RollbackOf = rev.Revision
+	rev.AppliedAt = time.Now()
+	if err := s.save(apps, a.add(rev)); err != nil {
+		return nil, err
+	}
+	return a.Current(), nil
+}
+
+func (s *Store) load() (map[string]*App, error) {
+	apps := make(map[string]*App)
+	cm, err := s.client.GetConfigMap(metav1.NamespaceSystem, IndexConfigMap)
+	if err != nil || cm == nil {
+		return apps, err
+	}
+	for name, data := range cm.Data {
+		a := &App{}
+		if err := json.Unmarshal([]byte(data), a); err != nil {
+			return nil, fmt.Errorf("failed to decode app %s of configmap %s: %v", name, IndexConfigMap, err)
+		}
+		apps[name] = a
+	}
+	return apps, nil
+}
+
+// save writes the index of apps, then deletes the content of the dropped revisions no longer referenced.
+func (s *Store) save(apps map[string]*App, dropped []Revision) error {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: IndexConfigMap, Namespace: metav1.NamespaceSystem},
+		Data:       make(map[string]string),
+	}
+	referenced := make(map[string]bool)
+	for name, a := range apps {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		cm.Data[name] = string(data)
+		for _, rev := range a.Revisions {
+			referenced[rev.Content] = true
+		}
+	}
+	if err := s.client.ApplyConfigMap(cm); err != nil {
+		return err
+	}
+	for _, rev := range dropped {
+		if rev.Content == "" || referenced[rev.Content] {
+			continue
+		}
+		if err := s.client.DeleteConfigMap(metav1.NamespaceSystem, rev.Content); err != nil {
+			logger.Warn("failed to delete the content of dropped revision %d: %v", rev.Revision, err)
+		}
+	}
+	return nil
+}
+
+// saveContent keeps the manifest, or the chart packed as tgz which helm installs, in the ConfigMap named by its
+// checksum, it returns an empty name if the content is too large.
+func (s *Store) saveContent(rootfs string, src Source) (string, error) {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ContentConfigMapPrefix + strings.TrimPrefix(src.Checksum, "sha256:")[:32],
+			Namespace: metav1.NamespaceSystem,
+		},
+	}
+	path := filepath.Join(rootfs, src.Path)
+	var size int
+	if src.Kind == Chart {
+		data, err := packChart(path)
+		if err != nil {
+			return "", err
+		}
+		cm.BinaryData = map[string][]byte{chartKey: data}
+		size = len(data)
+	} else {
+		data, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return "", err
+		}
+		cm.Data = map[string]string{manifestKey: string(data)}
+		size = len(data)
+	}
+	if size > maxContentSize {
+		logger.Warn("%s %s is larger than %d bytes, it can not be rolled back to", src.Kind, src.Name, maxContentSize)
+		return "", nil
+	}
+	return cm.Name, s.client.ApplyConfigMap(cm)
+}
+
+// packChart packs the chart dir as the tgz of helm package, with the dir as its root.
+func packChart(dir string) ([]byte, error) {
+	tarReader, err := archive.TarWithRootDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer tarReader.Close()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err = io.Copy(gz, tarReader); err != nil {
+		return nil, err
+	}
+	if err = gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,222 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/apply/v2"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/scaling"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// NodeGroupID is the only node group of a cluster, which holds all the nodes.
+const NodeGroupID = "nodes"
+
+const (
+	StatusRunning  = "Running"
+	StatusCreating = "Creating"
+	StatusDeleting = "Deleting"
+)
+
+type NodeGroup struct {
+	ID         string `json:"id"`
+	MinSize    int    `json:"minSize"`
+	MaxSize    int    `json:"maxSize"`
+	TargetSize int    `json:"targetSize"`
+}
+
+type Instance struct {
+	// ID is the IP of node
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// Provisioner joins hosts from the host pool of Clusterfile as nodes and deletes them back, the scaling
+// runs in background one by one, like the instances of a cloud being created after the request is accepted.
+type Provisioner struct {
+	clusterName string
+	// scaling serializes sealer join and delete on the cluster.
+	scaling  sync.Mutex
+	mu       sync.Mutex
+	creating []string
+	deleting []string
+	lastErr  error
+}
+
+func NewProvisioner(clusterName string) *Provisioner {
+	return &Provisioner{clusterName: clusterName}
+}
+
+func (p *Provisioner) clusterfile() string {
+	return common.GetClusterWorkClusterfile(p.clusterName)
+}
+
+func (p *Provisioner) loadCluster() (*v2.Cluster, error) {
+	return utils.GetClusterFromFile(p.clusterfile())
+}
+
+// NodeGroup returns the node group, the target size includes the nodes being created and excludes those being deleted.
+func (p *Provisioner) NodeGroup() (*NodeGroup, error) {
+	cluster, err := p.loadCluster()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.nodeGroup(cluster), nil
+}
+
+func (p *Provisioner) nodeGroup(cluster *v2.Cluster) *NodeGroup {
+	spec := cluster.Spec.Autoscaler
+	max := spec.MaxNodes
+	if max == 0 {
+		max = len(cluster.GetNodeIPList()) + len(freeHosts(cluster, p.creating))
+	}
+	return &NodeGroup{
+		ID:         NodeGroupID,
+		MinSize:    spec.MinNodes,
+		MaxSize:    max,
+		TargetSize: len(cluster.GetNodeIPList()) + len(p.creating) - len(p.deleting),
+	}
+}
+
+// Instances returns the nodes of cluster and those being created.
+func (p *Provisioner) Instances() ([]Instance, error) {
+	cluster, err := p.loadCluster()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var instances []Instance
+	for _, ip := range cluster.GetNodeIPList() {
+		status := StatusRunning
+		if utils.InList(ip, p.deleting) {
+			status = StatusDeleting
+		}
+		instances = append(instances, Instance{ID: ip, Status: status})
+	}
+	for _, ip := range p.creating {
+		if utils.NotIn(ip, cluster.GetNodeIPList()) {
+			instances = append(instances, Instance{ID: ip, Status: StatusCreating})
+		}
+	}
+	return instances, nil
+}
+
+// LastError returns the error of the last scaling, nil if it succeeded.
+func (p *Provisioner) LastError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// IncreaseSize joins delta hosts from the host pool in background.
+func (p *Provisioner) IncreaseSize(delta int) error {
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive, got %d", delta)
+	}
+	cluster, err := p.loadCluster()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	group := p.nodeGroup(cluster)
+	if group.TargetSize+delta > group.MaxSize {
+		return fmt.Errorf("size increase too large, target %d exceeds max size %d", group.TargetSize+delta, group.MaxSize)
+	}
+	free := freeHosts(cluster, p.creating)
+	if len(free) < delta {
+		return fmt.Errorf("only %d hosts left in the host pool, %d are requested", len(free), delta)
+	}
+	hosts := free[:delta]
+	req := &scaling.ScaleRequest{Action: common.JoinSubCmd, Nodes: scaling.Hosts{IPList: hosts}}
+	if err := req.Validate(scaling.ClusterOfV2(cluster)); err != nil {
+		return err
+	}
+	p.creating = append(p.creating, hosts...)
+	go p.scale(common.JoinSubCmd, hosts)
+	return nil
+}
+
+// DeleteNodes deletes the nodes in background, they are returned to the host pool.
+func (p *Provisioner) DeleteNodes(nodes []string) error {
+	cluster, err := p.loadCluster()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	req := &scaling.ScaleRequest{Action: common.DeleteSubCmd, Nodes: scaling.Hosts{IPList: nodes}}
+	if err := req.Validate(scaling.ClusterOfV2(cluster)); err != nil {
+		return err
+	}
+	for _, ip := range nodes {
+		if utils.InList(ip, p.deleting) {
+			return fmt.Errorf("node %s is being deleted", ip)
+		}
+	}
+	group := p.nodeGroup(cluster)
+	if group.TargetSize-len(nodes) < group.MinSize {
+		return fmt.Errorf("size decrease too large, target %d is less than min size %d", group.TargetSize-len(nodes), group.MinSize)
+	}
+	p.deleting = append(p.deleting, nodes...)
+	go p.scale(common.DeleteSubCmd, nodes)
+	return nil
+}
+
+func (p *Provisioner) scale(flag string, nodes []string) {
+	p.scaling.Lock()
+	defer p.scaling.Unlock()
+	logger.Info("autoscaler %s nodes %v", flag, nodes)
+	err := func() error {
+		applier, err := apply.NewScaleApplierFromArgs(p.clusterfile(), &common.RunArgs{Nodes: strings.Join(nodes, ",")}, flag)
+		if err != nil {
+			return err
+		}
+		return applier.Apply(context.Background())
+	}()
+	if err != nil {
+		logger.Error("autoscaler failed to %s nodes %v: %v", flag, nodes, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastErr = err
+	if flag == common.JoinSubCmd {
+		p.creating = utils.RemoveIPList(p.creating, nodes)
+	} else {
+		p.deleting = utils.RemoveIPList(p.deleting, nodes)
+	}
+}
+
+// freeHosts returns the hosts of pool which are neither in cluster nor being joined.
+func freeHosts(cluster *v2.Cluster, creating []string) []string {
+	var free []string
+	for _, ip := range cluster.Spec.Autoscaler.HostPool {
+		if utils.NotIn(ip, cluster.GetNodeIPList()) && utils.NotIn(ip, cluster.GetMasterIPList()) && utils.NotIn(ip, creating) {
+			free = append(free, ip)
+		}
+	}
+	return free
+}
@@ -0,0 +1,75 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoscaler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func newTestCluster(maxNodes int) *v2.Cluster {
+	cluster := &v2.Cluster{}
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{common.MASTER}},
+		{IPS: []string{"192.168.0.3", "192.168.0.4"}, Roles: []string{common.NODE}},
+	}
+	cluster.Spec.Autoscaler = v2.AutoscalerSpec{
+		MinNodes: 1,
+		MaxNodes: maxNodes,
+		HostPool: []string{"192.168.0.2", "192.168.0.4", "192.168.0.5", "192.168.0.6", "192.168.0.7"},
+	}
+	return cluster
+}
+
+func TestFreeHosts(t *testing.T) {
+	got := freeHosts(newTestCluster(0), []string{"192.168.0.5"})
+	want := []string{"192.168.0.6", "192.168.0.7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("freeHosts() = %v, want %v", got, want)
+	}
+}
+
+func TestNodeGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxNodes int
+		creating []string
+		deleting []string
+		want     NodeGroup
+	}{
+		{
+			name: "max size defaults to nodes and free hosts",
+			want: NodeGroup{ID: NodeGroupID, MinSize: 1, MaxSize: 5, TargetSize: 2},
+		},
+		{
+			name:     "target size counts scaling nodes",
+			maxNodes: 10,
+			creating: []string{"192.168.0.5", "192.168.0.6"},
+			deleting: []string{"192.168.0.3"},
+			want:     NodeGroup{ID: NodeGroupID, MinSize: 1, MaxSize: 10, TargetSize: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provisioner{creating: tt.creating, deleting: tt.deleting}
+			if got := p.nodeGroup(newTestCluster(tt.maxNodes)); !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("nodeGroup() = %v, want %v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoscaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/scaling"
+)
+
+type increaseSizeRequest struct {
+	Delta int `json:"delta"`
+}
+
+type deleteNodesRequest struct {
+	Nodes []string `json:"nodes"`
+}
+
+type nodeGroupStatus struct {
+	NodeGroup
+	// LastError is the error of the last scaling
+	LastError string `json:"lastError,omitempty"`
+}
+
+// NewHandler serves the node group API for the cluster-autoscaler:
+// GET  /v1/nodegroups                   list node groups
+// GET  /v1/nodegroups/nodes/instances   list the instances of node group
+// POST /v1/nodegroups/nodes/increase    {"delta": 1} join nodes from the host pool
+// POST /v1/nodegroups/nodes/delete      {"nodes": ["192.168.0.5"]} delete nodes
+func NewHandler(p *Provisioner) http.Handler {
+	mux := http.NewServeMux()
+	prefix := "/v1/nodegroups/" + NodeGroupID
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/v1/nodegroups", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		group, err := p.NodeGroup()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		status := nodeGroupStatus{NodeGroup: *group}
+		if err := p.LastError(); err != nil {
+			status.LastError = err.Error()
+		}
+		writeJSON(w, http.StatusOK, []nodeGroupStatus{status})
+	})
+	mux.HandleFunc(prefix+"/instances", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		instances, err := p.Instances()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, instances)
+	})
+	mux.HandleFunc(prefix+"/increase", func(w http.ResponseWriter, r *http.Request) {
+		var req increaseSizeRequest
+		if !allowMethod(w, r, http.MethodPost) || !decodeRequest(w, r, &req) {
+			return
+		}
+		if err := p.IncreaseSize(req.Delta); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc(prefix+"/delete", func(w http.ResponseWriter, r *http.Request) {
+		var req deleteNodesRequest
+		if !allowMethod(w, r, http.MethodPost) || !decodeRequest(w, r, &req) {
+			return
+		}
+		if err := p.DeleteNodes(req.Nodes); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return false
+	}
+	return true
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to decode request: %v", err))
+		return false
+	}
+	return true
+}
+
+// writeError writes err, with the reason if it is a rejected scaling.ScaleRequest.
+func writeError(w http.ResponseWriter, code int, err error) {
+	resp := map[string]string{"error": err.Error()}
+	if reason := scaling.ReasonOf(err); reason != "" {
+		resp["reason"] = string(reason)
+	}
+	writeJSON(w, code, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn("failed to write response: %v", err)
+	}
+}
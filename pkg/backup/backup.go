@@ -0,0 +1,231 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/archive"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// the layout of a backup archive.
+const (
+	ManifestFile = "manifest.json"
+	Clusterfile  = "Clusterfile"
+	EtcdSnapshot = "etcd/snapshot.db"
+	// PKIDir is /etc/kubernetes/pki of master0.
+	PKIDir = "pki"
+	// StateDir is the sealer state of the cluster on the local host, the work dir, and the pki and certs sealer generates.
+	StateDir = "state"
+	// RegistryDir is the images pushed to the registry of the cluster.
+	RegistryDir = "registry"
+
+	stateWorkDir  = "work"
+	statePKIDir   = "pki"
+	stateCertsDir = "certs"
+
+	RemoteTarPKI = "tar -czf %s -C /etc/kubernetes pki"
+)
+
+// Manifest describes what a backup archive contains.
+type Manifest struct {
+	Cluster   string    `json:"cluster"`
+	Image     string    `json:"image"`
+	Masters   []string  `json:"masters"`
+	Nodes     []string  `json:"nodes,omitempty"`
+	Registry  bool      `json:"registry"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Options struct {
+	// SkipRegistry leaves the images pushed to the registry out of the backup.
+	SkipRegistry bool
+}
+
+// Create saves the etcd snapshot, /etc/kubernetes/pki of master0, the Clusterfile, the images pushed to the
+// registry and the sealer state of cluster to the tar.gz archive output.
+func Create(cluster *v2.Cluster, output string, opts Options) error {
+	dir, err := ioutil.TempDir(common.DefaultTmpDir, "backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	logger.Info("start to save the snapshot of etcd")
+	if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(EtcdSnapshot)), common.FileMode0755); err != nil {
+		return err
+	}
+	if err := plugin.SnapshotEtcd(cluster, filepath.Join(dir, EtcdSnapshot)); err != nil {
+		return fmt.Errorf("failed to save the snapshot of etcd: %v", err)
+	}
+	logger.Info("start to fetch the pki of %s", cluster.GetMaster0Ip())
+	if err := fetchPKI(cluster, dir); err != nil {
+		return err
+	}
+	if err := utils.MarshalYamlToFile(filepath.Join(dir, Clusterfile), cluster); err != nil {
+		return err
+	}
+	if err := saveState(cluster.Name, dir); err != nil {
+		return err
+	}
+	if !opts.SkipRegistry {
+		logger.Info("start to fetch the images pushed to the registry")
+		if err := runtime.ExportRegistryData(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName), dir); err != nil {
+			return err
+		}
+	}
+
+	manifest := Manifest{
+		Cluster:   cluster.Name,
+		Image:     cluster.Spec.Image,
+		Masters:   cluster.GetMasterIPList(),
+		Nodes:     cluster.GetNodeIPList(),
+		Registry:  utils.IsExist(filepath.Join(dir, RegistryDir)),
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), data, common.FileMode0644); err != nil {
+		return err
+	}
+	return writeArchive(dir, output)
+}
+
+func fetchPKI(cluster *v2.Cluster, dir string) error {
+	master0 := cluster.GetMaster0Ip()
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return err
+	}
+	tarball := filepath.Join(common.DefaultTmpDir, fmt.Sprintf("pki-%s.tar.gz", cluster.Name))
+	if err := client.CmdAsync(master0, fmt.Sprintf(RemoteTarPKI, tarball)); err != nil {
+		return fmt.Errorf("failed to archive the pki on %s: %v", master0, err)
+	}
+	defer func() {
+		if err := client.CmdAsync(master0, fmt.Sprintf("rm -f %s", tarball)); err != nil {
+			logger.Warn("failed to remove %s on %s: %v", tarball, master0, err)
+		}
+	}()
+	local := filepath.Join(dir, "pki.tar.gz")
+	if err := client.Fetch(master0, local, tarball); err != nil {
+		return fmt.Errorf("failed to fetch the pki of %s: %v", master0, err)
+	}
+	defer os.Remove(local)
+	return extract(local, dir)
+}
+
+// saveState copies the work dir of cluster, and the pki and certs sealer generates for it.
+func saveState(name, dir string) error {
+	dirs := map[string]string{
+		stateWorkDir:  common.GetClusterWorkDir(name),
+		statePKIDir:   common.TheDefaultClusterPKIDir(name),
+		stateCertsDir: common.TheDefaultClusterCertDir(name),
+	}
+	for dst, src := range dirs {
+		if !utils.IsExist(src) {
+			continue
+		}
+		if err := utils.RecursionCopy(src, filepath.Join(dir, StateDir, dst)); err != nil {
+			return fmt.Errorf("failed to save the sealer state %s: %v", src, err)
+		}
+	}
+	return nil
+}
+
+func writeArchive(dir, output string) error {
+	tarReader, err := archive.TarWithoutRootDir(dir)
+	if err != nil {
+		return err
+	}
+	defer tarReader.Close()
+	gzReader, done := archive.GzipCompress(tarReader)
+	defer gzReader.Close()
+
+	if err := utils.MkFileFullPathDir(output); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Clean(output), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, gzReader); err != nil {
+		return fmt.Errorf("failed to write backup %s: %v", output, err)
+	}
+	<-done
+	return nil
+}
+
+func extract(path, dir string) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := archive.Decompress(f, dir, archive.Options{}); err != nil {
+		return fmt.Errorf("failed to extract %s: %v", path, err)
+	}
+	return nil
+}
+
+// Open extracts the backup archive to a temp dir, which the caller removes.
+func Open(path string) (string, *Manifest, error) {
+	dir, err := ioutil.TempDir(common.DefaultTmpDir, "restore-")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := extract(path, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	manifest, err := loadManifest(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return dir, manifest, nil
+}
+
+func loadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("not a backup of sealer, %v", err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode %s of backup: %v", ManifestFile, err)
+	}
+	for _, f := range []string{Clusterfile, EtcdSnapshot, PKIDir} {
+		if !utils.IsExist(filepath.Join(dir, f)) {
+			return nil, fmt.Errorf("%s is missing in the backup of cluster %s", f, manifest.Cluster)
+		}
+	}
+	return manifest, nil
+}
@@ -0,0 +1,135 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const etcdManifest = `apiVersion: v1
+kind: Pod
+metadata:
+  name: etcd
+  namespace: kube-system
+spec:
+  containers:
+  - command:
+    - etcd
+    - --advertise-client-urls=https://192.168.0.2:2379
+    - --data-dir=/var/lib/etcd
+    - --initial-advertise-peer-urls=https://192.168.0.2:2380
+    - --initial-cluster=master-0=https://192.168.0.2:2380
+    - --name=master-0
+    image: sea.hub:5000/etcd:3.4.13-0
+    name: etcd
+`
+
+func TestParseEtcdManifest(t *testing.T) {
+	member, err := parseEtcdManifest([]byte(etcdManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &etcdMember{Image: "sea.hub:5000/etcd:3.4.13-0", Name: "master-0", PeerURL: "https://192.168.0.2:2380", DataDir: "/var/lib/etcd"}
+	if !reflect.DeepEqual(member, want) {
+		t.Errorf("parseEtcdManifest() = %+v, want %+v", member, want)
+	}
+	if _, err := parseEtcdManifest([]byte("apiVersion: v1\nkind: Pod\nspec:\n  containers: []\n")); err == nil {
+		t.Error("parseEtcdManifest() of no container should fail")
+	}
+
+	pod, err := restorePod(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"--initial-cluster=master-0=https://192.168.0.2:2380", "--data-dir=" + RestoreDir + "/data", "image: sea.hub:5000/etcd:3.4.13-0"} {
+		if !strings.Contains(string(pod), s) {
+			t.Errorf("restore pod has no %s:\n%s", s, pod)
+		}
+	}
+}
+
+func TestStaleNodes(t *testing.T) {
+	out := "master-0 192.168.0.2\nmaster-1 192.168.0.3\nnode-0 192.168.0.5\n"
+	if got := staleNodes(out, []string{"192.168.0.2"}); !reflect.DeepEqual(got, []string{"master-1", "node-0"}) {
+		t.Errorf("staleNodes() = %v", got)
+	}
+	if got := staleNodes("", []string{"192.168.0.2"}); len(got) != 0 {
+		t.Errorf("staleNodes() of no node = %v", got)
+	}
+}
+
+func TestReplaceMasters(t *testing.T) {
+	newCluster := func() *v2.Cluster {
+		return &v2.Cluster{Spec: v2.ClusterSpec{Hosts: []v2.Host{
+			{IPS: []string{"192.168.0.2", "192.168.0.3"}, Roles: []string{"master"}, Env: []string{"a=b"}},
+			{IPS: []string{"192.168.0.5"}, Roles: []string{"node"}},
+		}}}
+	}
+	cluster := newCluster()
+	if err := ReplaceMasters(cluster, []string{"192.168.0.8"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := cluster.GetMasterIPList(); !reflect.DeepEqual(got, []string{"192.168.0.8"}) {
+		t.Errorf("masters = %v", got)
+	}
+	if got := cluster.GetNodeIPList(); !reflect.DeepEqual(got, []string{"192.168.0.5"}) {
+		t.Errorf("nodes = %v", got)
+	}
+	if !reflect.DeepEqual(cluster.Spec.Hosts[0].Env, []string{"a=b"}) {
+		t.Errorf("the new master does not keep the env of the first master: %v", cluster.Spec.Hosts[0].Env)
+	}
+	if err := ReplaceMasters(newCluster(), []string{"192.168.0.5"}); err == nil {
+		t.Error("ReplaceMasters() with a node should fail")
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := loadManifest(dir); err == nil {
+		t.Error("loadManifest() of no manifest should fail")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), []byte(`{"cluster":"my-cluster"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadManifest(dir); err == nil || !strings.Contains(err.Error(), Clusterfile) {
+		t.Errorf("loadManifest() without Clusterfile error = %v", err)
+	}
+	for _, f := range []string{Clusterfile, EtcdSnapshot, filepath.Join(PKIDir, "ca.crt")} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest, err := loadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Cluster != "my-cluster" {
+		t.Errorf("cluster of manifest = %s", manifest.Cluster)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	ManifestsDir = "/etc/kubernetes/manifests"
+	// StoppedDir keeps the manifests of the control plane while etcd is restored, so kubelet stops them.
+	StoppedDir = "/etc/kubernetes/sealer-restore"
+	// RestoreDir is where the snapshot is restored on master0, by a static pod running etcdctl of the etcd image.
+	RestoreDir     = "/var/lib/sealer-etcd-restore"
+	restorePodName = "sealer-etcd-restore"
+
+	controlPlanePods = "etcd kube-apiserver kube-controller-manager kube-scheduler"
+
+	RemoteStopControlPlane  = "mkdir -p %[2]s && for f in " + controlPlanePods + "; do mv %[1]s/$f.yaml %[2]s/; done"
+	RemoteStartControlPlane = "for f in " + controlPlanePods + "; do [ ! -f %[2]s/$f.yaml ] || mv %[2]s/$f.yaml %[1]s/; done && rmdir %[2]s"
+	RemoteWaitEtcdStopped   = `for i in $(seq 60); do ss -ltn | grep -q ":2379 " || exit 0; sleep 2; done; echo "etcd is not stopped"; exit 1`
+	// the snap file is written last by etcdctl snapshot restore.
+	RemoteWaitRestored = `for i in $(seq 90); do ls %s/data/member/snap/*.snap >/dev/null 2>&1 && sleep 3 && exit 0; sleep 2; done; ` +
+		`echo "the snapshot of etcd is not restored"; exit 1`
+	RemoteMoveRestored = "rm -rf %[2]s/member && mkdir -p %[2]s && mv %[1]s/data/member %[2]s/member && rm -rf %[1]s"
+	// the kubelet registers its node again, which is not in the snapshot if master0 is replaced.
+	RemoteRestartKubelet  = "systemctl restart kubelet"
+	RemoteWaitAPIServer   = `for i in $(seq 90); do kubectl get --raw /readyz >/dev/null 2>&1 && exit 0; sleep 2; done; echo "apiserver is not ready"; exit 1`
+	RemoteUploadConfig    = "kubeadm init phase upload-config all --config=%s/kubeadm-config.yaml"
+	RemoteListNodeAddress = `kubectl get nodes -o jsonpath='{range .items[*]}{.metadata.name} {.status.addresses[?(@.type=="InternalIP")].address}{"\n"}{end}'`
+	RemoteDeleteNode      = "kubectl delete node %s --ignore-not-found"
+)
+
+// caFiles are the CAs and keys the certs sealer generates are signed by, so the restored cluster keeps its identity.
+var caFiles = []string{"ca.crt", "ca.key", "front-proxy-ca.crt", "front-proxy-ca.key", "etcd/ca.crt", "etcd/ca.key", "sa.key", "sa.pub"}
+
+// ReplaceMasters replaces the masters of cluster with masters, which keep the roles, ssh and env of the first master.
+func ReplaceMasters(cluster *v2.Cluster, masters []string) error {
+	if len(masters) == 0 {
+		return nil
+	}
+	old := cluster.GetMasterIPList()
+	if len(old) == 0 {
+		return fmt.Errorf("no master in the Clusterfile of the backup")
+	}
+	var first *v2.Host
+	var hosts []v2.Host
+	for _, h := range cluster.Spec.Hosts {
+		if first == nil && utils.InList(old[0], h.IPS) {
+			host := h
+			first = &host
+		}
+		var ips []string
+		for _, ip := range h.IPS {
+			if utils.NotIn(ip, old) {
+				ips = append(ips, ip)
+			}
+		}
+		for _, ip := range masters {
+			if utils.InList(ip, ips) {
+				return fmt.Errorf("new master %s is a node of the cluster", ip)
+			}
+		}
+		if len(ips) > 0 {
+			h.IPS = ips
+			hosts = append(hosts, h)
+		}
+	}
+	first.IPS = masters
+	cluster.Spec.Hosts = append([]v2.Host{*first}, hosts...)
+	return nil
+}
+
+// RestoreState writes the sealer state in the backup of dir to the local host. The CAs and keys of master0
+// overwrite the ones of the state, and the certs are signed by them for the masters when initializing.
+func RestoreState(dir, name string) error {
+	dirs := map[string]string{
+		stateWorkDir:  common.GetClusterWorkDir(name),
+		statePKIDir:   common.TheDefaultClusterPKIDir(name),
+		stateCertsDir: common.TheDefaultClusterCertDir(name),
+	}
+	for src, dst := range dirs {
+		src = filepath.Join(dir, StateDir, src)
+		if !utils.IsExist(src) {
+			continue
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := utils.RecursionCopy(src, dst); err != nil {
+			return fmt.Errorf("failed to restore the sealer state %s: %v", dst, err)
+		}
+	}
+	pki := common.TheDefaultClusterPKIDir(name)
+	for _, f := range caFiles {
+		src := filepath.Join(dir, PKIDir, f)
+		if !utils.IsFileExist(src) {
+			return fmt.Errorf("%s is missing in the pki of backup", f)
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(pki, f)), common.FileMode0755); err != nil {
+			return err
+		}
+		if _, err := utils.CopySingleFile(src, filepath.Join(pki, f)); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", f, err)
+		}
+	}
+	return nil
+}
+
+// etcdMember is the etcd static pod kubeadm writes on a master.
+type etcdMember struct {
+	Image, Name, PeerURL, DataDir string
+}
+
+func parseEtcdManifest(data []byte) (*etcdMember, error) {
+	pod := corev1.Pod{}
+	if err := yaml.Unmarshal(data, &pod); err != nil {
+		return nil, fmt.Errorf("failed to decode the manifest of etcd: %v", err)
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("no container in the manifest of etcd")
+	}
+	c := pod.Spec.Containers[0]
+	member := &etcdMember{Image: c.Image, DataDir: "/var/lib/etcd"}
+	for _, arg := range append(c.Command, c.Args...) {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "--name":
+			member.Name = kv[1]
+		case "--initial-advertise-peer-urls":
+			member.PeerURL = kv[1]
+		case "--data-dir":
+			member.DataDir = kv[1]
+		}
+	}
+	if member.Image == "" || member.Name == "" || member.PeerURL == "" {
+		return nil, fmt.Errorf("no image, name or peer url of etcd in its manifest")
+	}
+	return member, nil
+}
+
+// restorePod returns the static pod restoring the snapshot in RestoreDir as the single member of etcd.
+func restorePod(member *etcdMember) ([]byte, error) {
+	pod := corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: restorePodName, Namespace: "kube-system"},
+		Spec: corev1.PodSpec{
+			HostNetwork:   true,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:  "restore",
+				Image: member.Image,
+				Command: []string{"etcdctl", "snapshot", "restore", filepath.Join(RestoreDir, "snapshot.db"),
+					"--data-dir=" + filepath.Join(RestoreDir, "data"),
+					"--name=" + member.Name,
+					fmt.Sprintf("--initial-cluster=%s=%s", member.Name, member.PeerURL),
+					"--initial-advertise-peer-urls=" + member.PeerURL,
+				},
+				Env:          []corev1.EnvVar{{Name: "ETCDCTL_API", Value: "3"}},
+				VolumeMounts: []corev1.VolumeMount{{Name: "restore", MountPath: RestoreDir}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name:         "restore",
+				VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: RestoreDir}},
+			}},
+		},
+	}
+	return yaml.Marshal(pod)
+}
+
+// RestoreEtcd restores the snapshot of etcd in the backup of dir on master0 initialized, as a cluster of the
+// single member. The control plane of master0 is stopped meanwhile, and the masters joined later sync from it.
+func RestoreEtcd(cluster *v2.Cluster, dir string) (err error) {
+	master0 := cluster.GetMaster0Ip()
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return err
+	}
+	data, err := client.Cmd(master0, fmt.Sprintf("cat %s/etcd.yaml", ManifestsDir))
+	if err != nil {
+		return fmt.Errorf("failed to read the manifest of etcd on %s: %v", master0, err)
+	}
+	member, err := parseEtcdManifest(data)
+	if err != nil {
+		return err
+	}
+	pod, err := restorePod(member)
+	if err != nil {
+		return err
+	}
+	local, err := ioutil.TempFile(common.DefaultTmpDir, restorePodName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local.Name())
+	if _, err = local.Write(pod); err != nil {
+		local.Close()
+		return err
+	}
+	local.Close()
+
+	if err = client.Copy(master0, filepath.Join(dir, EtcdSnapshot), filepath.Join(RestoreDir, "snapshot.db")); err != nil {
+		return fmt.Errorf("failed to copy the snapshot of etcd to %s: %v", master0, err)
+	}
+	logger.Info("start to restore the snapshot of etcd on %s", master0)
+	if err = client.CmdAsync(master0, fmt.Sprintf(RemoteStopControlPlane, ManifestsDir, StoppedDir), RemoteWaitEtcdStopped); err != nil {
+		return fmt.Errorf("failed to stop the control plane of %s: %v", master0, err)
+	}
+	defer func() {
+		if err != nil {
+			if e := client.CmdAsync(master0, fmt.Sprintf(RemoteStartControlPlane, ManifestsDir, StoppedDir)); e != nil {
+				logger.Error("failed to start the control plane of %s: %v", master0, e)
+			}
+		}
+	}()
+	restoreManifest := filepath.Join(ManifestsDir, restorePodName+".yaml")
+	if err = client.Copy(master0, local.Name(), restoreManifest); err != nil {
+		return err
+	}
+	err = client.CmdAsync(master0, fmt.Sprintf(RemoteWaitRestored, RestoreDir))
+	if e := client.CmdAsync(master0, "rm -f "+restoreManifest); e != nil {
+		logger.Warn("failed to remove %s on %s: %v", restoreManifest, master0, e)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore the snapshot of etcd on %s: %v", master0, err)
+	}
+	if err = client.CmdAsync(master0, fmt.Sprintf(RemoteMoveRestored, RestoreDir, member.DataDir),
+		fmt.Sprintf(RemoteStartControlPlane, ManifestsDir, StoppedDir), RemoteWaitAPIServer, RemoteRestartKubelet); err != nil {
+		return fmt.Errorf("failed to start the control plane of %s with the restored etcd: %v", master0, err)
+	}
+	// the kubeadm config in the snapshot has the etcd servers and endpoints of the masters backed up.
+	return client.CmdAsync(master0, fmt.Sprintf(RemoteUploadConfig, common.DefaultTheClusterRootfsDir(cluster.Name)))
+}
+
+// staleNodes returns the nodes in the output of RemoteListNodeAddress except the ones of hosts.
+func staleNodes(out string, hosts []string) []string {
+	var nodes []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && utils.NotIn(fields[1], hosts) {
+			nodes = append(nodes, fields[0])
+		}
+	}
+	return nodes
+}
+
+// RemoveStaleNodes deletes the nodes restored from the backup except master0, for kubeadm refuses to join a
+// host whose node is already registered, and the nodes of the masters replaced are gone.
+func RemoveStaleNodes(cluster *v2.Cluster) error {
+	master0 := cluster.GetMaster0Ip()
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return err
+	}
+	out, err := client.CmdToString(master0, RemoteListNodeAddress, "\n")
+	if err != nil {
+		return fmt.Errorf("failed to list the nodes: %v", err)
+	}
+	for _, node := range staleNodes(out, []string{master0}) {
+		logger.Info("delete node %s restored from the backup, it joins again if it is in the cluster", node)
+		if err := client.CmdAsync(master0, fmt.Sprintf(RemoteDeleteNode, node)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
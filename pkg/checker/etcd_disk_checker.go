@@ -0,0 +1,101 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/hostprep"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// DefaultEtcdMaxSyncLatency is the max latency of fdatasync etcd recommends.
+	DefaultEtcdMaxSyncLatency = 10 * time.Millisecond
+	etcdSyncWrites            = 500
+	// RemoteCheckEtcdDisk writes like the WAL of etcd, each block of 2300 bytes synced, and prints the summary of dd.
+	RemoteCheckEtcdDisk = "mkdir -p %[1]s && dd if=/dev/zero of=%[1]s/.sealer-disk-check bs=2300 count=%[2]d oflag=dsync 2>&1 | tail -1; " +
+		"rm -f %[1]s/.sealer-disk-check"
+)
+
+// EtcdDiskChecker checks the latency of the synced writes to the data dir of etcd on masters,
+// only if spec.hostPrep.etcdMaxSyncLatency is set or a disk is mounted to /var/lib/etcd.
+type EtcdDiskChecker struct {
+	hosts []string
+}
+
+func (e EtcdDiskChecker) Check(cluster *v2.Cluster, phase string) error {
+	if phase != PhasePre {
+		return nil
+	}
+	max := cluster.Spec.HostPrep.EtcdMaxSyncLatency.Duration
+	for _, ip := range e.hosts {
+		if utils.NotIn(ip, cluster.GetMasterIPList()) || (max == 0 && !hostprep.HasEtcdDisk(cluster, ip)) {
+			continue
+		}
+		limit := max
+		if limit == 0 {
+			limit = DefaultEtcdMaxSyncLatency
+		}
+		s, err := ssh.GetHostSSHClient(ip, cluster)
+		if err != nil {
+			return fmt.Errorf("checker: failed to get host %s client,%v", ip, err)
+		}
+		out, err := s.CmdToString(ip, fmt.Sprintf(RemoteCheckEtcdDisk, hostprep.EtcdDataDir, etcdSyncWrites), "")
+		if err != nil {
+			return fmt.Errorf("checker: failed to write %s of %s, %v", hostprep.EtcdDataDir, ip, err)
+		}
+		elapsed, err := parseDDElapsed(out)
+		if err != nil {
+			return fmt.Errorf("checker: failed to check the disk of etcd on %s, %v", ip, err)
+		}
+		latency := elapsed / etcdSyncWrites
+		iops := int(float64(etcdSyncWrites) / elapsed.Seconds())
+		logger.Info("the synced writes to %s of %s take %s, %d IOPS", hostprep.EtcdDataDir, ip, latency, iops)
+		if latency > limit {
+			return fmt.Errorf("checker: the synced writes to %s of %s take %s, %d IOPS, more than %s etcd allows, use a faster disk for it",
+				hostprep.EtcdDataDir, ip, latency, iops, limit)
+		}
+	}
+	return nil
+}
+
+// parseDDElapsed returns the elapsed time in the summary of dd, like:
+// 1150000 bytes (1.2 MB, 1.1 MiB) copied, 2.11372 s, 544 kB/s
+func parseDDElapsed(out string) (time.Duration, error) {
+	i := strings.Index(out, "copied,")
+	if i < 0 {
+		return 0, fmt.Errorf("unexpected output of dd: %s", out)
+	}
+	fields := strings.Fields(strings.TrimSpace(out[i+len("copied,"):]))
+	if len(fields) < 2 || strings.TrimSuffix(fields[1], ",") != "s" {
+		return 0, fmt.Errorf("unexpected output of dd: %s", out)
+	}
+	s, err := strconv.ParseFloat(strings.Replace(fields[0], ",", ".", 1), 64)
+	if err != nil || s <= 0 {
+		return 0, fmt.Errorf("unexpected output of dd: %s", out)
+	}
+	return time.Duration(s * float64(time.Second)), nil
+}
+
+func NewEtcdDiskChecker(hosts []string) Interface {
+	return &EtcdDiskChecker{hosts: hosts}
+}
@@ -0,0 +1,44 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDDElapsed(t *testing.T) {
+	tests := []struct {
+		out     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1150000 bytes (1.2 MB, 1.1 MiB) copied, 2.5 s, 460 kB/s\n", 2500 * time.Millisecond, false},
+		{"1150000 bytes (1.2 MB) copied, 0.75 s, 1.5 MB/s", 750 * time.Millisecond, false},
+		{"1150000 Bytes (1,2 MB, 1,1 MiB) copied, 1,5 s, 767 kB/s", 1500 * time.Millisecond, false},
+		{"dd: failed to open '/var/lib/etcd/.sealer-disk-check': Read-only file system", 0, true},
+		{"1150000 bytes copied, 0 s, inf B/s", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDDElapsed(tt.out)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDDElapsed(%q) error = %v, wantErr %v", tt.out, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDDElapsed(%q) = %s, want %s", tt.out, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/alibaba/sealer/pkg/facts"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const (
+	CgroupV1   = "v1"
+	CgroupV2   = "v2"
+	Docker     = "docker"
+	Containerd = "containerd"
+	CRIO       = "crio"
+)
+
+// CgroupV2MinCRIVersions are the oldest versions of the container runtimes supporting cgroup v2.
+var CgroupV2MinCRIVersions = map[string]string{
+	Docker:     "20.10.0",
+	Containerd: "1.4.0",
+	CRIO:       "1.20.0",
+}
+
+// MetadataChecker checks the hosts meet the kernel, cgroup and CRI requirements in the Metadata of CloudImage.
+type MetadataChecker struct {
+	hosts    []string
+	metadata *runtime.Metadata
+}
+
+func (m MetadataChecker) Check(cluster *v2.Cluster, phase string) error {
+	if phase != PhasePre || m.metadata == nil {
+		return nil
+	}
+	for _, ip := range m.hosts {
+		h, err := facts.Get(cluster, ip)
+		if err != nil {
+			return fmt.Errorf("checker: failed to get facts of host %s, %v", ip, err)
+		}
+		if err = m.checkKernel(h); err != nil {
+			return err
+		}
+		if err = m.checkCgroup(h); err != nil {
+			return err
+		}
+		if err = m.checkCRI(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MetadataChecker) checkKernel(h *facts.Host) error {
+	if m.metadata.MinKernelVersion == "" {
+		return nil
+	}
+	ok, err := KernelVersionAtLeast(h.Kernel, m.metadata.MinKernelVersion)
+	if err != nil {
+		return fmt.Errorf("checker: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("checker: the kernel %s of %s is older than %s required by CloudImage", h.Kernel, h.IP, m.metadata.MinKernelVersion)
+	}
+	return nil
+}
+
+// checkCgroup checks the cgroup version of the host is the one CloudImage requires, and is supported by the
+// container runtime of CloudImage.
+func (m MetadataChecker) checkCgroup(h *facts.Host) error {
+	if m.metadata.CgroupVersion == "" && m.metadata.CRIVersion == "" {
+		return nil
+	}
+	version := CgroupV1
+	if h.CgroupV2() {
+		version = CgroupV2
+	}
+	if m.metadata.CgroupVersion != "" && version != m.metadata.CgroupVersion {
+		return fmt.Errorf("checker: the cgroup of %s is %s, but CloudImage requires %s", h.IP, version, m.metadata.CgroupVersion)
+	}
+	if version == CgroupV2 {
+		if err := CheckCgroupV2Support(m.metadata.CRI, m.metadata.CRIVersion); err != nil {
+			return fmt.Errorf("checker: the cgroup of %s is v2, but %v, use a host of cgroup v1 or a newer CloudImage", h.IP, err)
+		}
+	}
+	return nil
+}
+
+// CheckCgroupV2Support returns an error if the version of cri is older than the one supporting cgroup v2,
+// it returns nil if either is unknown.
+func CheckCgroupV2Support(cri, version string) error {
+	min, ok := CgroupV2MinCRIVersions[cri]
+	if !ok || version == "" {
+		return nil
+	}
+	supported, err := versionAtLeast(version, min)
+	if err != nil {
+		return fmt.Errorf("invalid version %s of %s: %v", version, cri, err)
+	}
+	if !supported {
+		return fmt.Errorf("%s %s of CloudImage supports it since %s", cri, version, min)
+	}
+	return nil
+}
+
+// checkCRI rejects the host running a container runtime other than the one installed by CloudImage,
+// containerd is skipped for docker as it is run by docker itself.
+func (m MetadataChecker) checkCRI(h *facts.Host) error {
+	if m.metadata.CRI == "" {
+		return nil
+	}
+	for _, cri := range []string{Docker, Containerd, CRIO} {
+		if cri == m.metadata.CRI || (m.metadata.CRI == Docker && cri == Containerd) {
+			continue
+		}
+		if h.CRIActive(cri) {
+			return fmt.Errorf("checker: %s is running on %s, but CloudImage installs %s", cri, h.IP, m.metadata.CRI)
+		}
+	}
+	return nil
+}
+
+// KernelVersionAtLeast returns true if the kernel release, like 4.19.91-24.1.al7.x86_64, is not older than min.
+func KernelVersionAtLeast(release, min string) (bool, error) {
+	ok, err := versionAtLeast(release, min)
+	if err != nil {
+		return false, fmt.Errorf("invalid kernel version: %v", err)
+	}
+	return ok, nil
+}
+
+// versionAtLeast compares the versions without the build and distribution suffix, like 19.03.15-ce.
+func versionAtLeast(version, min string) (bool, error) {
+	v, err := semver.NewVersion(trimKernelRelease(version))
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", version, err)
+	}
+	m, err := semver.NewVersion(trimKernelRelease(min))
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", min, err)
+	}
+	return !v.LessThan(m), nil
+}
+
+// trimKernelRelease drops the build and distribution suffix of kernel release.
+func trimKernelRelease(release string) string {
+	release = strings.TrimSpace(release)
+	if i := strings.IndexAny(release, "-+_"); i >= 0 {
+		release = release[:i]
+	}
+	return release
+}
+
+func NewMetadataChecker(hosts []string, metadata *runtime.Metadata) Interface {
+	return &MetadataChecker{hosts: hosts, metadata: metadata}
+}
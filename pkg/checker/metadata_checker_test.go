@@ -0,0 +1,63 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import "testing"
+
+func TestKernelVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		release string
+		min     string
+		want    bool
+	}{
+		{"4.19.91-24.1.al7.x86_64", "4.19", true},
+		{"3.10.0-1160.el7.x86_64", "4.19", false},
+		{"5.4.0", "5.4.0", true},
+		{"5.10.0+\n", "4.19.0", true},
+		{"4.18.0-305.el8.x86_64", "4.19", false},
+	}
+	for _, tt := range tests {
+		got, err := KernelVersionAtLeast(tt.release, tt.min)
+		if err != nil {
+			t.Fatalf("KernelVersionAtLeast(%q, %q) error = %v", tt.release, tt.min, err)
+		}
+		if got != tt.want {
+			t.Errorf("KernelVersionAtLeast(%q, %q) = %v, want %v", tt.release, tt.min, got, tt.want)
+		}
+	}
+	if _, err := KernelVersionAtLeast("unknown", "4.19"); err == nil {
+		t.Errorf("expected error of invalid kernel version")
+	}
+}
+
+func TestCheckCgroupV2Support(t *testing.T) {
+	tests := []struct {
+		cri     string
+		version string
+		wantErr bool
+	}{
+		{Docker, "19.03.15", true},
+		{Docker, "20.10.7", false},
+		{Containerd, "1.3.9", true},
+		{Containerd, "1.6.4", false},
+		{Docker, "", false},
+		{"unknown", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if err := CheckCgroupV2Support(tt.cri, tt.version); (err != nil) != tt.wantErr {
+			t.Errorf("CheckCgroupV2Support(%s, %s) error = %v, wantErr %v", tt.cri, tt.version, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"fmt"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// RemoteCheckChronySync waits up to 60s, checking every second, until the correction of chrony is less than 1s.
+// The arguments are max-tries, max-correction, max-skew (0 to not check it) and interval.
+const RemoteCheckChronySync = "chronyc waitsync 60 1 0 1"
+
+// TimeSyncChecker checks chrony synchronized on all hosts, only if time sync is enabled in Clusterfile.
+type TimeSyncChecker struct {
+	hosts []string
+}
+
+func (t TimeSyncChecker) Check(cluster *v2.Cluster, phase string) error {
+	if phase != PhasePre || !cluster.Spec.TimeSync.Enabled {
+		return nil
+	}
+	for _, ip := range t.hosts {
+		s, err := ssh.GetHostSSHClient(ip, cluster)
+		if err != nil {
+			return fmt.Errorf("checker: failed to get host %s client,%v", ip, err)
+		}
+		if err := s.CmdAsync(ip, RemoteCheckChronySync); err != nil {
+			return fmt.Errorf("checker: the time of %s node is not synchronized by chrony, %v", ip, err)
+		}
+	}
+	return nil
+}
+
+func NewTimeSyncChecker(hosts []string) Interface {
+	return &TimeSyncChecker{hosts: hosts}
+}
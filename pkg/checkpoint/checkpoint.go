@@ -0,0 +1,157 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const fileName = "checkpoint.json"
+
+// RemoteDir keeps the checkpoints on master0, so another machine with the Clusterfile resumes the operation
+// stopped with the machine running it by "sealer apply --resume".
+const RemoteDir = "/var/lib/sealer/checkpoints"
+
+// Checkpoint records where an operation on a cluster was aborted, the next run of the operation resumes
+// by running its phases again, which are idempotent.
+type Checkpoint struct {
+	Operation string `json:"operation"`
+	Image     string `json:"image,omitempty"`
+	// Phase is the phase aborted at, Step is its index in the pipeline of the operation.
+	Phase           string   `json:"phase"`
+	Step            int      `json:"step"`
+	CompletedPhases []string `json:"completedPhases,omitempty"`
+	// Hosts are the hosts of the cluster the operation runs on, FailedHosts are the ones Phase failed on.
+	Hosts       []string `json:"hosts,omitempty"`
+	FailedHosts []string `json:"failedHosts,omitempty"`
+	Reason      string   `json:"reason,omitempty"`
+	// AbortedAt is zero if the machine running the operation stopped during Phase, which started at UpdatedAt.
+	AbortedAt time.Time `json:"abortedAt"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+func path(clusterName string) string {
+	return filepath.Join(common.GetClusterWorkDir(clusterName), fileName)
+}
+
+// Save records cp as the checkpoint of cluster clusterName.
+func Save(clusterName string, cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = utils.WriteFile(path(clusterName), data); err != nil {
+		return fmt.Errorf("failed to save checkpoint of cluster %s: %v", clusterName, err)
+	}
+	return nil
+}
+
+// Load returns the checkpoint of cluster clusterName, nil if its last operation was not aborted.
+func Load(clusterName string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path(clusterName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint of cluster %s: %v", clusterName, err)
+	}
+	return cp, nil
+}
+
+// Remove deletes the checkpoint of cluster clusterName after its operation succeeded.
+func Remove(clusterName string) error {
+	err := os.Remove(path(clusterName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// RemotePath returns the path of the checkpoint of cluster clusterName on master0.
+func RemotePath(clusterName string) string {
+	return filepath.Join(RemoteDir, clusterName+".json")
+}
+
+// SaveRemote records cp as the checkpoint of cluster clusterName on host by sftp.
+func SaveRemote(client ssh.Interface, host, clusterName string, cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile("", "sealer-checkpoint")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	remote := RemotePath(clusterName)
+	if err = client.CmdAsync(host, "mkdir -p "+utils.ShellQuote(RemoteDir)); err != nil {
+		return fmt.Errorf("failed to save checkpoint of cluster %s on %s: %v", clusterName, host, err)
+	}
+	if err = client.Copy(host, f.Name(), remote+".tmp"); err != nil {
+		return fmt.Errorf("failed to save checkpoint of cluster %s on %s: %v", clusterName, host, err)
+	}
+	return client.CmdAsync(host, fmt.Sprintf("mv -f %s %s", utils.ShellQuote(remote+".tmp"), utils.ShellQuote(remote)))
+}
+
+// LoadRemote returns the checkpoint of cluster clusterName on host, nil if there is none.
+func LoadRemote(client ssh.Interface, host, clusterName string) (*Checkpoint, error) {
+	remote := RemotePath(clusterName)
+	if !client.IsFileExist(host, remote) {
+		return nil, nil
+	}
+	dir, err := ioutil.TempDir("", "sealer-checkpoint")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, fileName)
+	if err = client.Fetch(host, local, remote); err != nil {
+		return nil, fmt.Errorf("failed to fetch checkpoint of cluster %s from %s: %v", clusterName, host, err)
+	}
+	data, err := ioutil.ReadFile(local)
+	if err != nil {
+		return nil, err
+	}
+	cp := &Checkpoint{}
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint of cluster %s on %s: %v", clusterName, host, err)
+	}
+	return cp, nil
+}
+
+// RemoveRemote deletes the checkpoint of cluster clusterName on host after its operation succeeded.
+func RemoveRemote(client ssh.Interface, host, clusterName string) error {
+	return client.CmdAsync(host, "rm -f "+utils.ShellQuote(RemotePath(clusterName)))
+}
@@ -0,0 +1,118 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoint
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// fakeSSH keeps the files of the host in root, and runs the commands on them by sh.
+type fakeSSH struct {
+	ssh.Interface
+	root string
+	cmds []string
+}
+
+func (f *fakeSSH) path(remote string) string {
+	return filepath.Join(f.root, remote)
+}
+
+func (f *fakeSSH) Copy(host, localPath, remotePath string) error {
+	data, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.path(remotePath), data, 0644)
+}
+
+func (f *fakeSSH) Fetch(host, localPath, remotePath string) error {
+	data, err := ioutil.ReadFile(f.path(remotePath))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(localPath, data, 0644)
+}
+
+func (f *fakeSSH) IsFileExist(host, remotePath string) bool {
+	_, err := os.Stat(f.path(remotePath))
+	return err == nil
+}
+
+func (f *fakeSSH) CmdAsync(host string, cmds ...string) error {
+	for _, cmd := range cmds {
+		f.cmds = append(f.cmds, cmd)
+		cmd = strings.Replace(cmd, RemoteDir, f.path(RemoteDir), -1)
+		if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to run %s: %v, %s", cmd, err, out)
+		}
+	}
+	return nil
+}
+
+func TestRemoteRoundTrip(t *testing.T) {
+	client := &fakeSSH{root: t.TempDir()}
+	// the name is quoted in the commands.
+	const clusterName = "it's $(touch x) cluster"
+	cp, err := LoadRemote(client, "192.168.0.2", clusterName)
+	if err != nil || cp != nil {
+		t.Fatalf("LoadRemote() without checkpoint = %v, %v, want nil", cp, err)
+	}
+
+	want := &Checkpoint{
+		Operation:       "create",
+		Image:           "kubernetes:v1.19.8",
+		Phase:           "Init",
+		Step:            3,
+		CompletedPhases: []string{"MountImage", "RunConfig", "MountRootfs"},
+		Hosts:           []string{"192.168.0.2", "192.168.0.3"},
+		FailedHosts:     []string{"192.168.0.3"},
+		Reason:          "context canceled",
+		AbortedAt:       time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:       time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err = SaveRemote(client, "192.168.0.2", clusterName, want); err != nil {
+		t.Fatalf("SaveRemote() error = %v", err)
+	}
+	if _, err = os.Stat(client.path(RemotePath(clusterName) + ".tmp")); !os.IsNotExist(err) {
+		t.Errorf("expected the tmp checkpoint renamed, got %v", err)
+	}
+	got, err := LoadRemote(client, "192.168.0.2", clusterName)
+	if err != nil {
+		t.Fatalf("LoadRemote() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadRemote() = %+v, want %+v", got, want)
+	}
+
+	if err = RemoveRemote(client, "192.168.0.2", clusterName); err != nil {
+		t.Fatalf("RemoveRemote() error = %v", err)
+	}
+	if got, err = LoadRemote(client, "192.168.0.2", clusterName); err != nil || got != nil {
+		t.Errorf("LoadRemote() after RemoveRemote() = %v, %v, want nil", got, err)
+	}
+	if _, err = os.Stat("x"); !os.IsNotExist(err) {
+		t.Errorf("expected the cluster name not run by shell")
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterdiff
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/sealer/pkg/env"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// Category is what changed in the Clusterfile besides the hosts joined and deleted.
+type Category string
+
+const (
+	CategoryEnv        Category = "env"
+	CategoryLabels     Category = "labels"
+	CategoryTaints     Category = "taints"
+	CategorySSH        Category = "ssh"
+	CategoryKubernetes Category = "kubernetes"
+	CategoryRegistries Category = "registries"
+)
+
+// Action reconciles a category of change on the hosts.
+type Action string
+
+const (
+	// ActionRenderConfigs mounts rootfs to the hosts again, which renders the configs and scripts with the new env.
+	ActionRenderConfigs Action = "render-configs"
+	// ActionRerunPlugins runs the PostInstall plugins on the hosts again, as they may read the env.
+	ActionRerunPlugins Action = "rerun-plugins"
+	// ActionUpdateNodes sets the labels and taints to the nodes of the hosts.
+	ActionUpdateNodes Action = "update-nodes"
+	// ActionCheckSSH connects the hosts with the new ssh config.
+	ActionCheckSSH Action = "check-ssh"
+	// ActionRestartKubelet writes the kubelet extra args to the hosts and restarts kubelet.
+	ActionRestartKubelet Action = "restart-kubelet"
+	// ActionRegenerateControlPlane regenerates the static pod manifests of apiserver, controller-manager and scheduler on masters.
+	ActionRegenerateControlPlane Action = "regenerate-control-plane"
+	// ActionConfigureRegistries writes the configs and credentials of the registries to the hosts.
+	ActionConfigureRegistries Action = "configure-registries"
+	// ActionManual is a change sealer does not reconcile, Detail tells how to.
+	ActionManual Action = "manual"
+)
+
+// Change is a category of change on the hosts and the actions to reconcile it, in order.
+type Change struct {
+	Category Category
+	Hosts    []string
+	Detail   string
+	Actions  []Action
+}
+
+// Plan is what applying the desired Clusterfile does to the current cluster.
+type Plan struct {
+	JoinMasters   []string
+	DeleteMasters []string
+	JoinNodes     []string
+	DeleteNodes   []string
+	// Image is the new image applied to the cluster, empty if unchanged.
+	Image   string
+	Changes []Change
+}
+
+// Diff returns the changes from current to desired on the hosts in both of them, and the labels and taints of
+// the joined hosts. The joined and deleted hosts themselves are not changes, they are in Plan.
+func Diff(current, desired *v2.Cluster) []Change {
+	var changes []Change
+	var envHosts, sshHosts []string
+	var envDetails []string
+	for _, ip := range hostIPs(desired) {
+		joined := utils.NotIn(ip, hostIPs(current))
+		if !joined {
+			if d := envDiff(env.GetHostEnv(current, ip), env.GetHostEnv(desired, ip)); d != "" {
+				envHosts = append(envHosts, ip)
+				envDetails = append(envDetails, fmt.Sprintf("%s: %s", ip, d))
+			}
+			if !reflect.DeepEqual(hostSSH(current, ip), hostSSH(desired, ip)) {
+				sshHosts = append(sshHosts, ip)
+			}
+		}
+		oldHost, newHost := HostOf(current, ip), HostOf(desired, ip)
+		if d := labelsDiff(oldHost.Labels, newHost.Labels); d != "" {
+			changes = append(changes, Change{Category: CategoryLabels, Hosts: []string{ip}, Detail: d, Actions: []Action{ActionUpdateNodes}})
+		}
+		if d := taintsDiff(oldHost.Taints, newHost.Taints); d != "" {
+			changes = append(changes, Change{Category: CategoryTaints, Hosts: []string{ip}, Detail: d, Actions: []Action{ActionUpdateNodes}})
+		}
+	}
+	if len(envHosts) > 0 {
+		changes = append(changes, Change{Category: CategoryEnv, Hosts: envHosts, Detail: strings.Join(envDetails, "; "),
+			Actions: []Action{ActionRenderConfigs, ActionRerunPlugins}})
+	}
+	if len(sshHosts) > 0 {
+		changes = append(changes, Change{Category: CategorySSH, Hosts: sshHosts, Detail: "ssh config changed", Actions: []Action{ActionCheckSSH}})
+	}
+	changes = append(changes, kubernetesDiff(current, desired)...)
+	return append(changes, registriesDiff(current, desired)...)
+}
+
+func registriesDiff(current, desired *v2.Cluster) []Change {
+	if reflect.DeepEqual(current.Spec.Registries, desired.Spec.Registries) {
+		return nil
+	}
+	// the joined hosts get the new registries by joining.
+	hosts := utils.ReduceIPList(hostIPs(desired), hostIPs(current))
+	if len(hosts) == 0 {
+		return nil
+	}
+	return []Change{{Category: CategoryRegistries, Hosts: hosts, Detail: "spec.registries changed",
+		Actions: []Action{ActionConfigureRegistries}}}
+}
+
+func kubernetesDiff(current, desired *v2.Cluster) []Change {
+	o, n := current.Spec.Kubernetes, desired.Spec.Kubernetes
+	// the joined hosts get the new spec by joining.
+	masters := utils.ReduceIPList(desired.GetMasterIPList(), current.GetMasterIPList())
+	all := append(append([]string{}, masters...), utils.ReduceIPList(desired.GetNodeIPList(), current.GetNodeIPList())...)
+	if len(all) == 0 {
+		return nil
+	}
+	var changes []Change
+	var components []string
+	for name, changed := range map[string]bool{
+		"apiServer":         !reflect.DeepEqual(o.APIServer, n.APIServer),
+		"controllerManager": !reflect.DeepEqual(o.ControllerManager, n.ControllerManager),
+		"scheduler":         !reflect.DeepEqual(o.Scheduler, n.Scheduler),
+		"audit":             !reflect.DeepEqual(o.Audit, n.Audit),
+	} {
+		if changed {
+			components = append(components, name)
+		}
+	}
+	if len(components) > 0 {
+		sort.Strings(components)
+		changes = append(changes, Change{Category: CategoryKubernetes, Hosts: masters,
+			Detail: "spec.kubernetes " + strings.Join(components, ", ") + " changed", Actions: []Action{ActionRegenerateControlPlane}})
+	}
+	if !reflect.DeepEqual(o.Kubelet.ExtraArgs, n.Kubelet.ExtraArgs) {
+		changes = append(changes, Change{Category: CategoryKubernetes, Hosts: all,
+			Detail: "spec.kubernetes.kubelet.extraArgs " + mapDiff(o.Kubelet.ExtraArgs, n.Kubelet.ExtraArgs), Actions: []Action{ActionRestartKubelet}})
+	}
+	for _, m := range []struct {
+		name    string
+		changed bool
+		how     string
+	}{
+		{"etcd", !reflect.DeepEqual(o.Etcd, n.Etcd), "edit /etc/kubernetes/manifests/etcd.yaml on each master"},
+		{"encryptionAtRest", !reflect.DeepEqual(o.EncryptionAtRest, n.EncryptionAtRest), "use sealer encryption"},
+		{"dns", !reflect.DeepEqual(o.DNS, n.DNS), "edit the coredns ConfigMap and the kubelet config"},
+		{"kubelet.extraVolumes", !reflect.DeepEqual(o.Kubelet.ExtraVolumes, n.Kubelet.ExtraVolumes), "kubelet does not run in a pod, it has no volumes"},
+	} {
+		if m.changed {
+			changes = append(changes, Change{Category: CategoryKubernetes, Hosts: masters,
+				Detail: fmt.Sprintf("spec.kubernetes.%s changed, %s", m.name, m.how), Actions: []Action{ActionManual}})
+		}
+	}
+	return changes
+}
+
+func hostIPs(cluster *v2.Cluster) []string {
+	return append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+}
+
+// HostOf returns the host group of ip in cluster, empty if ip is not in it.
+func HostOf(cluster *v2.Cluster, ip string) v2.Host {
+	for _, host := range cluster.Spec.Hosts {
+		if utils.InList(ip, host.IPS) {
+			return host
+		}
+	}
+	return v2.Host{}
+}
+
+// hostSSH returns the ssh config of ip, its host ssh overwrites cluster.Spec.SSH.
+func hostSSH(cluster *v2.Cluster, ip string) v1.SSH {
+	ssh := cluster.Spec.SSH
+	h := HostOf(cluster, ip).SSH
+	if h.User != "" {
+		ssh.User = h.User
+	}
+	if h.Passwd != "" {
+		ssh.Passwd = h.Passwd
+	}
+	if h.Pk != "" {
+		ssh.Pk = h.Pk
+	}
+	if h.PkPasswd != "" {
+		ssh.PkPasswd = h.PkPasswd
+	}
+	if h.Port != "" {
+		ssh.Port = h.Port
+	}
+	return ssh
+}
+
+func envDiff(o, n map[string]interface{}) string {
+	var keys []string
+	for k, v := range n {
+		if !reflect.DeepEqual(o[k], v) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range o {
+		if _, ok := n[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	// the values of env are not shown, they may be passwords.
+	sort.Strings(keys)
+	return "env " + strings.Join(keys, ",") + " changed"
+}
+
+func labelsDiff(o, n map[string]string) string {
+	if reflect.DeepEqual(o, n) || len(o) == 0 && len(n) == 0 {
+		return ""
+	}
+	return "labels " + mapDiff(o, n)
+}
+
+func taintsDiff(o, n []string) string {
+	added, removed := utils.GetDiffHosts(o, n)
+	if len(added) == 0 && len(removed) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, t := range added {
+		parts = append(parts, "+"+t)
+	}
+	for _, t := range removed {
+		parts = append(parts, "-"+t)
+	}
+	return "taints " + strings.Join(parts, " ")
+}
+
+// mapDiff shows the keys added, changed and removed, like: +a=1 ~b=2 -c.
+func mapDiff(o, n map[string]string) string {
+	var parts []string
+	for k, v := range n {
+		if ov, ok := o[k]; !ok {
+			parts = append(parts, fmt.Sprintf("+%s=%s", k, v))
+		} else if ov != v {
+			parts = append(parts, fmt.Sprintf("~%s=%s", k, v))
+		}
+	}
+	for k := range o {
+		if _, ok := n[k]; !ok {
+			parts = append(parts, "-"+k)
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i][1:] < parts[j][1:]
+	})
+	return strings.Join(parts, " ")
+}
+
+// Empty returns whether applying the plan changes nothing.
+func (p *Plan) Empty() bool {
+	return len(p.JoinMasters) == 0 && len(p.DeleteMasters) == 0 && len(p.JoinNodes) == 0 && len(p.DeleteNodes) == 0 &&
+		p.Image == "" && len(p.Changes) == 0
+}
+
+// Print writes the plan for humans to w.
+func (p *Plan) Print(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintln(w, "No changes, the cluster is up to date.")
+		return
+	}
+	for _, hosts := range []struct {
+		what string
+		ips  []string
+	}{
+		{"join masters", p.JoinMasters},
+		{"delete masters", p.DeleteMasters},
+		{"join nodes", p.JoinNodes},
+		{"delete nodes", p.DeleteNodes},
+	} {
+		if len(hosts.ips) > 0 {
+			fmt.Fprintf(w, "%s: %s\n", hosts.what, strings.Join(hosts.ips, ","))
+		}
+	}
+	if p.Image != "" {
+		fmt.Fprintf(w, "apply image: %s\n", p.Image)
+	}
+	for _, c := range p.Changes {
+		var actions []string
+		for _, a := range c.Actions {
+			actions = append(actions, string(a))
+		}
+		fmt.Fprintf(w, "%s changed on %s: %s\n    => %s\n", c.Category, strings.Join(c.Hosts, ","), c.Detail, strings.Join(actions, ", "))
+	}
+}
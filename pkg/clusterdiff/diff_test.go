@@ -0,0 +1,58 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterdiff
+
+import (
+	"reflect"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func newCluster(nodeEnv []string, labels map[string]string, taints []string, nodes ...string) *v2.Cluster {
+	cluster := &v2.Cluster{}
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+		{IPS: nodes, Roles: []string{"node"}, Env: nodeEnv, Labels: labels, Taints: taints},
+	}
+	return cluster
+}
+
+func TestDiff(t *testing.T) {
+	current := newCluster([]string{"a=1"}, map[string]string{"role": "web"}, nil, "192.168.0.3")
+	desired := newCluster([]string{"a=2"}, map[string]string{"role": "ingress"}, []string{"dedicated=ingress:NoSchedule"}, "192.168.0.3", "192.168.0.4")
+	desired.Spec.Kubernetes.Kubelet.ExtraArgs = map[string]string{"max-pods": "200"}
+	desired.Spec.Kubernetes.Etcd.ExtraArgs = map[string]string{"quota-backend-bytes": "8589934592"}
+	desired.Spec.Registries = []v2.RegistrySpec{{Domain: "harbor.example.com", Mirrors: []string{"https://mirror.example.com"}}}
+
+	want := []Change{
+		{CategoryLabels, []string{"192.168.0.3"}, "labels ~role=ingress", []Action{ActionUpdateNodes}},
+		{CategoryTaints, []string{"192.168.0.3"}, "taints +dedicated=ingress:NoSchedule", []Action{ActionUpdateNodes}},
+		// the joined host has no labels before.
+		{CategoryLabels, []string{"192.168.0.4"}, "labels +role=ingress", []Action{ActionUpdateNodes}},
+		{CategoryTaints, []string{"192.168.0.4"}, "taints +dedicated=ingress:NoSchedule", []Action{ActionUpdateNodes}},
+		{CategoryEnv, []string{"192.168.0.3"}, "192.168.0.3: env a changed", []Action{ActionRenderConfigs, ActionRerunPlugins}},
+		{CategoryKubernetes, []string{"192.168.0.2", "192.168.0.3"}, "spec.kubernetes.kubelet.extraArgs +max-pods=200", []Action{ActionRestartKubelet}},
+		{CategoryKubernetes, []string{"192.168.0.2"}, "spec.kubernetes.etcd changed, edit /etc/kubernetes/manifests/etcd.yaml on each master", []Action{ActionManual}},
+		// the joined host gets the registries by joining.
+		{CategoryRegistries, []string{"192.168.0.2", "192.168.0.3"}, "spec.registries changed", []Action{ActionConfigureRegistries}},
+	}
+	if got := Diff(current, desired); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %+v, want %+v", got, want)
+	}
+	if got := Diff(desired, desired.DeepCopy()); len(got) != 0 {
+		t.Errorf("Diff() of the same cluster = %+v, want no changes", got)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/sbom"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+	"github.com/alibaba/sealer/version"
+)
+
+// the value of the layer holding the snapshot of cluster.
+const commitLayerValue = "cluster snapshot"
+
+// Commit snapshots the running cluster into a new CloudImage named name. On top of the layers of
+// the image the cluster runs, it adds a layer of the images pushed to the registry of cluster, the
+// Config files of Clusterfile and the manifests of the helm releases not shipped in the image, and
+// a CMD layer applying those manifests.
+func Commit(cluster *v2.Cluster, clusterfile, name string) error {
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return err
+	}
+	layerStore, err := store.NewDefaultLayerStore()
+	if err != nil {
+		return err
+	}
+	base, err := runtime.GetClusterImage(imageStore, cluster)
+	if err != nil {
+		return fmt.Errorf("failed to get image of cluster %s: %v", cluster.Name, err)
+	}
+
+	layerDir, err := utils.MkTmpdir()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(layerDir); err != nil {
+			logger.Warn("failed to remove %s: %v", layerDir, err)
+		}
+	}()
+
+	logger.Info("start to export the registry data of cluster %s", cluster.Name)
+	if err = runtime.ExportRegistryData(cluster, clusterfile, layerDir); err != nil {
+		return err
+	}
+	if err = writeConfigs(clusterfile, layerDir); err != nil {
+		return err
+	}
+	master0 := runtime.GetMaster0Ip(cluster)
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return err
+	}
+	logger.Info("start to export the helm releases of cluster %s", cluster.Name)
+	namespaces, err := exportReleases(client, master0, shippedCharts(base), layerDir)
+	if err != nil {
+		return err
+	}
+	components, err := sbom.Generate(layerDir)
+	if err != nil {
+		return err
+	}
+
+	image := base.DeepCopy()
+	layerID, err := layerStore.RegisterLayerForBuilder(layerDir)
+	if err != nil {
+		return fmt.Errorf("failed to register layer, err: %v", err)
+	}
+	if layerID == "" {
+		logger.Warn("no change found in cluster %s", cluster.Name)
+	} else {
+		image.Spec.Layers = append(image.Spec.Layers, v1.Layer{
+			ID:    layerID,
+			Type:  common.BaseImageLayerType,
+			Value: commitLayerValue,
+		})
+	}
+	if len(namespaces) > 0 {
+		image.Spec.Layers = append(image.Spec.Layers, v1.Layer{
+			Type:  common.CMDCOMMAND,
+			Value: applyReleasesCmd(namespaces),
+		})
+	}
+
+	image.Name = name
+	image.Spec.SealerVersion = version.Get().GitVersion
+	// the image is built locally, it is not the one verified by the trusted keys any more.
+	delete(image.Annotations, common.ImageAnnotationForVerified)
+	if err = setClusterfile(image, base, cluster, name); err != nil {
+		return fmt.Errorf("failed to set Clusterfile of image: %v", err)
+	}
+	if err = updateSBOM(image, base, name, components); err != nil {
+		return fmt.Errorf("failed to update SBOM of image: %v", err)
+	}
+
+	if err = saveImage(imageStore, image, name); err != nil {
+		return err
+	}
+	logger.Info("commit cluster %s to image %s success !", cluster.Name, name)
+	return nil
+}
+
+// saveImage saves image as name, its ID is the digest of its content.
+func saveImage(imageStore store.ImageStore, image *v1.Image, name string) error {
+	image.Spec.ID = ""
+	data, err := yaml.Marshal(image)
+	if err != nil {
+		return err
+	}
+	image.Spec.ID = digest.FromBytes(data).Hex()
+	return imageStore.Save(*image, name)
+}
+
+// writeConfigs writes the data of Config in clusterfile to its path under dir,
+// the same as they are dumped to the rootfs of cluster.
+func writeConfigs(clusterfile, dir string) error {
+	configs, err := utils.DecodeConfigs(clusterfile)
+	if err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if err = utils.WriteFile(filepath.Join(dir, config.Spec.Path), []byte(config.Spec.Data)); err != nil {
+			return fmt.Errorf("failed to write config %s: %v", config.Name, err)
+		}
+	}
+	return nil
+}
+
+// setClusterfile saves the Clusterfile of base to image with the image name and the settings of
+// the running cluster, the hosts and ssh of cluster are not kept as they belong to the environment.
+func setClusterfile(image, base *v1.Image, cluster *v2.Cluster, name string) error {
+	c := cluster.DeepCopy()
+	if raw, ok := base.Annotations[common.ImageAnnotationForClusterfile]; ok {
+		var baseCluster v2.Cluster
+		if err := yaml.Unmarshal([]byte(raw), &baseCluster); err != nil {
+			return err
+		}
+		c.ObjectMeta = baseCluster.ObjectMeta
+		c.Spec.Hosts = baseCluster.Spec.Hosts
+		c.Spec.SSH = baseCluster.Spec.SSH
+	} else {
+		c.Spec.Hosts = nil
+		c.Spec.SSH = v1.SSH{}
+		c.Annotations = nil
+	}
+	c.Status = v2.ClusterStatus{}
+	c.Spec.Image = name
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if image.Annotations == nil {
+		image.Annotations = make(map[string]string)
+	}
+	image.Annotations[common.ImageAnnotationForClusterfile] = string(data)
+	return nil
+}
+
+// updateSBOM adds the components of the committed layer to the SBOM of base.
+func updateSBOM(image, base *v1.Image, name string, components []sbom.Component) error {
+	s, err := sbom.LoadFromImage(base)
+	if err != nil {
+		logger.Warn("skip updating SBOM: %v", err)
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, c := range s.Components {
+		seen[componentKey(c)] = true
+	}
+	for _, c := range components {
+		if !seen[componentKey(c)] {
+			s.Components = append(s.Components, c)
+		}
+	}
+	s.Image = name
+	s.Provenance = sbom.Provenance{
+		BuilderVersion: version.Get().GitVersion,
+		BaseImage:      base.Name,
+		Platform:       s.Provenance.Platform,
+		BuildTime:      time.Now(),
+	}
+	return sbom.SetToImage(s, image)
+}
+
+func componentKey(c sbom.Component) string {
+	return fmt.Sprintf("%s/%s:%s", c.Type, c.Name, c.Version)
+}
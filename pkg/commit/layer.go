@@ -0,0 +1,88 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commit
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/sbom"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/version"
+)
+
+// the value of the layer holding the changes made to the rootfs of image.
+const rootfsLayerValue = "rootfs changes"
+
+// CommitLayer adds the files of dir, like the upper dir of the rootfs of base mounted by overlay, as a new
+// layer on top of base and saves it as a new CloudImage named name. dir is moved into the layer store.
+func CommitLayer(base *v1.Image, dir, name string) error {
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return err
+	}
+	layerStore, err := store.NewDefaultLayerStore()
+	if err != nil {
+		return err
+	}
+	components, err := sbom.Generate(dir)
+	if err != nil {
+		return err
+	}
+	layerID, err := layerStore.RegisterLayerForBuilder(dir)
+	if err != nil {
+		return fmt.Errorf("failed to register layer, err: %v", err)
+	}
+	if layerID == "" {
+		logger.Warn("no change found in the rootfs of %s, image %s is not committed", base.Name, name)
+		return nil
+	}
+
+	image := base.DeepCopy()
+	image.Spec.Layers = append(image.Spec.Layers, v1.Layer{
+		ID:    layerID,
+		Type:  common.BaseImageLayerType,
+		Value: rootfsLayerValue,
+	})
+	image.Name = name
+	image.Spec.SealerVersion = version.Get().GitVersion
+	// the image is built locally, it is not the one verified by the trusted keys any more.
+	delete(image.Annotations, common.ImageAnnotationForVerified)
+	if raw, ok := base.Annotations[common.ImageAnnotationForClusterfile]; ok {
+		var cluster v2.Cluster
+		if err = yaml.Unmarshal([]byte(raw), &cluster); err != nil {
+			return fmt.Errorf("failed to set Clusterfile of image: %v", err)
+		}
+		cluster.Spec.Image = name
+		data, err := yaml.Marshal(&cluster)
+		if err != nil {
+			return err
+		}
+		image.Annotations[common.ImageAnnotationForClusterfile] = string(data)
+	}
+	if err = updateSBOM(image, base, name, components); err != nil {
+		return fmt.Errorf("failed to update SBOM of image: %v", err)
+	}
+	if err = saveImage(imageStore, image, name); err != nil {
+		return err
+	}
+	logger.Info("commit the rootfs changes of %s to image %s success !", base.Name, name)
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commit
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/sbom"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// ReleaseManifestsDir is where the manifests of helm releases are saved in rootfs, one dir per namespace.
+	ReleaseManifestsDir = "manifests/commit"
+
+	RemoteCheckHelm          = "command -v helm >/dev/null 2>&1"
+	RemoteListReleases       = "helm list -A -o json 2>/dev/null"
+	RemoteGetReleaseManifest = "helm get manifest %s -n %s 2>/dev/null"
+	ApplyReleaseManifestsCmd = "(kubectl get namespace %[1]s >/dev/null 2>&1 || kubectl create namespace %[1]s) && kubectl apply -n %[1]s -f %[2]s"
+	releaseStatusDeployed    = "deployed"
+)
+
+type release struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Chart     string `json:"chart"`
+	Status    string `json:"status"`
+}
+
+// shippedCharts returns the charts in the SBOM of image as chart-version, the same as
+// the chart of helm releases.
+func shippedCharts(image *v1.Image) map[string]bool {
+	charts := map[string]bool{}
+	s, err := sbom.LoadFromImage(image)
+	if err != nil {
+		logger.Warn("failed to get charts of image, all the helm releases will be committed: %v", err)
+		return charts
+	}
+	for _, c := range s.Components {
+		if c.Type == sbom.Chart {
+			charts[fmt.Sprintf("%s-%s", c.Name, c.Version)] = true
+		}
+	}
+	return charts
+}
+
+// exportReleases saves the rendered manifests of the deployed helm releases on master0, except the
+// ones of the charts shipped in image, to ReleaseManifestsDir under dir. It returns the namespaces
+// of the saved releases.
+func exportReleases(client ssh.Interface, master0 string, shipped map[string]bool, dir string) ([]string, error) {
+	if _, err := client.Cmd(master0, RemoteCheckHelm); err != nil {
+		logger.Info("helm is not found on %s, skip exporting helm releases", master0)
+		return nil, nil
+	}
+	out, err := client.Cmd(master0, RemoteListReleases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list helm releases: %v", err)
+	}
+	var releases []release
+	if err = json.Unmarshal(out, &releases); err != nil {
+		return nil, fmt.Errorf("failed to decode helm releases: %v", err)
+	}
+
+	namespaces := map[string]bool{}
+	for _, r := range releases {
+		if r.Status != releaseStatusDeployed || shipped[r.Chart] {
+			continue
+		}
+		manifest, err := client.Cmd(master0, fmt.Sprintf(RemoteGetReleaseManifest, r.Name, r.Namespace))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get manifest of helm release %s/%s: %v", r.Namespace, r.Name, err)
+		}
+		if strings.TrimSpace(string(manifest)) == "" {
+			continue
+		}
+		path := filepath.Join(dir, ReleaseManifestsDir, r.Namespace, r.Name+".yaml")
+		if err = utils.WriteFile(path, manifest); err != nil {
+			return nil, err
+		}
+		logger.Info("helm release %s/%s of chart %s is committed", r.Namespace, r.Name, r.Chart)
+		namespaces[r.Namespace] = true
+	}
+
+	var res []string
+	for ns := range namespaces {
+		res = append(res, ns)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// applyReleasesCmd is the CMD applying the committed manifests of namespaces in the rootfs of cluster.
+func applyReleasesCmd(namespaces []string) string {
+	var cmds []string
+	for _, ns := range namespaces {
+		cmds = append(cmds, fmt.Sprintf(ApplyReleaseManifestsCmd, ns, filepath.Join(ReleaseManifestsDir, ns)))
+	}
+	return strings.Join(cmds, " && ")
+}
@@ -0,0 +1,196 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+/*
+the component manifest in rootfs:
+
+components:
+- name: kubeadm
+  version: v1.19.8
+  path: bin/kubeadm
+  url: https://dl.k8s.io/release/v1.19.8/bin/linux/${ARCH}/kubeadm
+  digests:
+    amd64: 0a3e8e2f3d1b...
+    arm64: 5b6a7c1e4f9d...
+- name: helm
+  version: v3.6.0
+  path: bin/helm
+  sha256: 0a21e1a9e4c3...
+*/
+
+// ManifestFile is the component manifest under rootfs.
+var ManifestFile = filepath.Join(common.EtcDir, "components.yaml")
+
+const archPlaceholder = "${ARCH}"
+
+// httpClient downloads the components, which are up to a hundred MB like kubelet.
+var httpClient = &http.Client{Timeout: 10 * time.Minute}
+
+type Component struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Path is relative to rootfs.
+	Path string `json:"path"`
+	// URL is where the component is downloaded at build if it is not in rootfs, ${ARCH} is replaced by the arch of image.
+	URL string `json:"url,omitempty"`
+	// SHA256 is the digest of the component, Digests by arch overrides it for a multi-platform image.
+	SHA256  string            `json:"sha256,omitempty"`
+	Digests map[string]string `json:"digests,omitempty"`
+}
+
+type Manifest struct {
+	Components []Component `json:"components"`
+}
+
+// Digest returns the sha256 digest of the component for arch.
+func (c Component) Digest(arch string) (string, error) {
+	if d, ok := c.Digests[arch]; ok {
+		return strings.ToLower(d), nil
+	}
+	if c.SHA256 == "" {
+		return "", fmt.Errorf("no sha256 digest of component %s for %s", c.Name, arch)
+	}
+	return strings.ToLower(c.SHA256), nil
+}
+
+// LoadManifest returns the component manifest in rootfs, or nil if there is none.
+func LoadManifest(rootfs string) (*Manifest, error) {
+	path := filepath.Join(rootfs, ManifestFile)
+	if !utils.IsFileExist(path) {
+		return nil, nil
+	}
+	m := &Manifest{}
+	if err := utils.UnmarshalYamlFile(path, m); err != nil {
+		return nil, fmt.Errorf("failed to load component manifest: %v", err)
+	}
+	for _, c := range m.Components {
+		if c.Name == "" || c.Path == "" {
+			return nil, fmt.Errorf("name and path of component are required in %s", ManifestFile)
+		}
+		if err := c.validatePath(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// validatePath refuses the path out of rootfs, which would overwrite or verify a file of the host.
+func (c Component) validatePath() error {
+	if filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path %s of component %s must be relative to rootfs", c.Path, c.Name)
+	}
+	if p := filepath.Clean(c.Path); p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path %s of component %s is out of rootfs", c.Path, c.Name)
+	}
+	return nil
+}
+
+// Verify checks the sha256 digests of the components in rootfs. The missing components with an URL
+// are downloaded to dest if it is not empty, which is the rootfs being built.
+func (m *Manifest) Verify(rootfs, dest, arch string) error {
+	for _, c := range m.Components {
+		if err := c.validatePath(); err != nil {
+			return err
+		}
+		want, err := c.Digest(arch)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(rootfs, c.Path)
+		if !utils.IsFileExist(path) {
+			if dest == "" || c.URL == "" {
+				return fmt.Errorf("component %s is not found at %s", c.Name, c.Path)
+			}
+			if err = download(strings.ReplaceAll(c.URL, archPlaceholder, arch), filepath.Join(dest, c.Path), want); err != nil {
+				return fmt.Errorf("failed to download component %s: %v", c.Name, err)
+			}
+			logger.Info("component %s %s is downloaded and verified", c.Name, c.Version)
+			continue
+		}
+		got, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("sha256 of component %s at %s is %s, but %s is pinned", c.Name, c.Path, got, want)
+		}
+		logger.Debug("component %s %s is verified", c.Name, c.Version)
+	}
+	return nil
+}
+
+// download saves url to path if its sha256 digest is want, it is executable as most components are binaries.
+func download(url, path, want string) error {
+	resp, err := httpClient.Get(url) // #nosec
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s of %s", resp.Status, url)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), common.FileMode0755); err != nil {
+		return err
+	}
+	tmp := path + ".download"
+	f, err := os.OpenFile(filepath.Clean(tmp), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, common.FileMode0755)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("sha256 of %s is %s, but %s is pinned", url, got, want)
+	}
+	return os.Rename(tmp, path)
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
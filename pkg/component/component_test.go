@@ -0,0 +1,168 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func sha256Hex(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+func TestComponent_Digest(t *testing.T) {
+	tests := []struct {
+		name      string
+		component Component
+		arch      string
+		want      string
+		wantErr   bool
+	}{
+		{"sha256", Component{Name: "helm", SHA256: "ABC"}, "amd64", "abc", false},
+		{"digest of arch", Component{Name: "kubeadm", SHA256: "abc", Digests: map[string]string{"arm64": "DEF"}}, "arm64", "def", false},
+		{"fall back to sha256", Component{Name: "kubeadm", SHA256: "abc", Digests: map[string]string{"arm64": "def"}}, "amd64", "abc", false},
+		{"no digest of arch", Component{Name: "kubeadm", Digests: map[string]string{"arm64": "def"}}, "amd64", "", true},
+		{"no digest", Component{Name: "kubeadm"}, "amd64", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.component.Digest(tt.arch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Digest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Digest() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     *Manifest
+		wantErr  bool
+	}{
+		{"no manifest", "", nil, false},
+		{
+			"components",
+			"components:\n- name: kubeadm\n  version: v1.19.8\n  path: bin/kubeadm\n  digests:\n    amd64: abc\n- name: helm\n  path: bin/helm\n  sha256: def\n",
+			&Manifest{Components: []Component{
+				{Name: "kubeadm", Version: "v1.19.8", Path: "bin/kubeadm", Digests: map[string]string{"amd64": "abc"}},
+				{Name: "helm", Path: "bin/helm", SHA256: "def"},
+			}},
+			false,
+		},
+		{"no name", "components:\n- path: bin/helm\n  sha256: def\n", nil, true},
+		{"no path", "components:\n- name: helm\n  sha256: def\n", nil, true},
+		{"absolute path", "components:\n- name: helm\n  path: /usr/bin/helm\n  sha256: def\n", nil, true},
+		{"path out of rootfs", "components:\n- name: helm\n  path: bin/../../usr/bin/helm\n  sha256: def\n", nil, true},
+		{"invalid yaml", "components: [", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootfs := t.TempDir()
+			if tt.manifest != "" {
+				path := filepath.Join(rootfs, ManifestFile)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(tt.manifest), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := LoadManifest(rootfs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadManifest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifest_Verify(t *testing.T) {
+	const content = "#!/bin/sh\n"
+	digest := sha256Hex(content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/amd64/helm" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		component Component
+		inRootfs  bool
+		download  bool
+		wantErr   bool
+	}{
+		{"verified", Component{Name: "helm", Path: "bin/helm", SHA256: digest}, true, false, false},
+		{"tampered", Component{Name: "helm", Path: "bin/helm", SHA256: sha256Hex("helm")}, true, false, true},
+		{"not found", Component{Name: "helm", Path: "bin/helm", SHA256: digest}, false, false, true},
+		{"downloaded", Component{Name: "helm", Path: "bin/helm", URL: server.URL + "/${ARCH}/helm", SHA256: digest}, false, true, false},
+		{"downloaded tampered", Component{Name: "helm", Path: "bin/helm", URL: server.URL + "/${ARCH}/helm", SHA256: sha256Hex("helm")}, false, false, true},
+		{"download not found", Component{Name: "helm", Path: "bin/helm", URL: server.URL + "/helm", SHA256: digest}, false, false, true},
+		{"absolute path", Component{Name: "helm", Path: "/bin/helm", SHA256: digest}, true, false, true},
+		{"path out of rootfs", Component{Name: "helm", Path: "../bin/helm", SHA256: digest}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootfs, dest := t.TempDir(), t.TempDir()
+			if tt.inRootfs {
+				path := filepath.Join(rootfs, tt.component.Path)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m := &Manifest{Components: []Component{tt.component}}
+			if err := m.Verify(rootfs, dest, "amd64"); (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dest, tt.component.Path))
+			if tt.download && (err != nil || string(data) != content) {
+				t.Errorf("expected the component downloaded to dest, got %q, %v", data, err)
+			}
+			if !tt.download && !os.IsNotExist(err) {
+				t.Errorf("expected no component in dest, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSHA256Sum(t *testing.T) {
+	out := "abc  bin/kubeadm\ndef *bin/helm\nsha256sum: bin/kubectl: No such file or directory\n"
+	want := map[string]string{"bin/kubeadm": "abc", "bin/helm": "def"}
+	if got := parseSHA256Sum(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSHA256Sum() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// RemoteSHA256Sum prints the digests of the paths relative to the rootfs of cluster.
+const RemoteSHA256Sum = "cd %s && sha256sum -- %s"
+
+// VerifyHosts checks the components in the rootfs of cluster on hosts against the manifest of the mounted CloudImage,
+// so a component changed when copying the rootfs to a host is found before it is run.
+func VerifyHosts(cluster *v2.Cluster, hosts []string) error {
+	mountDir := common.DefaultMountCloudImageDir(cluster.Name)
+	m, err := LoadManifest(mountDir)
+	if err != nil || m == nil || len(m.Components) == 0 {
+		return err
+	}
+	arch := runtime.GetCloudImagePlatform(mountDir).Architecture
+	digests := map[string]string{}
+	var paths []string
+	for _, c := range m.Components {
+		d, err := c.Digest(arch)
+		if err != nil {
+			return err
+		}
+		digests[c.Path] = d
+		paths = append(paths, utils.ShellQuote(c.Path))
+	}
+
+	rootfs := common.DefaultTheClusterRootfsDir(cluster.Name)
+	for _, ip := range hosts {
+		client, err := ssh.GetHostSSHClient(ip, cluster)
+		if err != nil {
+			return result.OnHost(ip, err)
+		}
+		out, err := client.Cmd(ip, fmt.Sprintf(RemoteSHA256Sum, utils.ShellQuote(rootfs), strings.Join(paths, " ")))
+		if err != nil {
+			return result.OnHost(ip, fmt.Errorf("failed to get sha256 of components on %s: %v, %s", ip, err, out))
+		}
+		got := parseSHA256Sum(string(out))
+		for _, c := range m.Components {
+			if got[c.Path] != digests[c.Path] {
+				return result.OnHost(ip, fmt.Errorf("sha256 of component %s on %s is %s, but %s is pinned", c.Name, ip, got[c.Path], digests[c.Path]))
+			}
+		}
+		logger.Debug("components on %s are verified", ip)
+	}
+	return nil
+}
+
+// parseSHA256Sum returns the digests by path of the output of sha256sum.
+func parseSHA256Sum(out string) map[string]string {
+	res := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		res[strings.TrimPrefix(fields[1], "*")] = fields[0]
+	}
+	return res
+}
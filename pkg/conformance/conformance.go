@@ -0,0 +1,225 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	ModeQuick = "quick"
+	ModeFull  = "full"
+
+	// sonobuoyBin is the sonobuoy bundled in bin of CloudImage, the one in PATH of master0 is used if it is not.
+	sonobuoyBin = "bin/sonobuoy"
+	// resultsDir is where sonobuoy retrieve writes the results tarball on master0.
+	resultsDir = "/tmp/sealer-conformance"
+)
+
+// sonobuoyModes are the sonobuoy modes of the conformance modes: quick runs a single test to check the cluster works,
+// full runs all the conformance tests of the kubernetes certification, which takes one or two hours.
+var sonobuoyModes = map[string]string{
+	ModeQuick: "quick",
+	ModeFull:  "certified-conformance",
+}
+
+// DefaultWaits are how long the conformance tests of each mode are waited for by default.
+var DefaultWaits = map[string]time.Duration{
+	ModeQuick: 30 * time.Minute,
+	ModeFull:  3 * time.Hour,
+}
+
+type Options struct {
+	ClusterName string
+	// Mode is ModeQuick or ModeFull.
+	Mode string
+	// Wait is how long the tests are waited for, the default of Mode if zero.
+	Wait time.Duration
+	// Output is the local path the results tarball of sonobuoy is fetched to, it is not fetched if empty.
+	Output string
+}
+
+// PluginResult is the summary of a sonobuoy plugin, like e2e running the conformance tests.
+type PluginResult struct {
+	Plugin      string   `json:"plugin"`
+	Status      string   `json:"status"`
+	Total       int      `json:"total"`
+	Passed      int      `json:"passed"`
+	Failed      int      `json:"failed"`
+	Skipped     int      `json:"skipped"`
+	FailedTests []string `json:"failedTests,omitempty"`
+}
+
+type Report struct {
+	Cluster string         `json:"cluster"`
+	Mode    string         `json:"mode"`
+	Plugins []PluginResult `json:"plugins"`
+}
+
+// Passed returns whether all plugins of r passed.
+func (r *Report) Passed() bool {
+	if len(r.Plugins) == 0 {
+		return false
+	}
+	for _, p := range r.Plugins {
+		if p.Status != "passed" {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs the conformance tests of opts.Mode by sonobuoy on master0 of the cluster and summarizes the results.
+// The sonobuoy resources are deleted from the cluster after the tests.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	mode, ok := sonobuoyModes[opts.Mode]
+	if !ok {
+		return nil, fmt.Errorf("unsupported conformance mode %s, it should be %s or %s", opts.Mode, ModeQuick, ModeFull)
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultWaits[opts.Mode]
+	}
+	if opts.ClusterName == "" {
+		var err error
+		if opts.ClusterName, err = utils.GetDefaultClusterName(); err != nil {
+			return nil, err
+		}
+	}
+	cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(opts.ClusterName))
+	if err != nil {
+		return nil, err
+	}
+	master0 := cluster.GetMaster0Ip()
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	rootfsBin := filepath.Join(common.DefaultTheClusterRootfsDir(cluster.Name), sonobuoyBin)
+	out, err := client.Cmd(master0, fmt.Sprintf("if [ -x %[1]s ]; then echo %[1]s; else command -v sonobuoy; fi", rootfsBin))
+	sonobuoy := strings.TrimSpace(string(out))
+	if err != nil || sonobuoy == "" {
+		return nil, fmt.Errorf("sonobuoy is not found on master0 %s, add it to %s of the image and cache its images in the registry of the image", master0, sonobuoyBin)
+	}
+	sonobuoy = fmt.Sprintf("%s --kubeconfig %s", sonobuoy, common.KubeAdminConf)
+
+	// the resources left by the last run fail the new one.
+	if out, err = client.Cmd(master0, sonobuoy+" delete --wait"); err != nil {
+		return nil, fmt.Errorf("failed to delete the last run of sonobuoy: %v, %s", err, out)
+	}
+	defer func() {
+		if out, err := client.Cmd(master0, sonobuoy+" delete --wait"); err != nil {
+			logger.Warn("failed to delete sonobuoy from cluster %s: %v, %s", cluster.Name, err, out)
+		}
+	}()
+
+	logger.Info("running the %s conformance tests on cluster %s, which are waited for %s", opts.Mode, cluster.Name, opts.Wait)
+	// the tests are left running when ctx is done, and deleted by the deferred sonobuoy delete.
+	run := fmt.Sprintf("%s run --mode %s --wait=%d", sonobuoy, mode, int(opts.Wait.Minutes()))
+	if out, err = ssh.WithContext(ctx, client).Cmd(master0, run); err != nil {
+		return nil, fmt.Errorf("failed to run sonobuoy: %v, %s", err, out)
+	}
+
+	out, err = client.Cmd(master0, fmt.Sprintf("rm -rf %[1]s && mkdir -p %[1]s && %s retrieve %[1]s", resultsDir, sonobuoy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the results of sonobuoy: %v, %s", err, out)
+	}
+	tarball := lastLine(string(out))
+	if out, err = client.Cmd(master0, fmt.Sprintf("%s results %s", sonobuoy, tarball)); err != nil {
+		return nil, fmt.Errorf("failed to read the results of sonobuoy: %v, %s", err, out)
+	}
+	report := &Report{Cluster: cluster.Name, Mode: opts.Mode, Plugins: ParseResults(string(out))}
+
+	if opts.Output != "" {
+		if err = client.Fetch(master0, opts.Output, tarball); err != nil {
+			return nil, fmt.Errorf("failed to fetch the results of sonobuoy: %v", err)
+		}
+		logger.Info("the results of sonobuoy are saved to %s", opts.Output)
+	}
+	return report, nil
+}
+
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// ParseResults parses the output of sonobuoy results, which summarizes each plugin like:
+//
+//	Plugin: e2e
+//	Status: failed
+//	Total: 5771
+//	Passed: 303
+//	Failed: 2
+//	Skipped: 5466
+//
+//	Failed tests:
+//	[sig-network] DNS should provide DNS for services  [Conformance]
+func ParseResults(out string) []PluginResult {
+	var (
+		results     []PluginResult
+		cur         *PluginResult
+		failedTests bool
+	)
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			failedTests = false
+			continue
+		}
+		if failedTests {
+			cur.FailedTests = append(cur.FailedTests, line)
+			continue
+		}
+		key, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			key, value = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		if key == "Plugin" {
+			results = append(results, PluginResult{Plugin: value})
+			cur = &results[len(results)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(value)
+		switch key {
+		case "Status":
+			cur.Status = value
+		case "Total":
+			cur.Total = n
+		case "Passed":
+			cur.Passed = n
+		case "Failed":
+			cur.Failed = n
+		case "Skipped":
+			cur.Skipped = n
+		case "Failed tests":
+			failedTests = true
+		}
+	}
+	return results
+}
@@ -0,0 +1,76 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"reflect"
+	"testing"
+)
+
+const results = `Plugin: e2e
+Status: failed
+Total: 5771
+Passed: 303
+Failed: 2
+Skipped: 5466
+
+Failed tests:
+[sig-network] DNS should provide DNS for services  [Conformance]
+[sig-apps] Deployment should run the lifecycle of a Deployment [Conformance]
+
+Plugin: systemd-logs
+Status: passed
+Total: 3
+Passed: 3
+Failed: 0
+Skipped: 0
+`
+
+func TestParseResults(t *testing.T) {
+	want := []PluginResult{
+		{
+			Plugin: "e2e", Status: "failed", Total: 5771, Passed: 303, Failed: 2, Skipped: 5466,
+			FailedTests: []string{
+				"[sig-network] DNS should provide DNS for services  [Conformance]",
+				"[sig-apps] Deployment should run the lifecycle of a Deployment [Conformance]",
+			},
+		},
+		{Plugin: "systemd-logs", Status: "passed", Total: 3, Passed: 3},
+	}
+	got := ParseResults(results)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseResults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReportPassed(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins []PluginResult
+		want    bool
+	}{
+		{"no plugins", nil, false},
+		{"all passed", []PluginResult{{Plugin: "e2e", Status: "passed"}, {Plugin: "systemd-logs", Status: "passed"}}, true},
+		{"one failed", []PluginResult{{Plugin: "e2e", Status: "failed"}, {Plugin: "systemd-logs", Status: "passed"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Report{Plugins: tt.plugins}
+			if got := r.Passed(); got != tt.want {
+				t.Errorf("Passed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deprecation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+// DateLayout is the layout of the end-of-life date of image.
+const DateLayout = "2006-01-02"
+
+// strict is set by --strict of the commands which pull or apply images.
+var strict bool
+
+func SetStrict(s bool) {
+	strict = s
+}
+
+// Status is the deprecation of an image saved in its annotations.
+type Status struct {
+	Deprecated bool
+	// Message is why the image is deprecated.
+	Message string
+	// EndOfLife is the date after which the image is not supported, zero if it is not set.
+	EndOfLife time.Time
+	// ReplacedBy is the image to use instead.
+	ReplacedBy string
+}
+
+// IsZero returns true if the image is neither deprecated nor has an end-of-life date.
+func (s Status) IsZero() bool {
+	return !s.Deprecated && s.EndOfLife.IsZero()
+}
+
+// Expired returns true if the end-of-life date is before now.
+func (s Status) Expired(now time.Time) bool {
+	return !s.EndOfLife.IsZero() && now.After(s.EndOfLife.AddDate(0, 0, 1))
+}
+
+// Warning describes the status of image name for now.
+func (s Status) Warning(name string, now time.Time) string {
+	var parts []string
+	if s.Deprecated {
+		msg := fmt.Sprintf("image %s is deprecated", name)
+		if s.Message != "" {
+			msg += ": " + s.Message
+		}
+		parts = append(parts, msg)
+	}
+	if !s.EndOfLife.IsZero() {
+		eol := s.EndOfLife.Format(DateLayout)
+		if s.Expired(now) {
+			parts = append(parts, fmt.Sprintf("image %s reached its end of life on %s", name, eol))
+		} else {
+			parts = append(parts, fmt.Sprintf("image %s reaches its end of life on %s", name, eol))
+		}
+	}
+	if s.ReplacedBy != "" {
+		parts = append(parts, fmt.Sprintf("use %s instead", s.ReplacedBy))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FromImage returns the deprecation status in the annotations of image.
+func FromImage(image *v1.Image) (Status, error) {
+	var s Status
+	msg, ok := image.Annotations[common.ImageAnnotationForDeprecated]
+	if ok {
+		s.Deprecated = true
+		s.Message = msg
+	}
+	if eol := image.Annotations[common.ImageAnnotationForEndOfLife]; eol != "" {
+		t, err := time.Parse(DateLayout, eol)
+		if err != nil {
+			return s, fmt.Errorf("invalid end-of-life date %s of image %s: %v", eol, image.Name, err)
+		}
+		s.EndOfLife = t
+	}
+	s.ReplacedBy = image.Annotations[common.ImageAnnotationForReplacedBy]
+	return s, nil
+}
+
+// SetToImage saves s to the annotations of image, a zero status removes them.
+func SetToImage(s Status, image *v1.Image) {
+	if image.Annotations == nil {
+		image.Annotations = make(map[string]string)
+	}
+	for _, key := range []string{common.ImageAnnotationForDeprecated, common.ImageAnnotationForEndOfLife, common.ImageAnnotationForReplacedBy} {
+		delete(image.Annotations, key)
+	}
+	if s.Deprecated {
+		image.Annotations[common.ImageAnnotationForDeprecated] = s.Message
+	}
+	if !s.EndOfLife.IsZero() {
+		image.Annotations[common.ImageAnnotationForEndOfLife] = s.EndOfLife.Format(DateLayout)
+	}
+	if s.ReplacedBy != "" {
+		image.Annotations[common.ImageAnnotationForReplacedBy] = s.ReplacedBy
+	}
+}
+
+// Check warns if image is deprecated or has an end-of-life date, it returns an error instead with --strict
+// if the image is deprecated or reached its end of life.
+func Check(image *v1.Image) error {
+	s, err := FromImage(image)
+	if err != nil {
+		return err
+	}
+	if s.IsZero() {
+		return nil
+	}
+	now := time.Now()
+	warning := s.Warning(image.Name, now)
+	if strict && (s.Deprecated || s.Expired(now)) {
+		return fmt.Errorf("%s, it is blocked by --strict", warning)
+	}
+	logger.Warn(warning)
+	return nil
+}
+
+// CheckImage checks the image named name in the local image store.
+func CheckImage(name string) error {
+	imageStore, err := store.NewDefaultImageStore()
+	if err != nil {
+		return err
+	}
+	image, err := imageStore.GetByName(name)
+	if err != nil {
+		return err
+	}
+	return Check(image)
+}
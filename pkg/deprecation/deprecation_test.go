@@ -0,0 +1,60 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deprecation
+
+import (
+	"testing"
+	"time"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestStatus(t *testing.T) {
+	eol, _ := time.Parse(DateLayout, "2022-06-30")
+	image := &v1.Image{}
+	image.Name = "kubernetes:v1.19.8"
+	SetToImage(Status{EndOfLife: eol, ReplacedBy: "kubernetes:v1.19.16"}, image)
+
+	s, err := FromImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Deprecated || !s.EndOfLife.Equal(eol) || s.ReplacedBy != "kubernetes:v1.19.16" {
+		t.Fatalf("unexpected status %+v", s)
+	}
+	if s.Expired(eol.Add(12 * time.Hour)) {
+		t.Errorf("image should not be expired on its end-of-life date")
+	}
+	if !s.Expired(eol.AddDate(0, 0, 2)) {
+		t.Errorf("image should be expired after its end-of-life date")
+	}
+	want := "image kubernetes:v1.19.8 reached its end of life on 2022-06-30, use kubernetes:v1.19.16 instead"
+	if got := s.Warning(image.Name, eol.AddDate(0, 1, 0)); got != want {
+		t.Errorf("Warning() = %q, want %q", got, want)
+	}
+
+	SetStrict(true)
+	defer SetStrict(false)
+	if err = Check(image); err == nil {
+		t.Errorf("expected expired image blocked by strict")
+	}
+	SetToImage(Status{}, image)
+	if len(image.Annotations) != 0 {
+		t.Errorf("expected annotations removed, got %v", image.Annotations)
+	}
+	if err = Check(image); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+}
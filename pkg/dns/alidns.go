@@ -0,0 +1,99 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+// AliyunCLI is the command line of Alibaba Cloud, its credentials and region are the ones of its profile or
+// the ALIBABA_CLOUD_* env.
+const AliyunCLI = "aliyun"
+
+// aliDNSProvider manages the records in Alibaba Cloud DNS, the records are in the PrivateZone of ZoneID if it is
+// set, like cluster.local bound to the VPC of the hosts, or else in the public domain Zone.
+type aliDNSProvider struct {
+	zone   string
+	zoneID string
+	ttl    int
+}
+
+type pvtzRecords struct {
+	Records struct {
+		Record []struct {
+			RecordID int64  `json:"RecordId"`
+			Rr       string `json:"Rr"`
+			Type     string `json:"Type"`
+		} `json:"Record"`
+	} `json:"Records"`
+}
+
+func newAliDNSProvider(spec *v2.DNSProviderSpec) (Provider, error) {
+	if spec.Zone == "" {
+		return nil, fmt.Errorf("zone is required by the alidns dns provider")
+	}
+	return &aliDNSProvider{zone: spec.Zone, zoneID: spec.ZoneID, ttl: spec.TTL}, nil
+}
+
+func (a *aliDNSProvider) Register(domain string, ips []string) error {
+	if err := a.Deregister(domain); err != nil {
+		return err
+	}
+	rr := relativeName(domain, a.zone)
+	for _, ip := range ips {
+		var err error
+		if a.zoneID != "" {
+			_, err = runCommand(AliyunCLI, "pvtz", "AddZoneRecord", "--ZoneId", a.zoneID, "--Rr", rr,
+				"--Type", "A", "--Value", ip, "--Ttl", strconv.Itoa(a.ttl))
+		} else {
+			_, err = runCommand(AliyunCLI, "alidns", "AddDomainRecord", "--DomainName", a.zone, "--RR", rr,
+				"--Type", "A", "--Value", ip, "--TTL", strconv.Itoa(a.ttl))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *aliDNSProvider) Deregister(domain string) error {
+	rr := relativeName(domain, a.zone)
+	if a.zoneID == "" {
+		_, err := runCommand(AliyunCLI, "alidns", "DeleteSubDomainRecords", "--DomainName", a.zone, "--RR", rr, "--Type", "A")
+		return err
+	}
+	out, err := runCommand(AliyunCLI, "pvtz", "DescribeZoneRecords", "--ZoneId", a.zoneID, "--Keyword", rr,
+		"--SearchMode", "EXACT", "--PageSize", "100")
+	if err != nil {
+		return err
+	}
+	var records pvtzRecords
+	if err := json.Unmarshal(out, &records); err != nil {
+		return fmt.Errorf("failed to decode the records of %s: %v", domain, err)
+	}
+	for _, r := range records.Records.Record {
+		if r.Rr != rr || r.Type != "A" {
+			continue
+		}
+		if _, err := runCommand(AliyunCLI, "pvtz", "DeleteZoneRecord", "--RecordId", strconv.FormatInt(r.RecordID, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dns registers the apiserver and registry domains of a cluster in a DNS, so that the hosts resolve them
+// without the entries of /etc/hosts.
+package dns
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+/*
+The provider is set in the DNS of Clusterfile:
+
+spec:
+  kubernetes:
+    dns:
+      provider:
+        name: route53
+        zone: cluster.local
+        zoneID: Z0123456789ABCDEFGHIJ
+
+Other providers can be compiled in-tree or loaded from an out-of-tree plugin (.so) calling
+RegisterProvider in its init function.
+*/
+
+const (
+	// ProviderHosts writes the domains to /etc/hosts of the hosts, it is the default.
+	ProviderHosts = "hosts"
+	// ProviderCoreDNS adds the domains to the hosts plugin of the cluster CoreDNS, which is rendered by the runtime.
+	ProviderCoreDNS = "coredns"
+	ProviderShell   = "shell"
+	ProviderAliDNS  = "alidns"
+	ProviderRoute53 = "route53"
+
+	DefaultTTL = 60
+
+	// EnvDNSDomain, EnvDNSIPs and EnvDNSTTL are the record to (de)register, for the shell provider.
+	EnvDNSDomain = "SEALER_DNS_DOMAIN"
+	EnvDNSIPs    = "SEALER_DNS_IPS"
+	EnvDNSTTL    = "SEALER_DNS_TTL"
+)
+
+// Provider manages the A records of the domains of cluster in a DNS.
+type Provider interface {
+	// Register points domain to ips, the existing records of domain are replaced.
+	Register(domain string, ips []string) error
+	// Deregister removes the records of domain, it is not an error if there is none.
+	Deregister(domain string) error
+}
+
+type ProviderFactory func(spec *v2.DNSProviderSpec) (Provider, error)
+
+var providers = map[string]ProviderFactory{
+	ProviderShell:   newShellProvider,
+	ProviderAliDNS:  newAliDNSProvider,
+	ProviderRoute53: newRoute53Provider,
+}
+
+// RegisterProvider makes a DNS provider available by name.
+func RegisterProvider(name string, factory ProviderFactory) {
+	if factory == nil {
+		panic("Must not provide nil ProviderFactory")
+	}
+	if _, registered := providers[name]; registered || IsBuiltin(name) {
+		panic(fmt.Sprintf("dns provider named %s already registered", name))
+	}
+	providers[name] = factory
+}
+
+// IsBuiltin reports whether the domains are resolved by /etc/hosts or the cluster CoreDNS instead of a provider.
+func IsBuiltin(name string) bool {
+	return name == "" || name == ProviderHosts || name == ProviderCoreDNS
+}
+
+// New returns the provider of spec, it is nil if the provider is builtin.
+func New(spec v2.DNSProviderSpec) (Provider, error) {
+	for _, ip := range spec.APIServerIPs {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid apiserver ip %s of dns provider", ip)
+		}
+	}
+	if IsBuiltin(spec.Name) {
+		return nil, nil
+	}
+	factory, ok := providers[spec.Name]
+	if !ok {
+		return nil, fmt.Errorf("dns provider not registered: %s", spec.Name)
+	}
+	if spec.TTL <= 0 {
+		spec.TTL = DefaultTTL
+	}
+	return factory(&spec)
+}
+
+// Manages reports whether domain is registered by the provider of spec, the other domains are written to
+// /etc/hosts of the hosts.
+func Manages(spec v2.DNSProviderSpec, domain string) bool {
+	if IsBuiltin(spec.Name) {
+		return false
+	}
+	return spec.Zone == "" || inZone(domain, spec.Zone)
+}
+
+func inZone(domain, zone string) bool {
+	domain, zone = strings.TrimSuffix(domain, "."), strings.TrimSuffix(zone, ".")
+	return domain == zone || strings.HasSuffix(domain, "."+zone)
+}
+
+// relativeName returns the name of domain relative to zone, "@" is the zone itself.
+func relativeName(domain, zone string) string {
+	domain, zone = strings.TrimSuffix(domain, "."), strings.TrimSuffix(zone, ".")
+	if domain == zone {
+		return "@"
+	}
+	return strings.TrimSuffix(domain, "."+zone)
+}
+
+// runCommand runs the command of the CLI of a cloud provider on the local host.
+var runCommand = defaultRunCommand
+
+func defaultRunCommand(name string, args ...string) ([]byte, error) {
+	// #nosec
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s %s: %v, %s", name, strings.Join(args, " "), err, out)
+	}
+	return out, nil
+}
+
+// shellProvider runs the register and deregister commands on the local host once per domain.
+type shellProvider struct {
+	register   string
+	deregister string
+	ttl        int
+}
+
+func newShellProvider(spec *v2.DNSProviderSpec) (Provider, error) {
+	if spec.Register == "" && spec.Deregister == "" {
+		return nil, fmt.Errorf("register or deregister command is required by the shell dns provider")
+	}
+	return &shellProvider{register: spec.Register, deregister: spec.Deregister, ttl: spec.TTL}, nil
+}
+
+func (s *shellProvider) Register(domain string, ips []string) error {
+	return s.run(s.register, domain, ips)
+}
+
+func (s *shellProvider) Deregister(domain string) error {
+	return s.run(s.deregister, domain, nil)
+}
+
+func (s *shellProvider) run(cmd, domain string, ips []string) error {
+	if strings.TrimSpace(cmd) == "" {
+		return nil
+	}
+	c := exec.Command("/bin/sh", "-c", cmd) // #nosec
+	c.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%s", EnvDNSDomain, domain),
+		fmt.Sprintf("%s=%s", EnvDNSIPs, strings.Join(ips, ",")),
+		fmt.Sprintf("%s=%s", EnvDNSTTL, strconv.Itoa(s.ttl)))
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to run dns command for %s: %v, %s", domain, err, out)
+	}
+	return nil
+}
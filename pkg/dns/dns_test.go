@@ -0,0 +1,137 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestManages(t *testing.T) {
+	tests := []struct {
+		spec   v2.DNSProviderSpec
+		domain string
+		want   bool
+	}{
+		{v2.DNSProviderSpec{}, "apiserver.cluster.local", false},
+		{v2.DNSProviderSpec{Name: ProviderCoreDNS}, "apiserver.cluster.local", false},
+		{v2.DNSProviderSpec{Name: ProviderShell}, "sea.hub", true},
+		{v2.DNSProviderSpec{Name: ProviderRoute53, Zone: "cluster.local"}, "apiserver.cluster.local", true},
+		{v2.DNSProviderSpec{Name: ProviderRoute53, Zone: "cluster.local."}, "cluster.local", true},
+		{v2.DNSProviderSpec{Name: ProviderRoute53, Zone: "cluster.local"}, "sea.hub", false},
+		{v2.DNSProviderSpec{Name: ProviderRoute53, Zone: "local"}, "apiserver.cluster.local", true},
+		{v2.DNSProviderSpec{Name: ProviderRoute53, Zone: "er.cluster.local"}, "apiserver.cluster.local", false},
+	}
+	for _, tt := range tests {
+		if got := Manages(tt.spec, tt.domain); got != tt.want {
+			t.Errorf("Manages(%+v, %s) = %v, want %v", tt.spec, tt.domain, got, tt.want)
+		}
+	}
+	if got := relativeName("apiserver.cluster.local", "cluster.local"); got != "apiserver" {
+		t.Errorf("relativeName() = %s, want apiserver", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, spec := range []v2.DNSProviderSpec{
+		{Name: "bind"},
+		{Name: ProviderShell},
+		{Name: ProviderAliDNS},
+		{Name: ProviderRoute53, Zone: "cluster.local"},
+		{Name: ProviderCoreDNS, APIServerIPs: []string{"192.168.0.300"}},
+	} {
+		if _, err := New(spec); err == nil {
+			t.Errorf("New(%+v) want error, got nil", spec)
+		}
+	}
+	if p, err := New(v2.DNSProviderSpec{Name: ProviderCoreDNS}); p != nil || err != nil {
+		t.Errorf("New(coredns) = %v, %v, want nil provider", p, err)
+	}
+}
+
+func TestShellProvider(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "records")
+	p, err := New(v2.DNSProviderSpec{
+		Name:       ProviderShell,
+		Register:   fmt.Sprintf(`echo "add $%s $%s $%s" >> %s`, EnvDNSDomain, EnvDNSIPs, EnvDNSTTL, out),
+		Deregister: fmt.Sprintf(`echo "del $%s" >> %s`, EnvDNSDomain, out),
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.Register("apiserver.cluster.local", []string{"192.168.0.2", "192.168.0.3"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := p.Deregister("sea.hub"); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "add apiserver.cluster.local 192.168.0.2,192.168.0.3 60\ndel sea.hub\n"
+	if string(data) != want {
+		t.Errorf("commands run = %q, want %q", data, want)
+	}
+}
+
+func TestRoute53Provider(t *testing.T) {
+	var cmds []string
+	runCommand = func(name string, args ...string) ([]byte, error) {
+		cmds = append(cmds, name+" "+strings.Join(args, " "))
+		switch args[1] {
+		case "list-resource-record-sets":
+			return []byte(`{"ResourceRecordSets": [{"Name": "apiserver.cluster.local.", "Type": "A", "TTL": 60,
+"ResourceRecords": [{"Value": "192.168.0.2"}]}]}`), nil
+		case "change-resource-record-sets":
+			return []byte(`{"ChangeInfo": {"Id": "/change/C1"}}`), nil
+		}
+		return nil, nil
+	}
+	defer func() {
+		runCommand = defaultRunCommand
+	}()
+
+	p, err := New(v2.DNSProviderSpec{Name: ProviderRoute53, Zone: "cluster.local", ZoneID: "Z1", TTL: 30})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := p.Register("apiserver.cluster.local", []string{"192.168.0.2", "192.168.0.3"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := p.Deregister("apiserver.cluster.local"); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	// the registry domain has no record, the next one is listed.
+	if err := p.Deregister("sea.hub"); err != nil {
+		t.Fatalf("Deregister() error = %v", err)
+	}
+	want := []string{
+		`aws route53 change-resource-record-sets --hosted-zone-id Z1 --change-batch {"Changes":[{"Action":"UPSERT","ResourceRecordSet":{"Name":"apiserver.cluster.local.","Type":"A","TTL":30,"ResourceRecords":[{"Value":"192.168.0.2"},{"Value":"192.168.0.3"}]}}]} --output json`,
+		`aws route53 wait resource-record-sets-changed --id /change/C1`,
+		`aws route53 list-resource-record-sets --hosted-zone-id Z1 --start-record-name apiserver.cluster.local. --start-record-type A --max-items 1 --output json`,
+		`aws route53 change-resource-record-sets --hosted-zone-id Z1 --change-batch {"Changes":[{"Action":"DELETE","ResourceRecordSet":{"Name":"apiserver.cluster.local.","Type":"A","TTL":60,"ResourceRecords":[{"Value":"192.168.0.2"}]}}]} --output json`,
+		`aws route53 wait resource-record-sets-changed --id /change/C1`,
+		`aws route53 list-resource-record-sets --hosted-zone-id Z1 --start-record-name sea.hub. --start-record-type A --max-items 1 --output json`,
+	}
+	if strings.Join(cmds, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands run:\n%s\nwant:\n%s", strings.Join(cmds, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+// AWSCLI is the command line of AWS, its credentials are the ones of its profile or the AWS_* env.
+const AWSCLI = "aws"
+
+// route53Provider manages the records in the hosted zone of ZoneID, a private hosted zone of cluster.local
+// associated with the VPC of the hosts usually.
+type route53Provider struct {
+	zoneID string
+	ttl    int
+}
+
+type route53Record struct {
+	Value string `json:"Value"`
+}
+
+type route53RecordSet struct {
+	Name            string          `json:"Name"`
+	Type            string          `json:"Type"`
+	TTL             int             `json:"TTL"`
+	ResourceRecords []route53Record `json:"ResourceRecords"`
+}
+
+type route53Change struct {
+	Action            string           `json:"Action"`
+	ResourceRecordSet route53RecordSet `json:"ResourceRecordSet"`
+}
+
+func newRoute53Provider(spec *v2.DNSProviderSpec) (Provider, error) {
+	if spec.Zone == "" || spec.ZoneID == "" {
+		return nil, fmt.Errorf("zone and zoneID are required by the route53 dns provider")
+	}
+	return &route53Provider{zoneID: spec.ZoneID, ttl: spec.TTL}, nil
+}
+
+func (r *route53Provider) Register(domain string, ips []string) error {
+	set := route53RecordSet{Name: fqdn(domain), Type: "A", TTL: r.ttl}
+	for _, ip := range ips {
+		set.ResourceRecords = append(set.ResourceRecords, route53Record{Value: ip})
+	}
+	return r.change(route53Change{Action: "UPSERT", ResourceRecordSet: set})
+}
+
+func (r *route53Provider) Deregister(domain string) error {
+	out, err := runCommand(AWSCLI, "route53", "list-resource-record-sets", "--hosted-zone-id", r.zoneID,
+		"--start-record-name", fqdn(domain), "--start-record-type", "A", "--max-items", "1", "--output", "json")
+	if err != nil {
+		return err
+	}
+	var sets struct {
+		ResourceRecordSets []route53RecordSet `json:"ResourceRecordSets"`
+	}
+	if err := json.Unmarshal(out, &sets); err != nil {
+		return fmt.Errorf("failed to decode the records of %s: %v", domain, err)
+	}
+	// the sets are listed from the start name, which is the next one if domain has no record.
+	if len(sets.ResourceRecordSets) == 0 || sets.ResourceRecordSets[0].Name != fqdn(domain) ||
+		sets.ResourceRecordSets[0].Type != "A" {
+		return nil
+	}
+	// a record set is deleted only if it is the same as the current one.
+	return r.change(route53Change{Action: "DELETE", ResourceRecordSet: sets.ResourceRecordSets[0]})
+}
+
+// change applies c and waits for it to be propagated to the name servers of the zone.
+func (r *route53Provider) change(c route53Change) error {
+	batch, err := json.Marshal(map[string]interface{}{"Changes": []route53Change{c}})
+	if err != nil {
+		return err
+	}
+	out, err := runCommand(AWSCLI, "route53", "change-resource-record-sets", "--hosted-zone-id", r.zoneID,
+		"--change-batch", string(batch), "--output", "json")
+	if err != nil {
+		return err
+	}
+	var info struct {
+		ChangeInfo struct {
+			ID string `json:"Id"`
+		} `json:"ChangeInfo"`
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return fmt.Errorf("failed to decode the change of %s: %v", c.ResourceRecordSet.Name, err)
+	}
+	_, err = runCommand(AWSCLI, "route53", "wait", "resource-record-sets-changed", "--id", info.ChangeInfo.ID)
+	return err
+}
+
+// fqdn returns the domain with the trailing dot, which is the name of record sets of route53.
+func fqdn(domain string) string {
+	return strings.TrimSuffix(domain, ".") + "."
+}
@@ -0,0 +1,239 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	DefaultMaxFileSize = 10 << 20
+	DefaultMaxSize     = 200 << 20
+	DefaultSince       = 24 * time.Hour
+
+	// errorsFile lists what failed to be collected.
+	errorsFile = "errors.txt"
+)
+
+type Options struct {
+	ClusterName string
+	// Output is the path of the tarball, sealer-doctor-[cluster name]-[time].tar.gz in the working dir if empty.
+	Output string
+	// MaxFileSize is the max size of each collected file, the files are truncated to their tail.
+	MaxFileSize int64
+	// MaxSize is the max size of all collected files, the files beyond it are skipped.
+	MaxSize int64
+	// Since is how far the journald logs go back.
+	Since time.Duration
+}
+
+type remoteItem struct {
+	name string
+	cmd  string
+}
+
+// remoteItems are the commands whose output is collected from every host. The sealer registry and its proxies
+// only run on the registry host, it is cheaper to look for them on all hosts than to find it.
+func remoteItems(rootfs string, since time.Duration) []remoteItem {
+	journal := "journalctl -u %s --no-pager --since -" + fmt.Sprintf("%ds", int64(since/time.Second))
+	return []remoteItem{
+		{"journal-kubelet.log", fmt.Sprintf(journal, "kubelet")},
+		{"journal-containerd.log", fmt.Sprintf(journal, "containerd")},
+		{"journal-docker.log", fmt.Sprintf(journal, "docker")},
+		{"kubeadm.log", fmt.Sprintf(`kubeadm version; for f in %s/kubeadm*.yaml; do echo "==> $f"; cat $f; done`, rootfs)},
+		{"registry.log", fmt.Sprintf(`for c in $(docker ps -a --format '{{.Names}}' | grep '^%s'); do echo "==> $c"; docker logs --tail 5000 $c; done`, runtime.RegistryName)},
+		{"mounts.log", "cat /proc/mounts; df -h"},
+	}
+}
+
+// bundle is the tarball being collected, the files are scrubbed and truncated before written.
+type bundle struct {
+	opts    Options
+	root    string
+	lock    sync.Mutex
+	tw      *tar.Writer
+	written int64
+	errs    []string
+}
+
+// Collect gathers the sealer logs, the redacted Clusterfile and the logs and mount state of all hosts of the cluster
+// into a tarball, and returns its path. The hosts which fail to be collected are listed in errors.txt of the tarball.
+func Collect(opts Options) (string, error) {
+	if opts.ClusterName == "" {
+		var err error
+		if opts.ClusterName, err = utils.GetDefaultClusterName(); err != nil {
+			return "", err
+		}
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.Since <= 0 {
+		opts.Since = DefaultSince
+	}
+	root := fmt.Sprintf("sealer-doctor-%s-%s", opts.ClusterName, time.Now().Format("20060102150405"))
+	if opts.Output == "" {
+		opts.Output = root + ".tar.gz"
+	}
+
+	f, err := os.OpenFile(opts.Output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	b := &bundle{opts: opts, root: root, tw: tar.NewWriter(gw)}
+
+	clusterfile := common.GetClusterWorkClusterfile(opts.ClusterName)
+	b.addLocalFile(common.DefaultClusterFileName, clusterfile)
+	b.addLocalFile("checkpoint.json", filepath.Join(common.GetClusterWorkDir(opts.ClusterName), "checkpoint.json"))
+	b.addLogs(common.DefaultLogDir)
+	cluster, err := utils.GetClusterFromFile(clusterfile)
+	if err != nil {
+		b.addError("failed to load cluster %s, skip collecting from hosts: %v", opts.ClusterName, err)
+	} else {
+		b.addHosts(cluster)
+	}
+	b.addErrors()
+
+	if err := b.tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gw.Close(); err != nil {
+		return "", err
+	}
+	return opts.Output, nil
+}
+
+// addLogs adds the sealer logs from the newest, the older ones are skipped once the bundle is full.
+func (b *bundle) addLogs(dir string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		b.addError("failed to read sealer logs: %v", err)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		b.addLocalFile(path.Join("logs", fi.Name()), filepath.Join(dir, fi.Name()))
+	}
+}
+
+func (b *bundle) addLocalFile(name, file string) {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		b.addError("failed to read %s: %v", file, err)
+		return
+	}
+	b.add(name, data)
+}
+
+func (b *bundle) addHosts(cluster *v2.Cluster) {
+	items := remoteItems(common.DefaultTheClusterRootfsDir(cluster.Name), b.opts.Since)
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			client, err := ssh.GetHostSSHClient(host, cluster)
+			if err != nil {
+				b.addError("failed to collect from %s: %v", host, err)
+				return
+			}
+			logger.Info("collecting from %s", host)
+			for _, item := range items {
+				// only the tail is fetched from the host, as the rest would be truncated anyway.
+				out, err := client.Cmd(host, fmt.Sprintf("(%s) 2>&1 | tail -c %d", item.cmd, b.opts.MaxFileSize))
+				if err != nil {
+					b.addError("failed to collect %s from %s: %v", item.name, host, err)
+				}
+				if len(out) > 0 {
+					b.add(path.Join("hosts", host, item.name), out)
+				}
+			}
+		}(host)
+	}
+	wg.Wait()
+}
+
+func (b *bundle) addError(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger.Warn("%s", msg)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.errs = append(b.errs, msg)
+}
+
+func (b *bundle) addErrors() {
+	if len(b.errs) == 0 {
+		return
+	}
+	sort.Strings(b.errs)
+	b.add(errorsFile, []byte(strings.Join(b.errs, "\n")+"\n"))
+}
+
+// add writes data scrubbed and truncated to its tail as file name of the bundle, unless the bundle is full.
+func (b *bundle) add(name string, data []byte) {
+	data = Scrub(data)
+	if int64(len(data)) > b.opts.MaxFileSize {
+		data = data[int64(len(data))-b.opts.MaxFileSize:]
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if name != errorsFile && b.written+int64(len(data)) > b.opts.MaxSize {
+		b.errs = append(b.errs, fmt.Sprintf("skip %s, the bundle exceeds %d bytes", name, b.opts.MaxSize))
+		return
+	}
+	hdr := &tar.Header{
+		Name:    path.Join(b.root, name),
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		b.errs = append(b.errs, fmt.Sprintf("failed to write %s: %v", name, err))
+		return
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		b.errs = append(b.errs, fmt.Sprintf("failed to write %s: %v", name, err))
+		return
+	}
+	b.written += int64(len(data))
+}
@@ -0,0 +1,43 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import "regexp"
+
+const redacted = "<redacted>"
+
+var scrubbers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// PEM encoded private keys.
+	{regexp.MustCompile(`-----BEGIN ([A-Z ]*)PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "-----BEGIN ${1}PRIVATE KEY-----" + redacted + "-----END ${1}PRIVATE KEY-----"},
+	// the values of the secret keys in yaml, json and env, like passwd: xxx, "password":"xxx" and TOKEN=xxx.
+	{regexp.MustCompile(`(?i)((?:passw(?:or)?d|token|secret|certificate-?key|key-data|private-?key|access-?key)[\w-]*"?[ \t]*[:=][ \t]*"?)([^\s",}]+)`), "${1}" + redacted},
+	// the values of the secret flags, like --token xxx.
+	{regexp.MustCompile(`(?i)(--[\w-]*(?:passw(?:or)?d|token|secret|certificate-key)[\w-]*[ \t]+)([^\s-]\S*)`), "${1}" + redacted},
+	// docker login -p xxx.
+	{regexp.MustCompile(`(docker login\s[^\n]*-p\s+)(\S+)`), "${1}" + redacted},
+	// kubeadm bootstrap tokens.
+	{regexp.MustCompile(`\b[a-z0-9]{6}\.[a-z0-9]{16}\b`), redacted},
+}
+
+// Scrub replaces the passwords, tokens and private keys in data, it is best effort only.
+func Scrub(data []byte) []byte {
+	for _, s := range scrubbers {
+		data = s.re.ReplaceAll(data, []byte(s.repl))
+	}
+	return data
+}
@@ -0,0 +1,171 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/alibaba/sealer/logger"
+	sealerssh "github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	DefaultSSHD = "127.0.0.1:22"
+	// maxRetryInterval is the max interval of reconnecting to the broker, it doubles from a second on each failure.
+	maxRetryInterval = 30 * time.Second
+)
+
+// Agent runs on the edge host behind NAT, it keeps a connection to the broker on master0, and connects the
+// channels opened by the broker to the local sshd.
+type Agent struct {
+	// Broker is the address of the broker, like 192.168.0.2:7000
+	Broker string
+	// Host is the IP of the edge host in Clusterfile
+	Host string
+	// Token is the host token of Host, see HostToken
+	Token string
+	// BrokerFingerprint is the SHA256 fingerprint of the host key of the broker, like SHA256:xxx
+	BrokerFingerprint string
+	// SSHD is the address of the local sshd, DefaultSSHD if empty
+	SSHD string
+}
+
+// Run connects to the broker and reconnects once the connection is broken, until ctx is done.
+func (a *Agent) Run(ctx context.Context) error {
+	if a.BrokerFingerprint == "" {
+		return fmt.Errorf("the fingerprint of broker host key is required")
+	}
+	interval := time.Second
+	for {
+		start := time.Now()
+		err := a.serve(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		// the connection lasting a while was fine, retry it soon.
+		if time.Since(start) > maxRetryInterval {
+			interval = time.Second
+		}
+		logger.Warn("edge agent disconnected from %s: %v, reconnect in %s", a.Broker, err, interval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+		if interval *= 2; interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+}
+
+func (a *Agent) serve(ctx context.Context) error {
+	config := &ssh.ClientConfig{
+		User:            a.Host,
+		Auth:            []ssh.AuthMethod{ssh.Password(a.Token)},
+		HostKeyCallback: a.checkHostKey,
+		Timeout:         15 * time.Second,
+	}
+	nc, err := net.DialTimeout("tcp", a.Broker, config.Timeout)
+	if err != nil {
+		return err
+	}
+	conn, chans, reqs, err := ssh.NewClientConn(nc, a.Broker, config)
+	if err != nil {
+		_ = nc.Close()
+		return err
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(reqs)
+	logger.Info("edge agent of %s connected to %s", a.Host, a.Broker)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+	go keepalive(conn, done)
+
+	sshd := a.SSHD
+	if sshd == "" {
+		sshd = DefaultSSHD
+	}
+	for newCh := range chans {
+		if newCh.ChannelType() != sealerssh.EdgeChannelType {
+			_ = newCh.Reject(ssh.UnknownChannelType, fmt.Sprintf("unknown channel type %s", newCh.ChannelType()))
+			continue
+		}
+		go func(newCh ssh.NewChannel) {
+			local, err := net.DialTimeout("tcp", sshd, config.Timeout)
+			if err != nil {
+				_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+				return
+			}
+			defer local.Close()
+			ch, reqs, err := newCh.Accept()
+			if err != nil {
+				return
+			}
+			defer ch.Close()
+			go ssh.DiscardRequests(reqs)
+			pipe(local, ch)
+		}(newCh)
+	}
+	return conn.Wait()
+}
+
+// checkHostKey refuses the broker whose host key is not pinned, which may be a man in the middle collecting the token.
+func (a *Agent) checkHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if got := ssh.FingerprintSHA256(key); got != a.BrokerFingerprint {
+		return fmt.Errorf("the fingerprint of broker %s is %s, not %s", a.Broker, got, a.BrokerFingerprint)
+	}
+	return nil
+}
+
+// keepalive closes conn once the broker does not reply a keepalive request, so that the agent reconnects instead
+// of waiting on a dead connection behind NAT.
+func keepalive(conn ssh.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(sealerssh.KeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+		select {
+		case err := <-replied:
+			if err == nil {
+				continue
+			}
+		case <-time.After(sealerssh.KeepaliveInterval):
+		}
+		_ = conn.Close()
+		return
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	sealerssh "github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// DefaultListen is the address the broker accepts the edge agents on.
+	DefaultListen    = ":7000"
+	DefaultTokenFile = "/etc/sealer/edge-token"
+	// DefaultHostKeyFile is the ssh host key of the broker, the agents pin its fingerprint.
+	DefaultHostKeyFile = "/etc/sealer/edge-broker.key"
+	hostKeyPEMType     = "EC PRIVATE KEY"
+)
+
+// Broker runs on master0, the edge agents behind NAT keep a connection to it, and the connections to their sshd
+// asked on the control address are carried by them as the channels of the ssh protocol.
+type Broker struct {
+	token   string
+	hostKey ssh.Signer
+	mu      sync.Mutex
+	// agents are keyed by the IP of their host
+	agents map[string]ssh.Conn
+}
+
+// NewBroker returns the broker which the agents log in to with the host tokens of token, hostKey is the ssh
+// host key the agents authenticate the broker by.
+func NewBroker(token string, hostKey ssh.Signer) *Broker {
+	return &Broker{token: token, hostKey: hostKey, agents: map[string]ssh.Conn{}}
+}
+
+// HostToken is the token the agent of host logs in with, it is derived from the token of the broker and only valid
+// for host, so that the agent of an edge host can not claim another one.
+func HostToken(token, host string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	_, _ = mac.Write([]byte(host))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadOrCreateHostKey returns the ssh host key in file, a new one is written to it if it does not exist.
+func LoadOrCreateHostKey(file string) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), common.FileMode0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: hostKeyPEMType, Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+// Fingerprint is the SHA256 fingerprint of the host key of the broker, like SHA256:xxx, which the agents pin.
+func Fingerprint(hostKey ssh.Signer) string {
+	return ssh.FingerprintSHA256(hostKey.PublicKey())
+}
+
+// LoadOrCreateToken returns the token in file, a random one is written to it if it does not exist.
+func LoadOrCreateToken(file string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(file), common.FileMode0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(file, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Agents returns the IPs of the hosts whose agent is connected.
+func (b *Broker) Agents() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var hosts []string
+	for host := range b.agents {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// ServeAgents accepts the edge agents on l until it is closed, the agents log in with the IP of their host as the
+// user and the host token of it as the password.
+func (b *Broker) ServeAgents(l net.Listener) error {
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if subtle.ConstantTimeCompare(password, []byte(HostToken(b.token, meta.User()))) != 1 {
+				return nil, fmt.Errorf("invalid token of edge agent %s", meta.User())
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(b.hostKey)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go b.handleAgent(conn, config)
+	}
+}
+
+func (b *Broker) handleAgent(nc net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		logger.Warn("failed to accept edge agent from %s: %v", nc.RemoteAddr(), err)
+		_ = nc.Close()
+		return
+	}
+	host := conn.User()
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		for ch := range chans {
+			_ = ch.Reject(ssh.Prohibited, "the broker opens the channels")
+		}
+	}()
+
+	b.mu.Lock()
+	// the agent reconnecting replaces its stale connection.
+	if old, ok := b.agents[host]; ok {
+		_ = old.Close()
+	}
+	b.agents[host] = conn
+	b.mu.Unlock()
+	logger.Info("edge agent of %s connected from %s", host, nc.RemoteAddr())
+
+	_ = conn.Wait()
+	b.mu.Lock()
+	if b.agents[host] == conn {
+		delete(b.agents, host)
+	}
+	b.mu.Unlock()
+	logger.Info("edge agent of %s disconnected", host)
+}
+
+// ServeControl accepts the connections to the edge hosts on l until it is closed, l must only be reachable from
+// master0, like on 127.0.0.1, as the connections are not authenticated but by the sshd of the edge hosts.
+func (b *Broker) ServeControl(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go b.handleControl(conn)
+	}
+}
+
+func (b *Broker) handleControl(conn net.Conn) {
+	defer conn.Close()
+	// the request is the only line sent before the ssh protocol, which is sent after the reply.
+	line, err := bufio.NewReader(io.LimitReader(conn, 256)).ReadString('\n')
+	if err != nil {
+		return
+	}
+	var host string
+	if _, err := fmt.Sscanf(line, sealerssh.EdgeConnect, &host); err != nil {
+		_, _ = fmt.Fprintf(conn, "invalid request %q\n", strings.TrimSpace(line))
+		return
+	}
+	b.mu.Lock()
+	agent, ok := b.agents[host]
+	b.mu.Unlock()
+	if !ok {
+		_, _ = fmt.Fprintf(conn, "the edge agent of %s is not connected\n", host)
+		return
+	}
+	ch, reqs, err := agent.OpenChannel(sealerssh.EdgeChannelType, nil)
+	if err != nil {
+		_, _ = fmt.Fprintf(conn, "failed to open channel to %s: %v\n", host, err)
+		return
+	}
+	defer ch.Close()
+	go ssh.DiscardRequests(reqs)
+	if _, err := fmt.Fprintln(conn, sealerssh.EdgeOK); err != nil {
+		return
+	}
+	pipe(conn, ch)
+}
+
+// pipe copies between a and b until either side is closed.
+func pipe(a io.ReadWriter, b io.ReadWriter) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
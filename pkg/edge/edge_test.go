@@ -0,0 +1,189 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sealerssh "github.com/alibaba/sealer/utils/ssh"
+)
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	return l
+}
+
+// connect asks the broker on control for host, and returns the reply and the connection.
+func connect(t *testing.T, control, host string) (string, net.Conn) {
+	conn, err := net.Dial("tcp", control)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fmt.Fprintf(conn, sealerssh.EdgeConnect, host); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(reply), conn
+}
+
+func TestBrokerAndAgent(t *testing.T) {
+	// an echo server stands for the sshd of the edge host.
+	sshd := listen(t)
+	go func() {
+		for {
+			c, err := sshd.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _ = io.Copy(c, c)
+			}()
+		}
+	}()
+	agents, control := listen(t), listen(t)
+	hostKey, err := LoadOrCreateHostKey(filepath.Join(t.TempDir(), "edge-broker.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := NewBroker("token", hostKey)
+	go func() {
+		_ = broker.ServeAgents(agents)
+	}()
+	go func() {
+		_ = broker.ServeControl(control)
+	}()
+
+	reply, conn := connect(t, control.Addr().String(), "10.0.0.5")
+	_ = conn.Close()
+	if reply != "the edge agent of 10.0.0.5 is not connected" {
+		t.Errorf("reply before the agent connected = %s", reply)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fingerprint := Fingerprint(hostKey)
+	for _, bad := range []*Agent{
+		{Host: "10.0.0.6", Token: "bad", BrokerFingerprint: fingerprint},
+		// the agent of an edge host can not claim another one.
+		{Host: "10.0.0.7", Token: HostToken("token", "10.0.0.5"), BrokerFingerprint: fingerprint},
+		{Host: "10.0.0.8", Token: "token", BrokerFingerprint: fingerprint},
+	} {
+		bad.Broker = agents.Addr().String()
+		go func(a *Agent) {
+			_ = a.Run(ctx)
+		}(bad)
+	}
+	agent := &Agent{Broker: agents.Addr().String(), Host: "10.0.0.5", Token: HostToken("token", "10.0.0.5"),
+		BrokerFingerprint: fingerprint, SSHD: sshd.Addr().String()}
+	go func() {
+		_ = agent.Run(ctx)
+	}()
+	for i := 0; len(broker.Agents()) == 0; i++ {
+		if i == 50 {
+			t.Fatal("the agent is not connected")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if hosts := broker.Agents(); len(hosts) != 1 || hosts[0] != "10.0.0.5" {
+		t.Errorf("Agents() = %v, want the one with valid token only", hosts)
+	}
+
+	reply, conn = connect(t, control.Addr().String(), "10.0.0.5")
+	defer conn.Close()
+	if reply != sealerssh.EdgeOK {
+		t.Fatalf("reply = %s, want %s", reply, sealerssh.EdgeOK)
+	}
+	if _, err := conn.Write([]byte("SSH-2.0-test\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "SSH-2.0-test\n" {
+		t.Errorf("read %q, %v from the edge host, want the echo", line, err)
+	}
+}
+
+func TestAgentRefusesUnpinnedBroker(t *testing.T) {
+	agents := listen(t)
+	hostKey, err := LoadOrCreateHostKey(filepath.Join(t.TempDir(), "edge-broker.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := NewBroker("token", hostKey)
+	go func() {
+		_ = broker.ServeAgents(agents)
+	}()
+	other, err := LoadOrCreateHostKey(filepath.Join(t.TempDir(), "other.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agent := &Agent{Broker: agents.Addr().String(), Host: "10.0.0.5", Token: HostToken("token", "10.0.0.5")}
+	if err = agent.Run(context.Background()); err == nil {
+		t.Errorf("expected error of running agent without the fingerprint of broker")
+	}
+	agent.BrokerFingerprint = Fingerprint(other)
+	if err = agent.serve(context.Background()); err == nil || !strings.Contains(err.Error(), "fingerprint") {
+		t.Errorf("expected error of the fingerprint mismatched, got %v", err)
+	}
+	if hosts := broker.Agents(); len(hosts) != 0 {
+		t.Errorf("Agents() = %v, want none", hosts)
+	}
+}
+
+func TestLoadOrCreateHostKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "edge", "edge-broker.key")
+	key, err := LoadOrCreateHostKey(file)
+	if err != nil {
+		t.Fatalf("LoadOrCreateHostKey() error: %v", err)
+	}
+	// the broker keeps its host key across restarts.
+	loaded, err := LoadOrCreateHostKey(file)
+	if err != nil {
+		t.Fatalf("LoadOrCreateHostKey() error: %v", err)
+	}
+	if Fingerprint(key) != Fingerprint(loaded) {
+		t.Errorf("fingerprint of loaded host key %s, want %s", Fingerprint(loaded), Fingerprint(key))
+	}
+}
+
+func TestHostToken(t *testing.T) {
+	if HostToken("token", "10.0.0.5") != HostToken("token", "10.0.0.5") {
+		t.Errorf("expected the same host token of the same host")
+	}
+	if HostToken("token", "10.0.0.5") == HostToken("token", "10.0.0.6") {
+		t.Errorf("expected different host tokens of different hosts")
+	}
+	if HostToken("token", "10.0.0.5") == HostToken("other", "10.0.0.5") {
+		t.Errorf("expected different host tokens of different broker tokens")
+	}
+}
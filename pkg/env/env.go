@@ -98,6 +98,11 @@ func mergeList(dst, src []string) []string {
 	return dst
 }
 
+// GetHostEnv returns the env of hostIP, its host env overwrites cluster.Spec.Env.
+func GetHostEnv(cluster *v2.Cluster, hostIP string) map[string]interface{} {
+	return (&processor{cluster}).getHostEnv(hostIP)
+}
+
 // Merge the host ENV and global env, the host env will overwrite cluster.Spec.Env
 func (p *processor) getHostEnv(hostIP string) (env map[string]interface{}) {
 	var hostEnv []string
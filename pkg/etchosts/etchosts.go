@@ -0,0 +1,239 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etchosts
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// The domains sealer points to the hosts, like the registry and apiserver domains, are kept in the block between
+// the markers of /etc/hosts, so they are updated in place instead of appended on every apply.
+const (
+	BeginMarker = "# BEGIN sealer managed hosts"
+	EndMarker   = "# END sealer managed hosts"
+
+	Path = "/etc/hosts"
+
+	// RemoteRemoveBlock removes the managed block from /etc/hosts, for the hosts reset later by recorded commands.
+	RemoteRemoveBlock = `sed -i "/^` + BeginMarker + `$/,/^` + EndMarker + `$/d" ` + Path
+	// RemoteReplace replaces /etc/hosts with the new one %[1]s keeping its mode and owner. It is renamed to be
+	// atomic, and copied over if /etc/hosts is a bind mount which can not be renamed to, like in containers.
+	RemoteReplace = "chmod --reference=" + Path + " %[1]s && chown --reference=" + Path + " %[1]s && " +
+		"(mv -f %[1]s " + Path + " 2>/dev/null || (cat %[1]s > " + Path + " && rm -f %[1]s))"
+	remoteTmpPath = Path + ".sealer.tmp"
+)
+
+// Entry points Domain to IP.
+type Entry struct {
+	IP     string
+	Domain string
+}
+
+func (e Entry) String() string {
+	return e.IP + " " + e.Domain
+}
+
+// Update returns content with the domains of set pointed to their IPs and the ones of remove dropped from the
+// managed block. The managed domains are dropped from the lines out of the block, which were appended by the sealer
+// before or point them elsewhere, so each of them is resolved by a single line. The block is removed once it is empty.
+func Update(content string, set []Entry, remove ...string) string {
+	managed := map[string]bool{}
+	for _, e := range set {
+		managed[e.Domain] = true
+	}
+	for _, d := range remove {
+		managed[d] = true
+	}
+
+	var (
+		lines   []string
+		entries []Entry
+		inBlock bool
+		changed = len(set) > 0
+	)
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == BeginMarker:
+			inBlock, changed = true, true
+			continue
+		case trimmed == EndMarker:
+			inBlock = false
+			continue
+		case inBlock:
+			fields := strings.Fields(trimmed)
+			for _, d := range fieldsDomains(fields) {
+				if !managed[d] {
+					entries = append(entries, Entry{IP: fields[0], Domain: d})
+				}
+			}
+			continue
+		}
+		dropped, ok := dropDomains(line, managed)
+		if ok {
+			lines = append(lines, dropped)
+		}
+		changed = changed || !ok || dropped != line
+	}
+	if !changed {
+		return content
+	}
+	entries = append(entries, set...)
+
+	// the block is kept where it was at the end of the file.
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(entries) > 0 {
+		lines = append(lines, BeginMarker)
+		for _, e := range dedup(entries) {
+			lines = append(lines, e.String())
+		}
+		lines = append(lines, EndMarker)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func fieldsDomains(fields []string) []string {
+	if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+	return fields[1:]
+}
+
+// dropDomains returns line without the domains in managed, ok is false if no domain is left in it.
+func dropDomains(line string, managed map[string]bool) (string, bool) {
+	content, comment := line, ""
+	if i := strings.Index(line, "#"); i >= 0 {
+		content, comment = line[:i], line[i:]
+	}
+	fields := strings.Fields(content)
+	domains := fieldsDomains(fields)
+	var kept []string
+	for _, d := range domains {
+		if !managed[d] {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == len(domains) {
+		return line, true
+	}
+	if len(kept) == 0 {
+		return "", false
+	}
+	line = strings.Join(append([]string{fields[0]}, kept...), " ")
+	if comment != "" {
+		line += " " + comment
+	}
+	return line, true
+}
+
+// dedup keeps the last entry of each domain.
+func dedup(entries []Entry) []Entry {
+	last := map[string]int{}
+	for i, e := range entries {
+		last[e.Domain] = i
+	}
+	var res []Entry
+	for i, e := range entries {
+		if last[e.Domain] == i {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+var (
+	hostLocksLock sync.Mutex
+	hostLocks     = map[string]*sync.Mutex{}
+)
+
+// lockHost serializes the updates of /etc/hosts of host in this process, which read and write the whole file.
+func lockHost(host string) func() {
+	hostLocksLock.Lock()
+	l, ok := hostLocks[host]
+	if !ok {
+		l = &sync.Mutex{}
+		hostLocks[host] = l
+	}
+	hostLocksLock.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// Apply updates the managed block of /etc/hosts on host by Update, it is not written if nothing is changed.
+func Apply(client ssh.Interface, host string, set []Entry, remove ...string) error {
+	defer lockHost(host)()
+	out, err := client.Cmd(host, "cat "+Path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s of %s: %v, %s", Path, host, err, out)
+	}
+	updated := Update(string(out), set, remove...)
+	if updated == string(out) {
+		return nil
+	}
+
+	f, err := ioutil.TempFile("", "sealer-hosts")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(updated); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = client.Copy(host, f.Name(), remoteTmpPath); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %v", Path, host, err)
+	}
+	if err = client.CmdAsync(host, fmt.Sprintf(RemoteReplace, remoteTmpPath)); err != nil {
+		return fmt.Errorf("failed to update %s of %s: %v", Path, host, err)
+	}
+	return nil
+}
+
+// UpdateFile updates the managed block of the local hosts file path by Update, it is not written if nothing is
+// changed.
+func UpdateFile(path string, set []Entry, remove ...string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	updated := Update(string(data), set, remove...)
+	if updated == string(data) {
+		return nil
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := utils.AtomicWriteFile(path, []byte(updated), mode); err != nil {
+		// /etc/hosts bind mounted in containers can not be renamed to.
+		return ioutil.WriteFile(path, []byte(updated), mode)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etchosts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const base = `127.0.0.1 localhost
+::1 localhost ip6-localhost
+`
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		set     []Entry
+		remove  []string
+		want    string
+	}{
+		{
+			name:    "add the block",
+			content: base,
+			set:     []Entry{{IP: "192.168.0.2", Domain: "sea.hub"}, {IP: "192.168.0.2", Domain: "apiserver.cluster.local"}},
+			want: base + `# BEGIN sealer managed hosts
+192.168.0.2 sea.hub
+192.168.0.2 apiserver.cluster.local
+# END sealer managed hosts
+`,
+		},
+		{
+			name: "update in place",
+			content: base + `# BEGIN sealer managed hosts
+192.168.0.2 sea.hub
+192.168.0.2 apiserver.cluster.local
+# END sealer managed hosts
+`,
+			set: []Entry{{IP: "192.168.0.3", Domain: "apiserver.cluster.local"}},
+			want: base + `# BEGIN sealer managed hosts
+192.168.0.2 sea.hub
+192.168.0.3 apiserver.cluster.local
+# END sealer managed hosts
+`,
+		},
+		{
+			name: "drop the lines appended before",
+			content: base + `192.168.0.2 sea.hub
+192.168.0.2 apiserver.cluster.local
+192.168.0.2 sea.hub
+10.0.0.1 my.host apiserver.cluster.local # mine
+`,
+			set: []Entry{{IP: "192.168.0.2", Domain: "sea.hub"}, {IP: "192.168.0.3", Domain: "apiserver.cluster.local"}},
+			want: base + `10.0.0.1 my.host # mine
+# BEGIN sealer managed hosts
+192.168.0.2 sea.hub
+192.168.0.3 apiserver.cluster.local
+# END sealer managed hosts
+`,
+		},
+		{
+			name: "remove the empty block",
+			content: base + `# BEGIN sealer managed hosts
+192.168.0.2 sea.hub
+# END sealer managed hosts
+`,
+			remove: []string{"sea.hub"},
+			want:   base,
+		},
+		{
+			name:    "unchanged",
+			content: base + "\n\n",
+			remove:  []string{"sea.hub"},
+			want:    base + "\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Update(tt.content, tt.set, tt.remove...)
+			if got != tt.want {
+				t.Errorf("Update() =\n%s\nwant\n%s", got, tt.want)
+			}
+			// updating again changes nothing.
+			if again := Update(got, tt.set, tt.remove...); again != got {
+				t.Errorf("Update() again =\n%s\nwant\n%s", again, got)
+			}
+		})
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-etchosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hosts")
+	if err := ioutil.WriteFile(path, []byte(base), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := UpdateFile(path, []Entry{{IP: "192.168.0.2", Domain: "apiserver.cluster.local"}}); err != nil {
+			t.Fatalf("UpdateFile() error = %v", err)
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := base + BeginMarker + "\n192.168.0.2 apiserver.cluster.local\n" + EndMarker + "\n"
+	if string(data) != want {
+		t.Errorf("UpdateFile() wrote\n%s\nwant\n%s", data, want)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("UpdateFile() changed the mode of %s: %v, %v", path, fi, err)
+	}
+}
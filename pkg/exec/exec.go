@@ -15,23 +15,32 @@
 package exec
 
 import (
-	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/alibaba/sealer/common"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
 	"github.com/alibaba/sealer/utils/ssh"
-	"golang.org/x/sync/errgroup"
 )
 
 type Exec struct {
 	cluster *v2.Cluster
 	ipList  []string
+	// Parallel is the max number of hosts operated at the same time, 0 means no limit.
+	Parallel int
 }
 
-func NewExecCmd(clusterName string, roles string) (Exec, error) {
+// Result is the output of one host.
+type Result struct {
+	Host   string
+	Output string
+	Err    error
+}
+
+// NewExecCmd selects the hosts by roles or ips, all hosts of cluster if both are empty.
+func NewExecCmd(clusterName, roles, ips string) (Exec, error) {
 	if clusterName == "" {
 		var err error
 		clusterName, err = utils.GetDefaultClusterName()
@@ -44,10 +53,23 @@ func NewExecCmd(clusterName string, roles string) (Exec, error) {
 	if err != nil {
 		return Exec{}, err
 	}
+	if roles != "" && ips != "" {
+		return Exec{}, fmt.Errorf("only one of roles and ips can be set")
+	}
+	allHosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
 	var ipList []string
-	if roles == "" {
-		ipList = append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
-	} else {
+	switch {
+	case ips != "":
+		if err := utils.AssemblyIPList(&ips); err != nil {
+			return Exec{}, err
+		}
+		for _, ip := range strings.Split(ips, ",") {
+			if utils.NotIn(ip, allHosts) {
+				return Exec{}, fmt.Errorf("host %s is not in cluster %s", ip, clusterName)
+			}
+			ipList = append(ipList, ip)
+		}
+	case roles != "":
 		roles := strings.Split(roles, ",")
 		for _, role := range roles {
 			ipList = append(ipList, cluster.GetIPSByRole(role)...)
@@ -55,29 +77,100 @@ func NewExecCmd(clusterName string, roles string) (Exec, error) {
 		if len(ipList) == 0 {
 			return Exec{}, fmt.Errorf("failed to get ipList, please check your roles label")
 		}
+	default:
+		ipList = allHosts
 	}
-	return Exec{cluster: cluster, ipList: ipList}, nil
+	return Exec{cluster: cluster, ipList: removeDuplicate(ipList)}, nil
 }
 
+// RunCmd runs the command on all hosts, and prints the output of each host after all finished.
 func (exec *Exec) RunCmd(args ...string) error {
-	eg, _ := errgroup.WithContext(context.Background())
-	ipList := exec.ipList
-	for _, ip := range ipList {
-		ip := ip
-		eg.Go(func() error {
-			sshClient, sshErr := ssh.GetHostSSHClient(ip, exec.cluster)
-			if sshErr != nil {
-				return sshErr
-			}
-			err := sshClient.CmdAsync(ip, args...)
+	cmd := strings.Join(args, " ")
+	results := exec.run(func(sshClient ssh.Interface, ip string) (string, error) {
+		out, err := sshClient.Cmd(ip, cmd)
+		return string(out), err
+	})
+	printResults(results)
+	if err := resultsError(results); err != nil {
+		return fmt.Errorf("failed to sealer exec command, err: %v", err)
+	}
+	return nil
+}
+
+// Copy copies the local file or dir to dst of all hosts.
+func (exec *Exec) Copy(src, dst string) error {
+	results := exec.run(func(sshClient ssh.Interface, ip string) (string, error) {
+		return "", sshClient.Copy(ip, src, dst)
+	})
+	printResults(results)
+	if err := resultsError(results); err != nil {
+		return fmt.Errorf("failed to sealer cp %s, err: %v", src, err)
+	}
+	return nil
+}
+
+// run executes f on the hosts in parallel, no more than Parallel at the same time, results are in the order of hosts.
+func (exec *Exec) run(f func(sshClient ssh.Interface, ip string) (string, error)) []Result {
+	results := make([]Result, len(exec.ipList))
+	parallel := exec.Parallel
+	if parallel <= 0 || parallel > len(exec.ipList) {
+		parallel = len(exec.ipList)
+	}
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, ip := range exec.ipList {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ip string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i].Host = ip
+			sshClient, err := ssh.GetHostSSHClient(ip, exec.cluster)
 			if err != nil {
-				return err
+				results[i].Err = err
+				return
 			}
-			return nil
-		})
+			results[i].Output, results[i].Err = f(sshClient, ip)
+		}(i, ip)
 	}
-	if err := eg.Wait(); err != nil {
-		return fmt.Errorf("failed to sealer exec command, err: %v", err)
+	wg.Wait()
+	return results
+}
+
+func printResults(results []Result) {
+	for _, r := range results {
+		status := "ok"
+		if r.Err != nil {
+			status = "failed"
+		}
+		fmt.Fprintf(common.StdOut, "[%s] %s\n", r.Host, status)
+		if out := strings.TrimRight(r.Output, "\n"); out != "" {
+			fmt.Fprintln(common.StdOut, out)
+		}
 	}
-	return nil
+}
+
+func resultsError(results []Result) error {
+	var failed []string
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", r.Host, r.Err))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d hosts failed: %s", len(failed), len(results), strings.Join(failed, "; "))
+}
+
+func removeDuplicate(ipList []string) []string {
+	var res []string
+	for _, ip := range ipList {
+		if utils.NotIn(ip, res) {
+			res = append(res, ip)
+		}
+	}
+	return res
 }
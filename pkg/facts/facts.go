@@ -0,0 +1,277 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package facts gathers the profile of a host, like its OS, kernel, arch, CPU, memory and disks, by one command,
+// which is cached for the phases of apply asking for it, instead of each running its own commands.
+package facts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	Cgroup2Fs = "cgroup2fs"
+
+	// RemoteGatherFacts prints the facts of a host as KEY=VALUE lines, a disk per disk line.
+	RemoteGatherFacts = `echo "hostname=$(hostname)"
+(. /etc/os-release 2>/dev/null; echo "os=$ID"; echo "os_version=$VERSION_ID")
+echo "kernel=$(uname -r)"
+echo "arch=$(uname -m)"
+echo "cpus=$(nproc)"
+echo "memory_kb=$(awk '/^MemTotal:/{print $2}' /proc/meminfo)"
+echo "cgroup_fs=$(stat -fc %T /sys/fs/cgroup)"
+for cri in docker containerd crio; do systemctl is-active --quiet $cri 2>/dev/null && echo "cri=$cri"; done
+lsblk -bdnr -o NAME,SIZE,ROTA,TYPE 2>/dev/null | awk '$4 == "disk" {print "disk=" $1 " " $2 " " $3}'
+true`
+
+	factsDir = "facts"
+)
+
+// Disk is a block device of a host, without its partitions.
+type Disk struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Rotational is true for HDD, false for SSD.
+	Rotational bool `json:"rotational"`
+}
+
+// Host is the profile of a host.
+type Host struct {
+	IP        string `json:"ip"`
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	OSVersion string `json:"osVersion"`
+	Kernel    string `json:"kernel"`
+	// Arch is the machine hardware name of `uname -m`, like x86_64 and aarch64.
+	Arch     string `json:"arch"`
+	CPUs     int    `json:"cpus"`
+	MemoryKB int64  `json:"memoryKB"`
+	// CgroupFs is the file system type of /sys/fs/cgroup, cgroup2fs for cgroup v2.
+	CgroupFs string `json:"cgroupFs"`
+	// ActiveCRIs are the container runtimes running on the host, like docker and containerd.
+	ActiveCRIs []string  `json:"activeCRIs,omitempty"`
+	Disks      []Disk    `json:"disks,omitempty"`
+	GatheredAt time.Time `json:"gatheredAt"`
+}
+
+// CgroupV2 returns true if the host runs cgroup v2.
+func (h *Host) CgroupV2() bool {
+	return h.CgroupFs == Cgroup2Fs
+}
+
+// CRIActive returns true if the container runtime cri is running on the host.
+func (h *Host) CRIActive(cri string) bool {
+	return !utils.NotIn(cri, h.ActiveCRIs)
+}
+
+// Env returns the facts as the env of the commands run on the host, like HOST_ARCH=x86_64.
+func (h *Host) Env() map[string]string {
+	return map[string]string{
+		"HOST_HOSTNAME":   h.Hostname,
+		"HOST_OS":         h.OS,
+		"HOST_OS_VERSION": h.OSVersion,
+		"HOST_KERNEL":     h.Kernel,
+		"HOST_ARCH":       h.Arch,
+		"HOST_CPUS":       strconv.Itoa(h.CPUs),
+		"HOST_MEMORY_KB":  strconv.FormatInt(h.MemoryKB, 10),
+	}
+}
+
+var (
+	cache     = map[string]*Host{}
+	cacheLock sync.Mutex
+	// hostLocks makes the phases asking for the facts of the same host at the same time gather them once.
+	hostLocks sync.Map
+)
+
+// Get returns the facts of host, they are gathered once per process and cached, until Refresh.
+func Get(cluster *v2.Cluster, host string) (*Host, error) {
+	key := cluster.Name + "/" + host
+	l, _ := hostLocks.LoadOrStore(key, &sync.Mutex{})
+	l.(*sync.Mutex).Lock()
+	defer l.(*sync.Mutex).Unlock()
+
+	cacheLock.Lock()
+	h, ok := cache[key]
+	cacheLock.Unlock()
+	if ok {
+		return h, nil
+	}
+	return gather(cluster, host)
+}
+
+// GetAll returns the facts of hosts in the same order, gathering the ones not cached concurrently.
+func GetAll(cluster *v2.Cluster, hosts []string) ([]*Host, error) {
+	var (
+		wg    sync.WaitGroup
+		all   = make([]*Host, len(hosts))
+		errs  = make([]error, len(hosts))
+		descs []string
+	)
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			all[i], errs[i] = Get(cluster, host)
+		}(i, host)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			descs = append(descs, err.Error())
+		}
+	}
+	if len(descs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(descs, "; "))
+	}
+	return all, nil
+}
+
+// Refresh gathers the facts of host again, like after the host is changed by apply.
+func Refresh(cluster *v2.Cluster, host string) (*Host, error) {
+	key := cluster.Name + "/" + host
+	l, _ := hostLocks.LoadOrStore(key, &sync.Mutex{})
+	l.(*sync.Mutex).Lock()
+	defer l.(*sync.Mutex).Unlock()
+	return gather(cluster, host)
+}
+
+// Load returns the facts of host saved by the last gathering, nil if they were never gathered.
+func Load(clusterName, host string) (*Host, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path(clusterName, host)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := &Host{}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("failed to parse the facts of %s: %v", host, err)
+	}
+	return h, nil
+}
+
+func gather(cluster *v2.Cluster, host string) (*Host, error) {
+	client, err := ssh.GetHostSSHClient(host, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("new ssh client failed %v", err)
+	}
+	out, err := client.Cmd(host, RemoteGatherFacts)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to gather facts: %v", host, err)
+	}
+	h, err := Parse(string(out))
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %v", host, err)
+	}
+	h.IP = host
+	h.GatheredAt = time.Now()
+
+	cacheLock.Lock()
+	cache[cluster.Name+"/"+host] = h
+	cacheLock.Unlock()
+	// the saved facts are only for sealer facts, failing to save them does not fail the phase.
+	_ = save(cluster.Name, h)
+	return h, nil
+}
+
+// Parse parses the output of RemoteGatherFacts.
+func Parse(out string) (*Host, error) {
+	h := &Host{}
+	for _, line := range strings.Split(out, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var err error
+		switch v := strings.TrimSpace(kv[1]); kv[0] {
+		case "hostname":
+			h.Hostname = v
+		case "os":
+			h.OS = v
+		case "os_version":
+			h.OSVersion = v
+		case "kernel":
+			h.Kernel = v
+		case "arch":
+			h.Arch = v
+		case "cpus":
+			h.CPUs, err = strconv.Atoi(v)
+		case "memory_kb":
+			h.MemoryKB, err = strconv.ParseInt(v, 10, 64)
+		case "cgroup_fs":
+			h.CgroupFs = v
+		case "cri":
+			h.ActiveCRIs = append(h.ActiveCRIs, v)
+		case "disk":
+			var d Disk
+			d, err = parseDisk(v)
+			h.Disks = append(h.Disks, d)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid fact %s: %v", line, err)
+		}
+	}
+	if h.Arch == "" || h.Kernel == "" {
+		return nil, fmt.Errorf("failed to gather facts, got: %s", out)
+	}
+	sort.Slice(h.Disks, func(i, j int) bool {
+		return h.Disks[i].Name < h.Disks[j].Name
+	})
+	return h, nil
+}
+
+// parseDisk parses NAME SIZE ROTA of lsblk.
+func parseDisk(v string) (Disk, error) {
+	fields := strings.Fields(v)
+	if len(fields) != 3 {
+		return Disk{}, fmt.Errorf("want NAME SIZE ROTA")
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return Disk{}, err
+	}
+	return Disk{Name: fields[0], Size: size, Rotational: fields[2] == "1"}, nil
+}
+
+func path(clusterName, host string) string {
+	return filepath.Join(common.GetClusterWorkDir(clusterName), factsDir, host+".json")
+}
+
+func save(clusterName string, h *Host) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := path(clusterName, h.IP)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, data, 0600)
+}
@@ -0,0 +1,67 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package facts
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	out := `hostname=node1
+os=centos
+os_version=7
+kernel=4.19.91-24.1.al7.x86_64
+arch=x86_64
+cpus=8
+memory_kb=16266040
+cgroup_fs=cgroup2fs
+cri=containerd
+disk=vdb 107374182400 0
+disk=vda 42949672960 1
+`
+	h, err := Parse(out)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := &Host{
+		Hostname:   "node1",
+		OS:         "centos",
+		OSVersion:  "7",
+		Kernel:     "4.19.91-24.1.al7.x86_64",
+		Arch:       "x86_64",
+		CPUs:       8,
+		MemoryKB:   16266040,
+		CgroupFs:   "cgroup2fs",
+		ActiveCRIs: []string{"containerd"},
+		Disks: []Disk{
+			{Name: "vda", Size: 42949672960, Rotational: true},
+			{Name: "vdb", Size: 107374182400},
+		},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("Parse() = %+v, want %+v", h, want)
+	}
+	if !h.CgroupV2() || !h.CRIActive("containerd") || h.CRIActive("docker") {
+		t.Errorf("CgroupV2() = %v, CRIActive(containerd) = %v, CRIActive(docker) = %v, want true, true, false",
+			h.CgroupV2(), h.CRIActive("containerd"), h.CRIActive("docker"))
+	}
+
+	for _, out := range []string{"", "hostname=node1\n", "arch=x86_64\nkernel=5.10\ncpus=many\n", "arch=x86_64\nkernel=5.10\ndisk=vda\n"} {
+		if _, err := Parse(out); err == nil {
+			t.Errorf("Parse(%q) want error, got nil", out)
+		}
+	}
+}
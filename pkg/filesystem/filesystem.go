@@ -16,14 +16,16 @@ package filesystem
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/alibaba/sealer/pkg/env"
+	"github.com/alibaba/sealer/pkg/p2p"
+	"github.com/alibaba/sealer/pkg/registries"
 
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/pkg/runtime"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 
@@ -38,6 +40,8 @@ import (
 
 const (
 	RemoteChmod = "cd %s  && chmod +x scripts/* && cd scripts && bash init.sh"
+	// RemoteUnmountUnder unmounts the disks mounted in rootfs, like the storage of the registry, and removes them from fstab.
+	RemoteUnmountUnder = `(findmnt -rn -o TARGET | grep "^%[1]s/" | sort -r | xargs -r umount) && sed -i "\|^[^#]\S*\s\+%[1]s/|d" /etc/fstab`
 )
 
 type Interface interface {
@@ -53,6 +57,13 @@ type FileSystem struct {
 }
 
 func (c *FileSystem) Clean(cluster *v2.Cluster) error {
+	// only the context of cluster is removed from the merged kubeconfig, the others are kept.
+	if runtime.KubeconfigHasContext(common.DefaultKubeConfigFile(), cluster.Name) {
+		if err := runtime.RemoveKubeconfigContext(common.DefaultKubeConfigFile(), cluster.Name); err != nil {
+			return err
+		}
+		return utils.CleanFiles(common.GetClusterWorkDir(cluster.Name), common.DefaultClusterBaseDir(cluster.Name))
+	}
 	return utils.CleanFiles(common.GetClusterWorkDir(cluster.Name), common.DefaultClusterBaseDir(cluster.Name), common.DefaultKubeConfigDir())
 }
 
@@ -92,7 +103,7 @@ func (c *FileSystem) mountImage(cluster *v2.Cluster) error {
 		}
 	}
 	//get layers
-	Image, err := c.imageStore.GetByName(cluster.Spec.Image)
+	Image, err := runtime.GetClusterImage(c.imageStore, cluster)
 	if err != nil {
 		return err
 	}
@@ -151,6 +162,15 @@ func mountRootfs(ipList []string, target string, cluster *v2.Cluster, initFlag b
 		common.DefaultTheClusterRootfsDir(cluster.Name),
 		runtime.GetMaster0Ip(cluster))
 	src := common.DefaultMountCloudImageDir(cluster.Name)
+	if err := config.WriteDockerMirrors(src); err != nil {
+		return fmt.Errorf("failed to write mirrors of registry proxies: %v", err)
+	}
+	if err := p2p.WriteDockerMirror(cluster, src); err != nil {
+		return fmt.Errorf("failed to write mirror of p2p: %v", err)
+	}
+	if err := registries.WriteDockerMirrors(cluster, src); err != nil {
+		return fmt.Errorf("failed to write mirrors of registries: %v", err)
+	}
 	// TODO scp sdk has change file mod bug
 	initCmd := fmt.Sprintf(RemoteChmod, target)
 	envProcessor := env.NewEnvProcessor(cluster)
@@ -160,18 +180,18 @@ func mountRootfs(ipList []string, target string, cluster *v2.Cluster, initFlag b
 			defer wg.Done()
 			sshClient, err := ssh.GetHostSSHClient(ip, cluster)
 			if err != nil {
-				errCh <- fmt.Errorf("get host ssh client failed %v", err)
+				errCh <- result.OnHost(ip, fmt.Errorf("get host ssh client failed %v", err))
 				return
 			}
 			err = CopyFiles(sshClient, ip == config.IP, ip, src, target)
 			if err != nil {
-				errCh <- fmt.Errorf("copy rootfs failed %v", err)
+				errCh <- result.OnHost(ip, fmt.Errorf("copy rootfs failed %v", err))
 				return
 			}
 			if initFlag {
 				err = sshClient.CmdAsync(ip, envProcessor.WrapperShell(ip, initCmd))
 				if err != nil {
-					errCh <- fmt.Errorf("exec init.sh failed %v", err)
+					errCh <- result.OnHost(ip, fmt.Errorf("exec init.sh failed %v", err))
 				}
 			}
 		}(IP)
@@ -180,23 +200,13 @@ func mountRootfs(ipList []string, target string, cluster *v2.Cluster, initFlag b
 	return runtime.ReadChanError(errCh)
 }
 
-func CopyFiles(ssh ssh.Interface, isRegistry bool, ip, src, target string) error {
-	files, err := ioutil.ReadDir(src)
-	if err != nil {
-		return fmt.Errorf("failed to copy files %s", err)
+func CopyFiles(client ssh.Interface, isRegistry bool, ip, src, target string) error {
+	var opts ssh.CopyOptions
+	if !isRegistry {
+		opts.Excludes = []string{"./" + common.RegistryDirName}
 	}
-
-	if isRegistry {
-		return ssh.Copy(ip, src, target)
-	}
-	for _, f := range files {
-		if f.Name() == common.RegistryDirName {
-			continue
-		}
-		err = ssh.Copy(ip, filepath.Join(src, f.Name()), filepath.Join(target, f.Name()))
-		if err != nil {
-			return fmt.Errorf("failed to copy sub files %v", err)
-		}
+	if err := client.CopyDir(ip, src, target, opts); err != nil {
+		return fmt.Errorf("failed to copy files %v", err)
 	}
 	return nil
 }
@@ -222,8 +232,10 @@ func unmountRootfs(ipList []string, cluster *v2.Cluster) error {
 				mutex.Unlock()
 				return
 			}
-			cmd := fmt.Sprintf("%s && %s && %s", execClean, rmRootfs, rmDockerCert)
-			if mounted, _ := mount.GetRemoteMountDetails(SSH, ip, clusterRootfsDir); mounted {
+			cmd := fmt.Sprintf("%s && %s && %s && %s", execClean, fmt.Sprintf(RemoteUnmountUnder, clusterRootfsDir), rmRootfs, rmDockerCert)
+			// the registry dir is unmounted with the other mounts under rootfs, rootfs itself is mounted by the
+			// registry of the sealer before.
+			if fsType, _ := mount.GetRemoteMountFSType(SSH, ip, clusterRootfsDir); fsType != "" {
 				cmd = fmt.Sprintf("umount %s && %s", clusterRootfsDir, cmd)
 			}
 			if err := SSH.CmdAsync(ip, envProcessor.WrapperShell(ip, cmd)); err != nil {
@@ -0,0 +1,230 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firewall
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	Firewalld = "firewalld"
+	UFW       = "ufw"
+	IPTables  = "iptables"
+
+	DefaultNodePortRange = "30000-32767"
+	nodePortRangeArg     = "service-node-port-range"
+	// ruleComment marks the rules of ufw and iptables sealer adds.
+	ruleComment = "sealer"
+
+	// RemoteDetectBackend prints the running firewall of firewalld and ufw, nothing if neither is running.
+	RemoteDetectBackend = `if systemctl is-active -q firewalld 2>/dev/null; then echo ` + Firewalld + `; ` +
+		`elif command -v ufw >/dev/null 2>&1 && ufw status | grep -q "Status: active"; then echo ` + UFW + `; fi`
+)
+
+var (
+	// MasterPorts are the ports of apiserver, etcd, kubelet, controller-manager and scheduler.
+	MasterPorts = []string{"6443/tcp", "2379-2380/tcp", "10250/tcp", "10257/tcp", "10259/tcp"}
+	NodePorts   = []string{"10250/tcp"}
+	// CNIPorts are the overlay, BGP and health check ports of the CNIs.
+	CNIPorts = map[string][]string{
+		"calico":  {"179/tcp", "4789/udp", "5473/tcp"},
+		"flannel": {"8472/udp"},
+		"cilium":  {"8472/udp", "4240/tcp", "4244/tcp"},
+		"weave":   {"6783/tcp", "6783-6784/udp"},
+	}
+	Backends = []string{Firewalld, UFW, IPTables}
+)
+
+// Validate checks spec.firewall in Clusterfile.
+func Validate(spec v2.FirewallSpec) error {
+	if spec.Backend != "" && utils.NotIn(spec.Backend, Backends) {
+		return fmt.Errorf("invalid firewall backend %s, must be one of %v", spec.Backend, Backends)
+	}
+	if _, ok := CNIPorts[spec.CNI]; spec.CNI != "" && !ok {
+		return fmt.Errorf("unknown CNI %s of firewall, open its ports by spec.firewall.ports instead", spec.CNI)
+	}
+	for _, p := range spec.Ports {
+		if _, _, err := parsePort(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePort splits a port like 30000-32767/tcp into the range and the protocol.
+func parsePort(port string) (string, string, error) {
+	parts := strings.Split(port, "/")
+	if len(parts) != 2 || parts[0] == "" || (parts[1] != "tcp" && parts[1] != "udp") {
+		return "", "", fmt.Errorf("invalid port %s, must be like 8080/tcp or 30000-32767/udp", port)
+	}
+	return parts[0], parts[1], nil
+}
+
+// Ports returns the ports to open on host by its role, the NodePort range and the CNI in Clusterfile.
+func Ports(cluster *v2.Cluster, host string) []string {
+	ports := KubernetesPorts(cluster, host)
+	ports = append(ports, CNIPorts[cluster.Spec.Firewall.CNI]...)
+	return utils.RemoveDuplicate(append(ports, cluster.Spec.Firewall.Ports...))
+}
+
+// KubernetesPorts returns the ports kubernetes needs on host by its role, the NodePort range and the registry.
+func KubernetesPorts(cluster *v2.Cluster, host string) []string {
+	var ports []string
+	if utils.InList(host, cluster.GetMasterIPList()) {
+		ports = append(ports, MasterPorts...)
+	} else {
+		ports = append(ports, NodePorts...)
+	}
+	// kube-proxy serves the NodePort services on all hosts.
+	nodePorts := cluster.Spec.Kubernetes.APIServer.ExtraArgs[nodePortRangeArg]
+	if nodePorts == "" {
+		nodePorts = DefaultNodePortRange
+	}
+	ports = append(ports, nodePorts+"/tcp", nodePorts+"/udp")
+	reg := runtime.GetRegistryConfig(common.DefaultMountCloudImageDir(cluster.Name), cluster.GetMaster0Ip())
+	if ip, _ := utils.GetSSHHostIPAndPort(reg.IP); ip == host {
+		ports = append(ports, reg.Port+"/tcp")
+	}
+	return ports
+}
+
+// rule is how a backend checks, opens and closes a port.
+type rule struct {
+	check, open, close string
+}
+
+func ruleOf(backend, port string) (rule, error) {
+	ports, proto, err := parsePort(port)
+	if err != nil {
+		return rule{}, err
+	}
+	switch backend {
+	case Firewalld:
+		return rule{
+			check: fmt.Sprintf("firewall-cmd -q --query-port=%s", port),
+			open:  fmt.Sprintf("firewall-cmd -q --permanent --add-port=%[1]s && firewall-cmd -q --add-port=%[1]s", port),
+			close: fmt.Sprintf("firewall-cmd -q --permanent --remove-port=%[1]s && firewall-cmd -q --remove-port=%[1]s", port),
+		}, nil
+	case UFW:
+		p := strings.Replace(ports, "-", ":", 1) + "/" + proto
+		return rule{
+			check: fmt.Sprintf(`ufw show added | grep -q "ufw allow %s comment '%s'"`, p, ruleComment),
+			open:  fmt.Sprintf("ufw allow %s comment %s", p, ruleComment),
+			close: fmt.Sprintf("ufw delete allow %s", p),
+		}, nil
+	case IPTables:
+		spec := fmt.Sprintf("INPUT -p %s --dport %s -m comment --comment %s -j ACCEPT", proto, strings.Replace(ports, "-", ":", 1), ruleComment)
+		return rule{
+			check: "iptables -C " + spec,
+			open:  "iptables -I " + spec,
+			close: "iptables -D " + spec,
+		}, nil
+	}
+	return rule{}, fmt.Errorf("invalid firewall backend %s", backend)
+}
+
+// backendOf returns the firewall of host, the one in Clusterfile or the running one, empty if none is running.
+func backendOf(client ssh.Interface, host string, spec v2.FirewallSpec) (string, error) {
+	if spec.Backend != "" {
+		return spec.Backend, nil
+	}
+	out, err := client.Cmd(host, RemoteDetectBackend)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the firewall of %s: %v", host, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Open opens the ports of hosts in their firewall in parallel, if spec.firewall is enabled in Clusterfile.
+// The ports already open are skipped, and the hosts running no firewall are skipped unless the backend is set.
+func Open(cluster *v2.Cluster, hosts []string) error {
+	return forEachHost(cluster, hosts, true)
+}
+
+// Close closes the ports of hosts opened by Open in parallel, unless spec.firewall.keepOnDelete is set.
+func Close(cluster *v2.Cluster, hosts []string) error {
+	if cluster.Spec.Firewall.KeepOnDelete {
+		return nil
+	}
+	return forEachHost(cluster, hosts, false)
+}
+
+func forEachHost(cluster *v2.Cluster, hosts []string, open bool) error {
+	spec := cluster.Spec.Firewall
+	if !spec.Enabled || len(hosts) == 0 {
+		return nil
+	}
+	if err := Validate(spec); err != nil {
+		return err
+	}
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			if err := applyHost(cluster, ip, open); err != nil {
+				errCh <- result.OnHost(ip, err)
+			}
+		}(h)
+	}
+	wg.Wait()
+	return runtime.ReadChanError(errCh)
+}
+
+func applyHost(cluster *v2.Cluster, host string, open bool) error {
+	client, err := ssh.GetHostSSHClient(host, cluster)
+	if err != nil {
+		return fmt.Errorf("get host ssh client failed %v", err)
+	}
+	backend, err := backendOf(client, host, cluster.Spec.Firewall)
+	if err != nil || backend == "" {
+		return err
+	}
+	cmdOf, verb := func(r rule) string { return r.close }, "close"
+	if open {
+		cmdOf, verb = func(r rule) string { return r.open }, "open"
+	}
+	var changed []string
+	for _, p := range Ports(cluster, host) {
+		r, err := ruleOf(backend, p)
+		if err != nil {
+			return err
+		}
+		// only open the closed ports, and close the open ones.
+		if _, err := client.Cmd(host, r.check); (err == nil) == open {
+			continue
+		}
+		if err := client.CmdAsync(host, cmdOf(r)); err != nil {
+			return fmt.Errorf("failed to %s port %s in %s of %s: %v", verb, p, backend, host, err)
+		}
+		changed = append(changed, p)
+	}
+	if len(changed) > 0 {
+		logger.Info("%s ports %s in %s of %s", verb, strings.Join(changed, ", "), backend, host)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package firewall
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestPorts(t *testing.T) {
+	cluster := &v2.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-such-cluster"},
+		Spec: v2.ClusterSpec{
+			Hosts: []v2.Host{
+				{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+				{IPS: []string{"192.168.0.3"}, Roles: []string{"node"}},
+			},
+			Kubernetes: v2.KubernetesSpec{APIServer: v2.ComponentSpec{ExtraArgs: map[string]string{"service-node-port-range": "20000-22767"}}},
+			Firewall:   v2.FirewallSpec{Enabled: true, CNI: "calico", Ports: []string{"9100/tcp", "10250/tcp"}},
+		},
+	}
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"192.168.0.2", []string{"6443/tcp", "2379-2380/tcp", "10250/tcp", "10257/tcp", "10259/tcp", "20000-22767/tcp", "20000-22767/udp",
+			"5000/tcp", "179/tcp", "4789/udp", "5473/tcp", "9100/tcp"}},
+		{"192.168.0.3", []string{"10250/tcp", "20000-22767/tcp", "20000-22767/udp", "179/tcp", "4789/udp", "5473/tcp", "9100/tcp"}},
+	}
+	for _, tt := range tests {
+		if got := Ports(cluster, tt.host); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Ports(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestRuleOf(t *testing.T) {
+	tests := []struct {
+		backend string
+		port    string
+		want    rule
+	}{
+		{Firewalld, "30000-32767/tcp", rule{
+			check: "firewall-cmd -q --query-port=30000-32767/tcp",
+			open:  "firewall-cmd -q --permanent --add-port=30000-32767/tcp && firewall-cmd -q --add-port=30000-32767/tcp",
+			close: "firewall-cmd -q --permanent --remove-port=30000-32767/tcp && firewall-cmd -q --remove-port=30000-32767/tcp",
+		}},
+		{UFW, "30000-32767/udp", rule{
+			check: `ufw show added | grep -q "ufw allow 30000:32767/udp comment 'sealer'"`,
+			open:  "ufw allow 30000:32767/udp comment sealer",
+			close: "ufw delete allow 30000:32767/udp",
+		}},
+		{IPTables, "6443/tcp", rule{
+			check: "iptables -C INPUT -p tcp --dport 6443 -m comment --comment sealer -j ACCEPT",
+			open:  "iptables -I INPUT -p tcp --dport 6443 -m comment --comment sealer -j ACCEPT",
+			close: "iptables -D INPUT -p tcp --dport 6443 -m comment --comment sealer -j ACCEPT",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := ruleOf(tt.backend, tt.port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("ruleOf(%s, %s) = %+v, want %+v", tt.backend, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    v2.FirewallSpec
+		wantErr bool
+	}{
+		{"default", v2.FirewallSpec{Enabled: true}, false},
+		{"full", v2.FirewallSpec{Enabled: true, Backend: UFW, CNI: "flannel", Ports: []string{"8080/tcp"}}, false},
+		{"invalid backend", v2.FirewallSpec{Backend: "nftables"}, true},
+		{"unknown cni", v2.FirewallSpec{CNI: "kube-router"}, true},
+		{"port without protocol", v2.FirewallSpec{Ports: []string{"8080"}}, true},
+		{"invalid protocol", v2.FirewallSpec{Ports: []string{"8080/sctp"}}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.spec); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kube-proxy/config/v1alpha1"
+	"k8s.io/kubelet/config/v1beta1"
+
+	"github.com/alibaba/sealer/client/k8s"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	kubeadmConfigMap    = "kubeadm-config"
+	kubeProxyConfigMap  = "kube-proxy"
+	kubeletConfigMap    = "kubelet-config"
+	criSocketAnnotation = "kubeadm.alpha.kubernetes.io/cri-socket"
+	masterRoleLabel     = "node-role.kubernetes.io/master"
+	controlPlaneLabel   = "node-role.kubernetes.io/control-plane"
+
+	remoteCatKubeletSysconfig = "cat " + runtime.KubeletSysconfigFile + " 2>/dev/null || true"
+)
+
+// managedLabelPrefixes are the prefixes of the labels set by kubelet and kubeadm, they are not written to hosts.
+var managedLabelPrefixes = []string{
+	"kubernetes.io/",
+	"beta.kubernetes.io/",
+	"node.kubernetes.io/",
+	"topology.kubernetes.io/",
+	"failure-domain.beta.kubernetes.io/",
+	masterRoleLabel,
+	controlPlaneLabel,
+}
+
+type Options struct {
+	ClusterName string
+	// Image is the CloudImage of the cluster, it should be of the kubernetes version of the cluster.
+	Image string
+	SSH   v1.SSH
+}
+
+// Result is the Clusterfile generated, the Cluster and the raw kubeadm configs.
+type Result struct {
+	Cluster *v2.Cluster
+	Configs []interface{}
+}
+
+// Generate inspects the running kubeadm cluster which $HOME/.kube/config points to, and returns its Clusterfile.
+// The hosts, their labels and taints are read from the nodes, the kubeadm, kubelet and kube-proxy configs from
+// the ConfigMaps in kube-system, all hosts are checked to be reachable with opts.SSH.
+func Generate(opts Options) (*Result, error) {
+	client, err := k8s.Newk8sClient()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := client.ListNodes()
+	if err != nil {
+		return nil, err
+	}
+	hosts, criSocket, err := hostsOf(nodes.Items)
+	if err != nil {
+		return nil, err
+	}
+	cluster := &v2.Cluster{}
+	cluster.APIVersion = "sealer.cloud/v2"
+	cluster.Kind = common.Cluster
+	cluster.Name = opts.ClusterName
+	cluster.Spec.Image = opts.Image
+	cluster.Spec.SSH = opts.SSH
+	cluster.Spec.Hosts = hosts
+
+	configs, err := kubeadmConfigsOf(client, criSocket)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHosts(cluster); err != nil {
+		return nil, err
+	}
+	args, err := kubeletExtraArgsOf(cluster)
+	if err != nil {
+		return nil, err
+	}
+	cluster.Spec.Kubernetes.Kubelet.ExtraArgs = args
+	return &Result{Cluster: cluster, Configs: configs}, nil
+}
+
+// Marshal returns the Clusterfile of r.
+func (r *Result) Marshal() ([]byte, error) {
+	return utils.MarshalConfigsYaml(append([]interface{}{r.Cluster}, r.Configs...)...)
+}
+
+// hostsOf groups the nodes by role, labels and taints, the masters come first. The CRI socket of the nodes is
+// returned too, they are assumed to use the same container runtime.
+func hostsOf(nodes []corev1.Node) ([]v2.Host, string, error) {
+	groups := map[string]*v2.Host{}
+	var keys []string
+	var criSocket string
+	for _, node := range nodes {
+		ip := internalIP(node)
+		if ip == "" {
+			return nil, "", fmt.Errorf("failed to find the internal ip of node %s", node.Name)
+		}
+		if criSocket == "" {
+			criSocket = node.Annotations[criSocketAnnotation]
+		}
+		host := v2.Host{Roles: []string{common.NODE}}
+		if isMaster(node) {
+			host.Roles = []string{common.MASTER}
+		}
+		for k, v := range node.Labels {
+			if !isManaged(k) {
+				if host.Labels == nil {
+					host.Labels = map[string]string{}
+				}
+				host.Labels[k] = v
+			}
+		}
+		for _, t := range node.Spec.Taints {
+			if !isManaged(t.Key) {
+				host.Taints = append(host.Taints, formatTaint(t))
+			}
+		}
+		sort.Strings(host.Taints)
+		key := fmt.Sprintf("%s/%v/%v", host.Roles[0], host.Labels, host.Taints)
+		if g, ok := groups[key]; ok {
+			g.IPS = append(g.IPS, ip)
+			continue
+		}
+		host.IPS = []string{ip}
+		groups[key] = &host
+		keys = append(keys, key)
+	}
+	if len(groups) == 0 {
+		return nil, "", fmt.Errorf("no nodes found in the cluster")
+	}
+	// masters first, "master/..." sorts before "node/...".
+	sort.Strings(keys)
+	var hosts []v2.Host
+	for _, key := range keys {
+		sort.Strings(groups[key].IPS)
+		hosts = append(hosts, *groups[key])
+	}
+	if !utils.InList(common.MASTER, hosts[0].Roles) {
+		return nil, "", fmt.Errorf("no masters found in the cluster, they must have the label %s or %s", masterRoleLabel, controlPlaneLabel)
+	}
+	return hosts, criSocket, nil
+}
+
+func internalIP(node corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+func isMaster(node corev1.Node) bool {
+	_, master := node.Labels[masterRoleLabel]
+	_, controlPlane := node.Labels[controlPlaneLabel]
+	return master || controlPlane
+}
+
+func isManaged(key string) bool {
+	for _, prefix := range managedLabelPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatTaint formats the taint as key[=value]:effect, the form of host taints in Clusterfile.
+func formatTaint(t corev1.Taint) string {
+	if t.Value == "" {
+		return fmt.Sprintf("%s:%s", t.Key, t.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect)
+}
+
+// kubeadmConfigsOf returns the raw ClusterConfiguration, KubeletConfiguration and KubeProxyConfiguration uploaded
+// by kubeadm, and the Init and JoinConfiguration with criSocket.
+func kubeadmConfigsOf(client *k8s.Client, criSocket string) ([]interface{}, error) {
+	cm, err := client.GetConfigMap(metav1.NamespaceSystem, kubeadmConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	if cm == nil || cm.Data[runtime.ClusterConfiguration] == "" {
+		return nil, fmt.Errorf("failed to find %s in configmap kube-system/%s, is the cluster created by kubeadm?", runtime.ClusterConfiguration, kubeadmConfigMap)
+	}
+	c, err := runtime.TypeConversion([]byte(cm.Data[runtime.ClusterConfiguration]), runtime.ClusterConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", runtime.ClusterConfiguration, err)
+	}
+	clusterConfig := c.(*v1beta2.ClusterConfiguration)
+	// the newer kubeadm config versions are decoded as v1beta2, sealer sets the version of the image on apply.
+	clusterConfig.APIVersion = runtime.KubeadmV1beta2
+	clusterConfig.Kind = runtime.ClusterConfiguration
+
+	initConfig := &v1beta2.InitConfiguration{}
+	initConfig.APIVersion, initConfig.Kind = runtime.KubeadmV1beta2, runtime.InitConfiguration
+	initConfig.NodeRegistration.CRISocket = criSocket
+	joinConfig := &v1beta2.JoinConfiguration{}
+	joinConfig.APIVersion, joinConfig.Kind = runtime.KubeadmV1beta2, runtime.JoinConfiguration
+	joinConfig.NodeRegistration.CRISocket = criSocket
+	configs := []interface{}{initConfig, clusterConfig, joinConfig}
+
+	// kubeadm names the kubelet config kubelet-config-[major.minor] before v1.24.
+	var kubeletConfig *v1beta1.KubeletConfiguration
+	for _, name := range []string{kubeletConfigMap + "-" + minorVersion(clusterConfig.KubernetesVersion), kubeletConfigMap} {
+		cm, err := client.GetConfigMap(metav1.NamespaceSystem, name)
+		if err != nil {
+			return nil, err
+		}
+		if cm == nil || cm.Data["kubelet"] == "" {
+			continue
+		}
+		c, err := runtime.TypeConversion([]byte(cm.Data["kubelet"]), runtime.KubeletConfiguration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", runtime.KubeletConfiguration, err)
+		}
+		kubeletConfig = c.(*v1beta1.KubeletConfiguration)
+		break
+	}
+	if kubeletConfig != nil {
+		configs = append(configs, kubeletConfig)
+	} else {
+		logger.Warn("failed to find the kubelet config in kube-system, the default of the CloudImage is used")
+	}
+
+	cm, err = client.GetConfigMap(metav1.NamespaceSystem, kubeProxyConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	if cm != nil && cm.Data["config.conf"] != "" {
+		c, err := runtime.TypeConversion([]byte(cm.Data["config.conf"]), runtime.KubeProxyConfiguration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", runtime.KubeProxyConfiguration, err)
+		}
+		configs = append(configs, c.(*v1alpha1.KubeProxyConfiguration))
+	} else {
+		logger.Warn("failed to find the kube-proxy config in kube-system, the default of the CloudImage is used")
+	}
+	return configs, nil
+}
+
+// minorVersion returns 1.19 of v1.19.8.
+func minorVersion(version string) string {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// checkHosts connects all hosts of cluster, sealer manages them by ssh.
+func checkHosts(cluster *v2.Cluster) error {
+	hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	var (
+		lock   sync.Mutex
+		failed []string
+		wg     sync.WaitGroup
+	)
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			client, err := ssh.GetHostSSHClient(host, cluster)
+			if err == nil {
+				err = client.Ping(host)
+			}
+			if err != nil {
+				logger.Error("failed to connect %s: %v", host, err)
+				lock.Lock()
+				failed = append(failed, host)
+				lock.Unlock()
+			}
+		}(host)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to connect hosts %s by ssh, check the ssh flags", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// kubeletExtraArgsOf reads the kubelet extra args on master0, which sealer writes from spec.kubernetes.kubelet.extraArgs.
+func kubeletExtraArgsOf(cluster *v2.Cluster) (map[string]string, error) {
+	master0 := cluster.GetMaster0Ip()
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Cmd(master0, remoteCatKubeletSysconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s on %s: %v", runtime.KubeletSysconfigFile, master0, err)
+	}
+	return parseKubeletExtraArgs(string(out)), nil
+}
+
+// parseKubeletExtraArgs parses KUBELET_EXTRA_ARGS=--a=b --c=d of the kubelet sysconfig file.
+func parseKubeletExtraArgs(sysconfig string) map[string]string {
+	var args map[string]string
+	for _, line := range strings.Split(sysconfig, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "KUBELET_EXTRA_ARGS=") {
+			continue
+		}
+		value := strings.Trim(strings.TrimPrefix(line, "KUBELET_EXTRA_ARGS="), `"'`)
+		for _, arg := range strings.Fields(value) {
+			kv := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)
+			if kv[0] == "" {
+				continue
+			}
+			if args == nil {
+				args = map[string]string{}
+			}
+			if len(kv) == 2 {
+				args[kv[0]] = kv[1]
+			} else {
+				args[kv[0]] = "true"
+			}
+		}
+	}
+	return args
+}
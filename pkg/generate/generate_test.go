@@ -0,0 +1,192 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func node(name, ip string, labels map[string]string, taints ...corev1.Taint) corev1.Node {
+	n := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	n.Spec.Taints = taints
+	if ip != "" {
+		n.Status.Addresses = []corev1.NodeAddress{
+			{Type: corev1.NodeHostName, Address: name},
+			{Type: corev1.NodeInternalIP, Address: ip},
+		}
+	}
+	return n
+}
+
+func TestHostsOf(t *testing.T) {
+	noSchedule := corev1.Taint{Key: masterRoleLabel, Effect: corev1.TaintEffectNoSchedule}
+	gpu := corev1.Taint{Key: "nvidia.com/gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	master := func(name, ip string) corev1.Node {
+		n := node(name, ip, map[string]string{masterRoleLabel: "", "kubernetes.io/hostname": name}, noSchedule)
+		n.Annotations = map[string]string{criSocketAnnotation: "/run/containerd/containerd.sock"}
+		return n
+	}
+
+	tests := []struct {
+		name          string
+		nodes         []corev1.Node
+		want          []v2.Host
+		wantCRISocket string
+		wantErr       string
+	}{
+		{
+			name: "grouped by role, labels and taints",
+			nodes: []corev1.Node{
+				node("node-2", "192.168.0.5", map[string]string{"kubernetes.io/os": "linux", "gpu": "a100"}, gpu),
+				master("master-1", "192.168.0.3"),
+				node("node-1", "192.168.0.4", map[string]string{"kubernetes.io/os": "linux"}),
+				master("master-0", "192.168.0.2"),
+				node("node-0", "192.168.0.6", map[string]string{"kubernetes.io/os": "linux", "gpu": "a100"}, gpu),
+				node("control-plane", "192.168.0.7", map[string]string{controlPlaneLabel: ""}),
+			},
+			want: []v2.Host{
+				// the labels and taints of the master role are left out, the masters of both labels are grouped.
+				{IPS: []string{"192.168.0.2", "192.168.0.3", "192.168.0.7"}, Roles: []string{"master"}},
+				{IPS: []string{"192.168.0.4"}, Roles: []string{"node"}},
+				{IPS: []string{"192.168.0.5", "192.168.0.6"}, Roles: []string{"node"},
+					Labels: map[string]string{"gpu": "a100"}, Taints: []string{"nvidia.com/gpu=true:NoSchedule"}},
+			},
+			wantCRISocket: "/run/containerd/containerd.sock",
+		},
+		{
+			name:    "no internal ip",
+			nodes:   []corev1.Node{node("master-0", "", map[string]string{masterRoleLabel: ""})},
+			wantErr: "failed to find the internal ip of node master-0",
+		},
+		{
+			name:    "no masters",
+			nodes:   []corev1.Node{node("node-0", "192.168.0.4", nil)},
+			wantErr: "no masters found",
+		},
+		{
+			name:    "no nodes",
+			wantErr: "no nodes found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, criSocket, err := hostsOf(tt.nodes)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("hostsOf() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("hostsOf() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hostsOf() = %+v, want %+v", got, tt.want)
+			}
+			if criSocket != tt.wantCRISocket {
+				t.Errorf("hostsOf() criSocket = %s, want %s", criSocket, tt.wantCRISocket)
+			}
+		})
+	}
+}
+
+func TestFormatTaint(t *testing.T) {
+	tests := []struct {
+		taint corev1.Taint
+		want  string
+	}{
+		{corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}, "dedicated=gpu:NoSchedule"},
+		{corev1.Taint{Key: "example.com/maintenance", Effect: corev1.TaintEffectNoExecute}, "example.com/maintenance:NoExecute"},
+		{corev1.Taint{Key: "spot", Value: "true", Effect: corev1.TaintEffectPreferNoSchedule}, "spot=true:PreferNoSchedule"},
+	}
+	for _, tt := range tests {
+		if got := formatTaint(tt.taint); got != tt.want {
+			t.Errorf("formatTaint(%+v) = %s, want %s", tt.taint, got, tt.want)
+		}
+	}
+}
+
+func TestIsManaged(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"kubernetes.io/hostname", true},
+		{"beta.kubernetes.io/arch", true},
+		{"node.kubernetes.io/instance-type", true},
+		{"topology.kubernetes.io/zone", true},
+		{"failure-domain.beta.kubernetes.io/region", true},
+		{masterRoleLabel, true},
+		{controlPlaneLabel, true},
+		{"node-role.kubernetes.io/edge", false},
+		{"example.com/kubernetes.io", false},
+		{"gpu", false},
+	}
+	for _, tt := range tests {
+		if got := isManaged(tt.key); got != tt.want {
+			t.Errorf("isManaged(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMinorVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"v1.19.8":          "1.19",
+		"1.22.3":           "1.22",
+		"v1.24.0-rc.1":     "1.24",
+		"v1.20":            "1.20",
+		"v1":               "v1",
+		"":                 "",
+		"v1.21.2+k3s1.foo": "1.21",
+	} {
+		if got := minorVersion(version); got != want {
+			t.Errorf("minorVersion(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestParseKubeletExtraArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		sysconfig string
+		want      map[string]string
+	}{
+		{"args", "KUBELET_EXTRA_ARGS=--max-pods=200 --node-ip=192.168.0.2\n",
+			map[string]string{"max-pods": "200", "node-ip": "192.168.0.2"}},
+		{"double quoted", `KUBELET_EXTRA_ARGS="--root-dir=/data/kubelet --v=4"`,
+			map[string]string{"root-dir": "/data/kubelet", "v": "4"}},
+		{"single quoted", `KUBELET_EXTRA_ARGS='--rotate-certificates'`, map[string]string{"rotate-certificates": "true"}},
+		{"value with =", "KUBELET_EXTRA_ARGS=--node-labels=env=prod,zone=a", map[string]string{"node-labels": "env=prod,zone=a"}},
+		{"other lines", "# written by sealer\nHTTP_PROXY=http://proxy:3128\n  KUBELET_EXTRA_ARGS=--v=2  \n",
+			map[string]string{"v": "2"}},
+		{"empty args", "KUBELET_EXTRA_ARGS=", nil},
+		{"bare dashes", "KUBELET_EXTRA_ARGS=-- --v=2", map[string]string{"v": "2"}},
+		{"no args", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseKubeletExtraArgs(tt.sysconfig); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseKubeletExtraArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
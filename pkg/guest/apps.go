@@ -0,0 +1,95 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guest
+
+import (
+	"fmt"
+	"path/filepath"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// AppsFile in rootfs of CloudImage lists its app bundles, which can be installed selectively instead of its CMD.
+const AppsFile = "etc/apps.yaml"
+
+// AppBundle is a named set of commands installing an app of CloudImage, like the monitoring bundle running
+// helm install prometheus charts/prometheus.
+type AppBundle struct {
+	Name string   `json:"name"`
+	Cmd  []string `json:"cmd"`
+	// Paths are the manifests and charts the commands apply, relative to rootfs, recorded for sealer app rollback
+	Paths []string `json:"paths,omitempty"`
+}
+
+type appBundles struct {
+	Apps []AppBundle `json:"apps"`
+}
+
+// LoadAppBundles returns the app bundles in AppsFile of rootfs, nil if it does not exist.
+func LoadAppBundles(rootfs string) ([]AppBundle, error) {
+	path := filepath.Join(rootfs, AppsFile)
+	if !utils.IsFileExist(path) {
+		return nil, nil
+	}
+	var bundles appBundles
+	if err := utils.UnmarshalYamlFile(path, &bundles); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", AppsFile, err)
+	}
+	return bundles.Apps, nil
+}
+
+// SelectAppBundles returns the app bundles named in spec.guest.apps of cluster, in the order of AppsFile.
+func SelectAppBundles(cluster *v2.Cluster, rootfs string) ([]AppBundle, error) {
+	if len(cluster.Spec.Guest.Apps) == 0 {
+		return nil, nil
+	}
+	bundles, err := LoadAppBundles(rootfs)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		selected []AppBundle
+		names    []string
+	)
+	for _, b := range bundles {
+		names = append(names, b.Name)
+		if utils.InList(b.Name, cluster.Spec.Guest.Apps) {
+			selected = append(selected, b)
+		}
+	}
+	for _, name := range cluster.Spec.Guest.Apps {
+		if utils.NotIn(name, names) {
+			return nil, fmt.Errorf("app %s not found in %s of %s, the apps are %v", name, AppsFile, cluster.Spec.Image, names)
+		}
+	}
+	return selected, nil
+}
+
+// getGuestCmds returns the commands of the selected app bundles and spec.guest.cmd, or the CMD of image if neither is set.
+func getGuestCmds(cluster *v2.Cluster, rootfs string, imageCmds []string) ([]string, error) {
+	if len(cluster.Spec.Guest.Apps) == 0 && len(cluster.Spec.Guest.Cmd) == 0 {
+		return imageCmds, nil
+	}
+	bundles, err := SelectAppBundles(cluster, rootfs)
+	if err != nil {
+		return nil, err
+	}
+	var cmds []string
+	for _, b := range bundles {
+		cmds = append(cmds, b.Cmd...)
+	}
+	return append(cmds, cluster.Spec.Guest.Cmd...), nil
+}
@@ -16,9 +16,15 @@ package guest
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/alibaba/sealer/pkg/env"
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/pkg/runtime"
 	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image/store"
@@ -43,27 +49,120 @@ func NewGuestManager() (Interface, error) {
 	return &Default{imageStore: is}, nil
 }
 
+// Apply runs the CMD of CloudImage, or the commands of spec.guest, in order on the hosts of spec.guest.roles,
+// each command runs on them in parallel.
 func (d *Default) Apply(cluster *v2.Cluster) error {
-	image, err := d.imageStore.GetByName(cluster.Spec.Image)
+	image, err := runtime.GetClusterImage(d.imageStore, cluster)
 	if err != nil {
 		return fmt.Errorf("get cluster image failed, %s", err)
 	}
-	sshClient, err := ssh.GetHostSSHClient(runtime.GetMaster0Ip(cluster), cluster)
+	hosts, err := getGuestHosts(cluster)
 	if err != nil {
 		return err
 	}
-	clusterRootfs := common.DefaultTheClusterRootfsDir(cluster.Name)
+	facts, err := runtime.GetClusterFacts(cluster, cluster.GetAnnotationsByKey(common.ClusterfileName))
+	if err != nil {
+		return err
+	}
+	var imageCmds []string
 	for i := range image.Spec.Layers {
-		if image.Spec.Layers[i].Type != common.CMDCOMMAND {
-			continue
+		if image.Spec.Layers[i].Type == common.CMDCOMMAND {
+			imageCmds = append(imageCmds, image.Spec.Layers[i].Value)
+		}
+	}
+	cmds, err := getGuestCmds(cluster, common.DefaultMountCloudImageDir(cluster.Name), imageCmds)
+	if err != nil {
+		return err
+	}
+	clusterRootfs := common.DefaultTheClusterRootfsDir(cluster.Name)
+	for _, cmd := range cmds {
+		errCh := make(chan error, len(hosts))
+		var wg sync.WaitGroup
+		for _, host := range hosts {
+			wg.Add(1)
+			go func(host string) {
+				defer wg.Done()
+				sshClient, err := ssh.GetHostSSHClient(host, cluster)
+				if err == nil {
+					err = sshClient.CmdAsync(host, guestCommand(clusterRootfs, cmd, getGuestEnv(cluster, facts, host)))
+				}
+				if err != nil {
+					errCh <- result.OnHost(host, fmt.Errorf("failed to run CMD %s on %s: %v", cmd, host, err))
+				}
+			}(host)
 		}
-		if err := sshClient.CmdAsync(runtime.GetMaster0Ip(cluster), fmt.Sprintf(common.CdAndExecCmd, clusterRootfs, image.Spec.Layers[i].Value)); err != nil {
+		wg.Wait()
+		close(errCh)
+		if err := runtime.ReadChanError(errCh); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// getGuestHosts returns the hosts of spec.guest.roles, or master0 if no roles are set.
+func getGuestHosts(cluster *v2.Cluster) ([]string, error) {
+	if len(cluster.Spec.Guest.Roles) == 0 {
+		return []string{runtime.GetMaster0Ip(cluster)}, nil
+	}
+	var hosts []string
+	for _, role := range cluster.Spec.Guest.Roles {
+		for _, host := range cluster.GetIPSByRole(role) {
+			if utils.NotIn(host, hosts) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts of roles %v to run CMD on", cluster.Spec.Guest.Roles)
+	}
+	return hosts, nil
+}
+
+// getGuestEnv merges the facts of cluster, the env of host, its IP and roles, the env of host overwrites the facts.
+func getGuestEnv(cluster *v2.Cluster, facts map[string]string, host string) map[string]string {
+	guestEnv := make(map[string]string, len(facts)+2)
+	for k, v := range facts {
+		guestEnv[k] = v
+	}
+	for k, v := range env.GetHostEnv(cluster, host) {
+		switch value := v.(type) {
+		case []string:
+			guestEnv[k] = strings.Join(value, " ")
+		case string:
+			guestEnv[k] = value
+		}
+	}
+	var roles []string
+	for _, h := range cluster.Spec.Hosts {
+		if utils.InList(host, h.IPS) {
+			roles = append(roles, h.Roles...)
+		}
+	}
+	guestEnv["HOST_IP"] = utils.GetHostIP(host)
+	guestEnv["HOST_ROLES"] = strings.Join(roles, " ")
+	return guestEnv
+}
+
+// guestCommand exports the env sorted by name and runs cmd in rootfs, like:
+// export MASTER0_IP='192.168.0.2' && cd /var/lib/sealer/data/my-cluster/rootfs && kubectl apply -f manifests
+func guestCommand(rootfs, cmd string, guestEnv map[string]string) string {
+	var names []string
+	for k := range guestEnv {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var exports []string
+	for _, k := range names {
+		exports = append(exports, fmt.Sprintf("export %s=%s", k, utils.ShellQuote(guestEnv[k])))
+	}
+	cmd = fmt.Sprintf(common.CdAndExecCmd, rootfs, cmd)
+	if len(exports) == 0 {
+		return cmd
+	}
+	return strings.Join(exports, " && ") + " && " + cmd
+}
+
 func (d Default) Delete(cluster *v2.Cluster) error {
 	panic("implement me")
 }
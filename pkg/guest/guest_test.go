@@ -0,0 +1,121 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func newCluster(roles ...string) *v2.Cluster {
+	cluster := &v2.Cluster{}
+	cluster.Spec.Env = []string{"REGION=cn"}
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2", "192.168.0.3"}, Roles: []string{"master"}},
+		{IPS: []string{"192.168.0.4:2222"}, Roles: []string{"node", "ingress"}, Env: []string{"PORT=8080", "DISK=/data1", "DISK=/data2"}},
+	}
+	cluster.Spec.Guest.Roles = roles
+	return cluster
+}
+
+func TestGetGuestHosts(t *testing.T) {
+	tests := []struct {
+		roles   []string
+		want    []string
+		wantErr bool
+	}{
+		{nil, []string{"192.168.0.2"}, false},
+		{[]string{"master"}, []string{"192.168.0.2", "192.168.0.3"}, false},
+		{[]string{"ingress", "node"}, []string{"192.168.0.4:2222"}, false},
+		{[]string{"gpu"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := getGuestHosts(newCluster(tt.roles...))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("getGuestHosts(%v) error = %v, wantErr %v", tt.roles, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getGuestHosts(%v) = %v, want %v", tt.roles, got, tt.want)
+		}
+	}
+}
+
+func TestGuestCommand(t *testing.T) {
+	facts := map[string]string{"MASTER0_IP": "192.168.0.2", "PORT": "6443"}
+	got := guestCommand("/rootfs", "sh init.sh", getGuestEnv(newCluster(), facts, "192.168.0.4:2222"))
+	want := "export DISK='/data1 /data2' && export HOST_IP='192.168.0.4' && export HOST_ROLES='node ingress' && " +
+		"export MASTER0_IP='192.168.0.2' && export PORT='8080' && export REGION='cn' && cd /rootfs && sh init.sh"
+	if got != want {
+		t.Errorf("guestCommand() = %s, want %s", got, want)
+	}
+	if got := guestCommand("/rootfs", "ls", map[string]string{"A": "it's"}); got != `export A='it'\''s' && cd /rootfs && ls` {
+		t.Errorf("guestCommand() = %s", got)
+	}
+}
+
+func TestGetGuestCmds(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "sealer-guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+	if err = os.MkdirAll(filepath.Join(rootfs, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	apps := `apps:
+- name: dashboard
+  cmd: ["kubectl apply -f manifests/dashboard.yaml"]
+- name: monitoring
+  cmd: ["helm install prometheus charts/prometheus", "helm install grafana charts/grafana"]
+`
+	if err = ioutil.WriteFile(filepath.Join(rootfs, AppsFile), []byte(apps), 0600); err != nil {
+		t.Fatal(err)
+	}
+	imageCmds := []string{"sh install-all.sh"}
+
+	tests := []struct {
+		name    string
+		apps    []string
+		cmd     []string
+		want    []string
+		wantErr bool
+	}{
+		{"the CMD of image", nil, nil, imageCmds, false},
+		{"override", nil, []string{"sh install-lite.sh"}, []string{"sh install-lite.sh"}, false},
+		{"apps in the order of file", []string{"monitoring", "dashboard"}, []string{"kubectl get pods"}, []string{
+			"kubectl apply -f manifests/dashboard.yaml",
+			"helm install prometheus charts/prometheus",
+			"helm install grafana charts/grafana",
+			"kubectl get pods"}, false},
+		{"unknown app", []string{"logging"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		cluster := newCluster()
+		cluster.Spec.Guest.Apps = tt.apps
+		cluster.Spec.Guest.Cmd = tt.cmd
+		got, err := getGuestCmds(cluster, rootfs, imageCmds)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: getGuestCmds() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: getGuestCmds() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostlog
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/metrics"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// Enable logs the output of the remote commands run in the phases of clusters to the files under dir, which is
+// common.DefaultHostLogDir if empty.
+func Enable(dir string) {
+	if dir == "" {
+		dir = common.DefaultHostLogDir
+	}
+	ssh.SetOutputLogger(fileLogger{dir: dir})
+}
+
+// Path returns the log file of the output of host in phase of cluster, like:
+// /var/lib/sealer/logs/my-cluster/192.168.0.2/Init.log
+func Path(dir, cluster, host, phase string) string {
+	return filepath.Join(dir, cluster, sanitize(host), sanitize(phase)+".log")
+}
+
+// sanitize keeps the name of host or phase in a single path element.
+func sanitize(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(name)
+}
+
+type fileLogger struct {
+	dir string
+}
+
+// Open appends to the log file of the phase ctx is of, the commands not run in a phase are not logged.
+func (l fileLogger) Open(ctx context.Context, host string) (io.WriteCloser, string, error) {
+	cluster, phase, ok := metrics.PhaseOf(ctx)
+	if !ok {
+		return nil, "", nil
+	}
+	path := Path(l.dir, cluster, host, phase)
+	if err := os.MkdirAll(filepath.Dir(path), common.FileMode0755); err != nil {
+		return nil, "", err
+	}
+	// the output may carry the tokens and passwords of cluster.
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, path, nil
+}
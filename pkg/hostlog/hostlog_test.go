@@ -0,0 +1,66 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostlog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/sealer/pkg/metrics"
+)
+
+func TestFileLogger_Open(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hostlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l := fileLogger{dir: dir}
+
+	w, path, err := l.Open(context.Background(), "192.168.0.2")
+	if err != nil || w != nil {
+		t.Fatalf("Open() without phase = %v, %v, want no writer", w, err)
+	}
+
+	ctx := metrics.WithPhase(context.Background(), "my-cluster", "Init")
+	for _, line := range []string{"first", "second"} {
+		w, path, err = l.Open(ctx, "192.168.0.2:2222")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(w, line)
+		w.Close()
+	}
+	if want := filepath.Join(dir, "my-cluster", "192.168.0.2:2222", "Init.log"); path != want {
+		t.Errorf("got path %s, want %s", path, want)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first\nsecond\n" {
+		t.Errorf("got log %q, want the lines appended", data)
+	}
+}
+
+func TestPath(t *testing.T) {
+	if got, want := Path("/logs", "my-cluster", "../etc", "Init"), "/logs/my-cluster/__etc/Init.log"; got != want {
+		t.Errorf("Path() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostprep
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	EtcdDataDir = "/var/lib/etcd"
+	// RegistryDisk is the path of the disk for the storage of the registry in rootfs.
+	RegistryDisk = "registry"
+
+	FSTypeXFS  = "xfs"
+	FSTypeExt4 = "ext4"
+
+	// the device is formatted only if it has no filesystem, and mounted by the UUID and type of its filesystem.
+	diskApply = `D=%[1]s; P=%[2]s; [ -b $D ] || { echo "$D is not a block device"; exit 1; }; ` +
+		`blkid $D >/dev/null 2>&1 || mkfs.%[3]s $D || exit 1; mkdir -p $P; ` +
+		`if mountpoint -q $P; then findmnt -n --source $D --mountpoint $P >/dev/null || { echo "$P is mounted from another device"; exit 1; }; ` +
+		`else [ -z "$(ls -A $P)" ] || { echo "$P is not empty, move its data away before mounting $D"; exit 1; }; fi; ` +
+		`sed -i "\|^[^#]\S*\s\+$P\s|d" /etc/fstab && ` +
+		`echo "UUID=$(blkid -s UUID -o value $D) $P $(blkid -s TYPE -o value $D) %[4]s 0 0" >> /etc/fstab && ` +
+		`(mountpoint -q $P || mount $P) && (rmdir $P/lost+found 2>/dev/null || true)`
+	diskCheck = `findmnt -n --source %[1]s --mountpoint %[2]s >/dev/null && grep -q "^UUID=$(blkid -s UUID -o value %[1]s)\s\+%[2]s\s" /etc/fstab`
+)
+
+// diskPath returns the data dir of the disk on the hosts of cluster.
+func diskPath(cluster *v2.Cluster, disk v2.DiskSpec) string {
+	if disk.Path == RegistryDisk {
+		return filepath.Join(common.DefaultTheClusterRootfsDir(cluster.Name), common.RegistryDirName)
+	}
+	return disk.Path
+}
+
+func validateDisks(disks []v2.DiskSpec) error {
+	paths := map[string]bool{}
+	for _, d := range disks {
+		if !strings.HasPrefix(d.Device, "/dev/") {
+			return fmt.Errorf("invalid device %s of disk, must be like /dev/vdb", d.Device)
+		}
+		if d.Path != RegistryDisk && (!filepath.IsAbs(d.Path) || filepath.Clean(d.Path) == "/") {
+			return fmt.Errorf("invalid path %s of disk %s, must be an absolute data dir or %s", d.Path, d.Device, RegistryDisk)
+		}
+		if d.FSType != "" && d.FSType != FSTypeXFS && d.FSType != FSTypeExt4 {
+			return fmt.Errorf("invalid fsType %s of disk %s, must be %s or %s", d.FSType, d.Device, FSTypeXFS, FSTypeExt4)
+		}
+		if paths[d.Path] {
+			return fmt.Errorf("path %s of disks is duplicated", d.Path)
+		}
+		paths[d.Path] = true
+	}
+	return nil
+}
+
+// hostDisks returns the disks of host, the ones of the host in Clusterfile overwrite spec.hostPrep.disks by path.
+func hostDisks(cluster *v2.Cluster, host string) ([]v2.DiskSpec, error) {
+	var own []v2.DiskSpec
+	for _, h := range cluster.Spec.Hosts {
+		if utils.InList(host, h.IPS) {
+			own = h.Disks
+		}
+	}
+	if err := validateDisks(cluster.Spec.HostPrep.Disks); err != nil {
+		return nil, err
+	}
+	if err := validateDisks(own); err != nil {
+		return nil, err
+	}
+	var disks []v2.DiskSpec
+	for _, d := range cluster.Spec.HostPrep.Disks {
+		overwritten := false
+		for _, o := range own {
+			overwritten = overwritten || o.Path == d.Path
+		}
+		if !overwritten && onHost(cluster, host, d) {
+			disks = append(disks, d)
+		}
+	}
+	// the disks of the host itself are mounted without matching roles.
+	return append(disks, own...), nil
+}
+
+// onHost returns true if disk is mounted on host by its roles.
+func onHost(cluster *v2.Cluster, host string, disk v2.DiskSpec) bool {
+	if len(disk.Roles) == 0 {
+		switch disk.Path {
+		case EtcdDataDir:
+			return utils.InList(host, cluster.GetMasterIPList())
+		case RegistryDisk:
+			reg := runtime.GetRegistryConfig(common.DefaultMountCloudImageDir(cluster.Name), cluster.GetMaster0Ip())
+			ip, _ := utils.GetSSHHostIPAndPort(reg.IP)
+			return ip == host
+		}
+		return true
+	}
+	for _, role := range disk.Roles {
+		if utils.InList(host, cluster.GetIPSByRole(role)) {
+			return true
+		}
+	}
+	return false
+}
+
+func diskItems(cluster *v2.Cluster, host string) ([]item, error) {
+	disks, err := hostDisks(cluster, host)
+	if err != nil {
+		return nil, err
+	}
+	var list []item
+	for _, d := range disks {
+		fsType, options := d.FSType, strings.Join(d.Options, ",")
+		if fsType == "" {
+			fsType = FSTypeXFS
+		}
+		if options == "" {
+			options = "defaults"
+		}
+		path := diskPath(cluster, d)
+		list = append(list, item{
+			name:  fmt.Sprintf("disk %s on %s", d.Device, path),
+			check: fmt.Sprintf(diskCheck, d.Device, path),
+			apply: fmt.Sprintf(diskApply, d.Device, path, fsType, options),
+		})
+	}
+	return list, nil
+}
+
+// HasEtcdDisk returns true if a disk is mounted to /var/lib/etcd of host.
+func HasEtcdDisk(cluster *v2.Cluster, host string) bool {
+	disks, err := hostDisks(cluster, host)
+	if err != nil {
+		return false
+	}
+	for _, d := range disks {
+		if d.Path == EtcdDataDir {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostprep
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/firewall"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	SysctlConfFile  = "/etc/sysctl.d/99-sealer.conf"
+	ModulesConfFile = "/etc/modules-load.d/sealer.conf"
+	SELinuxConfFile = "/etc/selinux/config"
+
+	SELinuxEnforcing  = "enforcing"
+	SELinuxPermissive = "permissive"
+	SELinuxDisabled   = "disabled"
+)
+
+// item is one piece of the desired host state, check exits 0 if the host is in that state,
+// so apply is only run on drifted hosts, which makes the preparation idempotent.
+type item struct {
+	name  string
+	check string
+	apply string
+}
+
+// Drift is the items not in the desired state of a host.
+type Drift struct {
+	Host  string
+	Items []string
+}
+
+// items returns the desired state of spec, kubernetesPorts are opened in firewalld if its kubernetesPorts is set.
+func items(spec v2.HostPrepSpec, kubernetesPorts []string) ([]item, error) {
+	var list []item
+	// load modules before sysctls, like net.bridge.* needs br_netfilter.
+	for _, m := range spec.KernelModules {
+		list = append(list, item{
+			name:  "module " + m,
+			check: fmt.Sprintf(`lsmod | grep -q "^%s " && grep -qx %s %s`, m, m, ModulesConfFile),
+			apply: fmt.Sprintf(`modprobe %s && mkdir -p $(dirname %s) && (grep -qx %s %s 2>/dev/null || echo %s >> %s)`,
+				m, ModulesConfFile, m, ModulesConfFile, m, ModulesConfFile),
+		})
+	}
+
+	keys := make([]string, 0, len(spec.Sysctls))
+	for k := range spec.Sysctls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := spec.Sysctls[k]
+		list = append(list, item{
+			name: fmt.Sprintf("sysctl %s=%s", k, v),
+			// sysctl prints multiple values separated by tab, xargs normalizes it.
+			check: fmt.Sprintf(`[ "$(sysctl -n %s 2>/dev/null | xargs)" = "%s" ] && grep -q "^%s = %s$" %s`, k, v, k, v, SysctlConfFile),
+			apply: fmt.Sprintf(`sysctl -w "%s=%s" && touch %s && sed -i "/^%s *=/d" %s && echo "%s = %s" >> %s`,
+				k, v, SysctlConfFile, k, SysctlConfFile, k, v, SysctlConfFile),
+		})
+	}
+
+	if spec.DisableSwap {
+		list = append(list, item{
+			name:  "swap disabled",
+			check: `[ "$(sed 1d /proc/swaps | wc -l)" = "0" ] && ! grep -Eq "^[^#].*\sswap\s" /etc/fstab`,
+			apply: `swapoff -a && sed -ri "/^[^#].*\sswap\s/s/^/#/" /etc/fstab`,
+		})
+	}
+
+	if spec.SELinux != "" {
+		i, err := selinuxItem(spec.SELinux)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, i)
+	}
+
+	fw := spec.Firewalld
+	if fw.Disabled {
+		list = append(list, item{
+			name:  "firewalld disabled",
+			check: `! systemctl is-active -q firewalld && ! systemctl is-enabled -q firewalld 2>/dev/null`,
+			apply: `systemctl disable --now firewalld`,
+		})
+		return list, nil
+	}
+	ports := fw.Ports
+	if fw.KubernetesPorts {
+		ports = append(ports, kubernetesPorts...)
+	}
+	for _, p := range utils.RemoveDuplicate(ports) {
+		list = append(list, item{
+			name: "firewalld port " + p,
+			// ports only make sense when firewalld is running.
+			check: fmt.Sprintf(`! systemctl is-active -q firewalld || firewall-cmd -q --query-port=%s`, p),
+			apply: fmt.Sprintf(`firewall-cmd -q --permanent --add-port=%s && firewall-cmd -q --add-port=%s`, p, p),
+		})
+	}
+	return list, nil
+}
+
+func selinuxItem(mode string) (item, error) {
+	// getenforce prints Enforcing, Permissive or Disabled.
+	current := `$(getenforce | tr "[:upper:]" "[:lower:]")`
+	var check, setenforce string
+	switch mode {
+	case SELinuxEnforcing:
+		check, setenforce = fmt.Sprintf(`[ "%s" = "%s" ]`, current, mode), "setenforce 1"
+	case SELinuxPermissive:
+		check, setenforce = fmt.Sprintf(`[ "%s" = "%s" ]`, current, mode), "setenforce 0"
+	case SELinuxDisabled:
+		// it is permissive until reboot after setenforce 0.
+		check, setenforce = fmt.Sprintf(`[ "%s" != "%s" ]`, current, SELinuxEnforcing), "setenforce 0"
+	default:
+		return item{}, fmt.Errorf("invalid selinux mode %s, must be one of %s, %s and %s", mode, SELinuxEnforcing, SELinuxPermissive, SELinuxDisabled)
+	}
+	return item{
+		name:  "selinux " + mode,
+		check: fmt.Sprintf(`! command -v getenforce >/dev/null 2>&1 || (%s && grep -qx "SELINUX=%s" %s)`, check, mode, SELinuxConfFile),
+		apply: fmt.Sprintf(`(%s || true) && sed -ri "s/^SELINUX=.*/SELINUX=%s/" %s`, setenforce, mode, SELinuxConfFile),
+	}, nil
+}
+
+func hostItems(cluster *v2.Cluster, host string) ([]item, error) {
+	spec := cluster.Spec.HostPrep
+	// the kernel modules of the kube-proxy mode are verified and loaded on all hosts before installing.
+	modules := append(append([]string{}, spec.KernelModules...), runtime.KubeProxyKernelModules(cluster.Spec.Kubernetes.KubeProxy)...)
+	spec.KernelModules = utils.RemoveDuplicate(modules)
+	list, err := items(spec, firewall.KubernetesPorts(cluster, host))
+	if err != nil {
+		return nil, err
+	}
+	// mount the disks first, as the other items may write to the data dirs.
+	disks, err := diskItems(cluster, host)
+	if err != nil {
+		return nil, err
+	}
+	return append(disks, list...), nil
+}
+
+func driftItems(client ssh.Interface, host string, list []item) ([]item, error) {
+	// make sure a failed check means drift rather than an unreachable host.
+	if err := client.Ping(host); err != nil {
+		return nil, err
+	}
+	var drifted []item
+	for _, i := range list {
+		if _, err := client.Cmd(host, i.check); err != nil {
+			drifted = append(drifted, i)
+		}
+	}
+	return drifted, nil
+}
+
+// Check returns the drift of hosts from the host preparation in Clusterfile, hosts in the desired state are not included.
+func Check(cluster *v2.Cluster, hosts []string) ([]Drift, error) {
+	var drifts []Drift
+	for _, h := range hosts {
+		list, err := hostItems(cluster, h)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			continue
+		}
+		client, err := ssh.GetHostSSHClient(h, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("get host ssh client failed %v", err)
+		}
+		drifted, err := driftItems(client, h, list)
+		if err != nil {
+			return nil, err
+		}
+		drift := Drift{Host: h}
+		for _, i := range drifted {
+			drift.Items = append(drift.Items, i.name)
+		}
+		if len(drift.Items) != 0 {
+			drifts = append(drifts, drift)
+		}
+	}
+	return drifts, nil
+}
+
+// Apply prepares hosts as the Clusterfile declares, only the drifted items are applied.
+func Apply(cluster *v2.Cluster, hosts []string) error {
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			if err := applyHost(cluster, ip); err != nil {
+				errCh <- result.OnHost(ip, err)
+			}
+		}(h)
+	}
+	wg.Wait()
+	return runtime.ReadChanError(errCh)
+}
+
+func applyHost(cluster *v2.Cluster, host string) error {
+	list, err := hostItems(cluster, host)
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	client, err := ssh.GetHostSSHClient(host, cluster)
+	if err != nil {
+		return fmt.Errorf("get host ssh client failed %v", err)
+	}
+	drifted, err := driftItems(client, host, list)
+	if err != nil || len(drifted) == 0 {
+		return err
+	}
+	var names []string
+	for _, i := range drifted {
+		names = append(names, i.name)
+	}
+	logger.Info("host %s drifted from the host preparation: %s", host, strings.Join(names, ", "))
+	for _, i := range drifted {
+		if err := client.CmdAsync(host, i.apply); err != nil {
+			return fmt.Errorf("failed to apply %s on %s: %v", i.name, host, err)
+		}
+	}
+	return nil
+}
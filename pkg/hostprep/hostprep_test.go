@@ -0,0 +1,143 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostprep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/pkg/firewall"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func itemNames(list []item) []string {
+	var names []string
+	for _, i := range list {
+		names = append(names, i.name)
+	}
+	return names
+}
+
+func TestItems(t *testing.T) {
+	spec := v2.HostPrepSpec{
+		Sysctls:       map[string]string{"net.ipv4.ip_forward": "1", "net.bridge.bridge-nf-call-iptables": "1"},
+		KernelModules: []string{"br_netfilter"},
+		DisableSwap:   true,
+		SELinux:       "disabled",
+		Firewalld:     v2.FirewalldSpec{KubernetesPorts: true, Ports: []string{"10250/tcp"}},
+	}
+	cluster := &v2.Cluster{}
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+		{IPS: []string{"192.168.0.3"}, Roles: []string{"node"}},
+	}
+	list, err := items(spec, firewall.KubernetesPorts(cluster, "192.168.0.3"))
+	if err != nil {
+		t.Fatalf("items() error = %v", err)
+	}
+	want := []string{
+		"module br_netfilter",
+		"sysctl net.bridge.bridge-nf-call-iptables=1",
+		"sysctl net.ipv4.ip_forward=1",
+		"swap disabled",
+		"selinux disabled",
+		"firewalld port 10250/tcp",
+		"firewalld port 30000-32767/tcp",
+		"firewalld port 30000-32767/udp",
+	}
+	if got := itemNames(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("items() = %v, want %v", got, want)
+	}
+
+	spec.Firewalld.Disabled = true
+	list, _ = items(spec, firewall.KubernetesPorts(cluster, "192.168.0.2"))
+	if got := itemNames(list); got[len(got)-1] != "firewalld disabled" {
+		t.Errorf("items() = %v, want firewalld disabled without ports", got)
+	}
+
+	if _, err := items(v2.HostPrepSpec{SELinux: "off"}, nil); err == nil {
+		t.Errorf("items() should fail on invalid selinux mode")
+	}
+}
+
+func TestHostItemsKubeProxyModules(t *testing.T) {
+	cluster := &v2.Cluster{}
+	cluster.Spec.HostPrep.KernelModules = []string{"br_netfilter", "nf_conntrack"}
+	cluster.Spec.Kubernetes.KubeProxy = v2.KubeProxySpec{Mode: "ipvs", IPVS: v2.IPVSSpec{Scheduler: "wrr"}}
+	list, err := hostItems(cluster, "192.168.0.2")
+	if err != nil {
+		t.Fatalf("hostItems() error = %v", err)
+	}
+	want := []string{"module br_netfilter", "module nf_conntrack", "module ip_vs", "module ip_vs_wrr"}
+	if got := itemNames(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("hostItems() = %v, want %v", got, want)
+	}
+}
+
+func TestHostItemsDisks(t *testing.T) {
+	cluster := &v2.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-such-cluster"},
+		Spec: v2.ClusterSpec{
+			Hosts: []v2.Host{
+				{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+				{IPS: []string{"192.168.0.3"}, Roles: []string{"node"}, Disks: []v2.DiskSpec{{Device: "/dev/vdd", Path: "/var/lib/containerd"}}},
+			},
+			HostPrep: v2.HostPrepSpec{Disks: []v2.DiskSpec{
+				{Device: "/dev/vdb", Path: "/var/lib/etcd"},
+				{Device: "/dev/vdc", Path: "/var/lib/containerd", FSType: "ext4", Options: []string{"noatime"}},
+				{Device: "/dev/vde", Path: RegistryDisk},
+			}},
+		},
+	}
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"192.168.0.2", []string{
+			"disk /dev/vdb on /var/lib/etcd",
+			"disk /dev/vdc on /var/lib/containerd",
+			"disk /dev/vde on /var/lib/sealer/data/no-such-cluster/rootfs/registry",
+		}},
+		{"192.168.0.3", []string{"disk /dev/vdd on /var/lib/containerd"}},
+	}
+	for _, tt := range tests {
+		list, err := hostItems(cluster, tt.host)
+		if err != nil {
+			t.Fatalf("hostItems(%s) error = %v", tt.host, err)
+		}
+		if got := itemNames(list); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hostItems(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+	list, _ := hostItems(cluster, "192.168.0.2")
+	if !strings.Contains(list[1].apply, "D=/dev/vdc;") || !strings.Contains(list[1].apply, "mkfs.ext4 $D") || !strings.Contains(list[1].apply, "noatime 0 0") {
+		t.Errorf("apply of disk = %q, want ext4 with noatime", list[1].apply)
+	}
+	if !HasEtcdDisk(cluster, "192.168.0.2") || HasEtcdDisk(cluster, "192.168.0.3") {
+		t.Errorf("HasEtcdDisk() should be true only on the master")
+	}
+
+	cluster.Spec.HostPrep.Disks = append(cluster.Spec.HostPrep.Disks, v2.DiskSpec{Device: "/dev/vdf", Path: "/var/lib/etcd"})
+	if _, err := hostItems(cluster, "192.168.0.2"); err == nil {
+		t.Errorf("hostItems() should fail on duplicated paths")
+	}
+	cluster.Spec.HostPrep.Disks = []v2.DiskSpec{{Device: "vdb", Path: "/var/lib/etcd"}}
+	if _, err := hostItems(cluster, "192.168.0.2"); err == nil {
+		t.Errorf("hostItems() should fail on invalid device")
+	}
+}
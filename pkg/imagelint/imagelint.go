@@ -0,0 +1,336 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagelint
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/sbom"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/mount"
+)
+
+const (
+	scriptsDir   = "scripts"
+	binDir       = "bin"
+	manifestsDir = "manifests"
+	// ImageListFile lists the container images of CloudImage line by line, they are cached in its registry by sealer build.
+	ImageListFile = "imageList"
+)
+
+// RequiredScripts are run on every host by sealer apply.
+var RequiredScripts = []string{"init.sh", "init-registry.sh"}
+
+// The checks of CloudImage.
+const (
+	CheckScripts   = "scripts"
+	CheckMetadata  = "metadata"
+	CheckVersions  = "versions"
+	CheckImageList = "imageList"
+	CheckManifests = "manifests"
+)
+
+type Level string
+
+const (
+	LevelOK      Level = "ok"
+	LevelWarning Level = "warning"
+	LevelError   Level = "error"
+)
+
+// Result is the result of a check, the checks failing at LevelError break sealer apply.
+type Result struct {
+	Check   string `json:"check"`
+	Level   Level  `json:"level"`
+	Message string `json:"message"`
+}
+
+type Report struct {
+	Image   string   `json:"image"`
+	Results []Result `json:"results"`
+}
+
+// Failed returns whether any check of r failed at LevelError.
+func (r *Report) Failed() bool {
+	for _, res := range r.Results {
+		if res.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks the rootfs of CloudImage of arch, the kubeadm and kubelet are only run if arch is the one of this host.
+func Lint(rootfs, arch string) []Result {
+	var results []Result
+	results = append(results, checkScripts(rootfs))
+	metadata, res := checkMetadata(rootfs)
+	results = append(results, res)
+	results = append(results, checkVersions(rootfs, arch, metadata)...)
+	results = append(results, checkImageList(rootfs))
+	results = append(results, checkManifests(rootfs)...)
+	return results
+}
+
+func checkScripts(rootfs string) Result {
+	var missing []string
+	for _, script := range RequiredScripts {
+		if !utils.IsFileExist(filepath.Join(rootfs, scriptsDir, script)) {
+			missing = append(missing, filepath.Join(scriptsDir, script))
+		}
+	}
+	if len(missing) != 0 {
+		return Result{CheckScripts, LevelError, fmt.Sprintf("%s not found in rootfs", strings.Join(missing, ", "))}
+	}
+	return Result{CheckScripts, LevelOK, fmt.Sprintf("%s found", strings.Join(RequiredScripts, ", "))}
+}
+
+func checkMetadata(rootfs string) (*runtime.Metadata, Result) {
+	metadata, err := runtime.LoadMetadata(rootfs)
+	switch {
+	case err != nil:
+		return nil, Result{CheckMetadata, LevelError, err.Error()}
+	case metadata == nil:
+		return nil, Result{CheckMetadata, LevelWarning, fmt.Sprintf("%s not found, the kubernetes version and host requirements are not checked", common.DefaultMetadataName)}
+	case metadata.Version == "":
+		return metadata, Result{CheckMetadata, LevelError, fmt.Sprintf("the kubernetes version of %s is empty", common.DefaultMetadataName)}
+	}
+	return metadata, Result{CheckMetadata, LevelOK, fmt.Sprintf("kubernetes %s", metadata.Version)}
+}
+
+// checkVersions compares the versions of kubeadm and kubelet in bin with the one of Metadata.
+func checkVersions(rootfs, arch string, metadata *runtime.Metadata) []Result {
+	var results []Result
+	for _, bin := range []struct {
+		name string
+		args []string
+	}{
+		{"kubeadm", []string{"version", "-o", "short"}},
+		{"kubelet", []string{"--version"}},
+	} {
+		path := filepath.Join(rootfs, binDir, bin.name)
+		if !utils.IsFileExist(path) {
+			results = append(results, Result{CheckVersions, LevelError, fmt.Sprintf("%s not found in rootfs", filepath.Join(binDir, bin.name))})
+			continue
+		}
+		if metadata == nil || metadata.Version == "" {
+			continue
+		}
+		if arch != goruntime.GOARCH {
+			results = append(results, Result{CheckVersions, LevelWarning,
+				fmt.Sprintf("the version of %s is not checked, it is built for %s instead of %s", bin.name, arch, goruntime.GOARCH)})
+			continue
+		}
+		out, err := utils.CmdOutput(path, bin.args...)
+		if err != nil {
+			results = append(results, Result{CheckVersions, LevelError, fmt.Sprintf("failed to get the version of %s: %v", bin.name, err)})
+			continue
+		}
+		version := parseVersion(string(out))
+		if strings.TrimPrefix(version, "v") != strings.TrimPrefix(metadata.Version, "v") {
+			results = append(results, Result{CheckVersions, LevelError,
+				fmt.Sprintf("%s is %s, but the kubernetes version of %s is %s", bin.name, version, common.DefaultMetadataName, metadata.Version)})
+			continue
+		}
+		results = append(results, Result{CheckVersions, LevelOK, fmt.Sprintf("%s %s", bin.name, version)})
+	}
+	return results
+}
+
+// parseVersion returns the version in the output of kubeadm version -o short, like v1.19.8, or the one of
+// kubelet --version, like Kubernetes v1.19.8.
+func parseVersion(out string) string {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// checkImageList checks the images of manifests/imageList are cached in the registry of rootfs.
+func checkImageList(rootfs string) Result {
+	path := filepath.Join(rootfs, manifestsDir, ImageListFile)
+	if !utils.IsFileExist(path) {
+		return Result{CheckImageList, LevelOK, fmt.Sprintf("no %s", filepath.Join(manifestsDir, ImageListFile))}
+	}
+	lines, err := utils.ReadLines(path)
+	if err != nil {
+		return Result{CheckImageList, LevelError, err.Error()}
+	}
+	cached, err := sbom.ListImages(rootfs)
+	if err != nil {
+		return Result{CheckImageList, LevelError, fmt.Sprintf("failed to list the images in registry: %v", err)}
+	}
+	var images, missing []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		images = append(images, line)
+		named, err := reference.ParseToNamed(line)
+		if err != nil {
+			return Result{CheckImageList, LevelError, fmt.Sprintf("invalid image %s in %s: %v", line, ImageListFile, err)}
+		}
+		if !isCached(named, cached) {
+			missing = append(missing, line)
+		}
+	}
+	if len(missing) != 0 {
+		return Result{CheckImageList, LevelError, fmt.Sprintf("%d of the %d images are not in the registry of rootfs: %s",
+			len(missing), len(images), strings.Join(missing, ", "))}
+	}
+	return Result{CheckImageList, LevelOK, fmt.Sprintf("%d images found in registry", len(images))}
+}
+
+// isCached returns whether named is in the registry, which caches the images by their repository without
+// the domain, or with it if the images of several domains are cached.
+func isCached(named reference.Named, cached []sbom.Component) bool {
+	for _, c := range cached {
+		if c.Version == named.Tag() && (c.Name == named.Repo() || c.Name == named.Domain()+"/"+named.Repo()) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkManifests decodes the yaml files under manifests, which are applied by the CMD of CloudImage.
+func checkManifests(rootfs string) []Result {
+	root := filepath.Join(rootfs, manifestsDir)
+	if !utils.IsExist(root) {
+		return nil
+	}
+	var (
+		results []Result
+		n       int
+	)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !utils.YamlMatcher(path) {
+			return nil
+		}
+		n++
+		rel, _ := filepath.Rel(rootfs, path)
+		if err := decodeYaml(path); err != nil {
+			results = append(results, Result{CheckManifests, LevelError, fmt.Sprintf("failed to parse %s: %v", rel, err)})
+		}
+		return nil
+	})
+	if err != nil {
+		return append(results, Result{CheckManifests, LevelError, err.Error()})
+	}
+	if len(results) == 0 {
+		results = append(results, Result{CheckManifests, LevelOK, fmt.Sprintf("%d manifests parsed", n)})
+	}
+	return results
+}
+
+func decodeYaml(path string) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		ext := k8sruntime.RawExtension{}
+		if err := d.Decode(&ext); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// LintImage mounts the CloudImage and checks it, the index of multi-platform image is checked platform by platform.
+func LintImage(imageStore store.ImageStore, img *v1.Image) (*Report, error) {
+	report := &Report{Image: img.Name}
+	images := []*v1.Image{img}
+	if platform.IsIndex(img) {
+		images = nil
+		for _, m := range img.Spec.Manifests {
+			pImg, err := imageStore.GetByName(m.Name)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, pImg)
+		}
+	}
+
+	for _, i := range images {
+		results, err := lintImage(i)
+		if err != nil {
+			return nil, err
+		}
+		if len(images) > 1 {
+			for n := range results {
+				results[n].Check = fmt.Sprintf("%s (%s)", results[n].Check, platform.Format(i.Spec.Platform))
+			}
+		}
+		report.Results = append(report.Results, results...)
+	}
+	return report, nil
+}
+
+func lintImage(img *v1.Image) ([]Result, error) {
+	mountTarget, err := utils.MkTmpdir()
+	if err != nil {
+		return nil, err
+	}
+	mountUpper, err := utils.MkTmpdir()
+	if err != nil {
+		return nil, err
+	}
+	defer utils.CleanDirs(mountTarget, mountUpper)
+
+	layers, err := image.GetImageLayerDirs(img)
+	if err != nil {
+		return nil, err
+	}
+	driver := mount.NewMountDriver()
+	if err = driver.Mount(mountTarget, mountUpper, layers...); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := driver.Unmount(mountTarget); err != nil {
+			logger.Warn(err)
+		}
+	}()
+
+	arch := img.Spec.Platform.Architecture
+	if arch == "" {
+		arch = platform.Default().Architecture
+	}
+	return Lint(mountTarget, arch), nil
+}
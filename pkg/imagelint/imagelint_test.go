@@ -0,0 +1,91 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagelint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	goruntime "runtime"
+	"testing"
+)
+
+func writeFiles(t *testing.T, rootfs string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(rootfs, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func levels(results []Result) map[string]Level {
+	res := make(map[string]Level)
+	for _, r := range results {
+		if res[r.Check] != LevelError {
+			res[r.Check] = r.Level
+		}
+	}
+	return res
+}
+
+func TestLint(t *testing.T) {
+	complete := map[string]string{
+		"scripts/init.sh":          "",
+		"scripts/init-registry.sh": "",
+		"Metadata":                 `{"version": "v1.19.8", "arch": "amd64"}`,
+		"bin/kubeadm":              "#!/bin/sh\necho v1.19.8\n",
+		"bin/kubelet":              "#!/bin/sh\necho Kubernetes v1.19.8\n",
+		"manifests/imageList":      "k8s.gcr.io/pause:3.2\n\n# the dashboard\nkubernetesui/dashboard:v2.2.0\n",
+		"manifests/dashboard.yaml": "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: dashboard\n---\napiVersion: v1\nkind: ServiceAccount\n",
+		"registry/docker/registry/v2/repositories/pause/_manifests/tags/3.2/current/link":                     "sha256:1",
+		"registry/docker/registry/v2/repositories/kubernetesui/dashboard/_manifests/tags/v2.2.0/current/link": "sha256:2",
+	}
+	rootfs := t.TempDir()
+	writeFiles(t, rootfs, complete)
+	want := map[string]Level{CheckScripts: LevelOK, CheckMetadata: LevelOK, CheckVersions: LevelOK, CheckImageList: LevelOK, CheckManifests: LevelOK}
+	if got := levels(Lint(rootfs, goruntime.GOARCH)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() of complete image = %v, want %v", got, want)
+	}
+	if got := levels(Lint(rootfs, "s390x")); got[CheckVersions] != LevelWarning {
+		t.Errorf("Lint() of foreign arch = %v, want versions %s", got, LevelWarning)
+	}
+
+	broken := t.TempDir()
+	writeFiles(t, broken, complete)
+	if err := os.Remove(filepath.Join(broken, "scripts/init-registry.sh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(broken, "registry/docker/registry/v2/repositories/pause")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, broken, map[string]string{
+		"bin/kubelet":          "#!/bin/sh\necho Kubernetes v1.20.4\n",
+		"manifests/broken.yml": "kind: [Namespace\n",
+	})
+	want = map[string]Level{CheckScripts: LevelError, CheckMetadata: LevelOK, CheckVersions: LevelError, CheckImageList: LevelError, CheckManifests: LevelError}
+	if got := levels(Lint(broken, goruntime.GOARCH)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() of broken image = %v, want %v", got, want)
+	}
+
+	writeFiles(t, broken, map[string]string{"Metadata": "{"})
+	if got := levels(Lint(broken, goruntime.GOARCH)); got[CheckMetadata] != LevelError {
+		t.Errorf("Lint() of broken Metadata = %v, want metadata %s", got, LevelError)
+	}
+}
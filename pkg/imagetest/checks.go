@@ -0,0 +1,123 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/alibaba/sealer/pkg/imagelint"
+)
+
+// The checks of the cluster the CloudImage is applied to.
+const (
+	CheckApply     = "apply"
+	CheckAPIServer = "apiserver"
+	CheckNodes     = "nodes"
+	CheckPods      = "pods"
+)
+
+// Kubectl runs kubectl with args on master0 and returns its output.
+type Kubectl func(args string) ([]byte, error)
+
+// Check checks the apiserver is ready, all the hosts joined as ready nodes and the pods of all namespaces, which
+// include the ones installed by the CMD of CloudImage, are ready.
+func Check(kubectl Kubectl, hosts int) []imagelint.Result {
+	return []imagelint.Result{checkAPIServer(kubectl), checkNodes(kubectl, hosts), checkPods(kubectl)}
+}
+
+func failed(results []imagelint.Result) bool {
+	r := Report{Results: results}
+	return r.Failed()
+}
+
+func errorResult(check, format string, a ...interface{}) imagelint.Result {
+	return imagelint.Result{Check: check, Level: imagelint.LevelError, Message: fmt.Sprintf(format, a...)}
+}
+
+func checkAPIServer(kubectl Kubectl) imagelint.Result {
+	out, err := kubectl("get --raw /readyz")
+	if err != nil {
+		return errorResult(CheckAPIServer, "apiserver is not ready: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return imagelint.Result{Check: CheckAPIServer, Level: imagelint.LevelOK, Message: strings.TrimSpace(string(out))}
+}
+
+func checkNodes(kubectl Kubectl, hosts int) imagelint.Result {
+	out, err := kubectl("get nodes -o json")
+	if err != nil {
+		return errorResult(CheckNodes, "failed to list nodes: %v", err)
+	}
+	var nodes corev1.NodeList
+	if err = json.Unmarshal(out, &nodes); err != nil {
+		return errorResult(CheckNodes, "failed to decode nodes: %v", err)
+	}
+	var notReady []string
+	for _, n := range nodes.Items {
+		if !nodeReady(n) {
+			notReady = append(notReady, n.Name)
+		}
+	}
+	if len(notReady) != 0 {
+		return errorResult(CheckNodes, "%d of %d nodes are not ready: %s", len(notReady), len(nodes.Items), strings.Join(notReady, ", "))
+	}
+	if len(nodes.Items) != hosts {
+		return errorResult(CheckNodes, "%d of %d hosts joined", len(nodes.Items), hosts)
+	}
+	return imagelint.Result{Check: CheckNodes, Level: imagelint.LevelOK, Message: fmt.Sprintf("%d nodes ready", hosts)}
+}
+
+func nodeReady(n corev1.Node) bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func checkPods(kubectl Kubectl) imagelint.Result {
+	out, err := kubectl("get pods --all-namespaces -o json")
+	if err != nil {
+		return errorResult(CheckPods, "failed to list pods: %v", err)
+	}
+	var pods corev1.PodList
+	if err = json.Unmarshal(out, &pods); err != nil {
+		return errorResult(CheckPods, "failed to decode pods: %v", err)
+	}
+	var notReady []string
+	for _, p := range pods.Items {
+		// the pods of jobs are done once succeeded.
+		if p.Status.Phase != corev1.PodSucceeded && !podReady(p) {
+			notReady = append(notReady, p.Namespace+"/"+p.Name)
+		}
+	}
+	if len(notReady) != 0 {
+		return errorResult(CheckPods, "%d of %d pods are not ready: %s", len(notReady), len(pods.Items), strings.Join(notReady, ", "))
+	}
+	return imagelint.Result{Check: CheckPods, Level: imagelint.LevelOK, Message: fmt.Sprintf("%d pods ready", len(pods.Items))}
+}
+
+func podReady(p corev1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagetest
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/pkg/imagelint"
+)
+
+func node(name string, ready corev1.ConditionStatus) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}},
+	}
+}
+
+func pod(name string, phase corev1.PodPhase, ready corev1.ConditionStatus) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system"},
+		Status:     corev1.PodStatus{Phase: phase, Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+	}
+}
+
+func fakeKubectl(t *testing.T, readyz error, nodes corev1.NodeList, pods corev1.PodList) Kubectl {
+	return func(args string) ([]byte, error) {
+		switch {
+		case strings.HasPrefix(args, "get --raw /readyz"):
+			if readyz != nil {
+				return []byte("[-]etcd failed"), readyz
+			}
+			return []byte("ok"), nil
+		case strings.HasPrefix(args, "get nodes"):
+			return json.Marshal(nodes)
+		case strings.HasPrefix(args, "get pods"):
+			return json.Marshal(pods)
+		}
+		t.Fatalf("unexpected kubectl %s", args)
+		return nil, nil
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		readyz error
+		nodes  []corev1.Node
+		pods   []corev1.Pod
+		hosts  int
+		want   []imagelint.Level
+		msg    string
+	}{
+		{
+			name:  "ready",
+			nodes: []corev1.Node{node("master0", corev1.ConditionTrue), node("node0", corev1.ConditionTrue)},
+			pods:  []corev1.Pod{pod("coredns", corev1.PodRunning, corev1.ConditionTrue), pod("job", corev1.PodSucceeded, corev1.ConditionFalse)},
+			hosts: 2,
+			want:  []imagelint.Level{imagelint.LevelOK, imagelint.LevelOK, imagelint.LevelOK},
+		},
+		{
+			name:   "apiserver not ready",
+			readyz: errors.New("exit status 1"),
+			nodes:  []corev1.Node{node("master0", corev1.ConditionTrue)},
+			hosts:  1,
+			want:   []imagelint.Level{imagelint.LevelError, imagelint.LevelOK, imagelint.LevelOK},
+			msg:    "etcd failed",
+		},
+		{
+			name:  "node not joined",
+			nodes: []corev1.Node{node("master0", corev1.ConditionTrue)},
+			hosts: 2,
+			want:  []imagelint.Level{imagelint.LevelOK, imagelint.LevelError, imagelint.LevelOK},
+			msg:   "1 of 2 hosts joined",
+		},
+		{
+			name:  "pod not ready",
+			nodes: []corev1.Node{node("master0", corev1.ConditionFalse)},
+			pods:  []corev1.Pod{pod("calico-node", corev1.PodRunning, corev1.ConditionFalse)},
+			hosts: 1,
+			want:  []imagelint.Level{imagelint.LevelOK, imagelint.LevelError, imagelint.LevelError},
+			msg:   "kube-system/calico-node",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubectl := fakeKubectl(t, tt.readyz, corev1.NodeList{Items: tt.nodes}, corev1.PodList{Items: tt.pods})
+			results := Check(kubectl, tt.hosts)
+			var messages []string
+			for i, r := range results {
+				if r.Level != tt.want[i] {
+					t.Errorf("check %s = %s: %s, want %s", r.Check, r.Level, r.Message, tt.want[i])
+				}
+				messages = append(messages, r.Message)
+			}
+			if !strings.Contains(strings.Join(messages, "\n"), tt.msg) {
+				t.Errorf("Check() messages %v do not contain %q", messages, tt.msg)
+			}
+			if failed(results) != (tt.name != "ready") {
+				t.Errorf("failed() = %v", failed(results))
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{Image: "kubernetes:v1.19.8"}); err == nil {
+		t.Error("New() without masters should fail")
+	}
+	h, err := New(Options{Image: "kubernetes:v1.19.8", Masters: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(h.Name(), clusterPrefix) || h.opts.Wait != DefaultWait {
+		t.Errorf("New() = %+v", h)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagetest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/apply/v2"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/infra/container"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/imagelint"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// DefaultWait is how long the checks are retried by default, the pods of the CMD of CloudImage take a while to be ready.
+	DefaultWait   = 10 * time.Minute
+	clusterPrefix = "sealer-test-"
+	pollInterval  = 5 * time.Second
+)
+
+// Options is the cluster the CloudImage is tested on, whose hosts are docker containers on this host.
+type Options struct {
+	Image   string
+	Masters int
+	Nodes   int
+	// Wait is how long the checks are retried until they pass.
+	Wait time.Duration
+	// Keep leaves the containers running after the test to debug the cluster.
+	Keep bool
+}
+
+// Report is the result of the checks of the cluster the CloudImage is applied to.
+type Report struct {
+	Image   string             `json:"image"`
+	Cluster string             `json:"cluster"`
+	Hosts   []string           `json:"hosts"`
+	Results []imagelint.Result `json:"results"`
+}
+
+// Failed returns whether any check of r failed.
+func (r *Report) Failed() bool {
+	for _, res := range r.Results {
+		if res.Level == imagelint.LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Harness creates the containers of a cluster, applies the CloudImage to them, checks the cluster and removes them.
+type Harness struct {
+	opts    Options
+	name    string
+	infra   *container.ApplyProvider
+	masters []string
+	nodes   []string
+}
+
+func New(opts Options) (*Harness, error) {
+	if opts.Image == "" {
+		return nil, fmt.Errorf("the image to test is required")
+	}
+	if opts.Masters < 1 || opts.Nodes < 0 {
+		return nil, fmt.Errorf("at least 1 master is required, but got %d masters and %d nodes", opts.Masters, opts.Nodes)
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultWait
+	}
+	return &Harness{opts: opts, name: clusterPrefix + strings.ToLower(utils.GenUniqueID(6))}, nil
+}
+
+// Name is the name of the cluster under test.
+func (h *Harness) Name() string {
+	return h.name
+}
+
+// Hosts returns the IPs of the containers, the masters go first.
+func (h *Harness) Hosts() []string {
+	return append(append([]string{}, h.masters...), h.nodes...)
+}
+
+func (h *Harness) sshConfig() v1.SSH {
+	return v1.SSH{User: common.ROOT, Passwd: container.DefaultPassword}
+}
+
+// Up runs the privileged containers of the masters and nodes by the CONTAINER provider.
+func (h *Harness) Up() error {
+	cluster := &v1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: h.name},
+		Spec: v1.ClusterSpec{
+			Provider: container.CONTAINER,
+			Masters:  v1.Hosts{Count: strconv.Itoa(h.opts.Masters)},
+			Nodes:    v1.Hosts{Count: strconv.Itoa(h.opts.Nodes)},
+			SSH:      h.sshConfig(),
+		},
+	}
+	p, err := container.NewClientWithCluster(cluster)
+	if err != nil {
+		return fmt.Errorf("failed to connect to docker: %v", err)
+	}
+	h.infra = p
+	if err = p.Apply(); err != nil {
+		return fmt.Errorf("failed to run the containers of cluster %s: %v", h.name, err)
+	}
+	h.masters, h.nodes = cluster.Spec.Masters.IPList, cluster.Spec.Nodes.IPList
+	return nil
+}
+
+// Apply applies the CloudImage to the containers, with the Clusterfile of the image if it has one.
+func (h *Harness) Apply(ctx context.Context) error {
+	cluster, err := apply.GetClusterFileByImageName(h.opts.Image)
+	if err != nil {
+		return err
+	}
+	cluster.Name = h.name
+	cluster.Spec.Image = h.opts.Image
+	cluster.Spec.SSH = h.sshConfig()
+	cluster.Spec.Hosts = []v2.Host{{IPS: h.masters, Roles: []string{common.MASTER}}}
+	if len(h.nodes) != 0 {
+		cluster.Spec.Hosts = append(cluster.Spec.Hosts, v2.Host{IPS: h.nodes, Roles: []string{common.NODE}})
+	}
+	applier, err := apply.NewApplier(cluster)
+	if err != nil {
+		return err
+	}
+	return applier.Apply(ctx)
+}
+
+// Check runs the checks by kubectl on master0 until all of them pass or opts.Wait passes, and returns the results
+// of the last run.
+func (h *Harness) Check(ctx context.Context) ([]imagelint.Result, error) {
+	if len(h.masters) == 0 {
+		return nil, fmt.Errorf("the containers of cluster %s are not running", h.name)
+	}
+	kubectl := h.Kubectl()
+	deadline := time.Now().Add(h.opts.Wait)
+	for {
+		results := Check(kubectl, len(h.Hosts()))
+		if !failed(results) || time.Now().After(deadline) {
+			return results, nil
+		}
+		select {
+		case <-ctx.Done():
+			return results, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Kubectl runs kubectl on master0 by ssh.
+func (h *Harness) Kubectl() Kubectl {
+	sshConfig := h.sshConfig()
+	client := ssh.NewSSHClient(&sshConfig)
+	return func(args string) ([]byte, error) {
+		return client.Cmd(h.masters[0], "kubectl "+args)
+	}
+}
+
+// Down removes the containers and the files of the cluster, unless opts.Keep is set.
+func (h *Harness) Down() error {
+	if h.infra == nil {
+		return nil
+	}
+	if h.opts.Keep {
+		var ids []string
+		for _, ip := range h.Hosts() {
+			ids = append(ids, h.infra.Cluster.Annotations[ip])
+		}
+		logger.Info("the containers of cluster %s are kept, ssh to them by root:%s, remove them by: docker rm -f %s",
+			h.name, container.DefaultPassword, strings.Join(ids, " "))
+		return nil
+	}
+	if err := h.infra.CleanUp(); err != nil {
+		return err
+	}
+	utils.CleanDir(common.GetClusterWorkDir(h.name))
+	return nil
+}
+
+// Run tests the CloudImage of opts: it runs the containers, applies the image to them, checks the cluster and
+// removes the containers. The error is returned only if the test can not run, the failed checks are in the report.
+func Run(ctx context.Context, opts Options) (report *Report, err error) {
+	h, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if derr := h.Down(); derr != nil {
+			logger.Warn("failed to remove the containers of cluster %s: %v", h.name, derr)
+		}
+	}()
+	if err = h.Up(); err != nil {
+		return nil, err
+	}
+	report = &Report{Image: opts.Image, Cluster: h.name, Hosts: h.Hosts()}
+	if err = h.Apply(ctx); err != nil {
+		report.Results = []imagelint.Result{{Check: CheckApply, Level: imagelint.LevelError, Message: err.Error()}}
+		return report, nil
+	}
+	report.Results = append(report.Results, imagelint.Result{Check: CheckApply, Level: imagelint.LevelOK,
+		Message: fmt.Sprintf("%d masters and %d nodes", len(h.masters), len(h.nodes))})
+	results, err := h.Check(ctx)
+	report.Results = append(report.Results, results...)
+	return report, err
+}
@@ -0,0 +1,64 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alibaba/sealer/pkg/imagelint"
+)
+
+// Test runs the CloudImage of opts in containers for the go test t and fails it if the image can not be applied or
+// any check fails. The containers are removed once t and its subtests finish, so that t can check more with the
+// returned harness, like:
+//
+//	func TestMyImage(t *testing.T) {
+//		h := imagetest.Test(t, imagetest.Options{Image: "my-image:latest", Masters: 1, Nodes: 1})
+//		out, err := h.Kubectl()("get deployment my-app -o name")
+//		...
+//	}
+func Test(t testing.TB, opts Options) *Harness {
+	t.Helper()
+	h, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := h.Down(); err != nil {
+			t.Errorf("failed to remove the containers of cluster %s: %v", h.Name(), err)
+		}
+	})
+	if err = h.Up(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err = h.Apply(ctx); err != nil {
+		t.Fatalf("failed to apply %s: %v", opts.Image, err)
+	}
+	results, err := h.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Level == imagelint.LevelError {
+			t.Errorf("check %s failed: %s", r.Check, r.Message)
+		}
+	}
+	if t.Failed() {
+		t.FailNow()
+	}
+	return h
+}
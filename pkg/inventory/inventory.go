@@ -0,0 +1,316 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inventory exports the hosts of a cluster as an Ansible inventory, so the hosts provisioned by sealer
+// can be configured by the playbooks of the users.
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/imdario/mergo"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/pkg/facts"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	FormatINI  = "ini"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+var invalidGroupChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Options select what is exported besides the hosts and roles.
+type Options struct {
+	// Facts are the facts of the hosts by IP, they are exported as the sealer_facts var of the hosts.
+	Facts map[string]*facts.Host
+	// WithPasswords exports the passwords of ssh and sudo, which are omitted by default.
+	WithPasswords bool
+}
+
+// Inventory is the hosts of cluster grouped by their roles.
+type Inventory struct {
+	// Vars are the vars of group all, like the name and image of cluster.
+	Vars map[string]interface{}
+	// Hosts are the vars of the hosts by their names, which are their IPs without the ssh port.
+	Hosts map[string]map[string]interface{}
+	// Groups are the names of the hosts of each role.
+	Groups map[string][]string
+}
+
+// New returns the inventory of cluster, a host is in the group of each of its roles, like master and node.
+func New(cluster *v2.Cluster, opts Options) (*Inventory, error) {
+	inv := &Inventory{
+		Vars: map[string]interface{}{
+			"sealer_cluster_name": cluster.Name,
+			"sealer_image":        cluster.Spec.Image,
+		},
+		Hosts:  map[string]map[string]interface{}{},
+		Groups: map[string][]string{},
+	}
+	for _, host := range cluster.Spec.Hosts {
+		sshSpec := host.SSH
+		if err := mergo.Merge(&sshSpec, &cluster.Spec.SSH); err != nil {
+			return nil, err
+		}
+		for _, ip := range host.IPS {
+			name, vars := hostVars(ip, host, sshSpec, opts)
+			inv.Hosts[name] = vars
+			for _, role := range host.Roles {
+				group := GroupName(role)
+				if utils.NotIn(name, inv.Groups[group]) {
+					inv.Groups[group] = append(inv.Groups[group], name)
+				}
+			}
+		}
+	}
+	return inv, nil
+}
+
+func hostVars(ip string, host v2.Host, sshSpec v1.SSH, opts Options) (string, map[string]interface{}) {
+	port := sshSpec.Port
+	if port == "" {
+		port = "22"
+	}
+	name, port := utils.GetHostIPAndPortOrDefault(ip, port)
+	vars := map[string]interface{}{
+		"ansible_host": name,
+		"ansible_port": port,
+		"sealer_roles": host.Roles,
+	}
+	if sshSpec.User != "" {
+		vars["ansible_user"] = sshSpec.User
+	}
+	if sshSpec.Pk != "" {
+		vars["ansible_ssh_private_key_file"] = sshSpec.Pk
+	}
+	if sshSpec.Sudo {
+		vars["ansible_become"] = true
+	}
+	if sshSpec.Edge {
+		// the edge host is only reachable through the reverse tunnel of sealer.
+		vars["sealer_edge"] = true
+	}
+	if len(host.Labels) > 0 {
+		vars["sealer_labels"] = host.Labels
+	}
+	if opts.WithPasswords {
+		if sshSpec.Passwd != "" {
+			vars["ansible_password"] = sshSpec.Passwd
+		}
+		if sshSpec.SudoPasswd != "" {
+			vars["ansible_become_password"] = sshSpec.SudoPasswd
+		} else if sshSpec.Sudo && sshSpec.Passwd != "" {
+			vars["ansible_become_password"] = sshSpec.Passwd
+		}
+	}
+	if h, ok := opts.Facts[ip]; ok && h != nil {
+		vars["sealer_facts"] = h
+	}
+	return name, vars
+}
+
+// GroupName converts role to a valid name of Ansible group, like node_role_kubernetes_io_ingress.
+func GroupName(role string) string {
+	group := invalidGroupChars.ReplaceAllString(role, "_")
+	if group != "" && group[0] >= '0' && group[0] <= '9' {
+		group = "_" + group
+	}
+	return group
+}
+
+// Marshal encodes the inventory in format, one of ini, yaml and json, json is the output of a dynamic inventory
+// script for --list.
+func (inv *Inventory) Marshal(format string) ([]byte, error) {
+	switch format {
+	case FormatINI:
+		return inv.marshalINI()
+	case FormatYAML:
+		return yaml.Marshal(inv.yamlInventory())
+	case FormatJSON:
+		return json.MarshalIndent(inv.jsonInventory(), "", "  ")
+	}
+	return nil, fmt.Errorf("unsupported inventory format %s, it should be one of ini, yaml and json", format)
+}
+
+func (inv *Inventory) groupNames() []string {
+	groups := make([]string, 0, len(inv.Groups))
+	for g := range inv.Groups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func (inv *Inventory) hostNames() []string {
+	hosts := make([]string, 0, len(inv.Hosts))
+	for h := range inv.Hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func (inv *Inventory) marshalINI() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("[all]\n")
+	for _, h := range inv.hostNames() {
+		line, err := iniVars(inv.Hosts[h])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s %s\n", h, line)
+	}
+	for _, g := range inv.groupNames() {
+		fmt.Fprintf(&buf, "\n[%s]\n", g)
+		for _, h := range inv.Groups[g] {
+			fmt.Fprintln(&buf, h)
+		}
+	}
+	buf.WriteString("\n[all:vars]\n")
+	for _, k := range sortedKeys(inv.Vars) {
+		v, err := iniValue(inv.Vars[k])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", k, v)
+	}
+	return buf.Bytes(), nil
+}
+
+// iniVars encodes vars as the key=value pairs of a host line.
+func iniVars(vars map[string]interface{}) (string, error) {
+	var pairs []string
+	for _, k := range sortedKeys(vars) {
+		v, err := iniValue(vars[k])
+		if err != nil {
+			return "", err
+		}
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// iniValue encodes v as a value of INI inventory, which Ansible splits like shell words and parses as a python
+// literal, so the lists and maps are python literals in double quotes.
+func iniValue(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", err
+	}
+	switch value := value.(type) {
+	case string:
+		if value != "" && !strings.ContainsAny(value, " \t'\"#=;\\") {
+			return value, nil
+		}
+		return shellDoubleQuote(value), nil
+	case map[string]interface{}, []interface{}:
+		return shellDoubleQuote(pythonLiteral(value)), nil
+	}
+	return pythonLiteral(value), nil
+}
+
+// shellDoubleQuote quotes s for the shlex of python, which only escapes the backslash and double quote in them.
+func shellDoubleQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// pythonLiteral encodes the value decoded from json as a python literal.
+func pythonLiteral(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "None"
+	case bool:
+		if value {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`).Replace(value) + "'"
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, pythonLiteral(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		items := make([]string, 0, len(value))
+		for _, k := range sortedKeys(value) {
+			items = append(items, pythonLiteral(k)+": "+pythonLiteral(value[k]))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
+
+func (inv *Inventory) yamlInventory() map[string]interface{} {
+	children := map[string]interface{}{}
+	for _, g := range inv.groupNames() {
+		hosts := map[string]interface{}{}
+		for _, h := range inv.Groups[g] {
+			hosts[h] = nil
+		}
+		children[g] = map[string]interface{}{"hosts": hosts}
+	}
+	return map[string]interface{}{
+		"all": map[string]interface{}{
+			"vars":     inv.Vars,
+			"hosts":    inv.Hosts,
+			"children": children,
+		},
+	}
+}
+
+func (inv *Inventory) jsonInventory() map[string]interface{} {
+	groups := inv.groupNames()
+	out := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": inv.Hosts},
+		"all": map[string]interface{}{
+			"hosts":    inv.hostNames(),
+			"vars":     inv.Vars,
+			"children": groups,
+		},
+	}
+	for _, g := range groups {
+		out[g] = map[string]interface{}{"hosts": inv.Groups[g]}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,128 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/pkg/facts"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func newCluster() *v2.Cluster {
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	cluster.Spec.Image = "kubernetes:v1.19.8"
+	cluster.Spec.SSH = v1.SSH{User: "root", Passwd: "Seal'er123"}
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+		{
+			IPS:    []string{"192.168.0.3:2222"},
+			Roles:  []string{"node", "node-role.kubernetes.io/ingress"},
+			SSH:    v1.SSH{User: "ops", Sudo: true},
+			Labels: map[string]string{"ssd": "true"},
+		},
+	}
+	return cluster
+}
+
+func TestInventory_Marshal(t *testing.T) {
+	inv, err := New(newCluster(), Options{
+		Facts: map[string]*facts.Host{"192.168.0.2": {IP: "192.168.0.2", Arch: "x86_64", CPUs: 4}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ini, err := inv.Marshal(FormatINI)
+	if err != nil {
+		t.Fatalf("Marshal(ini) error = %v", err)
+	}
+	want := `[all]
+192.168.0.2 ansible_host=192.168.0.2 ansible_port=22 ansible_user=root sealer_facts="{'arch': 'x86_64', 'cgroupFs': '', 'cpus': 4, 'gatheredAt': '0001-01-01T00:00:00Z', 'hostname': '', 'ip': '192.168.0.2', 'kernel': '', 'memoryKB': 0, 'os': '', 'osVersion': ''}" sealer_roles="['master']"
+192.168.0.3 ansible_become=True ansible_host=192.168.0.3 ansible_port=2222 ansible_user=ops sealer_labels="{'ssd': 'true'}" sealer_roles="['node', 'node-role.kubernetes.io/ingress']"
+
+[master]
+192.168.0.2
+
+[node]
+192.168.0.3
+
+[node_role_kubernetes_io_ingress]
+192.168.0.3
+
+[all:vars]
+sealer_cluster_name=my-cluster
+sealer_image=kubernetes:v1.19.8
+`
+	if string(ini) != want {
+		t.Errorf("Marshal(ini) = %s, want %s", ini, want)
+	}
+
+	data, err := inv.Marshal(FormatJSON)
+	if err != nil {
+		t.Fatalf("Marshal(json) error = %v", err)
+	}
+	var list struct {
+		Meta struct {
+			HostVars map[string]map[string]interface{} `json:"hostvars"`
+		} `json:"_meta"`
+		Node struct {
+			Hosts []string `json:"hosts"`
+		} `json:"node"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatalf("failed to unmarshal %s: %v", data, err)
+	}
+	if !reflect.DeepEqual(list.Node.Hosts, []string{"192.168.0.3"}) || list.Meta.HostVars["192.168.0.3"]["ansible_port"] != "2222" {
+		t.Errorf("Marshal(json) = %s, want node 192.168.0.3 of port 2222", data)
+	}
+	if _, ok := list.Meta.HostVars["192.168.0.2"]["ansible_password"]; ok {
+		t.Errorf("Marshal(json) = %s, want no password", data)
+	}
+
+	if _, err := inv.Marshal("toml"); err == nil {
+		t.Errorf("Marshal(toml) want error, got nil")
+	}
+}
+
+func TestNew_WithPasswords(t *testing.T) {
+	inv, err := New(newCluster(), Options{WithPasswords: true})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if got := inv.Hosts["192.168.0.3"]["ansible_become_password"]; got != "Seal'er123" {
+		t.Errorf("ansible_become_password = %v, want the password of ssh", got)
+	}
+	v, err := iniValue(inv.Hosts["192.168.0.2"]["ansible_password"])
+	if err != nil || v != `"Seal'er123"` {
+		t.Errorf("iniValue() = %s, %v, want \"Seal'er123\"", v, err)
+	}
+}
+
+func TestGroupName(t *testing.T) {
+	for role, want := range map[string]string{
+		"master":                          "master",
+		"node-role.kubernetes.io/ingress": "node_role_kubernetes_io_ingress",
+		"5g":                              "_5g",
+	} {
+		if got := GroupName(role); got != want {
+			t.Errorf("GroupName(%s) = %s, want %s", role, got, want)
+		}
+	}
+}
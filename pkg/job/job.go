@@ -0,0 +1,235 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// DefaultJobDir keeps one sub dir per job: /var/lib/sealer/jobs/<id>/{job.json,output.log}
+	DefaultJobDir = "/var/lib/sealer/jobs"
+	// EnvJobID is set on the detached sealer process so it can report its result back.
+	EnvJobID    = "SEALER_JOB_ID"
+	jobFileName = "job.json"
+	logFileName = "output.log"
+	jobIDLength = 12
+)
+
+type Status string
+
+const (
+	Running   Status = "Running"
+	Succeeded Status = "Succeeded"
+	Failed    Status = "Failed"
+	// Exited means the process is gone without reporting a result, e.g. it was killed.
+	Exited Status = "Exited"
+)
+
+type Job struct {
+	ID        string    `json:"id"`
+	Args      []string  `json:"args"`
+	WorkDir   string    `json:"workDir,omitempty"`
+	PID       int       `json:"pid"`
+	Status    Status    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime,omitempty"`
+}
+
+// jobRoot is DefaultJobDir, it is only changed by the tests.
+var jobRoot = DefaultJobDir
+
+// jobIDPattern is the ids Start generates, the ids from the command line are checked against it so that they never
+// point outside jobRoot.
+var jobIDPattern = regexp.MustCompile(fmt.Sprintf(`^[0-9a-f]{%d}$`, jobIDLength))
+
+func jobDir(id string) string {
+	return filepath.Join(jobRoot, id)
+}
+
+func validateID(id string) error {
+	if !jobIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid job id %s", id)
+	}
+	return nil
+}
+
+// LogFile returns the file which holds the stdout and stderr of the job, check the id by Get first.
+func LogFile(id string) string {
+	return filepath.Join(jobDir(id), logFileName)
+}
+
+// Start runs sealer with args in a new session detached from the current
+// terminal, so that it survives the exit of the parent shell.
+func Start(args []string) (*Job, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sealer executable path: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Job{
+		ID:        utils.GenUniqueID(jobIDLength),
+		Args:      args,
+		WorkDir:   wd,
+		Status:    Running,
+		StartTime: time.Now(),
+	}
+	if err := os.MkdirAll(jobDir(j.ID), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job dir: %v", err)
+	}
+	logFile, err := os.OpenFile(LogFile(j.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job log file: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(self, args...) // #nosec
+	cmd.Dir = wd
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", EnvJobID, j.ID))
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	// the job file must exist before the child may report its result.
+	if err := save(j); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start job: %v", err)
+	}
+	j.PID = cmd.Process.Pid
+	if err := save(j); err != nil {
+		return nil, err
+	}
+	return j, cmd.Process.Release()
+}
+
+// CurrentID returns the job id of the running process, empty if it is not a detached job.
+func CurrentID() string {
+	return os.Getenv(EnvJobID)
+}
+
+// Finish records the result of the job, it is called by the detached process itself.
+func Finish(id string, result error) error {
+	j, err := load(id)
+	if err != nil {
+		return err
+	}
+	j.Status = Succeeded
+	if result != nil {
+		j.Status = Failed
+		j.Message = result.Error()
+	}
+	j.EndTime = time.Now()
+	return save(j)
+}
+
+// Get returns the job with the given id, refreshing the status of a job whose process has gone.
+func Get(id string) (*Job, error) {
+	j, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+	if j.Status == Running && j.PID != 0 && !processExist(j.PID) {
+		// the job may have reported its result after we loaded it.
+		if j, err = load(id); err != nil {
+			return nil, err
+		}
+		if j.Status == Running {
+			j.Status = Exited
+		}
+	}
+	return j, nil
+}
+
+// List returns all jobs ordered by start time.
+func List() ([]*Job, error) {
+	entries, err := ioutil.ReadDir(jobRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var jobs []*Job
+	for _, e := range entries {
+		if !e.IsDir() || validateID(e.Name()) != nil {
+			continue
+		}
+		j, err := Get(e.Name())
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].StartTime.Before(jobs[k].StartTime)
+	})
+	return jobs, nil
+}
+
+func (j *Job) IsDone() bool {
+	return j.Status != Running
+}
+
+func load(id string) (*Job, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(jobDir(id), jobFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("job %s not found", id)
+		}
+		return nil, err
+	}
+	var j Job
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("failed to decode job %s: %v", id, err)
+	}
+	return &j, nil
+}
+
+func save(j *Job) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temp file then rename, so readers never see a half written file.
+	tmp := filepath.Join(jobDir(j.ID), jobFileName+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to save job %s: %v", j.ID, err)
+	}
+	return os.Rename(tmp, filepath.Join(jobDir(j.ID), jobFileName))
+}
+
+func processExist(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
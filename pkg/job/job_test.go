@@ -0,0 +1,127 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package job
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func setJobRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobRoot = dir
+	t.Cleanup(func() {
+		jobRoot = DefaultJobDir
+		_ = os.RemoveAll(dir)
+	})
+}
+
+func TestStartAndFinish(t *testing.T) {
+	setJobRoot(t)
+	// the detached process is the test binary running no tests.
+	j, err := Start([]string{"-test.run=^$"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if validateID(j.ID) != nil || j.PID == 0 || j.Status != Running {
+		t.Errorf("Start() = %+v, want a running job", j)
+	}
+	if _, err = os.Stat(LogFile(j.ID)); err != nil {
+		t.Errorf("log file of job %s: %v", j.ID, err)
+	}
+
+	if err = Finish(j.ID, errors.New("failed to init master0")); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	got, err := Get(j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != Failed || got.Message != "failed to init master0" || got.EndTime.IsZero() {
+		t.Errorf("Get() after failed Finish = %+v", got)
+	}
+}
+
+func TestGetExited(t *testing.T) {
+	setJobRoot(t)
+	// the pid of a process waited for is gone.
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	id := "0123456789ab"
+	if err := os.MkdirAll(jobDir(id), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := save(&Job{ID: id, PID: cmd.Process.Pid, Status: Running, StartTime: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	j, err := Get(id)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if j.Status != Exited || !j.IsDone() {
+		t.Errorf("status of job gone without result = %s, want %s", j.Status, Exited)
+	}
+	if err = Finish(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ = Get(id); j.Status != Succeeded {
+		t.Errorf("status after Finish = %s, want %s", j.Status, Succeeded)
+	}
+}
+
+func TestList(t *testing.T) {
+	setJobRoot(t)
+	now := time.Now()
+	for i, id := range []string{"bbbbbbbbbbbb", "aaaaaaaaaaaa"} {
+		if err := os.MkdirAll(jobDir(id), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := save(&Job{ID: id, Status: Succeeded, StartTime: now.Add(time.Duration(i) * time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// not a job, it is skipped.
+	if err := os.MkdirAll(jobDir("tmp"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != "bbbbbbbbbbbb" || jobs[1].ID != "aaaaaaaaaaaa" {
+		t.Errorf("List() = %+v, want the jobs by start time", jobs)
+	}
+}
+
+func TestGetInvalidID(t *testing.T) {
+	setJobRoot(t)
+	for _, id := range []string{"../../etc", "", "ABCDEF012345", "0123456789abc", "/etc/passwd"} {
+		if _, err := Get(id); err == nil {
+			t.Errorf("Get(%q) should fail", id)
+		}
+		if err := Finish(id, nil); err == nil {
+			t.Errorf("Finish(%q) should fail", id)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// Job is the job label of the metrics pushed to the pushgateway.
+const Job = "sealer"
+
+// Registry holds the metrics of sealer, it is served by Serve and pushed by Push.
+var Registry = prometheus.NewRegistry()
+
+var (
+	phaseDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sealer_phase_duration_seconds",
+		Help: "Duration of the phases of the operations on clusters.",
+	}, []string{"cluster", "operation", "phase"})
+	hostPhaseDuration = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sealer_host_phase_duration_seconds_total",
+		Help: "Time spent in the remote commands and file transfers of each host in the phases.",
+	}, []string{"cluster", "phase", "host"})
+	transferredBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sealer_transferred_bytes_total",
+		Help: "Bytes of the files sent to or fetched from each host.",
+	}, []string{"cluster", "host", "direction"})
+	retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sealer_retries_total",
+		Help: "Retries of the actions on each host, like connecting it by ssh.",
+	}, []string{"cluster", "host", "action"})
+	registryProxyRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sealer_registry_proxy_requests",
+		Help: "Requests of the blobs and manifests served by the registry proxies from cache (hit) or upstream (miss) since they started.",
+	}, []string{"cluster", "upstream", "kind", "result"})
+)
+
+func init() {
+	Registry.MustRegister(phaseDuration, hostPhaseDuration, transferredBytes, retries, registryProxyRequests)
+}
+
+type phaseKey struct{}
+
+type phase struct {
+	cluster string
+	name    string
+}
+
+// WithPhase returns the context of the phase of cluster, the remote commands and file transfers with it are
+// counted into the phase.
+func WithPhase(ctx context.Context, cluster, name string) context.Context {
+	return context.WithValue(ctx, phaseKey{}, phase{cluster: cluster, name: name})
+}
+
+// PhaseOf returns the cluster and the name of the phase which ctx is of.
+func PhaseOf(ctx context.Context) (cluster, name string, ok bool) {
+	p, ok := phaseOf(ctx)
+	return p.cluster, p.name, ok
+}
+
+func phaseOf(ctx context.Context) (phase, bool) {
+	p, ok := ctx.Value(phaseKey{}).(phase)
+	return p, ok
+}
+
+func ObservePhase(cluster, operation, phase string, duration time.Duration) {
+	phaseDuration.WithLabelValues(cluster, operation, phase).Set(duration.Seconds())
+}
+
+func ObserveRetry(cluster, host, action string) {
+	retries.WithLabelValues(cluster, host, action).Inc()
+}
+
+func ObserveRegistryProxy(cluster, upstream, kind string, hits, misses uint64) {
+	registryProxyRequests.WithLabelValues(cluster, upstream, kind, "hit").Set(float64(hits))
+	registryProxyRequests.WithLabelValues(cluster, upstream, kind, "miss").Set(float64(misses))
+}
+
+type sshObserver struct{}
+
+func (sshObserver) ObserveCommand(ctx context.Context, host string, duration time.Duration) {
+	if p, ok := phaseOf(ctx); ok {
+		hostPhaseDuration.WithLabelValues(p.cluster, p.name, host).Add(duration.Seconds())
+	}
+}
+
+func (sshObserver) ObserveTransfer(ctx context.Context, host, direction string, bytes int64, duration time.Duration) {
+	p, ok := phaseOf(ctx)
+	if !ok {
+		return
+	}
+	hostPhaseDuration.WithLabelValues(p.cluster, p.name, host).Add(duration.Seconds())
+	transferredBytes.WithLabelValues(p.cluster, host, direction).Add(float64(bytes))
+}
+
+var enabled bool
+
+// Enable starts collecting the metrics of the remote commands and file transfers.
+func Enable() {
+	enabled = true
+	ssh.SetObserver(sshObserver{})
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+// Serve serves the metrics on http://addr/metrics until the returned func is called.
+func Serve(addr string) (func(), error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen metrics address %s: %v", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			logger.Warn("failed to serve metrics: %v", err)
+		}
+	}()
+	logger.Info("serving metrics on http://%s/metrics", l.Addr())
+	return func() {
+		_ = server.Close()
+	}, nil
+}
+
+// Push replaces the metrics of the sealer job and the instance in the pushgateway at gateway.
+func Push(gateway, instance string) error {
+	families, err := Registry.Gather()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := expfmt.NewEncoder(&buf, expfmt.FmtText)
+	for _, f := range families {
+		if err = enc.Encode(f); err != nil {
+			return err
+		}
+	}
+	u := fmt.Sprintf("%s/metrics/job/%s/instance/%s", gateway, Job, url.PathEscape(instance))
+	req, err := http.NewRequest(http.MethodPut, u, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(expfmt.FmtText))
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %v", gateway, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to push metrics to %s: unexpected status %s", gateway, resp.Status)
+	}
+	return nil
+}
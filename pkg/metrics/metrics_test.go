@@ -0,0 +1,64 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+func TestPush(t *testing.T) {
+	ctx := WithPhase(context.Background(), "my-cluster", "Init")
+	sshObserver{}.ObserveTransfer(ctx, "192.168.0.2", ssh.DirectionUpload, 1024, time.Second)
+	sshObserver{}.ObserveTransfer(context.Background(), "192.168.0.3", ssh.DirectionUpload, 1024, time.Second)
+	ObservePhase("my-cluster", "create", "Init", 2*time.Second)
+
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		path = r.URL.Path
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	if err := Push(server.URL, "node-1"); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if path != "/metrics/job/sealer/instance/node-1" {
+		t.Errorf("Push() path = %s", path)
+	}
+	for _, want := range []string{
+		`sealer_transferred_bytes_total{cluster="my-cluster",direction="upload",host="192.168.0.2"} 1024`,
+		`sealer_host_phase_duration_seconds_total{cluster="my-cluster",host="192.168.0.2",phase="Init"} 1`,
+		`sealer_phase_duration_seconds{cluster="my-cluster",operation="create",phase="Init"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Push() body misses %s, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "192.168.0.3") {
+		t.Errorf("Push() body contains transfer outside of a phase:\n%s", body)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/registries"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	DefaultPort     = 65001
+	DefaultManifest = "manifests/p2p.yaml"
+	renderedSuffix  = ".rendered"
+	// containerd pulls the images of the sealer registry from the agent first, then the registry itself,
+	// the empty config_path of containerd config is set to certs.d, and it fails if containerd does not read it.
+	RemoteWriteContainerdHosts = `if [ -d /etc/containerd ]; then ` + registries.RemoteEnableContainerdConfigPath + ` && ` +
+		registries.RemoteCheckContainerdConfigPath + ` && ` +
+		`mkdir -p ` + registries.ContainerdCertsDir + `/%[1]s && echo '%[2]s' > ` + registries.ContainerdCertsDir + `/%[1]s/hosts.toml; fi`
+	containerdHostsTemplate = `server = "https://%s"
+
+[host."%s"]
+  capabilities = ["pull", "resolve"]
+`
+	RemoteApplyManifest = "kubectl apply -f %s"
+)
+
+// ManifestData is used to render the P2P manifest of CloudImage, like {{.Port}} and {{.Registry}}.
+type ManifestData struct {
+	// Port the agent serves the registry mirror on every host
+	Port int
+	// Registry is the domain and port of the sealer registry, like sea.hub:5000
+	Registry string
+}
+
+func port(spec v2.P2PSpec) int {
+	if spec.Port == 0 {
+		return DefaultPort
+	}
+	return spec.Port
+}
+
+func mirror(spec v2.P2PSpec) string {
+	return fmt.Sprintf("http://127.0.0.1:%d", port(spec))
+}
+
+func registry(cluster *v2.Cluster) string {
+	cf := runtime.GetRegistryConfig(common.DefaultTheClusterRootfsDir(cluster.Name), cluster.GetMaster0Ip())
+	return fmt.Sprintf("%s:%s", cf.Domain, cf.Port)
+}
+
+// WriteDockerMirror puts the agent before the sealer registry in the wildcard mirrors of etc/daemon.json in rootfs,
+// docker falls back to the registry if the agent is not running yet, like on the hosts to join.
+func WriteDockerMirror(cluster *v2.Cluster, rootfs string) error {
+	spec := cluster.Spec.P2P
+	if !spec.Enabled {
+		return nil
+	}
+	agent := mirror(spec)
+	return runtime.UpdateDockerMirrors(rootfs, func(mirrors []runtime.DockerMirror) []runtime.DockerMirror {
+		for i := range mirrors {
+			if mirrors[i].Domain == "*" && !utils.InList(agent, mirrors[i].Mirrors) {
+				mirrors[i].Mirrors = append([]string{agent}, mirrors[i].Mirrors...)
+			}
+		}
+		return mirrors
+	})
+}
+
+// ConfigureHosts configs containerd of hosts to pull the images of the sealer registry through the agent.
+func ConfigureHosts(cluster *v2.Cluster, hosts []string) error {
+	spec := cluster.Spec.P2P
+	if !spec.Enabled || len(hosts) == 0 {
+		return nil
+	}
+	reg := registry(cluster)
+	cmd := fmt.Sprintf(RemoteWriteContainerdHosts, reg, fmt.Sprintf(containerdHostsTemplate, reg, mirror(spec)))
+
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			sshClient, err := ssh.GetHostSSHClient(ip, cluster)
+			if err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("get host ssh client failed %v", err))
+				return
+			}
+			if err := sshClient.CmdAsync(ip, cmd); err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("failed to config p2p mirror on %s: %v", ip, err))
+			}
+		}(h)
+	}
+	wg.Wait()
+	return runtime.ReadChanError(errCh)
+}
+
+// Deploy renders the P2P manifest of CloudImage and applies it on master0, it must run after kubeadm init.
+func Deploy(cluster *v2.Cluster) error {
+	spec := cluster.Spec.P2P
+	if !spec.Enabled {
+		return nil
+	}
+	manifest := spec.Manifest
+	if manifest == "" {
+		manifest = DefaultManifest
+	}
+	src := filepath.Join(common.DefaultMountCloudImageDir(cluster.Name), manifest)
+	if !utils.IsFileExist(src) {
+		return fmt.Errorf("p2p is enabled but %s is not found in CloudImage %s", manifest, cluster.Spec.Image)
+	}
+	data, err := renderManifest(src, ManifestData{Port: port(spec), Registry: registry(cluster)})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile("", "sealer-p2p")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	master0 := cluster.GetMaster0Ip()
+	sshClient, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return fmt.Errorf("get host ssh client failed %v", err)
+	}
+	dst := filepath.Join(common.DefaultTheClusterRootfsDir(cluster.Name), manifest+renderedSuffix)
+	if err := sshClient.Copy(master0, tmp.Name(), dst); err != nil {
+		return fmt.Errorf("failed to copy p2p manifest to %s: %v", master0, err)
+	}
+	logger.Info("start to deploy p2p image distribution of %s", manifest)
+	return sshClient.CmdAsync(master0, fmt.Sprintf(RemoteApplyManifest, dst))
+}
+
+func renderManifest(path string, data ManifestData) ([]byte, error) {
+	t, err := template.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse p2p manifest %s: %v", path, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render p2p manifest %s: %v", path, err)
+	}
+	return buf.Bytes(), nil
+}
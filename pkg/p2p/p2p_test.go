@@ -0,0 +1,124 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package p2p
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestRenderManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+		wantErr  bool
+	}{
+		{"vars", "port: {{.Port}}\nregistry: {{.Registry}}\n", "port: 65001\nregistry: sea.hub:5000\n", false},
+		{"no vars", "kind: DaemonSet\n", "kind: DaemonSet\n", false},
+		{"parse error", "port: {{.Port\n", "", true},
+		{"unknown field", "{{.Image}}\n", "", true},
+		{"not found", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "p2p.yaml")
+			if tt.manifest != "" {
+				if err := ioutil.WriteFile(path, []byte(tt.manifest), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := renderManifest(path, ManifestData{Port: DefaultPort, Registry: "sea.hub:5000"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("renderManifest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDockerMirror(t *testing.T) {
+	const daemon = `{"mirror-registries":[{"domain":"*","mirrors":["https://sea.hub:5000"]},` +
+		`{"domain":"quay.io","mirrors":["https://sea.hub:5000"]}]}`
+	tests := []struct {
+		name   string
+		spec   v2.P2PSpec
+		daemon string
+		want   []map[string]interface{}
+	}{
+		{"default port", v2.P2PSpec{Enabled: true}, daemon, []map[string]interface{}{
+			{"domain": "*", "mirrors": []interface{}{"http://127.0.0.1:65001", "https://sea.hub:5000"}},
+			{"domain": "quay.io", "mirrors": []interface{}{"https://sea.hub:5000"}},
+		}},
+		{"port", v2.P2PSpec{Enabled: true, Port: 5001}, daemon, []map[string]interface{}{
+			{"domain": "*", "mirrors": []interface{}{"http://127.0.0.1:5001", "https://sea.hub:5000"}},
+			{"domain": "quay.io", "mirrors": []interface{}{"https://sea.hub:5000"}},
+		}},
+		{"disabled", v2.P2PSpec{}, daemon, []map[string]interface{}{
+			{"domain": "*", "mirrors": []interface{}{"https://sea.hub:5000"}},
+			{"domain": "quay.io", "mirrors": []interface{}{"https://sea.hub:5000"}},
+		}},
+		{"no daemon.json", v2.P2PSpec{Enabled: true}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rootfs := t.TempDir()
+			daemonFile := filepath.Join(rootfs, "etc", "daemon.json")
+			if tt.daemon != "" {
+				if err := os.MkdirAll(filepath.Dir(daemonFile), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(daemonFile, []byte(tt.daemon), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cluster := &v2.Cluster{}
+			cluster.Spec.P2P = tt.spec
+			// the agent is put before the registry only once however many times it runs
+			for i := 0; i < 2; i++ {
+				if err := WriteDockerMirror(cluster, rootfs); err != nil {
+					t.Fatalf("WriteDockerMirror() error = %v", err)
+				}
+			}
+			if tt.daemon == "" {
+				if _, err := os.Stat(daemonFile); !os.IsNotExist(err) {
+					t.Errorf("expected no %s, got %v", daemonFile, err)
+				}
+				return
+			}
+			data, err := ioutil.ReadFile(daemonFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Mirrors []map[string]interface{} `json:"mirror-registries"`
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Mirrors, tt.want) {
+				t.Errorf("mirror-registries = %v, want %v", got.Mirrors, tt.want)
+			}
+		})
+	}
+}
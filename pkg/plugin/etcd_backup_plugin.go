@@ -28,6 +28,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils/ssh"
 )
 
@@ -43,12 +44,17 @@ func init() {
 }
 
 func (e EtcdBackupPlugin) Run(context Context, phase Phase) error {
-	masterIP, err := getMasterIP(context)
+	return SnapshotEtcd(context.Cluster, context.Plugin.Spec.On)
+}
+
+// SnapshotEtcd saves the snapshot of etcd on master0 of cluster to snapshotPath on the local host.
+func SnapshotEtcd(cluster *v2.Cluster, snapshotPath string) error {
+	masterIP, err := getMasterIP(cluster)
 	if err != nil {
 		return err
 	}
 
-	if err := fetchRemoteCert(context, masterIP); err != nil {
+	if err := fetchRemoteCert(cluster, masterIP); err != nil {
 		return err
 	}
 
@@ -57,21 +63,21 @@ func (e EtcdBackupPlugin) Run(context Context, phase Phase) error {
 		return err
 	}
 
-	return snapshotEtcd(context.Plugin.Spec.On, cfg)
+	return snapshotEtcd(snapshotPath, cfg)
 }
 
-func getMasterIP(context Context) (string, error) {
-	masterIPList := context.Cluster.GetMasterIPList()
+func getMasterIP(cluster *v2.Cluster) (string, error) {
+	masterIPList := cluster.GetMasterIPList()
 	if len(masterIPList) == 0 {
 		return "", errors.New("cluster master does not exist")
 	}
 	return masterIPList[0], nil
 }
 
-func fetchRemoteCert(context Context, masterIP string) error {
+func fetchRemoteCert(cluster *v2.Cluster, masterIP string) error {
 	certs := []string{"healthcheck-client.crt", "healthcheck-client.key", "ca.crt"}
 	for _, cert := range certs {
-		sshClient, err := ssh.GetHostSSHClient(masterIP, context.Cluster)
+		sshClient, err := ssh.GetHostSSHClient(masterIP, cluster)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,205 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+/*
+LOADBALANCER plugin registers the apiserver endpoints of the masters on an external load balancer
+after the cluster is installed, and keeps the membership in sync when masters are joined or deleted.
+
+apiVersion: sealer.aliyun.com/v1alpha1
+kind: Plugin
+metadata:
+  name: f5
+spec:
+  type: LOADBALANCER
+  action: PostInstall
+  data: |
+    provider: shell
+    port: 6443
+    register: curl -sf -X POST https://f5.example.com/pool/apiserver/members -d "$SEALER_LB_ENDPOINT"
+    deregister: curl -sf -X DELETE https://f5.example.com/pool/apiserver/members/$SEALER_LB_ENDPOINT
+
+Other providers for F5, NGINX or HAProxy appliances can be compiled in-tree or loaded from an
+out-of-tree plugin (.so) calling RegisterLoadBalancerProvider in its init function.
+*/
+
+const (
+	DefaultLoadBalancerProvider = "shell"
+	DefaultAPIServerPort        = "6443"
+	// EnvLoadBalancerEndpoint is the "ip:port" of the endpoint to (de)register, for the shell provider.
+	EnvLoadBalancerEndpoint = "SEALER_LB_ENDPOINT"
+)
+
+// LoadBalancerProvider manages the membership of apiserver endpoints on an external load balancer.
+type LoadBalancerProvider interface {
+	Register(endpoints []string) error
+	Deregister(endpoints []string) error
+}
+
+// LoadBalancerConfig is the data of a LOADBALANCER plugin.
+type LoadBalancerConfig struct {
+	Provider   string            `json:"provider,omitempty"`
+	Port       string            `json:"port,omitempty"`
+	Register   string            `json:"register,omitempty"`
+	Deregister string            `json:"deregister,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+}
+
+type LoadBalancerProviderFactory func(config *LoadBalancerConfig) (LoadBalancerProvider, error)
+
+var loadBalancerProviders = map[string]LoadBalancerProviderFactory{
+	DefaultLoadBalancerProvider: newShellLoadBalancer,
+}
+
+// RegisterLoadBalancerProvider makes a load balancer provider available by name.
+func RegisterLoadBalancerProvider(name string, factory LoadBalancerProviderFactory) {
+	if factory == nil {
+		panic("Must not provide nil LoadBalancerProviderFactory")
+	}
+	if _, registered := loadBalancerProviders[name]; registered {
+		panic(fmt.Sprintf("load balancer provider named %s already registered", name))
+	}
+	loadBalancerProviders[name] = factory
+}
+
+type LoadBalancer struct{}
+
+func init() {
+	Register(LoadBalancerPlugin, &LoadBalancer{})
+}
+
+func (l LoadBalancer) Run(context Context, phase Phase) error {
+	if context.Plugin.Spec.Type != LoadBalancerPlugin {
+		return nil
+	}
+	provider, config, err := newLoadBalancerProvider(context.Plugin)
+	if err != nil {
+		return err
+	}
+	endpoints := loadBalancerEndpoints(context.Cluster.GetMasterIPList(), config.Port)
+	if phase == PhasePostClean {
+		return provider.Deregister(endpoints)
+	}
+	return provider.Register(endpoints)
+}
+
+// ScaleLoadBalancer registers the joined masters and deregisters the deleted masters on all
+// load balancers configured by LOADBALANCER plugins in the cluster rootfs.
+func ScaleLoadBalancer(cluster *v2.Cluster, mastersToJoin, mastersToDelete []string) error {
+	if len(mastersToJoin) == 0 && len(mastersToDelete) == 0 {
+		return nil
+	}
+	plugins := &PluginsProcessor{ClusterName: cluster.Name}
+	if err := plugins.Load(); err != nil {
+		return err
+	}
+	for i := range plugins.Plugins {
+		if plugins.Plugins[i].Spec.Type != LoadBalancerPlugin {
+			continue
+		}
+		provider, config, err := newLoadBalancerProvider(&plugins.Plugins[i])
+		if err != nil {
+			return err
+		}
+		if err := provider.Deregister(loadBalancerEndpoints(mastersToDelete, config.Port)); err != nil {
+			return fmt.Errorf("failed to deregister masters from load balancer %s: %v", plugins.Plugins[i].Name, err)
+		}
+		if err := provider.Register(loadBalancerEndpoints(mastersToJoin, config.Port)); err != nil {
+			return fmt.Errorf("failed to register masters to load balancer %s: %v", plugins.Plugins[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func newLoadBalancerProvider(p *v1.Plugin) (LoadBalancerProvider, *LoadBalancerConfig, error) {
+	config := &LoadBalancerConfig{}
+	if err := yaml.Unmarshal([]byte(p.Spec.Data), config); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode load balancer plugin %s: %v", p.Name, err)
+	}
+	if config.Provider == "" {
+		config.Provider = DefaultLoadBalancerProvider
+	}
+	if config.Port == "" {
+		config.Port = DefaultAPIServerPort
+	}
+	factory, ok := loadBalancerProviders[config.Provider]
+	if !ok {
+		return nil, nil, fmt.Errorf("load balancer provider not registered: %s", config.Provider)
+	}
+	provider, err := factory(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return provider, config, nil
+}
+
+func loadBalancerEndpoints(masters []string, port string) []string {
+	var endpoints []string
+	for _, m := range masters {
+		endpoints = append(endpoints, fmt.Sprintf("%s:%s", utils.GetHostIP(m), port))
+	}
+	return endpoints
+}
+
+// shellLoadBalancer runs the register and deregister commands on the local host once per endpoint.
+type shellLoadBalancer struct {
+	register   string
+	deregister string
+}
+
+func newShellLoadBalancer(config *LoadBalancerConfig) (LoadBalancerProvider, error) {
+	if config.Register == "" && config.Deregister == "" {
+		return nil, fmt.Errorf("register or deregister command is required by the shell load balancer provider")
+	}
+	return &shellLoadBalancer{register: config.Register, deregister: config.Deregister}, nil
+}
+
+func (s *shellLoadBalancer) Register(endpoints []string) error {
+	return s.run(s.register, endpoints)
+}
+
+func (s *shellLoadBalancer) Deregister(endpoints []string) error {
+	return s.run(s.deregister, endpoints)
+}
+
+func (s *shellLoadBalancer) run(cmd string, endpoints []string) error {
+	if strings.TrimSpace(cmd) == "" {
+		return nil
+	}
+	for _, ep := range endpoints {
+		c := exec.Command("/bin/sh", "-c", cmd) // #nosec
+		c.Env = append(os.Environ(), fmt.Sprintf("%s=%s", EnvLoadBalancerEndpoint, ep))
+		out, err := c.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to run load balancer command for %s: %v, %s", ep, err, out)
+		}
+		logger.Info("load balancer command succeeded for %s", ep)
+	}
+	return nil
+}
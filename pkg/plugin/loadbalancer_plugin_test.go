@@ -0,0 +1,75 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestNewLoadBalancerProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantPort string
+		wantErr  bool
+	}{
+		{"default provider and port", "register: echo $SEALER_LB_ENDPOINT", DefaultAPIServerPort, false},
+		{"custom port", "provider: shell\nport: \"8443\"\nderegister: echo", "8443", false},
+		{"no command", "provider: shell", "", true},
+		{"unknown provider", "provider: f5", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &v1.Plugin{Spec: v1.PluginSpec{Type: LoadBalancerPlugin, Data: tt.data}}
+			_, config, err := newLoadBalancerProvider(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLoadBalancerProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && config.Port != tt.wantPort {
+				t.Errorf("newLoadBalancerProvider() port = %s, want %s", config.Port, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestShellLoadBalancer(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "members")
+	lb, err := newShellLoadBalancer(&LoadBalancerConfig{Register: "echo $SEALER_LB_ENDPOINT >> " + out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := loadBalancerEndpoints([]string{"192.168.0.2", "192.168.0.3:22"}, "6443")
+	if err := lb.Register(endpoints); err != nil {
+		t.Fatal(err)
+	}
+	// deregister command is empty, nothing to do.
+	if err := lb.Deregister(endpoints); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"192.168.0.2:6443", "192.168.0.3:6443"}
+	if got := strings.Fields(string(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("registered endpoints = %v, want %v", got, want)
+	}
+}
@@ -43,6 +43,7 @@ const (
 	ShellPlugin        = "SHELL"
 	HostNamePlugin     = "HOSTNAME"
 	ClusterCheckPlugin = "CLUSTERCHECK"
+	LoadBalancerPlugin = "LOADBALANCER"
 )
 
 const (
@@ -16,9 +16,11 @@ package plugin
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/alibaba/sealer/pkg/env"
+	"github.com/alibaba/sealer/pkg/facts"
 
 	"github.com/alibaba/sealer/utils"
 
@@ -72,7 +74,11 @@ func (s Sheller) Run(context Context, phase Phase) error {
 		if err != nil {
 			return err
 		}
-		err = sshClient.CmdAsync(ip, envProcessor.WrapperShell(ip, pluginCmd))
+		h, err := facts.Get(context.Cluster, ip)
+		if err != nil {
+			return err
+		}
+		err = sshClient.CmdAsync(ip, wrapFactsEnv(h, envProcessor.WrapperShell(ip, pluginCmd)))
 		if err != nil {
 			return fmt.Errorf("failed to run shell cmd,  %v", err)
 		}
@@ -80,6 +86,21 @@ func (s Sheller) Run(context Context, phase Phase) error {
 	return nil
 }
 
+// wrapFactsEnv sets the facts of host, like HOST_ARCH and HOST_OS, before shell, the env of Clusterfile overrides them.
+func wrapFactsEnv(h *facts.Host, shell string) string {
+	env := h.Env()
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var assigns []string
+	for _, k := range keys {
+		assigns = append(assigns, fmt.Sprintf("%s='%s'", k, strings.ReplaceAll(env[k], "'", `'\''`)))
+	}
+	return fmt.Sprintf("%s && %s", strings.Join(assigns, " "), shell)
+}
+
 func init() {
 	Register(ShellPlugin, &Sheller{})
 }
@@ -0,0 +1,66 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preload
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// PreloadDir holds the image tarballs in rootfs, like images/preload/nginx.tar
+	PreloadDir = "images/preload"
+	// import the tarballs into docker if it is running, otherwise into the k8s.io namespace of containerd used by kubelet.
+	RemoteImportImages = `for f in %s/*.tar; do [ -f "$f" ] || continue; echo "import $f"; ` +
+		`if docker info >/dev/null 2>&1; then docker load -q -i "$f"; else ctr -n k8s.io images import "$f"; fi || exit 1; done`
+)
+
+// Import imports the image tarballs of rootfs into the container runtime of hosts in parallel,
+// if image preload is enabled in Clusterfile. It must run after the rootfs is mounted on hosts.
+func Import(cluster *v2.Cluster, hosts []string) error {
+	if !cluster.Spec.ImagePreload.Enabled || len(hosts) == 0 {
+		return nil
+	}
+	dir := filepath.Join(common.DefaultTheClusterRootfsDir(cluster.Name), PreloadDir)
+	logger.Info("start to import the images in %s on %v", dir, hosts)
+
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			sshClient, err := ssh.GetHostSSHClient(ip, cluster)
+			if err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("get host ssh client failed %v", err))
+				return
+			}
+			if err := sshClient.CmdAsync(ip, fmt.Sprintf(RemoteImportImages, dir)); err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("failed to import images on %s: %v", ip, err))
+			}
+		}(h)
+	}
+	wg.Wait()
+	return runtime.ReadChanError(errCh)
+}
@@ -0,0 +1,97 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRuntime writes docker and ctr to a dir, which log their args, docker info fails unless dockerUp.
+func fakeRuntime(t *testing.T, dockerUp bool) (bin, log string) {
+	bin = t.TempDir()
+	log = filepath.Join(bin, "log")
+	info := "exit 1"
+	if dockerUp {
+		info = "exit 0"
+	}
+	scripts := map[string]string{
+		"docker": fmt.Sprintf("#!/bin/sh\n[ \"$1\" = info ] && %s\necho \"docker $*\" >> %s\n", info, log),
+		"ctr":    fmt.Sprintf("#!/bin/sh\necho \"ctr $*\" >> %s\n", log),
+	}
+	for name, script := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bin, log
+}
+
+func TestRemoteImportImages(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+	dir := t.TempDir()
+	for _, f := range []string{"nginx.tar", "busybox.tar", "README.md", "coredns.tar.gz"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the regular tarballs are imported.
+	if err := os.Mkdir(filepath.Join(dir, "charts.tar"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		dir      string
+		dockerUp bool
+		want     []string
+	}{
+		{"docker", dir, true, []string{
+			"docker load -q -i " + filepath.Join(dir, "busybox.tar"),
+			"docker load -q -i " + filepath.Join(dir, "nginx.tar"),
+		}},
+		{"containerd", dir, false, []string{
+			"ctr -n k8s.io images import " + filepath.Join(dir, "busybox.tar"),
+			"ctr -n k8s.io images import " + filepath.Join(dir, "nginx.tar"),
+		}},
+		{"no tarballs", t.TempDir(), true, nil},
+		{"no preload dir", filepath.Join(dir, "not-exist"), true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin, log := fakeRuntime(t, tt.dockerUp)
+			cmd := exec.Command("bash", "-c", fmt.Sprintf(RemoteImportImages, tt.dir)) // #nosec
+			cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("import images error = %v, %s", err, out)
+			}
+			var got []string
+			if data, err := ioutil.ReadFile(log); err == nil {
+				got = strings.Split(strings.TrimSpace(string(data)), "\n")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sealer/pkg/tracing"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// The categories of the time of a host, which are the columns of the breakdown of hosts.
+const (
+	CategoryCopy       = "copy"
+	CategoryImageLoad  = "image load"
+	CategoryInit       = "init"
+	CategoryJoin       = "join"
+	CategoryHealthWait = "health wait"
+	CategoryOther      = "other"
+)
+
+var Categories = []string{CategoryCopy, CategoryImageLoad, CategoryInit, CategoryJoin, CategoryHealthWait, CategoryOther}
+
+// imageLoadPhases are the phases loading the container images on the hosts, all their time of a host is image load.
+var imageLoadPhases = map[string]bool{
+	"PreloadImages": true,
+	"ConfigureP2P":  true,
+	"DeployP2P":     true,
+}
+
+// hostStepCategories are the categories of the steps the runtime runs on each host.
+var hostStepCategories = map[string]string{
+	"init master0":   CategoryInit,
+	"join master":    CategoryJoin,
+	"join node":      CategoryJoin,
+	"wait ssh ready": CategoryHealthWait,
+}
+
+type Phase struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Host is the time of a host in each category, summed over the spans of the host which may run in parallel.
+type Host struct {
+	Name       string                   `json:"name"`
+	Categories map[string]time.Duration `json:"categories"`
+}
+
+// Total is the sum of the time of h in all categories.
+func (h Host) Total() time.Duration {
+	var total time.Duration
+	for _, d := range h.Categories {
+		total += d
+	}
+	return total
+}
+
+// Node is the time of the spans of the same name under the same stack, summed over the hosts.
+type Node struct {
+	Name     string        `json:"name"`
+	Count    int           `json:"count"`
+	Duration time.Duration `json:"duration"`
+	Children []*Node       `json:"children,omitempty"`
+}
+
+func (n *Node) child(name string) *Node {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &Node{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// Self is the time of n not spent in its children, which is zero if the children ran in parallel.
+func (n *Node) Self() time.Duration {
+	self := n.Duration
+	for _, c := range n.Children {
+		self -= c.Duration
+	}
+	if self < 0 {
+		return 0
+	}
+	return self
+}
+
+// Profiler follows the spans of the pipelines and the ssh clients, and measures the time of the phases, of each host
+// in each category and of each stack of spans. It is a tracing.Observer.
+type Profiler struct {
+	lock      sync.Mutex
+	operation string
+	cluster   string
+	start     time.Time
+	end       time.Time
+	phases    []Phase
+	hosts     []*Host
+	root      *Node
+	started   map[*tracing.SpanInfo]time.Time
+	now       func() time.Time
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{root: &Node{}, started: make(map[*tracing.SpanInfo]time.Time), now: time.Now, start: time.Now()}
+}
+
+func (p *Profiler) host(name string) *Host {
+	for _, h := range p.hosts {
+		if h.Name == name {
+			return h
+		}
+	}
+	h := &Host{Name: name, Categories: make(map[string]time.Duration)}
+	p.hosts = append(p.hosts, h)
+	return h
+}
+
+// isPhase returns whether s is a phase of a pipeline, which is the child of the operation.
+func isPhase(s *tracing.SpanInfo) bool {
+	return s.Attr("operation") != "" && s.Attr("cluster") != ""
+}
+
+// categoryOf returns the category of the host span s, by the phase or the host step it runs in.
+func categoryOf(s *tracing.SpanInfo) string {
+	for p := s; p != nil; p = p.Parent {
+		if category, ok := hostStepCategories[p.Name]; ok {
+			return category
+		}
+		if isPhase(p) {
+			if imageLoadPhases[p.Name] {
+				return CategoryImageLoad
+			}
+			break
+		}
+	}
+	if s.Name == "ssh "+ssh.DirectionUpload {
+		return CategoryCopy
+	}
+	return CategoryOther
+}
+
+// isTopHostSpan returns whether s is a span of a host not in another span of a host, only the time of which is
+// counted for the host.
+func isTopHostSpan(s *tracing.SpanInfo) bool {
+	if s.Attr("host") == "" {
+		return false
+	}
+	for p := s.Parent; p != nil; p = p.Parent {
+		if p.Attr("host") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Profiler) SpanStarted(s *tracing.SpanInfo) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if isPhase(s) {
+		p.cluster = s.Attr("cluster")
+		p.operation = s.Attr("operation")
+	}
+	p.started[s] = p.now()
+}
+
+func (p *Profiler) SpanEnded(s *tracing.SpanInfo, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	start, ok := p.started[s]
+	if !ok {
+		return
+	}
+	delete(p.started, s)
+	d := p.now().Sub(start)
+
+	if isPhase(s) {
+		p.phases = append(p.phases, Phase{Name: s.Name, Start: start, Duration: d})
+	}
+	if isTopHostSpan(s) {
+		p.host(s.Attr("host")).Categories[categoryOf(s)] += d
+	}
+	n := p.root
+	for _, name := range stack(s) {
+		n = n.child(name)
+	}
+	n.Count++
+	n.Duration += d
+}
+
+// stack returns the names of the spans from the root to s.
+func stack(s *tracing.SpanInfo) []string {
+	var names []string
+	for p := s; p != nil; p = p.Parent {
+		names = append(names, p.Name)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+// Finish ends the profile once the operation finished.
+func (p *Profiler) Finish() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.end = p.now()
+}
+
+// Report is the profile of an operation.
+type Report struct {
+	Operation string        `json:"operation"`
+	Cluster   string        `json:"cluster"`
+	Duration  time.Duration `json:"duration"`
+	Phases    []Phase       `json:"phases"`
+	Hosts     []Host        `json:"hosts"`
+	// Spans are the roots of the stacks of spans, like the operation.
+	Spans []*Node `json:"spans"`
+}
+
+func (p *Profiler) Report() *Report {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	end := p.end
+	if end.IsZero() {
+		end = p.now()
+	}
+	r := &Report{
+		Operation: p.operation,
+		Cluster:   p.cluster,
+		Duration:  end.Sub(p.start),
+		Phases:    append([]Phase(nil), p.phases...),
+		Spans:     p.root.Children,
+	}
+	for _, h := range p.hosts {
+		categories := make(map[string]time.Duration, len(h.Categories))
+		for c, d := range h.Categories {
+			categories[c] = d
+		}
+		r.Hosts = append(r.Hosts, Host{Name: h.Name, Categories: categories})
+	}
+	return r
+}
+
+// Folded returns the stacks of spans in the folded format of flamegraph.pl and speedscope, each line is the names of
+// the spans from the root separated by ; and the milliseconds spent in the last one but not in its children.
+func (r *Report) Folded() string {
+	var b strings.Builder
+	var walk func(prefix string, n *Node)
+	walk = func(prefix string, n *Node) {
+		name := prefix + strings.ReplaceAll(n.Name, ";", ":")
+		if ms := n.Self().Milliseconds(); ms > 0 {
+			b.WriteString(name)
+			b.WriteString(" ")
+			b.WriteString(strconv.FormatInt(ms, 10))
+			b.WriteString("\n")
+		}
+		for _, c := range n.Children {
+			walk(name+";", c)
+		}
+	}
+	for _, n := range r.Spans {
+		walk("", n)
+	}
+	return b.String()
+}
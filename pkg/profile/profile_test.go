@@ -0,0 +1,115 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/sealer/pkg/tracing"
+)
+
+// clock advances a second on each call.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.t = c.t.Add(time.Second)
+	return c.t
+}
+
+func phase(ctx context.Context, name string) (context.Context, func(error)) {
+	return tracing.Start(ctx, name, tracing.Attr("cluster", "my-cluster"), tracing.Attr("operation", "apply"))
+}
+
+func onHost(ctx context.Context, name, host string) {
+	_, end := tracing.Start(ctx, name, tracing.Attr("host", host))
+	end(nil)
+}
+
+func TestProfiler(t *testing.T) {
+	c := &clock{}
+	profiler := NewProfiler()
+	profiler.now = c.now
+	profiler.start = c.t
+	defer tracing.AddObserver(profiler)()
+	ctx, endApply := tracing.Start(context.Background(), "apply", tracing.Attr("cluster", "my-cluster"))
+
+	ctx1, end := phase(ctx, "MountRootfs")
+	onHost(ctx1, "ssh upload", "192.168.0.2")
+	onHost(ctx1, "ssh upload", "192.168.0.3")
+	end(nil)
+
+	ctx1, end = phase(ctx, "PreloadImages")
+	onHost(ctx1, "ssh upload", "192.168.0.2")
+	end(nil)
+
+	ctx1, end = phase(ctx, "Init")
+	ctx2, endStep := tracing.Start(ctx1, "init master0", tracing.Attr("host", "192.168.0.2"))
+	// the commands of the step are counted in the step only.
+	onHost(ctx2, "ssh kubeadm", "192.168.0.2")
+	endStep(nil)
+	end(nil)
+
+	ctx1, end = phase(ctx, "Join")
+	onHost(ctx1, "wait ssh ready", "192.168.0.3")
+	onHost(ctx1, "join node", "192.168.0.3")
+	end(nil)
+	endApply(nil)
+	profiler.Finish()
+
+	r := profiler.Report()
+	if r.Operation != "apply" || r.Cluster != "my-cluster" {
+		t.Errorf("Report() = %+v", r)
+	}
+	var phases []string
+	for _, p := range r.Phases {
+		phases = append(phases, p.Name)
+	}
+	if strings.Join(phases, ",") != "MountRootfs,PreloadImages,Init,Join" {
+		t.Errorf("Report() phases = %v", phases)
+	}
+	want := map[string]map[string]time.Duration{
+		"192.168.0.2": {CategoryCopy: time.Second, CategoryImageLoad: time.Second, CategoryInit: 3 * time.Second},
+		"192.168.0.3": {CategoryCopy: time.Second, CategoryHealthWait: time.Second, CategoryJoin: time.Second},
+	}
+	if len(r.Hosts) != len(want) {
+		t.Fatalf("Report() hosts = %+v", r.Hosts)
+	}
+	for _, h := range r.Hosts {
+		for _, category := range Categories {
+			if h.Categories[category] != want[h.Name][category] {
+				t.Errorf("host %s %s = %s, want %s", h.Name, category, h.Categories[category], want[h.Name][category])
+			}
+		}
+	}
+
+	folded := r.Folded()
+	for _, s := range []string{"apply;MountRootfs;ssh upload 2000\n", "apply;Init;init master0;ssh kubeadm 1000\n", "apply;Init;init master0 2000\n"} {
+		if !strings.Contains(folded, s) {
+			t.Errorf("Folded() does not contain %q:\n%s", s, folded)
+		}
+	}
+
+	out := Render(r)
+	for _, s := range []string{"sealer apply cluster my-cluster", "PreloadImages", "HEALTH WAIT", "ssh upload x2", "█"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Render() does not contain %q:\n%s", s, out)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// barWidth is the width of the bar of the whole operation in the flame summary.
+	barWidth = 30
+	// minShare hides the spans of the flame summary taking less than it of the operation.
+	minShare = 0.01
+)
+
+// Render draws the time of the phases, the breakdown of the hosts in each category and the flame summary of r.
+func Render(r *Report) string {
+	var b strings.Builder
+	title := "sealer"
+	if r.Operation != "" {
+		title += " " + r.Operation
+	}
+	if r.Cluster != "" {
+		title += " cluster " + r.Cluster
+	}
+	fmt.Fprintf(&b, "%s took %s\n\n", title, formatDuration(r.Duration))
+
+	width := len("PHASE")
+	for _, p := range r.Phases {
+		if len(p.Name) > width {
+			width = len(p.Name)
+		}
+	}
+	fmt.Fprintf(&b, "%s%s%s\n", pad("PHASE", width+2), pad("TIME", 12), "SHARE")
+	for _, p := range r.Phases {
+		fmt.Fprintf(&b, "%s%s%s\n", pad(p.Name, width+2), pad(formatDuration(p.Duration), 12), formatShare(p.Duration, r.Duration))
+	}
+
+	if len(r.Hosts) > 0 {
+		width = len("HOST")
+		for _, h := range r.Hosts {
+			if len(h.Name) > width {
+				width = len(h.Name)
+			}
+		}
+		b.WriteString("\n")
+		b.WriteString(pad("HOST", width+2))
+		for _, c := range Categories {
+			b.WriteString(pad(strings.ToUpper(c), 14))
+		}
+		b.WriteString("TOTAL\n")
+		for _, h := range r.Hosts {
+			b.WriteString(pad(h.Name, width+2))
+			for _, c := range Categories {
+				b.WriteString(pad(formatDuration(h.Categories[c]), 14))
+			}
+			b.WriteString(formatDuration(h.Total()))
+			b.WriteString("\n")
+		}
+	}
+
+	if len(r.Spans) > 0 {
+		b.WriteString("\nthe time of the spans summed over the hosts, which exceeds the operation if they ran in parallel:\n")
+		for _, n := range r.Spans {
+			renderNode(&b, n, 0, r.Duration)
+		}
+	}
+	return b.String()
+}
+
+func renderNode(b *strings.Builder, n *Node, depth int, total time.Duration) {
+	if total > 0 && float64(n.Duration) < minShare*float64(total) {
+		return
+	}
+	name := strings.Repeat("  ", depth) + n.Name
+	if n.Count > 1 {
+		name += fmt.Sprintf(" x%d", n.Count)
+	}
+	fmt.Fprintf(b, "%s%s%s%s\n", pad(name, 40), pad(formatDuration(n.Duration), 12), pad(formatShare(n.Duration, total), 9), bar(n.Duration, total))
+	for _, c := range n.Children {
+		renderNode(b, c, depth+1, total)
+	}
+}
+
+// bar draws d in the width of barWidth for total, it is capped at twice of barWidth.
+func bar(d, total time.Duration) string {
+	if total <= 0 {
+		return ""
+	}
+	n := int(float64(d) / float64(total) * barWidth)
+	if n > 2*barWidth {
+		n = 2 * barWidth
+	}
+	return strings.Repeat("█", n)
+}
+
+func formatShare(d, total time.Duration) string {
+	if total <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(d)/float64(total)*100)
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// pad pads s to width runes, or one space after it if it is wider.
+func pad(s string, width int) string {
+	n := len([]rune(s))
+	if n >= width {
+		return s + " "
+	}
+	return s + strings.Repeat(" ", width-n)
+}
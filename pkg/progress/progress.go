@@ -0,0 +1,351 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/hostlog"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/tracing"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// The stages of a host, which are the columns of the progress.
+const (
+	StagePrep     = "prep"
+	StageCopy     = "copy"
+	StageInitJoin = "init/join"
+	StagePost     = "post"
+)
+
+var Stages = []string{StagePrep, StageCopy, StageInitJoin, StagePost}
+
+// phaseStages are the stages of the phases of the pipelines, the remote commands of a phase run in its stage.
+var phaseStages = map[string]string{
+	"Preflight":              StagePrep,
+	"CheckSSH":               StagePrep,
+	"Reset":                  StagePrep,
+	"RunConfig":              StagePrep,
+	"PrepareHosts":           StagePrep,
+	"OpenPorts":              StagePrep,
+	"SyncTime":               StagePrep,
+	"InstallUnits":           StagePrep,
+	"RenderConfigs":          StagePrep,
+	"MountRootfs":            StageCopy,
+	"VerifyComponents":       StageCopy,
+	"PreloadImages":          StageCopy,
+	"ConfigureP2P":           StageCopy,
+	"Init":                   StageInitJoin,
+	"DeployP2P":              StageInitJoin,
+	"Join":                   StageInitJoin,
+	"Takeover":               StageInitJoin,
+	"Upgrade":                StageInitJoin,
+	"RestoreEtcd":            StageInitJoin,
+	"RemoveStaleNodes":       StageInitJoin,
+	"PromoteMaster0":         StageInitJoin,
+	"RegenerateControlPlane": StageInitJoin,
+	"RestartKubelet":         StageInitJoin,
+	"UpdateNodes":            StageInitJoin,
+	"ApplyStaticPods":        StagePost,
+	"RestoreRegistry":        StagePost,
+	"RunGuest":               StagePost,
+	"RecordApps":             StagePost,
+	"Install":                StagePost,
+	"RerunPlugins":           StagePost,
+}
+
+// hostStepStages are the stages of the steps the runtime runs on each host.
+var hostStepStages = map[string]string{
+	"init master0":    StageInitJoin,
+	"join master":     StageInitJoin,
+	"join node":       StageInitJoin,
+	"takeover host":   StageInitJoin,
+	"promote master0": StageInitJoin,
+}
+
+type State int
+
+const (
+	Pending State = iota
+	Running
+	Done
+	Failed
+)
+
+// Cell is the progress of a host in a stage.
+type Cell struct {
+	State State
+	Start time.Time
+	End   time.Time
+	// Err is the last error of the host in the stage, Phase is where it occurred.
+	Err   string
+	Phase string
+	// Log is the file of the output of the host in Phase.
+	Log    string
+	active int
+}
+
+// Duration is how long the host has been in the stage, until now if it is still running.
+func (c Cell) Duration(now time.Time) time.Duration {
+	if c.State == Pending {
+		return 0
+	}
+	if c.State == Running {
+		return now.Sub(c.Start)
+	}
+	return c.End.Sub(c.Start)
+}
+
+type Host struct {
+	Name  string
+	Cells map[string]*Cell
+}
+
+// reached returns whether the host has started stage.
+func (h *Host) reached(stage string) bool {
+	c, ok := h.Cells[stage]
+	return ok && c.State != Pending
+}
+
+func (h *Host) cell(stage string) *Cell {
+	c, ok := h.Cells[stage]
+	if !ok {
+		c = &Cell{}
+		h.Cells[stage] = c
+	}
+	return c
+}
+
+// Tracker follows the spans of the pipelines and the ssh clients, and keeps the progress of each host in each
+// stage. It is a tracing.Observer.
+type Tracker struct {
+	lock      sync.Mutex
+	operation string
+	cluster   string
+	phase     string
+	start     time.Time
+	end       time.Time
+	finished  bool
+	err       error
+	hosts     []*Host
+	spans     map[*tracing.SpanInfo]*Cell
+	now       func() time.Time
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{spans: make(map[*tracing.SpanInfo]*Cell), now: time.Now, start: time.Now()}
+}
+
+func (t *Tracker) host(name string) *Host {
+	for _, h := range t.hosts {
+		if h.Name == name {
+			return h
+		}
+	}
+	h := &Host{Name: name, Cells: make(map[string]*Cell)}
+	t.hosts = append(t.hosts, h)
+	return h
+}
+
+// stageOf returns the stage of span s on host h, by the nearest host step or phase s runs in. The phases which
+// run many steps, like scaling up, are split by what h has done: the uploads are copying, and the commands after
+// joining are post ones.
+func stageOf(s *tracing.SpanInfo, h *Host) string {
+	for p := s; p != nil; p = p.Parent {
+		if stage, ok := hostStepStages[p.Name]; ok {
+			return stage
+		}
+		if isPhase(p) {
+			if stage, ok := phaseStages[p.Name]; ok {
+				return stage
+			}
+			break
+		}
+	}
+	switch {
+	case s.Name == "ssh "+ssh.DirectionUpload:
+		return StageCopy
+	case h.reached(StageInitJoin):
+		return StagePost
+	case h.reached(StageCopy):
+		return StageCopy
+	}
+	return StagePrep
+}
+
+// isPhase returns whether s is a phase of a pipeline, which is the child of the operation.
+func isPhase(s *tracing.SpanInfo) bool {
+	return s.Attr("operation") != "" && s.Attr("cluster") != ""
+}
+
+func (t *Tracker) SpanStarted(s *tracing.SpanInfo) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	switch {
+	case isPhase(s):
+		t.phase = s.Name
+		t.cluster = s.Attr("cluster")
+		t.operation = s.Attr("operation")
+		return
+	case s.Attr("host") == "":
+		return
+	}
+	h := t.host(s.Attr("host"))
+	c := h.cell(stageOf(s, h))
+	if c.State == Pending {
+		c.Start = t.now()
+	}
+	c.State = Running
+	c.active++
+	t.spans[s] = c
+}
+
+func (t *Tracker) SpanEnded(s *tracing.SpanInfo, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if isPhase(s) {
+		if err != nil {
+			t.failPhase(s.Name, err)
+		}
+		return
+	}
+	c, ok := t.spans[s]
+	if !ok {
+		return
+	}
+	delete(t.spans, s)
+	c.active--
+	c.End = t.now()
+	// the remote commands may fail as expected, like checking a file, only the steps of hosts tell their result.
+	if err != nil && !strings.HasPrefix(s.Name, "ssh ") {
+		t.fail(c, s.Attr("host"), err)
+		return
+	}
+	if c.active == 0 && c.State == Running {
+		c.State = Done
+	}
+}
+
+// failPhase fails the hosts err of phase occurred on, or the hosts running in its stage.
+func (t *Tracker) failPhase(phase string, err error) {
+	stage, ok := phaseStages[phase]
+	var hosts []string
+	var e *result.Error
+	if errors.As(err, &e) {
+		hosts = e.Hosts
+	}
+	for _, h := range t.hosts {
+		for s, c := range h.Cells {
+			if ok && s != stage || c.State == Failed {
+				continue
+			}
+			if len(hosts) == 0 && c.State == Running || inList(h.Name, hosts) {
+				c.End = t.now()
+				t.fail(c, h.Name, err)
+			}
+		}
+	}
+}
+
+func (t *Tracker) fail(c *Cell, host string, err error) {
+	c.State = Failed
+	c.Err = err.Error()
+	c.Phase = t.phase
+	if t.cluster != "" && t.phase != "" {
+		c.Log = hostlog.Path(common.DefaultHostLogDir, t.cluster, host, t.phase)
+	}
+}
+
+// Finish ends the progress with the result of the operation.
+func (t *Tracker) Finish(err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.finished = true
+	t.err = err
+	t.end = t.now()
+}
+
+func inList(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot is a copy of the progress for rendering.
+type Snapshot struct {
+	Operation string
+	Cluster   string
+	Phase     string
+	Elapsed   time.Duration
+	Finished  bool
+	Err       error
+	Hosts     []HostProgress
+	Now       time.Time
+}
+
+type HostProgress struct {
+	Name string
+	// Cells are in the order of Stages.
+	Cells []Cell
+}
+
+// Failed returns the cells the host failed in.
+func (h HostProgress) Failed() []Cell {
+	var cells []Cell
+	for _, c := range h.Cells {
+		if c.State == Failed {
+			cells = append(cells, c)
+		}
+	}
+	return cells
+}
+
+func (t *Tracker) Snapshot() Snapshot {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := t.now()
+	if t.finished {
+		now = t.end
+	}
+	snap := Snapshot{
+		Operation: t.operation,
+		Cluster:   t.cluster,
+		Phase:     t.phase,
+		Elapsed:   now.Sub(t.start),
+		Finished:  t.finished,
+		Err:       t.err,
+		Now:       now,
+	}
+	for _, h := range t.hosts {
+		hp := HostProgress{Name: h.Name}
+		for _, stage := range Stages {
+			c := Cell{}
+			if cell, ok := h.Cells[stage]; ok {
+				c = *cell
+			}
+			hp.Cells = append(hp.Cells, c)
+		}
+		snap.Hosts = append(snap.Hosts, hp)
+	}
+	return snap
+}
@@ -0,0 +1,98 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/tracing"
+)
+
+func phase(ctx context.Context, name string) (context.Context, func(error)) {
+	return tracing.Start(ctx, name, tracing.Attr("cluster", "my-cluster"), tracing.Attr("operation", "apply"))
+}
+
+func onHost(ctx context.Context, name, host string, err error) {
+	_, end := tracing.Start(ctx, name, tracing.Attr("host", host))
+	end(err)
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	defer tracing.AddObserver(tracker)()
+	ctx, endApply := tracing.Start(context.Background(), "apply", tracing.Attr("cluster", "my-cluster"))
+
+	ctx1, end := phase(ctx, "Preflight")
+	// the commands checking the hosts may fail as expected.
+	onHost(ctx1, "ssh test", "192.168.0.2", errors.New("exit status 1"))
+	onHost(ctx1, "ssh uname", "192.168.0.3", nil)
+	end(nil)
+
+	ctx1, end = phase(ctx, "MountRootfs")
+	onHost(ctx1, "ssh upload", "192.168.0.2", nil)
+	onHost(ctx1, "ssh upload", "192.168.0.3", nil)
+	end(result.Wrap(result.CategoryRuntime, "MountRootfs", errors.New("no space left on device\nmore"), "192.168.0.3"))
+
+	ctx1, end = phase(ctx, "Init")
+	onHost(ctx1, "init master0", "192.168.0.2", nil)
+	end(nil)
+
+	ctx1, end = phase(ctx, "scaleUp")
+	onHost(ctx1, "ssh kubectl", "192.168.0.2", nil)
+	end(nil)
+	endApply(nil)
+
+	snap := tracker.Snapshot()
+	if snap.Operation != "apply" || snap.Cluster != "my-cluster" || snap.Phase != "scaleUp" {
+		t.Errorf("Snapshot() = %+v", snap)
+	}
+	want := map[string][]State{
+		"192.168.0.2": {Done, Done, Done, Done},
+		"192.168.0.3": {Done, Failed, Pending, Pending},
+	}
+	if len(snap.Hosts) != len(want) {
+		t.Fatalf("Snapshot() hosts = %+v", snap.Hosts)
+	}
+	for _, h := range snap.Hosts {
+		for i, c := range h.Cells {
+			if c.State != want[h.Name][i] {
+				t.Errorf("host %s stage %s state = %d, want %d", h.Name, Stages[i], c.State, want[h.Name][i])
+			}
+		}
+	}
+	failed := snap.Hosts[1].Cells[1]
+	if failed.Phase != "MountRootfs" || failed.Log != "/var/lib/sealer/logs/my-cluster/192.168.0.3/MountRootfs.log" {
+		t.Errorf("failed cell = %+v", failed)
+	}
+
+	out := Render(snap, View{Selected: 1})
+	for _, s := range []string{"HOST", "init/join", "✓", "✗", "192.168.0.3 failed in phase MountRootfs", "press enter"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Render() does not contain %q:\n%s", s, out)
+		}
+	}
+	if out := Render(snap, View{Selected: 1, Expanded: true}); !strings.Contains(out, "  more") {
+		t.Errorf("Render() of expanded error does not contain the whole error:\n%s", out)
+	}
+
+	tracker.Finish(errors.New("apply failed"))
+	if out := Summary(tracker.Snapshot()); !strings.Contains(out, "failed") || !strings.Contains(out, "apply failed") {
+		t.Errorf("Summary() =\n%s", out)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const cellWidth = 14
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// View is what the terminal shows besides the progress.
+type View struct {
+	// Selected is the index of the selected host, -1 selects none.
+	Selected int
+	// Expanded shows the whole errors of the selected host instead of their first line.
+	Expanded bool
+	// Width truncates the lines, 0 does not.
+	Width int
+}
+
+// Render draws the matrix of the hosts and stages of snap, and the errors of the selected host.
+func Render(snap Snapshot, v View) string {
+	var b strings.Builder
+	title := "sealer"
+	if snap.Operation != "" {
+		title += " " + snap.Operation
+	}
+	if snap.Cluster != "" {
+		title += " cluster " + snap.Cluster
+	}
+	switch {
+	case snap.Finished && snap.Err != nil:
+		title += " failed"
+	case snap.Finished:
+		title += " succeeded"
+	case snap.Phase != "":
+		title += " phase " + snap.Phase
+	}
+	writeLine(&b, v.Width, fmt.Sprintf("%s  elapsed %s", title, formatDuration(snap.Elapsed)))
+	writeLine(&b, v.Width, "")
+
+	hostWidth := len("HOST")
+	for _, h := range snap.Hosts {
+		if len(h.Name) > hostWidth {
+			hostWidth = len(h.Name)
+		}
+	}
+	header := "  " + pad("HOST", hostWidth+2)
+	for _, stage := range Stages {
+		header += pad(stage, cellWidth)
+	}
+	writeLine(&b, v.Width, header)
+	for i, h := range snap.Hosts {
+		line := "  "
+		if i == v.Selected {
+			line = "> "
+		}
+		line += pad(h.Name, hostWidth+2)
+		for _, c := range h.Cells {
+			line += pad(renderCell(c, snap.Now), cellWidth)
+		}
+		writeLine(&b, v.Width, line)
+	}
+	if len(snap.Hosts) == 0 {
+		writeLine(&b, v.Width, "  waiting for the hosts")
+	}
+
+	if v.Selected >= 0 && v.Selected < len(snap.Hosts) {
+		renderErrors(&b, snap.Hosts[v.Selected], v.Expanded, v.Width)
+	}
+	return b.String()
+}
+
+// Summary draws the matrix and all errors of the hosts, which is printed once the terminal is restored.
+func Summary(snap Snapshot) string {
+	var b strings.Builder
+	b.WriteString(Render(snap, View{Selected: -1}))
+	for _, h := range snap.Hosts {
+		renderErrors(&b, h, true, 0)
+	}
+	if snap.Err != nil {
+		fmt.Fprintf(&b, "\n%v\n", snap.Err)
+	}
+	return b.String()
+}
+
+func renderErrors(b *strings.Builder, h HostProgress, expanded bool, width int) {
+	for _, c := range h.Failed() {
+		writeLine(b, width, "")
+		lines := strings.Split(strings.TrimSpace(c.Err), "\n")
+		writeLine(b, width, fmt.Sprintf("%s failed in phase %s: %s", h.Name, c.Phase, lines[0]))
+		if expanded {
+			for _, l := range lines[1:] {
+				writeLine(b, width, "  "+l)
+			}
+		} else if len(lines) > 1 {
+			writeLine(b, width, "  ... press enter to show the whole error")
+		}
+		if c.Log != "" {
+			writeLine(b, width, "  output: "+c.Log)
+		}
+	}
+}
+
+func renderCell(c Cell, now time.Time) string {
+	switch c.State {
+	case Running:
+		return fmt.Sprintf("%s %s", spinner[now.UnixNano()/int64(100*time.Millisecond)%int64(len(spinner))], formatDuration(c.Duration(now)))
+	case Done:
+		return "✓ " + formatDuration(c.Duration(now))
+	case Failed:
+		return "✗ " + formatDuration(c.Duration(now))
+	}
+	return "·"
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// pad pads s to width runes, or one space after it if it is wider.
+func pad(s string, width int) string {
+	n := len([]rune(s))
+	if n >= width {
+		return s + " "
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// writeLine writes s truncated to width runes in a line of b.
+func writeLine(b *strings.Builder, width int, s string) {
+	s = strings.TrimRight(s, " ")
+	if r := []rune(s); width > 0 && len(r) > width {
+		s = string(r[:width])
+	}
+	b.WriteString(s)
+	b.WriteString("\n")
+}
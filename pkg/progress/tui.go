@@ -0,0 +1,194 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/moby/term"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/tracing"
+)
+
+const (
+	refreshInterval = 100 * time.Millisecond
+	// logLines is the number of the last lines of the log shown under the progress.
+	logLines = 8
+
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+)
+
+type ui struct {
+	tracker *Tracker
+	out     *os.File
+	lock    sync.Mutex
+	view    View
+	logs    []string
+	// stopped ignores the keys read once the terminal is restored.
+	stopped bool
+}
+
+// Start shows the progress of the hosts on the terminal until the returned func is called with the result,
+// which restores the terminal and prints the summary. The log is shown under the progress instead of being
+// printed. Start shows nothing if stdin or stdout is not a terminal.
+func Start() func(err error) {
+	in, out := os.Stdin.Fd(), os.Stdout.Fd()
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		logger.Warn("stdin or stdout is not a terminal, the progress is not shown")
+		return func(error) {}
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		logger.Warn("failed to show the progress: %v", err)
+		return func(error) {}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		_ = term.RestoreTerminal(in, state)
+		logger.Warn("failed to show the progress: %v", err)
+		return func(error) {}
+	}
+
+	u := &ui{tracker: NewTracker(), out: os.Stdout}
+	removeObserver := tracing.AddObserver(u.tracker)
+	stdOut, stdErr := common.StdOut, common.StdErr
+	common.StdOut, common.StdErr = w, w
+	logsDone := make(chan struct{})
+	go func() {
+		defer close(logsDone)
+		u.readLogs(r)
+	}()
+	go u.readKeys()
+
+	fmt.Fprint(u.out, enterAltScreen)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			u.draw()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func(err error) {
+		close(stop)
+		<-stopped
+		u.lock.Lock()
+		u.stopped = true
+		u.lock.Unlock()
+		removeObserver()
+		common.StdOut, common.StdErr = stdOut, stdErr
+		_ = w.Close()
+		<-logsDone
+		_ = r.Close()
+		fmt.Fprint(u.out, exitAltScreen)
+		_ = term.RestoreTerminal(in, state)
+
+		u.tracker.Finish(err)
+		fmt.Fprint(u.out, Summary(u.tracker.Snapshot()))
+	}
+}
+
+func (u *ui) readLogs(r *os.File) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		u.lock.Lock()
+		u.logs = append(u.logs, scanner.Text())
+		if len(u.logs) > logLines {
+			u.logs = u.logs[len(u.logs)-logLines:]
+		}
+		u.lock.Unlock()
+	}
+}
+
+// readKeys moves the selection by the arrows or j and k, and expands the errors of the selected host by enter.
+// The terminal does not turn ctrl-c to SIGINT in the raw mode, it is sent here to abort the running phase.
+func (u *ui) readKeys() {
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		key := string(buf[:n])
+		hosts := len(u.tracker.Snapshot().Hosts)
+		u.lock.Lock()
+		if u.stopped {
+			u.lock.Unlock()
+			return
+		}
+		switch key {
+		case "\x03":
+			_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+		case "\x1b[A", "k":
+			if u.view.Selected > 0 {
+				u.view.Selected--
+				u.view.Expanded = false
+			}
+		case "\x1b[B", "j":
+			if u.view.Selected < hosts-1 {
+				u.view.Selected++
+				u.view.Expanded = false
+			}
+		case "\r", "\n":
+			u.view.Expanded = !u.view.Expanded
+		}
+		u.lock.Unlock()
+	}
+}
+
+func (u *ui) draw() {
+	var width, height int
+	if ws, err := term.GetWinsize(u.out.Fd()); err == nil {
+		width, height = int(ws.Width), int(ws.Height)
+	}
+	u.lock.Lock()
+	v := u.view
+	v.Width = width
+	logs := append([]string{}, u.logs...)
+	u.lock.Unlock()
+
+	var b strings.Builder
+	b.WriteString(Render(u.tracker.Snapshot(), v))
+	b.WriteString("\n")
+	for _, l := range logs {
+		writeLine(&b, width, l)
+	}
+	writeLine(&b, width, "")
+	writeLine(&b, width, "↑/↓ select a host  enter show its errors  ctrl-c abort")
+
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	fmt.Fprint(u.out, clearScreen+strings.Join(lines, "\r\n"))
+}
@@ -0,0 +1,217 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registries
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	ContainerdCertsDir = "/etc/containerd/certs.d"
+	// ManagedMarker is the first line of the hosts.toml written by sealer, the ones of the registries removed from
+	// Clusterfile are deleted by it.
+	ManagedMarker = "# managed by sealer registries"
+	// RemoteEnableContainerdConfigPath lets containerd read the hosts.toml in certs.d, by setting the empty config_path
+	// of the registry of CRI in its config, and restarts containerd only if the config changed.
+	RemoteEnableContainerdConfigPath = `f=/etc/containerd/config.toml; if [ -f $f ] && grep -q '^[[:space:]]*config_path = ""' $f; then ` +
+		`sed -i 's#^\([[:space:]]*\)config_path = ""#\1config_path = "` + ContainerdCertsDir + `"#' $f && ` +
+		`(! systemctl is-active -q containerd || systemctl restart containerd); fi`
+	// RemoteCheckContainerdConfigPath fails if containerd does not read the hosts.toml in certs.d, like a config_path
+	// set to another dir or a containerd config without the registry of CRI.
+	RemoteCheckContainerdConfigPath = `f=/etc/containerd/config.toml; grep -q '^[[:space:]]*config_path = "` + ContainerdCertsDir + `"' $f || ` +
+		`{ echo "config_path of CRI registry in $f is not ` + ContainerdCertsDir + `" >&2; exit 1; }`
+	RemoteWriteContainerdHosts = `mkdir -p %[1]s && echo '%[2]s' > %[1]s/hosts.toml`
+	// RemoteListManagedHosts prints the hosts.toml written by sealer.
+	RemoteListManagedHosts = `grep -lx '` + ManagedMarker + `' ` + ContainerdCertsDir + `/*/hosts.toml 2>/dev/null || true`
+	RemoteHasContainerd    = `if [ -d /etc/containerd ]; then echo true; fi`
+)
+
+// Validate checks the registries of Clusterfile, the domains and URLs are written to the configs of the hosts.
+func Validate(specs []v2.RegistrySpec) error {
+	domains := map[string]bool{}
+	for _, r := range specs {
+		if r.Domain == "" || strings.ContainsAny(r.Domain, "/'\" \t") {
+			return fmt.Errorf("invalid domain %q of registry, it must be like harbor.example.com:8443", r.Domain)
+		}
+		if r.Domain == runtime.SeaHub || strings.HasPrefix(r.Domain, runtime.SeaHub+":") {
+			return fmt.Errorf("registry %s is the sealer registry, configure it in etc/registry.yml of CloudImage", r.Domain)
+		}
+		if domains[r.Domain] {
+			return fmt.Errorf("registry %s is duplicated", r.Domain)
+		}
+		domains[r.Domain] = true
+		urls := r.Mirrors
+		if r.Server != "" {
+			urls = append([]string{r.Server}, urls...)
+		}
+		for _, u := range urls {
+			if !(strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")) || strings.ContainsAny(u, "'\" \t") {
+				return fmt.Errorf("invalid URL %q of registry %s, it must be like https://mirror.example.com", u, r.Domain)
+			}
+		}
+		if (r.Username == "") != (r.Password == "") {
+			return fmt.Errorf("both username and password of registry %s must be set", r.Domain)
+		}
+	}
+	return nil
+}
+
+func server(r v2.RegistrySpec) string {
+	if r.Server != "" {
+		return r.Server
+	}
+	return "https://" + r.Domain
+}
+
+// ContainerdHosts returns the hosts.toml of registry r, containerd pulls its images from the mirrors in order,
+// then the server itself.
+func ContainerdHosts(r v2.RegistrySpec) string {
+	var sb strings.Builder
+	sb.WriteString(ManagedMarker + "\n")
+	sb.WriteString(fmt.Sprintf("server = \"%s\"\n", server(r)))
+	hosts := r.Mirrors
+	if r.SkipVerify {
+		// the server is a host as well to skip verifying its certs.
+		hosts = append(append([]string{}, r.Mirrors...), server(r))
+	}
+	for i, h := range hosts {
+		sb.WriteString(fmt.Sprintf("\n[host.\"%s\"]\n", h))
+		if i < len(r.Mirrors) {
+			sb.WriteString("  capabilities = [\"pull\", \"resolve\"]\n")
+		} else {
+			sb.WriteString("  capabilities = [\"pull\", \"resolve\", \"push\"]\n")
+		}
+		if r.SkipVerify {
+			sb.WriteString("  skip_verify = true\n")
+		}
+	}
+	return sb.String()
+}
+
+// WriteDockerMirrors puts the mirrors of the registries to the mirror-registries of etc/daemon.json in rootfs, the
+// patched docker of rootfs pulls the images of a registry from them in order, then the registry itself.
+func WriteDockerMirrors(cluster *v2.Cluster, rootfs string) error {
+	specs := cluster.Spec.Registries
+	if len(specs) == 0 {
+		return nil
+	}
+	if err := Validate(specs); err != nil {
+		return err
+	}
+	return runtime.UpdateDockerMirrors(rootfs, func(mirrors []runtime.DockerMirror) []runtime.DockerMirror {
+		for _, r := range specs {
+			if len(r.Mirrors) == 0 {
+				continue
+			}
+			m := runtime.DockerMirror{Domain: r.Domain, Mirrors: r.Mirrors}
+			replaced := false
+			for i := range mirrors {
+				if mirrors[i].Domain == r.Domain {
+					mirrors[i], replaced = m, true
+				}
+			}
+			if !replaced {
+				// the exact domains are put before the wildcard one.
+				mirrors = append([]runtime.DockerMirror{m}, mirrors...)
+			}
+		}
+		return mirrors
+	})
+}
+
+// ConfigureHosts configs the registries of Clusterfile on hosts, it does nothing if there is no registry.
+func ConfigureHosts(cluster *v2.Cluster, hosts []string) error {
+	if len(cluster.Spec.Registries) == 0 {
+		return nil
+	}
+	return Reconcile(cluster, hosts)
+}
+
+// Reconcile writes the hosts.toml of the registries to containerd and their credentials to the docker configs of
+// hosts, and deletes the hosts.toml of the registries removed from Clusterfile.
+func Reconcile(cluster *v2.Cluster, hosts []string) error {
+	specs := cluster.Spec.Registries
+	if err := Validate(specs); err != nil {
+		return err
+	}
+	var auths []runtime.DockerAuth
+	for _, r := range specs {
+		if r.Username != "" {
+			auths = append(auths, runtime.DockerAuth{Server: r.Domain, Username: r.Username, Password: r.Password})
+		}
+	}
+
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			sshClient, err := ssh.GetHostSSHClient(ip, cluster)
+			if err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("get host ssh client failed %v", err))
+				return
+			}
+			if err = configureContainerd(sshClient, ip, specs); err == nil {
+				err = runtime.ApplyDockerAuths(sshClient, ip, auths)
+			}
+			if err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("failed to config registries on %s: %v", ip, err))
+			}
+		}(h)
+	}
+	wg.Wait()
+	return runtime.ReadChanError(errCh)
+}
+
+func configureContainerd(client ssh.Interface, ip string, specs []v2.RegistrySpec) error {
+	out, err := client.Cmd(ip, RemoteHasContainerd)
+	if err != nil || strings.TrimSpace(string(out)) != "true" {
+		return err
+	}
+	var cmds []string
+	if len(specs) > 0 {
+		cmds = append(cmds, RemoteEnableContainerdConfigPath)
+	}
+	var dirs []string
+	for _, r := range specs {
+		dir := filepath.Join(ContainerdCertsDir, r.Domain)
+		dirs = append(dirs, dir)
+		cmds = append(cmds, fmt.Sprintf(RemoteWriteContainerdHosts, dir, ContainerdHosts(r)))
+	}
+	out, err = client.Cmd(ip, RemoteListManagedHosts)
+	if err != nil {
+		return err
+	}
+	for _, f := range strings.Fields(string(out)) {
+		if dir := filepath.Dir(f); utils.NotIn(dir, dirs) {
+			cmds = append(cmds, fmt.Sprintf("rm -rf %s", dir))
+		}
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	return client.CmdAsync(ip, cmds...)
+}
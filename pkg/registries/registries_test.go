@@ -0,0 +1,143 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registries
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []v2.RegistrySpec
+		wantErr bool
+	}{
+		{"valid", []v2.RegistrySpec{{Domain: "harbor.example.com:8443", Server: "http://10.0.0.1:8443",
+			Mirrors: []string{"https://mirror.example.com"}, Username: "admin", Password: "passwd"}}, false},
+		{"empty domain", []v2.RegistrySpec{{}}, true},
+		{"domain with scheme", []v2.RegistrySpec{{Domain: "https://harbor.example.com"}}, true},
+		{"sealer registry", []v2.RegistrySpec{{Domain: "sea.hub:5000"}}, true},
+		{"duplicated", []v2.RegistrySpec{{Domain: "harbor.example.com"}, {Domain: "harbor.example.com"}}, true},
+		{"mirror without scheme", []v2.RegistrySpec{{Domain: "harbor.example.com", Mirrors: []string{"mirror.example.com"}}}, true},
+		{"username only", []v2.RegistrySpec{{Domain: "harbor.example.com", Username: "admin"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.specs); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContainerdHosts(t *testing.T) {
+	got := ContainerdHosts(v2.RegistrySpec{Domain: "harbor.example.com", Mirrors: []string{"https://mirror.example.com"}, SkipVerify: true})
+	want := ManagedMarker + `
+server = "https://harbor.example.com"
+
+[host."https://mirror.example.com"]
+  capabilities = ["pull", "resolve"]
+  skip_verify = true
+
+[host."https://harbor.example.com"]
+  capabilities = ["pull", "resolve", "push"]
+  skip_verify = true
+`
+	if got != want {
+		t.Errorf("ContainerdHosts() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteDockerMirrors(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "sealer-rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+	daemonFile := filepath.Join(rootfs, "etc", "daemon.json")
+	if err := os.MkdirAll(filepath.Dir(daemonFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	daemon := `{"mirror-registries": [{"domain": "*", "mirrors": ["https://sea.hub:5000"]}]}`
+	if err := ioutil.WriteFile(daemonFile, []byte(daemon), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &v2.Cluster{}
+	cluster.Spec.Registries = []v2.RegistrySpec{
+		{Domain: "harbor.example.com", Mirrors: []string{"https://mirror.example.com"}},
+		// no mirror, docker pulls from the registry itself.
+		{Domain: "quay.example.com"},
+	}
+	if err := WriteDockerMirrors(cluster, rootfs); err != nil {
+		t.Fatalf("WriteDockerMirrors() error = %v", err)
+	}
+	data, err := ioutil.ReadFile(daemonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Mirrors []runtime.DockerMirror `json:"mirror-registries"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []runtime.DockerMirror{
+		{Domain: "harbor.example.com", Mirrors: []string{"https://mirror.example.com"}},
+		{Domain: "*", Mirrors: []string{"https://sea.hub:5000"}},
+	}
+	if !reflect.DeepEqual(got.Mirrors, want) {
+		t.Errorf("mirror-registries = %+v, want %+v", got.Mirrors, want)
+	}
+}
+
+func TestRemoteCheckContainerdConfigPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"certs.d", "[plugins.\"io.containerd.grpc.v1.cri\".registry]\n  config_path = \"/etc/containerd/certs.d\"\n", false},
+		{"empty", "[plugins.\"io.containerd.grpc.v1.cri\".registry]\n  config_path = \"\"\n", true},
+		{"other dir", "[plugins.\"io.containerd.grpc.v1.cri\".registry]\n  config_path = \"/etc/docker/certs.d\"\n", true},
+		{"no config_path", "version = 2\n", true},
+		{"no config", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := filepath.Join(t.TempDir(), "config.toml")
+			if tt.config != "" {
+				if err := ioutil.WriteFile(config, []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cmd := strings.Replace(RemoteCheckContainerdConfigPath, "/etc/containerd/config.toml", config, 1)
+			err := exec.Command("sh", "-c", cmd).Run()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RemoteCheckContainerdConfigPath error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultResultFile is where sealer writes the result of a cluster operation, for CI pipelines to consume.
+const DefaultResultFile = "/var/lib/sealer/result.json"
+
+const redacted = "******"
+
+// secretFlags are the flags whose values are redacted from the command in result, keyed by the long and short names.
+var secretFlags = map[string]bool{
+	"--passwd":      true,
+	"-p":            true,
+	"--pk-passwd":   true,
+	"--sudo-passwd": true,
+	"--token":       true,
+}
+
+type Category string
+
+const (
+	CategoryUnknown    Category = "Unknown"
+	CategoryValidation Category = "Validation"
+	CategoryPreflight  Category = "Preflight"
+	CategoryInfra      Category = "Infra"
+	CategoryRuntime    Category = "Runtime"
+	CategoryGuest      Category = "Guest"
+)
+
+// exit code of sealer per failure category, 0 means succeeded.
+var exitCodes = map[Category]int{
+	CategoryUnknown:    1,
+	CategoryValidation: 2,
+	CategoryPreflight:  3,
+	CategoryInfra:      4,
+	CategoryRuntime:    5,
+	CategoryGuest:      6,
+}
+
+// Error classifies an error with the failure category, the phase and the hosts where it occurred.
+type Error struct {
+	Category Category
+	Phase    string
+	Hosts    []string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// OnHost records host as where err occurred, for the phases running on each host in parallel.
+// The category and phase are filled by Wrap of the phase.
+func OnHost(host string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Hosts: []string{host}, Err: err}
+}
+
+// Wrap classifies err, it keeps the category of an error which is already classified,
+// only fills its category, phase and hosts if they are empty.
+func Wrap(category Category, phase string, err error, hosts ...string) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Category == "" {
+			e.Category = category
+		}
+		if e.Phase == "" {
+			e.Phase = phase
+		}
+		if len(e.Hosts) == 0 {
+			e.Hosts = hosts
+		}
+		return err
+	}
+	return &Error{Category: category, Phase: phase, Hosts: hosts, Err: err}
+}
+
+// ExitCode returns the process exit code for err.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return exitCodes[categoryOf(err)]
+}
+
+func categoryOf(err error) Category {
+	var e *Error
+	if errors.As(err, &e) {
+		if _, ok := exitCodes[e.Category]; ok {
+			return e.Category
+		}
+	}
+	return CategoryUnknown
+}
+
+type Result struct {
+	Command     []string  `json:"command"`
+	Succeeded   bool      `json:"succeeded"`
+	ExitCode    int       `json:"exitCode"`
+	Category    Category  `json:"category,omitempty"`
+	Phase       string    `json:"phase,omitempty"`
+	FailedHosts []string  `json:"failedHosts,omitempty"`
+	Message     string    `json:"message,omitempty"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+}
+
+// New returns the result of command, the values of the secret flags in command are redacted.
+func New(command []string, startTime time.Time, err error) *Result {
+	r := &Result{
+		Command:   RedactCommand(command),
+		Succeeded: err == nil,
+		ExitCode:  ExitCode(err),
+		StartTime: startTime,
+		EndTime:   time.Now(),
+	}
+	if err == nil {
+		return r
+	}
+	r.Category = categoryOf(err)
+	r.Message = err.Error()
+	var e *Error
+	if errors.As(err, &e) {
+		r.Phase = e.Phase
+		r.FailedHosts = e.Hosts
+	}
+	return r
+}
+
+// RedactCommand returns command with the values of the secret flags replaced, like passwords and tokens, in the
+// forms of "--flag value", "--flag=value", "-p value", "-p=value" and "-pvalue".
+func RedactCommand(command []string) []string {
+	res := make([]string, len(command))
+	copy(res, command)
+	for i := 0; i < len(res); i++ {
+		arg := res[i]
+		if arg == "--" {
+			break
+		}
+		if name := strings.SplitN(arg, "=", 2)[0]; name != arg && secretFlags[name] {
+			res[i] = name + "=" + redacted
+			continue
+		}
+		if secretFlags[arg] {
+			if i+1 < len(res) {
+				res[i+1] = redacted
+				i++
+			}
+			continue
+		}
+		// the value of a shorthand flag may follow it directly, like -pxxx.
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && secretFlags[arg[:2]] {
+			res[i] = arg[:2] + redacted
+		}
+	}
+	return res
+}
+
+// Write saves the result as json to path, only the owner can read it.
+func Write(path string, r *Result) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create result file dir: %v", err)
+	}
+	// the file may exist with a wider mode written before.
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,130 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package result
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrap(t *testing.T) {
+	inner := Wrap(CategoryInfra, "WaitSSHReady", fmt.Errorf("ssh timeout"), "192.168.0.2")
+	outer := Wrap(CategoryRuntime, "JoinNodes", inner)
+	if got := ExitCode(outer); got != exitCodes[CategoryInfra] {
+		t.Errorf("ExitCode() = %d, want %d", got, exitCodes[CategoryInfra])
+	}
+
+	r := New([]string{"sealer", "join"}, time.Now(), fmt.Errorf("join failed: %w", outer))
+	if r.Succeeded || r.Category != CategoryInfra || r.Phase != "WaitSSHReady" ||
+		!reflect.DeepEqual(r.FailedHosts, []string{"192.168.0.2"}) {
+		t.Errorf("New() = %+v", r)
+	}
+
+	e := Wrap(CategoryGuest, "", fmt.Errorf("guest failed"))
+	_ = Wrap(CategoryRuntime, "RunGuest", e)
+	if r := New(nil, time.Now(), e); r.Phase != "RunGuest" || r.ExitCode != exitCodes[CategoryGuest] {
+		t.Errorf("New() = %+v, want phase filled by the outer wrap", r)
+	}
+
+	if Wrap(CategoryRuntime, "Init", nil) != nil {
+		t.Errorf("Wrap() of nil error should be nil")
+	}
+	if got := ExitCode(fmt.Errorf("plain error")); got != exitCodes[CategoryUnknown] {
+		t.Errorf("ExitCode() = %d, want %d", got, exitCodes[CategoryUnknown])
+	}
+	if got := ExitCode(nil); got != 0 {
+		t.Errorf("ExitCode() = %d, want 0", got)
+	}
+}
+
+func TestOnHost(t *testing.T) {
+	if OnHost("192.168.0.2", nil) != nil {
+		t.Errorf("OnHost() of nil error should be nil")
+	}
+	err := Wrap(CategoryRuntime, "InstallUnits", OnHost("192.168.0.3", fmt.Errorf("unit failed")))
+	r := New([]string{"sealer", "apply"}, time.Now(), fmt.Errorf("apply failed: %w", err))
+	if r.Category != CategoryRuntime || r.ExitCode != exitCodes[CategoryRuntime] || r.Phase != "InstallUnits" ||
+		!reflect.DeepEqual(r.FailedHosts, []string{"192.168.0.3"}) {
+		t.Errorf("New() = %+v, want the category and phase of the wrap and the host of OnHost", r)
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    []string
+	}{
+		{"no secret", []string{"sealer", "apply", "-f", "Clusterfile"}, []string{"sealer", "apply", "-f", "Clusterfile"}},
+		{"passwd", []string{"sealer", "run", "kubernetes:v1.19.8", "--passwd", "s3cret", "-m", "192.168.0.2"},
+			[]string{"sealer", "run", "kubernetes:v1.19.8", "--passwd", "******", "-m", "192.168.0.2"}},
+		{"passwd with equal", []string{"sealer", "run", "--passwd=s3cret"}, []string{"sealer", "run", "--passwd=******"}},
+		{"shorthand", []string{"sealer", "run", "-p", "s3cret"}, []string{"sealer", "run", "-p", "******"}},
+		{"shorthand with equal", []string{"sealer", "run", "-p=s3cret"}, []string{"sealer", "run", "-p=******"}},
+		{"shorthand attached", []string{"sealer", "run", "-ps3cret"}, []string{"sealer", "run", "-p******"}},
+		{"pk-passwd", []string{"sealer", "run", "--pk", "/root/.ssh/id_rsa", "--pk-passwd", "s3cret"},
+			[]string{"sealer", "run", "--pk", "/root/.ssh/id_rsa", "--pk-passwd", "******"}},
+		{"token", []string{"sealer", "join", "--standalone", "--token", "abcdef.0123456789abcdef", "--token=abcdef.0123456789abcdef"},
+			[]string{"sealer", "join", "--standalone", "--token", "******", "--token=******"}},
+		{"flag without value", []string{"sealer", "run", "--passwd"}, []string{"sealer", "run", "--passwd"}},
+		{"after terminator", []string{"sealer", "run", "--", "-p", "x"}, []string{"sealer", "run", "--", "-p", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := append([]string{}, tt.command...)
+			if got := RedactCommand(tt.command); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedactCommand() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.command, command) {
+				t.Errorf("RedactCommand() changed the command of caller to %v", tt.command)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sealer", "result.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// a result file written before with a wider mode
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New([]string{"sealer", "run", "-p", "s3cret"}, time.Now(), nil)
+	if err := Write(path, r); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode of result file = %o, want 600", info.Mode().Perm())
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("expected the password redacted in result file, got %s", data)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const (
+	AuditArgPrefix   = "audit-"
+	AuditLogPath     = "audit-log-path"
+	AuditLogMaxAge   = "audit-log-maxage"
+	AuditLogMaxBack  = "audit-log-maxbackup"
+	AuditLogMaxSize  = "audit-log-maxsize"
+	AuditLogVolume   = "audit-log"
+	auditLogPathType = "DirectoryOrCreate"
+)
+
+// mergeAuditSpec overwrites the audit args of the apiserver, the default kubeadm config enables audit log.
+func mergeAuditSpec(apiServer *v1beta2.ControlPlaneComponent, audit v2.AuditSpec) {
+	if audit.Disabled {
+		for arg := range apiServer.ExtraArgs {
+			if strings.HasPrefix(arg, AuditArgPrefix) {
+				delete(apiServer.ExtraArgs, arg)
+			}
+		}
+		return
+	}
+	args := map[string]string{}
+	if audit.LogPath != "" {
+		args[AuditLogPath] = audit.LogPath
+		mergeControlPlaneComponent(apiServer, v2.ComponentSpec{ExtraVolumes: []v2.HostPathMount{{
+			Name:      AuditLogVolume,
+			HostPath:  filepath.Dir(audit.LogPath),
+			MountPath: filepath.Dir(audit.LogPath),
+			PathType:  auditLogPathType,
+		}}})
+	}
+	if audit.MaxAge > 0 {
+		args[AuditLogMaxAge] = strconv.Itoa(audit.MaxAge)
+	}
+	if audit.MaxBackup > 0 {
+		args[AuditLogMaxBack] = strconv.Itoa(audit.MaxBackup)
+	}
+	if audit.MaxSize > 0 {
+		args[AuditLogMaxSize] = strconv.Itoa(audit.MaxSize)
+	}
+	if len(args) != 0 {
+		apiServer.ExtraArgs = mergeExtraArgs(apiServer.ExtraArgs, args)
+	}
+}
+
+// getAuditPolicyFile returns the audit policy file in rootfs which is copied to masters.
+func (k *KubeadmRuntime) getAuditPolicyFile() string {
+	if f := k.Spec.Kubernetes.Audit.PolicyFile; f != "" {
+		return filepath.Join(k.getRootfs(), f)
+	}
+	return filepath.Join(k.getStaticFileDir(), AuditPolicyYml)
+}
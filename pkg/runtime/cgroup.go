@@ -0,0 +1,124 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/facts"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	RemoteReadDockerDaemon  = "cat /etc/docker/daemon.json 2>/dev/null || true"
+	RemoteWriteDockerDaemon = "mkdir -p /etc/docker && printf '%%s\\n' %s > /etc/docker/daemon.json && systemctl restart docker"
+	// RemoteSetContainerdCgroupDriver fails if config.toml has no SystemdCgroup, which containerd does not use then.
+	RemoteSetContainerdCgroupDriver = `grep -q "SystemdCgroup = " /etc/containerd/config.toml && \
+sed -i "s/SystemdCgroup = .*/SystemdCgroup = %t/" /etc/containerd/config.toml && systemctl restart containerd`
+
+	dockerExecOptsKey     = "exec-opts"
+	dockerCgroupDriverOpt = "native.cgroupdriver="
+)
+
+// loadKubeletCgroupDriver keeps the cgroup driver of kubelet in Clusterfile, call it after merging the kubeadm configs,
+// as the cgroup driver of kubelet is set to the one of each host later.
+func (k *KubeadmRuntime) loadKubeletCgroupDriver() {
+	k.KubeletCgroupDriver = k.KubeletConfiguration.CgroupDriver
+}
+
+// alignCgroupDriver returns the cgroup driver of kubelet on host, it is the one in Clusterfile, or systemd on the
+// host of cgroup v2, or the one of container runtime. The container runtime is configured to it if they differ.
+func (k *KubeadmRuntime) alignCgroupDriver(host string) (string, error) {
+	current := k.getCgroupDriverFromShell(host)
+	driver := k.KubeletCgroupDriver
+	if driver == "" {
+		h, err := facts.Get(k.Cluster, host)
+		if err != nil {
+			return "", fmt.Errorf("failed to get cgroup version of %s: %v", host, err)
+		}
+		if !h.CgroupV2() {
+			return current, nil
+		}
+		// kubelet and container runtime must both use systemd on cgroup v2.
+		driver = DefaultSystemdCgroupDriver
+	}
+	if driver == current {
+		return driver, nil
+	}
+	logger.Info("set the cgroup driver of container runtime on %s from %s to %s", host, current, driver)
+	if err := k.setRuntimeCgroupDriver(host, driver); err != nil {
+		return "", fmt.Errorf("failed to set the cgroup driver of container runtime on %s to %s: %v", host, driver, err)
+	}
+	return driver, nil
+}
+
+func (k *KubeadmRuntime) setRuntimeCgroupDriver(host, driver string) error {
+	socket := k.InitConfiguration.NodeRegistration.CRISocket
+	if strings.HasSuffix(socket, DefaultContainerdCRISocket) {
+		_, err := k.cmdOnHost(host, fmt.Sprintf(RemoteSetContainerdCgroupDriver, driver == DefaultSystemdCgroupDriver))
+		return err
+	}
+	if !isDockershim(socket) && !isCRIDockerd(socket) {
+		return fmt.Errorf("the container runtime of %s is not supported, set its cgroup driver to %s manually", socket, driver)
+	}
+	daemon, err := k.cmdOnHost(host, RemoteReadDockerDaemon)
+	if err != nil {
+		return err
+	}
+	data, err := setDockerCgroupDriver([]byte(daemon), driver)
+	if err != nil {
+		return err
+	}
+	_, err = k.cmdOnHost(host, remoteWriteDockerDaemon(data))
+	return err
+}
+
+func (k *KubeadmRuntime) cmdOnHost(host, cmd string) (string, error) {
+	ssh, err := k.getHostSSHClient(host)
+	if err != nil {
+		return "", err
+	}
+	out, err := ssh.Cmd(host, cmd)
+	return string(out), err
+}
+
+// remoteWriteDockerDaemon quotes daemon.json, the options kept in it may have any characters.
+func remoteWriteDockerDaemon(data []byte) string {
+	return fmt.Sprintf(RemoteWriteDockerDaemon, utils.ShellQuote(string(data)))
+}
+
+// setDockerCgroupDriver replaces native.cgroupdriver in exec-opts of daemon.json, keeping the other options.
+func setDockerCgroupDriver(data []byte, driver string) ([]byte, error) {
+	daemon := map[string]interface{}{}
+	if strings.TrimSpace(string(data)) != "" {
+		if err := json.Unmarshal(data, &daemon); err != nil {
+			return nil, fmt.Errorf("failed to decode daemon.json: %v", err)
+		}
+	}
+	opts := []interface{}{dockerCgroupDriverOpt + driver}
+	if raw, ok := daemon[dockerExecOptsKey].([]interface{}); ok {
+		for _, opt := range raw {
+			if s, ok := opt.(string); ok && strings.HasPrefix(strings.ReplaceAll(s, " ", ""), dockerCgroupDriverOpt) {
+				continue
+			}
+			opts = append(opts, opt)
+		}
+	}
+	daemon[dockerExecOptsKey] = opts
+	return json.MarshalIndent(daemon, "", "  ")
+}
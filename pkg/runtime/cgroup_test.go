@@ -0,0 +1,80 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetDockerCgroupDriver(t *testing.T) {
+	tests := []struct {
+		name   string
+		daemon string
+		want   map[string]interface{}
+	}{
+		{"no daemon.json", "", map[string]interface{}{
+			"exec-opts": []interface{}{"native.cgroupdriver=systemd"},
+		}},
+		{"driver replaced", `{"exec-opts": ["native.cgroupdriver = cgroupfs", "native.umask=normal"], "log-driver": "json-file"}`, map[string]interface{}{
+			"exec-opts":  []interface{}{"native.cgroupdriver=systemd", "native.umask=normal"},
+			"log-driver": "json-file",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := setDockerCgroupDriver([]byte(tt.daemon), DefaultSystemdCgroupDriver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]interface{}{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("setDockerCgroupDriver() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := setDockerCgroupDriver([]byte("{"), DefaultSystemdCgroupDriver); err == nil {
+		t.Errorf("expected error of invalid daemon.json")
+	}
+}
+
+func TestRemoteWriteDockerDaemon(t *testing.T) {
+	data, err := setDockerCgroupDriver([]byte(`{"log-opts": {"labels": "it's $HOME`+"`id`"+`"}, "data-root": "C:\\\\docker"}`), DefaultSystemdCgroupDriver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "daemon.json")
+	cmd := strings.Replace(remoteWriteDockerDaemon(data), "/etc/docker/daemon.json", file, 1)
+	cmd = strings.Replace(cmd, "mkdir -p /etc/docker", "true", 1)
+	cmd = strings.Replace(cmd, "systemctl restart docker", "true", 1)
+	if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+		t.Fatalf("RemoteWriteDockerDaemon error = %v: %s", err, out)
+	}
+	written, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSuffix(string(written), "\n"); got != string(data) {
+		t.Errorf("written daemon.json = %s, want %s", got, data)
+	}
+}
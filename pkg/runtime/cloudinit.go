@@ -0,0 +1,207 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/alibaba/sealer/ipvs"
+	"github.com/alibaba/sealer/pkg/env"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const (
+	// the same as filesystem.RemoteChmod, which runs init.sh of rootfs.
+	cloudInitRootfsInitCmd = "cd %s && chmod +x scripts/* && cd scripts && bash init.sh"
+	DefaultJoinTokenTTL    = "24h0m0s"
+)
+
+// CloudInitOptions is how the generated user-data provisions a node.
+type CloudInitOptions struct {
+	// RootfsURL is where to download the rootfs tarball, like: tar -czf rootfs.tar.gz -C /var/lib/sealer/data/my-cluster/rootfs .
+	RootfsURL string
+	// TokenTTL of the bootstrap token embedded, 0 means never expire.
+	TokenTTL string
+}
+
+type cloudInitFile struct {
+	Path    string
+	Content string
+	// Mode is set by chmod if it is not empty, it must be octal.
+	Mode string
+}
+
+var fileModeOctal = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+type cloudInitData struct {
+	ClusterName string
+	Rootfs      string
+	RootfsURL   string
+	InitCmd     string
+	// HostsBlock is the managed block of /etc/hosts rendered by etchosts.Update, it replaces the one of the host.
+	HostsBlock string
+	Files      []cloudInitFile
+	IPVSCmd    string
+	JoinCmd    string
+	Lvscare    cloudInitFile
+}
+
+const cloudInitTemplate = `#!/bin/bash
+# generated by sealer, it joins this host to cluster {{.ClusterName}} as a worker.
+set -e{{- if .RootfsURL}}
+mkdir -p {{.Rootfs}}
+until curl -fsSL {{.RootfsURL}} | tar -xz -C {{.Rootfs}}; do sleep 10; done
+{{- end}}
+{{.InitCmd}}
+{{- if .HostsBlock}}
+` + etchosts.RemoteRemoveBlock + `
+cat >> ` + etchosts.Path + ` <<'SEALER_EOF'
+{{.HostsBlock}}
+SEALER_EOF
+{{- end}}
+{{- range .Files}}
+mkdir -p $(dirname {{.Path}})
+cat > {{.Path}} <<'SEALER_EOF'
+{{.Content}}
+SEALER_EOF
+{{- if .Mode}}
+chmod {{.Mode}} {{.Path}}
+{{- end}}
+{{- end}}
+{{.IPVSCmd}}
+# the apiserver may not be ready if the host boots with the cluster.
+until {{.JoinCmd}}; do kubeadm reset -f; sleep 10; done
+mkdir -p $(dirname {{.Lvscare.Path}})
+cat > {{.Lvscare.Path}} <<'SEALER_EOF'
+{{.Lvscare.Content}}
+SEALER_EOF
+`
+
+// GenerateCloudInit generates the user-data to provision a worker on boot without SSH from the operator machine,
+// it embeds a bootstrap token created on master0, the join config, the registry cert and the rootfs URL.
+func GenerateCloudInit(cluster *v2.Cluster, clusterfile string, opts CloudInitOptions) ([]byte, error) {
+	if opts.RootfsURL == "" {
+		return nil, fmt.Errorf("rootfs URL is required to generate cloud-init")
+	}
+	if opts.TokenTTL == "" {
+		opts.TokenTTL = DefaultJoinTokenTTL
+	}
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return nil, err
+	}
+	k := i.(*KubeadmRuntime)
+	if err := k.MergeKubeadmConfig(); err != nil {
+		return nil, err
+	}
+	if err := k.createJoinToken(opts.TokenTTL); err != nil {
+		return nil, err
+	}
+
+	// the hosts of a cluster are expected to have the same cgroup driver as master0.
+	k.setCgroupDriver(k.getCgroupDriverFromShell(k.getMaster0IP()))
+	regCert, err := ioutil.ReadFile(filepath.Join(k.getCertsDir(), SeaHub+".crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry cert: %v", err)
+	}
+	data, err := k.newJoinScriptData(regCert)
+	if err != nil {
+		return nil, err
+	}
+	data.RootfsURL = opts.RootfsURL
+	return renderJoinScript(data)
+}
+
+// newJoinScriptData collects what a worker needs to join by itself, the join token must have been set.
+func (k *KubeadmRuntime) newJoinScriptData(regCert []byte) (cloudInitData, error) {
+	k.setAPIServerEndpoint(fmt.Sprintf("%s:6443", k.getVIP()))
+	k.cleanJoinLocalAPIEndPoint()
+	joinConfig, err := k.marshalKubeadmConfigs(k.JoinConfiguration, k.KubeletConfiguration)
+	if err != nil {
+		return cloudInitData{}, err
+	}
+
+	var masters string
+	for _, master := range k.getMasterIPList() {
+		masters += fmt.Sprintf(" --rs %s:6443", master)
+	}
+	data := cloudInitData{
+		ClusterName: k.getClusterName(),
+		Rootfs:      k.getRootfs(),
+		InitCmd:     env.NewEnvProcessor(k.Cluster).WrapperShell("", fmt.Sprintf(cloudInitRootfsInitCmd, k.getRootfs())),
+		HostsBlock:  k.joinScriptHostsBlock(),
+		Files: []cloudInitFile{
+			{Path: fmt.Sprintf("%s/%s/%s.crt", DockerCertDir, SeaHub, SeaHub), Content: string(regCert)},
+			{Path: fmt.Sprintf("%s/%s:%d/%s.crt", DockerCertDir, SeaHub, k.getDefaultRegistryPort(), SeaHub), Content: string(regCert)},
+			{Path: filepath.Join(k.getRootfs(), "kubeadm-join-config.yaml"), Content: string(joinConfig)},
+		},
+		IPVSCmd: fmt.Sprintf(RemoteAddIPVS, k.getVIP(), masters),
+		JoinCmd: k.Command(k.getKubeVersion(), JoinNode),
+		Lvscare: cloudInitFile{
+			Path:    LvscareDefaultStaticPodFileName,
+			Content: ipvs.LvsStaticPodYaml(k.getVIP(), k.getMasterIPList(), ""),
+		},
+	}
+	cf := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+	if cf.Username != "" && cf.Password != "" {
+		// the credentials are written to the docker configs of the new host, instead of docker login in the script.
+		auth, err := MergeDockerConfig(nil, cf.server(), cf.Username, cf.Password)
+		if err != nil {
+			return data, err
+		}
+		for _, path := range RegistryAuthPaths {
+			data.Files = append(data.Files, cloudInitFile{Path: path, Content: string(auth), Mode: "600"})
+		}
+	}
+	return data, nil
+}
+
+// joinScriptHostsBlock returns the managed block of /etc/hosts of a worker, without the domains of the dns provider.
+func (k *KubeadmRuntime) joinScriptHostsBlock() string {
+	var set []etchosts.Entry
+	for _, e := range []etchosts.Entry{
+		getRegistryEntry(k.getRootfs(), k.getMaster0IP()),
+		{IP: k.getVIP(), Domain: k.getAPIServerDomain()},
+	} {
+		if !k.managesDomain(e.Domain) {
+			set = append(set, e)
+		}
+	}
+	return strings.TrimSuffix(etchosts.Update("", set), "\n")
+}
+
+func renderJoinScript(data cloudInitData) ([]byte, error) {
+	for _, f := range append(data.Files, data.Lvscare) {
+		if f.Mode != "" && !fileModeOctal.MatchString(f.Mode) {
+			return nil, fmt.Errorf("invalid mode %q of %s, it must be octal like 600", f.Mode, f.Path)
+		}
+	}
+	t, err := template.New("cloud-init").Parse(cloudInitTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render cloud-init: %v", err)
+	}
+	return buf.Bytes(), nil
+}
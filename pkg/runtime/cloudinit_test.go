@@ -0,0 +1,139 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/sealer/pkg/dns"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestKubeadmRuntime_joinScriptHostsBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider v2.DNSProviderSpec
+		want     string
+	}{
+		{"hosts", v2.DNSProviderSpec{}, etchosts.BeginMarker + "\n192.168.0.2 sea.hub\n10.103.97.2 apiserver.cluster.local\n" + etchosts.EndMarker},
+		{"apiserver domain by dns provider", v2.DNSProviderSpec{Name: dns.ProviderRoute53, Zone: "cluster.local"},
+			etchosts.BeginMarker + "\n192.168.0.2 sea.hub\n" + etchosts.EndMarker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newDomainsRuntime(tt.provider).joinScriptHostsBlock(); got != tt.want {
+				t.Errorf("joinScriptHostsBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderJoinScript_hosts(t *testing.T) {
+	k := newDomainsRuntime(v2.DNSProviderSpec{})
+	script, err := renderJoinScript(cloudInitData{HostsBlock: k.joinScriptHostsBlock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the block written before, like by a former run of the script, is replaced.
+	hosts := filepath.Join(t.TempDir(), "hosts")
+	content := "127.0.0.1 localhost\n" + etchosts.BeginMarker + "\n192.168.0.9 sea.hub\n" + etchosts.EndMarker + "\n"
+	if err := ioutil.WriteFile(hosts, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// run the lines of the script writing /etc/hosts only.
+	script = script[strings.Index(string(script), etchosts.RemoteRemoveBlock):]
+	end := strings.Index(string(script), "\nSEALER_EOF\n") + len("\nSEALER_EOF\n")
+	cmd := strings.Replace(string(script[:end]), etchosts.Path, hosts, -1)
+	if out, err := exec.Command("bash", "-c", cmd).CombinedOutput(); err != nil {
+		t.Fatalf("failed to run join script: %v, %s", err, out)
+	}
+	data, err := ioutil.ReadFile(hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := etchosts.Update(content, []etchosts.Entry{{IP: "192.168.0.2", Domain: "sea.hub"}, {IP: DefaultVIP, Domain: DefaultAPIserverDomain}})
+	if string(data) != want {
+		t.Errorf("/etc/hosts = %q, want %q", data, want)
+	}
+}
+
+func TestRenderJoinScript(t *testing.T) {
+	data := cloudInitData{
+		ClusterName: "my-cluster",
+		Rootfs:      "/var/lib/sealer/data/my-cluster/rootfs",
+		RootfsURL:   "http://192.168.0.1/rootfs.tar.gz",
+		InitCmd:     "cd /var/lib/sealer/data/my-cluster/rootfs && chmod +x scripts/* && cd scripts && bash init.sh",
+		HostsBlock:  etchosts.BeginMarker + "\n192.168.0.2 sea.hub\n" + etchosts.EndMarker,
+		Files: []cloudInitFile{
+			{Path: "/etc/docker/certs.d/sea.hub/sea.hub.crt", Content: "cert"},
+			{Path: "/root/.docker/config.json", Content: "{}", Mode: "600"},
+		},
+		IPVSCmd: "seautil ipvs --vs 10.103.97.2:6443 --rs 192.168.0.2:6443 --health-path /healthz --health-schem https --run-once",
+		JoinCmd: "kubeadm join --config=/var/lib/sealer/data/my-cluster/rootfs/kubeadm-join-config.yaml",
+		Lvscare: cloudInitFile{Path: "/etc/kubernetes/manifests/kube-lvscare.yaml", Content: "kind: Pod"},
+	}
+	want := `#!/bin/bash
+# generated by sealer, it joins this host to cluster my-cluster as a worker.
+set -e
+mkdir -p /var/lib/sealer/data/my-cluster/rootfs
+until curl -fsSL http://192.168.0.1/rootfs.tar.gz | tar -xz -C /var/lib/sealer/data/my-cluster/rootfs; do sleep 10; done
+cd /var/lib/sealer/data/my-cluster/rootfs && chmod +x scripts/* && cd scripts && bash init.sh
+` + etchosts.RemoteRemoveBlock + `
+cat >> /etc/hosts <<'SEALER_EOF'
+# BEGIN sealer managed hosts
+192.168.0.2 sea.hub
+# END sealer managed hosts
+SEALER_EOF
+mkdir -p $(dirname /etc/docker/certs.d/sea.hub/sea.hub.crt)
+cat > /etc/docker/certs.d/sea.hub/sea.hub.crt <<'SEALER_EOF'
+cert
+SEALER_EOF
+mkdir -p $(dirname /root/.docker/config.json)
+cat > /root/.docker/config.json <<'SEALER_EOF'
+{}
+SEALER_EOF
+chmod 600 /root/.docker/config.json
+seautil ipvs --vs 10.103.97.2:6443 --rs 192.168.0.2:6443 --health-path /healthz --health-schem https --run-once
+# the apiserver may not be ready if the host boots with the cluster.
+until kubeadm join --config=/var/lib/sealer/data/my-cluster/rootfs/kubeadm-join-config.yaml; do kubeadm reset -f; sleep 10; done
+mkdir -p $(dirname /etc/kubernetes/manifests/kube-lvscare.yaml)
+cat > /etc/kubernetes/manifests/kube-lvscare.yaml <<'SEALER_EOF'
+kind: Pod
+SEALER_EOF
+`
+	got, err := renderJoinScript(data)
+	if err != nil {
+		t.Fatalf("renderJoinScript() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("renderJoinScript() = %s, want %s", got, want)
+	}
+
+	for _, mode := range []string{"600; rm -rf /", "u+x", "rw", "99", "06000"} {
+		data.Files[1].Mode = mode
+		if _, err := renderJoinScript(data); err == nil {
+			t.Errorf("expected error of mode %q", mode)
+		}
+	}
+	data.Files[1].Mode = "0644"
+	if _, err := renderJoinScript(data); err != nil {
+		t.Errorf("renderJoinScript() with mode 0644 error = %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/utils"
+)
+
+/*
+The images of control plane components in Clusterfile, like a patched kube-apiserver:
+
+spec:
+  kubernetes:
+    apiServer:
+      image: registry.example.com/k8s/kube-apiserver:v1.22.8-hotfix.1
+
+They are pushed to the image repository of the cluster on master0. etcd uses the image tag of kubeadm config, and the
+others are set by the kubeadm patches written to the rootfs of masters, which kubeadm init, join and upgrade apply.
+*/
+
+const (
+	KubeAPIServer         = "kube-apiserver"
+	KubeControllerManager = "kube-controller-manager"
+	KubeScheduler         = "kube-scheduler"
+	Etcd                  = "etcd"
+
+	RemotePushImage = `if docker info >/dev/null 2>&1; then docker pull %[1]s && docker tag %[1]s %[2]s && docker push %[2]s; \
+else ctr -n k8s.io images pull %[1]s && ctr -n k8s.io images tag --force %[1]s %[2]s && ctr -n k8s.io images push --tlscacert %[3]s %[2]s; fi`
+	RemoteWriteImagePatch = `mkdir -p %[1]s && printf '%%s\n' %[2]s > %[1]s/%[3]s`
+	// ImagePatchSuffix sorts the image patches after the ones of rootfs with the same target, like kube-apiserver+strategic.yaml.
+	ImagePatchSuffix = "-image+strategic.yaml"
+	imagePatch       = `spec:
+  containers:
+  - name: %s
+    image: %s`
+)
+
+// componentImage is a control plane component whose image is set in Clusterfile.
+type componentImage struct {
+	// Name is the name of the component, its static pod and container
+	Name string
+	// Source is the image in Clusterfile
+	Source string
+	// Target is the image in the image repository of the cluster, which the static pod runs
+	Target string
+}
+
+func (k *KubeadmRuntime) getComponentImages() []componentImage {
+	spec := k.Spec.Kubernetes
+	var images []componentImage
+	for _, c := range []struct {
+		name  string
+		image string
+	}{
+		{KubeAPIServer, spec.APIServer.Image},
+		{KubeControllerManager, spec.ControllerManager.Image},
+		{KubeScheduler, spec.Scheduler.Image},
+		{Etcd, spec.Etcd.Image},
+	} {
+		if c.image == "" {
+			continue
+		}
+		images = append(images, componentImage{
+			Name:   c.name,
+			Source: c.image,
+			Target: fmt.Sprintf("%s/%s:%s", k.ImageRepository, c.name, imageTag(c.image)),
+		})
+	}
+	return images
+}
+
+// imageTag returns the tag of image, empty if it has none.
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
+// getRegistryRepository returns the prefix of the images in the registry of sealer, like sea.hub:5000/.
+func (k *KubeadmRuntime) getRegistryRepository() string {
+	cf := GetRegistryConfig(k.getImageMountDir(), k.getMaster0IP())
+	return fmt.Sprintf("%s:%s/", cf.Domain, cf.Port)
+}
+
+// validateComponentImages checks the images of control plane components in Clusterfile can be used, and their
+// versions meet the version skew policy with kubelet of CloudImage.
+func (k *KubeadmRuntime) validateComponentImages() error {
+	if k.Spec.Kubernetes.Kubelet.Image != "" {
+		return fmt.Errorf("spec.kubernetes.kubelet.image is not supported, kubelet is installed on hosts by CloudImage")
+	}
+	versions := map[string]string{}
+	for _, c := range k.getComponentImages() {
+		tag := imageTag(c.Source)
+		if tag == "" {
+			return fmt.Errorf("image %s of %s must have a tag", c.Source, c.Name)
+		}
+		if c.Name != Etcd && !VersionCompare(k.getKubeVersion(), V1190) {
+			return fmt.Errorf("image of %s is set by kubeadm patches, which are not supported by kubernetes %s", c.Name, k.getKubeVersion())
+		}
+		if c.Source != c.Target && !strings.HasPrefix(k.ImageRepository, k.getRegistryRepository()) {
+			return fmt.Errorf("image %s of %s is not in the image repository %s, which is not the registry of sealer to push it to",
+				c.Source, c.Name, k.ImageRepository)
+		}
+		versions[c.Name] = tag
+	}
+	kubelet := k.getKubeVersion()
+	for _, name := range []string{KubeAPIServer, KubeControllerManager, KubeScheduler} {
+		if _, ok := versions[name]; !ok {
+			versions[name] = kubelet
+		}
+	}
+	return checkVersionSkew(kubelet, versions[KubeAPIServer], versions[KubeControllerManager], versions[KubeScheduler])
+}
+
+// checkVersionSkew checks the versions of kubelet and control plane components against
+// https://kubernetes.io/releases/version-skew-policy/.
+func checkVersionSkew(kubelet, apiServer, controllerManager, scheduler string) error {
+	parse := func(name, version string) (*semver.Version, error) {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the version of %s from %s: %v", name, version, err)
+		}
+		return v, nil
+	}
+	a, err := parse(KubeAPIServer, apiServer)
+	if err != nil {
+		return err
+	}
+	// within returns true if v is not newer than kube-apiserver, nor more than max minor versions older.
+	within := func(v *semver.Version, max int64) bool {
+		older := int64(a.Minor()) - int64(v.Minor())
+		return v.Major() == a.Major() && older >= 0 && older <= max
+	}
+	v, err := parse("kubelet", kubelet)
+	if err != nil {
+		return err
+	}
+	if !within(v, 2) {
+		return fmt.Errorf("kubelet %s must not be newer than kube-apiserver %s, nor more than 2 minor versions older", kubelet, apiServer)
+	}
+	for _, c := range []struct {
+		name    string
+		version string
+	}{{KubeControllerManager, controllerManager}, {KubeScheduler, scheduler}} {
+		v, err := parse(c.name, c.version)
+		if err != nil {
+			return err
+		}
+		if !within(v, 1) {
+			return fmt.Errorf("%s %s must not be newer than kube-apiserver %s, nor more than 1 minor version older", c.name, c.version, apiServer)
+		}
+	}
+	return nil
+}
+
+// PushComponentImages pushes the images of control plane components in Clusterfile to the image repository
+// of the cluster on master0, the registry must be running.
+func (k *KubeadmRuntime) PushComponentImages() error {
+	var cmds []string
+	caCert := fmt.Sprintf("%s/%s:%d/%s.crt", DockerCertDir, SeaHub, k.getDefaultRegistryPort(), SeaHub)
+	for _, c := range k.getComponentImages() {
+		if c.Source == c.Target {
+			continue
+		}
+		logger.Info("push image %s of %s to %s", c.Source, c.Name, c.Target)
+		cmds = append(cmds, fmt.Sprintf(RemotePushImage, c.Source, c.Target, caCert))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	ssh, err := k.getHostSSHClient(k.getMaster0IP())
+	if err != nil {
+		return err
+	}
+	if err := ssh.CmdAsync(k.getMaster0IP(), cmds...); err != nil {
+		return fmt.Errorf("failed to push the images of control plane components: %v", err)
+	}
+	return nil
+}
+
+// getImagePatches returns the kubeadm patches setting the images of control plane components, keyed by file name.
+func (k *KubeadmRuntime) getImagePatches() map[string]string {
+	patches := map[string]string{}
+	for _, c := range k.getComponentImages() {
+		// kubeadm sets the image of etcd by its image tag.
+		if c.Name == Etcd {
+			continue
+		}
+		patches[c.Name+ImagePatchSuffix] = fmt.Sprintf(imagePatch, c.Name, c.Target)
+	}
+	return patches
+}
+
+// writeImagePatches writes the image patches to the patches dir in the rootfs of masters.
+func (k *KubeadmRuntime) writeImagePatches(masters []string) error {
+	patches := k.getImagePatches()
+	if len(patches) == 0 {
+		return nil
+	}
+	var cmds []string
+	for name, patch := range patches {
+		cmds = append(cmds, fmt.Sprintf(RemoteWriteImagePatch, filepath.Join(k.getRootfs(), KubeadmPatchesDir), utils.ShellQuote(patch), name))
+	}
+	errCh := make(chan error, len(masters))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, master := range masters {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			ssh, err := k.getHostSSHClient(ip)
+			if err != nil {
+				errCh <- result.OnHost(ip, err)
+				return
+			}
+			if err := ssh.CmdAsync(ip, cmds...); err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("failed to write image patches on %s: %v", ip, err))
+			}
+		}(master)
+	}
+	wg.Wait()
+	return ReadChanError(errCh)
+}
+
+func (k *KubeadmRuntime) WriteImagePatchesOnMaster0() error {
+	return k.writeImagePatches([]string{k.getMaster0IP()})
+}
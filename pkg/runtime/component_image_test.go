@@ -0,0 +1,81 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestImageTag(t *testing.T) {
+	for image, want := range map[string]string{
+		"registry.example.com/k8s/kube-apiserver:v1.22.8-hotfix.1": "v1.22.8-hotfix.1",
+		"127.0.0.1:5000/kube-apiserver":                            "",
+		"kube-apiserver@sha256:0123":                               "",
+	} {
+		if got := imageTag(image); got != want {
+			t.Errorf("imageTag(%s) = %s, want %s", image, got, want)
+		}
+	}
+}
+
+func TestCheckVersionSkew(t *testing.T) {
+	tests := []struct {
+		kubelet, apiServer, controllerManager, scheduler string
+		wantErr                                          string
+	}{
+		{"v1.22.8", "v1.22.8-hotfix.1", "v1.22.8", "v1.22.8", ""},
+		{"v1.22.8", "v1.23.5", "v1.22.8", "v1.23.5", ""},
+		{"v1.22.8", "v1.21.14", "v1.21.14", "v1.21.14", "kubelet v1.22.8 must not be newer"},
+		{"v1.22.8", "v1.24.3", "v1.22.8", "v1.24.3", "kube-controller-manager v1.22.8 must not be newer"},
+		{"v1.22.8", "latest", "v1.22.8", "v1.22.8", "failed to get the version of kube-apiserver"},
+	}
+	for _, tt := range tests {
+		err := checkVersionSkew(tt.kubelet, tt.apiServer, tt.controllerManager, tt.scheduler)
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("checkVersionSkew(%s, %s, %s, %s) error = %v, want %q", tt.kubelet, tt.apiServer,
+				tt.controllerManager, tt.scheduler, err, tt.wantErr)
+		}
+	}
+}
+
+func TestComponentImages(t *testing.T) {
+	k := &KubeadmRuntime{Cluster: &v2.Cluster{}, KubeadmConfig: &KubeadmConfig{}}
+	k.Cluster.Name = "component-image-test"
+	k.Spec.Hosts = []v2.Host{{IPS: []string{"192.168.0.2"}}}
+	k.Spec.Kubernetes.APIServer.Image = "registry.example.com/k8s/kube-apiserver:v1.22.8-hotfix.1"
+	k.Spec.Kubernetes.Etcd.Image = "registry.example.com/k8s/etcd:3.5.0-1"
+	k.KubernetesVersion = "v1.22.8"
+	k.MergeKubernetesSpec(k.Spec.Kubernetes)
+	k.ImageRepository = "sea.hub:5000/library"
+
+	if err := k.validateComponentImages(); err != nil {
+		t.Fatalf("validateComponentImages() error = %v", err)
+	}
+	if k.Etcd.Local == nil || k.Etcd.Local.ImageTag != "3.5.0-1" {
+		t.Errorf("etcd image tag = %v, want 3.5.0-1", k.Etcd.Local)
+	}
+	patches := k.getImagePatches()
+	if len(patches) != 1 || !strings.Contains(patches["kube-apiserver-image+strategic.yaml"], "image: sea.hub:5000/library/kube-apiserver:v1.22.8-hotfix.1") {
+		t.Errorf("getImagePatches() = %v", patches)
+	}
+
+	k.ImageRepository = "registry.example.com/mirror"
+	if err := k.validateComponentImages(); err == nil || !strings.Contains(err.Error(), "not the registry of sealer") {
+		t.Errorf("validateComponentImages() error = %v, want the image not pushable", err)
+	}
+}
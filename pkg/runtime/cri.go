@@ -0,0 +1,163 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+)
+
+const (
+	// V1240 removed dockershim, docker is used through cri-dockerd since then.
+	V1240 = "v1.24.0"
+
+	DefaultCRIDockerdSocket = "unix:///var/run/cri-dockerd.sock"
+	CRIDockerdService       = `[Unit]
+Description=CRI Interface for Docker Application Container Engine
+After=network-online.target firewalld.service docker.service
+Wants=network-online.target
+Requires=cri-dockerd.socket
+
+[Service]
+Type=notify
+ExecStart=/usr/bin/cri-dockerd --container-runtime-endpoint fd:// --network-plugin=cni --pod-infra-container-image=%s
+ExecReload=/bin/kill -s HUP $MAINPID
+Restart=always
+StartLimitBurst=3
+StartLimitInterval=60s
+LimitNOFILE=infinity
+LimitNPROC=infinity
+LimitCORE=infinity
+Delegate=yes
+KillMode=process
+
+[Install]
+WantedBy=multi-user.target`
+	CRIDockerdSocket = `[Unit]
+Description=CRI Docker Socket for the API
+PartOf=cri-dockerd.service
+
+[Socket]
+ListenStream=/var/run/cri-dockerd.sock
+SocketMode=0660
+SocketUser=root
+SocketGroup=root
+
+[Install]
+WantedBy=sockets.target`
+	// RemoteInstallCRIDockerd installs cri-dockerd of rootfs/bin, unless init.sh of the image has installed it.
+	RemoteInstallCRIDockerd = `if ! command -v cri-dockerd >/dev/null; then \
+[ -f %[1]s/bin/cri-dockerd ] || { echo "cri-dockerd is not found in %[1]s/bin, kubernetes 1.24+ requires it for docker"; exit 1; }; \
+cp -f %[1]s/bin/cri-dockerd /usr/bin/cri-dockerd && chmod +x /usr/bin/cri-dockerd; fi && \
+echo '%[2]s' > /etc/systemd/system/cri-dockerd.service && echo '%[3]s' > /etc/systemd/system/cri-dockerd.socket && \
+systemctl daemon-reload && systemctl enable cri-dockerd.socket cri-dockerd.service && systemctl restart cri-dockerd.socket cri-dockerd.service`
+	RemoteRemoveCRIDockerd = `if [ -f /etc/systemd/system/cri-dockerd.service ]; then \
+systemctl disable --now cri-dockerd.service cri-dockerd.socket; \
+rm -f /etc/systemd/system/cri-dockerd.service /etc/systemd/system/cri-dockerd.socket && systemctl daemon-reload; fi`
+	// criDockerdResetFlag lets kubeadm reset the host running cri-dockerd, which finds the sockets of both
+	// containerd and cri-dockerd and refuses to choose one.
+	criDockerdResetFlag = `$([ -S /var/run/cri-dockerd.sock ] && echo "--cri-socket ` + DefaultCRIDockerdSocket + `")`
+)
+
+// setCRISocket sets the CRI socket kubeadm init and join use, call it after merging the kubeadm configs.
+func (k *KubeadmRuntime) setCRISocket() {
+	socket := k.getCRISocket()
+	k.InitConfiguration.NodeRegistration.CRISocket = socket
+	k.JoinConfiguration.NodeRegistration.CRISocket = socket
+}
+
+// getCRISocket returns the CRI socket in Clusterfile spec.kubernetes.criSocket, or in the metadata of CloudImage,
+// or the one in the kubeadm configs, which is replaced by cri-dockerd for docker on kubernetes 1.24+.
+func (k *KubeadmRuntime) getCRISocket() string {
+	if k.Spec.Kubernetes.CRISocket != "" {
+		return k.Spec.Kubernetes.CRISocket
+	}
+	metadata, err := LoadMetadata(k.getImageMountDir())
+	if err != nil {
+		logger.Warn("failed to load the CRI socket of CloudImage: %v", err)
+	}
+	if metadata != nil && metadata.CRISocket != "" {
+		return metadata.CRISocket
+	}
+	socket := k.InitConfiguration.NodeRegistration.CRISocket
+	if isDockershim(socket) && k.getKubeVersion() != "" && VersionCompare(k.getKubeVersion(), V1240) {
+		return DefaultCRIDockerdSocket
+	}
+	return socket
+}
+
+func isDockershim(socket string) bool {
+	return socket == "" || strings.HasSuffix(socket, "dockershim.sock")
+}
+
+func isCRIDockerd(socket string) bool {
+	return strings.HasSuffix(socket, "cri-dockerd.sock")
+}
+
+// getResetCRISocketFlag returns the --cri-socket flag of kubeadm reset, cri-dockerd is used if the host runs it
+// and the socket is not known, like resetting the cluster without merging the kubeadm configs.
+func (k *KubeadmRuntime) getResetCRISocketFlag() string {
+	if socket := k.getCRISocket(); socket != "" {
+		return " --cri-socket " + socket
+	}
+	return " " + criDockerdResetFlag
+}
+
+// getPauseImage returns the pause image cri-dockerd runs the pods with, in the image repository of the cluster.
+func (k *KubeadmRuntime) getPauseImage() string {
+	version := "3.9"
+	switch {
+	case !VersionCompare(k.getKubeVersion(), "v1.25.0"):
+		version = "3.7"
+	case !VersionCompare(k.getKubeVersion(), "v1.27.0"):
+		version = "3.8"
+	}
+	return fmt.Sprintf("%s/pause:%s", k.ImageRepository, version)
+}
+
+// deployCRIDockerd installs and starts cri-dockerd on hosts if kubeadm uses it.
+func (k *KubeadmRuntime) deployCRIDockerd(hosts []string) error {
+	if !isCRIDockerd(k.InitConfiguration.NodeRegistration.CRISocket) {
+		return nil
+	}
+	cmd := fmt.Sprintf(RemoteInstallCRIDockerd, k.getRootfs(), fmt.Sprintf(CRIDockerdService, k.getPauseImage()), CRIDockerdSocket)
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			ssh, err := k.getHostSSHClient(ip)
+			if err != nil {
+				errCh <- result.OnHost(ip, err)
+				return
+			}
+			if err := ssh.CmdAsync(ip, cmd); err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("failed to deploy cri-dockerd on %s: %v", ip, err))
+			}
+		}(host)
+	}
+	wg.Wait()
+	return ReadChanError(errCh)
+}
+
+func (k *KubeadmRuntime) DeployCRIDockerdOnMaster0() error {
+	return k.deployCRIDockerd([]string{k.getMaster0IP()})
+}
@@ -0,0 +1,67 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestGetCRISocket(t *testing.T) {
+	tests := []struct {
+		name      string
+		specified string
+		merged    string
+		version   string
+		want      string
+	}{
+		{"docker before 1.24", "", DefaultDockerCRISocket, "v1.22.8", DefaultDockerCRISocket},
+		{"docker on 1.24", "", DefaultDockerCRISocket, "v1.24.1", DefaultCRIDockerdSocket},
+		{"containerd on 1.24", "", DefaultContainerdCRISocket, "v1.24.1", DefaultContainerdCRISocket},
+		{"Clusterfile wins", "unix:///run/crio/crio.sock", DefaultDockerCRISocket, "v1.24.1", "unix:///run/crio/crio.sock"},
+		{"kubeadm configs not merged", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KubeadmRuntime{
+				Cluster:       &v2.Cluster{},
+				KubeadmConfig: &KubeadmConfig{},
+			}
+			k.Cluster.Name = "cri-socket-test"
+			k.Spec.Kubernetes.CRISocket = tt.specified
+			k.InitConfiguration.NodeRegistration.CRISocket = tt.merged
+			k.KubernetesVersion = tt.version
+			if got := k.getCRISocket(); got != tt.want {
+				t.Errorf("getCRISocket() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPauseImage(t *testing.T) {
+	k := &KubeadmRuntime{KubeadmConfig: &KubeadmConfig{}}
+	k.ImageRepository = "sea.hub:5000"
+	for version, want := range map[string]string{
+		"v1.24.1": "sea.hub:5000/pause:3.7",
+		"v1.26.3": "sea.hub:5000/pause:3.8",
+		"v1.28.0": "sea.hub:5000/pause:3.9",
+	} {
+		k.KubernetesVersion = version
+		if got := k.getPauseImage(); got != want {
+			t.Errorf("getPauseImage() of %s = %s, want %s", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"text/template"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	DefaultNodeLocalDNSIP = "169.254.20.10"
+	NodeLocalDNSImage     = "k8s-dns-node-cache:1.21.1"
+	DefaultDNSUpstream    = "/etc/resolv.conf"
+	// kubeadm takes the 10th IP of the service subnet as the kube-dns service IP.
+	clusterDNSIPIndex = 10
+	RemoteReplaceYaml = `printf '%%s\n' %s | kubectl replace --force -f -`
+)
+
+const kubeDNSServiceTemplate = `apiVersion: v1
+kind: Service
+metadata:
+  name: kube-dns
+  namespace: kube-system
+  labels:
+    k8s-app: kube-dns
+    kubernetes.io/cluster-service: "true"
+    kubernetes.io/name: KubeDNS
+  annotations:
+    prometheus.io/port: "9153"
+    prometheus.io/scrape: "true"
+spec:
+  clusterIP: {{.ClusterDNS}}
+  selector:
+    k8s-app: kube-dns
+  ports:
+  - name: dns
+    port: 53
+    protocol: UDP
+  - name: dns-tcp
+    port: 53
+    protocol: TCP
+  - name: metrics
+    port: 9153
+    protocol: TCP
+`
+
+const coreDNSConfigMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: coredns
+  namespace: kube-system
+data:
+  Corefile: |
+    .:53 {
+        errors
+        health {
+           lameduck 5s
+        }
+        ready
+        kubernetes {{.Domain}} in-addr.arpa ip6.arpa {
+           pods insecure
+           fallthrough in-addr.arpa ip6.arpa
+           ttl 30
+        }
+        prometheus :9153
+{{- if .Hosts}}
+        hosts {
+{{- range .Hosts}}
+           {{.IP}} {{.Domain}}
+{{- end}}
+           fallthrough
+        }
+{{- end}}
+        forward . {{join .Upstreams " "}} {
+           max_concurrent 1000
+        }
+        cache 30
+        loop
+        reload
+        loadbalance
+    }
+{{- range .Snippets}}
+{{indent 4 .}}
+{{- end}}
+`
+
+// node-local-dns forwards all queries to CoreDNS, so the upstreams and snippets of CoreDNS take effect.
+const nodeLocalDNSTemplate = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: node-local-dns
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: kube-dns-upstream
+  namespace: kube-system
+  labels:
+    k8s-app: kube-dns
+spec:
+  ports:
+  - name: dns
+    port: 53
+    protocol: UDP
+    targetPort: 53
+  - name: dns-tcp
+    port: 53
+    protocol: TCP
+    targetPort: 53
+  selector:
+    k8s-app: kube-dns
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: node-local-dns
+  namespace: kube-system
+data:
+  Corefile: |
+    {{.Domain}}:53 {
+        errors
+        cache {
+            success 9984 30
+            denial 9984 5
+        }
+        reload
+        loop
+        bind {{.LocalIP}}{{if .BindClusterDNS}} {{.ClusterDNS}}{{end}}
+        forward . {{if .BindClusterDNS}}__PILLAR__CLUSTER__DNS__{{else}}{{.ClusterDNS}}{{end}} {
+            force_tcp
+        }
+        prometheus :9253
+        health {{.LocalIP}}:8080
+    }
+    .:53 {
+        errors
+        cache 30
+        reload
+        loop
+        bind {{.LocalIP}}{{if .BindClusterDNS}} {{.ClusterDNS}}{{end}}
+        forward . {{if .BindClusterDNS}}__PILLAR__CLUSTER__DNS__{{else}}{{.ClusterDNS}}{{end}}
+        prometheus :9253
+    }
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: node-local-dns
+  namespace: kube-system
+  labels:
+    k8s-app: node-local-dns
+spec:
+  updateStrategy:
+    rollingUpdate:
+      maxUnavailable: 10%
+  selector:
+    matchLabels:
+      k8s-app: node-local-dns
+  template:
+    metadata:
+      labels:
+        k8s-app: node-local-dns
+      annotations:
+        prometheus.io/port: "9253"
+        prometheus.io/scrape: "true"
+    spec:
+      priorityClassName: system-node-critical
+      serviceAccountName: node-local-dns
+      hostNetwork: true
+      dnsPolicy: Default
+      tolerations:
+      - operator: Exists
+      containers:
+      - name: node-cache
+        image: {{.Image}}
+        resources:
+          requests:
+            cpu: 25m
+            memory: 5Mi
+        args: ["-localip", "{{.LocalIP}}{{if .BindClusterDNS}},{{.ClusterDNS}}{{end}}", "-conf", "/etc/Corefile", "-upstreamsvc", "kube-dns-upstream"]
+        securityContext:
+          privileged: true
+        ports:
+        - containerPort: 53
+          name: dns
+          protocol: UDP
+        - containerPort: 53
+          name: dns-tcp
+          protocol: TCP
+        - containerPort: 9253
+          name: metrics
+          protocol: TCP
+        livenessProbe:
+          httpGet:
+            host: {{.LocalIP}}
+            path: /health
+            port: 8080
+          initialDelaySeconds: 60
+          timeoutSeconds: 5
+        volumeMounts:
+        - name: xtables-lock
+          mountPath: /run/xtables.lock
+        - name: config-volume
+          mountPath: /etc/coredns
+      volumes:
+      - name: xtables-lock
+        hostPath:
+          path: /run/xtables.lock
+          type: FileOrCreate
+      - name: config-volume
+        configMap:
+          name: node-local-dns
+          items:
+          - key: Corefile
+            path: Corefile.base
+`
+
+type dnsConfig struct {
+	Domain     string
+	ClusterDNS string
+	LocalIP    string
+	Image      string
+	Upstreams  []string
+	Snippets   []string
+	// Hosts are the apiserver and registry domains resolved by CoreDNS if the dns provider is coredns
+	Hosts []etchosts.Entry
+	// BindClusterDNS lets the node-local dns cache bind the kube-dns service IP too, so that the pods using it are
+	// cached, it is not possible in ipvs mode as the IP is bound to kube-ipvs0.
+	BindClusterDNS bool
+}
+
+var dnsTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"indent": func(n int, s string) string {
+		pad := strings.Repeat(" ", n)
+		return pad + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n"+pad)
+	},
+}
+
+func renderDNSTemplate(tmpl string, config dnsConfig) (string, error) {
+	t, err := template.New("dns").Funcs(dnsTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, config); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// mergeDNSSpec sets the cluster DNS of kubelet, the node-local dns cache wins over the kube-dns service.
+func (k *KubeadmConfig) mergeDNSSpec(dns v2.DNSSpec) {
+	if dns.NodeLocalDNS.Enabled {
+		k.KubeletConfiguration.ClusterDNS = []string{getNodeLocalDNSIP(dns.NodeLocalDNS)}
+		return
+	}
+	if dns.ClusterDNS != "" {
+		k.KubeletConfiguration.ClusterDNS = []string{dns.ClusterDNS}
+	}
+}
+
+func getNodeLocalDNSIP(spec v2.NodeLocalDNSSpec) string {
+	if spec.LocalIP != "" {
+		return spec.LocalIP
+	}
+	return DefaultNodeLocalDNSIP
+}
+
+// getDefaultClusterDNS returns the kube-dns service IP kubeadm allocates in the service subnet.
+func getDefaultClusterDNS(svcCIDR string) (string, error) {
+	// only the first subnet is used in dual stack, like: 10.96.0.0/22,fd00::/108
+	_, subnet, err := net.ParseCIDR(strings.Split(svcCIDR, ",")[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to parse service subnet %s: %v", svcCIDR, err)
+	}
+	base := subnet.IP.To4()
+	if base == nil {
+		base = subnet.IP.To16()
+	}
+	ip := big.NewInt(0).SetBytes(base)
+	ip.Add(ip, big.NewInt(clusterDNSIPIndex))
+	b := ip.Bytes()
+	dns := make(net.IP, len(base))
+	copy(dns[len(dns)-len(b):], b)
+	if !subnet.Contains(dns) {
+		return "", fmt.Errorf("service subnet %s is too small to allocate the cluster dns", svcCIDR)
+	}
+	return dns.String(), nil
+}
+
+func (k *KubeadmRuntime) getClusterDNS() (string, error) {
+	if dns := k.Spec.Kubernetes.DNS.ClusterDNS; dns != "" {
+		return dns, nil
+	}
+	return getDefaultClusterDNS(k.getSvcCIDR())
+}
+
+func (k *KubeadmRuntime) validateDNSSpec() error {
+	dns := k.Spec.Kubernetes.DNS
+	if dns.ClusterDNS != "" {
+		ip := net.ParseIP(dns.ClusterDNS)
+		if ip == nil {
+			return fmt.Errorf("invalid cluster dns %s", dns.ClusterDNS)
+		}
+		_, subnet, err := net.ParseCIDR(strings.Split(k.getSvcCIDR(), ",")[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse service subnet %s: %v", k.getSvcCIDR(), err)
+		}
+		if !subnet.Contains(ip) {
+			return fmt.Errorf("cluster dns %s is not in the service subnet %s", dns.ClusterDNS, k.getSvcCIDR())
+		}
+	}
+	if dns.NodeLocalDNS.LocalIP != "" && net.ParseIP(dns.NodeLocalDNS.LocalIP) == nil {
+		return fmt.Errorf("invalid node local dns ip %s", dns.NodeLocalDNS.LocalIP)
+	}
+	return k.validateDNSProvider()
+}
+
+func (k *KubeadmRuntime) getDNSConfig() (dnsConfig, error) {
+	dns := k.Spec.Kubernetes.DNS
+	clusterDNS, err := k.getClusterDNS()
+	if err != nil {
+		return dnsConfig{}, err
+	}
+	config := dnsConfig{
+		Domain:     k.getDNSDomain(),
+		ClusterDNS: clusterDNS,
+		LocalIP:    getNodeLocalDNSIP(dns.NodeLocalDNS),
+		Image:      dns.NodeLocalDNS.Image,
+		Upstreams:  dns.Upstreams,
+		Snippets:   dns.CorefileSnippets,
+		Hosts:      k.getCoreDNSHosts(k.getMasterIPList()),
+		// the cache forwards to kube-dns-upstream instead, which it resolves by -upstreamsvc.
+		BindClusterDNS: k.getKubeProxyMode() != ProxyModeIPVS,
+	}
+	if config.Image == "" {
+		config.Image = fmt.Sprintf("%s/%s", k.ImageRepository, NodeLocalDNSImage)
+	}
+	if len(config.Upstreams) == 0 {
+		config.Upstreams = []string{DefaultDNSUpstream}
+	}
+	return config, nil
+}
+
+// ConfigDNS renders the kube-dns service, CoreDNS ConfigMap and node-local dns cache on master0 after init,
+// nothing is changed if the DNS of Clusterfile is not set.
+func (k *KubeadmRuntime) ConfigDNS() error {
+	dns := k.Spec.Kubernetes.DNS
+	config, err := k.getDNSConfig()
+	if err != nil {
+		return err
+	}
+	var cmds []string
+	if dns.ClusterDNS != "" {
+		svc, err := renderDNSTemplate(kubeDNSServiceTemplate, config)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, fmt.Sprintf(RemoteReplaceYaml, utils.ShellQuote(svc)))
+	}
+	if len(dns.Upstreams) != 0 || len(dns.CorefileSnippets) != 0 || len(config.Hosts) != 0 {
+		cm, err := renderDNSTemplate(coreDNSConfigMapTemplate, config)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, fmt.Sprintf(RemoteApplyYaml, utils.ShellQuote(cm)))
+	}
+	if dns.NodeLocalDNS.Enabled {
+		nodeLocal, err := renderDNSTemplate(nodeLocalDNSTemplate, config)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, fmt.Sprintf(RemoteApplyYaml, utils.ShellQuote(nodeLocal)))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	logger.Info("start to config cluster dns...")
+	ssh, err := k.getHostSSHClient(k.getMaster0IP())
+	if err != nil {
+		return err
+	}
+	if err := ssh.CmdAsync(k.getMaster0IP(), cmds...); err != nil {
+		return fmt.Errorf("failed to config cluster dns: %v", err)
+	}
+	return nil
+}
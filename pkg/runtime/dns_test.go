@@ -0,0 +1,138 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/utils"
+)
+
+func TestGetDefaultClusterDNS(t *testing.T) {
+	tests := []struct {
+		svcCIDR string
+		want    string
+		wantErr bool
+	}{
+		{"10.96.0.0/12", "10.96.0.10", false},
+		{"10.96.0.0/22,fd00::/108", "10.96.0.10", false},
+		{"172.16.1.0/24", "172.16.1.10", false},
+		{"fd00::/108", "fd00::a", false},
+		{"10.96.0.0/29", "", true},
+		{"10.96.0.0", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.svcCIDR, func(t *testing.T) {
+			got, err := getDefaultClusterDNS(tt.svcCIDR)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("getDefaultClusterDNS() = %s, %v, want %s, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderCoreDNSConfigMap(t *testing.T) {
+	config := dnsConfig{
+		Domain:    "cluster.local",
+		Upstreams: []string{"114.114.114.114", "8.8.8.8"},
+		Snippets:  []string{"example.com:53 {\n    forward . 10.0.0.53\n}"},
+		Hosts:     []etchosts.Entry{{IP: "192.168.0.2", Domain: "apiserver.cluster.local"}},
+	}
+	out, err := renderDNSTemplate(coreDNSConfigMapTemplate, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cm struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := yaml.Unmarshal([]byte(out), &cm); err != nil {
+		t.Fatalf("rendered configmap is not valid yaml: %v\n%s", err, out)
+	}
+	corefile := cm.Data["Corefile"]
+	for _, want := range []string{
+		"kubernetes cluster.local in-addr.arpa ip6.arpa {",
+		"   192.168.0.2 apiserver.cluster.local\n",
+		"forward . 114.114.114.114 8.8.8.8 {",
+		"example.com:53 {\n    forward . 10.0.0.53\n}",
+	} {
+		if !strings.Contains(corefile, want) {
+			t.Errorf("Corefile does not contain %q:\n%s", want, corefile)
+		}
+	}
+
+	config.Hosts = nil
+	out, err = renderDNSTemplate(coreDNSConfigMapTemplate, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "hosts {") {
+		t.Errorf("Corefile without hosts contains the hosts plugin:\n%s", out)
+	}
+}
+
+func TestRenderNodeLocalDNS(t *testing.T) {
+	config := dnsConfig{Domain: "cluster.local", ClusterDNS: "10.96.0.10", LocalIP: DefaultNodeLocalDNSIP, Image: "sea.hub:5000/k8s-dns-node-cache:1.21.1"}
+	out, err := renderDNSTemplate(nodeLocalDNSTemplate, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := strings.Split(out, "\n---\n")
+	if len(docs) != 4 {
+		t.Fatalf("node local dns has %d documents, want 4", len(docs))
+	}
+	for _, doc := range docs {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil || obj["kind"] == nil {
+			t.Errorf("invalid document %v:\n%s", err, doc)
+		}
+	}
+	// in ipvs mode the cache only binds the local IP and forwards to kube-dns.
+	for _, want := range []string{"cluster.local:53 {", "bind 169.254.20.10\n", "forward . 10.96.0.10 {", `"-localip", "169.254.20.10"`,
+		"image: sea.hub:5000/k8s-dns-node-cache:1.21.1", "host: 169.254.20.10"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("node local dns does not contain %q", want)
+		}
+	}
+	if strings.Contains(out, "__PILLAR__CLUSTER__DNS__") {
+		t.Errorf("node local dns in ipvs mode should not forward to the pillar cluster dns")
+	}
+}
+
+func TestRemoteApplyYaml(t *testing.T) {
+	yaml := "data:\n  Corefile: |\n    hosts {\n      10.0.0.1 it's.$HOME.`id`\n    }\n"
+	for name, template := range map[string]string{"apply": RemoteApplyYaml, "replace": RemoteReplaceYaml} {
+		file := filepath.Join(t.TempDir(), "applied.yaml")
+		cmd := fmt.Sprintf(template, utils.ShellQuote(yaml))
+		cmd = cmd[:strings.Index(cmd, "| kubectl")] + "> " + file
+		if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+			t.Fatalf("%s error = %v: %s", name, err, out)
+		}
+		applied, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(string(applied), "\n"); got != yaml {
+			t.Errorf("%s applied %q, want %q", name, got, yaml)
+		}
+	}
+}
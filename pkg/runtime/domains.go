@@ -0,0 +1,153 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/dns"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+func (k *KubeadmRuntime) validateDNSProvider() error {
+	_, err := dns.New(k.Spec.Kubernetes.DNS.Provider)
+	return err
+}
+
+// managesDomain reports whether domain is registered by the dns provider of Clusterfile instead of /etc/hosts.
+func (k *KubeadmRuntime) managesDomain(domain string) bool {
+	return dns.Manages(k.Spec.Kubernetes.DNS.Provider, domain)
+}
+
+// applyEtcHosts writes entries to /etc/hosts of host, the domains registered by the dns provider are removed from it
+// instead, so that the stale entries written before switching to the provider do not shadow the records.
+func (k *KubeadmRuntime) applyEtcHosts(client ssh.Interface, host string, entries ...etchosts.Entry) error {
+	var (
+		set    []etchosts.Entry
+		remove []string
+	)
+	for _, e := range entries {
+		if k.managesDomain(e.Domain) {
+			remove = append(remove, e.Domain)
+		} else {
+			set = append(set, e)
+		}
+	}
+	return etchosts.Apply(client, host, set, remove...)
+}
+
+// getAPIServerDomainIPs returns the IPs the apiserver domain resolves to by the dns provider, which are the apiServerIPs
+// of the provider, or else the IPs of masters.
+func (k *KubeadmRuntime) getAPIServerDomainIPs(masters []string) []string {
+	if ips := k.Spec.Kubernetes.DNS.Provider.APIServerIPs; len(ips) > 0 {
+		return ips
+	}
+	var ips []string
+	for _, m := range masters {
+		if ip := utils.GetHostIP(m); utils.NotIn(ip, ips) {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// registerDomain points domain to ips by the dns provider, nothing is done if domain is written to /etc/hosts.
+func (k *KubeadmRuntime) registerDomain(domain string, ips []string) error {
+	if !k.managesDomain(domain) {
+		return nil
+	}
+	provider, err := dns.New(k.Spec.Kubernetes.DNS.Provider)
+	if err != nil {
+		return err
+	}
+	logger.Info("register %s to %v by dns provider %s", domain, ips, k.Spec.Kubernetes.DNS.Provider.Name)
+	if err := provider.Register(domain, ips); err != nil {
+		return fmt.Errorf("failed to register %s by dns provider: %v", domain, err)
+	}
+	return nil
+}
+
+// getCoreDNSHosts returns the entries of the hosts plugin of CoreDNS if the provider is coredns.
+func (k *KubeadmRuntime) getCoreDNSHosts(masters []string) []etchosts.Entry {
+	if k.Spec.Kubernetes.DNS.Provider.Name != dns.ProviderCoreDNS {
+		return nil
+	}
+	var hosts []etchosts.Entry
+	for _, ip := range k.getAPIServerDomainIPs(masters) {
+		hosts = append(hosts, etchosts.Entry{IP: ip, Domain: k.getAPIServerDomain()})
+	}
+	return append(hosts, getRegistryEntry(k.getRootfs(), k.getMaster0IP()))
+}
+
+// syncAPIServerDomain points the apiserver domain to masters after they are joined or deleted, in the dns provider
+// or the CoreDNS of cluster.
+func (k *KubeadmRuntime) syncAPIServerDomain(masters []string) error {
+	if err := k.registerDomain(k.getAPIServerDomain(), k.getAPIServerDomainIPs(masters)); err != nil {
+		return err
+	}
+	hosts := k.getCoreDNSHosts(masters)
+	if len(hosts) == 0 {
+		return nil
+	}
+	config, err := k.getDNSConfig()
+	if err != nil {
+		return err
+	}
+	config.Hosts = hosts
+	cm, err := renderDNSTemplate(coreDNSConfigMapTemplate, config)
+	if err != nil {
+		return err
+	}
+	client, err := k.getHostSSHClient(k.getMaster0IP())
+	if err != nil {
+		return err
+	}
+	if err := client.CmdAsync(k.getMaster0IP(), fmt.Sprintf(RemoteApplyYaml, utils.ShellQuote(cm))); err != nil {
+		return fmt.Errorf("failed to update the hosts of CoreDNS: %v", err)
+	}
+	return nil
+}
+
+// deregisterDomains removes the apiserver and registry domains from the dns provider when the cluster is deleted,
+// the failures are only logged as the hosts are reset already.
+func (k *KubeadmRuntime) deregisterDomains() {
+	spec := k.Spec.Kubernetes.DNS.Provider
+	provider, err := dns.New(spec)
+	if err != nil || provider == nil {
+		return
+	}
+	for _, domain := range []string{k.getAPIServerDomain(), getRegistryEntry(k.getRootfs(), k.getMaster0IP()).Domain} {
+		if !dns.Manages(spec, domain) {
+			continue
+		}
+		if err := provider.Deregister(domain); err != nil {
+			logger.Warn("failed to deregister %s by dns provider %s: %v", domain, spec.Name, err)
+		}
+	}
+}
+
+// masterListWith returns the masters of Clusterfile with joined and without deleted.
+func (k *KubeadmRuntime) masterListWith(joined, deleted []string) []string {
+	var masters []string
+	for _, m := range append(k.getMasterIPList(), joined...) {
+		if utils.NotIn(m, masters) && utils.NotIn(m, deleted) {
+			masters = append(masters, m)
+		}
+	}
+	return masters
+}
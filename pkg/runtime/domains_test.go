@@ -0,0 +1,71 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/sealer/pkg/dns"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func newDomainsRuntime(provider v2.DNSProviderSpec) *KubeadmRuntime {
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2", "192.168.0.3"}, Roles: []string{"master"}},
+		{IPS: []string{"192.168.0.4"}, Roles: []string{"node"}},
+	}
+	cluster.Spec.Kubernetes.DNS.Provider = provider
+	return &KubeadmRuntime{Cluster: cluster, KubeadmConfig: &KubeadmConfig{}, Config: &Config{APIServerDomain: DefaultAPIserverDomain}}
+}
+
+func TestKubeadmRuntime_getCoreDNSHosts(t *testing.T) {
+	k := newDomainsRuntime(v2.DNSProviderSpec{Name: dns.ProviderCoreDNS})
+	masters := k.masterListWith([]string{"192.168.0.5"}, []string{"192.168.0.3"})
+	if want := []string{"192.168.0.2", "192.168.0.5"}; !reflect.DeepEqual(masters, want) {
+		t.Errorf("masterListWith() = %v, want %v", masters, want)
+	}
+
+	cm, err := renderDNSTemplate(coreDNSConfigMapTemplate, dnsConfig{
+		Domain:    "cluster.local",
+		Upstreams: []string{DefaultDNSUpstream},
+		Hosts:     k.getCoreDNSHosts(masters),
+	})
+	if err != nil {
+		t.Fatalf("renderDNSTemplate() error = %v", err)
+	}
+	want := `        prometheus :9153
+        hosts {
+           192.168.0.2 apiserver.cluster.local
+           192.168.0.5 apiserver.cluster.local
+           192.168.0.2 sea.hub
+           fallthrough
+        }
+        forward . /etc/resolv.conf {`
+	if !strings.Contains(cm, want) {
+		t.Errorf("renderDNSTemplate() = %s, want hosts %s", cm, want)
+	}
+
+	k.Spec.Kubernetes.DNS.Provider = v2.DNSProviderSpec{Name: dns.ProviderRoute53, APIServerIPs: []string{"10.0.0.10"}}
+	if hosts := k.getCoreDNSHosts(masters); hosts != nil {
+		t.Errorf("getCoreDNSHosts() = %v, want nil for route53", hosts)
+	}
+	if ips := k.getAPIServerDomainIPs(masters); !reflect.DeepEqual(ips, []string{"10.0.0.10"}) {
+		t.Errorf("getAPIServerDomainIPs() = %v, want the apiserver ips of provider", ips)
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/timeout"
+	"github.com/alibaba/sealer/pkg/webhook"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	EncryptionConfigFile        = "encryption-config.yaml"
+	EncryptionProviderConfigArg = "encryption-provider-config"
+	// the pki dir is sent to all masters and mounted into the apiserver by kubeadm.
+	EncryptionConfigPath      = "/etc/kubernetes/pki/" + EncryptionConfigFile
+	DefaultEncryptionProvider = "aescbc"
+	DefaultEncryptionResource = "secrets"
+	encryptionKeyLength       = 32
+
+	// the config holds the keys, an existing one copied before with a wider mode is restricted too.
+	RemoteChmodEncryptionConfig = "chmod 600 " + EncryptionConfigPath
+	RemoteRestartAPIServer      = `mv /etc/kubernetes/manifests/kube-apiserver.yaml /etc/kubernetes/kube-apiserver.yaml.bak && sleep 10 && ` +
+		`mv /etc/kubernetes/kube-apiserver.yaml.bak /etc/kubernetes/manifests/kube-apiserver.yaml`
+	RemoteWaitAPIServerReady = `timeout %d sh -c 'until curl -sfk https://127.0.0.1:6443/healthz; do sleep 2; done'`
+	RemoteRewriteResources   = `kubectl get %s --all-namespaces -o json | kubectl replace -f -`
+)
+
+// EncryptionConfiguration is the apiserver.config.k8s.io/v1 EncryptionConfiguration, only the fields sealer uses.
+type EncryptionConfiguration struct {
+	APIVersion string                  `json:"apiVersion"`
+	Kind       string                  `json:"kind"`
+	Resources  []EncryptionResourceCfg `json:"resources"`
+}
+
+type EncryptionResourceCfg struct {
+	Resources []string                 `json:"resources"`
+	Providers []map[string]interface{} `json:"providers"`
+}
+
+type EncryptionKey struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+}
+
+type encryptionKeys struct {
+	Keys []EncryptionKey `json:"keys"`
+}
+
+func newEncryptionKey() (EncryptionKey, error) {
+	secret := make([]byte, encryptionKeyLength)
+	if _, err := rand.Read(secret); err != nil {
+		return EncryptionKey{}, fmt.Errorf("failed to generate encryption key: %v", err)
+	}
+	return EncryptionKey{
+		Name:   fmt.Sprintf("key-%d", time.Now().UnixNano()),
+		Secret: base64.StdEncoding.EncodeToString(secret),
+	}, nil
+}
+
+// newEncryptionConfiguration encrypts with the first key, the other keys are only used to decrypt.
+func newEncryptionConfiguration(spec v2.EncryptionSpec, keys []EncryptionKey) *EncryptionConfiguration {
+	provider := spec.Provider
+	if provider == "" {
+		provider = DefaultEncryptionProvider
+	}
+	resources := spec.Resources
+	if len(resources) == 0 {
+		resources = []string{DefaultEncryptionResource}
+	}
+	return &EncryptionConfiguration{
+		APIVersion: "apiserver.config.k8s.io/v1",
+		Kind:       "EncryptionConfiguration",
+		Resources: []EncryptionResourceCfg{{
+			Resources: resources,
+			Providers: []map[string]interface{}{
+				{provider: encryptionKeys{Keys: keys}},
+				// read the data written before encryption enabled.
+				{"identity": map[string]interface{}{}},
+			},
+		}},
+	}
+}
+
+func (k *KubeadmRuntime) getEncryptionConfigFile() string {
+	return filepath.Join(k.getPKIPath(), EncryptionConfigFile)
+}
+
+func (k *KubeadmRuntime) writeEncryptionConfig(keys []EncryptionKey) error {
+	return writeEncryptionConfigFile(k.getEncryptionConfigFile(), k.Spec.Kubernetes.EncryptionAtRest, keys)
+}
+
+// writeEncryptionConfigFile writes the config readable by the owner only, ssh.Copy keeps the mode on the masters.
+func writeEncryptionConfigFile(path string, spec v2.EncryptionSpec, keys []EncryptionKey) error {
+	data, err := yaml.Marshal(newEncryptionConfiguration(spec, keys))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), common.FileMode0755); err != nil {
+		return err
+	}
+	return utils.AtomicWriteFile(path, data, common.FileMode0600)
+}
+
+// readEncryptionKeys returns the keys in the current encryption config, the first one is used to encrypt.
+func (k *KubeadmRuntime) readEncryptionKeys() ([]EncryptionKey, error) {
+	data, err := ioutil.ReadFile(k.getEncryptionConfigFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption config: %v", err)
+	}
+	var config struct {
+		Resources []struct {
+			Providers []map[string]encryptionKeys `json:"providers"`
+		} `json:"resources"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode encryption config: %v", err)
+	}
+	for _, r := range config.Resources {
+		for _, p := range r.Providers {
+			for _, keys := range p {
+				if len(keys.Keys) != 0 {
+					return keys.Keys, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("no encryption key found in %s", k.getEncryptionConfigFile())
+}
+
+// GenerateEncryptionConfig generates the encryption config to the pki dir if encryption at rest is enabled,
+// the existing config is kept, so that the data encrypted by it can still be read.
+func (k *KubeadmRuntime) GenerateEncryptionConfig() error {
+	if !k.Spec.Kubernetes.EncryptionAtRest.Enabled || utils.IsFileExist(k.getEncryptionConfigFile()) {
+		return nil
+	}
+	key, err := newEncryptionKey()
+	if err != nil {
+		return err
+	}
+	return k.writeEncryptionConfig([]EncryptionKey{key})
+}
+
+// RotateEncryptionKey adds a new key, encrypts all resources with it, then removes the old keys.
+// Each step is distributed to all masters and the apiservers are restarted to load it.
+func RotateEncryptionKey(cluster *v2.Cluster, clusterfile string) error {
+	if !cluster.Spec.Kubernetes.EncryptionAtRest.Enabled {
+		return fmt.Errorf("encryption at rest is not enabled in cluster %s", cluster.Name)
+	}
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	oldKeys, err := k.readEncryptionKeys()
+	if err != nil {
+		return err
+	}
+	newKey, err := newEncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	steps := []struct {
+		msg  string
+		keys []EncryptionKey
+	}{
+		{"add the new encryption key", append(append([]EncryptionKey{}, oldKeys...), newKey)},
+		{"encrypt with the new encryption key", append([]EncryptionKey{newKey}, oldKeys...)},
+	}
+	for _, step := range steps {
+		logger.Info("start to %s", step.msg)
+		if err := k.applyEncryptionKeys(step.keys); err != nil {
+			return fmt.Errorf("failed to %s: %v", step.msg, err)
+		}
+	}
+
+	logger.Info("start to rewrite resources with the new encryption key")
+	ssh, err := k.getHostSSHClient(k.getMaster0IP())
+	if err != nil {
+		return err
+	}
+	resources := cluster.Spec.Kubernetes.EncryptionAtRest.Resources
+	if len(resources) == 0 {
+		resources = []string{DefaultEncryptionResource}
+	}
+	for _, r := range resources {
+		if err := ssh.CmdAsync(k.getMaster0IP(), fmt.Sprintf(RemoteRewriteResources, r)); err != nil {
+			return fmt.Errorf("failed to rewrite %s: %v", r, err)
+		}
+	}
+
+	logger.Info("start to remove the old encryption keys")
+	if err := k.applyEncryptionKeys([]EncryptionKey{newKey}); err != nil {
+		return fmt.Errorf("failed to remove the old encryption keys: %v", err)
+	}
+	logger.Info("Succeeded in rotating encryption key to %s", newKey.Name)
+	webhook.Notify(cluster, webhook.EncryptionKeyRotated, k.getMasterIPList(), nil)
+	return nil
+}
+
+// applyEncryptionKeys writes the keys to the encryption config, sends it to masters and restarts apiservers one by one.
+func (k *KubeadmRuntime) applyEncryptionKeys(keys []EncryptionKey) error {
+	if err := k.writeEncryptionConfig(keys); err != nil {
+		return err
+	}
+	return k.sendEncryptionConfig(k.getEncryptionConfigFile())
+}
+
+// sendEncryptionConfig sends the encryption config file to masters and restarts apiservers one by one.
+func (k *KubeadmRuntime) sendEncryptionConfig(file string) error {
+	for _, master := range k.getMasterIPList() {
+		ssh, err := k.getHostSSHClient(master)
+		if err != nil {
+			return err
+		}
+		if err := ssh.Copy(master, file, EncryptionConfigPath); err != nil {
+			return fmt.Errorf("failed to send encryption config to %s: %v", master, err)
+		}
+		waitReady := fmt.Sprintf(RemoteWaitAPIServerReady, int(timeout.Of(k.Cluster, timeout.HealthCheck).Seconds()))
+		if err := ssh.CmdAsync(master, RemoteChmodEncryptionConfig, RemoteRestartAPIServer, waitReady); err != nil {
+			return fmt.Errorf("failed to restart apiserver on %s: %v", master, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// fakeSSH copies the files into root as the file system of the hosts, keeping the mode like ssh.Copy,
+// and records the commands run on the hosts. The other methods of ssh.Interface are not used.
+type fakeSSH struct {
+	ssh.Interface
+	root string
+	lock sync.Mutex
+	cmds []string
+}
+
+func (f *fakeSSH) Copy(host, localPath, remotePath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(f.root, host, remotePath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dst, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(dst, info.Mode().Perm())
+}
+
+func (f *fakeSSH) CmdAsync(host string, cmd ...string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.cmds = append(f.cmds, host+": "+strings.Join(cmd, " && "))
+	return nil
+}
+
+func (f *fakeSSH) provider(hostIP string, cluster *v2.Cluster) (ssh.Interface, error) {
+	return f, nil
+}
+
+func TestKubeadmRuntime_sendEncryptionConfig(t *testing.T) {
+	k := newDomainsRuntime(v2.DNSProviderSpec{})
+	k.Cluster.Name = "encryption-cluster"
+	k.Spec.Kubernetes.EncryptionAtRest.Enabled = true
+	fake := &fakeSSH{root: t.TempDir()}
+	ssh.RegisterProvider(k.Cluster.Name, fake.provider)
+	defer ssh.UnregisterProvider(k.Cluster.Name)
+
+	file := filepath.Join(t.TempDir(), "pki", EncryptionConfigFile)
+	// a config written by the former versions is world-readable.
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	key, err := newEncryptionKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeEncryptionConfigFile(file, k.Spec.Kubernetes.EncryptionAtRest, []EncryptionKey{key}); err != nil {
+		t.Fatalf("writeEncryptionConfigFile() error = %v", err)
+	}
+	if err := k.sendEncryptionConfig(file); err != nil {
+		t.Fatalf("sendEncryptionConfig() error = %v", err)
+	}
+
+	for _, master := range []string{"192.168.0.2", "192.168.0.3"} {
+		info, err := os.Stat(filepath.Join(fake.root, master, EncryptionConfigPath))
+		if err != nil {
+			t.Fatalf("expected encryption config on %s: %v", master, err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("mode of encryption config on %s = %o, want 600", master, info.Mode().Perm())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(fake.root, "192.168.0.4")); !os.IsNotExist(err) {
+		t.Errorf("expected no encryption config sent to node, got %v", err)
+	}
+	var hosts []string
+	for _, cmd := range fake.cmds {
+		if !strings.Contains(cmd, RemoteChmodEncryptionConfig) {
+			t.Errorf("expected the encryption config chmod before apiserver restarted, got %s", cmd)
+		}
+		hosts = append(hosts, strings.SplitN(cmd, ":", 2)[0])
+	}
+	if want := []string{"192.168.0.2", "192.168.0.3"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("apiservers restarted on %v, want %v", hosts, want)
+	}
+}
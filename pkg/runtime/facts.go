@@ -0,0 +1,52 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// GetClusterFacts returns the facts of cluster the CMD of CloudImage may need, as env names to their values,
+// like: MASTER0_IP, REGISTRY_DOMAIN and POD_CIDR, the lists are separated by space.
+func GetClusterFacts(cluster *v2.Cluster, clusterfile string) (map[string]string, error) {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return nil, err
+	}
+	k := i.(*KubeadmRuntime)
+	if err := k.MergeKubeadmConfig(); err != nil {
+		return nil, err
+	}
+	registry := GetRegistryConfig(k.getImageMountDir(), k.getMaster0IP())
+	registryIP, _ := utils.GetSSHHostIPAndPort(registry.IP)
+	return map[string]string{
+		"CLUSTER_NAME":     k.getClusterName(),
+		"KUBE_VERSION":     k.getKubeVersion(),
+		"MASTER0_IP":       utils.GetHostIP(k.getMaster0IP()),
+		"MASTERS":          strings.Join(utils.GetHostIPSlice(k.getMasterIPList()), " "),
+		"NODES":            strings.Join(utils.GetHostIPSlice(k.getNodesIPList()), " "),
+		"VIP":              k.getVIP(),
+		"APISERVER_DOMAIN": k.getAPIServerDomain(),
+		"REGISTRY_IP":      registryIP,
+		"REGISTRY_DOMAIN":  registry.Domain,
+		"REGISTRY_PORT":    registry.Port,
+		"POD_CIDR":         k.ClusterConfiguration.Networking.PodSubnet,
+		"SVC_CIDR":         k.getSvcCIDR(),
+		"DNS_DOMAIN":       k.getDNSDomain(),
+	}, nil
+}
@@ -0,0 +1,170 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+)
+
+/*
+The feature gates in Clusterfile spec.kubernetes.featureGates are set to all the components of kubernetes:
+
+spec:
+  kubernetes:
+    featureGates:
+      InPlacePodVerticalScaling: true
+      NodeSwap: false
+
+They are merged into the --feature-gates of apiserver, controller-manager and scheduler, and the featureGates of
+KubeletConfiguration and KubeProxyConfiguration, overriding the same gates of the kubeadm configs.
+*/
+
+const FeatureGatesArg = "feature-gates"
+
+// featureGate is the versions a feature gate is added and removed in, empty if it is before the versions sealer
+// supports or not removed yet, kubernetes components fail to start with a gate they do not know.
+type featureGate struct {
+	Added   string
+	Removed string
+}
+
+// knownFeatureGates are the feature gates commonly set, the others are passed as they are with a warning.
+var knownFeatureGates = map[string]featureGate{
+	"CPUManagerPolicyOptions":          {Added: "v1.22.0"},
+	"CronJobControllerV2":              {Added: "v1.20.0", Removed: "v1.23.0"},
+	"CSIInlineVolume":                  {Added: "v1.15.0", Removed: "v1.27.0"},
+	"CSIMigration":                     {Added: "v1.14.0", Removed: "v1.27.0"},
+	"CSIStorageCapacity":               {Added: "v1.19.0", Removed: "v1.28.0"},
+	"DefaultPodTopologySpread":         {Added: "v1.19.0", Removed: "v1.26.0"},
+	"DelegateFSGroupToCSIDriver":       {Added: "v1.22.0", Removed: "v1.28.0"},
+	"DynamicKubeletConfig":             {Removed: "v1.26.0"},
+	"EndpointSlice":                    {Added: "v1.16.0", Removed: "v1.25.0"},
+	"EphemeralContainers":              {Added: "v1.16.0", Removed: "v1.27.0"},
+	"GracefulNodeShutdown":             {Added: "v1.20.0"},
+	"GRPCContainerProbe":               {Added: "v1.23.0", Removed: "v1.29.0"},
+	"HPAScaleToZero":                   {Added: "v1.16.0"},
+	"IndexedJob":                       {Added: "v1.21.0", Removed: "v1.26.0"},
+	"InPlacePodVerticalScaling":        {Added: "v1.27.0"},
+	"IPv6DualStack":                    {Added: "v1.16.0", Removed: "v1.25.0"},
+	"JobTrackingWithFinalizers":        {Added: "v1.22.0", Removed: "v1.28.0"},
+	"KubeletCredentialProviders":       {Added: "v1.20.0", Removed: "v1.28.0"},
+	"KubeletInUserNamespace":           {Added: "v1.22.0"},
+	"LegacyServiceAccountTokenCleanUp": {Added: "v1.28.0", Removed: "v1.32.0"},
+	"MaxUnavailableStatefulSet":        {Added: "v1.24.0"},
+	"MemoryQoS":                        {Added: "v1.22.0"},
+	"MinDomainsInPodTopologySpread":    {Added: "v1.24.0", Removed: "v1.32.0"},
+	"MixedProtocolLBService":           {Added: "v1.20.0", Removed: "v1.28.0"},
+	"NetworkPolicyEndPort":             {Added: "v1.21.0", Removed: "v1.27.0"},
+	"NodeSwap":                         {Added: "v1.22.0"},
+	"PodOverhead":                      {Added: "v1.16.0", Removed: "v1.26.0"},
+	"PodSecurity":                      {Added: "v1.22.0", Removed: "v1.28.0"},
+	"ReadWriteOncePod":                 {Added: "v1.22.0", Removed: "v1.31.0"},
+	"RotateKubeletServerCertificate":   {},
+	"SeccompDefault":                   {Added: "v1.22.0", Removed: "v1.29.0"},
+	"ServiceLBNodePortControl":         {Added: "v1.20.0", Removed: "v1.26.0"},
+	"ServiceTopology":                  {Added: "v1.17.0", Removed: "v1.22.0"},
+	"SidecarContainers":                {Added: "v1.28.0"},
+	"StatefulSetMinReadySeconds":       {Added: "v1.22.0", Removed: "v1.27.0"},
+	"SuspendJob":                       {Added: "v1.21.0", Removed: "v1.26.0"},
+	"TopologyManager":                  {Added: "v1.16.0", Removed: "v1.29.0"},
+	"TTLAfterFinished":                 {Added: "v1.12.0", Removed: "v1.25.0"},
+	"UserNamespacesSupport":            {Added: "v1.28.0"},
+	"ValidatingAdmissionPolicy":        {Added: "v1.26.0", Removed: "v1.32.0"},
+}
+
+var featureGateName = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// validateFeatureGates checks the feature gates in Clusterfile are known by the kubernetes of cluster.
+func (k *KubeadmRuntime) validateFeatureGates() error {
+	version := k.getKubeVersion()
+	for name := range k.Spec.Kubernetes.FeatureGates {
+		if !featureGateName.MatchString(name) {
+			return fmt.Errorf("invalid feature gate name %s", name)
+		}
+		gate, ok := knownFeatureGates[name]
+		if !ok {
+			logger.Warn("feature gate %s is unknown to sealer, make sure kubernetes %s supports it", name, version)
+			continue
+		}
+		if version == "" {
+			continue
+		}
+		if gate.Added != "" && !VersionCompare(version, gate.Added) {
+			return fmt.Errorf("feature gate %s is added in kubernetes %s, not supported by %s", name, gate.Added, version)
+		}
+		if gate.Removed != "" && VersionCompare(version, gate.Removed) {
+			return fmt.Errorf("feature gate %s is removed in kubernetes %s, not supported by %s", name, gate.Removed, version)
+		}
+	}
+	return nil
+}
+
+// mergeFeatureGates sets gates to the components of kubernetes, overriding the same gates of them.
+func (k *KubeadmConfig) mergeFeatureGates(gates map[string]bool) {
+	if len(gates) == 0 {
+		return
+	}
+	for _, c := range []*v1beta2.ControlPlaneComponent{&k.APIServer.ControlPlaneComponent, &k.ControllerManager, &k.Scheduler} {
+		c.ExtraArgs = mergeExtraArgs(c.ExtraArgs, map[string]string{FeatureGatesArg: mergeFeatureGatesArg(c.ExtraArgs[FeatureGatesArg], gates)})
+	}
+	// the feature gates of kubelet flags override the ones in its config.
+	for _, args := range []map[string]string{k.InitConfiguration.NodeRegistration.KubeletExtraArgs, k.JoinConfiguration.NodeRegistration.KubeletExtraArgs} {
+		if v, ok := args[FeatureGatesArg]; ok {
+			args[FeatureGatesArg] = mergeFeatureGatesArg(v, gates)
+		}
+	}
+	if k.KubeletConfiguration.FeatureGates == nil {
+		k.KubeletConfiguration.FeatureGates = map[string]bool{}
+	}
+	if k.KubeProxyConfiguration.FeatureGates == nil {
+		k.KubeProxyConfiguration.FeatureGates = map[string]bool{}
+	}
+	for name, enabled := range gates {
+		k.KubeletConfiguration.FeatureGates[name] = enabled
+		k.KubeProxyConfiguration.FeatureGates[name] = enabled
+	}
+}
+
+// mergeFeatureGatesArg merges gates into the --feature-gates arg, like: A=true,B=false, the gates are sorted by name.
+func mergeFeatureGatesArg(arg string, gates map[string]bool) string {
+	merged := map[string]string{}
+	for _, gate := range strings.Split(arg, ",") {
+		kv := strings.SplitN(strings.TrimSpace(gate), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			continue
+		}
+		merged[kv[0]] = strings.TrimSpace(kv[1])
+	}
+	for name, enabled := range gates {
+		merged[name] = strconv.FormatBool(enabled)
+	}
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		pairs = append(pairs, name+"="+merged[name])
+	}
+	return strings.Join(pairs, ",")
+}
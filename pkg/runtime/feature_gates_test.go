@@ -0,0 +1,78 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"reflect"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestKubeadmConfig_mergeFeatureGates(t *testing.T) {
+	k := &KubeadmConfig{}
+	k.APIServer.ExtraArgs = map[string]string{FeatureGatesArg: "TTLAfterFinished=true, NodeSwap=true"}
+	k.InitConfiguration.NodeRegistration.KubeletExtraArgs = map[string]string{FeatureGatesArg: "NodeSwap=true"}
+	k.KubeletConfiguration.FeatureGates = map[string]bool{"MemoryQoS": true}
+	k.MergeKubernetesSpec(v2.KubernetesSpec{FeatureGates: map[string]bool{"NodeSwap": false, "GracefulNodeShutdown": true}})
+
+	if got, want := k.APIServer.ExtraArgs[FeatureGatesArg], "GracefulNodeShutdown=true,NodeSwap=false,TTLAfterFinished=true"; got != want {
+		t.Errorf("apiserver feature-gates = %s, want %s", got, want)
+	}
+	for name, args := range map[string]map[string]string{"controller-manager": k.ControllerManager.ExtraArgs, "scheduler": k.Scheduler.ExtraArgs} {
+		if got, want := args[FeatureGatesArg], "GracefulNodeShutdown=true,NodeSwap=false"; got != want {
+			t.Errorf("%s feature-gates = %s, want %s", name, got, want)
+		}
+	}
+	if got, want := k.InitConfiguration.NodeRegistration.KubeletExtraArgs[FeatureGatesArg], "GracefulNodeShutdown=true,NodeSwap=false"; got != want {
+		t.Errorf("kubelet --feature-gates = %s, want %s", got, want)
+	}
+	if _, ok := k.JoinConfiguration.NodeRegistration.KubeletExtraArgs[FeatureGatesArg]; ok {
+		t.Errorf("kubelet --feature-gates of join should not be set if it is not there")
+	}
+	if want := map[string]bool{"GracefulNodeShutdown": true, "MemoryQoS": true, "NodeSwap": false}; !reflect.DeepEqual(k.KubeletConfiguration.FeatureGates, want) {
+		t.Errorf("kubelet featureGates = %v, want %v", k.KubeletConfiguration.FeatureGates, want)
+	}
+	if want := map[string]bool{"GracefulNodeShutdown": true, "NodeSwap": false}; !reflect.DeepEqual(k.KubeProxyConfiguration.FeatureGates, want) {
+		t.Errorf("kube-proxy featureGates = %v, want %v", k.KubeProxyConfiguration.FeatureGates, want)
+	}
+}
+
+func TestKubeadmRuntime_validateFeatureGates(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		gates   map[string]bool
+		wantErr bool
+	}{
+		{"known gate", "v1.22.4", map[string]bool{"NodeSwap": true, "TTLAfterFinished": true}, false},
+		{"unknown gate", "v1.22.4", map[string]bool{"SomeNewGate": true}, false},
+		{"no version", "", map[string]bool{"InPlacePodVerticalScaling": true}, false},
+		{"invalid name", "v1.22.4", map[string]bool{"node-swap": true}, true},
+		{"not added yet", "v1.19.8", map[string]bool{"NodeSwap": true}, true},
+		{"removed", "v1.25.3", map[string]bool{"TTLAfterFinished": true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &v2.Cluster{}
+			cluster.Spec.Kubernetes.FeatureGates = tt.gates
+			k := &KubeadmRuntime{Cluster: cluster, KubeadmConfig: &KubeadmConfig{}, Config: &Config{APIServerDomain: DefaultAPIserverDomain}}
+			k.KubernetesVersion = tt.version
+			if err := k.validateFeatureGates(); (err != nil) != tt.wantErr {
+				t.Errorf("validateFeatureGates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
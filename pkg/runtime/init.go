@@ -23,13 +23,15 @@ import (
 	"github.com/alibaba/sealer/cert"
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/pkg/result"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
 )
 
 const (
 	RemoteCmdCopyStatic            = "mkdir -p %s && cp -f %s %s"
-	RemoteApplyYaml                = `echo '%s' | kubectl apply -f -`
+	RemoteApplyYaml                = `printf '%%s\n' %s | kubectl apply -f -`
 	RemoteCmdGetNetworkInterface   = "ls /sys/class/net"
 	RemoteCmdExistNetworkInterface = "ip addr show %s | egrep \"%s\" || true"
 	WriteKubeadmConfigCmd          = `cd %s && echo '%s' > kubeadm-config.yaml`
@@ -40,12 +42,24 @@ const (
 )
 
 func (k *KubeadmRuntime) ConfigKubeadmOnMaster0() error {
-	if err := k.LoadFromClusterfile(k.Config.Clusterfile); err != nil {
-		return fmt.Errorf("failed to load kubeadm config from clusterfile: %v", err)
+	if err := k.mergeKubeadmConfigs(); err != nil {
+		return err
 	}
-	// TODO handle the kubeadm config, like kubeproxy config
-	k.handleKubeadmConfig()
-	if err := k.KubeadmConfig.Merge(k.getDefaultKubeadmConfig()); err != nil {
+	k.setCRISocket()
+	k.loadKubeletCgroupDriver()
+	if err := k.validateDNSSpec(); err != nil {
+		return err
+	}
+	if err := k.validateKubeProxySpec(); err != nil {
+		return err
+	}
+	if err := k.validateComponentImages(); err != nil {
+		return err
+	}
+	if err := k.validateKubeletOverrides(); err != nil {
+		return err
+	}
+	if err := k.validateFeatureGates(); err != nil {
 		return err
 	}
 	bs, err := k.generateConfigs()
@@ -61,15 +75,31 @@ func (k *KubeadmRuntime) ConfigKubeadmOnMaster0() error {
 }
 
 func (k *KubeadmRuntime) generateConfigs() ([]byte, error) {
-	//getCgroupDriverFromShell need get CRISocket, so after merge
-	k.setCgroupDriver(k.getCgroupDriverFromShell(k.getMaster0IP()))
+	//alignCgroupDriver need get CRISocket, so after merge
+	driver, err := k.alignCgroupDriver(k.getMaster0IP())
+	if err != nil {
+		return nil, err
+	}
+	k.setCgroupDriver(driver)
 	k.setKubeadmAPIVersion()
-	return utils.MarshalConfigsYaml(&k.InitConfiguration,
+	return k.marshalKubeadmConfigs(&k.InitConfiguration,
 		&k.ClusterConfiguration,
 		&k.KubeletConfiguration,
 		&k.KubeProxyConfiguration)
 }
 
+// mergeKubeadmConfigs merges the kubeadm configs of the layers, with the fields sealer computes for master0 set over
+// them, and then spec.kubernetes of Clusterfile over all.
+func (k *KubeadmRuntime) mergeKubeadmConfigs() error {
+	if err := k.LoadKubeadmConfigs(k.getDefaultKubeadmConfig(), k.Config.Clusterfile); err != nil {
+		return fmt.Errorf("failed to load kubeadm config from clusterfile: %v", err)
+	}
+	// TODO handle the kubeadm config, like kubeproxy config
+	k.handleKubeadmConfig()
+	k.MergeKubernetesSpec(k.Spec.Kubernetes)
+	return nil
+}
+
 func (k *KubeadmRuntime) handleKubeadmConfig() {
 	//The configuration set here does not require merge
 	k.setInitAdvertiseAddress(k.getMaster0IP())
@@ -122,6 +152,10 @@ func (k *KubeadmRuntime) GenerateCert() error {
 	if err != nil {
 		return err
 	}
+	err = k.GenerateEncryptionConfig()
+	if err != nil {
+		return fmt.Errorf("generate encryption config failed %v", err)
+	}
 	err = k.sendNewCertAndKey(k.getMasterIPList()[:1])
 	if err != nil {
 		return err
@@ -151,6 +185,9 @@ func (k *KubeadmRuntime) CopyStaticFiles(nodes []string) error {
 
 	for _, file := range MasterStaticFiles {
 		staticFilePath := filepath.Join(k.getStaticFileDir(), file.Name)
+		if file.Name == AuditPolicyYml {
+			staticFilePath = k.getAuditPolicyFile()
+		}
 		cmdLinkStatic := fmt.Sprintf(RemoteCmdCopyStatic, file.DestinationDir, staticFilePath, filepath.Join(file.DestinationDir, file.Name))
 		var wg sync.WaitGroup
 		for _, host := range nodes {
@@ -159,12 +196,12 @@ func (k *KubeadmRuntime) CopyStaticFiles(nodes []string) error {
 				defer wg.Done()
 				ssh, err := k.getHostSSHClient(host)
 				if err != nil {
-					errCh <- fmt.Errorf("new ssh client failed %v", err)
+					errCh <- result.OnHost(host, fmt.Errorf("new ssh client failed %v", err))
 					return
 				}
 				err = ssh.CmdAsync(host, cmdLinkStatic)
 				if err != nil {
-					errCh <- fmt.Errorf("[%s] link static file failed, error:%s", host, err.Error())
+					errCh <- result.OnHost(host, fmt.Errorf("[%s] link static file failed, error:%s", host, err.Error()))
 				}
 			}(host)
 		}
@@ -209,17 +246,23 @@ func (k *KubeadmRuntime) decodeJoinCmd(cmd string) {
 }
 
 //InitMaster0 is
-func (k *KubeadmRuntime) InitMaster0() error {
-	ssh, err := k.getHostSSHClient(k.getMaster0IP())
+func (k *KubeadmRuntime) InitMaster0() (err error) {
+	ssh, end, err := k.startHostSpan("init master0", k.getMaster0IP())
 	if err != nil {
 		return fmt.Errorf("failed to get master0 ssh client, %v", err)
 	}
+	defer func() {
+		end(err)
+	}()
 
 	if err := k.SendJoinMasterKubeConfigs([]string{k.getMaster0IP()}, AdminConf, ControllerConf, SchedulerConf, KubeletConf); err != nil {
 		return err
 	}
-	cmdAddEtcHost := fmt.Sprintf(RemoteAddEtcHosts, getAPIServerHost(k.getMaster0IP(), k.getAPIServerDomain()))
-	err = ssh.CmdAsync(k.getMaster0IP(), cmdAddEtcHost)
+	// the other masters are registered once they are joined.
+	if err = k.registerDomain(k.getAPIServerDomain(), k.getAPIServerDomainIPs([]string{k.getMaster0IP()})); err != nil {
+		return err
+	}
+	err = k.applyEtcHosts(ssh, k.getMaster0IP(), etchosts.Entry{IP: k.getMaster0IP(), Domain: k.getAPIServerDomain()})
 	if err != nil {
 		return err
 	}
@@ -242,8 +285,11 @@ func (k *KubeadmRuntime) InitMaster0() error {
 	return nil
 }
 
+// GetKubectlAndKubeconfig fetches the admin kubeconfig of master0 to the cluster dir, and merges it into
+// the local kubeconfig as the context named after cluster, unless spec.kubeconfig.noMerge is set.
 func (k *KubeadmRuntime) GetKubectlAndKubeconfig() error {
-	if utils.IsFileExist(common.DefaultKubeConfigFile()) {
+	if k.Spec.Kubeconfig.NoMerge && utils.IsFileExist(common.DefaultKubeConfigFile()) ||
+		KubeconfigHasContext(common.DefaultKubeConfigFile(), k.getClusterName()) {
 		return nil
 	}
 	ssh, err := k.getHostSSHClient(k.getMaster0IP())
@@ -251,7 +297,31 @@ func (k *KubeadmRuntime) GetKubectlAndKubeconfig() error {
 		return fmt.Errorf("failed to get master0 ssh client when get kubbectl and kubeconfig %v", err)
 	}
 
-	return GetKubectlAndKubeconfig(ssh, k.getMaster0IP())
+	kubeconfig := k.getAdminKubeconfig()
+	if k.Spec.Kubeconfig.NoMerge {
+		kubeconfig = common.DefaultKubeConfigFile()
+	}
+	if err := fetchKubeconfig(ssh, k.getMaster0IP(), kubeconfig); err != nil {
+		return err
+	}
+	if k.Spec.Kubeconfig.EtcHosts {
+		err = etchosts.UpdateFile(common.EtcHosts, []etchosts.Entry{{IP: k.getMaster0IP(), Domain: common.APIServerDomain}})
+		if err != nil {
+			return fmt.Errorf("failed to add master IP to etc hosts: %v", err)
+		}
+	} else {
+		// the local host is usually out of the cluster, where neither the domain nor the VIP balanced by lvscare on the
+		// nodes resolves, so it only reaches master0, point it to another master if master0 is down.
+		if err := PointKubeconfigToHost(kubeconfig, k.getMaster0IP(), k.getAPIServerDomain()); err != nil {
+			return err
+		}
+	}
+	if !k.Spec.Kubeconfig.NoMerge {
+		if err := MergeKubeconfig(kubeconfig, common.DefaultKubeConfigFile(), k.getClusterName()); err != nil {
+			return err
+		}
+	}
+	return fetchKubectl(ssh, k.getMaster0IP())
 }
 
 func (k *KubeadmRuntime) CopyStaticFilesTomasters() error {
@@ -264,8 +334,15 @@ func (k *KubeadmRuntime) init(cluster *v2.Cluster) error {
 		k.GenerateCert,
 		k.CreateKubeConfig,
 		k.CopyStaticFilesTomasters,
+		k.ConfigProxyOnMaster0,
 		k.ApplyRegistry,
+		k.PushComponentImages,
+		k.WriteImagePatchesOnMaster0,
+		k.WriteKubeletPatchesOnMaster0,
+		k.DeployCRIDockerdOnMaster0,
 		k.InitMaster0,
+		k.ApplyKubeletOverridesOnMaster0,
+		k.ConfigDNS,
 		k.GetKubectlAndKubeconfig,
 	}
 
@@ -0,0 +1,125 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+
+	"k8s.io/kube-proxy/config/v1alpha1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	ProxyModeIPTables = "iptables"
+	ProxyModeIPVS     = "ipvs"
+	ProxyModeNFTables = "nftables"
+	// V1290 is the first version of kube-proxy supporting nftables mode.
+	V1290 = "v1.29.0"
+
+	DefaultIPVSScheduler = "rr"
+)
+
+// IPVSSchedulers are the schedulers of ipvs, each of them is the kernel module ip_vs_<scheduler>.
+var IPVSSchedulers = []string{"rr", "wrr", "lc", "wlc", "lblc", "lblcr", "dh", "sh", "sed", "nq", "mh"}
+
+// mergeKubeProxySpec sets the kube-proxy mode and the ipvs and conntrack settings in Clusterfile spec.kubernetes.kubeProxy.
+func (k *KubeadmConfig) mergeKubeProxySpec(spec v2.KubeProxySpec) {
+	if spec.Mode != "" {
+		k.KubeProxyConfiguration.Mode = v1alpha1.ProxyMode(spec.Mode)
+	}
+	ipvs := spec.IPVS
+	if ipvs.Scheduler != "" {
+		k.IPVS.Scheduler = ipvs.Scheduler
+	}
+	if ipvs.StrictARP {
+		k.IPVS.StrictARP = true
+	}
+	if ipvs.TCPTimeout.Duration != 0 {
+		k.IPVS.TCPTimeout = ipvs.TCPTimeout
+	}
+	if ipvs.TCPFinTimeout.Duration != 0 {
+		k.IPVS.TCPFinTimeout = ipvs.TCPFinTimeout
+	}
+	if ipvs.UDPTimeout.Duration != 0 {
+		k.IPVS.UDPTimeout = ipvs.UDPTimeout
+	}
+	conntrack := spec.Conntrack
+	if conntrack.MaxPerCore != 0 {
+		maxPerCore := conntrack.MaxPerCore
+		k.Conntrack.MaxPerCore = &maxPerCore
+	}
+	if conntrack.Min != 0 {
+		min := conntrack.Min
+		k.Conntrack.Min = &min
+	}
+	if conntrack.TCPEstablishedTimeout.Duration != 0 {
+		timeout := conntrack.TCPEstablishedTimeout
+		k.Conntrack.TCPEstablishedTimeout = &timeout
+	}
+	if conntrack.TCPCloseWaitTimeout.Duration != 0 {
+		timeout := conntrack.TCPCloseWaitTimeout
+		k.Conntrack.TCPCloseWaitTimeout = &timeout
+	}
+}
+
+// getKubeProxyMode returns the mode of the merged KubeProxyConfiguration, kube-proxy uses iptables if it is empty.
+func (k *KubeadmConfig) getKubeProxyMode() string {
+	if k.KubeProxyConfiguration.Mode == "" {
+		return ProxyModeIPTables
+	}
+	return string(k.KubeProxyConfiguration.Mode)
+}
+
+// validateKubeProxySpec checks spec.kubernetes.kubeProxy against the merged KubeProxyConfiguration and kubernetes version.
+func (k *KubeadmRuntime) validateKubeProxySpec() error {
+	spec := k.Spec.Kubernetes.KubeProxy
+	mode := k.getKubeProxyMode()
+	switch mode {
+	case ProxyModeIPTables, ProxyModeIPVS:
+	case ProxyModeNFTables:
+		if k.getKubeVersion() != "" && !VersionCompare(k.getKubeVersion(), V1290) {
+			return fmt.Errorf("kube-proxy supports nftables mode since %s, but kubernetes is %s", V1290, k.getKubeVersion())
+		}
+	default:
+		return fmt.Errorf("invalid kube-proxy mode %s, must be one of %s, %s and %s", mode, ProxyModeIPTables, ProxyModeIPVS, ProxyModeNFTables)
+	}
+	if mode != ProxyModeIPVS && spec.IPVS != (v2.IPVSSpec{}) {
+		return fmt.Errorf("spec.kubernetes.kubeProxy.ipvs is only used in ipvs mode, but kube-proxy runs in %s mode", mode)
+	}
+	if s := spec.IPVS.Scheduler; s != "" && utils.NotIn(s, IPVSSchedulers) {
+		return fmt.Errorf("invalid ipvs scheduler %s, must be one of %v", s, IPVSSchedulers)
+	}
+	return nil
+}
+
+// KubeProxyKernelModules returns the kernel modules kube-proxy needs in the mode of spec, nil if the mode is not set,
+// as the mode in the kubeadm config of CloudImage is unknown before it is mounted.
+func KubeProxyKernelModules(spec v2.KubeProxySpec) []string {
+	switch spec.Mode {
+	case ProxyModeIPVS:
+		scheduler := spec.IPVS.Scheduler
+		if scheduler == "" {
+			scheduler = DefaultIPVSScheduler
+		}
+		return []string{"ip_vs", "ip_vs_" + scheduler, "nf_conntrack"}
+	case ProxyModeIPTables:
+		return []string{"ip_tables", "iptable_nat", "nf_conntrack"}
+	case ProxyModeNFTables:
+		return []string{"nf_tables", "nf_conntrack"}
+	}
+	return nil
+}
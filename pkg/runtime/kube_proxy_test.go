@@ -0,0 +1,87 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestKubeadmConfig_MergeKubeProxySpec(t *testing.T) {
+	k := &KubeadmConfig{}
+	k.KubeProxyConfiguration.Mode = ProxyModeIPVS
+	k.IPVS.ExcludeCIDRs = []string{"10.103.97.2/32"}
+	k.MergeKubernetesSpec(v2.KubernetesSpec{KubeProxy: v2.KubeProxySpec{
+		IPVS:      v2.IPVSSpec{Scheduler: "wrr", StrictARP: true, TCPTimeout: metav1.Duration{Duration: 900 * time.Second}},
+		Conntrack: v2.ConntrackSpec{MaxPerCore: 65536},
+	}})
+	if k.getKubeProxyMode() != ProxyModeIPVS || k.IPVS.Scheduler != "wrr" || !k.IPVS.StrictARP ||
+		k.IPVS.TCPTimeout.Duration != 900*time.Second || len(k.IPVS.ExcludeCIDRs) != 1 {
+		t.Errorf("ipvs config = %+v, mode %s", k.IPVS, k.getKubeProxyMode())
+	}
+	if k.Conntrack.MaxPerCore == nil || *k.Conntrack.MaxPerCore != 65536 || k.Conntrack.Min != nil {
+		t.Errorf("conntrack config = %+v", k.Conntrack)
+	}
+}
+
+func TestValidateKubeProxySpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    v2.KubeProxySpec
+		version string
+		wantErr string
+	}{
+		{"ipvs", v2.KubeProxySpec{Mode: "ipvs", IPVS: v2.IPVSSpec{Scheduler: "sh"}}, "v1.22.8", ""},
+		{"nftables", v2.KubeProxySpec{Mode: "nftables"}, "v1.29.1", ""},
+		{"nftables before 1.29", v2.KubeProxySpec{Mode: "nftables"}, "v1.22.8", "supports nftables mode since"},
+		{"invalid mode", v2.KubeProxySpec{Mode: "userspace"}, "v1.22.8", "invalid kube-proxy mode"},
+		{"ipvs settings in iptables mode", v2.KubeProxySpec{Mode: "iptables", IPVS: v2.IPVSSpec{StrictARP: true}}, "v1.22.8", "only used in ipvs mode"},
+		{"invalid scheduler", v2.KubeProxySpec{Mode: "ipvs", IPVS: v2.IPVSSpec{Scheduler: "random"}}, "v1.22.8", "invalid ipvs scheduler"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KubeadmRuntime{Cluster: &v2.Cluster{}, KubeadmConfig: &KubeadmConfig{}}
+			k.Spec.Kubernetes.KubeProxy = tt.spec
+			k.KubernetesVersion = tt.version
+			k.mergeKubeProxySpec(tt.spec)
+			err := k.validateKubeProxySpec()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateKubeProxySpec() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNodeLocalDNSBindClusterDNS(t *testing.T) {
+	config := dnsConfig{Domain: "cluster.local", ClusterDNS: "10.96.0.10", LocalIP: DefaultNodeLocalDNSIP, BindClusterDNS: true}
+	out, err := renderDNSTemplate(nodeLocalDNSTemplate, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"bind 169.254.20.10 10.96.0.10", "forward . __PILLAR__CLUSTER__DNS__", `"-localip", "169.254.20.10,10.96.0.10"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("node local dns in iptables mode does not contain %s", want)
+		}
+	}
+	config.BindClusterDNS = false
+	if out, _ = renderDNSTemplate(nodeLocalDNSTemplate, config); strings.Contains(out, "__PILLAR__") || !strings.Contains(out, "forward . 10.96.0.10") {
+		t.Errorf("node local dns in ipvs mode should forward to the cluster dns")
+	}
+}
@@ -34,28 +34,16 @@ echo ${driver}`
 
 // k.getKubeVersion can't be empty
 func (k *KubeadmRuntime) setKubeadmAPIVersion() {
-	switch {
-	case VersionCompare(k.getKubeVersion(), V1150) && !VersionCompare(k.getKubeVersion(), V1200):
-		k.InitConfiguration.APIVersion = KubeadmV1beta2
-		k.ClusterConfiguration.APIVersion = KubeadmV1beta2
-		k.JoinConfiguration.APIVersion = KubeadmV1beta2
-	// kubernetes gt 1.20, use Containerd instead of docker
-	case VersionCompare(k.getKubeVersion(), V1200):
-		k.InitConfiguration.APIVersion = KubeadmV1beta2
-		k.ClusterConfiguration.APIVersion = KubeadmV1beta2
-		k.JoinConfiguration.APIVersion = KubeadmV1beta2
-	default:
-		// Compatible with versions 1.14 and 1.13. but do not recommended.
-		k.InitConfiguration.APIVersion = KubeadmV1beta1
-		k.ClusterConfiguration.APIVersion = KubeadmV1beta1
-		k.JoinConfiguration.APIVersion = KubeadmV1beta1
-	}
+	version := kubeadmAPIVersion(k.getKubeVersion())
+	k.InitConfiguration.APIVersion = version
+	k.ClusterConfiguration.APIVersion = version
+	k.JoinConfiguration.APIVersion = version
 }
 
 // getCgroupDriverFromShell is get nodes container runtime CGroup by shell.
 func (k *KubeadmRuntime) getCgroupDriverFromShell(node string) string {
 	var cmd string
-	if k.InitConfiguration.NodeRegistration.CRISocket == DefaultContainerdCRISocket {
+	if strings.HasSuffix(k.InitConfiguration.NodeRegistration.CRISocket, DefaultContainerdCRISocket) {
 		cmd = ContainerdShell
 	} else {
 		cmd = DockerShell
@@ -76,12 +64,12 @@ func (k *KubeadmRuntime) MergeKubeadmConfig() error {
 	if k.getKubeVersion() != "" {
 		return nil
 	}
-	if err := k.LoadFromClusterfile(k.Config.Clusterfile); err != nil {
+	if err := k.LoadKubeadmConfigs(k.getDefaultKubeadmConfig(), k.Config.Clusterfile); err != nil {
 		return fmt.Errorf("failed to load kubeadm config from clusterfile: %v", err)
 	}
-	if err := k.Merge(k.getDefaultKubeadmConfig()); err != nil {
-		return fmt.Errorf("failed to merge kubeadm config: %v", err)
-	}
+	k.MergeKubernetesSpec(k.Spec.Kubernetes)
+	k.setCRISocket()
+	k.loadKubeletCgroupDriver()
 	k.setKubeadmAPIVersion()
 	return nil
 }
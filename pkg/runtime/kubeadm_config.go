@@ -15,21 +15,25 @@
 package runtime
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
-	"github.com/imdario/mergo"
 	"k8s.io/kube-proxy/config/v1alpha1"
 	"k8s.io/kubelet/config/v1beta1"
 )
 
 // Read config from https://github.com/alibaba/sealer/blob/main/docs/design/clusterfile-v2.md and overwrite default kubeadm.yaml
-// Use github.com/imdario/mergo to merge kubeadm config in Clusterfile and the default kubeadm config
-// Using a config filter to handle some edge cases
+// The kubeadm configs in Clusterfile and the default kubeadm config are merged by layers, see LoadKubeadmConfigs
 
 // https://github.com/kubernetes/kubernetes/blob/master/cmd/kubeadm/app/apis/kubeadm/v1beta2/types.go
 // Using map to overwrite Kubeadm configs
@@ -50,88 +54,154 @@ type KubeConfigSpec struct {
 	v1beta2.JoinConfiguration
 }
 
-// LoadFromClusterfile :Load KubeadmConfig from Clusterfile.
-// If it has `KubeadmConfig` in Clusterfile, load every field to each configuration.
-// If Kubeadm raw config in Clusterfile, just load it.
-func (k *KubeadmConfig) LoadFromClusterfile(fileName string) error {
-	if fileName == "" {
-		return nil
-	}
-	kubeConfig, err := DecodeCRDFromFile(fileName, Kubeadmconfig)
+// LoadKubeadmConfigs merges the kubeadm configs of the layers below into k, a later layer overrides the fields set
+// in the former ones, see kubeadm_merge.go:
+//
+//  1. the default kubeadm config of CloudImage at kubeadmYamlPath, or DefaultKubeadmConfig if it does not exist.
+//  2. the raw kubeadm configs in clusterfile, like InitConfiguration.
+//  3. the spec of KubeadmConfig in clusterfile.
+//
+// spec.kubernetes of Clusterfile, which the flags of sealer run are set to, is merged over them by MergeKubernetesSpec.
+func (k *KubeadmConfig) LoadKubeadmConfigs(kubeadmYamlPath, clusterfile string) error {
+	defaults, err := loadDefaultKubeadmLayer(kubeadmYamlPath)
 	if err != nil {
 		return err
-	} else if kubeConfig != nil {
-		k.APIServer.CertSANs = append(k.APIServer.CertSANs, kubeConfig.(*KubeadmConfig).APIServer.CertSANs...)
-		if err := mergo.Merge(&k.KubeConfigSpec, kubeConfig.(*KubeadmConfig).KubeConfigSpec); err != nil {
+	}
+	layers := []kubeadmLayer{defaults}
+	if clusterfile != "" {
+		data, err := ioutil.ReadFile(filepath.Clean(clusterfile))
+		if err != nil {
 			return err
 		}
+		raw, spec, err := decodeKubeadmLayers(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to load kubeadm config from %s, err: %v", clusterfile, err)
+		}
+		layers = append(layers, raw, spec)
 	}
-
-	kubeadmConfig, err := k.loadKubeadmConfigs(fileName, DecodeCRDFromFile)
+	spec, err := decodeKubeadmConfigs(mergeKubeadmLayers(layers...))
 	if err != nil {
-		return fmt.Errorf("failed to load kubeadm config from %s, err: %v", fileName, err)
-	} else if kubeConfig == nil {
-		return nil
+		return err
 	}
-	return mergo.Merge(k, kubeadmConfig)
+	certSANs := k.APIServer.CertSANs
+	k.KubeConfigSpec = *spec
+	k.APIServer.CertSANs = utils.RemoveDuplicate(append(certSANs, k.APIServer.CertSANs...))
+	return nil
 }
 
-// Merge Using github.com/imdario/mergo to merge KubeadmConfig to the CloudImage default kubeadm config, overwrite some field.
-// if defaultKubeadmConfig file not exist, use default raw kubeadm config to merge k.KubeConfigSpec empty value
-func (k *KubeadmConfig) Merge(kubeadmYamlPath string) error {
-	var (
-		defaultKubeadmConfig *KubeadmConfig
-		err                  error
-	)
-	if kubeadmYamlPath == "" || !utils.IsFileExist(kubeadmYamlPath) {
-		defaultKubeadmConfig, err = k.loadKubeadmConfigs(DefaultKubeadmConfig, DecodeCRDFromString)
-		if err != nil {
-			return err
+// loadDefaultKubeadmLayer loads the default kubeadm config of CloudImage, DefaultKubeadmConfig is used if it does not
+// exist or fails to decode.
+func loadDefaultKubeadmLayer(kubeadmYamlPath string) (kubeadmLayer, error) {
+	if kubeadmYamlPath != "" && utils.IsFileExist(kubeadmYamlPath) {
+		data, err := ioutil.ReadFile(filepath.Clean(kubeadmYamlPath))
+		if err == nil {
+			var layer kubeadmLayer
+			if layer, _, err = decodeKubeadmLayers(bytes.NewReader(data)); err == nil {
+				return layer, nil
+			}
 		}
-	} else {
-		defaultKubeadmConfig, err = k.loadKubeadmConfigs(kubeadmYamlPath, DecodeCRDFromFile)
-		if err != nil {
-			logger.Debug("failed to found kubeadm config from %s : %v, will use default kubeadm config to merge empty value", kubeadmYamlPath, err)
-			return k.Merge("")
+		logger.Debug("failed to found kubeadm config from %s : %v, will use default kubeadm config to merge empty value", kubeadmYamlPath, err)
+	}
+	layer, _, err := decodeKubeadmLayers(strings.NewReader(DefaultKubeadmConfig))
+	return layer, err
+}
+
+// MergeKubernetesSpec merges the extra args and volumes in Clusterfile spec.kubernetes to the kubeadm configs,
+// values in Clusterfile win over the same keys in KubeadmConfig and the default kubeadm config, so call it after Merge.
+func (k *KubeadmConfig) MergeKubernetesSpec(spec v2.KubernetesSpec) {
+	if spec.ImageRepository != "" {
+		k.ImageRepository = spec.ImageRepository
+	}
+	if spec.Networking.PodSubnet != "" {
+		k.ClusterConfiguration.Networking.PodSubnet = spec.Networking.PodSubnet
+	}
+	if spec.Networking.ServiceSubnet != "" {
+		k.ClusterConfiguration.Networking.ServiceSubnet = spec.Networking.ServiceSubnet
+	}
+	mergeControlPlaneComponent(&k.APIServer.ControlPlaneComponent, spec.APIServer)
+	mergeAuditSpec(&k.APIServer.ControlPlaneComponent, spec.Audit)
+	if spec.EncryptionAtRest.Enabled {
+		k.APIServer.ExtraArgs = mergeExtraArgs(k.APIServer.ExtraArgs, map[string]string{EncryptionProviderConfigArg: EncryptionConfigPath})
+	}
+	mergeControlPlaneComponent(&k.ControllerManager, spec.ControllerManager)
+	mergeControlPlaneComponent(&k.Scheduler, spec.Scheduler)
+	if len(spec.Etcd.ExtraArgs) != 0 {
+		if k.Etcd.Local == nil {
+			k.Etcd.Local = &v1beta2.LocalEtcd{}
 		}
+		k.Etcd.Local.ExtraArgs = mergeExtraArgs(k.Etcd.Local.ExtraArgs, spec.Etcd.ExtraArgs)
 	}
+	if tag := imageTag(spec.Etcd.Image); tag != "" {
+		if k.Etcd.Local == nil {
+			k.Etcd.Local = &v1beta2.LocalEtcd{}
+		}
+		k.Etcd.Local.ImageTag = tag
+	}
+	if len(spec.Kubelet.ExtraArgs) != 0 {
+		k.InitConfiguration.NodeRegistration.KubeletExtraArgs = mergeExtraArgs(k.InitConfiguration.NodeRegistration.KubeletExtraArgs, spec.Kubelet.ExtraArgs)
+		k.JoinConfiguration.NodeRegistration.KubeletExtraArgs = mergeExtraArgs(k.JoinConfiguration.NodeRegistration.KubeletExtraArgs, spec.Kubelet.ExtraArgs)
+	}
+	k.mergeDNSSpec(spec.DNS)
+	k.mergeKubeProxySpec(spec.KubeProxy)
+	k.mergeFeatureGates(spec.FeatureGates)
+}
 
-	return mergo.Merge(k, defaultKubeadmConfig)
+func mergeControlPlaneComponent(component *v1beta2.ControlPlaneComponent, spec v2.ComponentSpec) {
+	if len(spec.ExtraArgs) != 0 {
+		component.ExtraArgs = mergeExtraArgs(component.ExtraArgs, spec.ExtraArgs)
+	}
+	for _, v := range spec.ExtraVolumes {
+		volume := v1beta2.HostPathMount{
+			Name:      v.Name,
+			HostPath:  v.HostPath,
+			MountPath: v.MountPath,
+			ReadOnly:  v.ReadOnly,
+			PathType:  corev1.HostPathType(v.PathType),
+		}
+		replaced := false
+		for i := range component.ExtraVolumes {
+			if component.ExtraVolumes[i].Name == volume.Name {
+				component.ExtraVolumes[i] = volume
+				replaced = true
+			}
+		}
+		if !replaced {
+			component.ExtraVolumes = append(component.ExtraVolumes, volume)
+		}
+	}
 }
 
-func (k *KubeadmConfig) loadKubeadmConfigs(arg string, decode func(arg string, kind string) (interface{}, error)) (*KubeadmConfig, error) {
-	kubeadmConfig := &KubeadmConfig{}
-	initConfig, err := decode(arg, InitConfiguration)
-	if err != nil {
-		return nil, err
-	} else if initConfig != nil {
-		kubeadmConfig.InitConfiguration = *initConfig.(*v1beta2.InitConfiguration)
+func mergeExtraArgs(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
 	}
-	clusterConfig, err := decode(arg, ClusterConfiguration)
-	if err != nil {
-		return nil, err
-	} else if clusterConfig != nil {
-		kubeadmConfig.ClusterConfiguration = *clusterConfig.(*v1beta2.ClusterConfiguration)
+	for k, v := range src {
+		dst[k] = v
 	}
-	kubeProxyConfig, err := decode(arg, KubeProxyConfiguration)
+	return dst
+}
+
+// RenderKubeadmConfigs returns the kubeadm configs of cluster merged by layers, as the ones sealer generates for
+// master0 but without connecting to it, so the cgroup driver detected on the hosts is not set.
+func RenderKubeadmConfigs(cluster *v2.Cluster, clusterfile string) ([]byte, error) {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
 	if err != nil {
 		return nil, err
-	} else if kubeProxyConfig != nil {
-		kubeadmConfig.KubeProxyConfiguration = *kubeProxyConfig.(*v1alpha1.KubeProxyConfiguration)
 	}
-	kubeletConfig, err := decode(arg, KubeletConfiguration)
-	if err != nil {
-		return nil, err
-	} else if kubeletConfig != nil {
-		kubeadmConfig.KubeletConfiguration = *kubeletConfig.(*v1beta1.KubeletConfiguration)
+	k := i.(*KubeadmRuntime)
+	if !utils.IsFileExist(k.getDefaultKubeadmConfig()) {
+		logger.Warn("CloudImage of cluster %s is not mounted, the builtin default kubeadm config is used", k.getClusterName())
 	}
-	joinConfig, err := decode(arg, JoinConfiguration)
-	if err != nil {
+	if err := k.mergeKubeadmConfigs(); err != nil {
 		return nil, err
-	} else if joinConfig != nil {
-		kubeadmConfig.JoinConfiguration = *joinConfig.(*v1beta2.JoinConfiguration)
 	}
-	return kubeadmConfig, nil
+	k.setCRISocket()
+	k.setKubeadmAPIVersion()
+	return k.marshalKubeadmConfigs(&k.InitConfiguration,
+		&k.ClusterConfiguration,
+		&k.JoinConfiguration,
+		&k.KubeletConfiguration,
+		&k.KubeProxyConfiguration)
 }
 
 func NewKubeadmConfig() interface{} {
@@ -18,9 +18,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+	v2 "github.com/alibaba/sealer/types/api/v2"
 
 	"github.com/alibaba/sealer/utils"
 )
@@ -323,7 +328,7 @@ nodeRegistration:
   criSocket: /var/run/dockershim.sock`
 )
 
-func TestKubeadmConfig_LoadFromClusterfile(t *testing.T) {
+func TestKubeadmConfig_LoadKubeadmConfigs(t *testing.T) {
 	type fields struct {
 		KubeConfig *KubeadmConfig
 	}
@@ -359,8 +364,8 @@ func TestKubeadmConfig_LoadFromClusterfile(t *testing.T) {
 					t.Errorf("Remove %s error = %v, wantErr %v", testfile, err, tt.wantErr)
 				}
 			}()
-			if err := k.LoadFromClusterfile(testfile); (err != nil) != tt.wantErr {
-				t.Errorf("LoadFromClusterfile() error = %v, wantErr %v", err, tt.wantErr)
+			if err := k.LoadKubeadmConfigs("", testfile); (err != nil) != tt.wantErr {
+				t.Errorf("LoadKubeadmConfigs() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			logger.Info("k.InitConfiguration.Kind", k.InitConfiguration.Kind)
 			out, err := utils.MarshalConfigsToYaml(k.InitConfiguration, k.ClusterConfiguration,
@@ -373,7 +378,7 @@ func TestKubeadmConfig_LoadFromClusterfile(t *testing.T) {
 	}
 }
 
-func TestKubeadmConfig_Merge(t *testing.T) {
+func TestKubeadmConfig_LoadDefaultKubeadmConfig(t *testing.T) {
 	type fields struct {
 		kubeadmConfig *KubeadmConfig
 	}
@@ -398,11 +403,6 @@ func TestKubeadmConfig_Merge(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			k := tt.fields.kubeadmConfig
-			/*			err := k.LoadFromClusterfile("test-kubeConfig.yml")
-						if (err != nil) != tt.wantErr {
-							t.Errorf("LoadFromClusterfile() error = %v, wantErr %v", err, tt.wantErr)
-							return
-						}*/
 			testfile := "test-kubeadm.yml"
 			err := ioutil.WriteFile(testfile, tt.args.defaultKubeadmConfig, 0644)
 			if (err != nil) != tt.wantErr {
@@ -416,11 +416,79 @@ func TestKubeadmConfig_Merge(t *testing.T) {
 					return
 				}
 			}()
-			err = k.Merge(testfile)
+			err = k.LoadKubeadmConfigs(testfile, "")
 			if (err != nil) != tt.wantErr {
-				t.Errorf("Merge() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("LoadKubeadmConfigs() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 		})
 	}
 }
+
+func TestKubeadmConfig_MergeKubernetesSpec(t *testing.T) {
+	k := &KubeadmConfig{}
+	k.APIServer.ExtraArgs = map[string]string{"audit-log-maxage": "7", "v": "2"}
+	k.APIServer.ExtraVolumes = []v1beta2.HostPathMount{{Name: "audit", HostPath: "/old", MountPath: "/old"}}
+	k.MergeKubernetesSpec(v2.KubernetesSpec{
+		APIServer: v2.ComponentSpec{
+			ExtraArgs: map[string]string{"audit-log-maxage": "30"},
+			ExtraVolumes: []v2.HostPathMount{
+				{Name: "audit", HostPath: "/var/log/kubernetes", MountPath: "/var/log/kubernetes"},
+				{Name: "localtime", HostPath: "/etc/localtime", MountPath: "/etc/localtime", ReadOnly: true, PathType: "File"},
+			},
+		},
+		Scheduler: v2.ComponentSpec{ExtraArgs: map[string]string{"v": "4"}},
+		Etcd:      v2.ComponentSpec{ExtraArgs: map[string]string{"quota-backend-bytes": "8589934592"}},
+		Kubelet:   v2.ComponentSpec{ExtraArgs: map[string]string{"max-pods": "200"}},
+	})
+
+	if want := map[string]string{"audit-log-maxage": "30", "v": "2"}; !reflect.DeepEqual(k.APIServer.ExtraArgs, want) {
+		t.Errorf("apiserver extraArgs = %v, want %v", k.APIServer.ExtraArgs, want)
+	}
+	if len(k.APIServer.ExtraVolumes) != 2 || k.APIServer.ExtraVolumes[0].HostPath != "/var/log/kubernetes" ||
+		k.APIServer.ExtraVolumes[1].PathType != corev1.HostPathFile {
+		t.Errorf("apiserver extraVolumes = %v", k.APIServer.ExtraVolumes)
+	}
+	if k.Scheduler.ExtraArgs["v"] != "4" || k.Etcd.Local == nil || k.Etcd.Local.ExtraArgs["quota-backend-bytes"] != "8589934592" {
+		t.Errorf("scheduler extraArgs = %v, etcd = %v", k.Scheduler.ExtraArgs, k.Etcd.Local)
+	}
+	if k.InitConfiguration.NodeRegistration.KubeletExtraArgs["max-pods"] != "200" ||
+		k.JoinConfiguration.NodeRegistration.KubeletExtraArgs["max-pods"] != "200" {
+		t.Errorf("kubelet extraArgs not merged to init and join configuration")
+	}
+}
+
+func TestKubeadmConfig_MergeAuditAndEncryption(t *testing.T) {
+	k := &KubeadmConfig{}
+	k.APIServer.ExtraArgs = map[string]string{"audit-policy-file": "/etc/kubernetes/audit-policy.yml", "v": "2"}
+	k.MergeKubernetesSpec(v2.KubernetesSpec{
+		Audit:            v2.AuditSpec{LogPath: "/data/audit/audit.log", MaxAge: 30},
+		EncryptionAtRest: v2.EncryptionSpec{Enabled: true},
+	})
+	want := map[string]string{
+		"audit-policy-file":          "/etc/kubernetes/audit-policy.yml",
+		"audit-log-path":             "/data/audit/audit.log",
+		"audit-log-maxage":           "30",
+		"encryption-provider-config": EncryptionConfigPath,
+		"v":                          "2",
+	}
+	if !reflect.DeepEqual(k.APIServer.ExtraArgs, want) {
+		t.Errorf("apiserver extraArgs = %v, want %v", k.APIServer.ExtraArgs, want)
+	}
+	if len(k.APIServer.ExtraVolumes) != 1 || k.APIServer.ExtraVolumes[0].HostPath != "/data/audit" {
+		t.Errorf("apiserver extraVolumes = %v", k.APIServer.ExtraVolumes)
+	}
+
+	k.MergeKubernetesSpec(v2.KubernetesSpec{Audit: v2.AuditSpec{Disabled: true}})
+	for arg := range k.APIServer.ExtraArgs {
+		if arg != "v" && arg != EncryptionProviderConfigArg {
+			t.Errorf("audit arg %s not removed when audit disabled", arg)
+		}
+	}
+
+	conf := newEncryptionConfiguration(v2.EncryptionSpec{}, []EncryptionKey{{Name: "key-1", Secret: "c2VjcmV0"}})
+	if conf.Resources[0].Resources[0] != DefaultEncryptionResource || len(conf.Resources[0].Providers) != 2 ||
+		conf.Resources[0].Providers[0][DefaultEncryptionProvider] == nil {
+		t.Errorf("unexpected encryption config %v", conf)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kube-proxy/config/v1alpha1"
+	"k8s.io/kubelet/config/v1beta1"
+
+	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+)
+
+// The kubeadm configs are merged as raw objects of each kind, a field set in a later layer overrides the same field
+// of the former ones, even if it is false or 0:
+//
+//   - maps like extraArgs are merged key by key, a key set to null removes it.
+//   - lists like extraVolumes are replaced as a whole, except the apiServer.certSANs, which are appended to the
+//     ones sealer generates.
+
+// kubeadmLayerKinds are the kinds of kubeadm configs merged by layers.
+var kubeadmLayerKinds = []string{InitConfiguration, ClusterConfiguration, JoinConfiguration, KubeletConfiguration, KubeProxyConfiguration}
+
+// kubeadmLayer is the raw kubeadm configs of a layer by kind, in the order they are in the layer.
+type kubeadmLayer map[string][]map[string]interface{}
+
+func (l kubeadmLayer) add(kind string, obj map[string]interface{}) {
+	if len(obj) != 0 {
+		l[kind] = append(l[kind], obj)
+	}
+}
+
+// mergeKubeadmLayers merges the configs of layers in order into one of each kind.
+func mergeKubeadmLayers(layers ...kubeadmLayer) map[string]map[string]interface{} {
+	merged := map[string]map[string]interface{}{}
+	for _, layer := range layers {
+		for _, kind := range kubeadmLayerKinds {
+			for _, obj := range layer[kind] {
+				if merged[kind] == nil {
+					merged[kind] = map[string]interface{}{}
+				}
+				mergeObject(merged[kind], obj)
+			}
+		}
+	}
+	return merged
+}
+
+// mergeObject merges src into dst recursively, the maps are merged key by key, the other values in src replace the
+// ones in dst, and a null in src removes the key from dst.
+func mergeObject(dst, src map[string]interface{}) {
+	for key, v := range src {
+		if v == nil {
+			delete(dst, key)
+			continue
+		}
+		srcMap, ok := v.(map[string]interface{})
+		if !ok {
+			dst[key] = v
+			continue
+		}
+		dstMap, ok := dst[key].(map[string]interface{})
+		if !ok {
+			dstMap = map[string]interface{}{}
+			dst[key] = dstMap
+		}
+		mergeObject(dstMap, srcMap)
+	}
+}
+
+// decodeKubeadmLayers decodes the raw kubeadm configs in r, like InitConfiguration, and the spec of KubeadmConfig
+// in r as two layers, the raw kubeadm configs of any version are converted to v1beta2 first.
+func decodeKubeadmLayers(r io.Reader) (raw, spec kubeadmLayer, err error) {
+	raw, spec = kubeadmLayer{}, kubeadmLayer{}
+	d := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		ext := k8sruntime.RawExtension{}
+		if err := d.Decode(&ext); err != nil {
+			if err == io.EOF {
+				return raw, spec, nil
+			}
+			return nil, nil, err
+		}
+		ext.Raw = bytes.TrimSpace(ext.Raw)
+		if len(ext.Raw) == 0 || bytes.Equal(ext.Raw, []byte("null")) {
+			continue
+		}
+		obj, err := decodeObject(ext.Raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		kind, _ := obj["kind"].(string)
+		switch {
+		case kind == Kubeadmconfig:
+			s, _ := obj["spec"].(map[string]interface{})
+			for _, kind := range kubeadmLayerKinds {
+				spec.add(kind, selectFields(s, kubeadmConfigFields(kind)))
+			}
+		case isKubeadmKind(kind):
+			data, err := kubeadmConfigToV1beta2(ext.Raw)
+			if err != nil {
+				return nil, nil, err
+			}
+			if obj, err = decodeObject(data); err != nil {
+				return nil, nil, err
+			}
+			raw.add(kind, obj)
+		case kind == KubeletConfiguration || kind == KubeProxyConfiguration:
+			raw.add(kind, obj)
+		}
+	}
+}
+
+// decodeObject decodes the json data to a raw object, the numbers are kept as they are.
+func decodeObject(data []byte) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", string(data), err)
+	}
+	return obj, nil
+}
+
+// kubeadmConfigFields returns the json names of the fields of kind, the spec of KubeadmConfig is all the kinds
+// inlined, so its fields are split to the kinds by them. The fields in more than one kind like nodeRegistration
+// are set to all of them.
+func kubeadmConfigFields(kind string) map[string]bool {
+	fields := map[string]bool{}
+	if i := typeConversion(kind); i != nil {
+		jsonFields(reflect.TypeOf(i).Elem(), fields)
+	}
+	// every kind has them, and they are not in the spec of KubeadmConfig.
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	return fields
+}
+
+func jsonFields(t reflect.Type, fields map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			jsonFields(f.Type, fields)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+}
+
+// selectFields returns the keys of obj in fields.
+func selectFields(obj map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	selected := map[string]interface{}{}
+	for key, v := range obj {
+		if fields[key] {
+			selected[key] = v
+		}
+	}
+	return selected
+}
+
+// decodeKubeadmConfigs decodes the merged raw configs to the kubeadm configs of each kind.
+func decodeKubeadmConfigs(configs map[string]map[string]interface{}) (*KubeConfigSpec, error) {
+	spec := &KubeConfigSpec{}
+	for _, kind := range kubeadmLayerKinds {
+		obj, ok := configs[kind]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		config, err := TypeConversion(data, kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", kind, err)
+		}
+		switch c := config.(type) {
+		case *v1beta2.InitConfiguration:
+			spec.InitConfiguration = *c
+		case *v1beta2.ClusterConfiguration:
+			spec.ClusterConfiguration = *c
+		case *v1beta2.JoinConfiguration:
+			spec.JoinConfiguration = *c
+		case *v1beta1.KubeletConfiguration:
+			spec.KubeletConfiguration = *c
+		case *v1alpha1.KubeProxyConfiguration:
+			spec.KubeProxyConfiguration = *c
+		}
+	}
+	return spec, nil
+}
@@ -0,0 +1,162 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const (
+	testDefaultKubeadmLayer = `apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+kubernetesVersion: v1.19.8
+networking:
+  podSubnet: 100.64.0.0/10
+  serviceSubnet: 10.96.0.0/22
+apiServer:
+  certSANs:
+  - 127.0.0.1
+  extraArgs:
+    feature-gates: TTLAfterFinished=true
+    audit-log-maxage: "7"
+  extraVolumes:
+  - name: localtime
+    hostPath: /etc/localtime
+    mountPath: /etc/localtime
+---
+apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+serializeImagePulls: true
+maxPods: 110
+---
+apiVersion: kubeproxy.config.k8s.io/v1alpha1
+kind: KubeProxyConfiguration
+mode: ipvs`
+
+	testClusterfileKubeadmLayers = `apiVersion: sealer.cloud/v2
+kind: Cluster
+metadata:
+  name: my-cluster
+spec:
+  image: kubernetes:v1.19.8
+  hosts:
+  - ips: [192.168.0.2]
+    roles: [master]
+---
+apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+serializeImagePulls: false
+maxPods: 200
+---
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: ClusterConfiguration
+networking:
+  podSubnet: 10.244.0.0/16
+apiServer:
+  extraArgs:
+    audit-log-maxage: "30"
+---
+apiVersion: sealer.aliyun.com/v1alpha1
+kind: KubeadmConfig
+spec:
+  maxPods: 150
+  apiServer:
+    certSANs:
+    - sealer.example.com
+    extraArgs:
+      feature-gates: null
+    extraVolumes:
+    - name: audit
+      hostPath: /var/log/audit
+      mountPath: /var/log/audit`
+)
+
+func TestKubeadmConfig_LoadKubeadmConfigsByLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-kubeadm-layers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defaults := filepath.Join(dir, "kubeadm.yml")
+	clusterfile := filepath.Join(dir, "Clusterfile")
+	if err := ioutil.WriteFile(defaults, []byte(testDefaultKubeadmLayer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(clusterfile, []byte(testClusterfileKubeadmLayers), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	k := &KubeadmConfig{}
+	k.APIServer.CertSANs = []string{"127.0.0.1", "apiserver.cluster.local"}
+	if err := k.LoadKubeadmConfigs(defaults, clusterfile); err != nil {
+		t.Fatalf("LoadKubeadmConfigs() error = %v", err)
+	}
+	k.MergeKubernetesSpec(v2.KubernetesSpec{Networking: v2.NetworkingSpec{ServiceSubnet: "10.97.0.0/16"}})
+
+	if k.KubernetesVersion != "v1.19.8" {
+		t.Errorf("kubernetesVersion = %s, want the default v1.19.8", k.KubernetesVersion)
+	}
+	if k.ClusterConfiguration.Networking.PodSubnet != "10.244.0.0/16" {
+		t.Errorf("podSubnet = %s, want 10.244.0.0/16 of the raw ClusterConfiguration", k.ClusterConfiguration.Networking.PodSubnet)
+	}
+	if k.ClusterConfiguration.Networking.ServiceSubnet != "10.97.0.0/16" {
+		t.Errorf("serviceSubnet = %s, want 10.97.0.0/16 of spec.kubernetes", k.ClusterConfiguration.Networking.ServiceSubnet)
+	}
+	if want := map[string]string{"audit-log-maxage": "30"}; !reflect.DeepEqual(k.APIServer.ExtraArgs, want) {
+		t.Errorf("apiServer.extraArgs = %v, want %v", k.APIServer.ExtraArgs, want)
+	}
+	if len(k.APIServer.ExtraVolumes) != 1 || k.APIServer.ExtraVolumes[0].Name != "audit" {
+		t.Errorf("apiServer.extraVolumes = %v, want only the audit one of KubeadmConfig", k.APIServer.ExtraVolumes)
+	}
+	if want := []string{"127.0.0.1", "apiserver.cluster.local", "sealer.example.com"}; !reflect.DeepEqual(k.APIServer.CertSANs, want) {
+		t.Errorf("apiServer.certSANs = %v, want %v", k.APIServer.CertSANs, want)
+	}
+	if k.KubeletConfiguration.SerializeImagePulls == nil || *k.KubeletConfiguration.SerializeImagePulls {
+		t.Errorf("serializeImagePulls = %v, want false of the raw KubeletConfiguration", k.KubeletConfiguration.SerializeImagePulls)
+	}
+	if k.KubeletConfiguration.MaxPods != 150 {
+		t.Errorf("maxPods = %d, want 150 of KubeadmConfig", k.KubeletConfiguration.MaxPods)
+	}
+	if k.KubeProxyConfiguration.Mode != "ipvs" {
+		t.Errorf("kube-proxy mode = %s, want the default ipvs", k.KubeProxyConfiguration.Mode)
+	}
+}
+
+func TestMergeObject(t *testing.T) {
+	dst := map[string]interface{}{
+		"a": map[string]interface{}{"b": "1", "c": "2"},
+		"l": []interface{}{"x", "y"},
+		"t": true,
+	}
+	mergeObject(dst, map[string]interface{}{
+		"a": map[string]interface{}{"b": nil, "d": "3"},
+		"l": []interface{}{"z"},
+		"t": false,
+	})
+	want := map[string]interface{}{
+		"a": map[string]interface{}{"c": "2", "d": "3"},
+		"l": []interface{}{"z"},
+		"t": false,
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("mergeObject() = %v, want %v", dst, want)
+	}
+}
@@ -27,6 +27,9 @@ import (
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/metrics"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/tracing"
 	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils/ssh"
 )
@@ -38,6 +41,8 @@ type Config struct {
 	// Clusterfile: the absolute path, we need to read kubeadm config from Clusterfile
 	Clusterfile     string
 	APIServerDomain string
+	// KubeletCgroupDriver is the cgroup driver of kubelet in Clusterfile, the container runtime is configured to it
+	KubeletCgroupDriver string
 }
 
 func newKubeadmRuntime(cluster *v2.Cluster, clusterfile string) (Interface, error) {
@@ -98,6 +103,23 @@ func (k *KubeadmRuntime) getHostSSHClient(hostIP string) (ssh.Interface, error)
 	return ssh.GetHostSSHClient(hostIP, k.Cluster)
 }
 
+// startHostSpan starts the span of step on host under the span of the running phase,
+// the remote commands of the returned ssh client are traced within it until end is called.
+func (k *KubeadmRuntime) startHostSpan(step, host string) (ssh.Interface, func(err error), error) {
+	ctx, end := tracing.Start(ssh.Context(k.getClusterName()), step, tracing.Attr("host", host))
+	client, err := k.getHostSSHClient(host)
+	if err != nil {
+		end(err)
+		return nil, nil, err
+	}
+	return ssh.WithContext(ctx, client), end, nil
+}
+
+// /root/.sealer/my-cluster/admin.conf
+func (k *KubeadmRuntime) getAdminKubeconfig() string {
+	return filepath.Join(common.GetClusterWorkDir(k.getClusterName()), AdminConf)
+}
+
 // /var/lib/sealer/data/my-cluster
 func (k *KubeadmRuntime) getBasePath() string {
 	return common.DefaultClusterBaseDir(k.getClusterName())
@@ -208,6 +230,13 @@ func (k *KubeadmRuntime) setInitCertificateKey(certificateKey string) {
 	k.CertificateKey = certificateKey
 }
 
+func (k *KubeadmRuntime) setJoinCertificateKey(certificateKey string) {
+	if k.JoinConfiguration.ControlPlane == nil {
+		k.JoinConfiguration.ControlPlane = &v1beta2.JoinControlPlane{}
+	}
+	k.JoinConfiguration.ControlPlane.CertificateKey = certificateKey
+}
+
 func (k *KubeadmRuntime) setAPIServerEndpoint(endpoint string) {
 	k.JoinConfiguration.Discovery.BootstrapToken.APIServerEndpoint = endpoint
 }
@@ -270,22 +299,27 @@ func (k *KubeadmRuntime) WaitSSHReady(tryTimes int, hosts ...string) error {
 		wg.Add(1)
 		go func(host string) {
 			defer wg.Done()
+			_, end := tracing.Start(ssh.Context(k.getClusterName()), "wait ssh ready", tracing.Attr("host", host))
 			for i := 0; i < tryTimes; i++ {
 				sshClient, err := k.getHostSSHClient(host)
 				if err != nil {
+					end(err)
 					return
 				}
 
 				err = sshClient.Ping(host)
 				if err == nil {
+					end(nil)
 					return
 				}
+				metrics.ObserveRetry(k.Cluster.Name, host, "ssh")
 				time.Sleep(time.Duration(i) * time.Second)
 			}
 			err := fmt.Errorf("wait for [%s] ssh ready timeout, ensure that the IP address or password is correct", host)
-			errCh <- err
+			end(err)
+			errCh <- result.OnHost(host, err)
 		}(h)
 	}
 	wg.Wait()
-	return ReadChanError(errCh)
+	return result.Wrap(result.CategoryInfra, "WaitSSHReady", ReadChanError(errCh))
 }
@@ -0,0 +1,247 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+// The kubeadm configs are merged as v1beta2 in the runtime, the ones of other versions in Clusterfile and
+// CloudImage are converted to v1beta2 when they are decoded, and the generated ones are converted to the
+// version the kubeadm of the image supports when they are marshaled:
+//
+//   v1beta3 (v1.22+) drops useHyperKubeImage and the type of dns.
+//   v1beta4 (v1.31+) turns the extraArgs maps into lists of name and value, and moves the timeouts to the
+//   timeouts of Init and JoinConfiguration.
+
+const (
+	KubeadmV1beta4 = "kubeadm.k8s.io/v1beta4"
+	V1310          = "v1.31.0"
+)
+
+// kubeadmExtraArgsPaths are the paths of the extraArgs in each kind of kubeadm configs.
+var kubeadmExtraArgsPaths = map[string][][]string{
+	ClusterConfiguration: {
+		{"apiServer", "extraArgs"},
+		{"controllerManager", "extraArgs"},
+		{"scheduler", "extraArgs"},
+		{"etcd", "local", "extraArgs"},
+	},
+	InitConfiguration: {{"nodeRegistration", "kubeletExtraArgs"}},
+	JoinConfiguration: {{"nodeRegistration", "kubeletExtraArgs"}},
+}
+
+// kubeadmAPIVersion returns the newest kubeadm config version the kubeadm of version supports.
+func kubeadmAPIVersion(version string) string {
+	switch {
+	case version == "":
+		return KubeadmV1beta2
+	case VersionCompare(version, V1310):
+		return KubeadmV1beta4
+	case VersionCompare(version, V1220):
+		return KubeadmV1beta3
+	case VersionCompare(version, V1150):
+		return KubeadmV1beta2
+	default:
+		// Compatible with versions 1.14 and 1.13. but do not recommended.
+		return KubeadmV1beta1
+	}
+}
+
+func isKubeadmKind(kind string) bool {
+	_, ok := kubeadmExtraArgsPaths[kind]
+	return ok
+}
+
+// kubeadmConfigToV1beta2 converts the raw kubeadm config of any version to v1beta2, the fields only in the newer
+// versions are dropped by decoding.
+func kubeadmConfigToV1beta2(raw []byte) ([]byte, error) {
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	kind, _ := obj["kind"].(string)
+	if obj["apiVersion"] != KubeadmV1beta4 || !isKubeadmKind(kind) {
+		return raw, nil
+	}
+	for _, path := range kubeadmExtraArgsPaths[kind] {
+		convertNested(obj, path, extraArgsToMap)
+	}
+	if timeouts, ok := obj["timeouts"].(map[string]interface{}); ok {
+		if d, ok := timeouts["discovery"]; ok && kind == JoinConfiguration {
+			setNested(obj, []string{"discovery", "timeout"}, d)
+			delete(timeouts, "discovery")
+		}
+		if len(timeouts) != 0 {
+			logger.Warn("the timeouts %v of %s are ignored, set apiServer.timeoutForControlPlane of ClusterConfiguration instead", timeouts, kind)
+		}
+		delete(obj, "timeouts")
+	}
+	obj["apiVersion"] = KubeadmV1beta2
+	return json.Marshal(obj)
+}
+
+// convertKubeadmConfigs converts the v1beta2 kubeadm configs in docs to version in place, the other configs like
+// KubeletConfiguration are kept.
+func convertKubeadmConfigs(docs []map[string]interface{}, version string) {
+	if version != KubeadmV1beta3 && version != KubeadmV1beta4 {
+		for _, obj := range docs {
+			if kind, _ := obj["kind"].(string); isKubeadmKind(kind) {
+				obj["apiVersion"] = version
+			}
+		}
+		return
+	}
+
+	var timeoutForControlPlane interface{}
+	for _, obj := range docs {
+		kind, _ := obj["kind"].(string)
+		if !isKubeadmKind(kind) {
+			continue
+		}
+		obj["apiVersion"] = version
+		if kind == ClusterConfiguration {
+			delete(obj, "useHyperKubeImage")
+			if dns, ok := obj["dns"].(map[string]interface{}); ok {
+				delete(dns, "type")
+			}
+		}
+		if version != KubeadmV1beta4 {
+			continue
+		}
+		for _, path := range kubeadmExtraArgsPaths[kind] {
+			convertNested(obj, path, extraArgsToList)
+		}
+		if kind == ClusterConfiguration {
+			if apiServer, ok := obj["apiServer"].(map[string]interface{}); ok {
+				timeoutForControlPlane = apiServer["timeoutForControlPlane"]
+				delete(apiServer, "timeoutForControlPlane")
+			}
+		}
+		if discovery, ok := obj["discovery"].(map[string]interface{}); ok && discovery["timeout"] != nil {
+			setNested(obj, []string{"timeouts", "discovery"}, discovery["timeout"])
+			delete(discovery, "timeout")
+		}
+	}
+	if timeoutForControlPlane == nil {
+		return
+	}
+	for _, obj := range docs {
+		if obj["kind"] == InitConfiguration || obj["kind"] == JoinConfiguration {
+			setNested(obj, []string{"timeouts", "controlPlaneComponentHealthCheck"}, timeoutForControlPlane)
+		}
+	}
+}
+
+// marshalKubeadmConfigs marshals configs as a multi-document yaml, the kubeadm configs in it are converted to the
+// version the kubeadm of cluster supports.
+func (k *KubeadmRuntime) marshalKubeadmConfigs(configs ...interface{}) ([]byte, error) {
+	docs := make([]map[string]interface{}, 0, len(configs))
+	for _, c := range configs {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, err
+		}
+		docs = append(docs, obj)
+	}
+	convertKubeadmConfigs(docs, kubeadmAPIVersion(k.getKubeVersion()))
+
+	var out [][]byte
+	for _, obj := range docs {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data)
+	}
+	return bytes.Join(out, []byte("\n---\n")), nil
+}
+
+func extraArgsToList(v interface{}) interface{} {
+	args, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		list = append(list, map[string]interface{}{"name": name, "value": args[name]})
+	}
+	return list
+}
+
+// extraArgsToMap keeps the last value of the args repeated in the list, which kubeadm passes all of.
+func extraArgsToMap(v interface{}) interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	args := map[string]interface{}{}
+	for _, item := range list {
+		arg, _ := item.(map[string]interface{})
+		name, _ := arg["name"].(string)
+		if name == "" {
+			continue
+		}
+		if _, ok := args[name]; ok {
+			logger.Warn("extra arg %s is repeated, only the last value is used", name)
+		}
+		args[name] = fmt.Sprint(arg["value"])
+	}
+	return args
+}
+
+// convertNested replaces the value at path of obj with convert of it, if it exists.
+func convertNested(obj map[string]interface{}, path []string, convert func(interface{}) interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		obj = next
+	}
+	last := path[len(path)-1]
+	if v, ok := obj[last]; ok && v != nil {
+		obj[last] = convert(v)
+	}
+}
+
+// setNested sets the value at path of obj, creating the maps on the way.
+func setNested(obj map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[path[len(path)-1]] = value
+}
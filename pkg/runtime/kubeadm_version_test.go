@@ -0,0 +1,123 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/sealer/pkg/runtime/kubeadm_types/v1beta2"
+)
+
+func TestKubeadmAPIVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"v1.14.8":  KubeadmV1beta1,
+		"v1.19.8":  KubeadmV1beta2,
+		"v1.22.0":  KubeadmV1beta3,
+		"v1.28.2":  KubeadmV1beta3,
+		"v1.31.1":  KubeadmV1beta4,
+		"v1.32.0":  KubeadmV1beta4,
+		"":         KubeadmV1beta2,
+		"v1.25.16": KubeadmV1beta3,
+	} {
+		if got := kubeadmAPIVersion(version); got != want {
+			t.Errorf("kubeadmAPIVersion(%s) = %s, want %s", version, got, want)
+		}
+	}
+}
+
+const v1beta4Configs = `apiVersion: kubeadm.k8s.io/v1beta4
+kind: ClusterConfiguration
+kubernetesVersion: v1.31.1
+apiServer:
+  extraArgs:
+  - name: audit-log-maxage
+    value: "7"
+  - name: feature-gates
+    value: EphemeralContainers=true
+etcd:
+  local:
+    dataDir: /var/lib/etcd
+    extraArgs:
+    - name: quota-backend-bytes
+      value: "8589934592"
+---
+apiVersion: kubeadm.k8s.io/v1beta4
+kind: JoinConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+  - name: max-pods
+    value: "200"
+timeouts:
+  discovery: 5m0s
+  controlPlaneComponentHealthCheck: 4m0s
+`
+
+func TestKubeadmConfigConversion(t *testing.T) {
+	c, err := DecodeCRDFromString(v1beta4Configs, ClusterConfiguration)
+	if err != nil {
+		t.Fatalf("DecodeCRDFromString(ClusterConfiguration) error = %v", err)
+	}
+	cluster := c.(*v1beta2.ClusterConfiguration)
+	wantArgs := map[string]string{"audit-log-maxage": "7", "feature-gates": "EphemeralContainers=true"}
+	if !reflect.DeepEqual(cluster.APIServer.ExtraArgs, wantArgs) || cluster.Etcd.Local.ExtraArgs["quota-backend-bytes"] != "8589934592" {
+		t.Errorf("decoded ClusterConfiguration = %+v, want the extraArgs as maps", cluster)
+	}
+	j, err := DecodeCRDFromString(v1beta4Configs, JoinConfiguration)
+	if err != nil {
+		t.Fatalf("DecodeCRDFromString(JoinConfiguration) error = %v", err)
+	}
+	join := j.(*v1beta2.JoinConfiguration)
+	if join.NodeRegistration.KubeletExtraArgs["max-pods"] != "200" || join.Discovery.Timeout == nil || join.Discovery.Timeout.Duration != 5*time.Minute {
+		t.Errorf("decoded JoinConfiguration = %+v, want max-pods and discovery timeout", join)
+	}
+
+	cluster.APIServer.TimeoutForControlPlane = &metav1.Duration{Duration: 4 * time.Minute}
+	init := &v1beta2.InitConfiguration{}
+	init.Kind = InitConfiguration
+	k := &KubeadmRuntime{KubeadmConfig: &KubeadmConfig{}}
+	k.KubernetesVersion = "v1.31.1"
+	out, err := k.marshalKubeadmConfigs(init, cluster, join)
+	if err != nil {
+		t.Fatalf("marshalKubeadmConfigs() error = %v", err)
+	}
+	for _, want := range []string{
+		"apiVersion: kubeadm.k8s.io/v1beta4\nkind: InitConfiguration\n",
+		"  extraArgs:\n  - name: audit-log-maxage\n    value: \"7\"\n  - name: feature-gates\n",
+		"timeouts:\n  controlPlaneComponentHealthCheck: 4m0s\n",
+		"timeouts:\n  controlPlaneComponentHealthCheck: 4m0s\n  discovery: 5m0s\n",
+		"  kubeletExtraArgs:\n  - name: max-pods\n    value: \"200\"\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("marshalKubeadmConfigs() = %s, want %q in it", out, want)
+		}
+	}
+	if strings.Contains(string(out), "timeoutForControlPlane") || strings.Contains(string(out), "useHyperKubeImage") {
+		t.Errorf("marshalKubeadmConfigs() = %s, want no v1beta2 only fields", out)
+	}
+
+	k.KubernetesVersion = "v1.19.8"
+	out, err = k.marshalKubeadmConfigs(cluster)
+	if err != nil {
+		t.Fatalf("marshalKubeadmConfigs() error = %v", err)
+	}
+	if !strings.Contains(string(out), "apiVersion: kubeadm.k8s.io/v1beta2\n") || !strings.Contains(string(out), "    audit-log-maxage: \"7\"\n") {
+		t.Errorf("marshalKubeadmConfigs() = %s, want v1beta2 with the extraArgs map", out)
+	}
+}
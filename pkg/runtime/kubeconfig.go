@@ -0,0 +1,144 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/utils"
+)
+
+// RewriteKubeconfigServer points all clusters of the kubeconfig file to server, like https://192.168.0.2:6443.
+func RewriteKubeconfigServer(path, server string) error {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig %s: %v", path, err)
+	}
+	for _, cluster := range config.Clusters {
+		cluster.Server = server
+	}
+	return writeKubeconfig(config, path)
+}
+
+// PointKubeconfigToHost points all clusters of the kubeconfig file to the apiserver on host, like 192.168.0.2 or
+// fd00::2, the server certificate is verified by domain, which is in the certificate of all masters, so that host can
+// be any of the masters or an EIP not in the certificate.
+func PointKubeconfigToHost(path, host, domain string) error {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig %s: %v", path, err)
+	}
+	for _, cluster := range config.Clusters {
+		cluster.Server = "https://" + net.JoinHostPort(host, "6443")
+		cluster.TLSServerName = domain
+	}
+	return writeKubeconfig(config, path)
+}
+
+// MergeKubeconfig merges the current context of kubeconfig file src into dst as context name, the cluster and
+// user are renamed after it, and it becomes the current context of dst, the other contexts of dst are kept.
+func MergeKubeconfig(src, dst, name string) error {
+	srcConfig, err := clientcmd.LoadFromFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig %s: %v", src, err)
+	}
+	context, ok := srcConfig.Contexts[srcConfig.CurrentContext]
+	if !ok {
+		return fmt.Errorf("current context %s not found in %s", srcConfig.CurrentContext, src)
+	}
+	cluster, ok := srcConfig.Clusters[context.Cluster]
+	if !ok {
+		return fmt.Errorf("cluster %s not found in %s", context.Cluster, src)
+	}
+	authInfo, ok := srcConfig.AuthInfos[context.AuthInfo]
+	if !ok {
+		return fmt.Errorf("user %s not found in %s", context.AuthInfo, src)
+	}
+
+	dstConfig := clientcmdapi.NewConfig()
+	if utils.IsFileExist(dst) {
+		if dstConfig, err = clientcmd.LoadFromFile(dst); err != nil {
+			return fmt.Errorf("failed to load kubeconfig %s: %v", dst, err)
+		}
+	}
+	user := kubeconfigUser(name)
+	dstConfig.Clusters[name] = cluster
+	dstConfig.AuthInfos[user] = authInfo
+	dstConfig.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: user, Namespace: context.Namespace}
+	dstConfig.CurrentContext = name
+	return writeKubeconfig(dstConfig, dst)
+}
+
+// KubeconfigHasContext returns whether context name is in kubeconfig file path.
+func KubeconfigHasContext(path, name string) bool {
+	if !utils.IsFileExist(path) {
+		return false
+	}
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return false
+	}
+	_, ok := config.Contexts[name]
+	return ok
+}
+
+// RemoveKubeconfigContext removes context name merged by MergeKubeconfig, and the file if no context is left.
+func RemoveKubeconfigContext(path, name string) error {
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig %s: %v", path, err)
+	}
+	delete(config.Contexts, name)
+	delete(config.Clusters, name)
+	delete(config.AuthInfos, kubeconfigUser(name))
+	if config.CurrentContext == name {
+		config.CurrentContext = ""
+	}
+	if len(config.Contexts) == 0 {
+		return os.Remove(path)
+	}
+	return writeKubeconfig(config, path)
+}
+
+// writeKubeconfig encodes the external version by sigs.k8s.io/yaml like kubectl does, the file is only readable by owner.
+func writeKubeconfig(config *clientcmdapi.Config, path string) error {
+	obj, err := clientcmdlatest.Scheme.ConvertToVersion(config, clientcmdlatest.ExternalVersion)
+	if err != nil {
+		return fmt.Errorf("failed to convert kubeconfig: %v", err)
+	}
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to encode kubeconfig: %v", err)
+	}
+	if err := utils.MkFileFullPathDir(path); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig %s: %v", path, err)
+	}
+	return nil
+}
+
+func kubeconfigUser(clusterName string) string {
+	return clusterName + "-admin"
+}
@@ -0,0 +1,111 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func writeTestKubeconfig(t *testing.T, path, name, server string) {
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = &clientcmdapi.Cluster{Server: server}
+	config.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: name}
+	config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	config.CurrentContext = name
+	if err := writeKubeconfig(config, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeAndRemoveKubeconfigContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "admin.conf")
+	dst := filepath.Join(dir, "config")
+	writeTestKubeconfig(t, src, "kubernetes-admin@kubernetes", "https://apiserver.cluster.local:6443")
+	writeTestKubeconfig(t, dst, "kind-kind", "https://127.0.0.1:36443")
+
+	if err := RewriteKubeconfigServer(src, "https://192.168.0.2:6443"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MergeKubeconfig(src, dst, "my-cluster"); err != nil {
+		t.Fatal(err)
+	}
+	config, err := clientcmd.LoadFromFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.CurrentContext != "my-cluster" {
+		t.Errorf("current context = %s, want my-cluster", config.CurrentContext)
+	}
+	if got := config.Clusters["my-cluster"].Server; got != "https://192.168.0.2:6443" {
+		t.Errorf("server = %s, want https://192.168.0.2:6443", got)
+	}
+	if got := config.AuthInfos["my-cluster-admin"].Token; got != "kubernetes-admin@kubernetes" {
+		t.Errorf("user token = %s, want the one of admin.conf", got)
+	}
+	if !KubeconfigHasContext(dst, "kind-kind") {
+		t.Errorf("context kind-kind is lost after merging")
+	}
+
+	if err := RemoveKubeconfigContext(dst, "my-cluster"); err != nil {
+		t.Fatal(err)
+	}
+	if KubeconfigHasContext(dst, "my-cluster") || !KubeconfigHasContext(dst, "kind-kind") {
+		t.Errorf("only context my-cluster should be removed")
+	}
+	if err := RemoveKubeconfigContext(dst, "kind-kind"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("kubeconfig without context should be removed, got %v", err)
+	}
+}
+
+func TestPointKubeconfigToHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.conf")
+	for host, want := range map[string]string{
+		"192.168.0.2": "https://192.168.0.2:6443",
+		"fd00::2":     "https://[fd00::2]:6443",
+	} {
+		writeTestKubeconfig(t, path, "kubernetes-admin@kubernetes", "https://apiserver.cluster.local:6443")
+		if err := PointKubeconfigToHost(path, host, "apiserver.cluster.local"); err != nil {
+			t.Fatal(err)
+		}
+		config, err := clientcmd.LoadFromFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, cluster := range config.Clusters {
+			if cluster.Server != want || cluster.TLSServerName != "apiserver.cluster.local" {
+				t.Errorf("cluster %s = %s, %s, want %s verified by apiserver.cluster.local", name, cluster.Server, cluster.TLSServerName, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"k8s.io/kubelet/config/v1beta1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+/*
+The KubeletConfiguration of hosts is patched by the kubeletOverrides of their roles and the kubelet of them:
+
+spec:
+  hosts:
+  - ips: [192.168.0.5]
+    roles: [node, gpu]
+    kubelet:
+      reservedCPUs: "0-1"
+  kubernetes:
+    kubeletOverrides:
+    - roles: [edge]
+      config:
+        maxPods: 50
+        evictionHard:
+          memory.available: 200Mi
+
+kubeadm v1.25+ patches the kubelet config of each host at init, join and upgrade by the kubeletconfiguration patch in
+the patches dir of its rootfs. For the older ones, the patch is merged into the kubelet config written by kubeadm and
+kubelet is restarted.
+*/
+
+const (
+	V1250 = "v1.25.0"
+	// KubeletPatchFile is a JSON merge patch of the kubeletconfiguration target of kubeadm.
+	KubeletPatchFile  = "kubeletconfiguration+merge.json"
+	KubeletConfigFile = "/var/lib/kubelet/config.yaml"
+
+	RemoteRemoveKubeletPatch = `mkdir -p %[1]s && rm -f %[1]s/%[2]s`
+	RemoteCatKubeletConfig   = `cat ` + KubeletConfigFile
+	RemoteWriteKubeletConfig = `printf '%%s\n' %s > ` + KubeletConfigFile + ` && systemctl restart kubelet`
+)
+
+// getKubeletOverride returns the patch of the KubeletConfiguration of host, merged from the kubeletOverrides of its
+// roles in order and the kubelet of it, nil if there is none.
+func (k *KubeadmRuntime) getKubeletOverride(host string) (map[string]interface{}, error) {
+	var patches [][]byte
+	for _, o := range k.Spec.Kubernetes.KubeletOverrides {
+		for _, role := range o.Roles {
+			if utils.InList(host, k.GetIPSByRole(role)) {
+				patches = append(patches, o.Config.Raw)
+				break
+			}
+		}
+	}
+	for _, h := range k.Spec.Hosts {
+		if h.Kubelet != nil && utils.InList(host, h.IPS) {
+			patches = append(patches, h.Kubelet.Raw)
+		}
+	}
+	var override map[string]interface{}
+	for _, p := range patches {
+		if len(p) == 0 {
+			continue
+		}
+		obj, err := decodeObject(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubelet override of %s: %v", host, err)
+		}
+		if override == nil {
+			override = map[string]interface{}{}
+		}
+		mergePatch(override, obj)
+	}
+	return override, nil
+}
+
+// mergePatch merges the JSON merge patch src into dst like mergeObject, but the nulls are kept in dst to remove the
+// fields of the kubelet config it patches.
+func mergePatch(dst, src map[string]interface{}) {
+	for key, v := range src {
+		srcMap, ok := v.(map[string]interface{})
+		if !ok {
+			dst[key] = v
+			continue
+		}
+		dstMap, ok := dst[key].(map[string]interface{})
+		if !ok {
+			dstMap = map[string]interface{}{}
+			dst[key] = dstMap
+		}
+		mergePatch(dstMap, srcMap)
+	}
+}
+
+func (k *KubeadmRuntime) hasKubeletOverrides() bool {
+	if len(k.Spec.Kubernetes.KubeletOverrides) != 0 {
+		return true
+	}
+	for _, h := range k.Spec.Hosts {
+		if h.Kubelet != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// usesKubeletPatches reports whether the kubelet overrides are applied by the kubeadm patches.
+func (k *KubeadmRuntime) usesKubeletPatches() bool {
+	return k.hasKubeletOverrides() && k.getKubeVersion() != "" && VersionCompare(k.getKubeVersion(), V1250)
+}
+
+// validateKubeletOverrides checks the kubelet overrides of all hosts are of the fields of KubeletConfiguration.
+func (k *KubeadmRuntime) validateKubeletOverrides() error {
+	for _, host := range append(k.getMasterIPList(), k.getNodesIPList()...) {
+		override, err := k.getKubeletOverride(host)
+		if err != nil {
+			return err
+		}
+		if override == nil {
+			continue
+		}
+		data, err := json.Marshal(override)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(data, &v1beta1.KubeletConfiguration{}); err != nil {
+			return fmt.Errorf("invalid kubelet override of %s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+// writeKubeletPatches writes the kubelet patch of each host to the patches dir of its rootfs before kubeadm init, join
+// or upgrade, the patch written before is removed from the hosts without override.
+func (k *KubeadmRuntime) writeKubeletPatches(hosts []string) error {
+	if !k.usesKubeletPatches() {
+		return nil
+	}
+	if err := k.validateKubeletOverrides(); err != nil {
+		return err
+	}
+	dir := filepath.Join(k.getRootfs(), KubeadmPatchesDir)
+	for _, host := range hosts {
+		override, err := k.getKubeletOverride(host)
+		if err != nil {
+			return err
+		}
+		cmd := fmt.Sprintf(RemoteRemoveKubeletPatch, dir, KubeletPatchFile)
+		if override != nil {
+			data, err := json.Marshal(override)
+			if err != nil {
+				return err
+			}
+			cmd = fmt.Sprintf(RemoteWriteImagePatch, dir, utils.ShellQuote(string(data)), KubeletPatchFile)
+		}
+		ssh, err := k.getHostSSHClient(host)
+		if err != nil {
+			return err
+		}
+		if err := ssh.CmdAsync(host, cmd); err != nil {
+			return fmt.Errorf("failed to write kubelet patch on %s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+// applyKubeletOverrides merges the kubelet override of each host into the kubelet config kubeadm writes and restarts
+// kubelet, after kubeadm init, join or upgrade older than v1.25, which do not patch the KubeletConfiguration.
+func (k *KubeadmRuntime) applyKubeletOverrides(hosts []string) error {
+	if !k.hasKubeletOverrides() || k.usesKubeletPatches() {
+		return nil
+	}
+	if err := k.validateKubeletOverrides(); err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		override, err := k.getKubeletOverride(host)
+		if err != nil {
+			return err
+		}
+		if override == nil {
+			continue
+		}
+		ssh, err := k.getHostSSHClient(host)
+		if err != nil {
+			return err
+		}
+		out, err := ssh.Cmd(host, RemoteCatKubeletConfig)
+		if err != nil {
+			return fmt.Errorf("failed to read kubelet config of %s: %v", host, err)
+		}
+		config := map[string]interface{}{}
+		if err := yaml.Unmarshal(out, &config); err != nil {
+			return fmt.Errorf("failed to decode kubelet config of %s: %v", host, err)
+		}
+		mergeObject(config, override)
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return err
+		}
+		logger.Info("apply kubelet override to %s", host)
+		if err := ssh.CmdAsync(host, fmt.Sprintf(RemoteWriteKubeletConfig, utils.ShellQuote(string(data)))); err != nil {
+			return fmt.Errorf("failed to write kubelet config of %s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+func (k *KubeadmRuntime) WriteKubeletPatchesOnMaster0() error {
+	return k.writeKubeletPatches([]string{k.getMaster0IP()})
+}
+
+func (k *KubeadmRuntime) ApplyKubeletOverridesOnMaster0() error {
+	return k.applyKubeletOverrides([]string{k.getMaster0IP()})
+}
@@ -0,0 +1,105 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+func newKubeletOverridesRuntime(version string) *KubeadmRuntime {
+	cluster := &v2.Cluster{}
+	cluster.Name = "my-cluster"
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+		{IPS: []string{"192.168.0.3"}, Roles: []string{"node", "edge"}},
+		{IPS: []string{"192.168.0.4"}, Roles: []string{"node", "gpu"},
+			Kubelet: &k8sruntime.RawExtension{Raw: []byte(`{"maxPods":80,"evictionHard":{"nodefs.available":null}}`)}},
+	}
+	cluster.Spec.Kubernetes.KubeletOverrides = []v2.KubeletOverride{
+		{Roles: []string{"node"}, Config: k8sruntime.RawExtension{Raw: []byte(`{"maxPods":200,"evictionHard":{"memory.available":"500Mi"}}`)}},
+		{Roles: []string{"edge"}, Config: k8sruntime.RawExtension{Raw: []byte(`{"maxPods":50}`)}},
+	}
+	k := &KubeadmRuntime{Cluster: cluster, KubeadmConfig: &KubeadmConfig{}, Config: &Config{APIServerDomain: DefaultAPIserverDomain}}
+	k.KubernetesVersion = version
+	return k
+}
+
+func TestKubeadmRuntime_getKubeletOverride(t *testing.T) {
+	k := newKubeletOverridesRuntime("v1.25.3")
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"192.168.0.2", `null`},
+		{"192.168.0.3", `{"evictionHard":{"memory.available":"500Mi"},"maxPods":50}`},
+		{"192.168.0.4", `{"evictionHard":{"memory.available":"500Mi","nodefs.available":null},"maxPods":80}`},
+	}
+	for _, tt := range tests {
+		override, err := k.getKubeletOverride(tt.host)
+		if err != nil {
+			t.Fatalf("getKubeletOverride(%s) error = %v", tt.host, err)
+		}
+		got, _ := json.Marshal(override)
+		if string(got) != tt.want {
+			t.Errorf("getKubeletOverride(%s) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+	if err := k.validateKubeletOverrides(); err != nil {
+		t.Errorf("validateKubeletOverrides() error = %v", err)
+	}
+	if !k.usesKubeletPatches() || newKubeletOverridesRuntime("v1.24.6").usesKubeletPatches() {
+		t.Errorf("usesKubeletPatches() should be true only for v1.25+")
+	}
+
+	k.Spec.Kubernetes.KubeletOverrides[1].Config.Raw = []byte(`{"maxPod":50}`)
+	if err := k.validateKubeletOverrides(); err == nil {
+		t.Errorf("validateKubeletOverrides() should fail on unknown field maxPod")
+	}
+}
+
+func TestRemoteWriteKubeletFiles(t *testing.T) {
+	data := "evictionHard:\n  memory.available: 5%\nclusterDomain: it's.$HOME.`id`\n"
+	dir := filepath.Join(t.TempDir(), "patches")
+	cmd := fmt.Sprintf(RemoteWriteImagePatch, dir, utils.ShellQuote(data), KubeletPatchFile)
+	if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+		t.Fatalf("RemoteWriteImagePatch error = %v: %s", err, out)
+	}
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	cmd = strings.Replace(fmt.Sprintf(RemoteWriteKubeletConfig, utils.ShellQuote(data)), KubeletConfigFile, config, 1)
+	cmd = strings.Replace(cmd, "systemctl restart kubelet", "true", 1)
+	if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+		t.Fatalf("RemoteWriteKubeletConfig error = %v: %s", err, out)
+	}
+	for _, file := range []string{filepath.Join(dir, KubeletPatchFile), config} {
+		written, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(string(written), "\n"); got != data {
+			t.Errorf("written %s = %q, want %q", file, got, data)
+		}
+	}
+}
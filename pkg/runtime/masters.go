@@ -27,6 +27,8 @@ import (
 	"github.com/alibaba/sealer/command"
 	"github.com/alibaba/sealer/ipvs"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/utils"
 )
 
@@ -39,8 +41,6 @@ const (
 )
 
 const (
-	RemoteAddEtcHosts       = "echo %s >> /etc/hosts"
-	RemoteUpdateEtcHosts    = `sed "s/%s/%s/g" < /etc/hosts > hosts && cp -f hosts /etc/hosts`
 	RemoteCopyKubeConfig    = `rm -rf .kube/config && mkdir -p /root/.kube && cp /etc/kubernetes/admin.conf /root/.kube/config`
 	RemoteReplaceKubeConfig = `grep -qF "apiserver.cluster.local" %s  && sed -i 's/apiserver.cluster.local/%s/' %s && sed -i 's/apiserver.cluster.local/%s/' %s`
 	RemoteJoinMasterConfig  = `echo "%s" > %s/kubeadm-join-config.yaml`
@@ -50,7 +50,7 @@ const (
 	InitMaser115Upper       = `kubeadm init --config=%s/kubeadm-config.yaml --upload-certs`
 	JoinMaster115Upper      = "kubeadm join --config=%s/kubeadm-join-config.yaml"
 	JoinNode115Upper        = "kubeadm join --config=%s/kubeadm-join-config.yaml"
-	RemoteCleanMasterOrNode = `if which kubeadm;then kubeadm reset -f %s;fi && \
+	RemoteCleanMasterOrNode = `if which kubeadm;then kubeadm reset -f %s%s;fi && \
 modprobe -r ipip  && lsmod && \
 rm -rf ~/.kube/ && rm -rf /etc/kubernetes/ && \
 rm -rf /etc/systemd/system/kubelet.service.d && rm -rf /etc/systemd/system/kubelet.service && \
@@ -114,23 +114,11 @@ const InitMaster CommandType = "initMaster"
 const JoinMaster CommandType = "joinMaster"
 const JoinNode CommandType = "joinNode"
 
-func getAPIServerHost(ipAddr, APIServer string) (host string) {
-	return fmt.Sprintf("%s %s", ipAddr, APIServer)
-}
-
+// JoinMasterCommands returns the commands joining master, the registry and apiserver domains must be pointed to
+// master0 in its /etc/hosts and the registry credentials written before, and the apiserver domain to itself after.
 func (k *KubeadmRuntime) JoinMasterCommands(master, joinCmd, hostname string) []string {
-	cmdAddRegistryHosts := fmt.Sprintf(RemoteAddEtcHosts, getRegistryHost(k.getRootfs(), k.getMaster0IP()))
 	certCMD := command.RemoteCerts(k.getCertSANS(), master, hostname, k.getSvcCIDR(), "")
-	cmdAddHosts := fmt.Sprintf(RemoteAddEtcHosts, getAPIServerHost(k.getMaster0IP(), k.getAPIServerDomain()))
-	joinCommands := []string{cmdAddRegistryHosts, certCMD, cmdAddHosts}
-	cf := GetRegistryConfig(k.getImageMountDir(), k.getMaster0IP())
-	if cf.Username != "" && cf.Password != "" {
-		joinCommands = append(joinCommands, fmt.Sprintf(DockerLoginCommand, cf.Domain+":"+cf.Port, cf.Username, cf.Password))
-	}
-	cmdUpdateHosts := fmt.Sprintf(RemoteUpdateEtcHosts, getAPIServerHost(k.getMaster0IP(), k.getAPIServerDomain()),
-		getAPIServerHost(utils.GetHostIP(master), k.getAPIServerDomain()))
-
-	return append(joinCommands, joinCmd, cmdUpdateHosts, RemoteCopyKubeConfig)
+	return []string{certCMD, joinCmd, RemoteCopyKubeConfig}
 }
 
 func (k *KubeadmRuntime) sendKubeConfigFile(hosts []string, kubeFile string) error {
@@ -166,10 +154,10 @@ func (k *KubeadmRuntime) sendFileToHosts(Hosts []string, src, dst string) error
 			defer wg.Done()
 			ssh, err := k.getHostSSHClient(node)
 			if err != nil {
-				errCh <- fmt.Errorf("send file failed %v", err)
+				errCh <- result.OnHost(node, fmt.Errorf("send file failed %v", err))
 			}
 			if err := ssh.Copy(node, src, dst); err != nil {
-				errCh <- fmt.Errorf("send file failed %v", err)
+				errCh <- result.OnHost(node, fmt.Errorf("send file failed %v", err))
 			}
 		}(node)
 	}
@@ -217,8 +205,12 @@ func (k *KubeadmRuntime) joinMasterConfig(masterIP string) ([]byte, error) {
 	// TODO Using join file instead template
 	k.setAPIServerEndpoint(fmt.Sprintf("%s:6443", k.getMaster0IP()))
 	k.setJoinAdvertiseAddress(masterIP)
-	k.setCgroupDriver(k.getCgroupDriverFromShell(masterIP))
-	return utils.MarshalConfigsYaml(k.JoinConfiguration, k.KubeletConfiguration)
+	driver, err := k.alignCgroupDriver(masterIP)
+	if err != nil {
+		return nil, err
+	}
+	k.setCgroupDriver(driver)
+	return k.marshalKubeadmConfigs(k.JoinConfiguration, k.KubeletConfiguration)
 }
 
 // sendJoinCPConfig send join CP nodes configuration
@@ -234,18 +226,18 @@ func (k *KubeadmRuntime) sendJoinCPConfig(joinMaster []string) error {
 			// set d.CriCGroupDriver on every nodes.
 			joinConfig, err := k.joinMasterConfig(master)
 			if err != nil {
-				errCh <- fmt.Errorf("get join %s config failed: %v", master, err)
+				errCh <- result.OnHost(master, fmt.Errorf("get join %s config failed: %v", master, err))
 				return
 			}
 
 			cmd := fmt.Sprintf(RemoteJoinMasterConfig, joinConfig, k.getRootfs())
 			ssh, err := k.getHostSSHClient(master)
 			if err != nil {
-				errCh <- fmt.Errorf("set join kubeadm config failed %s %s %v", master, cmd, err)
+				errCh <- result.OnHost(master, fmt.Errorf("set join kubeadm config failed %s %s %v", master, cmd, err))
 				return
 			}
 			if err := ssh.CmdAsync(master, cmd); err != nil {
-				errCh <- fmt.Errorf("set join kubeadm config failed %s %s %v", master, cmd, err)
+				errCh <- result.OnHost(master, fmt.Errorf("set join kubeadm config failed %s %s %v", master, cmd, err))
 			}
 		}(master)
 	}
@@ -299,6 +291,7 @@ func (k *KubeadmRuntime) Command(version string, name CommandType) (cmd string)
 		logger.Error("get kubeadm command failed %v", cmds)
 		return ""
 	}
+	v = k.getProxyEnvPrefix() + v + k.getPatchesFlag()
 
 	if utils.IsInContainer() {
 		return fmt.Sprintf("%s%s%s", v, vlogToStr(k.Vlog), " --ignore-preflight-errors=all")
@@ -326,12 +319,25 @@ func (k *KubeadmRuntime) joinMasters(masters []string) error {
 	if err := k.WaitSSHReady(6, masters...); err != nil {
 		return errors.Wrap(err, "join masters wait for ssh ready time out")
 	}
+	if err := k.configProxy(masters); err != nil {
+		return err
+	}
+	if err := k.deployCRIDockerd(masters); err != nil {
+		return err
+	}
 	if err := k.GetJoinTokenHashAndKey(); err != nil {
 		return err
 	}
+	defer k.deleteJoinToken()
 	if err := k.CopyStaticFiles(masters); err != nil {
 		return err
 	}
+	if err := k.writeImagePatches(masters); err != nil {
+		return err
+	}
+	if err := k.writeKubeletPatches(masters); err != nil {
+		return err
+	}
 	if err := k.SendJoinMasterKubeConfigs(masters, AdminConf, ControllerConf, SchedulerConf); err != nil {
 		return err
 	}
@@ -359,39 +365,63 @@ func (k *KubeadmRuntime) joinMasters(masters []string) error {
 			return fmt.Errorf("get remote hostname failed %s", master)
 		}
 		cmds := k.JoinMasterCommands(master, cmd, hostname)
-		ssh, err := k.getHostSSHClient(master)
+		ssh, end, err := k.startHostSpan("join master", master)
 		if err != nil {
 			return err
 		}
 
-		if err := ssh.CmdAsync(master, cmds...); err != nil {
+		err = k.applyEtcHosts(ssh, master, getRegistryEntry(k.getRootfs(), k.getMaster0IP()),
+			etchosts.Entry{IP: k.getMaster0IP(), Domain: k.getAPIServerDomain()})
+		if err == nil {
+			err = applyRegistryAuth(ssh, master, GetRegistryConfig(k.getImageMountDir(), k.getMaster0IP()))
+		}
+		if err == nil {
+			err = ssh.CmdAsync(master, cmds...)
+		}
+		if err == nil {
+			err = k.applyEtcHosts(ssh, master, etchosts.Entry{IP: utils.GetHostIP(master), Domain: k.getAPIServerDomain()})
+		}
+		end(err)
+		if err != nil {
 			return fmt.Errorf("exec command failed %s %v %v", master, cmds, err)
 		}
 
 		logger.Info("Succeeded in joining %s as master", master)
 	}
-	return nil
+	if err := k.applyKubeletOverrides(masters); err != nil {
+		return err
+	}
+	return k.syncAPIServerDomain(k.masterListWith(masters, nil))
 }
 
 func (k *KubeadmRuntime) deleteMasters(masters []string) error {
 	if len(masters) == 0 {
 		return nil
 	}
+	unreachable := k.checkReachable(masters)
+	cmds := make(map[string][]string)
 	var wg sync.WaitGroup
 	for _, master := range masters {
+		_, skipReset := unreachable[master]
+		if skipReset {
+			cmds[master] = k.resetHostCmds()
+		}
 		wg.Add(1)
-		go func(master string) {
+		go func(master string, skipReset bool) {
 			defer wg.Done()
 			logger.Info("Start to delete master %s", master)
-			if err := k.deleteMaster(master); err != nil {
+			if err := k.deleteMaster(master, skipReset); err != nil {
 				logger.Error("delete master %s failed %v", master, err)
 			}
 			logger.Info("Succeeded in deleting master %s", master)
-		}(master)
+		}(master, skipReset)
 	}
 	wg.Wait()
 
-	return nil
+	if err := k.syncAPIServerDomain(k.masterListWith(nil, masters)); err != nil {
+		return err
+	}
+	return k.recordOrphans(unreachable, cmds)
 }
 
 func SliceRemoveStr(ss []string, s string) (result []string) {
@@ -423,17 +453,20 @@ func (k *KubeadmRuntime) isHostName(master, host string) string {
 	return name
 }
 
-func (k *KubeadmRuntime) deleteMaster(master string) error {
-	ssh, err := k.getHostSSHClient(master)
+// deleteMaster resets master and deletes it from the cluster, the reset is skipped if master is unreachable.
+func (k *KubeadmRuntime) deleteMaster(master string, skipReset bool) (err error) {
+	ssh, end, err := k.startHostSpan("delete master", master)
 	if err != nil {
 		return fmt.Errorf("failed to delete master: %v", err)
 	}
+	defer func() {
+		end(err)
+	}()
 
-	if err := ssh.CmdAsync(master,
-		fmt.Sprintf(RemoteCleanMasterOrNode, vlogToStr(k.Vlog)),
-		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, k.getAPIServerDomain()),
-		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, getRegistryHost(k.getRootfs(), k.getMaster0IP()))); err != nil {
-		return err
+	if !skipReset {
+		if err := ssh.CmdAsync(master, k.resetHostCmds()...); err != nil {
+			return err
+		}
 	}
 
 	//remove master
@@ -469,35 +502,14 @@ func (k *KubeadmRuntime) deleteMaster(master string) error {
 	return nil
 }
 
+// GetJoinTokenHashAndKey re-uploads the control plane certs and creates a short-lived token for joining masters.
 func (k *KubeadmRuntime) GetJoinTokenHashAndKey() error {
-	cmd := fmt.Sprintf(`kubeadm init phase upload-certs --upload-certs -v %d`, k.Vlog)
-	/*
-		I0415 11:45:06.653868   14520 version.go:251] remote version is much newer: v1.21.0; falling back to: stable-1.16
-		[upload-certs] Storing the certificates in Secret "kubeadm-certs" in the "kube-system" Namespace
-		[upload-certs] Using certificate key:
-		8376c70aaaf285b764b3c1a588740728aff493d7c2239684e84a7367c6a437cf
-	*/
-	output := k.CmdToString(k.getMaster0IP(), cmd, "\r\n")
-	logger.Debug("[globals]decodeCertCmd: %s", output)
-	slice := strings.Split(output, "Using certificate key:")
-	if len(slice) != 2 {
-		return fmt.Errorf("get certifacate key failed %s", slice)
-	}
-	key := strings.Replace(slice[1], "\r\n", "", -1)
-	k.CertificateKey = strings.Replace(key, "\n", "", -1)
-	cmd = fmt.Sprintf("kubeadm token create --print-join-command -v %d", k.Vlog)
-
-	ssh, err := k.getHostSSHClient(k.getMaster0IP())
-	if err != nil {
-		return fmt.Errorf("failed to get join token hash and key: %v", err)
+	if err := k.uploadCertificateKey(); err != nil {
+		return err
 	}
-	out, err := ssh.Cmd(k.getMaster0IP(), cmd)
-	if err != nil {
-		return fmt.Errorf("create kubeadm join token failed %v", err)
+	if err := k.createJoinToken(JoinTokenTTL); err != nil {
+		return err
 	}
-
-	k.decodeMaster0Output(out)
-
 	logger.Info("join token: %s hash: %s certifacate key: %s", k.getJoinToken(), k.getTokenCaCertHash(), k.getCertificateKey())
 	return nil
 }
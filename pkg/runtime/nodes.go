@@ -19,12 +19,12 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/alibaba/sealer/utils"
-
 	"github.com/pkg/errors"
 
 	"github.com/alibaba/sealer/ipvs"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/pkg/result"
 )
 
 const (
@@ -32,7 +32,6 @@ const (
 	RemoteStaticPodMkdir            = "mkdir -p /etc/kubernetes/manifests"
 	RemoteJoinConfig                = `echo "%s" > %s/kubeadm-join-config.yaml`
 	LvscareDefaultStaticPodFileName = "/etc/kubernetes/manifests/kube-lvscare.yaml"
-	RemoteAddIPVSEtcHosts           = "echo %s %s >> /etc/hosts"
 	RemoteCheckRoute                = "seautil route --host %s"
 	RemoteAddRoute                  = "seautil route add --host %s --gateway %s"
 	RouteOK                         = "ok"
@@ -42,8 +41,12 @@ const (
 func (k *KubeadmRuntime) joinNodeConfig(nodeIP string) ([]byte, error) {
 	// TODO get join config from config file
 	k.setAPIServerEndpoint(fmt.Sprintf("%s:6443", k.getVIP()))
-	k.setCgroupDriver(k.getCgroupDriverFromShell(nodeIP))
-	return utils.MarshalConfigsYaml(k.JoinConfiguration, k.KubeletConfiguration)
+	driver, err := k.alignCgroupDriver(nodeIP)
+	if err != nil {
+		return nil, err
+	}
+	k.setCgroupDriver(driver)
+	return k.marshalKubeadmConfigs(k.JoinConfiguration, k.KubeletConfiguration)
 }
 
 func (k *KubeadmRuntime) joinNodes(nodes []string) error {
@@ -60,12 +63,22 @@ func (k *KubeadmRuntime) joinNodes(nodes []string) error {
 	if err := k.WaitSSHReady(6, nodes...); err != nil {
 		return errors.Wrap(err, "join nodes wait for ssh ready time out")
 	}
+	if err := k.configProxy(nodes); err != nil {
+		return err
+	}
+	if err := k.deployCRIDockerd(nodes); err != nil {
+		return err
+	}
 	if err := k.sendRegistryCert(nodes); err != nil {
 		return err
 	}
-	if err := k.GetJoinTokenHashAndKey(); err != nil {
+	if err := k.writeKubeletPatches(nodes); err != nil {
+		return err
+	}
+	if err := k.createJoinToken(JoinTokenTTL); err != nil {
 		return err
 	}
+	defer k.deleteJoinToken()
 	var masters string
 	var wg sync.WaitGroup
 	for _, master := range k.getMasterIPList() {
@@ -76,11 +89,9 @@ func (k *KubeadmRuntime) joinNodes(nodes []string) error {
 	k.setAPIServerEndpoint(fmt.Sprintf("%s:6443", k.getVIP()))
 	k.cleanJoinLocalAPIEndPoint()
 
-	addRegistryHostsAndLogin := fmt.Sprintf(RemoteAddEtcHosts, getRegistryHost(k.getRootfs(), k.getMaster0IP()))
+	// the apiserver domain of nodes resolves to the VIP which lvscare balances to masters.
+	hosts := []etchosts.Entry{getRegistryEntry(k.getRootfs(), k.getMaster0IP()), {IP: k.getVIP(), Domain: k.getAPIServerDomain()}}
 	cf := GetRegistryConfig(k.getImageMountDir(), k.getMaster0IP())
-	if cf.Username != "" && cf.Password != "" {
-		addRegistryHostsAndLogin = fmt.Sprintf("%s && %s", addRegistryHostsAndLogin, fmt.Sprintf(DockerLoginCommand, cf.Domain+":"+cf.Port, cf.Username, cf.Password))
-	}
 	for _, node := range nodes {
 		wg.Add(1)
 		go func(node string) {
@@ -90,21 +101,28 @@ func (k *KubeadmRuntime) joinNodes(nodes []string) error {
 			// send join node config, get cgroup driver on every join nodes
 			joinConfig, err := k.joinNodeConfig(node)
 			if err != nil {
-				errCh <- fmt.Errorf("failed to join node %s %v", node, err)
+				errCh <- result.OnHost(node, fmt.Errorf("failed to join node %s %v", node, err))
 				return
 			}
 			cmdWriteJoinConfig := fmt.Sprintf(RemoteJoinConfig, string(joinConfig), k.getRootfs())
-			cmdHosts := fmt.Sprintf(RemoteAddIPVSEtcHosts, k.getVIP(), k.getAPIServerDomain())
 			cmd := k.Command(k.getKubeVersion(), JoinNode)
 			yaml := ipvs.LvsStaticPodYaml(k.getVIP(), k.getMasterIPList(), "")
 			lvscareStaticCmd := fmt.Sprintf(LvscareStaticPodCmd, yaml, LvscareDefaultStaticPodFileName)
-			ssh, err := k.getHostSSHClient(node)
+			ssh, end, err := k.startHostSpan("join node", node)
 			if err != nil {
-				errCh <- fmt.Errorf("failed to join node %s %v", node, err)
+				errCh <- result.OnHost(node, fmt.Errorf("failed to join node %s %v", node, err))
 				return
 			}
-			if err := ssh.CmdAsync(node, addRegistryHostsAndLogin, cmdWriteJoinConfig, cmdHosts, ipvsCmd, cmd, RemoteStaticPodMkdir, lvscareStaticCmd); err != nil {
-				errCh <- fmt.Errorf("failed to join node %s %v", node, err)
+			err = k.applyEtcHosts(ssh, node, hosts...)
+			if err == nil {
+				err = applyRegistryAuth(ssh, node, cf)
+			}
+			if err == nil {
+				err = ssh.CmdAsync(node, cmdWriteJoinConfig, ipvsCmd, cmd, RemoteStaticPodMkdir, lvscareStaticCmd)
+			}
+			end(err)
+			if err != nil {
+				errCh <- result.OnHost(node, fmt.Errorf("failed to join node %s %v", node, err))
 			}
 
 			logger.Info("Succeeded in joining %s as worker", node)
@@ -112,7 +130,10 @@ func (k *KubeadmRuntime) joinNodes(nodes []string) error {
 	}
 
 	wg.Wait()
-	return ReadChanError(errCh)
+	if err := ReadChanError(errCh); err != nil {
+		return err
+	}
+	return k.applyKubeletOverrides(nodes)
 }
 
 func (k *KubeadmRuntime) deleteNodes(nodes []string) error {
@@ -122,33 +143,46 @@ func (k *KubeadmRuntime) deleteNodes(nodes []string) error {
 	if len(nodes) == 0 {
 		return nil
 	}
+	unreachable := k.checkReachable(nodes)
+	cmds := make(map[string][]string)
 	var wg sync.WaitGroup
 	for _, node := range nodes {
+		_, skipReset := unreachable[node]
+		if skipReset {
+			cmds[node] = k.resetHostCmds()
+		}
 		wg.Add(1)
-		go func(node string) {
+		go func(node string, skipReset bool) {
 			defer wg.Done()
 			logger.Info("Start to delete worker %s", node)
-			if err := k.deleteNode(node); err != nil {
-				errCh <- fmt.Errorf("delete node %s failed %v", node, err)
+			if err := k.deleteNode(node, skipReset); err != nil {
+				errCh <- result.OnHost(node, fmt.Errorf("delete node %s failed %v", node, err))
 			}
 			logger.Info("Succeeded in deleting worker %s", node)
-		}(node)
+		}(node, skipReset)
 	}
 	wg.Wait()
 
+	if err := k.recordOrphans(unreachable, cmds); err != nil {
+		return err
+	}
 	return ReadChanError(errCh)
 }
 
-func (k *KubeadmRuntime) deleteNode(node string) error {
-	ssh, err := k.getHostSSHClient(node)
+// deleteNode resets node and deletes it from the cluster, the reset is skipped if node is unreachable.
+func (k *KubeadmRuntime) deleteNode(node string, skipReset bool) (err error) {
+	ssh, end, err := k.startHostSpan("delete node", node)
 	if err != nil {
 		return fmt.Errorf("failed to delete node: %v", err)
 	}
+	defer func() {
+		end(err)
+	}()
 
-	if err := ssh.CmdAsync(node, fmt.Sprintf(RemoteCleanMasterOrNode, vlogToStr(k.Vlog)),
-		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, k.getAPIServerDomain()),
-		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, getRegistryHost(k.getRootfs(), k.getMaster0IP()))); err != nil {
-		return err
+	if !skipReset {
+		if err := ssh.CmdAsync(node, k.resetHostCmds()...); err != nil {
+			return err
+		}
 	}
 
 	//remove node
@@ -0,0 +1,273 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imdario/mergo"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/systemd"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const orphansFileSuffix = ".orphans.json"
+
+var skipUnreachable bool
+
+// SetSkipUnreachable makes deleting hosts or the cluster skip the hosts unreachable by ssh instead of failing on them,
+// the skipped hosts are recorded as orphans which "sealer cleanup-orphans" cleans up once they are back.
+func SetSkipUnreachable(skip bool) {
+	skipUnreachable = skip
+}
+
+// Orphan is a host skipped for being unreachable, with the commands to clean it up.
+type Orphan struct {
+	Host string `json:"host"`
+	// SSH is kept, as the host is removed from the Clusterfile, or the Clusterfile is removed with the cluster.
+	SSH       v1.SSH    `json:"ssh"`
+	Reason    string    `json:"reason"`
+	SkippedAt time.Time `json:"skippedAt"`
+	Commands  []string  `json:"commands"`
+}
+
+// Orphans are the hosts skipped of a cluster.
+type Orphans struct {
+	ClusterName string   `json:"clusterName"`
+	Hosts       []Orphan `json:"hosts"`
+}
+
+// LoadOrphans returns the orphans of cluster, which are empty if none is recorded.
+func LoadOrphans(clusterName string) (*Orphans, error) {
+	orphans := &Orphans{ClusterName: clusterName}
+	file := common.GetClusterOrphansFile(clusterName)
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if os.IsNotExist(err) {
+		return orphans, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, orphans); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", file, err)
+	}
+	return orphans, nil
+}
+
+// OrphanClusters returns the names of the clusters which have orphans.
+func OrphanClusters() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(common.GetHomeDir(), ".sealer", "*"+orphansFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(f), orphansFileSuffix))
+	}
+	return names, nil
+}
+
+// Save writes the orphans, the file is removed once there is none left.
+func (o *Orphans) Save() error {
+	file := common.GetClusterOrphansFile(o.ClusterName)
+	if len(o.Hosts) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), common.FileMode0755); err != nil {
+		return err
+	}
+	// the ssh passwords of hosts are in it.
+	return utils.AtomicWriteFile(file, data, 0600)
+}
+
+// Get returns the orphan of host, nil if it is not skipped.
+func (o *Orphans) Get(host string) *Orphan {
+	for i := range o.Hosts {
+		if o.Hosts[i].Host == host {
+			return &o.Hosts[i]
+		}
+	}
+	return nil
+}
+
+// add records host is skipped for reason, cmds are appended to the ones recorded before, so a host skipped by
+// deleting the node and then by deleting the cluster runs both of them.
+func (o *Orphans) add(host string, sshConfig v1.SSH, reason string, cmds ...string) {
+	if orphan := o.Get(host); orphan != nil {
+		orphan.SSH = sshConfig
+		orphan.Reason = reason
+		orphan.SkippedAt = time.Now()
+		orphan.Commands = append(orphan.Commands, cmds...)
+		return
+	}
+	o.Hosts = append(o.Hosts, Orphan{Host: host, SSH: sshConfig, Reason: reason, SkippedAt: time.Now(), Commands: cmds})
+}
+
+func (o *Orphans) remove(host string) {
+	for i := range o.Hosts {
+		if o.Hosts[i].Host == host {
+			o.Hosts = append(o.Hosts[:i], o.Hosts[i+1:]...)
+			return
+		}
+	}
+}
+
+// CleanupOrphans runs the recorded commands on the orphans of cluster which are reachable again, and returns the
+// cleaned hosts. They are removed from the record, the ones still unreachable or failed are kept to retry.
+func CleanupOrphans(clusterName string) ([]string, error) {
+	orphans, err := LoadOrphans(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cleaned []string
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		errCh   = make(chan error, len(orphans.Hosts))
+	)
+	for _, o := range orphans.Hosts {
+		wg.Add(1)
+		go func(o Orphan) {
+			defer wg.Done()
+			client := ssh.NewSSHClient(&o.SSH)
+			if err := client.Ping(o.Host); err != nil {
+				errCh <- result.OnHost(o.Host, fmt.Errorf("host %s is still unreachable: %v", o.Host, err))
+				return
+			}
+			logger.Info("start to clean up orphan host %s", o.Host)
+			if err := client.CmdAsync(o.Host, o.Commands...); err != nil {
+				errCh <- result.OnHost(o.Host, fmt.Errorf("failed to clean up %s: %v", o.Host, err))
+				return
+			}
+			mu.Lock()
+			cleaned = append(cleaned, o.Host)
+			mu.Unlock()
+		}(o)
+	}
+	wg.Wait()
+	close(errCh)
+
+	sort.Strings(cleaned)
+	for _, host := range cleaned {
+		orphans.remove(host)
+	}
+	if err := orphans.Save(); err != nil {
+		return cleaned, err
+	}
+	return cleaned, ReadChanError(errCh)
+}
+
+// checkReachable pings hosts by ssh in parallel if skipUnreachable is set, and returns the unreachable ones with
+// the errors of them.
+func (k *KubeadmRuntime) checkReachable(hosts []string) map[string]error {
+	unreachable := make(map[string]error)
+	if !skipUnreachable {
+		return unreachable
+	}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			client, err := k.getHostSSHClient(host)
+			if err == nil {
+				err = client.Ping(host)
+			}
+			if err != nil {
+				logger.Warn("host %s is unreachable, skip it: %v", host, err)
+				mu.Lock()
+				unreachable[host] = err
+				mu.Unlock()
+			}
+		}(host)
+	}
+	wg.Wait()
+	return unreachable
+}
+
+// recordOrphans records the unreachable hosts with the commands to clean them up, cmds are keyed by host.
+func (k *KubeadmRuntime) recordOrphans(unreachable map[string]error, cmds map[string][]string) error {
+	if len(unreachable) == 0 {
+		return nil
+	}
+	orphans, err := LoadOrphans(k.getClusterName())
+	if err != nil {
+		return err
+	}
+	var hosts []string
+	for host := range unreachable {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		orphans.add(host, k.getHostSSH(host), unreachable[host].Error(), cmds[host]...)
+	}
+	if err := orphans.Save(); err != nil {
+		return fmt.Errorf("failed to record the unreachable hosts %s: %v", strings.Join(hosts, ","), err)
+	}
+	logger.Warn("the unreachable hosts %s are skipped, run \"sealer cleanup-orphans -c %s\" to clean them up once they are back",
+		strings.Join(hosts, ","), k.getClusterName())
+	return nil
+}
+
+// getHostSSH returns the ssh config of host merged with the one of cluster, the same as GetHostSSHClient uses.
+func (k *KubeadmRuntime) getHostSSH(host string) v1.SSH {
+	config := k.Cluster.Spec.SSH
+	for _, h := range k.Cluster.Spec.Hosts {
+		if utils.InList(host, h.IPS) {
+			config = h.SSH
+			if err := mergo.Merge(&config, &k.Cluster.Spec.SSH); err != nil {
+				logger.Warn("failed to merge the ssh config of %s: %v", host, err)
+			}
+			break
+		}
+	}
+	return config
+}
+
+// resetHostCmds resets kubernetes on a master or node and removes the domains sealer added to its /etc/hosts,
+// both the managed block and the lines appended by the sealer before.
+func (k *KubeadmRuntime) resetHostCmds() []string {
+	return []string{fmt.Sprintf(RemoteCleanMasterOrNode, k.getResetCRISocketFlag(), vlogToStr(k.Vlog)),
+		RemoteRemoveCRIDockerd,
+		systemd.RemoteRemoveUnits,
+		etchosts.RemoteRemoveBlock,
+		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, k.getAPIServerDomain()),
+		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, getRegistryHost(k.getRootfs(), k.getMaster0IP()))}
+}
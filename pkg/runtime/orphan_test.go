@@ -0,0 +1,55 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestOrphans(t *testing.T) {
+	o := &Orphans{ClusterName: "my-cluster"}
+	o.add("192.168.0.2", v1.SSH{User: "root"}, "timeout", "reset")
+	o.add("192.168.0.3", v1.SSH{User: "root"}, "timeout", "reset")
+	// skipped by deleting the node, then by pruning the cluster.
+	o.add("192.168.0.2", v1.SSH{User: "admin"}, "refused", "prune")
+
+	if len(o.Hosts) != 2 {
+		t.Fatalf("got %d orphans, want 2", len(o.Hosts))
+	}
+	got := o.Get("192.168.0.2")
+	if got == nil {
+		t.Fatalf("orphan 192.168.0.2 not found")
+	}
+	if !reflect.DeepEqual(got.Commands, []string{"reset", "prune"}) || got.SSH.User != "admin" || got.Reason != "refused" {
+		t.Errorf("got orphan %+v, want the commands appended and the ssh and reason updated", got)
+	}
+
+	o.remove("192.168.0.2")
+	if o.Get("192.168.0.2") != nil || o.Get("192.168.0.3") == nil {
+		t.Errorf("got orphans %+v after removing 192.168.0.2", o.Hosts)
+	}
+}
+
+func TestRemoveHosts(t *testing.T) {
+	unreachable := map[string]error{"192.168.0.3": errors.New("timeout")}
+	got := removeHosts([]string{"192.168.0.2", "192.168.0.3", "192.168.0.4"}, unreachable)
+	if want := []string{"192.168.0.2", "192.168.0.4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removeHosts() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+/*
+kubeadm patches in the cluster rootfs, or in the Clusterfile using the Config kind:
+
+apiVersion: sealer.aliyun.com/v1alpha1
+kind: Config
+metadata:
+  name: apiserver-patch
+spec:
+  path: patches/kube-apiserver+strategic.yaml
+  data: |
+    spec:
+      containers:
+      - name: kube-apiserver
+        resources:
+          requests:
+            cpu: 500m
+
+The patches dir is distributed to all hosts with the rootfs, and passed to kubeadm init, join and upgrade.
+*/
+
+const (
+	KubeadmPatchesDir       = "patches"
+	PatchesFlag             = " --patches %s"
+	ExperimentalPatchesFlag = " --experimental-patches %s"
+	V1190                   = "v1.19.0"
+	V1220                   = "v1.22.0"
+)
+
+// getPatchesFlag returns the kubeadm flag pointing to the patches dir in the rootfs, empty if there are no patches.
+func (k *KubeadmRuntime) getPatchesFlag() string {
+	return k.patchesFlag(filepath.Join(k.getImageMountDir(), KubeadmPatchesDir))
+}
+
+// patchesFlag returns the patches flag of the patches dir of the cloud image, which is distributed to the rootfs.
+func (k *KubeadmRuntime) patchesFlag(imagePatchesDir string) string {
+	files, err := ioutil.ReadDir(imagePatchesDir)
+	if (err != nil || len(files) == 0) && len(k.getImagePatches()) == 0 && !k.usesKubeletPatches() {
+		return ""
+	}
+	version := k.getKubeVersion()
+	if !VersionCompare(version, V1190) {
+		logger.Warn("kubeadm patches are not supported by kubernetes %s, skip them", version)
+		return ""
+	}
+	patchesDir := filepath.Join(k.getRootfs(), KubeadmPatchesDir)
+	if !VersionCompare(version, V1220) {
+		return fmt.Sprintf(ExperimentalPatchesFlag, patchesDir)
+	}
+	return fmt.Sprintf(PatchesFlag, patchesDir)
+}
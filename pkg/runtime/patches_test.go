@@ -0,0 +1,71 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+)
+
+func TestKubeadmRuntime_patchesFlag(t *testing.T) {
+	withPatches := filepath.Join(t.TempDir(), KubeadmPatchesDir)
+	if err := os.MkdirAll(withPatches, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(withPatches, "kube-apiserver+strategic.yaml"), []byte("spec: {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	emptyPatches := filepath.Join(t.TempDir(), KubeadmPatchesDir)
+	if err := os.MkdirAll(emptyPatches, 0755); err != nil {
+		t.Fatal(err)
+	}
+	noPatches := filepath.Join(t.TempDir(), KubeadmPatchesDir)
+	rootfsPatches := filepath.Join(common.DefaultTheClusterRootfsDir("my-cluster"), KubeadmPatchesDir)
+
+	tests := []struct {
+		name       string
+		version    string
+		patchesDir string
+		apiServer  string
+		kubelet    bool
+		want       string
+	}{
+		{"patches", "v1.22.8", withPatches, "", false, " --patches " + rootfsPatches},
+		{"experimental patches", "v1.19.8", withPatches, "", false, " --experimental-patches " + rootfsPatches},
+		{"unsupported patches", "v1.18.2", withPatches, "", false, ""},
+		{"no patches dir", "v1.22.8", noPatches, "", false, ""},
+		{"empty patches dir", "v1.22.8", emptyPatches, "", false, ""},
+		{"image patches", "v1.22.8", noPatches, "registry.example.com/k8s/kube-apiserver:v1.22.8-hotfix.1", false, " --patches " + rootfsPatches},
+		{"kubelet patches", "v1.25.3", noPatches, "", true, " --patches " + rootfsPatches},
+		{"kubelet config", "v1.24.3", noPatches, "", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newKubeletOverridesRuntime(tt.version)
+			if !tt.kubelet {
+				k.Spec.Kubernetes.KubeletOverrides = nil
+				k.Spec.Hosts[2].Kubelet = nil
+			}
+			k.Spec.Kubernetes.APIServer.Image = tt.apiServer
+			if got := k.patchesFlag(tt.patchesDir); got != tt.want {
+				t.Errorf("patchesFlag() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
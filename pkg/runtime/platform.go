@@ -0,0 +1,90 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/pkg/facts"
+	"github.com/alibaba/sealer/pkg/sign"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+// GetClusterPlatform returns the platform of hosts, all hosts must have the same platform,
+// since the rootfs of one platform is distributed to all of them.
+func GetClusterPlatform(cluster *v2.Cluster, hosts []string) (v1.Platform, error) {
+	all, err := facts.GetAll(cluster, hosts)
+	if err != nil {
+		return v1.Platform{}, err
+	}
+	var (
+		platforms = map[string][]string{}
+		result    v1.Platform
+	)
+	for _, h := range all {
+		p, err := platform.FromMachine(h.Arch)
+		if err != nil {
+			return v1.Platform{}, fmt.Errorf("[%s] %v", h.IP, err)
+		}
+		platforms[platform.Format(p)] = append(platforms[platform.Format(p)], h.IP)
+		result = p
+	}
+
+	if len(platforms) > 1 {
+		var desc []string
+		for p, ips := range platforms {
+			desc = append(desc, fmt.Sprintf("%s: %s", p, strings.Join(ips, ",")))
+		}
+		sort.Strings(desc)
+		return v1.Platform{}, fmt.Errorf("hosts of different platforms are not supported, %s", strings.Join(desc, "; "))
+	}
+	if len(platforms) == 0 {
+		return platform.Default(), nil
+	}
+	return result, nil
+}
+
+// GetClusterImage returns the image of cluster, a multi-platform image is
+// resolved to the image of the platform of cluster hosts. An image not verified
+// by the trusted keys is refused.
+func GetClusterImage(imageStore store.ImageStore, cluster *v2.Cluster) (*v1.Image, error) {
+	image, err := imageStore.GetByName(cluster.Spec.Image)
+	if err != nil {
+		return nil, err
+	}
+	if err = sign.CheckVerified(image); err != nil {
+		return nil, err
+	}
+	if !platform.IsIndex(image) {
+		return image, nil
+	}
+	p, err := GetClusterPlatform(cluster, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...))
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := platform.Resolve(imageStore, image, p)
+	if err != nil {
+		return nil, err
+	}
+	if err = sign.CheckVerified(resolved); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
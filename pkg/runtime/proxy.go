@@ -0,0 +1,154 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/pkg/result"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	EnvHTTPProxy  = "HTTP_PROXY"
+	EnvHTTPSProxy = "HTTPS_PROXY"
+	EnvNoProxy    = "NO_PROXY"
+	ProxyDropIn   = "/etc/systemd/system/%s.service.d/http-proxy.conf"
+	// RemoteConfigProxy writes the drop-in of a container runtime service if it is installed,
+	// and restarts the service only if the drop-in changed.
+	RemoteConfigProxy = `if systemctl cat %[1]s >/dev/null 2>&1; then f=%[2]s; mkdir -p $(dirname $f) && ` +
+		`echo '%[3]s' > $f.tmp && if cmp -s $f.tmp $f; then rm -f $f.tmp; else mv -f $f.tmp $f && systemctl daemon-reload && ` +
+		`(! systemctl is-active -q %[1]s || systemctl restart %[1]s); fi; fi`
+)
+
+// containerRuntimeServices pull images through the proxy.
+var containerRuntimeServices = []string{"docker", "containerd"}
+
+func proxyEnabled(spec v2.ProxySpec) bool {
+	return spec.HTTPProxy != "" || spec.HTTPSProxy != ""
+}
+
+// GetNoProxy returns the addresses not to be proxied: localhost, the apiserver and registry domains, all host IPs
+// and the user specified ones, extra is appended like service and pod CIDRs.
+func GetNoProxy(cluster *v2.Cluster, extra ...string) string {
+	noProxy := []string{"localhost", "127.0.0.1", DefaultAPIserverDomain, SeaHub, DefaultVIP}
+	noProxy = append(noProxy, cluster.GetMasterIPList()...)
+	noProxy = append(noProxy, cluster.GetNodeIPList()...)
+	noProxy = append(noProxy, extra...)
+	noProxy = append(noProxy, cluster.Spec.Proxy.NoProxy...)
+	var res []string
+	for _, s := range utils.RemoveDuplicate(noProxy) {
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return strings.Join(res, ",")
+}
+
+// SetProxyEnv sets the proxy of Clusterfile to the environment of sealer, so that its outbound HTTP calls use it.
+// Call it before any HTTP call, because net/http reads the environment only once.
+func SetProxyEnv(cluster *v2.Cluster) error {
+	spec := cluster.Spec.Proxy
+	if !proxyEnabled(spec) {
+		return nil
+	}
+	for k, v := range proxyEnv(spec, GetNoProxy(cluster)) {
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+		if err := os.Setenv(strings.ToLower(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func proxyEnv(spec v2.ProxySpec, noProxy string) map[string]string {
+	env := map[string]string{EnvNoProxy: noProxy}
+	if spec.HTTPProxy != "" {
+		env[EnvHTTPProxy] = spec.HTTPProxy
+	}
+	if spec.HTTPSProxy != "" {
+		env[EnvHTTPSProxy] = spec.HTTPSProxy
+	}
+	return env
+}
+
+// getNoProxy is the NO_PROXY of the cluster, with the service and pod CIDRs in kubeadm config.
+func (k *KubeadmRuntime) getNoProxy(extra ...string) string {
+	cidrs := strings.Split(k.getSvcCIDR(), ",")
+	cidrs = append(cidrs, strings.Split(k.ClusterConfiguration.Networking.PodSubnet, ",")...)
+	return GetNoProxy(k.Cluster, append(append(cidrs, k.getAPIServerDomain(), "."+k.getDNSDomain(), ".svc"), extra...)...)
+}
+
+// getProxyEnvPrefix returns the proxy env assignments to prefix a remote command, empty if no proxy.
+func (k *KubeadmRuntime) getProxyEnvPrefix() string {
+	if !proxyEnabled(k.Spec.Proxy) {
+		return ""
+	}
+	var sb strings.Builder
+	for _, key := range []string{EnvHTTPProxy, EnvHTTPSProxy, EnvNoProxy} {
+		if v, ok := proxyEnv(k.Spec.Proxy, k.getNoProxy())[key]; ok {
+			sb.WriteString(fmt.Sprintf("%s=%s ", key, v))
+		}
+	}
+	return sb.String()
+}
+
+// configProxy sets the proxy to the container runtime services on hosts, NO_PROXY of each host contains its hostname.
+func (k *KubeadmRuntime) configProxy(hosts []string) error {
+	if !proxyEnabled(k.Spec.Proxy) {
+		return nil
+	}
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			ssh, err := k.getHostSSHClient(ip)
+			if err != nil {
+				errCh <- result.OnHost(ip, err)
+				return
+			}
+			env := proxyEnv(k.Spec.Proxy, k.getNoProxy(k.getRemoteHostName(ip)))
+			var sb strings.Builder
+			sb.WriteString("[Service]\n")
+			for _, key := range []string{EnvHTTPProxy, EnvHTTPSProxy, EnvNoProxy} {
+				if v, ok := env[key]; ok {
+					sb.WriteString(fmt.Sprintf("Environment=\"%s=%s\"\n", key, v))
+				}
+			}
+			for _, s := range containerRuntimeServices {
+				cmd := fmt.Sprintf(RemoteConfigProxy, s, fmt.Sprintf(ProxyDropIn, s), sb.String())
+				if err := ssh.CmdAsync(ip, cmd); err != nil {
+					errCh <- result.OnHost(ip, fmt.Errorf("failed to config proxy of %s on %s: %v", s, ip, err))
+					return
+				}
+			}
+		}(host)
+	}
+	wg.Wait()
+	return ReadChanError(errCh)
+}
+
+func (k *KubeadmRuntime) ConfigProxyOnMaster0() error {
+	return k.configProxy([]string{k.getMaster0IP()})
+}
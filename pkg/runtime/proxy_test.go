@@ -0,0 +1,48 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestGetNoProxy(t *testing.T) {
+	cluster := &v2.Cluster{}
+	cluster.Spec.Hosts = []v2.Host{
+		{IPS: []string{"192.168.0.2", "192.168.0.3"}, Roles: []string{"master"}},
+		{IPS: []string{"192.168.0.4"}, Roles: []string{"node"}},
+	}
+	base := "localhost,127.0.0.1,apiserver.cluster.local,sea.hub,10.103.97.2,192.168.0.2,192.168.0.3,192.168.0.4"
+	tests := []struct {
+		name    string
+		noProxy []string
+		extra   []string
+		want    string
+	}{
+		{"hosts", nil, nil, base},
+		{"extra before user specified", []string{".example.com"}, []string{"10.96.0.0/22", "100.64.0.0/10"}, base + ",10.96.0.0/22,100.64.0.0/10,.example.com"},
+		{"duplicated and empty", []string{"192.168.0.2", "", "sea.hub", ".example.com"}, []string{"", "10.96.0.0/22", "10.96.0.0/22"}, base + ",10.96.0.0/22,.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster.Spec.Proxy = v2.ProxySpec{HTTPProxy: "http://proxy.example.com:3128", NoProxy: tt.noProxy}
+			if got := GetNoProxy(cluster, tt.extra...); got != tt.want {
+				t.Errorf("GetNoProxy() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// the cleanup levels of deleting a cluster, each one removes what the former one keeps.
+const (
+	// CleanupKeepData resets kubernetes and removes rootfs, the registry data dir and the container runtime are kept
+	CleanupKeepData = "keep-data"
+	// CleanupPruneSealer removes all the sealer dirs of cluster, including the registry data dir
+	CleanupPruneSealer = "prune-sealer"
+	// CleanupPruneAll removes the state of container runtime and kubelet as well, like images and volumes
+	CleanupPruneAll = "prune-all"
+)
+
+const (
+	RemotePruneSealer = `rm -rf %s /var/lib/sealer/tmp /usr/bin/seautil /etc/kubernetes/manifests/kube-sealyun-lvscare* && \
+(rmdir /var/lib/sealer/data /var/lib/sealer 2>/dev/null || true)`
+	RemotePruneRuntime = `(systemctl stop kubelet docker containerd 2>/dev/null || true) && \
+(grep -oE " /(var/lib/(docker|containerd|kubelet)|run/containerd)/[^ ]*" /proc/mounts | sort -r | xargs -r umount -l || true) && \
+rm -rf /var/lib/docker /var/lib/containerd /var/lib/kubelet /var/lib/cni /var/log/pods /var/log/containers /run/containerd`
+	// RemoteDiskUsage prints the available space of / and the size of the dirs left, like:
+	// 35G
+	// 1.2G	/var/lib/docker
+	RemoteDiskUsage = `df -h --output=avail / | tail -1 && (du -sh %s 2>/dev/null || true)`
+)
+
+var cleanupLevel = CleanupKeepData
+
+// SetCleanupLevel sets the level of cleanup when the cluster is deleted, CleanupKeepData by default.
+func SetCleanupLevel(level string) error {
+	switch level {
+	case CleanupKeepData, CleanupPruneSealer, CleanupPruneAll:
+		cleanupLevel = level
+		return nil
+	}
+	return fmt.Errorf("invalid cleanup level %s, must be one of %s, %s and %s", level, CleanupKeepData, CleanupPruneSealer, CleanupPruneAll)
+}
+
+// DiskUsage is the disk usage of a host after the cluster is deleted.
+type DiskUsage struct {
+	Host string
+	// Available is the available space of /
+	Available string
+	// Left are the sizes of the sealer, kubernetes and container runtime dirs left, like: 1.2G /var/lib/docker
+	Left []string
+}
+
+// Prune removes the data dirs of cluster on all hosts by the cleanup level, after they are reset and their rootfs
+// is removed, and returns the disk usage of each host.
+func Prune(cluster *v2.Cluster, clusterfile string) ([]DiskUsage, error) {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return nil, err
+	}
+	k := i.(*KubeadmRuntime)
+	registry := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+	hosts := append(k.getMasterIPList(), k.getNodesIPList()...)
+	if utils.NotIn(registry.IP, hosts) {
+		hosts = append(hosts, registry.IP)
+	}
+	dirs := []string{common.DefaultClusterBaseDir(k.getClusterName())}
+	left := []string{"/var/lib/sealer", "/var/lib/etcd", "/var/lib/kubelet", "/var/lib/docker", "/var/lib/containerd"}
+	if registry.DataDir != "" {
+		dirs = append(dirs, registry.DataDir)
+		left = append(left, registry.DataDir)
+	}
+
+	var cmds []string
+	if cleanupLevel != CleanupKeepData {
+		cmds = append(cmds, fmt.Sprintf(RemotePruneSealer, strings.Join(dirs, " ")))
+	}
+	if cleanupLevel == CleanupPruneAll {
+		cmds = append(cmds, RemotePruneRuntime)
+	}
+	// the hosts skipped by reset are pruned with it by "sealer cleanup-orphans".
+	orphans, err := LoadOrphans(k.getClusterName())
+	if err != nil {
+		return nil, err
+	}
+	if skipUnreachable && len(orphans.Hosts) > 0 {
+		var reachable []string
+		for _, host := range hosts {
+			if orphan := orphans.Get(host); orphan != nil {
+				orphan.Commands = append(orphan.Commands, cmds...)
+				continue
+			}
+			reachable = append(reachable, host)
+		}
+		if err := orphans.Save(); err != nil {
+			return nil, err
+		}
+		hosts = reachable
+	}
+
+	var (
+		usages = make([]DiskUsage, len(hosts))
+		errCh  = make(chan error, len(hosts))
+		wg     sync.WaitGroup
+	)
+	for n, host := range hosts {
+		wg.Add(1)
+		go func(n int, host string) {
+			defer wg.Done()
+			ssh, end, err := k.startHostSpan("prune host", host)
+			if err != nil {
+				errCh <- result.OnHost(host, fmt.Errorf("failed to prune %s: %v", host, err))
+				return
+			}
+			if len(cmds) > 0 {
+				logger.Info("pruning %s by %s", host, cleanupLevel)
+				if err = ssh.CmdAsync(host, cmds...); err != nil {
+					end(err)
+					errCh <- result.OnHost(host, fmt.Errorf("failed to prune %s: %v", host, err))
+					return
+				}
+			}
+			out, err := ssh.Cmd(host, fmt.Sprintf(RemoteDiskUsage, strings.Join(left, " ")))
+			end(err)
+			if err != nil {
+				errCh <- result.OnHost(host, fmt.Errorf("failed to get the disk usage of %s: %v", host, err))
+				return
+			}
+			usages[n] = parseDiskUsage(host, string(out))
+		}(n, host)
+	}
+	wg.Wait()
+	close(errCh)
+	return usages, ReadChanError(errCh)
+}
+
+func parseDiskUsage(host, out string) DiskUsage {
+	usage := DiskUsage{Host: host}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	usage.Available = strings.TrimSpace(lines[0])
+	for _, line := range lines[1:] {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			usage.Left = append(usage.Left, fields[0]+" "+fields[1])
+		}
+	}
+	return usage
+}
@@ -0,0 +1,105 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/timeout"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// KubeletSysconfigFile is sourced by the kubelet service as KUBELET_EXTRA_ARGS, which comes after the kubeadm flags.
+	KubeletSysconfigFile         = "/etc/sysconfig/kubelet"
+	RemoteWriteKubeletExtraArgs  = `mkdir -p /etc/sysconfig && printf '%%s\n' %s > ` + KubeletSysconfigFile + ` && systemctl restart kubelet`
+	ReconfigureKubeadmConfig     = "kubeadm-reconfigure.yaml"
+	RemoteWriteReconfigureCmd    = `cd %s && printf '%%s\n' %s > ` + ReconfigureKubeadmConfig
+	RemoteRegenerateControlPlane = `kubeadm init phase control-plane all --config=%s/` + ReconfigureKubeadmConfig
+)
+
+// ConfigKubeletExtraArgs writes spec.kubernetes.kubelet.extraArgs of cluster to hosts and restarts their kubelet,
+// the args kubeadm wrote on join are kept, the extra args overwrite them.
+func ConfigKubeletExtraArgs(cluster *v2.Cluster, hosts []string) error {
+	i, err := newKubeadmRuntime(cluster, "")
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	var args []string
+	for key, value := range cluster.Spec.Kubernetes.Kubelet.ExtraArgs {
+		args = append(args, fmt.Sprintf("--%s=%s", key, value))
+	}
+	sort.Strings(args)
+	for _, host := range hosts {
+		logger.Info("start to restart kubelet on %s with the new extra args", host)
+		ssh, err := k.getHostSSHClient(host)
+		if err != nil {
+			return err
+		}
+		if err := ssh.CmdAsync(host, remoteWriteKubeletExtraArgs(args)); err != nil {
+			return fmt.Errorf("failed to restart kubelet on %s: %v", host, err)
+		}
+	}
+	return nil
+}
+
+// RegenerateControlPlane regenerates the static pod manifests of apiserver, controller-manager and scheduler
+// on masters one by one from the kubeadm config merged with spec.kubernetes of cluster, then waits for the apiserver.
+func RegenerateControlPlane(cluster *v2.Cluster, clusterfile string, masters []string) error {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	if err := k.mergeKubeadmConfigs(); err != nil {
+		return err
+	}
+	waitReady := fmt.Sprintf(RemoteWaitAPIServerReady, int(timeout.Of(k.Cluster, timeout.HealthCheck).Seconds()))
+	for _, master := range masters {
+		logger.Info("start to regenerate control plane on %s", master)
+		// the apiserver advertises the address of the master it runs on.
+		k.setInitAdvertiseAddress(master)
+		bs, err := k.generateConfigs()
+		if err != nil {
+			return err
+		}
+		ssh, err := k.getHostSSHClient(master)
+		if err != nil {
+			return err
+		}
+		err = ssh.CmdAsync(master, remoteWriteReconfigureCmd(k.getRootfs(), bs),
+			fmt.Sprintf(RemoteRegenerateControlPlane, k.getRootfs()), waitReady)
+		if err != nil {
+			return fmt.Errorf("failed to regenerate control plane on %s: %v", master, err)
+		}
+	}
+	return nil
+}
+
+// remoteWriteKubeletExtraArgs quotes the args, which come from the Clusterfile, as a single word of the shell,
+// printf keeps the backslashes in it, which echo of some shells interprets.
+func remoteWriteKubeletExtraArgs(args []string) string {
+	return fmt.Sprintf(RemoteWriteKubeletExtraArgs, utils.ShellQuote("KUBELET_EXTRA_ARGS="+strings.Join(args, " ")))
+}
+
+// remoteWriteReconfigureCmd quotes the kubeadm config like the kubelet args.
+func remoteWriteReconfigureCmd(rootfs string, config []byte) string {
+	return fmt.Sprintf(RemoteWriteReconfigureCmd, utils.ShellQuote(rootfs), utils.ShellQuote(string(config)))
+}
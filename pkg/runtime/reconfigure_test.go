@@ -0,0 +1,62 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoteWriteReconfigureCmd(t *testing.T) {
+	config := "apiServer:\n  extraArgs:\n    audit-policy-file: '/etc/kubernetes/audit policy.yaml'\n" +
+		"    oidc-username-prefix: \"it's $HOME `id` \\n\"\n"
+	rootfs := filepath.Join(t.TempDir(), "my cluster")
+	if err := os.MkdirAll(rootfs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("sh", "-c", remoteWriteReconfigureCmd(rootfs, []byte(config))).CombinedOutput(); err != nil {
+		t.Fatalf("RemoteWriteReconfigureCmd error = %v: %s", err, out)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(rootfs, ReconfigureKubeadmConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSuffix(string(data), "\n"); got != config {
+		t.Errorf("written kubeadm config = %q, want %q", got, config)
+	}
+}
+
+func TestRemoteWriteKubeletExtraArgs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kubelet")
+	args := []string{"--node-labels=team='a b'", "--eviction-hard=memory.available<100Mi", "--v=$(id)"}
+	cmd := strings.Replace(remoteWriteKubeletExtraArgs(args), KubeletSysconfigFile, file, 1)
+	cmd = strings.Replace(cmd, "mkdir -p /etc/sysconfig", "true", 1)
+	cmd = strings.Replace(cmd, "systemctl restart kubelet", "true", 1)
+	if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+		t.Fatalf("RemoteWriteKubeletExtraArgs error = %v: %s", err, out)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "KUBELET_EXTRA_ARGS=" + strings.Join(args, " ") + "\n"
+	if string(data) != want {
+		t.Errorf("written kubelet sysconfig = %q, want %q", data, want)
+	}
+}
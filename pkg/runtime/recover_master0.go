@@ -0,0 +1,209 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/ipvs"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/pkg/result"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// RemoteEtcdctl runs etcdctl in the etcd pod of node %s, by the certs of the etcd server.
+	RemoteEtcdctl = "kubectl -n kube-system exec etcd-%s -- etcdctl --endpoints=https://127.0.0.1:2379 " +
+		"--cacert=/etc/kubernetes/pki/etcd/ca.crt --cert=/etc/kubernetes/pki/etcd/server.crt --key=/etc/kubernetes/pki/etcd/server.key %s"
+	RemoteListNodeAddress = `kubectl get nodes -o jsonpath='{range .items[*]}{.metadata.name} {.status.addresses[?(@.type=="InternalIP")].address}{"\n"}{end}'`
+)
+
+// nodeNames returns the name of nodes by their ip in the output of RemoteListNodeAddress.
+func nodeNames(out string) map[string]string {
+	names := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			names[fields[1]] = fields[0]
+		}
+	}
+	return names
+}
+
+// etcdMemberOf returns the id of the member whose peer url is on host in the output of etcdctl member list,
+// like "8e9e05c52164694d, started, master-0, https://192.168.0.2:2380, https://192.168.0.2:2379, false".
+func etcdMemberOf(out, host string) string {
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 4 {
+			continue
+		}
+		for _, peer := range strings.Fields(fields[3]) {
+			if u, err := url.Parse(peer); err == nil && u.Hostname() == host {
+				return strings.TrimSpace(fields[0])
+			}
+		}
+	}
+	return ""
+}
+
+// PromoteMaster0 makes the first master of cluster master0 in place of the lost one, which is not in cluster anymore:
+// the lost master is removed from etcd and the nodes, the registry is run on the new master0 if it was on the
+// lost one, the registry domain in /etc/hosts and lvscare of all hosts are updated, and the local kubeconfig
+// is fetched from the new master0. The lost master is reset if it is reachable, or recorded as an orphan.
+func PromoteMaster0(cluster *v2.Cluster, clusterfile, lost string) error {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	if err := k.MergeKubeadmConfig(); err != nil {
+		return err
+	}
+	master0 := k.getMaster0IP()
+	ssh, err := k.getHostSSHClient(master0)
+	if err != nil {
+		return fmt.Errorf("failed to get master0 ssh client: %v", err)
+	}
+
+	out, err := ssh.CmdToString(master0, RemoteListNodeAddress, "\n")
+	if err != nil {
+		return fmt.Errorf("failed to list the nodes: %v", err)
+	}
+	names := nodeNames(out)
+	name0 := names[utils.GetHostIP(master0)]
+	if name0 == "" {
+		return fmt.Errorf("master %s is not a node of the cluster", master0)
+	}
+	members, err := ssh.CmdToString(master0, fmt.Sprintf(RemoteEtcdctl, name0, "member list"), "\n")
+	if err != nil {
+		return fmt.Errorf("failed to list the members of etcd on %s: %v", master0, err)
+	}
+	if id := etcdMemberOf(members, utils.GetHostIP(lost)); id != "" {
+		logger.Info("remove member %s of lost master0 %s from etcd", id, lost)
+		if err := ssh.CmdAsync(master0, fmt.Sprintf(RemoteEtcdctl, name0, "member remove "+id)); err != nil {
+			return fmt.Errorf("failed to remove lost master0 %s from etcd: %v", lost, err)
+		}
+	}
+	if name := names[utils.GetHostIP(lost)]; name != "" {
+		if err := ssh.CmdAsync(master0, fmt.Sprintf(KubeDeleteNode, name)); err != nil {
+			return fmt.Errorf("delete node %s failed %v", name, err)
+		}
+	}
+
+	old := GetRegistryConfig(k.getRootfs(), lost)
+	cf := GetRegistryConfig(k.getRootfs(), master0)
+	if old.IP != cf.IP {
+		if err := k.sendFileToHosts([]string{master0}, k.getCertsDir(), filepath.Join(k.getRootfs(), "certs")); err != nil {
+			return err
+		}
+		logger.Info("start to run registry on %s", master0)
+		if err := k.ApplyRegistry(); err != nil {
+			return fmt.Errorf("failed to run registry: %v", err)
+		}
+	}
+	if err := k.updateHostsOfMaster0(old.IP != cf.IP); err != nil {
+		return err
+	}
+	if err := k.syncAPIServerDomain(k.masterListWith(nil, []string{lost})); err != nil {
+		return err
+	}
+
+	removeLocalAPIServerHost(lost)
+	if k.Spec.Kubeconfig.NoMerge {
+		if err := os.Remove(common.DefaultKubeConfigFile()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if KubeconfigHasContext(common.DefaultKubeConfigFile(), k.getClusterName()) {
+		if err := RemoveKubeconfigContext(common.DefaultKubeConfigFile(), k.getClusterName()); err != nil {
+			return err
+		}
+	}
+	if err := k.GetKubectlAndKubeconfig(); err != nil {
+		return err
+	}
+
+	// the kubelet of the lost master registers its node again once it is back, unless it is reset.
+	if err := k.resetLostMaster(lost); err != nil {
+		return k.recordOrphans(map[string]error{lost: err}, map[string][]string{lost: k.resetHostCmds()})
+	}
+	return nil
+}
+
+// updateHostsOfMaster0 points the registry domain of all hosts to the new master0 if the registry is moved,
+// and balances the apiserver of nodes to the masters left by lvscare.
+func (k *KubeadmRuntime) updateHostsOfMaster0(registryMoved bool) error {
+	cf := GetRegistryConfig(k.getImageMountDir(), k.getMaster0IP())
+	registryIP, _ := utils.GetSSHHostIPAndPort(cf.IP)
+	yaml := ipvs.LvsStaticPodYaml(k.getVIP(), k.getMasterIPList(), "")
+	hosts := append(k.getMasterIPList(), k.getNodesIPList()...)
+
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			var cmds []string
+			if utils.NotIn(host, k.getMasterIPList()) {
+				cmds = append(cmds, RemoveLvscareStaticPod, fmt.Sprintf(CreateLvscareStaticPod, yaml))
+			}
+			if len(cmds) == 0 && !registryMoved {
+				return
+			}
+			ssh, end, err := k.startHostSpan("promote master0", host)
+			if err != nil {
+				errCh <- result.OnHost(host, fmt.Errorf("failed to update %s: %v", host, err))
+				return
+			}
+			if registryMoved {
+				err = k.applyEtcHosts(ssh, host, etchosts.Entry{IP: registryIP, Domain: cf.Domain})
+				if err == nil {
+					err = applyRegistryAuth(ssh, host, cf)
+				}
+			}
+			if err == nil && len(cmds) > 0 {
+				err = ssh.CmdAsync(host, cmds...)
+			}
+			end(err)
+			if err != nil {
+				errCh <- result.OnHost(host, fmt.Errorf("failed to update %s: %v", host, err))
+			}
+		}(host)
+	}
+	wg.Wait()
+	return ReadChanError(errCh)
+}
+
+func (k *KubeadmRuntime) resetLostMaster(lost string) error {
+	ssh, err := k.getHostSSHClient(lost)
+	if err != nil {
+		return err
+	}
+	if err := ssh.CmdAsync(lost, k.resetHostCmds()...); err != nil {
+		return fmt.Errorf("failed to reset lost master0: %v", err)
+	}
+	logger.Info("lost master0 %s is reachable and reset", lost)
+	return nil
+}
@@ -0,0 +1,44 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEtcdMemberOf(t *testing.T) {
+	out := `8e9e05c52164694d, started, master-0, https://192.168.0.2:2380, https://192.168.0.2:2379, false
+91bc3c398fb3c146, started, master-1, https://192.168.0.3:2380, https://192.168.0.3:2379, false
+fd422379fda50e48, unstarted, , https://192.168.0.4:2380, , false`
+	tests := []struct {
+		host, want string
+	}{
+		{"192.168.0.2", "8e9e05c52164694d"},
+		{"192.168.0.3", "91bc3c398fb3c146"},
+		{"192.168.0.4", "fd422379fda50e48"},
+		{"192.168.0.22", ""},
+	}
+	for _, tt := range tests {
+		if got := etcdMemberOf(out, tt.host); got != tt.want {
+			t.Errorf("etcdMemberOf(%s) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+
+	names := nodeNames("master-0 192.168.0.2\nmaster-1 192.168.0.3\nnot-ready\n")
+	if !reflect.DeepEqual(names, map[string]string{"192.168.0.2": "master-0", "192.168.0.3": "master-1"}) {
+		t.Errorf("nodeNames() = %v", names)
+	}
+}
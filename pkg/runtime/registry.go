@@ -17,12 +17,11 @@ package runtime
 import (
 	"fmt"
 	"path/filepath"
-
-	"golang.org/x/crypto/bcrypt"
+	"strings"
 
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
 	"github.com/alibaba/sealer/utils"
-	"github.com/alibaba/sealer/utils/mount"
 )
 
 const (
@@ -32,7 +31,8 @@ const (
 	RegistryMountWork           = "/var/lib/sealer/tmp/work"
 	SeaHub                      = "sea.hub"
 	DefaultRegistryHtPasswdFile = "registry_htpasswd"
-	DockerLoginCommand          = "docker login %s -u %s -p %s"
+	// copy the images in the old upper dir to the new one if it is empty.
+	RemoteMigrateRegistryData = "if [ -d %[1]s ] && [ -z \"$(ls -A %[2]s)\" ]; then cp -a %[1]s/. %[2]s/; fi"
 )
 
 type RegistryConfig struct {
@@ -41,12 +41,22 @@ type RegistryConfig struct {
 	Port     string `yaml:"port,omitempty"`
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+	// DataDir keeps the images pushed to the registry, which are written to the upper dir of the mount of the
+	// registry dir of rootfs under /var/lib/sealer/tmp by default and removed on every apply.
+	DataDir string `json:"dataDir,omitempty"`
+	// Proxies are pull-through caches of upstream registries.
+	Proxies []RegistryProxy `json:"proxies,omitempty"`
 }
 
 func getRegistryHost(rootfs, defaultRegistry string) (host string) {
+	return getRegistryEntry(rootfs, defaultRegistry).String()
+}
+
+// getRegistryEntry returns the /etc/hosts entry of the registry domain.
+func getRegistryEntry(rootfs, defaultRegistry string) etchosts.Entry {
 	cf := GetRegistryConfig(rootfs, defaultRegistry)
 	ip, _ := utils.GetSSHHostIPAndPort(cf.IP)
-	return fmt.Sprintf("%s %s", ip, cf.Domain)
+	return etchosts.Entry{IP: ip, Domain: cf.Domain}
 }
 
 // ApplyRegistry Only use this for join and init, due to the initiation operations.
@@ -57,52 +67,53 @@ func (k *KubeadmRuntime) ApplyRegistry() error {
 		return fmt.Errorf("failed to get registry ssh client: %v", err)
 	}
 
-	mkdir := fmt.Sprintf("rm -rf %s %s && mkdir -p %s %s", RegistryMountUpper, RegistryMountWork,
-		RegistryMountUpper, RegistryMountWork)
-
-	mountCmd := fmt.Sprintf("%s && mount -t overlay overlay -o lowerdir=%s,upperdir=%s,workdir=%s %s", mkdir,
-		k.getRootfs(),
-		RegistryMountUpper, RegistryMountWork, k.getRootfs())
-	isMount, _ := mount.GetRemoteMountDetails(ssh, cf.IP, k.getRootfs())
-	if isMount {
-		mountCmd = fmt.Sprintf("umount %s && %s", k.getRootfs(), mountCmd)
-	}
-	if err := ssh.CmdAsync(cf.IP, mountCmd); err != nil {
+	m := k.registryMount(cf)
+	upper, work := cf.mountDirs()
+	prepare := fmt.Sprintf("rm -rf %s %s", RegistryMountUpper, RegistryMountWork)
+	if cf.DataDir != "" {
+		// keep the pushed images in data dir, and move the ones in the tmp upper dir of a previous apply to it.
+		prepare = fmt.Sprintf("mkdir -p %s %s && %s && rm -rf %s %s", upper, work,
+			fmt.Sprintf(RemoteMigrateRegistryData, RegistryMountUpper, upper), RegistryMountUpper, RegistryMountWork)
+	}
+	if err := ssh.CmdAsync(cf.IP, m.unmountCmd(), prepare); err != nil {
 		return err
 	}
-	if cf.Username != "" && cf.Password != "" {
-		htpasswd, err := cf.GenerateHtPasswd()
-		if err != nil {
-			return err
-		}
-		err = ssh.CmdAsync(cf.IP, fmt.Sprintf("echo '%s' >> %s", htpasswd, filepath.Join(k.getRootfs(), "etc", DefaultRegistryHtPasswdFile)))
-		if err != nil {
-			return err
-		}
+	if err := m.mount(ssh, cf.IP); err != nil {
+		return fmt.Errorf("failed to mount the registry dir: %v", err)
+	}
+	if _, err = k.applyRegistryHtpasswd(ssh, cf); err != nil {
+		return err
 	}
 	initRegistry := fmt.Sprintf("cd %s/scripts && sh init-registry.sh %s %s", k.getRootfs(), cf.Port, fmt.Sprintf("%s/registry", k.getRootfs()))
-	addRegistryHosts := fmt.Sprintf(RemoteAddEtcHosts, getRegistryHost(k.getRootfs(), k.getMaster0IP()))
 	if err = ssh.CmdAsync(cf.IP, initRegistry); err != nil {
 		return err
 	}
-	if err = ssh.CmdAsync(k.getMaster0IP(), addRegistryHosts); err != nil {
+	for _, p := range cf.Proxies {
+		if err = ssh.CmdAsync(cf.IP, cf.runProxyCmd(k.getRootfs(), p)); err != nil {
+			return fmt.Errorf("failed to run registry proxy of %s: %v", p.Upstream, err)
+		}
+	}
+	entry := getRegistryEntry(k.getRootfs(), k.getMaster0IP())
+	if err = k.registerDomain(entry.Domain, []string{entry.IP}); err != nil {
 		return err
 	}
-	if cf.Username == "" || cf.Password == "" {
-		return nil
+	if err = k.applyEtcHosts(ssh, k.getMaster0IP(), entry); err != nil {
+		return err
 	}
-	return ssh.CmdAsync(k.getMaster0IP(), fmt.Sprintf(DockerLoginCommand, cf.Domain+":"+cf.Port, cf.Username, cf.Password))
+	return applyRegistryAuth(ssh, k.getMaster0IP(), cf)
 }
 
-func (r *RegistryConfig) GenerateHtPasswd() (string, error) {
-	if r.Username == "" || r.Password == "" {
-		return "", fmt.Errorf("generate htpasswd failed: registry username or passwodr is empty")
-	}
-	pwdHash, err := bcrypt.GenerateFromPassword([]byte(r.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate registry password: %v", err)
+func isSubDir(dir, parent string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// mountDirs returns the upper and work dir of the mount of registry, the registry data is in the registry dir of upper.
+func (r *RegistryConfig) mountDirs() (upper, work string) {
+	if r.DataDir == "" {
+		return RegistryMountUpper, RegistryMountWork
 	}
-	return r.Username + ":" + string(pwdHash), nil
+	return filepath.Join(r.DataDir, "upper"), filepath.Join(r.DataDir, "work")
 }
 
 func GetRegistryConfig(rootfs, defaultRegistry string) *RegistryConfig {
@@ -134,22 +145,39 @@ func GetRegistryConfig(rootfs, defaultRegistry string) *RegistryConfig {
 	if config.Domain == "" {
 		config.Domain = DefaultConfig.Domain
 	}
+	config.setProxiesDefault()
+	if config.DataDir != "" {
+		dataDir := filepath.Clean(config.DataDir)
+		if !filepath.IsAbs(dataDir) || isSubDir(dataDir, filepath.Dir(RegistryMountUpper)) {
+			logger.Warn("registry data dir %s must be an absolute path out of %s, ignore it", config.DataDir, filepath.Dir(RegistryMountUpper))
+			dataDir = ""
+		}
+		config.DataDir = dataDir
+	}
 	logger.Debug(fmt.Sprintf("show registry info, IP: %s, Domain: %s", config.IP, config.Domain))
 	return &config
 }
 
 func (k *KubeadmRuntime) DeleteRegistry() error {
 	cf := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
-	delDir := fmt.Sprintf("rm -rf %s %s", RegistryMountUpper, RegistryMountWork)
 	ssh, err := k.getHostSSHClient(cf.IP)
 	if err != nil {
 		return fmt.Errorf("failed to delete registry: %v", err)
 	}
 
-	isMount, _ := mount.GetRemoteMountDetails(ssh, cf.IP, k.getRootfs())
-	if isMount {
-		delDir = fmt.Sprintf("umount %s && %s", k.getRootfs(), delDir)
-	}
-	cmd := fmt.Sprintf("if docker inspect %s;then docker rm -f %s;fi && %s ", RegistryName, RegistryName, delDir)
-	return ssh.CmdAsync(cf.IP, cmd)
+	return ssh.CmdAsync(cf.IP, k.deleteRegistryCmd(cf, k.registryMount(cf).unmountCmd()))
+}
+
+// deleteRegistryCmd removes the registry container and its dirs on the registry host, after running umount if set.
+func (k *KubeadmRuntime) deleteRegistryCmd(cf *RegistryConfig, umount string) string {
+	// the data dir is kept, so the pushed images survive deleting and applying the cluster again.
+	_, work := cf.mountDirs()
+	delDir := fmt.Sprintf("rm -rf %s %s %s", RegistryMountUpper, RegistryMountWork, work)
+	if umount != "" {
+		delDir = fmt.Sprintf("%s && %s", umount, delDir)
+	}
+	if len(cf.Proxies) > 0 {
+		delDir = fmt.Sprintf("%s && %s", cf.removeProxiesCmd(), delDir)
+	}
+	return fmt.Sprintf("if docker inspect %s;then docker rm -f %s;fi && %s ", RegistryName, RegistryName, delDir)
 }
@@ -0,0 +1,203 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// DockerConfigPath is read by docker to pull images from the registry.
+	DockerConfigPath = "/root/.docker/config.json"
+	// KubeletDockerConfigPath is read by kubelet, which passes the credentials to the CRI pulling the images of pods,
+	// so it works for both docker and containerd.
+	KubeletDockerConfigPath = "/var/lib/kubelet/config.json"
+	// RemoteReplaceDockerConfig renames the new config %[1]s to %[2]s, which is read by root only.
+	RemoteReplaceDockerConfig = "chmod 600 %[1]s && mv -f %[1]s %[2]s"
+)
+
+// RegistryAuthPaths are the docker configs the credentials of the registry are written to on every host.
+var RegistryAuthPaths = []string{DockerConfigPath, KubeletDockerConfigPath}
+
+// MergeDockerConfig returns the docker config data with the credentials of server set, the other fields of it are
+// kept. It returns data itself if the credentials are set already.
+func MergeDockerConfig(data []byte, server, username, password string) ([]byte, error) {
+	config := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse docker config: %v", err)
+		}
+	}
+	auths := map[string]map[string]interface{}{}
+	if raw, ok := config["auths"]; ok {
+		if err := json.Unmarshal(raw, &auths); err != nil {
+			return nil, fmt.Errorf("failed to parse the auths of docker config: %v", err)
+		}
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	if a, ok := auths[server]; ok && a["auth"] == auth {
+		return data, nil
+	}
+	auths[server] = map[string]interface{}{"auth": auth}
+	raw, err := json.Marshal(auths)
+	if err != nil {
+		return nil, err
+	}
+	config["auths"] = raw
+	return json.MarshalIndent(config, "", "\t")
+}
+
+func (r *RegistryConfig) server() string {
+	return r.Domain + ":" + r.Port
+}
+
+// DockerAuth is the credentials of a registry in the docker configs.
+type DockerAuth struct {
+	// Server is the domain of the registry, with the port if it is not 443, like: sea.hub:5000
+	Server   string
+	Username string
+	Password string
+}
+
+func (r *RegistryConfig) dockerAuth() DockerAuth {
+	return DockerAuth{Server: r.server(), Username: r.Username, Password: r.Password}
+}
+
+// applyRegistryAuth writes the credentials of the registry to the docker configs on host.
+func applyRegistryAuth(client ssh.Interface, host string, cf *RegistryConfig) error {
+	if cf.Username == "" || cf.Password == "" {
+		return nil
+	}
+	return ApplyDockerAuths(client, host, []DockerAuth{cf.dockerAuth()})
+}
+
+// ApplyDockerAuths writes the credentials to the docker configs on host by sftp, so the passwords are not in the
+// command lines, the configs are not written if they have the credentials already.
+func ApplyDockerAuths(client ssh.Interface, host string, auths []DockerAuth) error {
+	if len(auths) == 0 {
+		return nil
+	}
+	for _, path := range RegistryAuthPaths {
+		data, updated, err := dockerAuthsOf(client, host, path, auths)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(data, updated) {
+			continue
+		}
+		if err = copyRegistryAuth(client, host, path, updated); err != nil {
+			return fmt.Errorf("failed to write the registry credentials to %s of %s: %v", path, host, err)
+		}
+	}
+	return nil
+}
+
+// checkRegistryAuth returns whether the docker configs on host have the credentials of the registry.
+func checkRegistryAuth(client ssh.Interface, host string, cf *RegistryConfig) (bool, string) {
+	for _, path := range RegistryAuthPaths {
+		data, updated, err := dockerAuthsOf(client, host, path, []DockerAuth{cf.dockerAuth()})
+		if err != nil {
+			return false, err.Error()
+		}
+		if !bytes.Equal(data, updated) {
+			return false, fmt.Sprintf("the credentials of %s are not in %s", cf.server(), path)
+		}
+	}
+	return true, ""
+}
+
+// dockerAuthsOf returns the docker config at path on host, and the one with the credentials of auths.
+func dockerAuthsOf(client ssh.Interface, host, path string, auths []DockerAuth) (data, updated []byte, err error) {
+	if client.IsFileExist(host, path) {
+		dir, err := ioutil.TempDir("", "sealer-docker-config")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.RemoveAll(dir)
+		local := filepath.Join(dir, "config.json")
+		if err = client.Fetch(host, local, path); err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch %s of %s: %v", path, host, err)
+		}
+		if data, err = ioutil.ReadFile(local); err != nil {
+			return nil, nil, err
+		}
+	}
+	updated = data
+	for _, a := range auths {
+		if updated, err = MergeDockerConfig(updated, a.Server, a.Username, a.Password); err != nil {
+			return nil, nil, fmt.Errorf("%s of %s: %v", path, host, err)
+		}
+	}
+	return data, updated, nil
+}
+
+func copyRegistryAuth(client ssh.Interface, host, path string, data []byte) error {
+	f, err := ioutil.TempFile("", "sealer-docker-config")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	// the dir of path may not exist before kubelet is installed.
+	if err = client.CmdAsync(host, fmt.Sprintf("mkdir -p %s", filepath.Dir(path))); err != nil {
+		return err
+	}
+	tmp := path + ".sealer.tmp"
+	if err = client.Copy(host, f.Name(), tmp); err != nil {
+		return err
+	}
+	return client.CmdAsync(host, fmt.Sprintf(RemoteReplaceDockerConfig, tmp, path))
+}
+
+// applyRegistryAuthToHosts writes the credentials of the registry to all hosts concurrently.
+func (k *KubeadmRuntime) applyRegistryAuthToHosts(cf *RegistryConfig, hosts []string) error {
+	if cf.Username == "" || cf.Password == "" {
+		return nil
+	}
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			client, err := k.getHostSSHClient(host)
+			if err == nil {
+				err = applyRegistryAuth(client, host, cf)
+			}
+			if err != nil {
+				errCh <- result.OnHost(host, fmt.Errorf("failed to distribute the registry credentials to %s: %v", host, err))
+			}
+		}(host)
+	}
+	wg.Wait()
+	return ReadChanError(errCh)
+}
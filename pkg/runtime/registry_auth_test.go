@@ -0,0 +1,54 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergeDockerConfig(t *testing.T) {
+	existing := []byte(`{"auths":{"docker.io":{"auth":"eDp5"}},"credsStore":"","proxies":{"default":{"httpProxy":"http://proxy:3128"}}}`)
+	merged, err := MergeDockerConfig(existing, "sea.hub:5000", "admin", "passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Auths   map[string]map[string]string `json:"auths"`
+		Proxies map[string]interface{}       `json:"proxies"`
+	}
+	if err := json.Unmarshal(merged, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Auths["sea.hub:5000"]["auth"] != "YWRtaW46cGFzc3dk" {
+		t.Errorf("MergeDockerConfig() auth of sea.hub:5000 = %v", config.Auths["sea.hub:5000"])
+	}
+	if config.Auths["docker.io"]["auth"] != "eDp5" || config.Proxies == nil {
+		t.Errorf("MergeDockerConfig() dropped the other fields:\n%s", merged)
+	}
+
+	// merging again changes nothing.
+	again, err := MergeDockerConfig(merged, "sea.hub:5000", "admin", "passwd")
+	if err != nil || string(again) != string(merged) {
+		t.Errorf("MergeDockerConfig() again = %s, %v", again, err)
+	}
+
+	if _, err := MergeDockerConfig(nil, "sea.hub:5000", "admin", "passwd"); err != nil {
+		t.Errorf("MergeDockerConfig() of no config: %v", err)
+	}
+	if _, err := MergeDockerConfig([]byte("not json"), "sea.hub:5000", "admin", "passwd"); err == nil {
+		t.Errorf("MergeDockerConfig() accepts invalid config")
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	RemoteCheckRegistryRunning = "docker inspect -f '{{.State.Running}}' %s"
+	RemoteRemoveRegistry       = "if docker inspect %s >/dev/null 2>&1;then docker rm -f %s;fi"
+	RemoteCheckEtcHosts        = "grep -qE '%s' /etc/hosts"
+)
+
+const (
+	RegistryCheckMount     = "registry mount"
+	RegistryCheckContainer = "registry container"
+	RegistryCheckHtpasswd  = "htpasswd"
+	RegistryCheckEtcHosts  = "/etc/hosts"
+	RegistryCheckAuth      = "registry auth"
+)
+
+// RegistryCheck is the result of one item of the registry on one host.
+type RegistryCheck struct {
+	Host     string
+	Item     string
+	Healthy  bool
+	Repaired bool
+	Message  string
+}
+
+type registryCheckItem struct {
+	host  string
+	item  string
+	check func() (healthy bool, message string)
+	// repair re-applies the item, it is nil if the item can not be repaired.
+	repair func() error
+}
+
+// CheckRegistry verifies the mount of the registry dir, the registry container and the htpasswd on the registry host,
+// and the registry entry of /etc/hosts and the registry credentials on all hosts. The broken items are re-applied if
+// repair is true, otherwise nothing is changed.
+func CheckRegistry(cluster *v2.Cluster, clusterfile string, repair bool) ([]RegistryCheck, error) {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return nil, err
+	}
+	k := i.(*KubeadmRuntime)
+	items, err := k.registryCheckItems()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []RegistryCheck
+	for _, item := range items {
+		result := RegistryCheck{Host: item.host, Item: item.item}
+		result.Healthy, result.Message = item.check()
+		if !result.Healthy && repair && item.repair != nil {
+			logger.Info("start to repair %s of registry on %s", item.item, item.host)
+			if err := item.repair(); err != nil {
+				result.Message = fmt.Sprintf("%s, failed to repair: %v", result.Message, err)
+			} else {
+				result.Healthy, result.Message = item.check()
+				result.Repaired = result.Healthy
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (k *KubeadmRuntime) registryCheckItems() ([]registryCheckItem, error) {
+	cf := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+	registryIP := utils.GetHostIP(cf.IP)
+	client, err := k.getHostSSHClient(cf.IP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry ssh client: %v", err)
+	}
+	rootfs := k.getRootfs()
+	m := k.registryMount(cf)
+	// the registry container must be recreated to see the remounted data dir and the new htpasswd.
+	recreate := false
+
+	items := []registryCheckItem{
+		{
+			host: registryIP,
+			item: RegistryCheckMount,
+			check: func() (bool, string) {
+				return m.check(client, cf.IP)
+			},
+			repair: func() error {
+				// unlike ApplyRegistry, the upper dir is kept when remounting, it holds the images pushed to the registry.
+				recreate = true
+				return m.mount(client, cf.IP)
+			},
+		},
+	}
+
+	items = append(items, registryCheckItem{
+		host: registryIP,
+		item: RegistryCheckHtpasswd,
+		check: func() (bool, string) {
+			return k.checkRegistryHtpasswd(client, cf)
+		},
+		repair: func() error {
+			changed, err := k.applyRegistryHtpasswd(client, cf)
+			recreate = recreate || changed
+			return err
+		},
+	})
+
+	items = append(items, registryCheckItem{
+		host: registryIP,
+		item: RegistryCheckContainer,
+		check: func() (bool, string) {
+			if recreate {
+				return false, fmt.Sprintf("%s must be recreated", RegistryName)
+			}
+			return checkContainerRunning(client, cf.IP, RegistryName)
+		},
+		repair: func() error {
+			if err := k.recreateRegistry(client, cf); err != nil {
+				return err
+			}
+			recreate = false
+			return nil
+		},
+	})
+
+	for _, p := range cf.Proxies {
+		p := p
+		name := fmt.Sprintf(RegistryProxyName, p.Port)
+		items = append(items, registryCheckItem{
+			host: registryIP,
+			item: fmt.Sprintf("proxy of %s", p.Upstream),
+			check: func() (bool, string) {
+				return checkContainerRunning(client, cf.IP, name)
+			},
+			repair: func() error {
+				return client.CmdAsync(cf.IP, cf.runProxyCmd(rootfs, p))
+			},
+		})
+	}
+
+	registryHost := getRegistryHost(rootfs, k.getMaster0IP())
+	for _, ip := range append(k.getMasterIPList(), k.getNodesIPList()...) {
+		if !k.managesDomain(cf.Domain) {
+			items = append(items, k.etcHostsCheckItem(ip, cf.Domain, registryHost))
+		}
+		if cf.Username != "" && cf.Password != "" {
+			items = append(items, k.registryAuthCheckItem(ip, cf))
+		}
+	}
+	return items, nil
+}
+
+func checkContainerRunning(client ssh.Interface, ip, name string) (bool, string) {
+	out, err := client.CmdToString(ip, fmt.Sprintf(RemoteCheckRegistryRunning, name), "")
+	if err != nil {
+		return false, fmt.Sprintf("%s does not exist", name)
+	}
+	if strings.TrimSpace(out) != "true" {
+		return false, fmt.Sprintf("%s is not running", name)
+	}
+	return true, ""
+}
+
+// etcHostsCheckItem checks the "<registry ip> <registry domain>" entry of /etc/hosts on ip,
+// a stale entry of the domain is replaced when repairing.
+func (k *KubeadmRuntime) etcHostsCheckItem(ip, domain, registryHost string) registryCheckItem {
+	var (
+		client ssh.Interface
+		err    error
+	)
+	fields := strings.Fields(registryHost)
+	return registryCheckItem{
+		host: utils.GetHostIP(ip),
+		item: RegistryCheckEtcHosts,
+		check: func() (bool, string) {
+			if client == nil {
+				if client, err = k.getHostSSHClient(ip); err != nil {
+					return false, err.Error()
+				}
+			}
+			cmd := fmt.Sprintf(RemoteCheckEtcHosts, etcHostsPattern(fields[0], domain))
+			if _, err := client.Cmd(ip, cmd); err != nil {
+				return false, fmt.Sprintf("%s is not in /etc/hosts", registryHost)
+			}
+			return true, ""
+		},
+		repair: func() error {
+			if client == nil {
+				return err
+			}
+			return etchosts.Apply(client, ip, []etchosts.Entry{{IP: fields[0], Domain: domain}})
+		},
+	}
+}
+
+// etcHostsPattern returns the extended regular expression of the "<ip> <domain>" line of /etc/hosts,
+// the dots are escaped not to match any character.
+func etcHostsPattern(ip, domain string) string {
+	return fmt.Sprintf(`^%s[[:space:]]+%s([[:space:]]|$)`, strings.ReplaceAll(ip, ".", `\.`), strings.ReplaceAll(domain, ".", `\.`))
+}
+
+// registryAuthCheckItem checks the credentials of the registry in the docker configs on ip.
+func (k *KubeadmRuntime) registryAuthCheckItem(ip string, cf *RegistryConfig) registryCheckItem {
+	var (
+		client ssh.Interface
+		err    error
+	)
+	return registryCheckItem{
+		host: utils.GetHostIP(ip),
+		item: RegistryCheckAuth,
+		check: func() (bool, string) {
+			if client == nil {
+				if client, err = k.getHostSSHClient(ip); err != nil {
+					return false, err.Error()
+				}
+			}
+			return checkRegistryAuth(client, ip, cf)
+		},
+		repair: func() error {
+			if client == nil {
+				return err
+			}
+			return applyRegistryAuth(client, ip, cf)
+		},
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEtcHostsPattern(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"192.168.0.2 sea.hub", true},
+		{"192.168.0.2\tsea.hub", true},
+		{"192.168.0.2   sea.hub registry.local", true},
+		{"192x168x0x2 sea.hub", false},
+		{"192.168.0.2 seaxhub", false},
+		{"192.168.0.25 sea.hub", false},
+		{"192.168.0.2 sea.hub.example.com", false},
+		{"#192.168.0.2 sea.hub", false},
+	}
+	pattern := etcHostsPattern("192.168.0.2", "sea.hub")
+	re := regexp.MustCompile(pattern)
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := re.MatchString(tt.line); got != tt.want {
+				t.Errorf("%s matches %q = %v, want %v", pattern, tt.line, got, tt.want)
+			}
+			// the pattern is run by grep -E on the hosts.
+			if _, err := exec.LookPath("grep"); err != nil {
+				return
+			}
+			cmd := exec.Command("grep", "-qE", pattern)
+			cmd.Stdin = strings.NewReader(tt.line + "\n")
+			if got := cmd.Run() == nil; got != tt.want {
+				t.Errorf("grep -E %s of %q = %v, want %v", pattern, tt.line, got, tt.want)
+			}
+		})
+	}
+}
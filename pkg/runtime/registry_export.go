@@ -0,0 +1,129 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/archive"
+)
+
+const (
+	// the registry data is under the registry dir of rootfs, both in the image and in the upper dir.
+	registryDataDir      = "registry"
+	RemoteTarRegistryDir = "if [ -d %[1]s/%[2]s ]; then tar -czf %[3]s -C %[1]s %[2]s; fi"
+	RemoteUntarRegistry  = "tar -xzf %s -C %s && rm -f %[1]s"
+)
+
+// ExportRegistryData fetches the images pushed to the registry of cluster since it is applied to the
+// registry dir under dst. They are kept in the upper dir of the mount of the registry, so the images of the
+// CloudImage itself are not fetched again, unless the registry is mounted by RegistryMountBind.
+func ExportRegistryData(cluster *v2.Cluster, clusterfile, dst string) error {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	cf := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+	client, err := k.getHostSSHClient(cf.IP)
+	if err != nil {
+		return fmt.Errorf("failed to get registry ssh client: %v", err)
+	}
+
+	upper, _ := cf.mountDirs()
+	tarball := filepath.Join(common.DefaultTmpDir, fmt.Sprintf("registry-%s.tar.gz", cluster.Name))
+	if err = client.CmdAsync(cf.IP, fmt.Sprintf(RemoteTarRegistryDir, upper, registryDataDir, tarball)); err != nil {
+		return fmt.Errorf("failed to archive registry data on %s: %v", cf.IP, err)
+	}
+	if !client.IsFileExist(cf.IP, tarball) {
+		logger.Info("no image is pushed to the registry of cluster %s", cluster.Name)
+		return nil
+	}
+	defer func() {
+		if err := client.CmdAsync(cf.IP, fmt.Sprintf("rm -f %s", tarball)); err != nil {
+			logger.Warn("failed to remove %s on %s: %v", tarball, cf.IP, err)
+		}
+	}()
+
+	local := filepath.Join(common.DefaultTmpDir, fmt.Sprintf("registry-%s-fetched.tar.gz", cluster.Name))
+	if err = client.Fetch(cf.IP, local, tarball); err != nil {
+		return fmt.Errorf("failed to fetch registry data from %s: %v", cf.IP, err)
+	}
+	defer os.Remove(local)
+
+	f, err := os.Open(filepath.Clean(local))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = archive.Decompress(f, dst, archive.Options{}); err != nil {
+		return fmt.Errorf("failed to extract registry data: %v", err)
+	}
+	return nil
+}
+
+// ImportRegistryData pushes the images in the registry dir under src, fetched by ExportRegistryData, to the
+// running registry of cluster. They are extracted to the rootfs mounted by the registry, so they land in the
+// upper dir like the images pushed.
+func ImportRegistryData(cluster *v2.Cluster, clusterfile, src string) error {
+	if _, err := os.Stat(filepath.Join(src, registryDataDir)); os.IsNotExist(err) {
+		return nil
+	}
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	cf := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+	client, err := k.getHostSSHClient(cf.IP)
+	if err != nil {
+		return fmt.Errorf("failed to get registry ssh client: %v", err)
+	}
+
+	tarReader, err := archive.TarWithRootDir(filepath.Join(src, registryDataDir))
+	if err != nil {
+		return err
+	}
+	defer tarReader.Close()
+	gzReader, done := archive.GzipCompress(tarReader)
+	defer gzReader.Close()
+	local := filepath.Join(common.DefaultTmpDir, fmt.Sprintf("registry-%s-import.tar.gz", cluster.Name))
+	f, err := os.Create(filepath.Clean(local))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local)
+	_, err = io.Copy(f, gzReader)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to archive registry data: %v", err)
+	}
+	<-done
+
+	tarball := filepath.Join(common.DefaultTmpDir, fmt.Sprintf("registry-%s.tar.gz", cluster.Name))
+	if err = client.Copy(cf.IP, local, tarball); err != nil {
+		return fmt.Errorf("failed to copy registry data to %s: %v", cf.IP, err)
+	}
+	if err = client.CmdAsync(cf.IP, fmt.Sprintf(RemoteUntarRegistry, tarball, k.getRootfs())); err != nil {
+		return fmt.Errorf("failed to extract registry data on %s: %v", cf.IP, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils/mount"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// The strategies of making the registry dir of rootfs writable, from the safest one.
+const (
+	RegistryMountOverlay       = "overlay"
+	RegistryMountFuseOverlayfs = "fuse-overlayfs"
+	// RegistryMountBind copies the registry of the image to the upper dir and bind mounts it, which costs the disk
+	// of the copy, for the hosts whose kernel or fs of the upper dir can not overlay.
+	RegistryMountBind = "bind"
+)
+
+const (
+	// RemoteDetectRegistryMount mounts a probe overlay in dir %[1]s, which is on the fs of the upper dir, and prints
+	// the first strategy that works.
+	RemoteDetectRegistryMount = "rm -rf %[1]s && mkdir -p %[1]s/lower %[1]s/upper %[1]s/work %[1]s/merged && " +
+		"opts=lowerdir=%[1]s/lower,upperdir=%[1]s/upper,workdir=%[1]s/work; " +
+		"if mount -t overlay overlay -o $opts %[1]s/merged 2>/dev/null; then umount %[1]s/merged; echo overlay; " +
+		"elif command -v fuse-overlayfs >/dev/null 2>&1 && fuse-overlayfs -o $opts %[1]s/merged 2>/dev/null; then umount %[1]s/merged; echo fuse-overlayfs; " +
+		"else echo bind; fi; rm -rf %[1]s"
+	RemoteMountRegistryOverlay       = "mount -t overlay overlay -o lowerdir=%[1]s,upperdir=%[2]s,workdir=%[3]s %[1]s"
+	RemoteMountRegistryFuseOverlayfs = "fuse-overlayfs -o lowerdir=%[1]s,upperdir=%[2]s,workdir=%[3]s %[1]s"
+	// the images pushed to the upper dir are not overwritten by the ones of the image.
+	RemoteMountRegistryBind = "cp -an %[1]s/. %[2]s/ && mount --bind %[2]s %[1]s"
+	// RemoteUnmountRegistry unmounts the registry dir %[1]s, and the overlay of the whole rootfs %[2]s the registry
+	// was mounted by before, it does nothing if they are not mounted.
+	RemoteUnmountRegistry = "if mountpoint -q %[1]s; then umount %[1]s; fi && " +
+		"if [ \"$(findmnt -rn -o FSTYPE --mountpoint %[2]s)\" = overlay ]; then umount %[2]s; fi"
+)
+
+var registryMountCmds = map[string]string{
+	RegistryMountOverlay:       RemoteMountRegistryOverlay,
+	RegistryMountFuseOverlayfs: RemoteMountRegistryFuseOverlayfs,
+	RegistryMountBind:          RemoteMountRegistryBind,
+}
+
+// registryMount makes the registry dir of rootfs writable, only the registry dir is mounted so the rest of rootfs
+// is left alone to the other writers.
+type registryMount struct {
+	rootfs string
+	// target is the registry dir of rootfs, the lower dir of the overlay.
+	target string
+	upper  string
+	work   string
+}
+
+func (k *KubeadmRuntime) registryMount(cf *RegistryConfig) registryMount {
+	upper, work := cf.mountDirs()
+	return registryMount{
+		rootfs: k.getRootfs(),
+		target: filepath.Join(k.getRootfs(), registryDataDir),
+		// the registry dir in upper keeps the layout of the data dir of the overlay of the whole rootfs.
+		upper: filepath.Join(upper, registryDataDir),
+		work:  work,
+	}
+}
+
+func (m registryMount) unmountCmd() string {
+	return fmt.Sprintf(RemoteUnmountRegistry, m.target, m.rootfs)
+}
+
+// detect returns the safest strategy the fs of the upper dir on host supports.
+func (m registryMount) detect(client ssh.Interface, host string) (string, error) {
+	out, err := client.Cmd(host, fmt.Sprintf(RemoteDetectRegistryMount, m.work+"-probe"))
+	if err != nil {
+		return "", fmt.Errorf("failed to detect how to mount the registry on %s: %v", host, err)
+	}
+	strategy := lastLine(string(out))
+	if _, ok := registryMountCmds[strategy]; !ok {
+		return "", fmt.Errorf("failed to detect how to mount the registry on %s: unexpected output %s", host, out)
+	}
+	return strategy, nil
+}
+
+// mount mounts the upper dir over the registry dir by the safest strategy of host, the registry dir is unmounted
+// first if it is mounted.
+func (m registryMount) mount(client ssh.Interface, host string) error {
+	if err := client.CmdAsync(host, m.unmountCmd(), fmt.Sprintf("mkdir -p %s %s %s", m.target, m.upper, m.work)); err != nil {
+		return err
+	}
+	strategy, err := m.detect(client, host)
+	if err != nil {
+		return err
+	}
+	if strategy != RegistryMountOverlay {
+		logger.Warn("the kernel or the fs of %s on %s does not support overlay, mount the registry by %s", m.upper, host, strategy)
+	}
+	return client.CmdAsync(host, fmt.Sprintf(registryMountCmds[strategy], m.target, m.upper, m.work))
+}
+
+// check returns whether the registry dir on host is mounted with the upper dir.
+func (m registryMount) check(client ssh.Interface, host string) (bool, string) {
+	fsType, err := mount.GetRemoteMountFSType(client, host, m.target)
+	if err != nil {
+		return false, err.Error()
+	}
+	if fsType == "" {
+		return false, fmt.Sprintf("%s is not mounted", m.target)
+	}
+	if fsType != RegistryMountOverlay {
+		return true, ""
+	}
+	if _, info := mount.GetRemoteMountDetails(client, host, m.target); info != nil && info.Upper != m.upper {
+		return false, fmt.Sprintf("%s is mounted with upper dir %s, not %s", m.target, info.Upper, m.upper)
+	}
+	return true, ""
+}
+
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
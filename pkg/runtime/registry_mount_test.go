@@ -0,0 +1,130 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestKubeadmRuntime_registryMount(t *testing.T) {
+	k := &KubeadmRuntime{Cluster: &v2.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"}}}
+	tests := []struct {
+		name string
+		cf   *RegistryConfig
+		want registryMount
+	}{
+		{
+			name: "tmp upper dir",
+			cf:   &RegistryConfig{},
+			want: registryMount{
+				rootfs: "/var/lib/sealer/data/my-cluster/rootfs",
+				target: "/var/lib/sealer/data/my-cluster/rootfs/registry",
+				upper:  "/var/lib/sealer/tmp/upper/registry",
+				work:   "/var/lib/sealer/tmp/work",
+			},
+		},
+		{
+			// the data dir keeps the layout of the overlay of the whole rootfs, the images pushed before are kept.
+			name: "data dir",
+			cf:   &RegistryConfig{DataDir: "/data/registry"},
+			want: registryMount{
+				rootfs: "/var/lib/sealer/data/my-cluster/rootfs",
+				target: "/var/lib/sealer/data/my-cluster/rootfs/registry",
+				upper:  "/data/registry/upper/registry",
+				work:   "/data/registry/work",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.registryMount(tt.cf); got != tt.want {
+				t.Errorf("registryMount() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSubDir(t *testing.T) {
+	tests := []struct {
+		dir    string
+		parent string
+		want   bool
+	}{
+		{"/var/lib/sealer/tmp", "/var/lib/sealer/tmp", true},
+		{"/var/lib/sealer/tmp/registry", "/var/lib/sealer/tmp", true},
+		{"/var/lib/sealer/tmp/../data", "/var/lib/sealer/tmp", false},
+		{"/var/lib/sealer/tmpdata", "/var/lib/sealer/tmp", false},
+		{"/var/lib/sealer", "/var/lib/sealer/tmp", false},
+		{"/data/registry", "/var/lib/sealer/tmp", false},
+		{"/var/lib/sealer/tmp/..registry", "/var/lib/sealer/tmp", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			if got := isSubDir(tt.dir, tt.parent); got != tt.want {
+				t.Errorf("isSubDir(%s, %s) = %v, want %v", tt.dir, tt.parent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryConfig_mountDirs(t *testing.T) {
+	tests := []struct {
+		dataDir   string
+		wantUpper string
+		wantWork  string
+	}{
+		{"", RegistryMountUpper, RegistryMountWork},
+		{"/data/registry", "/data/registry/upper", "/data/registry/work"},
+	}
+	for _, tt := range tests {
+		upper, work := (&RegistryConfig{DataDir: tt.dataDir}).mountDirs()
+		if upper != tt.wantUpper || work != tt.wantWork {
+			t.Errorf("mountDirs() of %q = %s, %s, want %s, %s", tt.dataDir, upper, work, tt.wantUpper, tt.wantWork)
+		}
+	}
+}
+
+func TestGetRegistryConfig_DataDir(t *testing.T) {
+	tests := []struct {
+		dataDir string
+		want    string
+	}{
+		{"/data/registry/", "/data/registry"},
+		{"data/registry", ""},
+		{"/var/lib/sealer/tmp/registry", ""},
+		{"/var/lib/sealer/tmp/../data", "/var/lib/sealer/data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dataDir, func(t *testing.T) {
+			rootfs := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(rootfs, "etc"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(rootfs, "etc", "registry.yml"), []byte("dataDir: "+tt.dataDir+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := GetRegistryConfig(rootfs, "192.168.0.2").DataDir; got != tt.want {
+				t.Errorf("DataDir = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	RegistryProxyName = "sealer-registry-proxy-%s"
+	// the cache of proxies is kept out of /var/lib/sealer/tmp, which is removed on apply.
+	DefaultRegistryProxyDataDir = "/var/lib/sealer/registry-proxy"
+	DefaultRegistryProxyPort    = 5001
+	DockerHubDomain             = "docker.io"
+	DockerHubRemoteURL          = "https://registry-1.docker.io"
+	// the registry image loaded by init-registry.sh
+	RegistryImage = "registry:2.7.1"
+	// the patched docker of rootfs pulls the images of domain from the mirrors in order, then the domain itself.
+	dockerMirrorRegistriesKey = "mirror-registries"
+	// the debug server of a proxy listens on its port plus the offset on localhost, it serves the expvars of the
+	// registry, including the hits and misses of the proxy.
+	registryProxyDebugPortOffset = 1000
+	RemoteGetRegistryProxyVars   = "curl -s http://127.0.0.1:%d/debug/vars"
+)
+
+// RegistryProxy runs a pull-through cache of an upstream registry beside the sealer registry,
+// so the images not in the CloudImage are cached on the first pull.
+type RegistryProxy struct {
+	// Upstream is the domain of images to cache, like docker.io, quay.io and gcr.io.
+	Upstream string `json:"upstream,omitempty"`
+	// RemoteURL is https://<upstream> by default, and https://registry-1.docker.io for docker.io.
+	RemoteURL string `json:"remoteURL,omitempty"`
+	// Port of the proxy on the registry host, 5001 for the first proxy, 5002 for the second and so on by default.
+	Port     string `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// TTL is passed to proxy.ttl of the registry, cached images expire after it.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// DockerMirror is an item of mirror-registries in daemon.json of the patched docker in rootfs.
+type DockerMirror struct {
+	Domain  string   `json:"domain"`
+	Mirrors []string `json:"mirrors"`
+}
+
+func (r *RegistryConfig) setProxiesDefault() {
+	var proxies []RegistryProxy
+	for _, p := range r.Proxies {
+		if p.Upstream == "" {
+			logger.Warn("upstream of registry proxy %s is empty, ignore it", p.RemoteURL)
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+	r.Proxies = proxies
+	for i := range r.Proxies {
+		p := &r.Proxies[i]
+		if p.RemoteURL == "" {
+			p.RemoteURL = "https://" + p.Upstream
+			if p.Upstream == DockerHubDomain {
+				p.RemoteURL = DockerHubRemoteURL
+			}
+		}
+		if p.Port == "" {
+			p.Port = strconv.Itoa(DefaultRegistryProxyPort + i)
+		}
+	}
+}
+
+func (r *RegistryConfig) proxyDataDir(p RegistryProxy) string {
+	if r.DataDir != "" {
+		return filepath.Join(r.DataDir, "proxy", p.Upstream)
+	}
+	return filepath.Join(DefaultRegistryProxyDataDir, p.Upstream)
+}
+
+// runProxyCmd returns the command to recreate the container of proxy p with the certs of the sealer registry.
+func (r *RegistryConfig) runProxyCmd(rootfs string, p RegistryProxy) string {
+	name := fmt.Sprintf(RegistryProxyName, p.Port)
+	args := []string{
+		"-d", "--restart=always", "--net=host", "--name", name,
+		"-v", fmt.Sprintf("%s:/certs", filepath.Join(rootfs, "certs")),
+		"-v", fmt.Sprintf("%s:/var/lib/registry", r.proxyDataDir(p)),
+		"-e", "REGISTRY_HTTP_ADDR=0.0.0.0:" + p.Port,
+		"-e", "REGISTRY_HTTP_TLS_CERTIFICATE=/certs/sea.hub.crt",
+		"-e", "REGISTRY_HTTP_TLS_KEY=/certs/sea.hub.key",
+		"-e", "REGISTRY_PROXY_REMOTEURL=" + p.RemoteURL,
+		"-e", fmt.Sprintf("REGISTRY_HTTP_DEBUG_ADDR=127.0.0.1:%d", proxyDebugPort(p)),
+	}
+	if p.Username != "" && p.Password != "" {
+		args = append(args, "-e", utils.ShellQuote("REGISTRY_PROXY_USERNAME="+p.Username),
+			"-e", utils.ShellQuote("REGISTRY_PROXY_PASSWORD="+p.Password))
+	}
+	if p.TTL != "" {
+		args = append(args, "-e", "REGISTRY_PROXY_TTL="+p.TTL)
+	}
+	return fmt.Sprintf("mkdir -p %s && %s && docker run %s %s", r.proxyDataDir(p),
+		fmt.Sprintf(RemoteRemoveRegistry, name, name), strings.Join(args, " "), RegistryImage)
+}
+
+func proxyDebugPort(p RegistryProxy) int {
+	port, _ := strconv.Atoi(p.Port)
+	return port + registryProxyDebugPortOffset
+}
+
+// ProxyMetrics are the counters of the blobs or manifests served by a registry proxy since it started.
+type ProxyMetrics struct {
+	Requests    uint64
+	Hits        uint64
+	Misses      uint64
+	BytesPulled uint64
+	BytesPushed uint64
+}
+
+// ProxyStats returns the metrics of the blobs and manifests of the registry proxy of upstream, which are read
+// from the expvars of its debug server on the registry host.
+func (r *RegistryConfig) ProxyStats(client ssh.Interface, upstream string) (blobs, manifests ProxyMetrics, err error) {
+	for _, p := range r.Proxies {
+		if p.Upstream != upstream {
+			continue
+		}
+		out, err := client.Cmd(r.IP, fmt.Sprintf(RemoteGetRegistryProxyVars, proxyDebugPort(p)))
+		if err != nil {
+			return blobs, manifests, fmt.Errorf("failed to get the expvars of registry proxy %s: %v", upstream, err)
+		}
+		var vars struct {
+			Registry struct {
+				Proxy struct {
+					Blobs     ProxyMetrics `json:"blobs"`
+					Manifests ProxyMetrics `json:"manifests"`
+				} `json:"proxy"`
+			} `json:"registry"`
+		}
+		if err = json.Unmarshal(out, &vars); err != nil {
+			return blobs, manifests, fmt.Errorf("failed to decode the expvars of registry proxy %s: %v", upstream, err)
+		}
+		return vars.Registry.Proxy.Blobs, vars.Registry.Proxy.Manifests, nil
+	}
+	return blobs, manifests, fmt.Errorf("registry proxy of %s not found", upstream)
+}
+
+func (r *RegistryConfig) removeProxiesCmd() string {
+	var cmds []string
+	for _, p := range r.Proxies {
+		name := fmt.Sprintf(RegistryProxyName, p.Port)
+		cmds = append(cmds, fmt.Sprintf(RemoteRemoveRegistry, name, name))
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// WriteDockerMirrors adds the proxies to the mirrors of their upstreams in etc/daemon.json of rootfs,
+// after the sealer registry, so the images of CloudImage are still pulled from it first. The mirrors already
+// set for an upstream are kept between them.
+func (r *RegistryConfig) WriteDockerMirrors(rootfs string) error {
+	if len(r.Proxies) == 0 {
+		return nil
+	}
+	return UpdateDockerMirrors(rootfs, func(mirrors []DockerMirror) []DockerMirror {
+		for _, p := range r.Proxies {
+			registry := fmt.Sprintf("https://%s:%s", r.Domain, r.Port)
+			proxy := fmt.Sprintf("https://%s:%s", r.Domain, p.Port)
+			merged := false
+			for i := range mirrors {
+				if mirrors[i].Domain == p.Upstream {
+					mirrors[i].Mirrors = utils.RemoveDuplicate(append(append([]string{registry}, mirrors[i].Mirrors...), proxy))
+					merged = true
+				}
+			}
+			if !merged {
+				// the exact domains are put before the wildcard one.
+				mirrors = append([]DockerMirror{{Domain: p.Upstream, Mirrors: []string{registry, proxy}}}, mirrors...)
+			}
+		}
+		return mirrors
+	})
+}
+
+// UpdateDockerMirrors rewrites the mirror-registries of etc/daemon.json in rootfs by update.
+// It does nothing if rootfs has no daemon.json, like the rootfs of containerd.
+func UpdateDockerMirrors(rootfs string, update func([]DockerMirror) []DockerMirror) error {
+	daemonFile := filepath.Join(rootfs, "etc", "daemon.json")
+	data, err := ioutil.ReadFile(filepath.Clean(daemonFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Debug("%s not found, skip writing docker mirrors", daemonFile)
+			return nil
+		}
+		return err
+	}
+	daemon := map[string]interface{}{}
+	if err := json.Unmarshal(data, &daemon); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", daemonFile, err)
+	}
+
+	var (
+		mirrors []DockerMirror
+		before  []byte
+	)
+	if raw, ok := daemon[dockerMirrorRegistriesKey]; ok {
+		if before, err = json.Marshal(raw); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(before, &mirrors); err != nil {
+			return fmt.Errorf("failed to decode %s of %s: %v", dockerMirrorRegistriesKey, daemonFile, err)
+		}
+	}
+	mirrors = update(mirrors)
+	after, err := json.Marshal(mirrors)
+	if err != nil {
+		return err
+	}
+	// the rootfs is mounted again on every scaling and upgrading, leave it untouched if the mirrors are set.
+	if bytes.Equal(before, after) || before == nil && len(mirrors) == 0 {
+		return nil
+	}
+	daemon[dockerMirrorRegistriesKey] = mirrors
+
+	out, err := json.MarshalIndent(daemon, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(daemonFile, out, common.FileMode0644)
+}
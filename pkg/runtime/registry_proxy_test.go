@@ -0,0 +1,111 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryConfig_WriteDockerMirrors(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "sealer-rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+	daemonFile := filepath.Join(rootfs, "etc", "daemon.json")
+	if err := os.MkdirAll(filepath.Dir(daemonFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	daemon := `{
+  "log-driver": "json-file",
+  "mirror-registries": [
+   {
+    "domain": "quay.io",
+    "mirrors": ["https://quay.example.com"]
+   },
+   {
+    "domain": "*",
+    "mirrors": ["https://sea.hub:5000"]
+   }
+  ]
+}`
+	if err := ioutil.WriteFile(daemonFile, []byte(daemon), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cf := &RegistryConfig{
+		Domain: SeaHub,
+		Port:   "5000",
+		Proxies: []RegistryProxy{
+			{Upstream: "docker.io"},
+			{Upstream: "quay.io", Port: "5100"},
+		},
+	}
+	cf.setProxiesDefault()
+	if cf.Proxies[0].RemoteURL != DockerHubRemoteURL || cf.Proxies[0].Port != "5001" {
+		t.Errorf("unexpected default of docker.io proxy: %+v", cf.Proxies[0])
+	}
+	if cf.Proxies[1].RemoteURL != "https://quay.io" || cf.Proxies[1].Port != "5100" {
+		t.Errorf("unexpected default of quay.io proxy: %+v", cf.Proxies[1])
+	}
+	if err := cf.WriteDockerMirrors(rootfs); err != nil {
+		t.Fatalf("WriteDockerMirrors() error = %v", err)
+	}
+
+	data, err := ioutil.ReadFile(daemonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		LogDriver string         `json:"log-driver"`
+		Mirrors   []DockerMirror `json:"mirror-registries"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []DockerMirror{
+		{Domain: "docker.io", Mirrors: []string{"https://sea.hub:5000", "https://sea.hub:5001"}},
+		{Domain: "quay.io", Mirrors: []string{"https://sea.hub:5000", "https://quay.example.com", "https://sea.hub:5100"}},
+		{Domain: "*", Mirrors: []string{"https://sea.hub:5000"}},
+	}
+	if !reflect.DeepEqual(got.Mirrors, want) {
+		t.Errorf("mirror-registries = %+v, want %+v", got.Mirrors, want)
+	}
+	if got.LogDriver != "json-file" {
+		t.Errorf("log-driver = %s, want json-file", got.LogDriver)
+	}
+
+	// mounting the rootfs again leaves daemon.json untouched.
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(daemonFile, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.WriteDockerMirrors(rootfs); err != nil {
+		t.Fatalf("WriteDockerMirrors() error = %v", err)
+	}
+	if fi, err := os.Stat(daemonFile); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("daemon.json is rewritten with the same mirrors")
+	}
+	again, err := ioutil.ReadFile(daemonFile)
+	if err != nil || string(again) != string(data) {
+		t.Errorf("daemon.json = %s, want %s", again, data)
+	}
+}
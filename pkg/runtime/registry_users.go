@@ -0,0 +1,276 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// RegistryUsersFile keeps the users added by "sealer registry user add" in the cluster work dir, in the format
+	// of htpasswd, so only the bcrypt hashes of their passwords are kept.
+	RegistryUsersFile = "registry_users"
+	// RemoteReplaceHtpasswd renames the new htpasswd %[1]s to %[2]s, which is read by the registry only.
+	RemoteReplaceHtpasswd = "chmod 600 %[1]s && mv -f %[1]s %[2]s"
+	RemoteRemoveHtpasswd  = "rm -f %s"
+)
+
+// Htpasswd is the users of the registry and the bcrypt hashes of their passwords.
+type Htpasswd map[string]string
+
+// ParseHtpasswd parses the lines of user:hash, the invalid lines are skipped.
+func ParseHtpasswd(data string) Htpasswd {
+	h := Htpasswd{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			continue
+		}
+		h[kv[0]] = kv[1]
+	}
+	return h
+}
+
+// Set sets the password of user, the hash is kept if it matches password so the htpasswd is unchanged.
+func (h Htpasswd) Set(user, password string) error {
+	if user == "" || password == "" || strings.Contains(user, ":") {
+		return fmt.Errorf("registry username must not be empty or contain ':', and password must not be empty")
+	}
+	if hash, ok := h[user]; ok && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to generate registry password: %v", err)
+	}
+	h[user] = string(hash)
+	return nil
+}
+
+// Users returns the sorted users of h.
+func (h Htpasswd) Users() []string {
+	var users []string
+	for u := range h {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	return users
+}
+
+func (h Htpasswd) String() string {
+	var sb strings.Builder
+	for _, u := range h.Users() {
+		sb.WriteString(u + ":" + h[u] + "\n")
+	}
+	return sb.String()
+}
+
+func (k *KubeadmRuntime) getRegistryUsersFile() string {
+	return filepath.Join(common.GetClusterWorkDir(k.getClusterName()), RegistryUsersFile)
+}
+
+func (k *KubeadmRuntime) loadRegistryUsers() (Htpasswd, error) {
+	data, err := ioutil.ReadFile(k.getRegistryUsersFile())
+	if os.IsNotExist(err) {
+		return Htpasswd{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseHtpasswd(string(data)), nil
+}
+
+// ReconcileHtpasswd returns the exact users of the registry: the ones of users added by "sealer registry user add"
+// and user of registry.yml if it is set. The hash of user in current, the htpasswd on the registry host, is kept if
+// it matches password, so the htpasswd is unchanged across applies.
+func ReconcileHtpasswd(current, users Htpasswd, user, password string) (Htpasswd, error) {
+	h := Htpasswd{}
+	for u, hash := range users {
+		h[u] = hash
+	}
+	if user == "" || password == "" {
+		return h, nil
+	}
+	if _, ok := h[user]; !ok && current[user] != "" {
+		h[user] = current[user]
+	}
+	if err := h.Set(user, password); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// fetchRegistryHtpasswd fetches the htpasswd on the registry host by sftp, so the hashes are not in the logs of
+// commands, it returns empty if the htpasswd does not exist.
+func (k *KubeadmRuntime) fetchRegistryHtpasswd(client ssh.Interface, cf *RegistryConfig) (string, error) {
+	htpasswdFile := filepath.Join(k.getRootfs(), "etc", DefaultRegistryHtPasswdFile)
+	if !client.IsFileExist(cf.IP, htpasswdFile) {
+		return "", nil
+	}
+	dir, err := ioutil.TempDir("", "sealer-htpasswd")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	local := filepath.Join(dir, DefaultRegistryHtPasswdFile)
+	if err = client.Fetch(cf.IP, local, htpasswdFile); err != nil {
+		return "", fmt.Errorf("failed to fetch the htpasswd of registry: %v", err)
+	}
+	data, err := ioutil.ReadFile(local)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// registryHtpasswd returns the htpasswd on the registry host and the one it should be.
+func (k *KubeadmRuntime) registryHtpasswd(client ssh.Interface, cf *RegistryConfig) (current string, desired Htpasswd, err error) {
+	users, err := k.loadRegistryUsers()
+	if err != nil {
+		return "", nil, err
+	}
+	if current, err = k.fetchRegistryHtpasswd(client, cf); err != nil {
+		return "", nil, err
+	}
+	desired, err = ReconcileHtpasswd(ParseHtpasswd(current), users, cf.Username, cf.Password)
+	return current, desired, err
+}
+
+// checkRegistryHtpasswd returns whether the htpasswd on the registry host has the exact users of the registry.
+func (k *KubeadmRuntime) checkRegistryHtpasswd(client ssh.Interface, cf *RegistryConfig) (bool, string) {
+	current, desired, err := k.registryHtpasswd(client, cf)
+	if err != nil {
+		return false, err.Error()
+	}
+	if current == desired.String() {
+		return true, ""
+	}
+	return false, fmt.Sprintf("the users of %s are %v, not %v", DefaultRegistryHtPasswdFile, ParseHtpasswd(current).Users(), desired.Users())
+}
+
+// applyRegistryHtpasswd writes the exact users of the registry to the htpasswd on the registry host by sftp, so the
+// hashes are not in the command lines, and removes the htpasswd if there is no user. changed is whether the htpasswd
+// is rewritten, which the registry container must be recreated to load.
+func (k *KubeadmRuntime) applyRegistryHtpasswd(client ssh.Interface, cf *RegistryConfig) (changed bool, err error) {
+	current, desired, err := k.registryHtpasswd(client, cf)
+	if err != nil {
+		return false, err
+	}
+	data := desired.String()
+	if current == data {
+		return false, nil
+	}
+	htpasswdFile := filepath.Join(k.getRootfs(), "etc", DefaultRegistryHtPasswdFile)
+	if len(desired) == 0 {
+		return true, client.CmdAsync(cf.IP, fmt.Sprintf(RemoteRemoveHtpasswd, htpasswdFile))
+	}
+
+	f, err := ioutil.TempFile("", "sealer-htpasswd")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(data); err != nil {
+		_ = f.Close()
+		return false, err
+	}
+	if err = f.Close(); err != nil {
+		return false, err
+	}
+	tmp := htpasswdFile + ".tmp"
+	if err = client.Copy(cf.IP, f.Name(), tmp); err != nil {
+		return false, fmt.Errorf("failed to copy the htpasswd of registry: %v", err)
+	}
+	return true, client.CmdAsync(cf.IP, fmt.Sprintf(RemoteReplaceHtpasswd, tmp, htpasswdFile))
+}
+
+// recreateRegistry recreates the registry container to load the new htpasswd.
+func (k *KubeadmRuntime) recreateRegistry(client ssh.Interface, cf *RegistryConfig) error {
+	initRegistry := fmt.Sprintf("cd %s/scripts && sh init-registry.sh %s %s", k.getRootfs(), cf.Port, fmt.Sprintf("%s/registry", k.getRootfs()))
+	return client.CmdAsync(cf.IP, fmt.Sprintf(RemoteRemoveRegistry, RegistryName, RegistryName), initRegistry)
+}
+
+// updateRegistryUsers saves the users changed by update to the users file, and applies them to the registry.
+func updateRegistryUsers(cluster *v2.Cluster, clusterfile string, update func(h Htpasswd) error) error {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	h, err := k.loadRegistryUsers()
+	if err != nil {
+		return err
+	}
+	if err = update(h); err != nil {
+		return err
+	}
+	if err = utils.AtomicWriteFile(k.getRegistryUsersFile(), []byte(h.String()), 0600); err != nil {
+		return err
+	}
+
+	cf := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+	client, err := k.getHostSSHClient(cf.IP)
+	if err != nil {
+		return fmt.Errorf("failed to get registry ssh client: %v", err)
+	}
+	changed, err := k.applyRegistryHtpasswd(client, cf)
+	if err != nil {
+		return err
+	}
+	if changed {
+		logger.Info("recreate %s to load the new users", RegistryName)
+		if err = k.recreateRegistry(client, cf); err != nil {
+			return err
+		}
+	}
+	// the hosts pull by the user of registry.yml, make sure all of them have its credentials.
+	return k.applyRegistryAuthToHosts(cf, append(k.getMasterIPList(), k.getNodesIPList()...))
+}
+
+// AddRegistryUser adds user to the registry of cluster, or changes its password if it exists.
+func AddRegistryUser(cluster *v2.Cluster, clusterfile, user, password string) error {
+	return updateRegistryUsers(cluster, clusterfile, func(h Htpasswd) error {
+		return h.Set(user, password)
+	})
+}
+
+// DeleteRegistryUser deletes user added by AddRegistryUser from the registry of cluster, the user of registry.yml
+// is always kept.
+func DeleteRegistryUser(cluster *v2.Cluster, clusterfile, user string) error {
+	return updateRegistryUsers(cluster, clusterfile, func(h Htpasswd) error {
+		if _, ok := h[user]; !ok {
+			return fmt.Errorf("registry user %s is not found, the user of registry.yml can not be deleted", user)
+		}
+		delete(h, user)
+		return nil
+	})
+}
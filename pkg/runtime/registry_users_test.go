@@ -0,0 +1,66 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestReconcileHtpasswd(t *testing.T) {
+	users := Htpasswd{}
+	if err := users.Set("alice", "alice-passwd"); err != nil {
+		t.Fatal(err)
+	}
+	h, err := ReconcileHtpasswd(Htpasswd{}, users, "admin", "admin-passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(h.Users(), ","); got != "admin,alice" {
+		t.Fatalf("ReconcileHtpasswd() users = %s", got)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(h["admin"]), []byte("admin-passwd")) != nil {
+		t.Errorf("ReconcileHtpasswd() hash of admin does not match its password")
+	}
+
+	// the htpasswd written before is unchanged, the stale and duplicated users are dropped.
+	current := ParseHtpasswd(h.String() + "bob:stale\nadmin:" + h["admin"] + "\n")
+	again, err := ReconcileHtpasswd(current, users, "admin", "admin-passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != h.String() {
+		t.Errorf("ReconcileHtpasswd() again =\n%s\nwant\n%s", again, h)
+	}
+
+	// the password of registry.yml is changed.
+	changed, err := ReconcileHtpasswd(current, users, "admin", "new-passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(changed["admin"]), []byte("new-passwd")) != nil {
+		t.Errorf("ReconcileHtpasswd() hash of admin does not match the new password")
+	}
+
+	none, err := ReconcileHtpasswd(current, Htpasswd{}, "", "")
+	if err != nil || len(none) != 0 {
+		t.Errorf("ReconcileHtpasswd() = %v, %v, want no user", none, err)
+	}
+	if err := users.Set("a:b", "passwd"); err == nil {
+		t.Errorf("Set() accepts user with ':'")
+	}
+}
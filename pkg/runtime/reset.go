@@ -16,17 +16,72 @@ package runtime
 
 import (
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"sync"
 
+	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/utils"
 )
 
 func (k *KubeadmRuntime) reset() error {
-	k.resetNodes(k.getNodesIPList())
-	k.resetMasters(k.getMasterIPList())
+	registry := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+	hosts := append(k.getMasterIPList(), k.getNodesIPList()...)
+	if utils.NotIn(registry.IP, hosts) {
+		hosts = append(hosts, registry.IP)
+	}
+	unreachable := k.checkReachable(hosts)
+	cmds := make(map[string][]string)
+	for _, host := range append(k.getMasterIPList(), k.getNodesIPList()...) {
+		if _, ok := unreachable[host]; ok {
+			cmds[host] = k.resetHostCmds()
+		}
+	}
+	_, registryUnreachable := unreachable[registry.IP]
+	if registryUnreachable {
+		// the registry dir is left mounted if it is not unmounted before the host goes down.
+		cmds[registry.IP] = append(cmds[registry.IP], k.deleteRegistryCmd(registry, fmt.Sprintf("(%s 2>/dev/null || true)", k.registryMount(registry).unmountCmd())))
+	}
+
+	k.resetNodes(removeHosts(k.getNodesIPList(), unreachable))
+	k.resetMasters(removeHosts(k.getMasterIPList(), unreachable))
+	removeLocalAPIServerHost(k.getMaster0IP())
+	k.deregisterDomains()
+	if err := k.recordOrphans(unreachable, cmds); err != nil {
+		return err
+	}
+	if registryUnreachable {
+		return nil
+	}
 	return k.DeleteRegistry()
 }
 
+// removeHosts returns the hosts not in unreachable.
+func removeHosts(hosts []string, unreachable map[string]error) []string {
+	var result []string
+	for _, host := range hosts {
+		if _, ok := unreachable[host]; !ok {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// removeLocalAPIServerHost removes the apiserver domain of master0 added to the local /etc/hosts by GetKubectlAndKubeconfig,
+// it is removed regardless of spec.kubeconfig.etcHosts for the clusters created before the server rewriting.
+func removeLocalAPIServerHost(master0 string) {
+	data, err := ioutil.ReadFile(common.EtcHosts)
+	// the domain may point to another cluster operated from here.
+	if err != nil || !strings.Contains(string(data), fmt.Sprintf("%s %s", master0, common.APIServerDomain)) {
+		return
+	}
+	if err := etchosts.UpdateFile(common.EtcHosts, nil, common.APIServerDomain); err != nil {
+		logger.Warn("failed to remove %s from %s: %v", common.APIServerDomain, common.EtcHosts, err)
+	}
+}
+
 func (k *KubeadmRuntime) resetNodes(nodes []string) {
 	var wg sync.WaitGroup
 	for _, node := range nodes {
@@ -49,15 +104,13 @@ func (k *KubeadmRuntime) resetMasters(nodes []string) {
 	}
 }
 
-func (k *KubeadmRuntime) resetNode(node string) error {
-	ssh, err := k.getHostSSHClient(node)
+func (k *KubeadmRuntime) resetNode(node string) (err error) {
+	ssh, end, err := k.startHostSpan("reset node", node)
 	if err != nil {
 		return fmt.Errorf("reset node failed %v", err)
 	}
-	if err := ssh.CmdAsync(node, fmt.Sprintf(RemoteCleanMasterOrNode, vlogToStr(k.Vlog)),
-		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, k.getAPIServerDomain()),
-		fmt.Sprintf(RemoteRemoveAPIServerEtcHost, getRegistryHost(k.getRootfs(), k.getMaster0IP()))); err != nil {
-		return err
-	}
-	return nil
+	defer func() {
+		end(err)
+	}()
+	return ssh.CmdAsync(node, k.resetHostCmds()...)
 }
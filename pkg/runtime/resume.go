@@ -0,0 +1,64 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/cert"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+// RestoreLocalState fetches the state of the initialized cluster kept on the machine running sealer from master0:
+// the pki, the registry certs and the kubeconfig, so another machine resumes the operation stopped on the cluster.
+// The state already here is kept.
+func RestoreLocalState(cluster *v2.Cluster, clusterfile string) error {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	master0 := k.getMaster0IP()
+	client, err := k.getHostSSHClient(master0)
+	if err != nil {
+		return fmt.Errorf("failed to get master0 ssh client: %v", err)
+	}
+	dirs := []struct {
+		local, remote string
+		required      bool
+	}{
+		{k.getPKIPath(), cert.KubeDefaultCertPath, true},
+		{k.getCertsDir(), filepath.Join(k.getRootfs(), "certs"), false},
+	}
+	for _, d := range dirs {
+		if utils.IsExist(d.local) {
+			continue
+		}
+		if !d.required {
+			if ok, err := client.RemoteDirExist(master0, d.remote); err != nil || !ok {
+				continue
+			}
+		}
+		if err = client.FetchDir(master0, d.local, d.remote, ssh.CopyOptions{}); err != nil {
+			return fmt.Errorf("failed to fetch %s of master0 %s: %v", d.remote, master0, err)
+		}
+		logger.Info("fetched %s of master0 %s to %s", d.remote, master0, d.local)
+	}
+	return k.GetKubectlAndKubeconfig()
+}
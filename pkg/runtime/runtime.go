@@ -38,6 +38,21 @@ type Metadata struct {
 	Variant string `json:"variant"`
 	//KubeVersion is a SemVer constraint specifying the version of Kubernetes required.
 	KubeVersion string `json:"kubeVersion"`
+	// MinKernelVersion is the minimum kernel version required on all hosts, like 4.19.
+	MinKernelVersion string `json:"minKernelVersion,omitempty"`
+	// CgroupVersion is the cgroup version required on all hosts, v1 or v2.
+	CgroupVersion string `json:"cgroupVersion,omitempty"`
+	// CRI is the container runtime installed by the image, hosts running another one are rejected.
+	CRI string `json:"cri,omitempty"`
+	// CRIVersion is the version of the container runtime installed by the image, like 19.03.15, it is checked
+	// against the cgroup version of hosts.
+	CRIVersion string `json:"criVersion,omitempty"`
+	// CRISocket is the CRI socket kubeadm uses, default is the one of CRI, cri-dockerd for docker on kubernetes 1.24+.
+	CRISocket string `json:"criSocket,omitempty"`
+	// UpgradeFrom is a SemVer constraint of the Kubernetes versions the image can upgrade a cluster from.
+	UpgradeFrom string `json:"upgradeFrom,omitempty"`
+	// Dependencies are the images that must be applied to the cluster before the image.
+	Dependencies []string `json:"dependencies,omitempty"`
 }
 
 type KubeadmRuntime struct {
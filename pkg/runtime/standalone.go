@@ -0,0 +1,128 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const standaloneJoinScript = "standalone-join.sh"
+
+// StandaloneJoinOptions is how a node joins the cluster by itself with a bootstrap token.
+type StandaloneJoinOptions struct {
+	Token      string
+	CACertHash string
+	// RegistryCert is the cert of the registry on master0, it is fetched from the registry if empty.
+	RegistryCert []byte
+}
+
+// StandaloneJoin joins the local host to the cluster as a worker without SSH, the masters of cluster are
+// the apiservers to join, and the cluster image must have been mounted on the local host.
+func StandaloneJoin(cluster *v2.Cluster, opts StandaloneJoinOptions) error {
+	if opts.Token == "" || opts.CACertHash == "" {
+		return fmt.Errorf("token and discovery token CA cert hash are required to join standalone")
+	}
+	i, err := newKubeadmRuntime(cluster, "")
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	if err := k.MergeKubeadmConfig(); err != nil {
+		return err
+	}
+	k.setJoinToken(opts.Token)
+	k.setTokenCaCertHash([]string{opts.CACertHash})
+	if err := k.copyRootfsLocally(); err != nil {
+		return err
+	}
+
+	regCert := opts.RegistryCert
+	if len(regCert) == 0 {
+		cf := GetRegistryConfig(k.getRootfs(), k.getMaster0IP())
+		ip, _ := utils.GetSSHHostIPAndPort(cf.IP)
+		logger.Warn("no registry cert is specified, trust the cert served by %s:%s", ip, cf.Port)
+		if regCert, err = fetchServedCert(net.JoinHostPort(ip, cf.Port)); err != nil {
+			return fmt.Errorf("failed to fetch registry cert: %v", err)
+		}
+	}
+	data, err := k.newJoinScriptData(regCert)
+	if err != nil {
+		return err
+	}
+	script, err := renderJoinScript(data)
+	if err != nil {
+		return err
+	}
+	// the script holds the bootstrap token and registry password, WriteFile keeps the mode of an existing file.
+	scriptPath := filepath.Join(k.getRootfs(), standaloneJoinScript)
+	if err := os.RemoveAll(scriptPath); err != nil {
+		return fmt.Errorf("failed to remove join script: %v", err)
+	}
+	if err := ioutil.WriteFile(scriptPath, script, common.FileMode0600); err != nil {
+		return fmt.Errorf("failed to write join script: %v", err)
+	}
+	defer func() {
+		if err := os.Remove(scriptPath); err != nil {
+			logger.Warn("failed to remove join script %s: %v", scriptPath, err)
+		}
+	}()
+	if err := utils.Cmd("bash", scriptPath); err != nil {
+		return fmt.Errorf("failed to join %s standalone: %v", k.getClusterName(), err)
+	}
+	return nil
+}
+
+// copyRootfsLocally copies the mounted cluster image to rootfs, except the registry data which stays on master0.
+func (k *KubeadmRuntime) copyRootfsLocally() error {
+	files, err := ioutil.ReadDir(k.getImageMountDir())
+	if err != nil {
+		return fmt.Errorf("failed to read cluster image: %v", err)
+	}
+	for _, f := range files {
+		if f.Name() == common.RegistryDirName {
+			continue
+		}
+		if err := utils.RecursionCopy(filepath.Join(k.getImageMountDir(), f.Name()), filepath.Join(k.getRootfs(), f.Name())); err != nil {
+			return fmt.Errorf("failed to copy rootfs: %v", err)
+		}
+	}
+	return nil
+}
+
+// fetchServedCert returns the certificate presented by the TLS server at address in PEM.
+func fetchServedCert(address string) ([]byte, error) {
+	// the served cert is not verified, it is what we are going to trust.
+	conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true}) // #nosec
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no cert is served by %s", address)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certs[0].Raw}), nil
+}
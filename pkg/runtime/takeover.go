@@ -0,0 +1,119 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/alibaba/sealer/cert"
+	"github.com/alibaba/sealer/ipvs"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/etchosts"
+	"github.com/alibaba/sealer/pkg/result"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// RemoteInstallSeautil installs seautil of rootfs, which init.sh installs on the hosts created by sealer.
+	RemoteInstallSeautil = `[ -x /usr/bin/seautil ] || cp -f %s/bin/seautil /usr/bin/seautil`
+)
+
+// Takeover installs the sealer bits on the hosts of a running kubeadm cluster which is not created by sealer, without
+// resetting them: the registry on master0, the registry and apiserver domains in the managed block of /etc/hosts,
+// the registry cert, and lvscare on nodes. The rootfs must be sent to the hosts before.
+func Takeover(cluster *v2.Cluster, clusterfile string) error {
+	i, err := newKubeadmRuntime(cluster, clusterfile)
+	if err != nil {
+		return err
+	}
+	k := i.(*KubeadmRuntime)
+	if err := k.MergeKubeadmConfig(); err != nil {
+		return err
+	}
+
+	master0 := k.getMaster0IP()
+	if !utils.IsFileExist(filepath.Join(k.getCertsDir(), SeaHub+".crt")) {
+		if err := cert.GenerateRegistryCert(k.getCertsDir(), SeaHub); err != nil {
+			return err
+		}
+	}
+	if err := k.sendFileToHosts([]string{master0}, k.getCertsDir(), filepath.Join(k.getRootfs(), "certs")); err != nil {
+		return err
+	}
+	logger.Info("start to run registry on %s", GetRegistryConfig(k.getRootfs(), master0).IP)
+	if err := k.ApplyRegistry(); err != nil {
+		return fmt.Errorf("failed to run registry: %v", err)
+	}
+
+	hosts := append(k.getMasterIPList(), k.getNodesIPList()...)
+	if err := k.configProxy(hosts); err != nil {
+		return err
+	}
+	if err := k.sendRegistryCert(hosts); err != nil {
+		return err
+	}
+	cf := GetRegistryConfig(k.getImageMountDir(), master0)
+	registry := getRegistryEntry(k.getImageMountDir(), master0)
+	var masters string
+	for _, master := range k.getMasterIPList() {
+		masters += fmt.Sprintf(" --rs %s:6443", master)
+	}
+
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			entries := []etchosts.Entry{registry}
+			var cmds []string
+			if utils.InList(host, k.getMasterIPList()) {
+				entries = append(entries, etchosts.Entry{IP: utils.GetHostIP(host), Domain: k.getAPIServerDomain()})
+			} else {
+				// the same as joining a node, the apiserver domain resolves to the VIP which lvscare balances to masters.
+				yaml := ipvs.LvsStaticPodYaml(k.getVIP(), k.getMasterIPList(), "")
+				entries = append(entries, etchosts.Entry{IP: k.getVIP(), Domain: k.getAPIServerDomain()})
+				cmds = append(cmds, fmt.Sprintf(RemoteInstallSeautil, k.getRootfs()),
+					fmt.Sprintf(RemoteAddIPVS, k.getVIP(), masters),
+					RemoteStaticPodMkdir,
+					fmt.Sprintf(LvscareStaticPodCmd, yaml, LvscareDefaultStaticPodFileName))
+			}
+			ssh, end, err := k.startHostSpan("takeover host", host)
+			if err != nil {
+				errCh <- result.OnHost(host, fmt.Errorf("failed to take over %s: %v", host, err))
+				return
+			}
+			err = k.applyEtcHosts(ssh, host, entries...)
+			if err == nil {
+				err = ssh.CmdAsync(host, cmds...)
+			}
+			if err == nil {
+				err = applyRegistryAuth(ssh, host, cf)
+			}
+			end(err)
+			if err != nil {
+				errCh <- result.OnHost(host, fmt.Errorf("failed to take over %s: %v", host, err))
+				return
+			}
+			logger.Info("Succeeded in taking over %s", host)
+		}(host)
+	}
+	wg.Wait()
+	return ReadChanError(errCh)
+}
@@ -0,0 +1,122 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+const (
+	// JoinTokenTTL is the ttl of the token created for each join, it is deleted after joining.
+	JoinTokenTTL          = "2h0m0s"
+	JoinTokenDescription  = "created by sealer"
+	RemoteCreateJoinToken = `kubeadm token create --ttl %s --description "%s" --print-join-command -v %d`
+	RemoteUploadCerts     = `kubeadm init phase upload-certs --upload-certs -v %d`
+	RemoteDeleteJoinToken = "kubeadm token delete %s"
+	RemoteListJoinTokens  = "kubeadm token list"
+	// kubeadm token list prints the TTL of expired tokens as <invalid>.
+	expiredTokenTTL = "<invalid>"
+)
+
+// uploadCertificateKey re-uploads the control plane certs, which expire in 2 hours, and sets the new key to join config.
+func (k *KubeadmRuntime) uploadCertificateKey() error {
+	/*
+		I0415 11:45:06.653868   14520 version.go:251] remote version is much newer: v1.21.0; falling back to: stable-1.16
+		[upload-certs] Storing the certificates in Secret "kubeadm-certs" in the "kube-system" Namespace
+		[upload-certs] Using certificate key:
+		8376c70aaaf285b764b3c1a588740728aff493d7c2239684e84a7367c6a437cf
+	*/
+	output := k.CmdToString(k.getMaster0IP(), fmt.Sprintf(RemoteUploadCerts, k.Vlog), "\r\n")
+	logger.Debug("[globals]decodeCertCmd: %s", output)
+	slice := strings.Split(output, "Using certificate key:")
+	if len(slice) != 2 {
+		return fmt.Errorf("get certifacate key failed %s", slice)
+	}
+	key := strings.Replace(slice[1], "\r\n", "", -1)
+	key = strings.Replace(key, "\n", "", -1)
+	k.setInitCertificateKey(key)
+	k.setJoinCertificateKey(key)
+	return nil
+}
+
+// createJoinToken creates a bootstrap token with ttl on master0, and cleans up the expired tokens created by sealer.
+func (k *KubeadmRuntime) createJoinToken(ttl string) error {
+	if err := k.cleanupExpiredJoinTokens(); err != nil {
+		logger.Warn("failed to clean up expired join tokens: %v", err)
+	}
+	ssh, err := k.getHostSSHClient(k.getMaster0IP())
+	if err != nil {
+		return fmt.Errorf("failed to create join token: %v", err)
+	}
+	out, err := ssh.Cmd(k.getMaster0IP(), fmt.Sprintf(RemoteCreateJoinToken, ttl, JoinTokenDescription, k.Vlog))
+	if err != nil {
+		return fmt.Errorf("create kubeadm join token failed %v", err)
+	}
+	if !strings.Contains(string(out), "kubeadm join") {
+		return fmt.Errorf("no join command in the output of creating token: %s", out)
+	}
+	k.decodeMaster0Output(out)
+	return nil
+}
+
+// deleteJoinToken revokes the token created for joining, it is only logged on failure because joining has been done.
+func (k *KubeadmRuntime) deleteJoinToken() {
+	token := k.getJoinToken()
+	if token == "" {
+		return
+	}
+	ssh, err := k.getHostSSHClient(k.getMaster0IP())
+	if err == nil {
+		err = ssh.CmdAsync(k.getMaster0IP(), fmt.Sprintf(RemoteDeleteJoinToken, token))
+	}
+	if err != nil {
+		logger.Warn("failed to delete join token: %v", err)
+	}
+}
+
+func (k *KubeadmRuntime) cleanupExpiredJoinTokens() error {
+	ssh, err := k.getHostSSHClient(k.getMaster0IP())
+	if err != nil {
+		return err
+	}
+	out, err := ssh.Cmd(k.getMaster0IP(), RemoteListJoinTokens)
+	if err != nil {
+		return err
+	}
+	for _, token := range expiredJoinTokens(string(out)) {
+		if err := ssh.CmdAsync(k.getMaster0IP(), fmt.Sprintf(RemoteDeleteJoinToken, token)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiredJoinTokens returns the expired tokens created by sealer in the output of kubeadm token list:
+// TOKEN                     TTL         EXPIRES                USAGES                   DESCRIPTION         EXTRA GROUPS
+// abcdef.0123456789abcdef   <invalid>   2021-04-15T11:45:06Z   authentication,signing   created by sealer   system:bootstrappers:kubeadm:default-node-token
+func expiredJoinTokens(list string) []string {
+	var tokens []string
+	for _, line := range strings.Split(list, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] != expiredTokenTTL || !strings.Contains(line, JoinTokenDescription) {
+			continue
+		}
+		tokens = append(tokens, fields[0])
+	}
+	return tokens
+}
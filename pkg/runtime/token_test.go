@@ -0,0 +1,35 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_expiredJoinTokens(t *testing.T) {
+	list := `TOKEN                     TTL         EXPIRES                USAGES                   DESCRIPTION                                                EXTRA GROUPS
+abcdef.0123456789abcdef   <invalid>   2021-04-15T11:45:06Z   authentication,signing   created by sealer                                          system:bootstrappers:kubeadm:default-node-token
+bcdefg.0123456789abcdef   1h          2021-04-15T12:45:06Z   authentication,signing   created by sealer                                          system:bootstrappers:kubeadm:default-node-token
+cdefgh.0123456789abcdef   <invalid>   2021-04-15T11:45:06Z   authentication,signing   The default bootstrap token generated by 'kubeadm init'.   system:bootstrappers:kubeadm:default-node-token
+`
+	want := []string{"abcdef.0123456789abcdef"}
+	if got := expiredJoinTokens(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("expiredJoinTokens() = %v, want %v", got, want)
+	}
+	if got := expiredJoinTokens(""); got != nil {
+		t.Errorf("expiredJoinTokens() = %v, want nil", got)
+	}
+}
@@ -18,13 +18,15 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	"github.com/alibaba/sealer/pkg/timeout"
 )
 
 const (
 	chmodCmd       = `chmod +x %s/*`
 	mvCmd          = `mv %s/* /usr/bin`
 	getNodeNameCmd = `$(uname -n | tr '[A-Z]' '[a-z]')`
-	drainCmd       = `kubectl drain ` + getNodeNameCmd + ` --ignore-daemonsets`
+	drainCmd       = `kubectl drain ` + getNodeNameCmd + ` --ignore-daemonsets --timeout=%s`
 	upgradeCmd     = `kubeadm upgrade %s`
 	restartCmd     = `systemctl daemon-reload && systemctl restart kubelet`
 	uncordonCmd    = `kubectl uncordon ` + getNodeNameCmd
@@ -34,6 +36,11 @@ func (k *KubeadmRuntime) upgrade() error {
 	var err error
 	binpath := filepath.Join(k.getRootfs(), `bin`)
 
+	// the kubelet patches are written at join only if the former version supports them.
+	err = k.writeKubeletPatches(append(k.getMasterIPList(), k.getNodesIPList()...))
+	if err != nil {
+		return err
+	}
 	err = k.upgradeFirstMaster(k.getMaster0IP(), binpath, k.getKubeVersion())
 	if err != nil {
 		return err
@@ -46,15 +53,15 @@ func (k *KubeadmRuntime) upgrade() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return k.applyKubeletOverrides(append(k.getMasterIPList(), k.getNodesIPList()...))
 }
 
 func (k *KubeadmRuntime) upgradeFirstMaster(IP string, binpath, version string) error {
 	var firstMasterCmds = []string{
 		fmt.Sprintf(chmodCmd, binpath),
 		fmt.Sprintf(mvCmd, binpath),
-		drainCmd,
-		fmt.Sprintf(upgradeCmd, strings.Join([]string{`apply`, version, `-y`}, " ")),
+		fmt.Sprintf(drainCmd, timeout.Of(k.Cluster, timeout.Drain)),
+		fmt.Sprintf(upgradeCmd, strings.Join([]string{`apply`, version, `-y`}, " ")) + k.getPatchesFlag(),
 		restartCmd,
 		uncordonCmd,
 	}
@@ -69,8 +76,8 @@ func (k *KubeadmRuntime) upgradeOtherMasters(IPs []string, binpath string) error
 	var otherMasterCmds = []string{
 		fmt.Sprintf(chmodCmd, binpath),
 		fmt.Sprintf(mvCmd, binpath),
-		drainCmd,
-		fmt.Sprintf(upgradeCmd, `node`),
+		fmt.Sprintf(drainCmd, timeout.Of(k.Cluster, timeout.Drain)),
+		fmt.Sprintf(upgradeCmd, `node`) + k.getPatchesFlag(),
 		restartCmd,
 		uncordonCmd,
 	}
@@ -92,7 +99,7 @@ func (k *KubeadmRuntime) upgradeNodes(IPs []string, binpath string) error {
 	var nodeCmds = []string{
 		fmt.Sprintf(chmodCmd, binpath),
 		fmt.Sprintf(mvCmd, binpath),
-		fmt.Sprintf(upgradeCmd, `node`),
+		fmt.Sprintf(upgradeCmd, `node`) + k.getPatchesFlag(),
 		restartCmd,
 	}
 	var err error
@@ -21,7 +21,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 
@@ -39,7 +38,9 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/reference"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
 	"github.com/alibaba/sealer/utils"
 	"github.com/alibaba/sealer/utils/ssh"
 )
@@ -73,55 +74,28 @@ func VersionCompare(v1, v2 string) bool {
 	return true
 }
 
-func PreInitMaster0(sshClient ssh.Interface, remoteHostIP string) error {
-	err := ssh.WaitSSHReady(sshClient, 6, remoteHostIP)
-	if err != nil {
-		return fmt.Errorf("apply cloud cluster failed: %s", err)
-	}
-	// send sealer and cluster file to remote host
-	sealerPath := utils.ExecutableFilePath()
-	err = sshClient.Copy(remoteHostIP, sealerPath, common.RemoteSealerPath)
-	if err != nil {
-		return fmt.Errorf("send sealer to remote host %s failed:%v", remoteHostIP, err)
-	}
-	err = sshClient.CmdAsync(remoteHostIP, fmt.Sprintf(common.ChmodCmd, common.RemoteSealerPath))
-	if err != nil {
-		return fmt.Errorf("chmod +x sealer on remote host %s failed:%v", remoteHostIP, err)
+func GetKubectlAndKubeconfig(ssh ssh.Interface, host string) error {
+	// fetch the cluster kubeconfig pointing to host, so we can get the current cluster status later
+	if err := fetchKubeconfig(ssh, host, common.DefaultKubeConfigFile()); err != nil {
+		return err
 	}
-	logger.Info("send sealer cmd to %s success !", remoteHostIP)
-
-	// send tmp cluster file
-	err = sshClient.Copy(remoteHostIP, common.TmpClusterfile, common.TmpClusterfile)
-	if err != nil {
-		return fmt.Errorf("send cluster file to remote host %s failed:%v", remoteHostIP, err)
+	if err := PointKubeconfigToHost(common.DefaultKubeConfigFile(), host, common.APIServerDomain); err != nil {
+		return err
 	}
-	logger.Info("send cluster file to %s success !", remoteHostIP)
-
-	// send register login info
-	authFile := common.DefaultRegistryAuthConfigDir()
-	if utils.IsFileExist(authFile) {
-		err = sshClient.Copy(remoteHostIP, authFile, common.DefaultRegistryAuthDir)
-		if err != nil {
-			return fmt.Errorf("failed to send register config %s to remote host %s err: %v", authFile, remoteHostIP, err)
-		}
-		logger.Info("send register info to %s success !", remoteHostIP)
-	} else {
-		logger.Warn("failed to find %s, if image registry is private, please login first", authFile)
-	}
-	return nil
+	return fetchKubectl(ssh, host)
 }
 
-func GetKubectlAndKubeconfig(ssh ssh.Interface, host string) error {
-	// fetch the cluster kubeconfig, and add /etc/hosts "EIP apiserver.cluster.local" so we can get the current cluster status later
-	err := ssh.Fetch(host, path.Join(common.DefaultKubeConfigDir(), "config"), common.KubeAdminConf)
+func fetchKubeconfig(ssh ssh.Interface, host, kubeconfig string) error {
+	err := ssh.Fetch(host, kubeconfig, common.KubeAdminConf)
 	if err != nil {
 		return errors.Wrap(err, "failed to copy kubeconfig")
 	}
-	err = utils.AppendFile(common.EtcHosts, fmt.Sprintf("%s %s", host, common.APIServerDomain))
-	if err != nil {
-		return errors.Wrap(err, "failed to append master IP to etc hosts")
-	}
-	err = ssh.Fetch(host, common.KubectlPath, common.KubectlPath)
+	// it holds the credential of cluster admin.
+	return os.Chmod(kubeconfig, 0600)
+}
+
+func fetchKubectl(ssh ssh.Interface, host string) error {
+	err := ssh.Fetch(host, common.KubectlPath, common.KubectlPath)
 	if err != nil {
 		return errors.Wrap(err, "fetch kubectl failed")
 	}
@@ -154,6 +128,35 @@ func LoadMetadata(rootfs string) (*Metadata, error) {
 	return &md, nil
 }
 
+// CheckDependencies returns an error if any dependency of metadata is not in the images applied to the cluster.
+func CheckDependencies(metadata *Metadata, applied []string) error {
+	if metadata == nil {
+		return nil
+	}
+	appliedNames := map[string]bool{}
+	for _, img := range applied {
+		appliedNames[normalizeImageName(img)] = true
+	}
+	var missing []string
+	for _, dep := range metadata.Dependencies {
+		if !appliedNames[normalizeImageName(dep)] {
+			missing = append(missing, dep)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the dependencies %s of CloudImage are not applied to the cluster, apply them first", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func normalizeImageName(name string) string {
+	named, err := reference.ParseToNamed(name)
+	if err != nil {
+		return name
+	}
+	return named.Raw()
+}
+
 func GetCloudImagePlatform(rootfs string) (cp ocispecs.Platform) {
 	// current we only support build on linux
 	cp = ocispecs.Platform{
@@ -162,7 +165,7 @@ func GetCloudImagePlatform(rootfs string) (cp ocispecs.Platform) {
 		Variant:      "",
 	}
 	meta, err := LoadMetadata(rootfs)
-	if err != nil {
+	if err != nil || meta == nil {
 		return
 	}
 
@@ -175,15 +178,28 @@ func GetCloudImagePlatform(rootfs string) (cp ocispecs.Platform) {
 	return
 }
 
-func ReadChanError(errors chan error) (err error) {
+// ReadChanError combines the errors in errCh, the hosts recorded by result.OnHost are kept
+// to report where the phase failed.
+func ReadChanError(errCh chan error) error {
+	var (
+		err   error
+		hosts []string
+	)
 	for {
-		if len(errors) == 0 {
+		if len(errCh) == 0 {
 			break
 		}
-		err = fmt.Errorf("%v,%v", err, <-errors)
+		e := <-errCh
+		var re *result.Error
+		if errors.As(e, &re) {
+			hosts = append(hosts, re.Hosts...)
+		}
+		err = fmt.Errorf("%v,%v", err, e)
 	}
-
-	return
+	if err != nil && len(hosts) != 0 {
+		return &result.Error{Hosts: hosts, Err: err}
+	}
+	return err
 }
 
 func GetMasterIPList(cluster *v2.Cluster) (masters []string) {
@@ -260,11 +276,17 @@ func DecodeCRDFromString(config string, kind string) (interface{}, error) {
 	return DecodeCRDFromReader(strings.NewReader(config), kind)
 }
 
+// TypeConversion decodes raw as kind, the kubeadm configs of any version are decoded as v1beta2.
 func TypeConversion(raw []byte, kind string) (i interface{}, err error) {
 	i = typeConversion(kind)
 	if i == nil {
 		return nil, fmt.Errorf("not found type %s from %s", kind, string(raw))
 	}
+	if isKubeadmKind(kind) {
+		if raw, err = kubeadmConfigToV1beta2(raw); err != nil {
+			return nil, err
+		}
+	}
 	return i, yaml.Unmarshal(raw, i)
 }
 
@@ -0,0 +1,45 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/sealer/pkg/result"
+)
+
+func TestReadChanError(t *testing.T) {
+	errCh := make(chan error, 3)
+	if err := ReadChanError(errCh); err != nil {
+		t.Errorf("ReadChanError() of empty channel = %v, want nil", err)
+	}
+
+	errCh <- result.OnHost("192.168.0.2", fmt.Errorf("failed on 192.168.0.2"))
+	errCh <- fmt.Errorf("failed without host")
+	errCh <- result.OnHost("192.168.0.3", fmt.Errorf("failed on 192.168.0.3"))
+	err := result.Wrap(result.CategoryRuntime, "JoinNodes", ReadChanError(errCh))
+	r := result.New(nil, time.Now(), err)
+	if !reflect.DeepEqual(r.FailedHosts, []string{"192.168.0.2", "192.168.0.3"}) || r.Phase != "JoinNodes" {
+		t.Errorf("New() = %+v, want the hosts of the errors in channel", r)
+	}
+
+	errCh <- fmt.Errorf("failed without host")
+	if r := result.New(nil, time.Now(), ReadChanError(errCh)); len(r.FailedHosts) != 0 {
+		t.Errorf("FailedHosts = %v, want empty", r.FailedHosts)
+	}
+}
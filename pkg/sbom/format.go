@@ -0,0 +1,273 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	FormatJSON      = "json"
+	FormatSPDX      = "spdx-json"
+	FormatCycloneDX = "cyclonedx-json"
+
+	noAssertion = "NOASSERTION"
+
+	// imageRegistry serves the images cached in the registry of rootfs in the cluster.
+	imageRegistry = "sea.hub:5000"
+)
+
+// Encode converts sbom to the given format, json is the sealer native format.
+func Encode(s *SBOM, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON, "":
+		return json.MarshalIndent(s, "", "  ")
+	case FormatSPDX:
+		return json.MarshalIndent(toSPDX(s), "", "  ")
+	case FormatCycloneDX:
+		return json.MarshalIndent(toCycloneDX(s), "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format %s, must be one of %s,%s,%s", format, FormatJSON, FormatSPDX, FormatCycloneDX)
+	}
+}
+
+// purl returns the package url of container image, empty for other components. The version is
+// the digest, or the tag without digest, the registry goes to the repository_url qualifier, like
+// pkg:docker/library/nginx@sha256%3Aabc?repository_url=sea.hub%3A5000.
+func purl(c Component) string {
+	if c.Type != ContainerImage {
+		return ""
+	}
+	registry, repo := imageRegistry, c.Name
+	if i := strings.IndexRune(repo, '/'); i > 0 {
+		if domain := repo[:i]; strings.ContainsAny(domain, ".:") || domain == "localhost" {
+			registry, repo = domain, repo[i+1:]
+		}
+	}
+	segments := strings.Split(strings.ToLower(repo), "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	version := c.Digest
+	if version == "" {
+		version = c.Version
+	}
+	p := "pkg:docker/" + strings.Join(segments, "/")
+	if version != "" {
+		p += "@" + url.QueryEscape(version)
+	}
+	return p + "?repository_url=" + url.QueryEscape(registry)
+}
+
+// splitDigest returns the algorithm and hex of digest like sha256:xxx.
+func splitDigest(dgst string) (string, string, bool) {
+	d, err := digest.Parse(dgst)
+	if err != nil {
+		return "", "", false
+	}
+	return strings.ToUpper(d.Algorithm().String()), d.Hex(), true
+}
+
+type spdxDocument struct {
+	SPDXVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SPDXID            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	Packages          []spdxPackage    `json:"packages"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+	Comment  string   `json:"comment,omitempty"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	PrimaryPurpose   string            `json:"primaryPackagePurpose,omitempty"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+func toSPDX(s *SBOM) spdxDocument {
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.2",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        s.Image,
+		CreationInfo: spdxCreationInfo{
+			Created:  s.Provenance.BuildTime.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: sealer-" + s.Provenance.BuilderVersion},
+			Comment:  provenanceComment(s.Provenance),
+		},
+	}
+	data, _ := json.Marshal(s)
+	doc.DocumentNamespace = fmt.Sprintf("https://sealer.cool/spdxdocs/%s-%s", strings.NewReplacer("/", "-", ":", "-").Replace(s.Image), digest.FromBytes(data).Hex())
+
+	for i, c := range s.Components {
+		pkg := spdxPackage{
+			Name:             c.Name,
+			SPDXID:           fmt.Sprintf("SPDXRef-%s-%d", c.Type, i),
+			VersionInfo:      c.Version,
+			DownloadLocation: noAssertion,
+			SourceInfo:       c.Path,
+		}
+		switch c.Type {
+		case Binary:
+			pkg.PrimaryPurpose = "APPLICATION"
+		case Chart:
+			pkg.PrimaryPurpose = "INSTALL"
+		case ContainerImage:
+			pkg.PrimaryPurpose = "CONTAINER"
+		}
+		if alg, hex, ok := splitDigest(c.Digest); ok {
+			pkg.Checksums = []spdxChecksum{{Algorithm: alg, ChecksumValue: hex}}
+		}
+		if p := purl(c); p != "" {
+			pkg.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: p}}
+		}
+		doc.Packages = append(doc.Packages, pkg)
+	}
+	return doc
+}
+
+func provenanceComment(p Provenance) string {
+	var items []string
+	if p.BaseImage != "" {
+		items = append(items, "baseImage="+p.BaseImage)
+	}
+	if p.KubefileDigest != "" {
+		items = append(items, "kubefile="+p.KubefileDigest)
+	}
+	if p.Platform != "" {
+		items = append(items, "platform="+p.Platform)
+	}
+	if len(p.BuildArgs) > 0 {
+		items = append(items, "buildArgs="+strings.Join(p.BuildArgs, ","))
+	}
+	return strings.Join(items, " ")
+}
+
+type cycloneDXDocument struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp  string              `json:"timestamp"`
+	Tools      []cycloneDXTool     `json:"tools"`
+	Component  cycloneDXComponent  `json:"component"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func toCycloneDX(s *SBOM) cycloneDXDocument {
+	tool := cycloneDXTool{Vendor: "alibaba", Name: "sealer", Version: s.Provenance.BuilderVersion}
+	doc := cycloneDXDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: s.Provenance.BuildTime.UTC().Format(time.RFC3339),
+			Tools:     []cycloneDXTool{tool},
+			Component: cycloneDXComponent{Type: "container", Name: s.Image},
+		},
+	}
+	for name, value := range map[string]string{
+		"sealer:baseImage": s.Provenance.BaseImage,
+		"sealer:kubefile":  s.Provenance.KubefileDigest,
+		"sealer:platform":  s.Provenance.Platform,
+		"sealer:buildArgs": strings.Join(s.Provenance.BuildArgs, ","),
+	} {
+		if value != "" {
+			doc.Metadata.Properties = append(doc.Metadata.Properties, cycloneDXProperty{Name: name, Value: value})
+		}
+	}
+	sort.Slice(doc.Metadata.Properties, func(i, j int) bool {
+		return doc.Metadata.Properties[i].Name < doc.Metadata.Properties[j].Name
+	})
+
+	for _, c := range s.Components {
+		comp := cycloneDXComponent{Name: c.Name, Version: c.Version, PURL: purl(c)}
+		switch c.Type {
+		case ContainerImage:
+			comp.Type = "container"
+		default:
+			comp.Type = "application"
+		}
+		if alg, hex, ok := splitDigest(c.Digest); ok {
+			// CycloneDX spells algorithms with a dash, like SHA-256.
+			comp.Hashes = []cycloneDXHash{{Alg: strings.Replace(alg, "SHA", "SHA-", 1), Content: hex}}
+		}
+		comp.Properties = []cycloneDXProperty{{Name: "sealer:type", Value: string(c.Type)}}
+		if c.Path != "" {
+			comp.Properties = append(comp.Properties, cycloneDXProperty{Name: "sealer:path", Value: c.Path})
+		}
+		doc.Components = append(doc.Components, comp)
+	}
+	return doc
+}
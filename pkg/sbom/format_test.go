@@ -0,0 +1,158 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testDigest = "sha256:2d8a6ba16e8a8d6b7a5e6b4f5c1c7d1d8c3b4e6f7a8b9c0d1e2f3a4b5c6d7e8f"
+
+func testSBOM() *SBOM {
+	return &SBOM{
+		Image: "registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8",
+		Provenance: Provenance{
+			BuilderVersion: "v0.5.0",
+			BaseImage:      "kubernetes:v1.19.8-base",
+			KubefileDigest: "sha256:kubefile",
+			BuildArgs:      []string{"Version=v1.19.8"},
+			Platform:       "linux/amd64",
+			BuildTime:      time.Date(2021, 8, 1, 10, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+		},
+		Components: []Component{
+			{Type: Binary, Name: "kubectl", Path: "bin/kubectl", Digest: testDigest},
+			{Type: Chart, Name: "calico", Version: "v3.19.1", Path: "charts/calico"},
+			{Type: ContainerImage, Name: "library/nginx", Version: "1.19", Digest: testDigest},
+		},
+	}
+}
+
+func TestPurl(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Component
+		want string
+	}{
+		{"digest", Component{Type: ContainerImage, Name: "library/nginx", Version: "1.19", Digest: testDigest},
+			"pkg:docker/library/nginx@sha256%3A" + testDigest[len("sha256:"):] + "?repository_url=sea.hub%3A5000"},
+		{"tag", Component{Type: ContainerImage, Name: "sealer-io/kube-apiserver", Version: "v1.19.8"},
+			"pkg:docker/sealer-io/kube-apiserver@v1.19.8?repository_url=sea.hub%3A5000"},
+		{"registry", Component{Type: ContainerImage, Name: "quay.io/Coreos/etcd", Version: "v3.4.13"},
+			"pkg:docker/coreos/etcd@v3.4.13?repository_url=quay.io"},
+		{"registry with port", Component{Type: ContainerImage, Name: "localhost:5000/pause", Version: "3.2"},
+			"pkg:docker/pause@3.2?repository_url=localhost%3A5000"},
+		{"no version", Component{Type: ContainerImage, Name: "pause"}, "pkg:docker/pause?repository_url=sea.hub%3A5000"},
+		{"binary", Component{Type: Binary, Name: "kubectl", Digest: testDigest}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := purl(tt.c); got != tt.want {
+				t.Errorf("purl() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode_SPDX(t *testing.T) {
+	data, err := Encode(testSBOM(), FormatSPDX)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var doc spdxDocument
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SPDXVersion != "SPDX-2.2" || doc.SPDXID != "SPDXRef-DOCUMENT" || doc.Name != testSBOM().Image {
+		t.Errorf("unexpected document header %+v", doc)
+	}
+	wantInfo := spdxCreationInfo{
+		Created:  "2021-08-01T02:00:00Z",
+		Creators: []string{"Tool: sealer-v0.5.0"},
+		Comment:  "baseImage=kubernetes:v1.19.8-base kubefile=sha256:kubefile platform=linux/amd64 buildArgs=Version=v1.19.8",
+	}
+	if !reflect.DeepEqual(doc.CreationInfo, wantInfo) {
+		t.Errorf("creationInfo = %+v, want %+v", doc.CreationInfo, wantInfo)
+	}
+	again, _ := Encode(testSBOM(), FormatSPDX)
+	if string(again) != string(data) {
+		t.Errorf("expected the same document of the same sbom")
+	}
+
+	hex := testDigest[len("sha256:"):]
+	want := []spdxPackage{
+		{Name: "kubectl", SPDXID: "SPDXRef-binary-0", DownloadLocation: noAssertion, PrimaryPurpose: "APPLICATION",
+			SourceInfo: "bin/kubectl", Checksums: []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: hex}}},
+		{Name: "calico", SPDXID: "SPDXRef-chart-1", VersionInfo: "v3.19.1", DownloadLocation: noAssertion,
+			PrimaryPurpose: "INSTALL", SourceInfo: "charts/calico"},
+		{Name: "library/nginx", SPDXID: "SPDXRef-image-2", VersionInfo: "1.19", DownloadLocation: noAssertion,
+			PrimaryPurpose: "CONTAINER", Checksums: []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: hex}},
+			ExternalRefs: []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl",
+				ReferenceLocator: "pkg:docker/library/nginx@sha256%3A" + hex + "?repository_url=sea.hub%3A5000"}}},
+	}
+	if !reflect.DeepEqual(doc.Packages, want) {
+		t.Errorf("packages = %+v, want %+v", doc.Packages, want)
+	}
+}
+
+func TestEncode_CycloneDX(t *testing.T) {
+	data, err := Encode(testSBOM(), FormatCycloneDX)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	var doc cycloneDXDocument
+	if err = json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	wantMeta := cycloneDXMetadata{
+		Timestamp: "2021-08-01T02:00:00Z",
+		Tools:     []cycloneDXTool{{Vendor: "alibaba", Name: "sealer", Version: "v0.5.0"}},
+		Component: cycloneDXComponent{Type: "container", Name: testSBOM().Image},
+		Properties: []cycloneDXProperty{
+			{Name: "sealer:baseImage", Value: "kubernetes:v1.19.8-base"},
+			{Name: "sealer:buildArgs", Value: "Version=v1.19.8"},
+			{Name: "sealer:kubefile", Value: "sha256:kubefile"},
+			{Name: "sealer:platform", Value: "linux/amd64"},
+		},
+	}
+	if doc.BOMFormat != "CycloneDX" || doc.SpecVersion != "1.4" || doc.Version != 1 {
+		t.Errorf("unexpected document header %+v", doc)
+	}
+	if !reflect.DeepEqual(doc.Metadata, wantMeta) {
+		t.Errorf("metadata = %+v, want %+v", doc.Metadata, wantMeta)
+	}
+
+	hex := testDigest[len("sha256:"):]
+	want := []cycloneDXComponent{
+		{Type: "application", Name: "kubectl", Hashes: []cycloneDXHash{{Alg: "SHA-256", Content: hex}},
+			Properties: []cycloneDXProperty{{Name: "sealer:type", Value: "binary"}, {Name: "sealer:path", Value: "bin/kubectl"}}},
+		{Type: "application", Name: "calico", Version: "v3.19.1",
+			Properties: []cycloneDXProperty{{Name: "sealer:type", Value: "chart"}, {Name: "sealer:path", Value: "charts/calico"}}},
+		{Type: "container", Name: "library/nginx", Version: "1.19", Hashes: []cycloneDXHash{{Alg: "SHA-256", Content: hex}},
+			PURL:       "pkg:docker/library/nginx@sha256%3A" + hex + "?repository_url=sea.hub%3A5000",
+			Properties: []cycloneDXProperty{{Name: "sealer:type", Value: "image"}}},
+	}
+	if !reflect.DeepEqual(doc.Components, want) {
+		t.Errorf("components = %+v, want %+v", doc.Components, want)
+	}
+}
+
+func TestEncode_unsupported(t *testing.T) {
+	if _, err := Encode(testSBOM(), "spdx-tag-value"); err == nil {
+		t.Errorf("expected error of unsupported format")
+	}
+}
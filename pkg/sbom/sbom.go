@@ -0,0 +1,227 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+type ComponentType string
+
+const (
+	Binary         ComponentType = "binary"
+	Chart          ComponentType = "chart"
+	ContainerImage ComponentType = "image"
+)
+
+const (
+	binDir    = "bin"
+	chartsDir = "charts"
+	chartFile = "Chart.yaml"
+	// the layout of docker registry storage under rootfs/registry.
+	registryRepositoriesDir = "docker/registry/v2/repositories"
+	registryManifestsDir    = "_manifests"
+	registryTagsDir         = "tags"
+)
+
+// Component is a binary, helm chart or container image bundled in a CloudImage.
+type Component struct {
+	Type    ComponentType `json:"type"`
+	Name    string        `json:"name"`
+	Version string        `json:"version,omitempty"`
+	// Path is relative to rootfs.
+	Path   string `json:"path,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// Provenance records how the image was built, the values of build args are not
+// recorded as they may be secrets.
+type Provenance struct {
+	BuilderVersion string    `json:"builderVersion"`
+	BaseImage      string    `json:"baseImage,omitempty"`
+	KubefileDigest string    `json:"kubefileDigest,omitempty"`
+	BuildArgs      []string  `json:"buildArgs,omitempty"`
+	Platform       string    `json:"platform,omitempty"`
+	BuildTime      time.Time `json:"buildTime"`
+}
+
+type SBOM struct {
+	Image      string      `json:"image"`
+	Provenance Provenance  `json:"provenance"`
+	Components []Component `json:"components"`
+}
+
+// Generate lists the binaries under bin, the helm charts under charts and
+// the container images cached in the registry of rootfs.
+func Generate(rootfs string) ([]Component, error) {
+	var components []Component
+	for _, list := range []func(string) ([]Component, error){listBinaries, listCharts, ListImages} {
+		res, err := list(rootfs)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, res...)
+	}
+	return components, nil
+}
+
+func listBinaries(rootfs string) ([]Component, error) {
+	var components []Component
+	err := walkIfExist(filepath.Join(rootfs, binDir), func(path string, info os.FileInfo) error {
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		dgst, err := fileDigest(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(rootfs, path)
+		components = append(components, Component{Type: Binary, Name: info.Name(), Path: rel, Digest: dgst})
+		return nil
+	})
+	return components, err
+}
+
+func listCharts(rootfs string) ([]Component, error) {
+	var components []Component
+	err := walkIfExist(filepath.Join(rootfs, chartsDir), func(path string, info os.FileInfo) error {
+		if info.IsDir() || info.Name() != chartFile {
+			return nil
+		}
+		data, err := ioutil.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return err
+		}
+		var chart struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if err = yaml.Unmarshal(data, &chart); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		rel, _ := filepath.Rel(rootfs, filepath.Dir(path))
+		components = append(components, Component{Type: Chart, Name: chart.Name, Version: chart.Version, Path: rel})
+		return nil
+	})
+	return components, err
+}
+
+// ListImages lists the container images cached in the registry of rootfs, it reads
+// the repositories/<repo>/_manifests/tags/<tag>/current/link of registry storage.
+func ListImages(rootfs string) ([]Component, error) {
+	var (
+		components []Component
+		repoRoot   = filepath.Join(rootfs, common.RegistryDirName, registryRepositoriesDir)
+	)
+	err := walkIfExist(repoRoot, func(path string, info os.FileInfo) error {
+		if !info.IsDir() || info.Name() != registryManifestsDir {
+			return nil
+		}
+		repo, _ := filepath.Rel(repoRoot, filepath.Dir(path))
+		tags, err := ioutil.ReadDir(filepath.Join(path, registryTagsDir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		for _, tag := range tags {
+			link, err := ioutil.ReadFile(filepath.Join(path, registryTagsDir, tag.Name(), "current", "link"))
+			if err != nil {
+				continue
+			}
+			components = append(components, Component{
+				Type:    ContainerImage,
+				Name:    filepath.ToSlash(repo),
+				Version: tag.Name(),
+				Digest:  strings.TrimSpace(string(link)),
+			})
+		}
+		return filepath.SkipDir
+	})
+	return components, err
+}
+
+func walkIfExist(root string, fn func(path string, info os.FileInfo) error) error {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return fn(path, info)
+	})
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SetToImage saves sbom to the annotation of image.
+func SetToImage(s *SBOM, image *v1.Image) error {
+	sort.SliceStable(s.Components, func(i, j int) bool {
+		if s.Components[i].Type != s.Components[j].Type {
+			return s.Components[i].Type < s.Components[j].Type
+		}
+		return s.Components[i].Name < s.Components[j].Name
+	})
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if image.Annotations == nil {
+		image.Annotations = make(map[string]string)
+	}
+	image.Annotations[common.ImageAnnotationForSBOM] = string(data)
+	return nil
+}
+
+// LoadFromImage returns the sbom saved in image annotation.
+func LoadFromImage(image *v1.Image) (*SBOM, error) {
+	data, ok := image.Annotations[common.ImageAnnotationForSBOM]
+	if !ok {
+		return nil, fmt.Errorf("no SBOM found in image %s, it may be built by an older sealer", image.Name)
+	}
+	s := &SBOM{}
+	if err := json.Unmarshal([]byte(data), s); err != nil {
+		return nil, fmt.Errorf("failed to decode SBOM of image %s: %v", image.Name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,62 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaling
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reason tells why a ScaleRequest is rejected.
+type Reason string
+
+const (
+	ReasonNoHosts             Reason = "NoHosts"
+	ReasonInvalidArgument     Reason = "InvalidArgument"
+	ReasonUnsupportedProvider Reason = "UnsupportedProvider"
+	ReasonCountNotSupported   Reason = "CountNotSupported"
+	ReasonIPNotInSubnet       Reason = "IPNotInSubnet"
+	ReasonHostExists          Reason = "HostExists"
+	ReasonHostNotFound        Reason = "HostNotFound"
+	ReasonNotInIPPool         Reason = "NotInIPPool"
+	ReasonCountExceeded       Reason = "CountExceeded"
+	ReasonDeleteMaster0       Reason = "DeleteMaster0"
+	ReasonInvalidPromotion    Reason = "InvalidPromotion"
+	ReasonBelowHASize         Reason = "BelowHASize"
+)
+
+// Error is a ScaleRequest rejected for Reason, Hosts are the hosts of the request it is about.
+type Error struct {
+	Reason  Reason
+	Hosts   []string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(reason Reason, hosts []string, format string, a ...interface{}) error {
+	return &Error{Reason: reason, Hosts: hosts, Message: fmt.Sprintf(format, a...)}
+}
+
+// ReasonOf returns the reason of the Error in the chain of err, empty if there is none.
+func ReasonOf(err error) Reason {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return ""
+}
@@ -0,0 +1,275 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaling
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/infra/aliyun"
+	"github.com/alibaba/sealer/logger"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// MinHAMasters is the number of masters a highly available cluster keeps, the quorum of etcd survives the
+// failure of one master.
+const MinHAMasters = 3
+
+// Hosts of a role are scaled either by Count, the number of hosts the cloud provider provisions or releases,
+// or by IPList.
+type Hosts struct {
+	Count  int
+	IPList []string
+}
+
+func (h Hosts) isEmpty() bool {
+	return h.Count == 0 && len(h.IPList) == 0
+}
+
+func (h Hosts) size() int {
+	return h.Count + len(h.IPList)
+}
+
+// ScaleRequest is the masters and nodes to join to or delete from a cluster, from the flags of sealer join
+// and delete, or the node group API of the autoscaler.
+type ScaleRequest struct {
+	// Action is common.JoinSubCmd or common.DeleteSubCmd.
+	Action  string
+	Masters Hosts
+	Nodes   Hosts
+	// Promote is the master promoted to master0 before master0 is deleted.
+	Promote string
+	// Force deletes the masters even if fewer than MinHAMasters are left.
+	Force bool
+}
+
+// NewScaleRequest parses the masters and nodes of args, each one is a number or an ip list like
+// 192.168.0.2,192.168.0.3 or 192.168.0.2-192.168.0.5.
+func NewScaleRequest(action string, args *common.RunArgs) (*ScaleRequest, error) {
+	masters, err := parseHosts(common.MASTER, args.Masters)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := parseHosts(common.NODE, args.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	return &ScaleRequest{Action: action, Masters: masters, Nodes: nodes, Promote: args.Promote, Force: args.Force}, nil
+}
+
+func parseHosts(role, arg string) (Hosts, error) {
+	if arg == "" {
+		return Hosts{}, nil
+	}
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n <= 0 {
+			return Hosts{}, newError(ReasonInvalidArgument, nil, "the number of %ss must be positive, got %d", role, n)
+		}
+		return Hosts{Count: n}, nil
+	}
+	ipRange := arg
+	if err := utils.AssemblyIPList(&ipRange); err != nil {
+		return Hosts{}, newError(ReasonInvalidArgument, nil, "invalid %ss %s: %v", role, arg, err)
+	}
+	var ipList []string
+	for _, ip := range strings.Split(ipRange, ",") {
+		if ip == "" || utils.InList(ip, ipList) {
+			continue
+		}
+		if !utils.CheckIP(ip) {
+			return Hosts{}, newError(ReasonInvalidArgument, []string{ip},
+				"%s in %ss is not an ip, set it to a number or an ip list like 192.168.0.2,192.168.0.3 or 192.168.0.2-192.168.0.5", ip, role)
+		}
+		ipList = append(ipList, ip)
+	}
+	return Hosts{IPList: ipList}, nil
+}
+
+// Cluster is the hosts of the cluster a ScaleRequest is validated against.
+type Cluster struct {
+	Provider string
+	// Masters and Nodes are the hosts of the roles, the first master is master0.
+	Masters []string
+	Nodes   []string
+	// MasterPool and NodePool are the baremetal hosts beside the ones provisioned by the cloud provider.
+	MasterPool []string
+	NodePool   []string
+	// Subnets are the CIDRs the hosts joined by ip must be in, any ip is allowed if it is empty.
+	Subnets []string
+}
+
+// ClusterOfV1 returns the hosts of the v1 cluster, the hosts joined to the ip pool of ALI_CLOUD must be in its VPC.
+func ClusterOfV1(cluster *v1.Cluster) *Cluster {
+	c := &Cluster{
+		Provider:   cluster.Spec.Provider,
+		Masters:    cluster.Spec.Masters.IPList,
+		Nodes:      cluster.Spec.Nodes.IPList,
+		MasterPool: cluster.Spec.Masters.IPPool,
+		NodePool:   cluster.Spec.Nodes.IPPool,
+	}
+	if c.Provider == common.AliCloud {
+		c.Subnets = []string{aliyun.CidrBlock}
+	}
+	return c
+}
+
+// ClusterOfV2 returns the hosts of the v2 cluster, which are baremetal ones.
+func ClusterOfV2(cluster *v2.Cluster) *Cluster {
+	return &Cluster{
+		Provider: common.BAREMETAL,
+		Masters:  cluster.GetMasterIPList(),
+		Nodes:    cluster.GetNodeIPList(),
+	}
+}
+
+type validator func(c *Cluster, r *ScaleRequest) error
+
+var validators = map[string]validator{
+	common.BAREMETAL: validateBaremetal,
+	common.AliCloud:  validateInfra,
+	common.CONTAINER: validateInfra,
+}
+
+// Validate checks r against cluster by the rules of its provider and of all providers, it returns an *Error
+// telling what to change if r is rejected.
+func (r *ScaleRequest) Validate(cluster *Cluster) error {
+	if r.Masters.isEmpty() && r.Nodes.isEmpty() {
+		return newError(ReasonNoHosts, nil, "no masters or nodes to %s, set them to a number or an ip list", r.Action)
+	}
+	validate, ok := validators[cluster.Provider]
+	if !ok {
+		return newError(ReasonUnsupportedProvider, nil, "provider %q of the Clusterfile is not supported, it should be one of %s, %s and %s",
+			cluster.Provider, common.BAREMETAL, common.AliCloud, common.CONTAINER)
+	}
+	if err := validate(cluster, r); err != nil {
+		return err
+	}
+	if r.Action == common.JoinSubCmd {
+		return validateJoin(cluster, r)
+	}
+	return validateDelete(cluster, r)
+}
+
+// validateBaremetal rejects counts, there is no cloud provider to provision the hosts.
+func validateBaremetal(c *Cluster, r *ScaleRequest) error {
+	if r.Masters.Count != 0 || r.Nodes.Count != 0 {
+		return newError(ReasonCountNotSupported, nil,
+			"the hosts of a %s cluster are scaled by ip list, like 192.168.0.2,192.168.0.3 or 192.168.0.2-192.168.0.5", c.Provider)
+	}
+	return nil
+}
+
+// validateInfra only deletes the baremetal hosts in the ip pool by ip, the provisioned ones are released by count.
+func validateInfra(c *Cluster, r *ScaleRequest) error {
+	if r.Action != common.DeleteSubCmd {
+		return nil
+	}
+	provisioned := append(utils.RemoveIPList(r.Masters.IPList, c.MasterPool), utils.RemoveIPList(r.Nodes.IPList, c.NodePool)...)
+	provisioned = utils.ReduceIPList(provisioned, append(append([]string{}, c.Masters...), c.Nodes...))
+	if len(provisioned) != 0 {
+		return newError(ReasonNotInIPPool, provisioned,
+			"hosts %v are provisioned by %s, delete them by the number of hosts instead of ip", provisioned, c.Provider)
+	}
+	if r.Masters.Count != 0 && r.Masters.Count >= len(utils.RemoveIPList(c.Masters, c.MasterPool)) {
+		return newError(ReasonDeleteMaster0, nil, "deleting %d masters releases all masters provisioned by %s with master0, "+
+			"at most %d can be deleted", r.Masters.Count, c.Provider, len(utils.RemoveIPList(c.Masters, c.MasterPool))-1)
+	}
+	if provisionedNodes := len(utils.RemoveIPList(c.Nodes, c.NodePool)); r.Nodes.Count > provisionedNodes {
+		return newError(ReasonCountExceeded, nil, "only %d nodes are provisioned by %s, %d can not be deleted",
+			provisionedNodes, c.Provider, r.Nodes.Count)
+	}
+	return nil
+}
+
+func validateJoin(c *Cluster, r *ScaleRequest) error {
+	if both := utils.ReduceIPList(r.Masters.IPList, r.Nodes.IPList); len(both) != 0 {
+		return newError(ReasonHostExists, both, "hosts %v are joined as both masters and nodes, join each host in one role", both)
+	}
+	joined := append(append([]string{}, r.Masters.IPList...), r.Nodes.IPList...)
+	if exists := utils.ReduceIPList(joined, append(append([]string{}, c.Masters...), c.Nodes...)); len(exists) != 0 {
+		return newError(ReasonHostExists, exists, "hosts %v are already in the cluster, delete them first to join them in another role", exists)
+	}
+	if len(c.Subnets) == 0 {
+		return nil
+	}
+	var subnets []*net.IPNet
+	for _, cidr := range c.Subnets {
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return newError(ReasonInvalidArgument, nil, "invalid subnet %s of cluster: %v", cidr, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	var outside []string
+	for _, host := range joined {
+		if !inSubnets(net.ParseIP(utils.GetHostIP(host)), subnets) {
+			outside = append(outside, host)
+		}
+	}
+	if len(outside) != 0 {
+		return newError(ReasonIPNotInSubnet, outside, "hosts %v are not in any subnet of the cluster %v, the hosts of %s can not reach them",
+			outside, c.Subnets, c.Provider)
+	}
+	return nil
+}
+
+func inSubnets(ip net.IP, subnets []*net.IPNet) bool {
+	for _, subnet := range subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateDelete(c *Cluster, r *ScaleRequest) error {
+	if notFound := utils.RemoveIPList(r.Masters.IPList, c.Masters); len(notFound) != 0 {
+		return newError(ReasonHostNotFound, notFound, "hosts %v are not masters of the cluster, the masters are %v", notFound, c.Masters)
+	}
+	if notFound := utils.RemoveIPList(r.Nodes.IPList, c.Nodes); len(notFound) != 0 {
+		return newError(ReasonHostNotFound, notFound, "hosts %v are not nodes of the cluster, the nodes are %v", notFound, c.Nodes)
+	}
+	if len(c.Masters) != 0 && utils.InList(c.Masters[0], r.Masters.IPList) {
+		if r.Promote == "" {
+			return newError(ReasonDeleteMaster0, c.Masters[:1], "master0 %s runs the registry and the cluster is applied from it, "+
+				"set the master to promote to master0 before deleting it", c.Masters[0])
+		}
+		if r.Promote == c.Masters[0] || utils.NotIn(r.Promote, c.Masters) || utils.InList(r.Promote, r.Masters.IPList) {
+			return newError(ReasonInvalidPromotion, []string{r.Promote}, "%s can not be promoted to master0, "+
+				"it must be one of the masters %v which is not deleted", r.Promote, c.Masters[1:])
+		}
+	}
+	if r.Masters.isEmpty() {
+		return nil
+	}
+	left := len(c.Masters) - r.Masters.size()
+	if len(c.Masters) >= MinHAMasters && left < MinHAMasters {
+		if !r.Force {
+			return newError(ReasonBelowHASize, r.Masters.IPList, "deleting %d masters leaves %d of the %d masters, "+
+				"a highly available cluster keeps at least %d masters for the quorum of etcd, "+
+				"join other masters before deleting them, or force it", r.Masters.size(), left, len(c.Masters), MinHAMasters)
+		}
+		logger.Warn("deleting %d masters by force, the %d masters left can not keep the quorum of etcd if any of them fails",
+			r.Masters.size(), left)
+	} else if left%2 == 0 {
+		logger.Warn("deleting %d masters leaves an even number %d of masters, which tolerates no more failures than %d masters",
+			r.Masters.size(), left, left-1)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaling
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/sealer/common"
+)
+
+func TestNewScaleRequest(t *testing.T) {
+	req, err := NewScaleRequest(common.JoinSubCmd, &common.RunArgs{Masters: "2", Nodes: "192.168.0.3-192.168.0.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Masters.Count != 2 || !reflect.DeepEqual(req.Nodes.IPList, []string{"192.168.0.4", "192.168.0.3"}) {
+		t.Errorf("NewScaleRequest() = %+v", req)
+	}
+	for _, args := range []*common.RunArgs{{Masters: "0"}, {Nodes: "b-a"}, {Nodes: "192.168.0.3,node1"}} {
+		if _, err := NewScaleRequest(common.JoinSubCmd, args); ReasonOf(err) != ReasonInvalidArgument {
+			t.Errorf("NewScaleRequest(%+v) error = %v, want %s", args, err, ReasonInvalidArgument)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	baremetal := &Cluster{
+		Provider: common.BAREMETAL,
+		Masters:  []string{"192.168.0.2", "192.168.0.3", "192.168.0.4"},
+		Nodes:    []string{"192.168.0.5"},
+	}
+	aliCloud := &Cluster{
+		Provider: common.AliCloud,
+		Masters:  []string{"172.16.0.2", "172.16.0.3"},
+		Nodes:    []string{"172.16.0.5", "172.16.0.6", "172.16.0.10"},
+		NodePool: []string{"172.16.0.10"},
+		Subnets:  []string{"172.16.0.0/24"},
+	}
+	tests := []struct {
+		name    string
+		cluster *Cluster
+		action  string
+		args    common.RunArgs
+		want    Reason
+	}{
+		{"join nodes", baremetal, common.JoinSubCmd, common.RunArgs{Nodes: "192.168.0.6"}, ""},
+		{"no hosts", baremetal, common.JoinSubCmd, common.RunArgs{}, ReasonNoHosts},
+		{"unknown provider", &Cluster{Provider: "VM"}, common.JoinSubCmd, common.RunArgs{Nodes: "1"}, ReasonUnsupportedProvider},
+		{"count of baremetal", baremetal, common.JoinSubCmd, common.RunArgs{Nodes: "1"}, ReasonCountNotSupported},
+		{"join existing host", baremetal, common.JoinSubCmd, common.RunArgs{Masters: "192.168.0.5"}, ReasonHostExists},
+		{"join in both roles", baremetal, common.JoinSubCmd, common.RunArgs{Masters: "192.168.0.6", Nodes: "192.168.0.6"}, ReasonHostExists},
+		{"delete unknown host", baremetal, common.DeleteSubCmd, common.RunArgs{Nodes: "192.168.0.6"}, ReasonHostNotFound},
+		{"delete master as node", baremetal, common.DeleteSubCmd, common.RunArgs{Nodes: "192.168.0.3"}, ReasonHostNotFound},
+		{"delete master0", baremetal, common.DeleteSubCmd, common.RunArgs{Masters: "192.168.0.2"}, ReasonDeleteMaster0},
+		{"below ha size", baremetal, common.DeleteSubCmd, common.RunArgs{Masters: "192.168.0.4"}, ReasonBelowHASize},
+		{"below ha size by force", baremetal, common.DeleteSubCmd, common.RunArgs{Masters: "192.168.0.4", Force: true}, ""},
+		{"delete master0 with promotion", baremetal, common.DeleteSubCmd,
+			common.RunArgs{Masters: "192.168.0.2", Promote: "192.168.0.3", Force: true}, ""},
+		{"promote deleted master", baremetal, common.DeleteSubCmd,
+			common.RunArgs{Masters: "192.168.0.2,192.168.0.3", Promote: "192.168.0.3", Force: true}, ReasonInvalidPromotion},
+		{"promote node", baremetal, common.DeleteSubCmd,
+			common.RunArgs{Masters: "192.168.0.2", Promote: "192.168.0.5", Force: true}, ReasonInvalidPromotion},
+		{"join count and pool", aliCloud, common.JoinSubCmd, common.RunArgs{Masters: "1", Nodes: "172.16.0.11"}, ""},
+		{"join outside subnet", aliCloud, common.JoinSubCmd, common.RunArgs{Nodes: "192.168.0.11"}, ReasonIPNotInSubnet},
+		{"delete pool host", aliCloud, common.DeleteSubCmd, common.RunArgs{Nodes: "172.16.0.10"}, ""},
+		{"delete provisioned host by ip", aliCloud, common.DeleteSubCmd, common.RunArgs{Nodes: "172.16.0.5"}, ReasonNotInIPPool},
+		{"delete provisioned masters", aliCloud, common.DeleteSubCmd, common.RunArgs{Masters: "2"}, ReasonDeleteMaster0},
+		{"delete too many nodes", aliCloud, common.DeleteSubCmd, common.RunArgs{Nodes: "3"}, ReasonCountExceeded},
+		{"delete nodes", aliCloud, common.DeleteSubCmd, common.RunArgs{Masters: "1", Nodes: "2"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewScaleRequest(tt.action, &tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = req.Validate(tt.cluster)
+			if ReasonOf(err) != tt.want || (err == nil) != (tt.want == "") {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
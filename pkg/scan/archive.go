@@ -0,0 +1,154 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/alibaba/sealer/common"
+)
+
+const (
+	manifestList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	manifestOCIIndex = "application/vnd.oci.image.index.v1+json"
+
+	registryBlobsDir = "docker/registry/v2/blobs"
+	blobDataFile     = "data"
+)
+
+// registryBlobPath returns the path of blob in the registry storage under rootfs.
+func registryBlobPath(rootfs string, dgst digest.Digest) string {
+	hex := dgst.Hex()
+	return filepath.Join(rootfs, common.RegistryDirName, registryBlobsDir, dgst.Algorithm().String(), hex[:2], hex, blobDataFile)
+}
+
+func readBlob(rootfs string, dgst digest.Digest) ([]byte, error) {
+	if err := dgst.Validate(); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filepath.Clean(registryBlobPath(rootfs, dgst)))
+}
+
+// selectManifest returns the manifest of arch if dgst references a manifest list,
+// the image of registry is saved for the arch of the cloud image only.
+func selectManifest(rootfs string, dgst digest.Digest, arch string) (ocispec.Descriptor, []byte, error) {
+	data, err := readBlob(rootfs, dgst)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	var versioned struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err = json.Unmarshal(data, &versioned); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("failed to decode manifest %s: %v", dgst, err)
+	}
+	if versioned.MediaType != manifestList && versioned.MediaType != manifestOCIIndex {
+		return ocispec.Descriptor{MediaType: versioned.MediaType, Digest: dgst, Size: int64(len(data))}, data, nil
+	}
+
+	var index ocispec.Index
+	if err = json.Unmarshal(data, &index); err != nil {
+		return ocispec.Descriptor{}, nil, fmt.Errorf("failed to decode manifest list %s: %v", dgst, err)
+	}
+	for _, m := range index.Manifests {
+		if m.Platform != nil && m.Platform.Architecture == arch {
+			if _, err = os.Stat(registryBlobPath(rootfs, m.Digest)); err == nil {
+				return selectManifest(rootfs, m.Digest, arch)
+			}
+		}
+	}
+	return ocispec.Descriptor{}, nil, fmt.Errorf("no manifest of %s found in manifest list %s", arch, dgst)
+}
+
+// ExportOCIArchive writes the image of registry storage under rootfs referenced
+// by manifest digest to w as a tar of OCI image layout, which can be scanned by
+// `trivy image --input`.
+func ExportOCIArchive(rootfs, name string, dgst digest.Digest, arch string, w io.Writer) error {
+	desc, data, err := selectManifest(rootfs, dgst, arch)
+	if err != nil {
+		return err
+	}
+	var manifest ocispec.Manifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest %s: %v", desc.Digest, err)
+	}
+	desc.Annotations = map[string]string{ocispec.AnnotationRefName: name}
+
+	tw := tar.NewWriter(w)
+	layout, _ := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	index, _ := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Manifests: []ocispec.Descriptor{desc},
+	})
+	if err = writeTarFile(tw, ocispec.ImageLayoutFile, layout); err != nil {
+		return err
+	}
+	if err = writeTarFile(tw, "index.json", index); err != nil {
+		return err
+	}
+	if err = writeTarFile(tw, blobName(desc.Digest), data); err != nil {
+		return err
+	}
+	blobs := append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...)
+	for _, blob := range blobs {
+		if err = writeTarBlob(tw, rootfs, blob.Digest); err != nil {
+			return fmt.Errorf("failed to export blob %s of %s: %v", blob.Digest, name, err)
+		}
+	}
+	return tw.Close()
+}
+
+func blobName(dgst digest.Digest) string {
+	return strings.Join([]string{"blobs", dgst.Algorithm().String(), dgst.Hex()}, "/")
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: common.FileMode0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeTarBlob(tw *tar.Writer, rootfs string, dgst digest.Digest) error {
+	if err := dgst.Validate(); err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Clean(registryBlobPath(rootfs, dgst)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(&tar.Header{Name: blobName(dgst), Mode: common.FileMode0644, Size: info.Size()}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,106 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func writeBlob(t *testing.T, rootfs string, data []byte) ocispec.Descriptor {
+	dgst := digest.FromBytes(data)
+	path := registryBlobPath(rootfs, dgst)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return ocispec.Descriptor{Digest: dgst, Size: int64(len(data))}
+}
+
+func TestExportOCIArchive(t *testing.T) {
+	rootfs, err := ioutil.TempDir("", "sealer-scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+
+	config := writeBlob(t, rootfs, []byte(`{"architecture":"arm64"}`))
+	layer := writeBlob(t, rootfs, []byte("layer"))
+	manifest, _ := json.Marshal(ocispec.Manifest{Config: config, Layers: []ocispec.Descriptor{layer}})
+	arm64 := writeBlob(t, rootfs, manifest)
+	arm64.Platform = &ocispec.Platform{OS: "linux", Architecture: "arm64"}
+	// the amd64 manifest is not saved in the registry.
+	amd64 := ocispec.Descriptor{Digest: digest.FromString("amd64"), Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}}
+	list, _ := json.Marshal(struct {
+		MediaType string               `json:"mediaType"`
+		Manifests []ocispec.Descriptor `json:"manifests"`
+	}{manifestList, []ocispec.Descriptor{amd64, arm64}})
+	listDesc := writeBlob(t, rootfs, list)
+
+	if err = ExportOCIArchive(rootfs, "nginx:latest", listDesc.Digest, "amd64", ioutil.Discard); err == nil {
+		t.Errorf("ExportOCIArchive() should fail for missing platform")
+	}
+
+	var buf bytes.Buffer
+	if err = ExportOCIArchive(rootfs, "nginx:latest", listDesc.Digest, "arm64", &buf); err != nil {
+		t.Fatalf("ExportOCIArchive() error: %v", err)
+	}
+	var files []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, hdr.Name)
+	}
+	want := []string{"blobs/sha256/" + arm64.Digest.Hex(), "blobs/sha256/" + config.Digest.Hex(),
+		"blobs/sha256/" + layer.Digest.Hex(), "index.json", ocispec.ImageLayoutFile}
+	sort.Strings(files)
+	sort.Strings(want)
+	if len(files) != len(want) {
+		t.Fatalf("ExportOCIArchive() files = %v, want %v", files, want)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("ExportOCIArchive() files = %v, want %v", files, want)
+		}
+	}
+}
+
+func TestParseSeverities(t *testing.T) {
+	got, err := ParseSeverities("high, Critical")
+	if err != nil || len(got) != 2 || got[0] != "HIGH" || got[1] != "CRITICAL" {
+		t.Errorf("ParseSeverities() = %v, %v", got, err)
+	}
+	if _, err = ParseSeverities("HIGH,SEVERE"); err == nil {
+		t.Errorf("ParseSeverities() should fail for invalid severity")
+	}
+}
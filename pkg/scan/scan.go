@@ -0,0 +1,289 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/sbom"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/mount"
+)
+
+const (
+	// DefaultScanner is the scanner binary, any scanner compatible with the
+	// `trivy image --input` and `trivy rootfs` command line and json report works.
+	DefaultScanner = "trivy"
+
+	TargetImage  = "image"
+	TargetBinary = "binary"
+
+	binDir = "bin"
+)
+
+// Severities in ascending order.
+var Severities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+type Options struct {
+	// Scanner is the name or path of the scanner binary, default is trivy.
+	Scanner string
+	// Severities only reports vulnerabilities of these severities, default is all.
+	Severities []string
+}
+
+type Vulnerability struct {
+	VulnerabilityID  string `json:"VulnerabilityID"`
+	PkgName          string `json:"PkgName"`
+	InstalledVersion string `json:"InstalledVersion"`
+	FixedVersion     string `json:"FixedVersion,omitempty"`
+	Severity         string `json:"Severity"`
+	Title            string `json:"Title,omitempty"`
+}
+
+// Target is a container image or a binary of the CloudImage.
+type Target struct {
+	Type            string          `json:"type"`
+	Name            string          `json:"name"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+type Report struct {
+	Image   string   `json:"image"`
+	Targets []Target `json:"targets"`
+}
+
+// Summary counts the vulnerabilities by severity.
+func (r *Report) Summary() map[string]int {
+	summary := make(map[string]int)
+	for _, t := range r.Targets {
+		for _, v := range t.Vulnerabilities {
+			summary[v.Severity]++
+		}
+	}
+	return summary
+}
+
+// Total returns the count of all vulnerabilities.
+func (r *Report) Total() int {
+	var total int
+	for _, n := range r.Summary() {
+		total += n
+	}
+	return total
+}
+
+// ParseSeverities parses the comma separated severities, like HIGH,CRITICAL.
+func ParseSeverities(s string) ([]string, error) {
+	var res []string
+	for _, severity := range strings.Split(s, ",") {
+		severity = strings.ToUpper(strings.TrimSpace(severity))
+		if severity == "" {
+			continue
+		}
+		if utils.NotIn(severity, Severities) {
+			return nil, fmt.Errorf("invalid severity %s, should be one of %s", severity, strings.Join(Severities, ","))
+		}
+		res = append(res, severity)
+	}
+	return res, nil
+}
+
+// trivyReport is the json report of `trivy --format json`.
+type trivyReport struct {
+	Results []struct {
+		Target          string          `json:"Target"`
+		Vulnerabilities []Vulnerability `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+func (o *Options) scanner() string {
+	if o.Scanner == "" {
+		return DefaultScanner
+	}
+	return o.Scanner
+}
+
+func (o *Options) run(args ...string) ([]Vulnerability, error) {
+	args = append([]string{"--format", "json", "--quiet"}, args...)
+	if len(o.Severities) > 0 {
+		args = append(args, "--severity", strings.Join(o.Severities, ","))
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(o.scanner(), args...) // #nosec
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run %s %s: %v, %s", o.scanner(), strings.Join(args, " "), err, stderr.String())
+	}
+
+	var report trivyReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		return nil, fmt.Errorf("failed to decode the report of %s: %v", o.scanner(), err)
+	}
+	var vulns []Vulnerability
+	for _, res := range report.Results {
+		for _, v := range res.Vulnerabilities {
+			// filter again as not every compatible scanner supports --severity.
+			if len(o.Severities) > 0 && utils.NotIn(v.Severity, o.Severities) {
+				continue
+			}
+			vulns = append(vulns, v)
+		}
+	}
+	sort.SliceStable(vulns, func(i, j int) bool {
+		return severityLevel(vulns[i].Severity) > severityLevel(vulns[j].Severity)
+	})
+	return vulns, nil
+}
+
+func severityLevel(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return 0
+}
+
+// Scan scans the container images in the registry and the binaries under bin of rootfs.
+func Scan(rootfs, arch string, opts *Options) ([]Target, error) {
+	if _, ok := utils.CheckCmdIsExist(opts.scanner()); !ok {
+		return nil, fmt.Errorf("scanner %s not found, please install trivy or set a compatible scanner", opts.scanner())
+	}
+	tmpDir, err := utils.MkTmpdir()
+	if err != nil {
+		return nil, err
+	}
+	defer utils.CleanDir(tmpDir)
+
+	images, err := sbom.ListImages(rootfs)
+	if err != nil {
+		return nil, err
+	}
+	var targets []Target
+	for _, img := range images {
+		name := img.Name + ":" + img.Version
+		logger.Info("scanning image %s", name)
+		archive := filepath.Join(tmpDir, digest.Digest(img.Digest).Hex()+".tar")
+		if err = exportToFile(rootfs, name, digest.Digest(img.Digest), arch, archive); err != nil {
+			return nil, err
+		}
+		vulns, err := opts.run("image", "--input", archive)
+		if err != nil {
+			return nil, err
+		}
+		if err = utils.CleanFiles(archive); err != nil {
+			logger.Warn(err)
+		}
+		targets = append(targets, Target{Type: TargetImage, Name: name, Vulnerabilities: vulns})
+	}
+
+	if utils.IsExist(filepath.Join(rootfs, binDir)) {
+		logger.Info("scanning binaries")
+		vulns, err := opts.run("rootfs", filepath.Join(rootfs, binDir))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, Target{Type: TargetBinary, Name: binDir, Vulnerabilities: vulns})
+	}
+	return targets, nil
+}
+
+func exportToFile(rootfs, name string, dgst digest.Digest, arch, path string) error {
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, common.FileMode0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ExportOCIArchive(rootfs, name, dgst, arch, f)
+}
+
+// ScanImage mounts the CloudImage and scans it, the index of multi-platform
+// image is scanned platform by platform.
+func ScanImage(imageStore store.ImageStore, img *v1.Image, opts *Options) (*Report, error) {
+	report := &Report{Image: img.Name}
+	images := []*v1.Image{img}
+	if platform.IsIndex(img) {
+		images = nil
+		for _, m := range img.Spec.Manifests {
+			pImg, err := imageStore.GetByName(m.Name)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, pImg)
+		}
+	}
+
+	for _, i := range images {
+		targets, err := scanImage(i, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(images) > 1 {
+			for n := range targets {
+				targets[n].Name = fmt.Sprintf("%s (%s)", targets[n].Name, platform.Format(i.Spec.Platform))
+			}
+		}
+		report.Targets = append(report.Targets, targets...)
+	}
+	return report, nil
+}
+
+func scanImage(img *v1.Image, opts *Options) ([]Target, error) {
+	mountTarget, err := utils.MkTmpdir()
+	if err != nil {
+		return nil, err
+	}
+	mountUpper, err := utils.MkTmpdir()
+	if err != nil {
+		return nil, err
+	}
+	defer utils.CleanDirs(mountTarget, mountUpper)
+
+	layers, err := image.GetImageLayerDirs(img)
+	if err != nil {
+		return nil, err
+	}
+	driver := mount.NewMountDriver()
+	if err = driver.Mount(mountTarget, mountUpper, layers...); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := driver.Unmount(mountTarget); err != nil {
+			logger.Warn(err)
+		}
+	}()
+
+	arch := img.Spec.Platform.Architecture
+	if arch == "" {
+		arch = platform.Default().Architecture
+	}
+	return Scan(mountTarget, arch, opts)
+}
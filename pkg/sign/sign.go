@@ -0,0 +1,341 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// DefaultTrustedKeysDir holds the public keys *.pub used to verify cloud images,
+	// images are only verified if it has keys.
+	DefaultTrustedKeysDir = "/etc/sealer/trusted-keys"
+	// DefaultVerifiedImagesDir records the manifest digest of the images verified when pulled, by the digest of
+	// their content. It is out of the image metadata, so an image loaded or edited locally is not taken as verified.
+	DefaultVerifiedImagesDir = "/var/lib/sealer/verified"
+	PrivateKeyFile           = "sealer.key"
+	PublicKeyFile            = "sealer.pub"
+
+	// the payload follows the simple signing format of cosign.
+	signatureType = "cosign container image signature"
+
+	privateKeyPEMType  = "EC PRIVATE KEY"
+	pkcs8KeyPEMType    = "PRIVATE KEY"
+	publicKeyPEMType   = "PUBLIC KEY"
+	privateKeyFileMode = 0600
+	publicKeyFileMode  = 0644
+	publicKeySuffix    = ".pub"
+	verifiedFileMode   = 0600
+)
+
+// verifiedImagesDir is a variable to be replaced in tests.
+var verifiedImagesDir = DefaultVerifiedImagesDir
+
+// insecureSkipVerify is set by --insecure-skip-verify of the commands which pull images.
+var insecureSkipVerify bool
+
+func SetInsecureSkipVerify(skip bool) {
+	insecureSkipVerify = skip
+}
+
+// Signature is a signed payload of an image manifest digest.
+type Signature struct {
+	Payload   []byte
+	Signature string
+	// Certificate is the Fulcio certificate of a keyless signature, which is not verified yet.
+	Certificate string
+}
+
+// ecdsaSignature is the ASN.1 form of ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+type payload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]string `json:"optional"`
+}
+
+// GenerateKeyPair writes an ECDSA P-256 key pair to dir.
+func GenerateKeyPair(dir string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	priv, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	privFile, pubFile := filepath.Join(dir, PrivateKeyFile), filepath.Join(dir, PublicKeyFile)
+	for _, f := range []string{privFile, pubFile} {
+		if utils.IsFileExist(f) {
+			return fmt.Errorf("%s already exists", f)
+		}
+	}
+	if err = ioutil.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{Type: privateKeyPEMType, Bytes: priv}), privateKeyFileMode); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: publicKeyPEMType, Bytes: pub}), publicKeyFileMode)
+}
+
+func LoadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	switch block.Type {
+	case privateKeyPEMType:
+		return x509.ParseECPrivateKey(block.Bytes)
+	case pkcs8KeyPEMType:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if k, ok := key.(*ecdsa.PrivateKey); ok {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not an ECDSA private key, encrypted keys are not supported", path)
+}
+
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %v", path, err)
+	}
+	k, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ECDSA public key", path)
+	}
+	return k, nil
+}
+
+// TrustedKeys returns the public keys in DefaultTrustedKeysDir, nil means images need not be verified.
+func TrustedKeys() ([]*ecdsa.PublicKey, error) {
+	if insecureSkipVerify {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(DefaultTrustedKeysDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var keys []*ecdsa.PublicKey
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), publicKeySuffix) {
+			continue
+		}
+		key, err := LoadPublicKey(filepath.Join(DefaultTrustedKeysDir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
+
+// NewPayload returns the payload to sign for the manifest digest of image repo.
+func NewPayload(repo string, manifestDigest digest.Digest) ([]byte, error) {
+	p := payload{}
+	p.Critical.Identity.DockerReference = repo
+	p.Critical.Image.DockerManifestDigest = manifestDigest.String()
+	p.Critical.Type = signatureType
+	return json.Marshal(p)
+}
+
+func Sign(key *ecdsa.PrivateKey, payload []byte) (string, error) {
+	h := sha256.Sum256(payload)
+	r, ss, err := ecdsa.Sign(rand.Reader, key, h[:])
+	if err != nil {
+		return "", err
+	}
+	sig, err := asn1.Marshal(ecdsaSignature{R: r, S: ss})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Verify checks that one of signatures is signed by one of keys for manifestDigest of image repo,
+// like registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes.
+func Verify(keys []*ecdsa.PublicKey, signatures []Signature, repo string, manifestDigest digest.Digest) error {
+	if len(signatures) == 0 {
+		return fmt.Errorf("no signature found for %s", manifestDigest)
+	}
+	var (
+		mismatch error
+		keyless  bool
+	)
+	for _, s := range signatures {
+		keyless = keyless || s.Certificate != ""
+		data, err := base64.StdEncoding.DecodeString(s.Signature)
+		if err != nil {
+			continue
+		}
+		var sig ecdsaSignature
+		if _, err = asn1.Unmarshal(data, &sig); err != nil {
+			continue
+		}
+		h := sha256.Sum256(s.Payload)
+		for _, key := range keys {
+			if !ecdsa.Verify(key, h[:], sig.R, sig.S) {
+				continue
+			}
+			mismatch = checkPayload(s.Payload, repo, manifestDigest)
+			if mismatch == nil {
+				return nil
+			}
+			break
+		}
+	}
+	// a signature of another image of the repo may be found besides the right one, only fail if none matches.
+	if mismatch != nil {
+		return mismatch
+	}
+	if keyless {
+		return fmt.Errorf("no signature of %s is signed by the trusted keys, keyless signatures with certificates are not supported yet, "+
+			"sign it with a key or trust the public key of the signer", manifestDigest)
+	}
+	return fmt.Errorf("no signature of %s is signed by the trusted keys", manifestDigest)
+}
+
+func checkPayload(data []byte, repo string, manifestDigest digest.Digest) error {
+	p := payload{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("failed to decode signature payload: %v", err)
+	}
+	if p.Critical.Type != signatureType {
+		return fmt.Errorf("unknown signature type %q", p.Critical.Type)
+	}
+	if p.Critical.Image.DockerManifestDigest != manifestDigest.String() {
+		return fmt.Errorf("signature is for %s, not %s, the image may be tampered",
+			p.Critical.Image.DockerManifestDigest, manifestDigest)
+	}
+	if p.Critical.Identity.DockerReference != repo {
+		return fmt.Errorf("signature is for image %s, not %s", p.Critical.Identity.DockerReference, repo)
+	}
+	return nil
+}
+
+// contentDigest is the digest of image without its name and the annotation of verified digest,
+// which are changed by tagging and pulling.
+func contentDigest(image *v1.Image) (digest.Digest, error) {
+	content := image.DeepCopy()
+	content.Name = ""
+	delete(content.Annotations, common.ImageAnnotationForVerified)
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(data), nil
+}
+
+// RecordVerified records that image was pulled by manifestDigest verified by the trusted keys.
+func RecordVerified(image *v1.Image, manifestDigest digest.Digest) error {
+	dgst, err := contentDigest(image)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(verifiedImagesDir, common.FileMode0755); err != nil {
+		return err
+	}
+	return utils.AtomicWriteFile(filepath.Join(verifiedImagesDir, dgst.Hex()), []byte(manifestDigest.String()), verifiedFileMode)
+}
+
+func verifiedDigest(image *v1.Image) (digest.Digest, error) {
+	dgst, err := contentDigest(image)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(verifiedImagesDir, dgst.Hex()))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return digest.Parse(strings.TrimSpace(string(data)))
+}
+
+// CheckVerified refuses the local image which was not verified when pulled if trusted keys are configured,
+// e.g. the image is built or loaded locally, changed after pulled, or pulled before the keys are configured.
+func CheckVerified(image *v1.Image) error {
+	keys, err := TrustedKeys()
+	if err != nil {
+		return fmt.Errorf("failed to load trusted keys: %v", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	dgst, err := verifiedDigest(image)
+	if err != nil {
+		return fmt.Errorf("failed to read the verified digest of image %s: %v", image.Name, err)
+	}
+	if dgst != "" {
+		return nil
+	}
+	return fmt.Errorf("image %s is not verified by the trusted keys, pull it from a registry again or use --insecure-skip-verify", image.Name)
+}
@@ -0,0 +1,166 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sealer-sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = GenerateKeyPair(dir); err != nil {
+		t.Fatalf("GenerateKeyPair() error: %v", err)
+	}
+	if err = GenerateKeyPair(dir); err == nil {
+		t.Errorf("GenerateKeyPair() should not overwrite existing keys")
+	}
+	priv, err := LoadPrivateKey(filepath.Join(dir, PrivateKeyFile))
+	if err != nil {
+		t.Fatalf("LoadPrivateKey() error: %v", err)
+	}
+	pub, err := LoadPublicKey(filepath.Join(dir, PublicKeyFile))
+	if err != nil {
+		t.Fatalf("LoadPublicKey() error: %v", err)
+	}
+
+	const repo = "registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes"
+	dgst := digest.FromString("manifest")
+	sign := func(repo string, dgst digest.Digest) Signature {
+		payload, err := NewPayload(repo, dgst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := Sign(priv, payload)
+		if err != nil {
+			t.Fatalf("Sign() error: %v", err)
+		}
+		return Signature{Payload: payload, Signature: sig}
+	}
+	signature := sign(repo, dgst)
+	signatures := []Signature{signature}
+
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		keys       []*ecdsa.PublicKey
+		signatures []Signature
+		digest     digest.Digest
+		wantErr    bool
+	}{
+		{"valid", []*ecdsa.PublicKey{pub}, signatures, dgst, false},
+		{"no signature", []*ecdsa.PublicKey{pub}, nil, dgst, true},
+		{"untrusted key", []*ecdsa.PublicKey{&untrusted.PublicKey}, signatures, dgst, true},
+		{"tampered manifest", []*ecdsa.PublicKey{pub}, signatures, digest.FromString("tampered"), true},
+		{"tampered payload", []*ecdsa.PublicKey{pub}, []Signature{{Payload: append(signature.Payload, ' '), Signature: signature.Signature}}, dgst, true},
+		{"signature of another repo", []*ecdsa.PublicKey{pub}, []Signature{sign("docker.io/library/kubernetes", dgst)}, dgst, true},
+		{"signature of another digest before the right one", []*ecdsa.PublicKey{pub},
+			[]Signature{sign(repo, digest.FromString("old")), sign("docker.io/library/kubernetes", dgst), signature}, dgst, false},
+		{"invalid signature before the right one", []*ecdsa.PublicKey{pub}, []Signature{{Payload: signature.Payload, Signature: "invalid"}, signature}, dgst, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.keys, tt.signatures, repo, tt.digest); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// keyless signatures are refused with the reason, instead of taken as signed by untrusted keys.
+	keyless := sign(repo, dgst)
+	keyless.Certificate = "-----BEGIN CERTIFICATE-----"
+	err = Verify([]*ecdsa.PublicKey{&untrusted.PublicKey}, []Signature{keyless}, repo, dgst)
+	if err == nil || !strings.Contains(err.Error(), "keyless signatures with certificates are not supported") {
+		t.Errorf("Verify() of keyless signature error = %v", err)
+	}
+}
+
+func TestRecordVerified(t *testing.T) {
+	verifiedImagesDir = filepath.Join(t.TempDir(), "verified")
+	defer func() { verifiedImagesDir = DefaultVerifiedImagesDir }()
+
+	image := &v1.Image{}
+	image.Name = "kubernetes:v1.19.8"
+	image.Annotations = map[string]string{common.ImageAnnotationForClusterfile: "kind: Cluster"}
+	image.Spec.ID = "d6a6c9bfd4ad"
+	image.Spec.Layers = []v1.Layer{{ID: digest.FromString("layer"), Type: "COPY", Value: ". ."}}
+	dgst := digest.FromString("manifest")
+	if err := RecordVerified(image, dgst); err != nil {
+		t.Fatalf("RecordVerified() error: %v", err)
+	}
+
+	// the image is saved as yaml with the annotation of verified digest, and may be tagged with another name
+	saved := image.DeepCopy()
+	saved.Name = "my-kubernetes:v1.19.8"
+	saved.Annotations[common.ImageAnnotationForVerified] = dgst.String()
+	data, err := yaml.Marshal(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &v1.Image{}
+	if err = yaml.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := loaded.DeepCopy()
+	tampered.Annotations[common.ImageAnnotationForClusterfile] = "kind: Cluster\nspec: {}"
+	layer := loaded.DeepCopy()
+	layer.Spec.Layers[0].ID = digest.FromString("tampered")
+	unverified := &v1.Image{}
+	unverified.Annotations = map[string]string{common.ImageAnnotationForVerified: dgst.String()}
+
+	tests := []struct {
+		name  string
+		image *v1.Image
+		want  digest.Digest
+	}{
+		{"verified", loaded, dgst},
+		{"tampered clusterfile", tampered, ""},
+		{"tampered layer", layer, ""},
+		{"annotation only", unverified, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifiedDigest(tt.image)
+			if err != nil {
+				t.Fatalf("verifiedDigest() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("verifiedDigest() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smoke
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The checks of the smoke test.
+const (
+	CheckImagePull    = "image-pull"
+	CheckPodToPod     = "pod-to-pod"
+	CheckPodToService = "pod-to-service"
+	CheckDNS          = "dns"
+	CheckPVC          = "pvc"
+)
+
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+type Result struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+type Report struct {
+	Cluster string   `json:"cluster"`
+	Results []Result `json:"results"`
+}
+
+// Passed returns whether none of the checks of r failed.
+func (r *Report) Passed() bool {
+	if len(r.Results) == 0 {
+		return false
+	}
+	for _, res := range r.Results {
+		if res.Status == StatusFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// Kubectl runs kubectl with args on master0 and returns its output.
+type Kubectl func(args string) ([]byte, error)
+
+func result(check string, status Status, format string, a ...interface{}) Result {
+	return Result{Check: check, Status: status, Message: fmt.Sprintf(format, a...)}
+}
+
+// Check runs the checks by the smoke resources deployed to the cluster, the checks of networking and DNS are skipped
+// if none of the smoke pods is ready.
+func Check(kubectl Kubectl, opts Options) []Result {
+	pull, pods := checkImagePull(kubectl, opts)
+	results := []Result{pull}
+	if len(pods) == 0 {
+		for _, check := range []string{CheckPodToPod, CheckPodToService, CheckDNS} {
+			results = append(results, result(check, StatusSkipped, "none of the smoke pods is ready"))
+		}
+	} else {
+		clusterIP, err := kubectl(fmt.Sprintf("get service %s -n %s -o jsonpath={.spec.clusterIP}", name, Namespace))
+		svc := strings.TrimSpace(string(clusterIP))
+		results = append(results, checkPodToPod(kubectl, pods))
+		if err != nil || svc == "" {
+			msg := fmt.Sprintf("failed to get the cluster ip of service %s: %v, %s", name, err, svc)
+			results = append(results, result(CheckPodToService, StatusFailed, "%s", msg), result(CheckDNS, StatusFailed, "%s", msg))
+		} else {
+			results = append(results, checkPodToService(kubectl, pods, svc), checkDNS(kubectl, pods, svc, opts.ClusterDomain))
+		}
+	}
+	return append(results, checkPVC(kubectl, opts))
+}
+
+func exec(kubectl Kubectl, pod, cmd string) (string, error) {
+	out, err := kubectl(fmt.Sprintf("exec -n %s %s -- %s", Namespace, pod, cmd))
+	return strings.TrimSpace(string(out)), err
+}
+
+// checkImagePull waits for the smoke pods to be ready on all the nodes and returns the ready ones sorted by node.
+func checkImagePull(kubectl Kubectl, opts Options) (Result, []corev1.Pod) {
+	// the pods not ready are reported below.
+	_, _ = kubectl(fmt.Sprintf("rollout status daemonset/%s -n %s --timeout=%ds", name, Namespace, int(opts.Wait.Seconds())))
+	out, err := kubectl(fmt.Sprintf("get pods -n %s -l app=%s -o json", Namespace, name))
+	if err != nil {
+		return result(CheckImagePull, StatusFailed, "failed to list the smoke pods: %v, %s", err, strings.TrimSpace(string(out))), nil
+	}
+	var pods corev1.PodList
+	if err = json.Unmarshal(out, &pods); err != nil {
+		return result(CheckImagePull, StatusFailed, "failed to decode the smoke pods: %v", err), nil
+	}
+	if len(pods.Items) == 0 {
+		return result(CheckImagePull, StatusFailed, "none of the smoke pods is scheduled"), nil
+	}
+	var (
+		ready    []corev1.Pod
+		notReady []string
+	)
+	for _, p := range pods.Items {
+		if podReady(p) && p.Status.PodIP != "" {
+			ready = append(ready, p)
+			continue
+		}
+		notReady = append(notReady, fmt.Sprintf("%s on %s: %s", p.Name, p.Spec.NodeName, waitingReason(p)))
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].Spec.NodeName < ready[j].Spec.NodeName })
+	if len(notReady) != 0 {
+		return result(CheckImagePull, StatusFailed, "%d of %d smoke pods of %s are not ready: %s",
+			len(notReady), len(pods.Items), opts.Image, strings.Join(notReady, "; ")), ready
+	}
+	return result(CheckImagePull, StatusPassed, "%s pulled and ready on %d nodes", opts.Image, len(ready)), ready
+}
+
+func podReady(p corev1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// waitingReason returns why the container of p is not ready, like ImagePullBackOff.
+func waitingReason(p corev1.Pod) string {
+	for _, s := range p.Status.ContainerStatuses {
+		if s.State.Waiting != nil && s.State.Waiting.Reason != "" {
+			return s.State.Waiting.Reason
+		}
+	}
+	return string(p.Status.Phase)
+}
+
+// checkPodToPod requests each pod from the one on the former node, so that the traffic across each node is checked
+// in a ring.
+func checkPodToPod(kubectl Kubectl, pods []corev1.Pod) Result {
+	var failures []string
+	for i, from := range pods {
+		to := pods[(i+1)%len(pods)]
+		out, err := exec(kubectl, from.Name, fmt.Sprintf("wget -q -T 5 -O - http://%s:%d/", to.Status.PodIP, port))
+		if err != nil || out != to.Name {
+			failures = append(failures, fmt.Sprintf("%s (%s) -> %s (%s): %v %s", from.Name, from.Spec.NodeName, to.Name, to.Spec.NodeName, err, out))
+		}
+	}
+	if len(failures) != 0 {
+		return result(CheckPodToPod, StatusFailed, "%d of %d requests failed: %s", len(failures), len(pods), strings.Join(failures, "; "))
+	}
+	return result(CheckPodToPod, StatusPassed, "%d pods reached each other across the nodes", len(pods))
+}
+
+// checkPodToService requests the service of the smoke pods from each of them.
+func checkPodToService(kubectl Kubectl, pods []corev1.Pod, clusterIP string) Result {
+	names := map[string]bool{}
+	for _, p := range pods {
+		names[p.Name] = true
+	}
+	var failures []string
+	for _, from := range pods {
+		out, err := exec(kubectl, from.Name, fmt.Sprintf("wget -q -T 5 -O - http://%s:%d/", clusterIP, port))
+		if err != nil || !names[out] {
+			failures = append(failures, fmt.Sprintf("%s (%s): %v %s", from.Name, from.Spec.NodeName, err, out))
+		}
+	}
+	if len(failures) != 0 {
+		return result(CheckPodToService, StatusFailed, "%d of %d pods failed to reach service %s: %s", len(failures), len(pods), clusterIP, strings.Join(failures, "; "))
+	}
+	return result(CheckPodToService, StatusPassed, "%d pods reached service %s", len(pods), clusterIP)
+}
+
+// checkDNS resolves the service of the smoke pods from each of them.
+func checkDNS(kubectl Kubectl, pods []corev1.Pod, clusterIP, domain string) Result {
+	fqdn := fmt.Sprintf("%s.%s.svc.%s", name, Namespace, domain)
+	var failures []string
+	for _, from := range pods {
+		out, err := exec(kubectl, from.Name, "nslookup "+fqdn)
+		if err != nil || !strings.Contains(out, clusterIP) {
+			failures = append(failures, fmt.Sprintf("%s (%s): %v %s", from.Name, from.Spec.NodeName, err, lastLine(out)))
+		}
+	}
+	if len(failures) != 0 {
+		return result(CheckDNS, StatusFailed, "%d of %d pods failed to resolve %s: %s", len(failures), len(pods), fqdn, strings.Join(failures, "; "))
+	}
+	return result(CheckDNS, StatusPassed, "%d pods resolved %s to %s", len(pods), fqdn, clusterIP)
+}
+
+// checkPVC waits for the pod mounting the smoke PVC to be ready and writes a file to the PVC by it.
+func checkPVC(kubectl Kubectl, opts Options) Result {
+	if !opts.PVC {
+		return result(CheckPVC, StatusSkipped, "the PVC check is not enabled")
+	}
+	pod := name + "-pvc"
+	out, err := kubectl(fmt.Sprintf("wait --for=condition=Ready pod/%s -n %s --timeout=%ds", pod, Namespace, int(opts.Wait.Seconds())))
+	if err != nil {
+		phase, _ := kubectl(fmt.Sprintf("get pvc %s -n %s -o jsonpath={.status.phase}", name, Namespace))
+		return result(CheckPVC, StatusFailed, "pod %s mounting PVC %s is not ready, the PVC is %s: %v, %s",
+			pod, name, strings.TrimSpace(string(phase)), err, strings.TrimSpace(string(out)))
+	}
+	got, err := exec(kubectl, pod, "sh -c 'echo "+name+" > /data/smoke && cat /data/smoke'")
+	if err != nil || got != name {
+		return result(CheckPVC, StatusFailed, "failed to write PVC %s: %v, %s", name, err, got)
+	}
+	class := opts.StorageClass
+	if class == "" {
+		class = "the default storage class"
+	}
+	return result(CheckPVC, StatusPassed, "PVC %s of %s is mounted and written", name, class)
+}
+
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
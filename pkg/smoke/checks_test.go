@@ -0,0 +1,203 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smoke
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func smokePod(name, node, ip string, ready corev1.ConditionStatus, waiting string) corev1.Pod {
+	p := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: Namespace},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			PodIP:      ip,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+		},
+	}
+	if waiting != "" {
+		p.Status.Phase = corev1.PodPending
+		p.Status.ContainerStatuses = []corev1.ContainerStatus{{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: waiting}}}}
+	}
+	return p
+}
+
+// fakeCluster answers the kubectl of the checks like a cluster of pods, the requests to the pod ips in unreachable
+// time out.
+type fakeCluster struct {
+	t           *testing.T
+	pods        corev1.PodList
+	unreachable map[string]bool
+	noDNS       bool
+	pvcReady    bool
+}
+
+func (c *fakeCluster) kubectl(args string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(args, "rollout status"):
+		return nil, nil
+	case strings.HasPrefix(args, "get pods"):
+		return json.Marshal(c.pods)
+	case strings.HasPrefix(args, "get service"):
+		return []byte("10.96.0.100"), nil
+	case strings.HasPrefix(args, "get pvc"):
+		return []byte("Pending"), nil
+	case strings.HasPrefix(args, "wait"):
+		if !c.pvcReady {
+			return []byte("timed out waiting for the condition"), errors.New("exit status 1")
+		}
+		return nil, nil
+	case strings.HasPrefix(args, "exec"):
+		cmd := args[strings.Index(args, " -- ")+4:]
+		switch {
+		case strings.HasPrefix(cmd, "wget"):
+			if strings.Contains(cmd, "10.96.0.100") {
+				return []byte(c.pods.Items[0].Name), nil
+			}
+			for _, p := range c.pods.Items {
+				if strings.Contains(cmd, "http://"+p.Status.PodIP+":") {
+					if c.unreachable[p.Status.PodIP] {
+						return []byte("wget: download timed out"), errors.New("exit status 1")
+					}
+					return []byte(p.Name), nil
+				}
+			}
+		case strings.HasPrefix(cmd, "nslookup"):
+			if c.noDNS {
+				return []byte("nslookup: can't resolve 'sealer-smoke.sealer-smoke.svc.cluster.local'"), errors.New("exit status 1")
+			}
+			return []byte("Name:      sealer-smoke.sealer-smoke.svc.cluster.local\nAddress 1: 10.96.0.100"), nil
+		case strings.HasPrefix(cmd, "sh -c"):
+			return []byte(name), nil
+		}
+	}
+	c.t.Fatalf("unexpected kubectl %s", args)
+	return nil, nil
+}
+
+func statuses(results []Result) map[string]Status {
+	s := map[string]Status{}
+	for _, r := range results {
+		s[r.Check] = r.Status
+	}
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	pods := []corev1.Pod{
+		smokePod("sealer-smoke-b", "node-2", "100.64.2.5", corev1.ConditionTrue, ""),
+		smokePod("sealer-smoke-a", "node-1", "100.64.1.5", corev1.ConditionTrue, ""),
+	}
+	opts := Options{Image: DefaultImage, ClusterDomain: DefaultClusterDomain, Wait: DefaultWait}
+	tests := []struct {
+		name    string
+		cluster fakeCluster
+		opts    Options
+		want    map[string]Status
+	}{
+		{
+			name:    "all passed",
+			cluster: fakeCluster{pods: corev1.PodList{Items: pods}, pvcReady: true},
+			opts:    Options{Image: DefaultImage, ClusterDomain: DefaultClusterDomain, Wait: DefaultWait, PVC: true},
+			want: map[string]Status{CheckImagePull: StatusPassed, CheckPodToPod: StatusPassed, CheckPodToService: StatusPassed,
+				CheckDNS: StatusPassed, CheckPVC: StatusPassed},
+		},
+		{
+			name:    "pod unreachable across nodes",
+			cluster: fakeCluster{pods: corev1.PodList{Items: pods}, unreachable: map[string]bool{"100.64.2.5": true}, noDNS: true},
+			opts:    opts,
+			want: map[string]Status{CheckImagePull: StatusPassed, CheckPodToPod: StatusFailed, CheckPodToService: StatusPassed,
+				CheckDNS: StatusFailed, CheckPVC: StatusSkipped},
+		},
+		{
+			name: "image pull failed",
+			cluster: fakeCluster{pods: corev1.PodList{Items: []corev1.Pod{
+				smokePod("sealer-smoke-a", "node-1", "", corev1.ConditionFalse, "ImagePullBackOff"),
+			}}},
+			opts: Options{Image: DefaultImage, ClusterDomain: DefaultClusterDomain, Wait: DefaultWait, PVC: true},
+			want: map[string]Status{CheckImagePull: StatusFailed, CheckPodToPod: StatusSkipped, CheckPodToService: StatusSkipped,
+				CheckDNS: StatusSkipped, CheckPVC: StatusFailed},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cluster.t = t
+			results := Check(tt.cluster.kubectl, tt.opts)
+			got := statuses(results)
+			for check, want := range tt.want {
+				if got[check] != want {
+					t.Errorf("check %s = %s, want %s: %+v", check, got[check], want, results)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckImagePullReason(t *testing.T) {
+	c := &fakeCluster{t: t, pods: corev1.PodList{Items: []corev1.Pod{
+		smokePod("sealer-smoke-a", "node-1", "100.64.1.5", corev1.ConditionTrue, ""),
+		smokePod("sealer-smoke-b", "node-2", "", corev1.ConditionFalse, "ErrImagePull"),
+	}}}
+	r, ready := checkImagePull(c.kubectl, Options{Image: DefaultImage, Wait: DefaultWait})
+	if r.Status != StatusFailed || !strings.Contains(r.Message, "sealer-smoke-b on node-2: ErrImagePull") {
+		t.Errorf("checkImagePull() = %+v, want failed by ErrImagePull of sealer-smoke-b", r)
+	}
+	if len(ready) != 1 || ready[0].Name != "sealer-smoke-a" {
+		t.Errorf("checkImagePull() ready pods = %v, want sealer-smoke-a", ready)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	m, err := Manifest(Options{Image: DefaultImage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(m, "PersistentVolumeClaim") || !strings.Contains(m, "image: "+DefaultImage) {
+		t.Errorf("Manifest() without PVC = %s", m)
+	}
+	if m, err = Manifest(Options{Image: DefaultImage, PVC: true, StorageClass: "local-path"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m, "kind: PersistentVolumeClaim") || !strings.Contains(m, "storageClassName: local-path") {
+		t.Errorf("Manifest() with PVC = %s", m)
+	}
+}
+
+func TestReportPassed(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+		want    bool
+	}{
+		{"no results", nil, false},
+		{"passed and skipped", []Result{{Check: CheckDNS, Status: StatusPassed}, {Check: CheckPVC, Status: StatusSkipped}}, true},
+		{"one failed", []Result{{Check: CheckDNS, Status: StatusFailed}, {Check: CheckPVC, Status: StatusSkipped}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Report{Results: tt.results}
+			if got := r.Passed(); got != tt.want {
+				t.Errorf("Passed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
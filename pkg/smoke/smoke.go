@@ -0,0 +1,206 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smoke
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	// DefaultImage is the image of the smoke pods, it is pulled from the registry of sealer, so push it to the
+	// registry of the CloudImage. It needs sh, httpd, wget and nslookup of busybox.
+	DefaultImage         = "sea.hub:5000/library/busybox:1.28"
+	DefaultClusterDomain = "cluster.local"
+	DefaultWait          = 5 * time.Minute
+
+	// Namespace is where the smoke resources are created, it is deleted after the checks.
+	Namespace = "sealer-smoke"
+	// name is the name of the DaemonSet, Service, PVC and the label of the smoke pods.
+	name = "sealer-smoke"
+	port = 8080
+)
+
+type Options struct {
+	ClusterName string
+	// Image is the busybox image of the smoke pods, DefaultImage if empty.
+	Image string
+	// ClusterDomain is the dns domain of the cluster the service name is resolved in, DefaultClusterDomain if empty.
+	ClusterDomain string
+	// PVC checks a PVC can be provisioned and mounted, it is skipped if false.
+	PVC bool
+	// StorageClass is the storage class of the PVC, the default one of the cluster if empty.
+	StorageClass string
+	// Wait is how long the smoke pods are waited for to be ready, DefaultWait if zero.
+	Wait time.Duration
+}
+
+// The smoke pods run on all the nodes including the masters, each serves its name on port by httpd, so a request
+// tells which pod it reaches.
+var manifest = template.Must(template.New("smoke").Parse(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Namespace}}
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+spec:
+  selector:
+    matchLabels:
+      app: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app: {{.Name}}
+    spec:
+      tolerations:
+      - operator: Exists
+      containers:
+      - name: httpd
+        image: {{.Image}}
+        command: ["sh", "-c", "mkdir -p /www && hostname > /www/index.html && exec httpd -f -p {{.Port}} -h /www"]
+        ports:
+        - containerPort: {{.Port}}
+        readinessProbe:
+          tcpSocket:
+            port: {{.Port}}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+spec:
+  selector:
+    app: {{.Name}}
+  ports:
+  - port: {{.Port}}
+    targetPort: {{.Port}}
+{{- if .PVC}}
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: {{.Name}}
+  namespace: {{.Namespace}}
+spec:
+{{- if .StorageClass}}
+  storageClassName: {{.StorageClass}}
+{{- end}}
+  accessModes: ["ReadWriteOnce"]
+  resources:
+    requests:
+      storage: 16Mi
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: {{.Name}}-pvc
+  namespace: {{.Namespace}}
+spec:
+  tolerations:
+  - operator: Exists
+  containers:
+  - name: pvc
+    image: {{.Image}}
+    command: ["sleep", "86400"]
+    volumeMounts:
+    - name: data
+      mountPath: /data
+  volumes:
+  - name: data
+    persistentVolumeClaim:
+      claimName: {{.Name}}
+{{- end}}
+`))
+
+// Manifest returns the smoke resources of opts.
+func Manifest(opts Options) (string, error) {
+	var buf bytes.Buffer
+	err := manifest.Execute(&buf, map[string]interface{}{
+		"Namespace":    Namespace,
+		"Name":         name,
+		"Port":         port,
+		"Image":        opts.Image,
+		"PVC":          opts.PVC,
+		"StorageClass": opts.StorageClass,
+	})
+	return buf.String(), err
+}
+
+// Run deploys the smoke pods to the cluster by kubectl on master0, checks the image pull, networking, DNS and the
+// optional PVC of the cluster by them, and deletes them after the checks.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	if opts.ClusterDomain == "" {
+		opts.ClusterDomain = DefaultClusterDomain
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = DefaultWait
+	}
+	if opts.ClusterName == "" {
+		var err error
+		if opts.ClusterName, err = utils.GetDefaultClusterName(); err != nil {
+			return nil, err
+		}
+	}
+	cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(opts.ClusterName))
+	if err != nil {
+		return nil, err
+	}
+	master0 := cluster.GetMaster0Ip()
+	client, err := ssh.GetHostSSHClient(master0, cluster)
+	if err != nil {
+		return nil, err
+	}
+	client = ssh.WithContext(ctx, client)
+	kubectl := func(args string) ([]byte, error) {
+		return client.Cmd(master0, fmt.Sprintf("kubectl --kubeconfig %s %s", common.KubeAdminConf, args))
+	}
+
+	// the resources left by the last run are replaced.
+	deleteNamespace := fmt.Sprintf("delete namespace %s --ignore-not-found --wait", Namespace)
+	if out, err := kubectl(deleteNamespace); err != nil {
+		return nil, fmt.Errorf("failed to delete the last smoke test: %v, %s", err, out)
+	}
+	defer func() {
+		if out, err := kubectl(deleteNamespace); err != nil {
+			logger.Warn("failed to delete namespace %s from cluster %s: %v, %s", Namespace, cluster.Name, err, out)
+		}
+	}()
+
+	m, err := Manifest(opts)
+	if err != nil {
+		return nil, err
+	}
+	if out, err := kubectl(fmt.Sprintf("apply -f - <<'EOF'\n%s\nEOF", m)); err != nil {
+		return nil, fmt.Errorf("failed to deploy the smoke test: %v, %s", err, out)
+	}
+	logger.Info("running the smoke test on cluster %s, the smoke pods are waited for %s", cluster.Name, opts.Wait)
+	return &Report{Cluster: cluster.Name, Results: Check(kubectl, opts)}, nil
+}
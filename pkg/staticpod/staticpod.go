@@ -0,0 +1,208 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package staticpod
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+/*
+The static pod manifests in rootfs/staticpods of CloudImage, like node-problem-detector, are installed to the hosts
+matching the roles in their annotation, or to all hosts without it:
+
+apiVersion: v1
+kind: Pod
+metadata:
+  name: node-problem-detector
+  namespace: kube-system
+  annotations:
+    sea.aliyun.com/roles: "node,ingress"
+
+The installed manifests are kept in InstalledDir of each host, so the ones removed from CloudImage or no longer
+matching the roles of the host are removed by the next apply.
+*/
+
+const (
+	Dir             = "staticpods"
+	RolesAnnotation = common.AliDomain + "roles"
+	ManifestsDir    = "/etc/kubernetes/manifests"
+	// InstalledDir keeps the manifests sealer installed, outside the ones kubelet watches.
+	InstalledDir = "/etc/kubernetes/sealer-staticpods"
+
+	RemoteListInstalled = "ls -1 " + InstalledDir + " 2>/dev/null || true"
+	RemoteRemove        = "rm -f " + ManifestsDir + "/%[1]s " + InstalledDir + "/%[1]s"
+	RemoteInstall       = "mkdir -p " + ManifestsDir + " && cp -f " + InstalledDir + "/%[1]s " + ManifestsDir + "/%[1]s"
+)
+
+// reserved are the manifests of kubeadm and sealer, which the ones of CloudImage must not replace.
+var reserved = []string{
+	runtime.KubeAPIServer + ".yaml",
+	runtime.KubeControllerManager + ".yaml",
+	runtime.KubeScheduler + ".yaml",
+	runtime.Etcd + ".yaml",
+	filepath.Base(runtime.LvscareDefaultStaticPodFileName),
+	"kube-sealyun-lvscare.yaml",
+}
+
+// Manifest is a static pod manifest of CloudImage.
+type Manifest struct {
+	// Name is the file name of the manifest, like node-problem-detector.yaml
+	Name string
+	// Roles of the hosts to install it to, all hosts if empty
+	Roles []string
+}
+
+type manifestMeta struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// Load returns the static pod manifests in dir, nil if it does not exist.
+func Load(dir string) ([]Manifest, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifests []Manifest
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		if utils.InList(f.Name(), reserved) {
+			return nil, fmt.Errorf("static pod manifest %s conflicts with the one of kubernetes or sealer", f.Name())
+		}
+		data, err := ioutil.ReadFile(filepath.Clean(filepath.Join(dir, f.Name())))
+		if err != nil {
+			return nil, err
+		}
+		m, err := parse(f.Name(), data)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+func parse(name string, data []byte) (Manifest, error) {
+	var meta manifestMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return Manifest{}, fmt.Errorf("failed to decode static pod manifest %s: %v", name, err)
+	}
+	if meta.Kind != "Pod" {
+		return Manifest{}, fmt.Errorf("static pod manifest %s must be a Pod, not %q", name, meta.Kind)
+	}
+	m := Manifest{Name: name}
+	for _, role := range strings.Split(meta.Metadata.Annotations[RolesAnnotation], ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			m.Roles = append(m.Roles, role)
+		}
+	}
+	return m, nil
+}
+
+// Select returns the names of manifests to install to host of cluster, sorted.
+func Select(cluster *v2.Cluster, host string, manifests []Manifest) []string {
+	var names []string
+	for _, m := range manifests {
+		matched := len(m.Roles) == 0
+		for _, role := range m.Roles {
+			if utils.InList(host, cluster.GetIPSByRole(role)) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			names = append(names, m.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply installs the static pod manifests of the mounted CloudImage to the matching hosts in parallel, and removes
+// the ones installed before but not matching any more. It runs after the hosts are joined, as kubeadm requires
+// the manifests dir of masters to be empty.
+func Apply(cluster *v2.Cluster, hosts []string) error {
+	dir := filepath.Join(common.DefaultMountCloudImageDir(cluster.Name), Dir)
+	manifests, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			if err := applyHost(cluster, ip, dir, Select(cluster, ip, manifests)); err != nil {
+				errCh <- result.OnHost(ip, fmt.Errorf("failed to apply static pods on %s: %v", ip, err))
+			}
+		}(h)
+	}
+	wg.Wait()
+	return runtime.ReadChanError(errCh)
+}
+
+func applyHost(cluster *v2.Cluster, host, dir string, names []string) error {
+	sshClient, err := ssh.GetHostSSHClient(host, cluster)
+	if err != nil {
+		return fmt.Errorf("get host ssh client failed %v", err)
+	}
+	out, err := sshClient.Cmd(host, RemoteListInstalled)
+	if err != nil {
+		return err
+	}
+	var cmds []string
+	for _, name := range strings.Fields(string(out)) {
+		if utils.NotIn(name, names) {
+			logger.Info("remove static pod %s from %s", name, host)
+			cmds = append(cmds, fmt.Sprintf(RemoteRemove, name))
+		}
+	}
+	for _, name := range names {
+		if err := sshClient.Copy(host, filepath.Join(dir, name), filepath.Join(InstalledDir, name)); err != nil {
+			return err
+		}
+		cmds = append(cmds, fmt.Sprintf(RemoteInstall, name))
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	return sshClient.CmdAsync(host, cmds...)
+}
@@ -0,0 +1,117 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package staticpod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+const npd = `apiVersion: v1
+kind: Pod
+metadata:
+  name: node-problem-detector
+  namespace: kube-system
+  annotations:
+    sea.aliyun.com/roles: "node, ingress"
+spec:
+  containers:
+  - name: node-problem-detector
+    image: sea.hub:5000/node-problem-detector:v0.8.10
+`
+
+const auditbeat = `apiVersion: v1
+kind: Pod
+metadata:
+  name: auditbeat
+  namespace: kube-system
+spec:
+  containers:
+  - name: auditbeat
+    image: sea.hub:5000/auditbeat:7.17.0
+`
+
+func writeManifests(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "staticpods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	manifests, err := Load(writeManifests(t, map[string]string{
+		"npd.yaml":       npd,
+		"auditbeat.yaml": auditbeat,
+		"README.md":      "not a manifest",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Manifest{{Name: "auditbeat.yaml"}, {Name: "npd.yaml", Roles: []string{"node", "ingress"}}}
+	if !reflect.DeepEqual(manifests, want) {
+		t.Errorf("Load() = %v, want %v", manifests, want)
+	}
+
+	manifests, err = Load(filepath.Join(os.TempDir(), "no-such-staticpods"))
+	if err != nil || manifests != nil {
+		t.Errorf("Load() of missing dir = %v, %v, want nil", manifests, err)
+	}
+
+	for name, files := range map[string]map[string]string{
+		"reserved": {"kube-apiserver.yaml": auditbeat},
+		"not pod":  {"cm.yaml": "apiVersion: v1\nkind: ConfigMap\n"},
+		"invalid":  {"bad.yaml": "kind: [Pod"},
+	} {
+		if _, err := Load(writeManifests(t, files)); err == nil {
+			t.Errorf("Load() of %s manifest succeeded, want error", name)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	cluster := &v2.Cluster{Spec: v2.ClusterSpec{Hosts: []v2.Host{
+		{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+		{IPS: []string{"192.168.0.3"}, Roles: []string{"node"}},
+		{IPS: []string{"192.168.0.4"}, Roles: []string{"master", "ingress"}},
+	}}}
+	manifests := []Manifest{{Name: "npd.yaml", Roles: []string{"node", "ingress"}}, {Name: "auditbeat.yaml"}}
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{"192.168.0.2", []string{"auditbeat.yaml"}},
+		{"192.168.0.3", []string{"auditbeat.yaml", "npd.yaml"}},
+		{"192.168.0.4", []string{"auditbeat.yaml", "npd.yaml"}},
+	}
+	for _, tt := range tests {
+		if got := Select(cluster, tt.host, manifests); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Select(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
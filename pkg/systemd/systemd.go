@@ -0,0 +1,361 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/env"
+	"github.com/alibaba/sealer/pkg/result"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+/*
+The systemd units in rootfs/systemd of CloudImage are the components sealer manages on every host, like a helper
+of the registry. They are templates rendered for each host:
+
+[Service]
+ExecStart={{.Rootfs}}/bin/registry-helper --host {{.HostIP}} --data {{.Env.DATADISK}}
+
+sealer adds a drop-in of the restart policy in Clusterfile spec.units to each of them, and sends their output to
+journald, identified by the unit name. The units removed from CloudImage are stopped and removed by the next apply.
+*/
+
+const (
+	Dir            = "systemd"
+	UnitDir        = "/etc/systemd/system"
+	DropIn         = "10-sealer.conf"
+	suffix         = ".service"
+	dropInTemplate = `[Service]
+Restart=%s
+RestartSec=%d
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=%s
+`
+
+	DefaultRestart    = "always"
+	DefaultRestartSec = 5 * time.Second
+	// activeTimeout is how long a unit may take to become active after it is started.
+	activeTimeout = 10 * time.Second
+
+	// RemoteInstallUnit writes the unit and its drop-in, and restarts it only if either of them changed.
+	RemoteInstallUnit = `f=` + UnitDir + `/%[1]s; mkdir -p $f.d && echo '%[2]s' > $f.tmp && echo '%[3]s' > $f.d/` + DropIn + `.tmp && ` +
+		`if cmp -s $f.tmp $f && cmp -s $f.d/` + DropIn + `.tmp $f.d/` + DropIn + `; then rm -f $f.tmp $f.d/` + DropIn + `.tmp; ` +
+		`else mv -f $f.tmp $f && mv -f $f.d/` + DropIn + `.tmp $f.d/` + DropIn + ` && systemctl daemon-reload && ` +
+		`systemctl enable %[1]s && systemctl restart %[1]s; fi && systemctl start %[1]s`
+	RemoteRemoveUnit = `systemctl disable --now %[1]s; rm -rf ` + UnitDir + `/%[1]s ` + UnitDir + `/%[1]s.d && systemctl daemon-reload`
+	// RemoteListUnits lists the units sealer installed, which have its drop-in.
+	RemoteListUnits = `cd ` + UnitDir + ` && ls -1d *` + suffix + `.d/` + DropIn + ` 2>/dev/null | sed 's#\.d/` + DropIn + `$##' || true`
+	// RemoteRemoveUnits removes all units sealer installed, when the host is reset.
+	RemoteRemoveUnits = `for d in ` + UnitDir + `/*` + suffix + `.d/` + DropIn + `; do [ -f "$d" ] || continue; ` +
+		`u=$(basename $(dirname $d) .d); systemctl disable --now $u; rm -rf ` + UnitDir + `/$u ` + UnitDir + `/$u.d; done; systemctl daemon-reload`
+	RemoteIsActive    = "systemctl is-active %s || true"
+	RemoteRestart     = "systemctl restart %s"
+	RemoteUnitJournal = "journalctl -u %s -n 20 --no-pager"
+)
+
+// RestartPolicies are the values of Restart= of systemd.
+var RestartPolicies = []string{"no", "always", "on-success", "on-failure", "on-abnormal", "on-abort", "on-watchdog"}
+
+// TemplateData renders the units of CloudImage for a host.
+type TemplateData struct {
+	// Rootfs is the rootfs of cluster on the host
+	Rootfs string
+	HostIP string
+	// Env is the env of the host in Clusterfile
+	Env map[string]interface{}
+}
+
+// Unit is a systemd unit rendered for a host.
+type Unit struct {
+	Name    string
+	Content string
+	DropIn  string
+}
+
+// Status is the state of a unit on a host, like active, failed and inactive.
+type Status struct {
+	Host   string
+	Unit   string
+	Active string
+}
+
+// UnitName returns the unit name of a component, like sealer-registry.service of sealer-registry.
+func UnitName(name string) string {
+	if strings.HasSuffix(name, suffix) {
+		return name
+	}
+	return name + suffix
+}
+
+// Validate checks the restart policies in Clusterfile spec.units.
+func Validate(specs []v2.UnitSpec) error {
+	for _, s := range specs {
+		if s.Name == "" {
+			return fmt.Errorf("name of spec.units is required")
+		}
+		if s.Restart != "" && utils.NotIn(s.Restart, RestartPolicies) {
+			return fmt.Errorf("invalid restart policy %s of unit %s, must be one of %v", s.Restart, s.Name, RestartPolicies)
+		}
+		if s.RestartSec.Duration < 0 {
+			return fmt.Errorf("restartSec of unit %s must not be negative", s.Name)
+		}
+	}
+	return nil
+}
+
+// dropIn returns the drop-in of the unit with its restart policy in specs.
+func dropIn(name string, specs []v2.UnitSpec) string {
+	restart, restartSec := DefaultRestart, DefaultRestartSec
+	for _, s := range specs {
+		if UnitName(s.Name) != name {
+			continue
+		}
+		if s.Restart != "" {
+			restart = s.Restart
+		}
+		if s.RestartSec.Duration != 0 {
+			restartSec = s.RestartSec.Duration
+		}
+	}
+	return fmt.Sprintf(dropInTemplate, restart, int(restartSec.Seconds()), strings.TrimSuffix(name, suffix))
+}
+
+// Load renders the units in dir for host of cluster, sorted by name, nil if dir does not exist.
+func Load(cluster *v2.Cluster, host, dir string) ([]Unit, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := TemplateData{
+		Rootfs: common.DefaultTheClusterRootfsDir(cluster.Name),
+		HostIP: host,
+		Env:    env.GetHostEnv(cluster, host),
+	}
+	var units []Unit
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), suffix) {
+			continue
+		}
+		t, err := template.ParseFiles(filepath.Clean(filepath.Join(dir, f.Name())))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse unit %s: %v", f.Name(), err)
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("failed to render unit %s for %s: %v", f.Name(), host, err)
+		}
+		units = append(units, Unit{Name: f.Name(), Content: buf.String(), DropIn: dropIn(f.Name(), cluster.Spec.Units)})
+	}
+	return units, nil
+}
+
+// Install installs, enables and starts the units of the mounted CloudImage on hosts in parallel, and removes
+// the ones installed before but removed from CloudImage. It fails if any of them is not active.
+func Install(cluster *v2.Cluster, hosts []string) error {
+	if err := Validate(cluster.Spec.Units); err != nil {
+		return err
+	}
+	dir := filepath.Join(common.DefaultMountCloudImageDir(cluster.Name), Dir)
+	return forEachHost(hosts, func(ip string) error {
+		units, err := Load(cluster, ip, dir)
+		if err != nil {
+			return err
+		}
+		return installHost(cluster, ip, units)
+	})
+}
+
+func installHost(cluster *v2.Cluster, host string, units []Unit) error {
+	client, err := ssh.GetHostSSHClient(host, cluster)
+	if err != nil {
+		return fmt.Errorf("get host ssh client failed %v", err)
+	}
+	installed, err := listUnits(client, host)
+	if err != nil {
+		return err
+	}
+	var names, cmds []string
+	for _, u := range units {
+		names = append(names, u.Name)
+		cmds = append(cmds, fmt.Sprintf(RemoteInstallUnit, u.Name, u.Content, u.DropIn))
+	}
+	for _, name := range installed {
+		if utils.NotIn(name, names) {
+			logger.Info("remove unit %s from %s", name, host)
+			cmds = append(cmds, fmt.Sprintf(RemoteRemoveUnit, name))
+		}
+	}
+	if len(cmds) == 0 {
+		return nil
+	}
+	if err := client.CmdAsync(host, cmds...); err != nil {
+		return fmt.Errorf("failed to install units on %s: %v", host, err)
+	}
+	for _, name := range names {
+		if err := waitActive(client, host, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restart restarts the unit of component name on the hosts it is installed on, and waits for it active.
+func Restart(cluster *v2.Cluster, hosts []string, name string) error {
+	unit := UnitName(name)
+	var mu sync.Mutex
+	var restarted []string
+	err := forEachHost(hosts, func(ip string) error {
+		client, err := ssh.GetHostSSHClient(ip, cluster)
+		if err != nil {
+			return fmt.Errorf("get host ssh client failed %v", err)
+		}
+		installed, err := listUnits(client, ip)
+		if err != nil || utils.NotIn(unit, installed) {
+			return err
+		}
+		logger.Info("restart %s on %s", unit, ip)
+		if err := client.CmdAsync(ip, fmt.Sprintf(RemoteRestart, unit)); err != nil {
+			return fmt.Errorf("failed to restart %s on %s: %v", unit, ip, err)
+		}
+		mu.Lock()
+		restarted = append(restarted, ip)
+		mu.Unlock()
+		return waitActive(client, ip, unit)
+	})
+	if err != nil {
+		return err
+	}
+	if len(restarted) == 0 {
+		return fmt.Errorf("component %s is not installed on %v", name, hosts)
+	}
+	return nil
+}
+
+// GetStatus returns the state of the units sealer installed on hosts, only the unit of component name if it is set.
+func GetStatus(cluster *v2.Cluster, hosts []string, name string) ([]Status, error) {
+	var mu sync.Mutex
+	var statuses []Status
+	err := forEachHost(hosts, func(ip string) error {
+		client, err := ssh.GetHostSSHClient(ip, cluster)
+		if err != nil {
+			return fmt.Errorf("get host ssh client failed %v", err)
+		}
+		installed, err := listUnits(client, ip)
+		if err != nil {
+			return err
+		}
+		for _, unit := range installed {
+			if name != "" && unit != UnitName(name) {
+				continue
+			}
+			active, err := isActive(client, ip, unit)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			statuses = append(statuses, Status{Host: ip, Unit: unit, Active: active})
+			mu.Unlock()
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Host != statuses[j].Host {
+			return statuses[i].Host < statuses[j].Host
+		}
+		return statuses[i].Unit < statuses[j].Unit
+	})
+	return statuses, err
+}
+
+func listUnits(client ssh.Interface, host string) ([]string, error) {
+	out, err := client.Cmd(host, RemoteListUnits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list units on %s: %v", host, err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+func isActive(client ssh.Interface, host, unit string) (string, error) {
+	out, err := client.Cmd(host, fmt.Sprintf(RemoteIsActive, unit))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the state of %s on %s: %v", unit, host, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// waitActive waits for the unit active, the recent journal of the unit is in the error if it is not.
+func waitActive(client ssh.Interface, host, unit string) error {
+	var active string
+	for deadline := time.Now().Add(activeTimeout); ; time.Sleep(time.Second) {
+		var err error
+		if active, err = isActive(client, host, unit); err != nil {
+			return err
+		}
+		if active == "active" || time.Now().After(deadline) {
+			break
+		}
+	}
+	if active == "active" {
+		return nil
+	}
+	journal, _ := client.Cmd(host, fmt.Sprintf(RemoteUnitJournal, unit))
+	return fmt.Errorf("unit %s on %s is %s, its journal:\n%s", unit, host, active, journal)
+}
+
+func forEachHost(hosts []string, f func(ip string) error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var (
+		errs   []error
+		failed []string
+	)
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			if err := f(ip); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				failed = append(failed, ip)
+				mu.Unlock()
+			}
+		}(h)
+	}
+	wg.Wait()
+	if len(errs) == 0 {
+		return nil
+	}
+	return &result.Error{Hosts: failed, Err: utilerrors.NewAggregate(errs)}
+}
@@ -0,0 +1,104 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"registry-helper.service": "[Service]\nExecStart={{.Rootfs}}/bin/registry-helper --host {{.HostIP}} --data {{.Env.DATADISK}}\n",
+		"README.md":               "not a unit",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cluster := &v2.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"},
+		Spec: v2.ClusterSpec{
+			Env: []string{"DATADISK=/data"},
+			Hosts: []v2.Host{
+				{IPS: []string{"192.168.0.2"}, Roles: []string{"master"}},
+			},
+			Units: []v2.UnitSpec{{Name: "registry-helper", Restart: "on-failure", RestartSec: metav1.Duration{Duration: 10 * time.Second}}},
+		},
+	}
+	units, err := Load(cluster, "192.168.0.2", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 1 || units[0].Name != "registry-helper.service" {
+		t.Fatalf("Load() = %v, want registry-helper.service only", units)
+	}
+	want := "ExecStart=/var/lib/sealer/data/my-cluster/rootfs/bin/registry-helper --host 192.168.0.2 --data /data"
+	if !strings.Contains(units[0].Content, want) {
+		t.Errorf("rendered unit %q, want %q", units[0].Content, want)
+	}
+	for _, line := range []string{"Restart=on-failure", "RestartSec=10", "SyslogIdentifier=registry-helper"} {
+		if !strings.Contains(units[0].DropIn, line) {
+			t.Errorf("drop-in %q has no %s", units[0].DropIn, line)
+		}
+	}
+
+	units, err = Load(cluster, "192.168.0.2", filepath.Join(dir, "no-such-dir"))
+	if err != nil || units != nil {
+		t.Errorf("Load() of missing dir = %v, %v, want nil", units, err)
+	}
+}
+
+func TestDropInDefault(t *testing.T) {
+	got := dropIn("sealer-registry.service", []v2.UnitSpec{{Name: "other", Restart: "no"}})
+	for _, line := range []string{"Restart=always", "RestartSec=5", "StandardOutput=journal"} {
+		if !strings.Contains(got, line) {
+			t.Errorf("drop-in %q has no %s", got, line)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []v2.UnitSpec
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"valid", []v2.UnitSpec{{Name: "sealer-registry", Restart: "on-failure"}}, false},
+		{"no name", []v2.UnitSpec{{Restart: "always"}}, true},
+		{"invalid restart", []v2.UnitSpec{{Name: "sealer-registry", Restart: "sometimes"}}, true},
+		{"negative restartSec", []v2.UnitSpec{{Name: "sealer-registry", RestartSec: metav1.Duration{Duration: -time.Second}}}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.specs); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+type Kind string
+
+const (
+	SSHConnect        Kind = "ssh-connect"
+	Init              Kind = "init"
+	Join              Kind = "join"
+	ImageDistribution Kind = "image-distribution"
+	Drain             Kind = "drain"
+	HealthCheck       Kind = "health-check"
+)
+
+var defaults = map[Kind]time.Duration{
+	SSHConnect:        15 * time.Second,
+	Init:              30 * time.Minute,
+	Join:              30 * time.Minute,
+	ImageDistribution: 30 * time.Minute,
+	Drain:             10 * time.Minute,
+	HealthCheck:       5 * time.Minute,
+}
+
+var overrides = map[Kind]time.Duration{}
+
+// Kinds returns the names of all timeouts, for the usage of the --timeout flag.
+func Kinds() []string {
+	var kinds []string
+	for k := range defaults {
+		kinds = append(kinds, string(k))
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// SetOverrides parses the --timeout flag, like: init=20m,join=40m, its timeouts override the ones in Clusterfile.
+func SetOverrides(flags []string) error {
+	for _, f := range flags {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid timeout %s, it should be NAME=DURATION", f)
+		}
+		kind := Kind(kv[0])
+		if _, ok := defaults[kind]; !ok {
+			return fmt.Errorf("unknown timeout %s, it should be one of %s", kv[0], strings.Join(Kinds(), ", "))
+		}
+		d, err := time.ParseDuration(kv[1])
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %s of timeout %s", kv[1], kind)
+		}
+		overrides[kind] = d
+	}
+	ssh.ConnectTimeout = overrides[SSHConnect]
+	return nil
+}
+
+// Of returns the timeout of kind for cluster, which is the --timeout flag, Clusterfile or the default in order.
+func Of(cluster *v2.Cluster, kind Kind) time.Duration {
+	if d, ok := overrides[kind]; ok {
+		return d
+	}
+	if d := fromSpec(cluster.Spec.Timeouts, kind); d > 0 {
+		return d
+	}
+	return defaults[kind]
+}
+
+func fromSpec(spec v2.TimeoutsSpec, kind Kind) time.Duration {
+	switch kind {
+	case SSHConnect:
+		return spec.SSHConnect.Duration
+	case Init:
+		return spec.Init.Duration
+	case Join:
+		return spec.Join.Duration
+	case ImageDistribution:
+		return spec.ImageDistribution.Duration
+	case Drain:
+		return spec.Drain.Duration
+	case HealthCheck:
+		return spec.HealthCheck.Duration
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeout
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestOf(t *testing.T) {
+	defer func() {
+		overrides = map[Kind]time.Duration{}
+	}()
+	cluster := &v2.Cluster{}
+	cluster.Spec.Timeouts.Init = metav1.Duration{Duration: 20 * time.Minute}
+	cluster.Spec.Timeouts.Join = metav1.Duration{Duration: 20 * time.Minute}
+
+	if err := SetOverrides([]string{"join=40m"}); err != nil {
+		t.Fatalf("SetOverrides() error = %v", err)
+	}
+	tests := []struct {
+		kind Kind
+		want time.Duration
+	}{
+		{Init, 20 * time.Minute},
+		{Join, 40 * time.Minute},
+		{Drain, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := Of(cluster, tt.kind); got != tt.want {
+			t.Errorf("Of(%s) = %s, want %s", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestSetOverrides(t *testing.T) {
+	defer func() {
+		overrides = map[Kind]time.Duration{}
+	}()
+	for _, flags := range [][]string{{"init"}, {"reboot=1m"}, {"init=1"}, {"init=-1m"}} {
+		if err := SetOverrides(flags); err == nil {
+			t.Errorf("SetOverrides(%v) want error, got nil", flags)
+		}
+	}
+}
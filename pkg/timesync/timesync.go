@@ -0,0 +1,118 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesync
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	RemoteInstallChrony = `command -v chronyd >/dev/null 2>&1 || yum install -y chrony || apt-get install -y chrony`
+	// debian family keeps the config in /etc/chrony and names the service chrony.
+	RemoteWriteChronyConf = `if [ -d /etc/chrony ]; then f=/etc/chrony/chrony.conf; else f=/etc/chrony.conf; fi; printf '%%s\n' %s > $f`
+	RemoteRestartChrony   = `if systemctl list-unit-files | grep -q '^chronyd'; then s=chronyd; else s=chrony; fi; systemctl enable $s && systemctl restart $s`
+)
+
+const chronyConfBase = `driftfile /var/lib/chrony/drift
+makestep 1.0 3
+rtcsync
+logdir /var/log/chrony
+`
+
+// chronyConf returns the chrony config of host, master0 serves its local clock to the clients when no NTP server is specified.
+func chronyConf(spec v2.TimeSyncSpec, master0, host string, clients []string) string {
+	var sb strings.Builder
+	servers := spec.Servers
+	if len(servers) == 0 && host != master0 {
+		servers = []string{master0}
+	}
+	for _, s := range servers {
+		sb.WriteString(fmt.Sprintf("server %s iburst\n", s))
+	}
+	if len(spec.Servers) == 0 && host == master0 {
+		sb.WriteString("local stratum 10\n")
+		for _, c := range clients {
+			if c != master0 {
+				sb.WriteString(fmt.Sprintf("allow %s\n", c))
+			}
+		}
+	}
+	sb.WriteString(chronyConfBase)
+	return sb.String()
+}
+
+// Setup installs and configs chrony on hosts if time sync is enabled in Clusterfile.
+func Setup(cluster *v2.Cluster, hosts []string) error {
+	spec := cluster.Spec.TimeSync
+	if !spec.Enabled || len(hosts) == 0 {
+		return nil
+	}
+	master0 := cluster.GetMaster0Ip()
+	if len(spec.Servers) == 0 && !utils.NotInIPList(master0, hosts) {
+		logger.Info("no NTP server specified, use master0 %s as the time source", master0)
+		// master0 must serve the time before the others sync from it.
+		if err := setupHost(cluster, master0); err != nil {
+			return err
+		}
+	}
+
+	errCh := make(chan error, len(hosts))
+	defer close(errCh)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		if len(spec.Servers) == 0 && h == master0 {
+			continue
+		}
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			if err := setupHost(cluster, ip); err != nil {
+				errCh <- result.OnHost(ip, err)
+			}
+		}(h)
+	}
+	wg.Wait()
+	return runtime.ReadChanError(errCh)
+}
+
+func setupHost(cluster *v2.Cluster, ip string) error {
+	sshClient, err := ssh.GetHostSSHClient(ip, cluster)
+	if err != nil {
+		return fmt.Errorf("get host ssh client failed %v", err)
+	}
+	conf := chronyConf(cluster.Spec.TimeSync, cluster.GetMaster0Ip(), ip, clusterIPs(cluster))
+	err = sshClient.CmdAsync(ip, RemoteInstallChrony, fmt.Sprintf(RemoteWriteChronyConf, utils.ShellQuote(conf)), RemoteRestartChrony)
+	if err != nil {
+		return fmt.Errorf("failed to setup chrony on %s: %v", ip, err)
+	}
+	return nil
+}
+
+func clusterIPs(cluster *v2.Cluster) []string {
+	var ips []string
+	for _, host := range cluster.Spec.Hosts {
+		ips = append(ips, host.IPS...)
+	}
+	return ips
+}
@@ -0,0 +1,67 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timesync
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+func TestChronyConf(t *testing.T) {
+	const master0 = "192.168.0.2"
+	clients := []string{master0, "192.168.0.3", "192.168.0.4"}
+	tests := []struct {
+		name    string
+		servers []string
+		host    string
+		want    string
+	}{
+		{"master0 serves its local clock", nil, master0, "local stratum 10\nallow 192.168.0.3\nallow 192.168.0.4\n"},
+		{"others sync from master0", nil, "192.168.0.3", "server 192.168.0.2 iburst\n"},
+		{"master0 with servers", []string{"ntp1.aliyun.com", "ntp2.aliyun.com"}, master0, "server ntp1.aliyun.com iburst\nserver ntp2.aliyun.com iburst\n"},
+		{"others with servers", []string{"ntp1.aliyun.com"}, "192.168.0.3", "server ntp1.aliyun.com iburst\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chronyConf(v2.TimeSyncSpec{Enabled: true, Servers: tt.servers}, master0, tt.host, clients)
+			if want := tt.want + chronyConfBase; got != want {
+				t.Errorf("chronyConf() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRemoteWriteChronyConf(t *testing.T) {
+	conf := chronyConf(v2.TimeSyncSpec{Enabled: true, Servers: []string{"ntp.example.com' && touch /tmp/x '"}}, "192.168.0.2", "192.168.0.3", nil)
+	dir := t.TempDir()
+	cmd := strings.Replace(fmt.Sprintf(RemoteWriteChronyConf, utils.ShellQuote(conf)), "/etc/chrony", filepath.Join(dir, "chrony"), -1)
+	if out, err := exec.Command("sh", "-c", cmd).CombinedOutput(); err != nil {
+		t.Fatalf("RemoteWriteChronyConf error = %v: %s", err, out)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "chrony.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSuffix(string(data), "\n"); got != conf {
+		t.Errorf("written chrony.conf = %q, want %q", got, conf)
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils/ssh"
+	"github.com/alibaba/sealer/version"
+)
+
+// The environment variables of the OpenTelemetry OTLP exporter, the spans are only exported if an endpoint is set.
+const (
+	EnvEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	EnvHeaders        = "OTEL_EXPORTER_OTLP_HEADERS"
+	EnvServiceName    = "OTEL_SERVICE_NAME"
+
+	defaultServiceName = "sealer"
+	scopeName          = "github.com/alibaba/sealer"
+	// maxBatch spans are exported at once while sealer runs, the rest on Shutdown.
+	maxBatch = 512
+)
+
+// Attribute is a string attribute of a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+func Attr(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+type span struct {
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	err        error
+	info       *SpanInfo
+}
+
+type spanKey struct{}
+
+// SpanInfo is a span seen by the observers.
+type SpanInfo struct {
+	Name       string
+	Attributes []Attribute
+	// Parent is nil for the root span of a trace.
+	Parent *SpanInfo
+}
+
+// Attr returns the value of attribute key of s, empty if it is not set.
+func (s *SpanInfo) Attr(key string) string {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Observer is notified when the spans start and end, whether they are exported or not, like the progress of
+// apply. It is called on the goroutine of the span, so it must not block.
+type Observer interface {
+	SpanStarted(s *SpanInfo)
+	SpanEnded(s *SpanInfo, err error)
+}
+
+var (
+	observerLock sync.Mutex
+	observers    []Observer
+)
+
+// AddObserver makes o observe the spans and the ones of the ssh clients, until the returned func is called.
+func AddObserver(o Observer) func() {
+	observerLock.Lock()
+	defer observerLock.Unlock()
+	observers = append(observers, o)
+	ssh.SetTracer(sshTracer{})
+	return func() {
+		observerLock.Lock()
+		defer observerLock.Unlock()
+		for i := range observers {
+			if observers[i] == o {
+				observers = append(observers[:i:i], observers[i+1:]...)
+				break
+			}
+		}
+		if len(observers) == 0 && current == nil {
+			ssh.SetTracer(nil)
+		}
+	}
+}
+
+func currentObservers() []Observer {
+	observerLock.Lock()
+	defer observerLock.Unlock()
+	return observers
+}
+
+// exporter sends the ended spans to an OTLP/HTTP endpoint in the JSON encoding.
+type exporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+
+	lock  sync.Mutex
+	spans []*span
+	wg    sync.WaitGroup
+}
+
+var current *exporter
+
+// Init enables tracing if an OTLP endpoint is set in the environment,
+// the ssh clients start the spans of their remote commands and file transfers from then on.
+func Init() error {
+	endpoint := os.Getenv(EnvTracesEndpoint)
+	if endpoint == "" {
+		base := os.Getenv(EnvEndpoint)
+		if base == "" {
+			return nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	headers, err := parseHeaders(os.Getenv(EnvHeaders))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", EnvHeaders, err)
+	}
+	serviceName := os.Getenv(EnvServiceName)
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	current = &exporter{
+		endpoint:    endpoint,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+	ssh.SetTracer(sshTracer{})
+	return nil
+}
+
+// parseHeaders parses comma separated key=value pairs with URL encoded values.
+func parseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("header %s is not key=value", pair)
+		}
+		value, err := url.QueryUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, err
+		}
+		headers[strings.TrimSpace(kv[0])] = value
+	}
+	return headers, nil
+}
+
+func Enabled() bool {
+	return current != nil
+}
+
+// Start starts the span name as the child of the span in ctx, or a new trace if there is none,
+// end must be called with the result once it finished. Start does nothing if tracing is not enabled
+// and there is no observer.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, func(err error)) {
+	e := current
+	obs := currentObservers()
+	if e == nil && len(obs) == 0 {
+		return ctx, func(error) {}
+	}
+	s := &span{name: name, start: time.Now(), attributes: attributes}
+	s.info = &SpanInfo{Name: name, Attributes: attributes}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+		s.info.Parent = parent.info
+	} else {
+		_, _ = rand.Read(s.traceID[:])
+	}
+	_, _ = rand.Read(s.spanID[:])
+	for _, o := range obs {
+		o.SpanStarted(s.info)
+	}
+	var once sync.Once
+	return context.WithValue(ctx, spanKey{}, s), func(err error) {
+		once.Do(func() {
+			s.end = time.Now()
+			s.err = err
+			if e != nil {
+				e.record(s)
+			}
+			for _, o := range obs {
+				o.SpanEnded(s.info, err)
+			}
+		})
+	}
+}
+
+func (e *exporter) record(s *span) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, s)
+	if len(e.spans) < maxBatch {
+		return
+	}
+	batch := e.spans
+	e.spans = nil
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		if err := e.export(batch); err != nil {
+			logger.Warn("%v", err)
+		}
+	}()
+}
+
+// Shutdown exports the spans not exported yet after the running exports finished.
+func Shutdown() error {
+	e := current
+	if e == nil {
+		return nil
+	}
+	e.wg.Wait()
+	e.lock.Lock()
+	batch := e.spans
+	e.spans = nil
+	e.lock.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return e.export(batch)
+}
+
+func (e *exporter) export(spans []*span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans to %s: %v", e.endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to export spans to %s: unexpected status %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// The JSON encoding of the OTLP ExportTraceServiceRequest.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+const (
+	spanKindInternal = 1
+	statusCodeOK     = 1
+	statusCodeError  = 2
+)
+
+func (e *exporter) request(spans []*span) otlpRequest {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: scopeName, Version: version.Get().GitVersion}}
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        attributes(s.attributes),
+			Status:            otlpStatus{Code: statusCodeOK},
+		}
+		if s.parentID != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		if s.err != nil {
+			o.Status = otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+		}
+		scope.Spans = append(scope.Spans, o)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes([]Attribute{Attr("service.name", e.serviceName)})},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}}
+}
+
+func attributes(attrs []Attribute) []otlpAttribute {
+	var o []otlpAttribute
+	for _, a := range attrs {
+		o = append(o, otlpAttribute{Key: a.Key, Value: otlpValue{StringValue: a.Value}})
+	}
+	return o
+}
+
+type sshTracer struct{}
+
+func (sshTracer) StartSpan(ctx context.Context, name string, attributes map[string]string) func(err error) {
+	var attrs []Attribute
+	for k, v := range attributes {
+		attrs = append(attrs, Attr(k, v))
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+	_, end := Start(ctx, name, attrs...)
+	return end
+}
@@ -0,0 +1,92 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+func TestExport(t *testing.T) {
+	var (
+		got    otlpRequest
+		path   string
+		header string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		header = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+	}))
+	defer server.Close()
+	os.Setenv(EnvEndpoint, server.URL+"/")
+	os.Setenv(EnvHeaders, "Authorization=Bearer%20token")
+	defer func() {
+		os.Unsetenv(EnvEndpoint)
+		os.Unsetenv(EnvHeaders)
+		current = nil
+		ssh.SetTracer(nil)
+	}()
+	if err := Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	ctx, endApply := Start(context.Background(), "apply", Attr("cluster", "my-cluster"))
+	_, endPhase := Start(ctx, "Init")
+	endPhase(errors.New("init master0 failed"))
+	endApply(nil)
+	if err := Shutdown(); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if path != "/v1/traces" || header != "Bearer token" {
+		t.Errorf("exported to %s with Authorization %q", path, header)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	phase, apply := spans[0], spans[1]
+	if phase.TraceID != apply.TraceID || phase.ParentSpanID != apply.SpanID || apply.ParentSpanID != "" {
+		t.Errorf("span Init is not the child of span apply: %+v %+v", phase, apply)
+	}
+	if phase.Status.Code != statusCodeError || apply.Status.Code != statusCodeOK {
+		t.Errorf("unexpected status %+v %+v", phase.Status, apply.Status)
+	}
+	if len(apply.Attributes) != 1 || apply.Attributes[0].Value.StringValue != "my-cluster" {
+		t.Errorf("unexpected attributes %+v", apply.Attributes)
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	ctx := context.Background()
+	got, end := Start(ctx, "apply")
+	end(nil)
+	if got != ctx || Enabled() {
+		t.Errorf("Start() started a span while tracing is disabled")
+	}
+}
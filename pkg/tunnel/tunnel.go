@@ -0,0 +1,111 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const (
+	DefaultRegistryListen  = "127.0.0.1:5000"
+	DefaultAPIServerListen = "127.0.0.1:6443"
+	// the apiserver on master0, its cert contains 127.0.0.1 which the tunnel kubeconfig uses.
+	apiServerAddr    = "127.0.0.1:6443"
+	tunnelKubeconfig = "tunnel.kubeconfig"
+)
+
+// Tunnel forwards a local address to a service of cluster through SSH to the host running it.
+type Tunnel struct {
+	cluster *v2.Cluster
+	// Host is the SSH host which dials Remote
+	Host   string
+	Remote string
+	Local  string
+}
+
+// NewRegistryTunnel forwards local to the registry, docker trusts registries on 127.0.0.0/8 without the cert of sea.hub.
+func NewRegistryTunnel(cluster *v2.Cluster, local string) *Tunnel {
+	cf := runtime.GetRegistryConfig(common.DefaultTheClusterRootfsDir(cluster.Name), runtime.GetMaster0Ip(cluster))
+	ip, _ := utils.GetSSHHostIPAndPort(cf.IP)
+	return &Tunnel{
+		cluster: cluster,
+		Host:    ip,
+		Remote:  net.JoinHostPort(ip, cf.Port),
+		Local:   local,
+	}
+}
+
+func NewAPIServerTunnel(cluster *v2.Cluster, local string) *Tunnel {
+	return &Tunnel{
+		cluster: cluster,
+		Host:    runtime.GetMaster0Ip(cluster),
+		Remote:  apiServerAddr,
+		Local:   local,
+	}
+}
+
+// Run forwards until the process exits.
+func (t *Tunnel) Run() error {
+	sshClient, err := ssh.GetHostSSHClient(t.Host, t.cluster)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", t.Local)
+	if err != nil {
+		return fmt.Errorf("failed to listen %s: %v", t.Local, err)
+	}
+	defer listener.Close()
+	logger.Info("forwarding %s to %s through %s", t.Local, t.Remote, t.Host)
+	return sshClient.Forward(t.Host, listener, t.Remote)
+}
+
+// WriteKubeconfig writes the admin kubeconfig of master0 with the server pointed to the local address of tunnel.
+func (t *Tunnel) WriteKubeconfig(path string) (string, error) {
+	if path == "" {
+		path = filepath.Join(common.GetClusterWorkDir(t.cluster.Name), tunnelKubeconfig)
+	}
+	sshClient, err := ssh.GetHostSSHClient(t.Host, t.cluster)
+	if err != nil {
+		return "", err
+	}
+	if err := utils.MkFileFullPathDir(path); err != nil {
+		return "", err
+	}
+	if err := sshClient.Fetch(t.Host, path, common.KubeAdminConf); err != nil {
+		return "", fmt.Errorf("failed to get kubeconfig from %s: %v", t.Host, err)
+	}
+	host, port, err := net.SplitHostPort(t.Local)
+	if err != nil {
+		return "", err
+	}
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	if err := runtime.RewriteKubeconfigServer(path, "https://"+net.JoinHostPort(host, port)); err != nil {
+		return "", err
+	}
+	// it holds the credential of cluster admin, and the fetched file may be readable by others.
+	return path, os.Chmod(path, 0600)
+}
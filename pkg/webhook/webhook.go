@@ -0,0 +1,204 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sealer/logger"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+// The types of the events of the cluster lifecycle.
+const (
+	ApplyStarted         = "apply-started"
+	ApplySucceeded       = "apply-succeeded"
+	ApplyFailed          = "apply-failed"
+	NodesJoined          = "nodes-joined"
+	NodesDeleted         = "nodes-deleted"
+	ClusterDeleted       = "cluster-deleted"
+	EncryptionKeyRotated = "encryption-key-rotated"
+)
+
+// The types of the webhooks.
+const (
+	TypeGeneric  = "generic"
+	TypeSlack    = "slack"
+	TypeDingTalk = "dingtalk"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the body of the generic webhook keyed by its secret.
+const SignatureHeader = "X-Sealer-Signature-256"
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Event is posted as JSON to the generic webhooks.
+type Event struct {
+	Type    string    `json:"type"`
+	Cluster string    `json:"cluster"`
+	Image   string    `json:"image,omitempty"`
+	Hosts   []string  `json:"hosts,omitempty"`
+	Message string    `json:"message,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Notify sends the event of type on cluster to its webhooks subscribing it, err is the error of a failed operation.
+// The webhooks failed to be sent are only warned, they never fail the operation.
+func Notify(cluster *v2.Cluster, eventType string, hosts []string, err error) {
+	if cluster == nil || len(cluster.Spec.Webhooks) == 0 {
+		return
+	}
+	e := Event{
+		Type:    eventType,
+		Cluster: cluster.Name,
+		Image:   cluster.Spec.Image,
+		Hosts:   hosts,
+		Time:    time.Now(),
+	}
+	e.Message = e.summary()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	var wg sync.WaitGroup
+	for _, w := range cluster.Spec.Webhooks {
+		if len(w.Events) > 0 && utils.NotIn(eventType, w.Events) {
+			continue
+		}
+		wg.Add(1)
+		go func(w v2.WebhookSpec) {
+			defer wg.Done()
+			if err := Send(w, e); err != nil {
+				logger.Warn("failed to send event %s to webhook %s: %v", eventType, redactURL(w.URL), err)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func (e Event) summary() string {
+	s := fmt.Sprintf("[sealer] %s of cluster %s", e.Type, e.Cluster)
+	if e.Image != "" {
+		s += fmt.Sprintf(" with image %s", e.Image)
+	}
+	if len(e.Hosts) > 0 {
+		s += fmt.Sprintf(", hosts: %s", strings.Join(e.Hosts, ","))
+	}
+	return s
+}
+
+// Send posts e to webhook w in its format.
+func Send(w v2.WebhookSpec, e Event) error {
+	target := w.URL
+	var payload interface{}
+	switch w.Type {
+	case "", TypeGeneric:
+		payload = e
+	case TypeSlack:
+		payload = map[string]string{"text": e.text()}
+	case TypeDingTalk:
+		payload = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": e.text()},
+		}
+		if w.Secret != "" {
+			var err error
+			if target, err = signDingTalk(target, w.Secret, time.Now()); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown webhook type %s, must be one of %s, %s and %s", w.Type, TypeGeneric, TypeSlack, TypeDingTalk)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return stripURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if (w.Type == "" || w.Type == TypeGeneric) && w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return stripURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (e Event) text() string {
+	if e.Error == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s\nerror: %s", e.Message, e.Error)
+}
+
+// signDingTalk adds the timestamp and the sign of the DingTalk robot with secret to target.
+func signDingTalk(target, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", stripURL(err)
+	}
+	timestamp := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	q := u.Query()
+	q.Set("timestamp", timestamp)
+	q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// stripURL drops the url from err of url.Parse and http requests, the url may carry the access token.
+func stripURL(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		return fmt.Errorf("%s webhook: %v", ue.Op, ue.Err)
+	}
+	return err
+}
+
+// redactURL drops the query and path of webhook urls, they often carry the access token.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,112 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/app"
+	"github.com/alibaba/sealer/utils"
+)
+
+var appRollbackOpts app.RollbackOptions
+
+var appCmd = &cobra.Command{
+	Use:   "app",
+	Short: "manage the applications of cluster applied from CloudImages",
+	Long: `The manifests and helm charts in the CloudImages applied to the cluster are recorded as revisions in the
+ConfigMaps of kube-system, with their versions and checksums.`,
+}
+
+var appListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list the applications applied to the cluster and their current revisions",
+	Args:    cobra.NoArgs,
+	Example: `sealer app list`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := app.NewStore()
+		if err != nil {
+			return err
+		}
+		apps, err := store.List()
+		if err != nil {
+			return err
+		}
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{"NAME", "KIND", "REVISION", "VERSION", "CHECKSUM", "IMAGE", "APPLIED"})
+		for _, a := range apps {
+			rev := a.Current()
+			if rev == nil {
+				continue
+			}
+			revision := strconv.Itoa(rev.Revision)
+			if rev.RollbackOf > 0 {
+				revision += " (rollback of " + strconv.Itoa(rev.RollbackOf) + ")"
+			}
+			checksum := strings.TrimPrefix(rev.Checksum, "sha256:")
+			if len(checksum) > 12 {
+				checksum = checksum[:12]
+			}
+			table.Append([]string{a.Name, string(a.Kind), revision, rev.Version, checksum, rev.Image,
+				rev.AppliedAt.Format("2006-01-02 15:04:05")})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+var appRollbackCmd = &cobra.Command{
+	Use:   "rollback <name>",
+	Short: "restore an application to its previous revision",
+	Long: `rollback applies the manifest kept for the revision by kubectl apply, or the chart kept for it by helm upgrade
+with the values of the release reused, on master0, then records it as a new revision.`,
+	Args: cobra.ExactArgs(1),
+	Example: `sealer app rollback dashboard
+sealer app rollback nginx-ingress --revision 2 --namespace ingress-nginx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			clusterName = cn
+		}
+		cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+		if err != nil {
+			return err
+		}
+		rev, err := app.Rollback(cluster, args[0], appRollbackOpts)
+		if err != nil {
+			return err
+		}
+		logger.Info("%s is rolled back to revision %d as revision %d", args[0], rev.RollbackOf, rev.Revision)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(appCmd)
+	appCmd.AddCommand(appListCmd)
+	appCmd.AddCommand(appRollbackCmd)
+	appRollbackCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	appRollbackCmd.Flags().IntVar(&appRollbackOpts.Revision, "revision", 0, "the revision to restore, the previous one by default")
+	appRollbackCmd.Flags().StringVarP(&appRollbackOpts.Namespace, "namespace", "n", "default", "the namespace of the helm release of a chart")
+}
@@ -15,29 +15,174 @@
 package cmd
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/apply/v2"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/deprecation"
+	"github.com/alibaba/sealer/pkg/job"
+	"github.com/alibaba/sealer/pkg/profile"
+	"github.com/alibaba/sealer/pkg/progress"
+	"github.com/alibaba/sealer/pkg/sign"
+	"github.com/alibaba/sealer/pkg/timeout"
+	"github.com/alibaba/sealer/pkg/tracing"
+)
+
+var (
+	clusterFile string
+	applyAsync  bool
+	applyDryRun bool
+	takeover    bool
+	showTUI     bool
+	applyResume bool
+
+	applyProfile       bool
+	applyProfileOutput string
 )
 
-var clusterFile string
+const tuiUsage = "show the progress of each host in the terminal, select a host by the arrows and press enter to show its errors"
 
 // applyCmd represents the apply command
 var applyCmd = &cobra.Command{
-	Use:     "apply",
-	Short:   "apply a kubernetes cluster",
-	Example: `sealer apply -f Clusterfile`,
-	Args:    cobra.NoArgs,
+	Use:   "apply",
+	Short: "apply a kubernetes cluster",
+	Example: `sealer apply -f Clusterfile
+# apply in background, then follow it with "sealer status <job>" and "sealer logs -f <job>"
+sealer apply -f Clusterfile --async
+# print the hosts to join and delete, the changes of hosts and how they are applied, without applying them
+sealer apply -f Clusterfile --dry-run
+# show the progress of each host in the terminal instead of the log
+sealer apply -f Clusterfile --tui
+# print the time of each phase and of each host in copy, image load, init, join and health wait after applying,
+# and save the stacks of spans for flamegraph.pl or speedscope
+sealer apply -f Clusterfile --profile --profile-output apply.folded
+# bring a running cluster, like the one "sealer generate" writes the Clusterfile of, under management without resetting its hosts
+sealer apply -f Clusterfile --takeover
+# continue the apply stopped with the machine running it, like a crashed laptop, from the checkpoint on master0
+sealer apply -f Clusterfile --resume`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		applier, err := apply.NewApplierFromFile(clusterFile)
-		if err != nil {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
 			return err
 		}
-		return applier.Apply()
+		if applyAsync && !applyDryRun {
+			j, err := job.Start(removeAsyncFlag(os.Args[1:]))
+			if err != nil {
+				return err
+			}
+			fmt.Println(j.ID)
+			return nil
+		}
+		sign.SetInsecureSkipVerify(insecureSkipVerify)
+		deprecation.SetStrict(strictDeprecation)
+		err := runApply()
+		if id := job.CurrentID(); id != "" {
+			if ferr := job.Finish(id, err); ferr != nil {
+				return fmt.Errorf("%v, failed to record job result: %v", err, ferr)
+			}
+		}
+		return err
 	},
 }
 
+func runApply() error {
+	applier, err := apply.NewApplierFromFile(clusterFile)
+	if err != nil {
+		return err
+	}
+	if applyDryRun {
+		plan, err := applier.Plan()
+		if err != nil {
+			return err
+		}
+		plan.Print(os.Stdout)
+		return nil
+	}
+	if applyResume {
+		if takeover {
+			return fmt.Errorf("--resume and --takeover can not be used together")
+		}
+		return withProfile(func() error {
+			return withProgress(func() error {
+				return applier.Resume(signalContext())
+			})
+		})
+	}
+	if takeover {
+		return withProfile(func() error {
+			return withProgress(func() error {
+				return applier.Takeover(signalContext())
+			})
+		})
+	}
+	return withProfile(func() error {
+		return withProgress(func() error {
+			return applier.Apply(signalContext())
+		})
+	})
+}
+
+// withProfile runs f and prints how long its phases and hosts took if --profile is set.
+func withProfile(f func() error) error {
+	if !applyProfile {
+		return f()
+	}
+	profiler := profile.NewProfiler()
+	remove := tracing.AddObserver(profiler)
+	err := f()
+	remove()
+	profiler.Finish()
+
+	report := profiler.Report()
+	fmt.Fprint(common.StdOut, profile.Render(report))
+	if applyProfileOutput != "" {
+		if werr := ioutil.WriteFile(applyProfileOutput, []byte(report.Folded()), common.FileMode0644); werr != nil {
+			logger.Warn("failed to write the profile to %s: %v", applyProfileOutput, werr)
+		} else {
+			logger.Info("the stacks of the profile are saved to %s", applyProfileOutput)
+		}
+	}
+	return err
+}
+
+// withProgress runs f with the progress shown in the terminal if --tui is set.
+func withProgress(f func() error) error {
+	if !showTUI {
+		return f()
+	}
+	stop := progress.Start()
+	err := f()
+	stop(err)
+	return err
+}
+
+func removeAsyncFlag(args []string) []string {
+	var res []string
+	for _, arg := range args {
+		if arg == "--async" || arg == "--async=true" || arg == "--async=false" {
+			continue
+		}
+		res = append(res, arg)
+	}
+	return res
+}
+
 func init() {
 	rootCmd.AddCommand(applyCmd)
 	applyCmd.Flags().StringVarP(&clusterFile, "Clusterfile", "f", "Clusterfile", "apply a kubernetes cluster")
+	applyCmd.Flags().BoolVar(&applyAsync, "async", false, "run apply in background and print the job id")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "print what apply would change in the cluster without applying it")
+	applyCmd.Flags().BoolVar(&takeover, "takeover", false, "install only the missing sealer bits on the hosts of the running cluster instead of resetting them")
+	applyCmd.Flags().BoolVar(&applyResume, "resume", false, "continue the apply stopped with the machine running it from the checkpoint on master0, the completed phases are skipped")
+	applyCmd.Flags().BoolVar(&showTUI, "tui", false, tuiUsage)
+	applyCmd.Flags().BoolVar(&applyProfile, "profile", false, "print the time of each phase and of each host in copy, image load, init, join and health wait after applying")
+	applyCmd.Flags().StringVar(&applyProfileOutput, "profile-output", "", "the file to save the stacks of spans of --profile to in the folded format of flamegraph.pl and speedscope")
+	applyCmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, insecureSkipVerifyUsage)
+	applyCmd.Flags().BoolVar(&strictDeprecation, "strict", false, strictUsage)
+	applyCmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
 }
@@ -0,0 +1,61 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/autoscaler"
+	"github.com/alibaba/sealer/utils"
+)
+
+var autoscalerListen string
+
+var autoscalerCmd = &cobra.Command{
+	Use:   "autoscaler",
+	Short: "integrate the cluster with the cluster-autoscaler",
+}
+
+var autoscalerServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serve the node group API which joins and deletes nodes for the cluster-autoscaler",
+	Long: `serve exposes the nodes of cluster as a node group, the hosts in spec.autoscaler.hostPool of Clusterfile
+are joined when the cluster-autoscaler increases the size, and deleted nodes are returned to the pool.
+The API has no authentication, listen on localhost or put it behind a proxy.`,
+	Args: cobra.NoArgs,
+	Example: `sealer autoscaler serve
+sealer autoscaler serve --listen 127.0.0.1:8089 -c my-cluster`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			clusterName = cn
+		}
+		logger.Info("serving node group API of cluster %s on %s", clusterName, autoscalerListen)
+		return http.ListenAndServe(autoscalerListen, autoscaler.NewHandler(autoscaler.NewProvisioner(clusterName)))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(autoscalerCmd)
+	autoscalerCmd.AddCommand(autoscalerServeCmd)
+	autoscalerServeCmd.Flags().StringVar(&autoscalerListen, "listen", "127.0.0.1:8089", "the address to serve the node group API")
+	autoscalerServeCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+}
@@ -0,0 +1,70 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/backup"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	backupOutput       string
+	backupSkipRegistry bool
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "back up the cluster to a tar.gz archive",
+	Long: `backup saves the snapshot of etcd, the pki of master0, the Clusterfile, the images pushed to the
+registry and the sealer state of the cluster to a tar.gz archive, which sealer restore rebuilds the cluster from.`,
+	Args: cobra.NoArgs,
+	Example: `sealer backup
+sealer backup -c my-cluster -o /backup/my-cluster.tar.gz --skip-registry`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			clusterName = cn
+		}
+		cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+		if err != nil {
+			return err
+		}
+		if backupOutput == "" {
+			backupOutput = fmt.Sprintf("%s-%s.tar.gz", clusterName, time.Now().Format("20060102150405"))
+		}
+		if err := backup.Create(cluster, backupOutput, backup.Options{SkipRegistry: backupSkipRegistry}); err != nil {
+			return err
+		}
+		logger.Info("cluster %s is backed up to %s", clusterName, backupOutput)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "the path of the archive, default is CLUSTER-TIMESTAMP.tar.gz")
+	backupCmd.Flags().BoolVar(&backupSkipRegistry, "skip-registry", false, "leave the images pushed to the registry out of the backup")
+}
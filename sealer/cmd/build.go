@@ -15,12 +15,23 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/build"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/image/store"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/scan"
+	"github.com/alibaba/sealer/utils"
+	"github.com/alibaba/sealer/utils/archive"
+	"github.com/alibaba/sealer/utils/ssh"
 )
 
 type BuildFlag struct {
@@ -29,6 +40,12 @@ type BuildFlag struct {
 	BuildType    string
 	NoCache      bool
 	Base         bool
+	BuildArgs    []string
+	Platform     string
+	ScanSeverity string
+	Scanner      string
+	Compression  string
+	ReadyTimeout time.Duration
 }
 
 var buildConfig *BuildFlag
@@ -55,13 +72,52 @@ build without cache:
 
 build without base:
 	sealer build -f Kubefile -t my-kubernetes:1.19.9 --no-base
+
+build with args, the ARG of Kubefile is overridden:
+	sealer build -f Kubefile -t my-kubernetes:1.20.4 --build-arg KUBE_VERSION=v1.20.4
+
+build multi-platform image:
+	sealer build -f Kubefile -t my-kubernetes:1.19.9 --platform linux/amd64,linux/arm64
+
+build with zstd compressed layers, which are used by sealer push:
+	sealer build -f Kubefile -t my-kubernetes:1.19.9 --compression zstd
+
+build and fail if HIGH or CRITICAL vulnerabilities found:
+	sealer build -f Kubefile -t my-kubernetes:1.19.9 --scan-severity HIGH,CRITICAL
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		buildArgs, err := parseBuildArgs(buildConfig.BuildArgs)
+		if err != nil {
+			return err
+		}
+		platforms, err := platform.ParseList(buildConfig.Platform)
+		if err != nil {
+			return err
+		}
+		severities, err := scan.ParseSeverities(buildConfig.ScanSeverity)
+		if err != nil {
+			return err
+		}
+		compression, err := archive.ParseCompression(buildConfig.Compression)
+		if err != nil {
+			return err
+		}
+		if buildConfig.Compression != "" && (buildConfig.BuildType == common.AliCloudBuild || buildConfig.BuildType == common.ContainerBuild) {
+			return fmt.Errorf("build type %s does not support --compression, only lite and local build do", buildConfig.BuildType)
+		}
+		if compression == archive.Zstd {
+			if _, ok := utils.CheckCmdIsExist("zstd"); !ok {
+				return fmt.Errorf("zstd compression requires the zstd binary installed")
+			}
+		}
+		ssh.ReadyTimeout = buildConfig.ReadyTimeout
 		conf := &build.Config{
 			BuildType: buildConfig.BuildType,
 			NoCache:   buildConfig.NoCache,
 			ImageName: buildConfig.ImageName,
 			NoBase:    !buildConfig.Base,
+			BuildArgs: buildArgs,
+			Platforms: platforms,
 		}
 
 		builder, err := build.NewBuilder(conf)
@@ -69,7 +125,18 @@ build without base:
 			return err
 		}
 
-		return builder.Build(buildConfig.ImageName, args[0], buildConfig.KubefileName)
+		if err = builder.Build(buildConfig.ImageName, args[0], buildConfig.KubefileName); err != nil {
+			return err
+		}
+		if buildConfig.Compression != "" {
+			if err = setImageCompression(buildConfig.ImageName, compression); err != nil {
+				return err
+			}
+		}
+		if len(severities) == 0 {
+			return nil
+		}
+		return scanBuiltImage(buildConfig.ImageName, &scan.Options{Scanner: buildConfig.Scanner, Severities: severities})
 	},
 }
 
@@ -81,8 +148,70 @@ func init() {
 	buildCmd.Flags().StringVarP(&buildConfig.ImageName, "imageName", "t", "", "cluster image name")
 	buildCmd.Flags().BoolVar(&buildConfig.NoCache, "no-cache", false, "build without cache")
 	buildCmd.Flags().BoolVar(&buildConfig.Base, "base", true, "build with base image,default value is true.")
+	buildCmd.Flags().StringSliceVar(&buildConfig.BuildArgs, "build-arg", nil, "set build-time variables declared by ARG in Kubefile, KEY=VALUE")
+	buildCmd.Flags().StringVar(&buildConfig.Platform, "platform", "", "set target platforms of lite build, like linux/amd64,linux/arm64")
+	buildCmd.Flags().StringVar(&buildConfig.Compression, "compression", "", "compression of image layers when pushed, one of gzip|zstd, default is gzip")
+	buildCmd.Flags().StringVar(&buildConfig.ScanSeverity, "scan-severity", "", "scan the image after build and fail if vulnerabilities of these severities found, like HIGH,CRITICAL")
+	buildCmd.Flags().DurationVar(&buildConfig.ReadyTimeout, "ready-timeout", ssh.DefaultReadyTimeout, "the time to wait for the instances of cloud build running, reachable by ssh and cloud-init done")
+	buildCmd.Flags().StringVar(&buildConfig.Scanner, "scanner", scan.DefaultScanner, "the name or path of trivy compatible scanner used by --scan-severity")
 	if err := buildCmd.MarkFlagRequired("imageName"); err != nil {
 		logger.Error("failed to init flag: %v", err)
 		os.Exit(1)
 	}
 }
+
+// setImageCompression saves the layer compression to image annotation, which is used by push.
+func setImageCompression(name string, compression archive.Compression) error {
+	named, err := reference.ParseToNamed(name)
+	if err != nil {
+		return err
+	}
+	is, err := store.NewDefaultImageStore()
+	if err != nil {
+		return err
+	}
+	img, err := is.GetByName(named.Raw())
+	if err != nil {
+		return err
+	}
+	if img.Annotations == nil {
+		img.Annotations = make(map[string]string)
+	}
+	img.Annotations[common.ImageAnnotationForCompression] = string(compression)
+	return is.Save(*img, named.Raw())
+}
+
+// scanBuiltImage is the vulnerability gate of build, the image is kept on failure
+// so that the report can be reviewed by sealer scan.
+func scanBuiltImage(name string, opts *scan.Options) error {
+	report, err := scanCloudImage(name, "", opts)
+	if err != nil {
+		return fmt.Errorf("failed to scan image %s: %v", name, err)
+	}
+	if report.Total() == 0 {
+		logger.Info("no %s vulnerabilities found in %s", strings.Join(opts.Severities, ","), name)
+		return nil
+	}
+	printScanReport(report)
+	return fmt.Errorf("%d %s vulnerabilities found in %s", report.Total(), strings.Join(opts.Severities, ","), name)
+}
+
+// parseBuildArgs converts KEY=VALUE to map, KEY without value takes the value of the environment variable.
+func parseBuildArgs(args []string) (map[string]string, error) {
+	buildArgs := map[string]string{}
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("invalid build arg %s, should be KEY=VALUE", arg)
+		}
+		if len(kv) == 1 {
+			v, ok := os.LookupEnv(kv[0])
+			if !ok {
+				continue
+			}
+			kv = append(kv, v)
+		}
+		buildArgs[kv[0]] = kv[1]
+	}
+	return buildArgs, nil
+}
@@ -15,31 +15,53 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/checker"
+	"github.com/alibaba/sealer/common"
+	imageutils "github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/pkg/hostprep"
+	"github.com/alibaba/sealer/pkg/imagelint"
+	"github.com/alibaba/sealer/utils"
 )
 
 type CheckArgs struct {
-	Pre  bool
-	Post bool
+	Pre      bool
+	Post     bool
+	HostPrep bool
 }
 
 var checkArgs *CheckArgs
 
+var (
+	checkImagePlatform string
+	checkImageFormat   string
+)
+
 // pushCmd represents the push command
 var checkCmd = &cobra.Command{
-	Use:     "check",
-	Short:   "check the state of cluster ",
-	Example: `sealer check --pre or sealer check --post`,
-	Args:    cobra.NoArgs,
+	Use:   "check",
+	Short: "check the state of cluster ",
+	Example: `sealer check --pre or sealer check --post
+# report the hosts drifted from the hostPrep in Clusterfile
+sealer check --host-prep -c my-cluster
+# check a cloud image is complete before running it
+sealer check image kubernetes:v1.19.8`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if checkArgs.Pre && checkArgs.Post {
 			return fmt.Errorf("don't allow to set two flags --pre and --post")
 		}
 
+		if checkArgs.HostPrep {
+			return checkHostPrep()
+		}
+
 		if checkArgs.Pre {
 			return checker.RunViewCheckList(nil)
 		}
@@ -47,9 +69,84 @@ var checkCmd = &cobra.Command{
 	},
 }
 
+var checkImageCmd = &cobra.Command{
+	Use:   "image IMAGE",
+	Short: "check a cloud image is complete before running it",
+	Long: `check the rootfs of cloud image has the scripts run by apply, its Metadata parses, the kubeadm and kubelet
+in bin are of the kubernetes version of Metadata, the images in manifests/imageList are cached in its registry
+and the manifests parse, it fails if any check fails.`,
+	Example: `sealer check image kubernetes:v1.19.8
+# only check the arm64 platform of a multi-platform image, and print the results in json
+sealer check image kubernetes:v1.19.8 --platform linux/arm64 -o json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: imageutils.ImageListFuncForCompletion,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		is, img, err := getCloudImage(args[0], checkImagePlatform)
+		if err != nil {
+			return err
+		}
+		report, err := imagelint.LintImage(is, img)
+		if err != nil {
+			return err
+		}
+		switch checkImageFormat {
+		case scanFormatTable:
+			table := tablewriter.NewWriter(common.StdOut)
+			table.SetHeader([]string{"CHECK", "RESULT", "MESSAGE"})
+			for _, r := range report.Results {
+				table.Append([]string{r.Check, string(r.Level), r.Message})
+			}
+			table.Render()
+		case scanFormatJSON:
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		default:
+			return fmt.Errorf("unsupported output format %s", checkImageFormat)
+		}
+		if report.Failed() {
+			return fmt.Errorf("cloud image %s is broken, fix the failed checks and build it again", args[0])
+		}
+		return nil
+	},
+}
+
 func init() {
 	checkArgs = &CheckArgs{}
 	rootCmd.AddCommand(checkCmd)
+	checkCmd.AddCommand(checkImageCmd)
+	checkImageCmd.Flags().StringVar(&checkImagePlatform, "platform", "", "only check the platform of multi-platform image, default is all platforms")
+	checkImageCmd.Flags().StringVarP(&checkImageFormat, "output", "o", scanFormatTable, "output format, one of table|json")
 	checkCmd.Flags().BoolVar(&checkArgs.Pre, "pre", false, "Check dependencies before cluster creation")
 	checkCmd.Flags().BoolVar(&checkArgs.Post, "post", false, "Check the status of the cluster after it is created")
+	checkCmd.Flags().BoolVar(&checkArgs.HostPrep, "host-prep", false, "Report the hosts drifted from the host preparation in Clusterfile")
+	checkCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+}
+
+func checkHostPrep() error {
+	if clusterName == "" {
+		cn, err := utils.GetDefaultClusterName()
+		if err != nil {
+			return err
+		}
+		clusterName = cn
+	}
+	cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+	if err != nil {
+		return err
+	}
+	drifts, err := hostprep.Check(cluster, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...))
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		fmt.Println("all hosts are in the desired state")
+		return nil
+	}
+	for _, d := range drifts {
+		fmt.Printf("%s: %s\n", d.Host, strings.Join(d.Items, ", "))
+	}
+	return fmt.Errorf("%d hosts drifted from the host preparation", len(drifts))
 }
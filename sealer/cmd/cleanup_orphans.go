@@ -0,0 +1,65 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+)
+
+var cleanupOrphansClusterName string
+
+var cleanupOrphansCmd = &cobra.Command{
+	Use:   "cleanup-orphans",
+	Short: "clean up the hosts skipped by delete --skip-unreachable",
+	Long: `clean up the hosts skipped for being unreachable by "sealer delete --skip-unreachable" once they are back,
+by resetting and pruning them as the delete would. The cleaned hosts are removed from the record, and the ones still
+unreachable or failed are kept to retry.`,
+	Args:    cobra.NoArgs,
+	Example: `sealer cleanup-orphans -c my-cluster`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := cleanupOrphansClusterName
+		if name == "" {
+			// the cluster may be deleted already, so it is found by its orphans.
+			names, err := runtime.OrphanClusters()
+			if err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				logger.Info("no orphan hosts to clean up")
+				return nil
+			}
+			if len(names) > 1 {
+				return fmt.Errorf("select a cluster through the -c parameter: %s", strings.Join(names, ","))
+			}
+			name = names[0]
+		}
+		cleaned, err := runtime.CleanupOrphans(name)
+		for _, host := range cleaned {
+			logger.Info("orphan host %s is cleaned up", host)
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanupOrphansCmd)
+	cleanupOrphansCmd.Flags().StringVarP(&cleanupOrphansClusterName, "cluster", "c", "", "the name of the cluster the orphan hosts are skipped from")
+}
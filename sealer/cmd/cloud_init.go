@@ -0,0 +1,81 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	cloudInitOpts   runtime.CloudInitOptions
+	cloudInitOutput string
+)
+
+var cloudInitCmd = &cobra.Command{
+	Use:   "cloud-init",
+	Short: "generate the cloud-init user-data which joins a host to the cluster as a worker on boot",
+	Long: `cloud-init generates a user-data script for immutable infrastructure, the infra provider injects it into new instances,
+which download the rootfs and join the cluster by themselves, no inbound SSH from the operator machine is needed.
+The rootfs tarball can be made on master0 by: tar -czf rootfs.tar.gz -C /var/lib/sealer/data/my-cluster/rootfs .`,
+	Args: cobra.NoArgs,
+	Example: `sealer cloud-init --rootfs-url http://10.0.0.10/rootfs.tar.gz -o user-data.sh
+# the bootstrap token never expires, for auto scaling groups
+sealer cloud-init --rootfs-url http://10.0.0.10/rootfs.tar.gz --token-ttl 0 -c my-cluster`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			clusterName = cn
+		}
+		path := common.GetClusterWorkClusterfile(clusterName)
+		cluster, err := utils.GetClusterFromFile(path)
+		if err != nil {
+			return err
+		}
+		userData, err := runtime.GenerateCloudInit(cluster, path, cloudInitOpts)
+		if err != nil {
+			return err
+		}
+		if cloudInitOutput == "" {
+			fmt.Print(string(userData))
+			return nil
+		}
+		// it contains the bootstrap token, keep it private.
+		return ioutil.WriteFile(cloudInitOutput, userData, 0600)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cloudInitCmd)
+	cloudInitCmd.Flags().StringVar(&cloudInitOpts.RootfsURL, "rootfs-url", "", "the URL to download the rootfs tarball")
+	cloudInitCmd.Flags().StringVar(&cloudInitOpts.TokenTTL, "token-ttl", runtime.DefaultJoinTokenTTL, "the ttl of the bootstrap token, 0 means never expire")
+	cloudInitCmd.Flags().StringVarP(&cloudInitOutput, "output", "o", "", "the file to write the user-data, default is stdout")
+	cloudInitCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	if err := cloudInitCmd.MarkFlagRequired("rootfs-url"); err != nil {
+		logger.Error("failed to init flag: %v", err)
+		os.Exit(1)
+	}
+}
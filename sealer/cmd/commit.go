@@ -0,0 +1,61 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/commit"
+	"github.com/alibaba/sealer/utils"
+)
+
+var commitClusterName string
+
+var commitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "commit the running cluster to a new CloudImage",
+	Long: `commit snapshots the running cluster into a new CloudImage on top of the image it runs, so a tuned
+cluster can be cloned onto new environments by sealer run. The new image adds:
+
+  * the images pushed to the registry of cluster since it is applied
+  * the Config files of Clusterfile
+  * the manifests of the helm releases whose chart is not shipped in the image, which are applied by a new CMD
+
+The hosts and ssh of cluster are not kept in the Clusterfile of the new image.`,
+	Args: cobra.ExactArgs(1),
+	Example: `sealer commit my-registry.com/my-kubernetes:v1.19.8
+sealer commit -c my-cluster my-registry.com/my-kubernetes:v1.19.8`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if commitClusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			commitClusterName = cn
+		}
+		path := common.GetClusterWorkClusterfile(commitClusterName)
+		cluster, err := utils.GetClusterFromFile(path)
+		if err != nil {
+			return err
+		}
+		return commit.Commit(cluster, path, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(commitCmd)
+	commitCmd.Flags().StringVarP(&commitClusterName, "cluster-name", "c", "", "the name of cluster to commit")
+}
@@ -21,13 +21,20 @@ import (
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
 )
 
+// clusterFlags are the flags of the cluster name, whose values are completed by the clusters in $HOME/.sealer.
+var clusterFlags = []string{"cluster-name", "cluster"}
+
 // completionCmd represents the completion command
 var completionCmd = &cobra.Command{
-	Use:   "completion",
-	Short: "generate autocompletion script for bash",
-	Long: `Generate the autocompletion script for sealer for the bash shell.
+	Use:   "completion [bash|zsh|fish]",
+	Short: "generate autocompletion script for bash, zsh or fish",
+	Long: `Generate the autocompletion script for sealer for the bash, zsh or fish shell. Besides the subcommands and flags,
+it completes the names of the local images, like the image of "sealer run" and "sealer push", and the names of the
+clusters in $HOME/.sealer, like the value of --cluster-name.
+
 To load completions in your current shell session:
 
 	source <(sealer completion bash)
@@ -37,21 +44,55 @@ To load completions for every new session, execute once:
 - Linux :
 	## If bash-completion is not installed on Linux, please install the 'bash-completion' package
 		sealer completion bash > /etc/bash_completion.d/sealer
+- zsh :
+	## If shell completion is not already enabled in your environment, enable it by "autoload -U compinit; compinit" in ~/.zshrc
+		sealer completion zsh > "${fpath[1]}/_sealer"
+- fish :
+		sealer completion fish > ~/.config/fish/completions/sealer.fish
 	`,
+	Example: `# complete in the current bash session
+source <(sealer completion bash)
+# complete in every new zsh session
+sealer completion zsh > "${fpath[1]}/_sealer"
+# complete in every new fish session
+sealer completion fish > ~/.config/fish/completions/sealer.fish`,
 	DisableFlagsInUseLine: true,
-	ValidArgs:             []string{"bash"},
+	ValidArgs:             []string{"bash", "zsh", "fish"},
 	Args:                  cobra.ExactValidArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		var err error
 		switch args[0] {
 		case "bash":
-			if err := cmd.Root().GenBashCompletion(common.StdOut); err != nil {
-				logger.Error("failed to use bash completion, %v", err)
-				os.Exit(1)
-			}
+			err = cmd.Root().GenBashCompletion(common.StdOut)
+		case "zsh":
+			err = cmd.Root().GenZshCompletion(common.StdOut)
+		case "fish":
+			err = cmd.Root().GenFishCompletion(common.StdOut, true)
+		}
+		if err != nil {
+			logger.Error("failed to use %s completion, %v", args[0], err)
+			os.Exit(1)
 		}
 	},
 }
 
+// registerClusterCompletion completes the cluster flags of cmd and its subcommands by the names of the clusters,
+// it is called once all commands are added.
+func registerClusterCompletion(cmd *cobra.Command) {
+	for _, name := range clusterFlags {
+		if cmd.LocalFlags().Lookup(name) == nil {
+			continue
+		}
+		if err := cmd.RegisterFlagCompletionFunc(name, utils.ClusterListFuncForCompletion); err != nil {
+			logger.Error("provide completion for %s flag of %s, err: %v", name, cmd.CommandPath(), err)
+			os.Exit(1)
+		}
+	}
+	for _, c := range cmd.Commands() {
+		registerClusterCompletion(c)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(completionCmd)
 }
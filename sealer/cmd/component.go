@@ -0,0 +1,120 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/systemd"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+var componentHosts []string
+
+var componentCmd = &cobra.Command{
+	Use:   "component",
+	Short: "manage the systemd units sealer installs from CloudImage on the hosts",
+}
+
+var componentRestartCmd = &cobra.Command{
+	Use:   "restart NAME",
+	Short: "restart a component on the hosts it is installed on",
+	Long: `restart restarts the systemd unit of the component, like sealer-registry for sealer-registry.service,
+and waits for it active. The recent journal of the unit is printed if it fails to start.`,
+	Args: cobra.ExactArgs(1),
+	Example: `sealer component restart sealer-registry
+sealer component restart sealer-registry -c my-cluster --host 192.168.0.2`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, hosts, err := loadComponentHosts()
+		if err != nil {
+			return err
+		}
+		return systemd.Restart(cluster, hosts, args[0])
+	},
+}
+
+var componentStatusCmd = &cobra.Command{
+	Use:   "status [NAME]",
+	Short: "show the state of the components on the hosts",
+	Args:  cobra.MaximumNArgs(1),
+	Example: `sealer component status
+sealer component status sealer-registry`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, hosts, err := loadComponentHosts()
+		if err != nil {
+			return err
+		}
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		statuses, err := systemd.GetStatus(cluster, hosts, name)
+		if err != nil {
+			return err
+		}
+		inactive := 0
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{"HOST", "UNIT", "STATE"})
+		for _, s := range statuses {
+			if s.Active != "active" {
+				inactive++
+			}
+			table.Append([]string{s.Host, s.Unit, s.Active})
+		}
+		table.Render()
+		if inactive > 0 {
+			return fmt.Errorf("%d components are not active, see journalctl -u UNIT on their hosts", inactive)
+		}
+		return nil
+	},
+}
+
+// loadComponentHosts returns the cluster and the hosts of --host, all hosts of cluster by default.
+func loadComponentHosts() (*v2.Cluster, []string, error) {
+	if clusterName == "" {
+		cn, err := utils.GetDefaultClusterName()
+		if err != nil {
+			return nil, nil, err
+		}
+		clusterName = cn
+	}
+	cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+	if err != nil {
+		return nil, nil, err
+	}
+	all := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+	if len(componentHosts) == 0 {
+		return cluster, all, nil
+	}
+	for _, host := range componentHosts {
+		if utils.NotIn(host, all) {
+			return nil, nil, fmt.Errorf("host %s is not in cluster %s", host, clusterName)
+		}
+	}
+	return cluster, componentHosts, nil
+}
+
+func init() {
+	rootCmd.AddCommand(componentCmd)
+	componentCmd.AddCommand(componentRestartCmd)
+	componentCmd.AddCommand(componentStatusCmd)
+	componentCmd.PersistentFlags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	componentCmd.PersistentFlags().StringSliceVar(&componentHosts, "host", nil, "the hosts of the components, all hosts of cluster by default")
+}
@@ -0,0 +1,52 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/pkg/exec"
+)
+
+// cpCmd represents the cp command
+var cpCmd = &cobra.Command{
+	Use:   "cp",
+	Short: "copy a local file or dir to the hosts of cluster",
+	Example: `
+copy to all hosts of default cluster:
+	sealer cp ./kubelet /usr/bin/kubelet
+copy to the hosts with role label:
+	sealer cp -c my-cluster -r master ./audit-policy.yaml /etc/kubernetes/audit-policy.yaml
+copy to ip list:
+	sealer cp --ips 192.168.0.2-192.168.0.5 --parallel 2 ./images.tar /root/images.tar
+`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		execCmd, err := exec.NewExecCmd(clusterName, roles, ips)
+		if err != nil {
+			return err
+		}
+		execCmd.Parallel = parallel
+		return execCmd.Copy(args[0], args[1])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+	cpCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	cpCmd.Flags().StringVarP(&roles, "roles", "r", "", "set role label to roles")
+	cpCmd.Flags().StringVar(&ips, "ips", "", "set IPList of hosts, like 192.168.0.2,192.168.0.3 or 192.168.0.2-192.168.0.5")
+	cpCmd.Flags().IntVar(&parallel, "parallel", 0, "the max number of hosts at the same time, 0 means no limit")
+}
@@ -15,20 +15,109 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
+	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/debug"
+	"github.com/alibaba/sealer/image/platform"
+	imageutils "github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/logger"
 )
 
 var debugOptions = debug.NewDebugOptions()
 
+var (
+	debugImagePlatform string
+	debugImageTree     bool
+	debugImageDepth    int
+	debugImageCommit   string
+)
+
 var debugCommand = &cobra.Command{
 	Use:   "debug",
-	Short: "Creating debugging sessions for pods and nodes",
+	Short: "Creating debugging sessions for pods, nodes and the rootfs of cloud images",
+}
+
+var debugImageCmd = &cobra.Command{
+	Use:   "image IMAGE [-- COMMAND [ARG...]]",
+	Short: "Open a shell in the rootfs of a cloud image",
+	Long: `mount the layers of cloud image locally and open a shell in its rootfs, which is exactly what apply
+distributes to the hosts, or run the command after "--" in it, or print its files as a tree.
+
+The changes made to the rootfs are discarded once the shell exits, unless --commit is set to add them as a new
+layer of the image and save it as a new cloud image.`,
+	Example: `# open a shell in the rootfs
+sealer debug image kubernetes:v1.19.8
+# print the files of rootfs 2 levels deep
+sealer debug image kubernetes:v1.19.8 --tree --depth 2
+# run a command in the rootfs
+sealer debug image kubernetes:v1.19.8 -- cat Metadata
+# fix the rootfs in a shell and save it as a new image
+sealer debug image kubernetes:v1.19.8 --commit kubernetes:v1.19.8-fix`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: imageutils.ImageListFuncForCompletion,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var command []string
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			if dash != 1 {
+				return fmt.Errorf("only one image is debugged, the command to run is set after \"--\"")
+			}
+			command = args[dash:]
+		} else if len(args) > 1 {
+			return fmt.Errorf("only one image is debugged, the command to run is set after \"--\"")
+		}
+		if debugImageTree && (debugImageCommit != "" || len(command) != 0) {
+			return fmt.Errorf("--tree only prints the files of rootfs, it can not run a command or commit the changes")
+		}
+
+		is, img, err := getCloudImage(args[0], debugImagePlatform)
+		if err != nil {
+			return err
+		}
+		if platform.IsIndex(img) {
+			if img, err = platform.ResolveDefault(is, img); err != nil {
+				return err
+			}
+		}
+		rootfs, err := debug.MountRootfs(img)
+		if err != nil {
+			return err
+		}
+		defer rootfs.Unmount()
+		if debugImageTree {
+			return debug.PrintTree(common.StdOut, rootfs.Dir, debugImageDepth)
+		}
+		if debugImageCommit != "" && !rootfs.Overlay() {
+			return fmt.Errorf("the changes of rootfs can not be committed, overlay is not supported on this host")
+		}
+
+		if len(command) == 0 {
+			logger.Info("rootfs of %s is mounted at %s, exit the shell to unmount it", args[0], rootfs.Dir)
+		}
+		err = rootfs.Shell(args[0], command)
+		if debugImageCommit == "" {
+			if rootfs.Changed() {
+				logger.Warn("the changes made to the rootfs of %s are discarded, set --commit to save them as a new image", args[0])
+			}
+			return err
+		}
+		// the exit code of an interactive shell is the one of the last command, which does not fail the changes.
+		if err != nil && len(command) != 0 {
+			return fmt.Errorf("the changes are not committed as the command failed: %v", err)
+		}
+		return rootfs.Commit(img, debugImageCommit)
+	},
 }
 
 func init() {
 	rootCmd.AddCommand(debugCommand)
+	debugCommand.AddCommand(debugImageCmd)
+	debugImageCmd.Flags().StringVar(&debugImagePlatform, "platform", "", "the platform of multi-platform image to debug, default is the one of this host")
+	debugImageCmd.Flags().BoolVar(&debugImageTree, "tree", false, "print the files of rootfs as a tree instead of opening a shell")
+	debugImageCmd.Flags().IntVar(&debugImageDepth, "depth", 3, "the levels of dirs the tree prints")
+	debugImageCmd.Flags().StringVar(&debugImageCommit, "commit", "", "save the rootfs with the changes made in the shell as a new image of this name")
 
 	debugCommand.AddCommand(debug.CleanCMD)
 	debugCommand.AddCommand(debug.ShowImagesCMD)
@@ -21,13 +21,21 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/apply/v2"
+	"github.com/alibaba/sealer/client/k8s"
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/plugin"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/timeout"
 	"github.com/alibaba/sealer/utils"
 )
 
 var deleteArgs *common.RunArgs
 var deleteClusterFile string
 var deleteClusterName string
+var deleteCleanup string
+var deleteEtcdSnapshot string
+var deleteSkipUnreachable bool
 
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
@@ -47,8 +55,26 @@ delete all:
 	sealer delete --all [--force]
 	sealer delete -f /root/.sealer/mycluster/Clusterfile [--force]
 	sealer delete -c my-cluster [--force]
+remove the registry data and the container runtime state as well, like images and volumes:
+	sealer delete -c my-cluster --prune prune-all
+save the snapshot of etcd before deleting the cluster:
+	sealer delete -c my-cluster --etcd-snapshot /backup/my-cluster.db
+delete master0 after promoting another master to master0:
+	sealer delete --masters <master0 ip> --promote x.x.x.x
+delete masters even if fewer than 3 are left, which can not keep the quorum of etcd if any of them fails:
+	sealer delete --masters x.x.x.x --force
+skip the dead hosts, and clean them up once they are back:
+	sealer delete --nodes x.x.x.x --skip-unreachable
+	sealer cleanup-orphans -c my-cluster
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
+			return err
+		}
+		if err := runtime.SetCleanupLevel(deleteCleanup); err != nil {
+			return err
+		}
+		runtime.SetSkipUnreachable(deleteSkipUnreachable)
 		force, err := cmd.Flags().GetBool("force")
 		if err != nil {
 			return err
@@ -79,41 +105,102 @@ delete all:
 			deleteClusterFile = common.GetClusterWorkClusterfile(deleteClusterName)
 		}
 
-		if !force {
-			var yesRx = regexp.MustCompile("^(?:y(?:es)?)$")
-			var noRx = regexp.MustCompile("^(?:n(?:o)?)$")
-			var input string
-			for {
-				fmt.Printf("Are you sure to delete the cluster? Yes [y/yes], No [n/no] : ")
-				_, err := fmt.Scanln(&input)
-				if err != nil {
-					return err
-				}
-				if yesRx.MatchString(input) {
-					break
-				}
-				if noRx.MatchString(input) {
-					fmt.Println("You have canceled to delete the cluster!")
-					return nil
-				}
+		deleteNodes := deleteArgs.Nodes != "" || deleteArgs.Masters != ""
+		var workloads []string
+		if !deleteNodes {
+			workloads = listUserWorkloads()
+			if len(workloads) > 0 {
+				printWorkloads(workloads)
 			}
 		}
-		if deleteArgs.Nodes != "" || deleteArgs.Masters != "" {
-			applier, err := apply.NewScaleApplierFromArgs(deleteClusterFile, deleteArgs, common.DeleteSubCmd)
+		if !force {
+			confirmed, err := confirmDelete(deleteClusterName, workloads)
 			if err != nil {
 				return err
 			}
-			return applier.Apply()
+			if !confirmed {
+				fmt.Println("You have canceled to delete the cluster!")
+				return nil
+			}
+		}
+		if deleteNodes {
+			if deleteCleanup != runtime.CleanupKeepData {
+				return fmt.Errorf("--prune only applies to deleting the cluster")
+			}
+			deleteArgs.Force = force
+			return apply.DeleteHosts(signalContext(), deleteClusterFile, deleteArgs)
 		}
 
+		if deleteEtcdSnapshot != "" {
+			cluster, err := utils.GetClusterFromFile(deleteClusterFile)
+			if err != nil {
+				return err
+			}
+			if err := plugin.SnapshotEtcd(cluster, deleteEtcdSnapshot); err != nil {
+				return fmt.Errorf("failed to save the snapshot of etcd, the cluster is not deleted: %v", err)
+			}
+		}
 		applier, err := apply.NewApplierFromFile(deleteClusterFile)
 		if err != nil {
 			return err
 		}
-		return applier.Delete()
+		return applier.Delete(signalContext())
 	},
 }
 
+// listUserWorkloads lists the workloads out of system namespaces, the check is skipped if the cluster is unreachable.
+func listUserWorkloads() []string {
+	client, err := k8s.Newk8sClient()
+	if err == nil {
+		var workloads []string
+		if workloads, err = client.ListUserWorkloads(); err == nil {
+			return workloads
+		}
+	}
+	logger.Warn("failed to list the workloads of cluster, skip checking them: %v", err)
+	return nil
+}
+
+func printWorkloads(workloads []string) {
+	const max = 20
+	fmt.Printf("The cluster is running %d workloads out of system namespaces:\n", len(workloads))
+	for i, w := range workloads {
+		if i == max {
+			fmt.Printf("  ... and %d more\n", len(workloads)-max)
+			break
+		}
+		fmt.Printf("  %s\n", w)
+	}
+}
+
+// confirmDelete asks for yes or no, or for the name of cluster if it is running workloads.
+func confirmDelete(name string, workloads []string) (bool, error) {
+	var input string
+	if len(workloads) > 0 {
+		fmt.Printf("They are deleted with the cluster, type the name of cluster %s to confirm: ", name)
+		_, err := fmt.Scanln(&input)
+		if err != nil {
+			return false, err
+		}
+		return input == name, nil
+	}
+	var yesRx = regexp.MustCompile("^(?:y(?:es)?)$")
+	var noRx = regexp.MustCompile("^(?:n(?:o)?)$")
+	for {
+		fmt.Printf("Are you sure to delete the cluster? Yes [y/yes], No [n/no] : ")
+		_, err := fmt.Scanln(&input)
+		if err != nil {
+			return false, err
+		}
+		if yesRx.MatchString(input) {
+			return true, nil
+		}
+		if noRx.MatchString(input) {
+			return false, nil
+		}
+	}
+}
+
 func init() {
 	deleteArgs = &common.RunArgs{}
 	rootCmd.AddCommand(deleteCmd)
@@ -121,6 +208,14 @@ func init() {
 	deleteCmd.Flags().StringVarP(&deleteArgs.Nodes, "nodes", "n", "", "reduce Count or IPList to nodes")
 	deleteCmd.Flags().StringVarP(&deleteClusterFile, "Clusterfile", "f", "", "delete a kubernetes cluster with Clusterfile Annotations")
 	deleteCmd.Flags().StringVarP(&deleteClusterName, "cluster", "c", "", "delete a kubernetes cluster with cluster name")
-	deleteCmd.Flags().BoolP("force", "", false, "We also can input an --force flag to delete cluster by force")
+	deleteCmd.Flags().BoolP("force", "", false, "We also can input an --force flag to delete cluster by force, "+
+		"or delete masters even if fewer than 3 are left")
+	deleteCmd.Flags().StringVar(&deleteArgs.Promote, "promote", "", "the master to promote to master0 when master0 is deleted")
 	deleteCmd.Flags().BoolP("all", "a", false, "this flags is for delete nodes, if this is true, empty all node ip")
+	deleteCmd.Flags().StringVar(&deleteCleanup, "prune", runtime.CleanupKeepData,
+		"the cleanup level of hosts, keep-data keeps the registry data dir and the container runtime state, prune-sealer removes all the sealer dirs, prune-all removes the container runtime state as well")
+	deleteCmd.Flags().StringVar(&deleteEtcdSnapshot, "etcd-snapshot", "", "save the snapshot of etcd to the local path before deleting the cluster")
+	deleteCmd.Flags().BoolVar(&deleteSkipUnreachable, "skip-unreachable", false,
+		"skip the hosts unreachable by ssh instead of failing on them, they are recorded to clean up by sealer cleanup-orphans")
+	deleteCmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
 }
@@ -0,0 +1,97 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/deprecation"
+)
+
+type DeprecateFlag struct {
+	Message    string
+	EndOfLife  string
+	ReplacedBy string
+	Undo       bool
+}
+
+var (
+	deprecateConfig *DeprecateFlag
+	// strictDeprecation is shared by the commands which pull or apply images.
+	strictDeprecation bool
+)
+
+const strictUsage = "fail instead of warning if cloud image is deprecated or reached its end of life"
+
+var deprecateCmd = &cobra.Command{
+	Use:   "deprecate IMAGE",
+	Short: "mark a local cloud image as deprecated",
+	Long: `deprecate saves the deprecation message, end-of-life date and replacement to the annotations of a local cloud image,
+push it to registry again to share with users. sealer pull, run and apply warn about a deprecated image or one with an
+end-of-life date, and fail with --strict if it is deprecated or reached its end of life. Sign the image again if it is signed.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: utils.ImageListFuncForCompletion,
+	Example: `sealer deprecate kubernetes:v1.19.8 --message "CVE-2021-25741" --replaced-by kubernetes:v1.19.16
+sealer deprecate kubernetes:v1.19.8 --end-of-life 2022-06-30
+sealer deprecate kubernetes:v1.19.8 --undo`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageStore, err := store.NewDefaultImageStore()
+		if err != nil {
+			return err
+		}
+		image, err := imageStore.GetByName(args[0])
+		if err != nil {
+			return err
+		}
+
+		var s deprecation.Status
+		if !deprecateConfig.Undo {
+			if deprecateConfig.EndOfLife != "" {
+				if s.EndOfLife, err = time.Parse(deprecation.DateLayout, deprecateConfig.EndOfLife); err != nil {
+					return fmt.Errorf("invalid --end-of-life %s, it must be like 2022-06-30", deprecateConfig.EndOfLife)
+				}
+			}
+			// an image only with end-of-life date is not deprecated until then.
+			s.Deprecated = s.EndOfLife.IsZero() || deprecateConfig.Message != ""
+			s.Message = deprecateConfig.Message
+			s.ReplacedBy = deprecateConfig.ReplacedBy
+		}
+		deprecation.SetToImage(s, image)
+		if err = imageStore.Save(*image, args[0]); err != nil {
+			return err
+		}
+		if s.IsZero() {
+			logger.Info("image %s is not deprecated any more", args[0])
+			return nil
+		}
+		logger.Info(s.Warning(args[0], time.Now()))
+		return nil
+	},
+}
+
+func init() {
+	deprecateConfig = &DeprecateFlag{}
+	rootCmd.AddCommand(deprecateCmd)
+	deprecateCmd.Flags().StringVar(&deprecateConfig.Message, "message", "", "why the image is deprecated")
+	deprecateCmd.Flags().StringVar(&deprecateConfig.EndOfLife, "end-of-life", "", "the date after which the image is not supported, like 2022-06-30")
+	deprecateCmd.Flags().StringVar(&deprecateConfig.ReplacedBy, "replaced-by", "", "the image to use instead")
+	deprecateCmd.Flags().BoolVar(&deprecateConfig.Undo, "undo", false, "remove the deprecation of the image")
+}
@@ -0,0 +1,53 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/doctor"
+)
+
+var doctorOpts doctor.Options
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "collect the logs of sealer and all hosts into a tarball for troubleshooting",
+	Long: `doctor collects the sealer logs, the Clusterfile, the checkpoint of the aborted apply, and the kubelet, containerd
+and docker journal, kubeadm configs, sealer registry logs and mounts of all hosts into a tarball.
+The passwords, tokens and private keys are scrubbed, please still check the tarball before sharing it.`,
+	Example: `sealer doctor
+# collect the journal of the last 2 hours of cluster my-cluster
+sealer doctor -c my-cluster --since 2h -o my-cluster.tar.gz`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, err := doctor.Collect(doctorOpts)
+		if err != nil {
+			return err
+		}
+		logger.Info("the troubleshooting bundle is saved to %s", output)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().StringVarP(&doctorOpts.ClusterName, "cluster-name", "c", "", "the name of the cluster, the only cluster in $HOME/.sealer if empty")
+	doctorCmd.Flags().StringVarP(&doctorOpts.Output, "output", "o", "", "the path of the tarball, sealer-doctor-[cluster name]-[time].tar.gz if empty")
+	doctorCmd.Flags().DurationVar(&doctorOpts.Since, "since", doctor.DefaultSince, "how far the journal of the hosts goes back")
+	doctorCmd.Flags().Int64Var(&doctorOpts.MaxFileSize, "max-file-size", doctor.DefaultMaxFileSize, "the max bytes of each collected file, only its tail is kept")
+	doctorCmd.Flags().Int64Var(&doctorOpts.MaxSize, "max-size", doctor.DefaultMaxSize, "the max bytes of all collected files, the files beyond it are skipped")
+}
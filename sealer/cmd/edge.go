@@ -0,0 +1,152 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/edge"
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+var (
+	edgeListen      string
+	edgeControl     string
+	edgeTokenFile   string
+	edgeHostKeyFile string
+	edgeTokenHost   string
+	edgeAgent       edge.Agent
+)
+
+var edgeCmd = &cobra.Command{
+	Use:   "edge",
+	Short: "reach the edge hosts behind NAT through a reverse tunnel",
+	Long: `The edge hosts set ssh.edge in Clusterfile are not reachable by ssh from sealer. The edge agent on each of
+them keeps a connection to the broker on master0, and sealer connects their sshd through it.`,
+}
+
+var edgeBrokerCmd = &cobra.Command{
+	Use:   "broker",
+	Short: "run the broker on master0 which the edge agents connect to",
+	Long: `broker accepts the edge agents authenticated by their host tokens, and the connections to their sshd on the
+control address, which must only be reachable on master0. The token and the host key of broker are generated to their
+files if they do not exist, run "sealer edge token" for the flags of each agent.`,
+	Args: cobra.NoArgs,
+	Example: `sealer edge broker
+sealer edge broker --listen :7000 --token-file /etc/sealer/edge-token`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, hostKey, err := loadEdgeBrokerSecrets()
+		if err != nil {
+			return err
+		}
+		agents, err := net.Listen("tcp", edgeListen)
+		if err != nil {
+			return err
+		}
+		control, err := net.Listen("tcp", edgeControl)
+		if err != nil {
+			return err
+		}
+		broker := edge.NewBroker(token, hostKey)
+		errCh := make(chan error, 2)
+		go func() {
+			errCh <- broker.ServeAgents(agents)
+		}()
+		go func() {
+			errCh <- broker.ServeControl(control)
+		}()
+		logger.Info("edge broker is accepting agents on %s with the token in %s, its host key fingerprint is %s",
+			edgeListen, edgeTokenFile, edge.Fingerprint(hostKey))
+		return <-errCh
+	},
+}
+
+var edgeTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "print the flags of the edge agent of a host, run on master0",
+	Long: `token prints the host token and the broker fingerprint the agent of host runs with, the host token is only valid
+for the host. The token and the host key of broker are generated to their files if they do not exist.`,
+	Args: cobra.NoArgs,
+	Example: `sealer edge token --host 10.0.0.5
+sealer edge agent --broker 47.100.1.2:7000 $(ssh 47.100.1.2 sealer edge token --host 10.0.0.5)`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if net.ParseIP(edgeTokenHost) == nil {
+			return fmt.Errorf("--host must be the IP of the edge host in Clusterfile")
+		}
+		token, hostKey, err := loadEdgeBrokerSecrets()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("--host %s --token %s --broker-fingerprint %s\n", edgeTokenHost, edge.HostToken(token, edgeTokenHost), edge.Fingerprint(hostKey))
+		return nil
+	},
+}
+
+func loadEdgeBrokerSecrets() (string, gossh.Signer, error) {
+	token, err := edge.LoadOrCreateToken(edgeTokenFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load the token of edge agents: %v", err)
+	}
+	hostKey, err := edge.LoadOrCreateHostKey(edgeHostKeyFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load the host key of edge broker: %v", err)
+	}
+	return token, hostKey, nil
+}
+
+var edgeAgentCmd = &cobra.Command{
+	Use:     "agent",
+	Short:   "run the agent on an edge host which connects it to the broker",
+	Args:    cobra.NoArgs,
+	Example: `sealer edge agent --broker 47.100.1.2:7000 --host 10.0.0.5 --token xxx --broker-fingerprint SHA256:xxx`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if edgeAgent.Broker == "" || edgeAgent.Token == "" || edgeAgent.BrokerFingerprint == "" {
+			return fmt.Errorf("--broker, --token and --broker-fingerprint are required, run sealer edge token on master0 for them")
+		}
+		if edgeAgent.Host == "" {
+			ip, err := utilnet.ChooseHostInterface()
+			if err != nil {
+				return fmt.Errorf("failed to get the IP of host, set it by --host: %v", err)
+			}
+			edgeAgent.Host = ip.String()
+		}
+		return edgeAgent.Run(signalContext())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(edgeCmd)
+	edgeCmd.AddCommand(edgeBrokerCmd)
+	edgeCmd.AddCommand(edgeAgentCmd)
+	edgeCmd.AddCommand(edgeTokenCmd)
+	edgeBrokerCmd.Flags().StringVar(&edgeListen, "listen", edge.DefaultListen, "the address to accept the edge agents on")
+	edgeBrokerCmd.Flags().StringVar(&edgeControl, "control", ssh.DefaultEdgeControlAddr, "the address to accept the connections to edge hosts on, sealer connects it through ssh to master0")
+	for _, c := range []*cobra.Command{edgeBrokerCmd, edgeTokenCmd} {
+		c.Flags().StringVar(&edgeTokenFile, "token-file", edge.DefaultTokenFile, "the file of the token the host tokens of edge agents are derived from")
+		c.Flags().StringVar(&edgeHostKeyFile, "host-key-file", edge.DefaultHostKeyFile, "the file of the ssh host key of broker")
+	}
+	edgeTokenCmd.Flags().StringVar(&edgeTokenHost, "host", "", "the IP of the edge host in Clusterfile")
+	edgeAgentCmd.Flags().StringVar(&edgeAgent.Broker, "broker", "", "the address of the broker on master0, like 192.168.0.2:7000")
+	edgeAgentCmd.Flags().StringVar(&edgeAgent.Token, "token", "", "the host token of this host printed by sealer edge token")
+	edgeAgentCmd.Flags().StringVar(&edgeAgent.BrokerFingerprint, "broker-fingerprint", "", "the SHA256 fingerprint of the host key of broker printed by sealer edge token")
+	edgeAgentCmd.Flags().StringVar(&edgeAgent.Host, "host", "", "the IP of this host in Clusterfile, the IP of the default route by default")
+	edgeAgentCmd.Flags().StringVar(&edgeAgent.SSHD, "sshd", edge.DefaultSSHD, "the address of the local sshd")
+}
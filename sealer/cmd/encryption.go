@@ -0,0 +1,60 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/utils"
+)
+
+var encryptionCmd = &cobra.Command{
+	Use:   "encryption",
+	Short: "manage the etcd encryption at rest of the cluster",
+}
+
+var encryptionRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "rotate the etcd encryption key of the cluster",
+	Long: `rotate adds a new encryption key to all masters, makes it the key to encrypt,
+rewrites all encrypted resources with it and then removes the old keys.
+The apiservers are restarted one by one at each step.`,
+	Args: cobra.NoArgs,
+	Example: `sealer encryption rotate
+sealer encryption rotate -c my-cluster`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			clusterName = cn
+		}
+		path := common.GetClusterWorkClusterfile(clusterName)
+		cluster, err := utils.GetClusterFromFile(path)
+		if err != nil {
+			return err
+		}
+		return runtime.RotateEncryptionKey(cluster, path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(encryptionCmd)
+	encryptionCmd.AddCommand(encryptionRotateCmd)
+	encryptionRotateCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+}
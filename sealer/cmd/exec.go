@@ -19,12 +19,17 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var roles string
+var (
+	roles    string
+	ips      string
+	parallel int
+)
 
 // execCmd represents the exec command
 var execCmd = &cobra.Command{
 	Use:   "exec",
 	Short: "exec a shell command or script on all node.",
+	Long:  "exec runs the command on the hosts in parallel, and prints the output of each host after all finished.",
 	Example: `
 exec to default cluster: my-cluster
 	sealer exec 'cat /etc/hosts'
@@ -32,13 +37,16 @@ specify the cluster name(If there is only one cluster in the $HOME/.sealer direc
     sealer exec -c my-cluster 'cat /etc/hosts'
 set role label to exec cmd:
     sealer exec -c my-cluster -r master,slave,node1 'cat /etc/hosts'		
+set ip list to exec cmd, no more than 5 hosts at the same time:
+    sealer exec --ips 192.168.0.2,192.168.0.3 --parallel 5 -- df -h /
 `,
-	Args: cobra.ExactArgs(1),
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		execCmd, err := exec.NewExecCmd(clusterName, roles)
+		execCmd, err := exec.NewExecCmd(clusterName, roles, ips)
 		if err != nil {
 			return err
 		}
+		execCmd.Parallel = parallel
 		return execCmd.RunCmd(args...)
 	},
 }
@@ -47,4 +55,6 @@ func init() {
 	rootCmd.AddCommand(execCmd)
 	execCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
 	execCmd.Flags().StringVarP(&roles, "roles", "r", "", "set role label to roles")
+	execCmd.Flags().StringVar(&ips, "ips", "", "set IPList of hosts, like 192.168.0.2,192.168.0.3 or 192.168.0.2-192.168.0.5")
+	execCmd.Flags().IntVar(&parallel, "parallel", 0, "the max number of hosts at the same time, 0 means no limit")
 }
@@ -0,0 +1,126 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/facts"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	factsHost    string
+	factsRefresh bool
+	factsFormat  string
+)
+
+var factsCmd = &cobra.Command{
+	Use:   "facts",
+	Short: "show the facts of a host, like its OS, kernel, arch, CPU, memory and disks",
+	Long: `facts shows the profile of a host gathered once by apply for preflight, platform and cgroup driver selection
+and plugins, the saved one is shown unless --refresh is set or it was never gathered.`,
+	Example: `sealer facts --host 192.168.0.2
+# gather the facts again and print them in json
+sealer facts -c my-cluster --host 192.168.0.2 --refresh -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterName == "" {
+			var err error
+			clusterName, err = utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+		}
+		cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+		if err != nil {
+			return err
+		}
+		if utils.NotIn(factsHost, append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)) {
+			return fmt.Errorf("host %s is not in cluster %s", factsHost, clusterName)
+		}
+
+		var h *facts.Host
+		if !factsRefresh {
+			if h, err = facts.Load(clusterName, factsHost); err != nil {
+				return err
+			}
+		}
+		if h == nil {
+			if h, err = facts.Refresh(cluster, factsHost); err != nil {
+				return err
+			}
+		}
+		return printFacts(h)
+	},
+}
+
+func printFacts(h *facts.Host) error {
+	switch factsFormat {
+	case scanFormatTable:
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{"FACT", "VALUE"})
+		table.AppendBulk([][]string{
+			{"IP", h.IP},
+			{"Hostname", h.Hostname},
+			{"OS", strings.TrimSpace(h.OS + " " + h.OSVersion)},
+			{"Kernel", h.Kernel},
+			{"Arch", h.Arch},
+			{"CPUs", strconv.Itoa(h.CPUs)},
+			{"Memory", fmt.Sprintf("%d MiB", h.MemoryKB/1024)},
+			{"Cgroup", h.CgroupFs},
+			{"Active CRIs", strings.Join(h.ActiveCRIs, ",")},
+		})
+		for _, d := range h.Disks {
+			kind := "SSD"
+			if d.Rotational {
+				kind = "HDD"
+			}
+			table.Append([]string{"Disk " + d.Name, fmt.Sprintf("%d GiB %s", d.Size>>30, kind)})
+		}
+		table.Append([]string{"Gathered At", h.GatheredAt.Format("2006-01-02 15:04:05")})
+		table.Render()
+	case scanFormatJSON:
+		data, err := json.MarshalIndent(h, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		return fmt.Errorf("unsupported output format %s", factsFormat)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(factsCmd)
+	factsCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "the name of the cluster, the only cluster in $HOME/.sealer if empty")
+	factsCmd.Flags().StringVar(&factsHost, "host", "", "the IP of host to show the facts of")
+	factsCmd.Flags().BoolVar(&factsRefresh, "refresh", false, "gather the facts again instead of showing the saved ones")
+	factsCmd.Flags().StringVarP(&factsFormat, "output", "o", scanFormatTable, "output format, one of table|json")
+	if err := factsCmd.MarkFlagRequired("host"); err != nil {
+		logger.Error("failed to init flag: %v", err)
+		os.Exit(1)
+	}
+}
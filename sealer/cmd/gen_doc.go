@@ -35,6 +35,7 @@ func init() {
 	genDocCommand.cmd = &cobra.Command{
 		Use:           "gen-doc",
 		Short:         "Generate document for sealer CLI with MarkDown format",
+		Example:       "sealer gen-doc --path docs/commandline",
 		Args:          cobra.NoArgs,
 		SilenceErrors: true,
 		SilenceUsage:  true,
@@ -0,0 +1,83 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/cert"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/generate"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	generateOpts   generate.Options
+	generateOutput string
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "generate the Clusterfile of a running kubeadm cluster to manage it by sealer",
+	Long: `generate inspects the kubeadm cluster which $HOME/.kube/config points to, and writes its Clusterfile with the
+hosts, their labels and taints, and the kubeadm, kubelet and kube-proxy configs in use. All hosts must be reachable
+by ssh. The cluster is adopted by sealer, so "sealer apply" of the Clusterfile reconciles its changes afterwards.`,
+	Example: `sealer generate --image kubernetes:v1.19.8 -p password -o Clusterfile
+# install the registry, lvscare and the other sealer bits on the hosts without resetting them
+sealer apply -f Clusterfile --takeover
+sealer apply -f Clusterfile --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if generateOpts.Image == "" {
+			return fmt.Errorf("--image is required, it should be of the kubernetes version of the cluster")
+		}
+		workClusterfile := common.GetClusterWorkClusterfile(generateOpts.ClusterName)
+		if utils.IsFileExist(workClusterfile) {
+			return fmt.Errorf("cluster %s is already managed by sealer, its Clusterfile is %s", generateOpts.ClusterName, workClusterfile)
+		}
+		result, err := generate.Generate(generateOpts)
+		if err != nil {
+			return err
+		}
+		data, err := result.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := utils.WriteFile(generateOutput, data); err != nil {
+			return err
+		}
+		// sealer apply diffs the Clusterfile against the one last applied.
+		if err := utils.SaveClusterInfoToFile(result.Cluster, generateOpts.ClusterName); err != nil {
+			return err
+		}
+		logger.Info("the Clusterfile of cluster %s is written to %s", generateOpts.ClusterName, generateOutput)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+	generateCmd.Flags().StringVar(&generateOpts.ClusterName, "name", "my-cluster", "the name of the cluster")
+	generateCmd.Flags().StringVar(&generateOpts.Image, "image", "", "the CloudImage of the kubernetes version of the cluster")
+	generateCmd.Flags().StringVarP(&generateOpts.SSH.User, "user", "u", "root", "set baremetal server username")
+	generateCmd.Flags().StringVarP(&generateOpts.SSH.Passwd, "passwd", "p", "", "set baremetal server password")
+	generateCmd.Flags().StringVar(&generateOpts.SSH.Pk, "pk", cert.GetUserHomeDir()+"/.ssh/id_rsa", "set baremetal server private key")
+	generateCmd.Flags().StringVar(&generateOpts.SSH.PkPasswd, "pk-passwd", "", "set baremetal server private key password")
+	generateCmd.Flags().StringVar(&generateOpts.SSH.Port, "port", "22", "set baremetal server ssh port")
+	generateCmd.Flags().StringVarP(&generateOutput, "output", "o", "Clusterfile", "the path of the generated Clusterfile")
+}
@@ -15,13 +15,17 @@
 package cmd
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/image/types"
+	"github.com/alibaba/sealer/utils"
 )
 
 const (
@@ -29,6 +33,7 @@ const (
 	imageName         = "IMAGE NAME"
 	imageCreate       = "CREATE"
 	imageSize         = "SIZE"
+	imageArch         = "ARCH"
 	timeDefaultFormat = "2006-01-02 15:04:05"
 )
 
@@ -47,12 +52,16 @@ var listCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		is, err := store.NewDefaultImageStore()
+		if err != nil {
+			return err
+		}
 		table := tablewriter.NewWriter(common.StdOut)
-		table.SetHeader([]string{imageID, imageName, imageCreate, imageSize})
+		table.SetHeader([]string{imageID, imageName, imageArch, imageCreate, imageSize})
 		for _, image := range imageMetadataList {
 			create := image.CREATED.Format(timeDefaultFormat)
-			size := formatSize(image.SIZE)
-			table.Append([]string{image.ID, image.Name, create, size})
+			arch, size := imageArchAndSize(is, image)
+			table.Append([]string{image.ID, image.Name, arch, create, utils.FormatSize(size)})
 		}
 		table.Render()
 		return nil
@@ -63,15 +72,25 @@ func init() {
 	rootCmd.AddCommand(listCmd)
 }
 
-func formatSize(size int64) (Size string) {
-	if size < 1024 {
-		Size = fmt.Sprintf("%.2fB", float64(size)/float64(1))
-	} else if size < (1024 * 1024) {
-		Size = fmt.Sprintf("%.2fKB", float64(size)/float64(1024))
-	} else if size < (1024 * 1024 * 1024) {
-		Size = fmt.Sprintf("%.2fMB", float64(size)/float64(1024*1024))
-	} else {
-		Size = fmt.Sprintf("%.2fGB", float64(size)/float64(1024*1024*1024))
+// imageArchAndSize returns the architectures and size of all platforms for multi-platform image.
+func imageArchAndSize(is store.ImageStore, metadata types.ImageMetadata) (string, int64) {
+	img, err := is.GetByID(metadata.ID)
+	if err != nil {
+		return "", metadata.SIZE
+	}
+	if !platform.IsIndex(img) {
+		return img.Spec.Platform.Architecture, metadata.SIZE
+	}
+
+	var (
+		archs []string
+		size  int64
+	)
+	for _, m := range img.Spec.Manifests {
+		archs = append(archs, m.Platform.Architecture)
+		if pm, err := is.GetImageMetadataItem(m.Name); err == nil {
+			size += pm.SIZE
+		}
 	}
-	return
+	return strings.Join(archs, ","), size
 }
@@ -19,19 +19,45 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/image"
+	imageutils "github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/utils"
 )
 
-var clusterFilePrint bool
+var (
+	clusterFilePrint bool
+	renderKubeadm    bool
+	inspectFile      string
+)
 
 // inspectCmd represents the inspect command
 var inspectCmd = &cobra.Command{
 	Use:   "inspect",
 	Short: "print the image information or clusterFile",
 	Long: `sealer inspect kubernetes:v1.18.3 to print image information
-sealer inspect -c kubernetes:v1.18.3 to print image Clusterfile`,
-	Args: cobra.ExactArgs(1),
+sealer inspect -c kubernetes:v1.18.3 to print image Clusterfile
+sealer inspect --render-kubeadm to print the kubeadm configs merged from the default kubeadm config of CloudImage,
+the KubeadmConfig in Clusterfile and spec.kubernetes of Clusterfile`,
+	Example: `# print the information of image, like its layers and platforms
+sealer inspect registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8
+# print the Clusterfile of image
+sealer inspect -c registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8
+# print the kubeadm configs of the current cluster, or of Clusterfile
+sealer inspect --render-kubeadm
+sealer inspect --render-kubeadm -f Clusterfile`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if renderKubeadm {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: imageutils.ImageListFuncForCompletion,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if renderKubeadm {
+			return printKubeadmConfigs()
+		}
 		if clusterFilePrint {
 			cluster, err := image.GetClusterFileFromImageManifest(args[0])
 			if err != nil {
@@ -49,7 +75,31 @@ sealer inspect -c kubernetes:v1.18.3 to print image Clusterfile`,
 	},
 }
 
+// printKubeadmConfigs prints the kubeadm configs rendered from inspectFile, or the Clusterfile of the current cluster.
+func printKubeadmConfigs() error {
+	clusterfile := inspectFile
+	if clusterfile == "" {
+		clusterName, err := utils.GetDefaultClusterName()
+		if err != nil {
+			return err
+		}
+		clusterfile = common.GetClusterWorkClusterfile(clusterName)
+	}
+	cluster, err := utils.GetClusterFromFile(clusterfile)
+	if err != nil {
+		return err
+	}
+	configs, err := runtime.RenderKubeadmConfigs(cluster, clusterfile)
+	if err != nil {
+		return fmt.Errorf("failed to render kubeadm configs of %s: %v", clusterfile, err)
+	}
+	fmt.Println(string(configs))
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(inspectCmd)
 	inspectCmd.Flags().BoolVarP(&clusterFilePrint, "Clusterfile", "c", false, "print the clusterFile")
+	inspectCmd.Flags().BoolVar(&renderKubeadm, "render-kubeadm", false, "print the kubeadm configs merged by layers instead of the image information")
+	inspectCmd.Flags().StringVarP(&inspectFile, "file", "f", "", "the Clusterfile to render the kubeadm configs of, default is the one of the current cluster")
 }
@@ -0,0 +1,110 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/facts"
+	"github.com/alibaba/sealer/pkg/inventory"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	inventoryFormat        string
+	inventoryOutput        string
+	inventoryGatherFacts   bool
+	inventoryWithPasswords bool
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "export the hosts of cluster for the configuration management tools like Ansible",
+}
+
+var inventoryExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export the hosts, roles, ssh parameters and facts of cluster as an Ansible inventory",
+	Long: `export writes the hosts of cluster grouped by their roles, with the ssh parameters of Clusterfile as the
+ansible_* vars and the facts saved by apply as the sealer_facts var. The passwords are omitted unless
+--with-passwords is set. The json format is the output of a dynamic inventory script for --list.`,
+	Example: `sealer inventory export > hosts.ini
+ansible -i hosts.ini master -m ping
+# gather the facts of all hosts instead of the saved ones, and write a yaml inventory
+sealer inventory export -c my-cluster --gather-facts --format yaml -o hosts.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if clusterName == "" {
+			var err error
+			clusterName, err = utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+		}
+		cluster, err := utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+		if err != nil {
+			return err
+		}
+
+		hosts := append(cluster.GetMasterIPList(), cluster.GetNodeIPList()...)
+		hostFacts := map[string]*facts.Host{}
+		if inventoryGatherFacts {
+			all, err := facts.GetAll(cluster, hosts)
+			if err != nil {
+				return err
+			}
+			for _, h := range all {
+				hostFacts[h.IP] = h
+			}
+		} else {
+			for _, host := range hosts {
+				h, err := facts.Load(clusterName, host)
+				if err != nil {
+					return err
+				}
+				hostFacts[host] = h
+			}
+		}
+
+		inv, err := inventory.New(cluster, inventory.Options{Facts: hostFacts, WithPasswords: inventoryWithPasswords})
+		if err != nil {
+			return err
+		}
+		data, err := inv.Marshal(inventoryFormat)
+		if err != nil {
+			return err
+		}
+		if inventoryOutput == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		// the inventory may have the passwords.
+		return ioutil.WriteFile(inventoryOutput, data, 0600)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inventoryCmd)
+	inventoryCmd.AddCommand(inventoryExportCmd)
+	inventoryExportCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "the name of the cluster, the only cluster in $HOME/.sealer if empty")
+	inventoryExportCmd.Flags().StringVar(&inventoryFormat, "format", inventory.FormatINI, "the format of inventory, one of ini|yaml|json")
+	inventoryExportCmd.Flags().StringVarP(&inventoryOutput, "output", "o", "", "the file to write the inventory, default is stdout")
+	inventoryExportCmd.Flags().BoolVar(&inventoryGatherFacts, "gather-facts", false, "gather the facts of all hosts instead of exporting the ones saved by apply")
+	inventoryExportCmd.Flags().BoolVar(&inventoryWithPasswords, "with-passwords", false, "export the passwords of ssh and sudo as ansible_password and ansible_become_password")
+}
@@ -15,16 +15,37 @@
 package cmd
 
 import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/apply/v2"
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/filesystem"
+	"github.com/alibaba/sealer/pkg/runtime"
+	"github.com/alibaba/sealer/pkg/timeout"
+	v2 "github.com/alibaba/sealer/types/api/v2"
 	"github.com/alibaba/sealer/utils"
 )
 
+// standaloneClusterName is the cluster name on a node joining standalone, which has no Clusterfile.
+const standaloneClusterName = "my-cluster"
+
 var clusterName string
 var joinArgs *common.RunArgs
 
+var (
+	standalone       bool
+	standaloneMaster string
+	standaloneImage  string
+	standaloneCert   string
+	standaloneOpts   runtime.StandaloneJoinOptions
+)
+
 var joinCmd = &cobra.Command{
 	Use:   "join",
 	Short: "join node to cluster",
@@ -37,8 +58,18 @@ join to cluster by cloud provider, just set the number of masters or nodes:
 	sealer join --masters 2 --nodes 3
 specify the cluster name(If there is only one cluster in the $HOME/.sealer directory, it should be applied. ):
     sealer join --masters 2 --nodes 3 -c my-cluster
+join the host itself as a worker, the token is printed by "kubeadm token create --print-join-command" on master0,
+and the cluster image is pulled from the registry on master0:
+    sealer join --standalone --master0 192.168.0.2 --token abcdef.0123456789abcdef \
+        --discovery-token-ca-cert-hash sha256:xxx --image 192.168.0.2:5000/kubernetes:v1.19.8
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
+			return err
+		}
+		if standalone {
+			return joinStandalone()
+		}
 		if clusterName == "" {
 			cn, err := utils.GetDefaultClusterName()
 			if err != nil {
@@ -51,7 +82,7 @@ specify the cluster name(If there is only one cluster in the $HOME/.sealer direc
 		if err != nil {
 			return err
 		}
-		return applier.Apply()
+		return applier.Apply(signalContext())
 	},
 }
 
@@ -61,4 +92,63 @@ func init() {
 	joinCmd.Flags().StringVarP(&joinArgs.Masters, "masters", "m", "", "set Count or IPList to masters")
 	joinCmd.Flags().StringVarP(&joinArgs.Nodes, "nodes", "n", "", "set Count or IPList to nodes")
 	joinCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	joinCmd.Flags().BoolVar(&standalone, "standalone", false, "join the host itself as a worker without Clusterfile and SSH")
+	joinCmd.Flags().StringVar(&standaloneMaster, "master0", "", "the IP of master0, used by --standalone")
+	joinCmd.Flags().StringVar(&standaloneOpts.Token, "token", "", "the bootstrap token to join, used by --standalone")
+	joinCmd.Flags().StringVar(&standaloneOpts.CACertHash, "discovery-token-ca-cert-hash", "", "the hash of cluster CA, used by --standalone")
+	joinCmd.Flags().StringVar(&standaloneImage, "image", "", "the cluster image in the registry of master0, used by --standalone")
+	joinCmd.Flags().StringVar(&standaloneCert, "registry-cert", "", "the cert file of the registry on master0, it is fetched from the registry if empty, used by --standalone")
+	joinCmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
+}
+
+// joinStandalone pulls the cluster image and joins the local host, --masters is the IPList of the masters of
+// the cluster for the apiserver load balancer, master0 only by default.
+func joinStandalone() error {
+	if standaloneMaster == "" || standaloneImage == "" {
+		return fmt.Errorf("--master0 and --image are required to join standalone")
+	}
+	if err := utils.AssemblyIPList(&joinArgs.Masters); err != nil {
+		return err
+	}
+	masters := []string{standaloneMaster}
+	for _, ip := range strings.Split(joinArgs.Masters, ",") {
+		if ip != "" && utils.NotIn(ip, masters) {
+			masters = append(masters, ip)
+		}
+	}
+	if standaloneCert != "" {
+		cert, err := ioutil.ReadFile(standaloneCert)
+		if err != nil {
+			return fmt.Errorf("failed to read registry cert: %v", err)
+		}
+		standaloneOpts.RegistryCert = cert
+	}
+	if clusterName == "" {
+		clusterName = standaloneClusterName
+	}
+	cluster := &v2.Cluster{}
+	cluster.Name = clusterName
+	cluster.Spec.Image = standaloneImage
+	cluster.Spec.Hosts = []v2.Host{{IPS: masters, Roles: []string{common.MASTER}}}
+
+	imgSvc, err := image.NewImageService()
+	if err != nil {
+		return err
+	}
+	if err := imgSvc.PullIfNotExist(standaloneImage); err != nil {
+		return err
+	}
+	fs, err := filesystem.NewFilesystem()
+	if err != nil {
+		return err
+	}
+	if err := fs.MountImage(cluster); err != nil {
+		return err
+	}
+	defer func() {
+		if err := fs.UnMountImage(cluster); err != nil {
+			logger.Warn("failed to unmount cluster image: %v", err)
+		}
+	}()
+	return runtime.StandaloneJoin(cluster, standaloneOpts)
 }
@@ -0,0 +1,73 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/job"
+)
+
+var followLogs bool
+
+var logsCmd = &cobra.Command{
+	Use:   "logs JOB_ID",
+	Short: "print the logs of a background job started by apply --async",
+	Example: `sealer logs 0d3fa2c3b9e1
+# keep printing the logs until the job is done
+sealer logs -f 0d3fa2c3b9e1`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		if _, err := job.Get(id); err != nil {
+			return err
+		}
+		f, err := os.Open(filepath.Clean(job.LogFile(id)))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		for {
+			if _, err := io.Copy(common.StdOut, f); err != nil {
+				return err
+			}
+			if !followLogs {
+				return nil
+			}
+			j, err := job.Get(id)
+			if err != nil {
+				return err
+			}
+			if j.IsDone() {
+				// flush what was written between the last copy and the job exit.
+				_, err = io.Copy(common.StdOut, f)
+				return err
+			}
+			time.Sleep(time.Second)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "follow the job logs until the job is done")
+}
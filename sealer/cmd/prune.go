@@ -0,0 +1,50 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "remove dangling layers and temporary dirs",
+	Long: `remove the layers not referenced by any image and the temporary dirs under /var/lib/sealer/tmp,
+the ones modified within an hour or still mounted are kept as they may be used by a running build.`,
+	Args:    cobra.NoArgs,
+	Example: `sealer prune`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report, err := image.Prune()
+		if err != nil {
+			return err
+		}
+		for _, layer := range report.Layers {
+			logger.Info("deleted layer: %s", layer)
+		}
+		for _, dir := range report.Dirs {
+			logger.Info("deleted dir: %s", dir)
+		}
+		logger.Info("total reclaimed space: %s", utils.FormatSize(report.Reclaimed))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+}
@@ -19,6 +19,8 @@ import (
 
 	"github.com/alibaba/sealer/image"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/deprecation"
+	"github.com/alibaba/sealer/pkg/sign"
 )
 
 // pullCmd represents the pull command
@@ -28,6 +30,8 @@ var pullCmd = &cobra.Command{
 	Example: `sealer pull registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8`,
 	Args:    cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		sign.SetInsecureSkipVerify(insecureSkipVerify)
+		deprecation.SetStrict(strictDeprecation)
 		imgSvc, err := image.NewImageService()
 		if err != nil {
 			return err
@@ -37,10 +41,12 @@ var pullCmd = &cobra.Command{
 			return err
 		}
 		logger.Info("Pull %s success", args[0])
-		return nil
+		return deprecation.CheckImage(args[0])
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(pullCmd)
+	pullCmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, insecureSkipVerifyUsage)
+	pullCmd.Flags().BoolVar(&strictDeprecation, "strict", false, strictUsage)
 }
@@ -0,0 +1,70 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/apply/v2"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/timeout"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	recoverNewIP  string
+	recoverBackup string
+)
+
+var recoverMaster0Cmd = &cobra.Command{
+	Use:   "recover-master0",
+	Short: "rebuild the lost master0 of the cluster on another master or a new host",
+	Long: `recover-master0 removes the lost master0 from etcd and the nodes, promotes a master left to master0,
+runs the registry on it, points the registry domain in /etc/hosts and the lvscare of all hosts to it,
+and fetches the kubeconfig from it. If the new ip is a master, it is promoted; otherwise the first master
+left is promoted and the new host joins as a master in place of the lost one.
+The images pushed to the registry are restored from the archive of sealer backup given by --backup.`,
+	Args: cobra.NoArgs,
+	Example: `sealer recover-master0 --new-ip 192.168.0.3
+sealer recover-master0 --new-ip 192.168.0.8 --backup my-cluster-20211201120000.tar.gz -c my-cluster`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
+			return err
+		}
+		if clusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			clusterName = cn
+		}
+		return apply.RecoverMaster0(signalContext(), common.GetClusterWorkClusterfile(clusterName), recoverNewIP, recoverBackup)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recoverMaster0Cmd)
+	recoverMaster0Cmd.Flags().StringVar(&recoverNewIP, "new-ip", "", "the master to promote to master0, or a new host to join in place of the lost master0")
+	recoverMaster0Cmd.Flags().StringVar(&recoverBackup, "backup", "", "the archive of sealer backup to restore the images of registry from")
+	recoverMaster0Cmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	recoverMaster0Cmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
+	if err := recoverMaster0Cmd.MarkFlagRequired("new-ip"); err != nil {
+		logger.Error("failed to init flag: %v", err)
+		os.Exit(1)
+	}
+}
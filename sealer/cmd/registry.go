@@ -0,0 +1,158 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/runtime"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	registryRepair          bool
+	registryUserPasswd      string
+	registryUserPasswdStdin bool
+)
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "manage the registry of cluster",
+}
+
+var registryCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "check the health of the registry of cluster",
+	Long: `check verifies the mount of the registry dir, the sealer-registry container and the htpasswd on the registry host,
+and the registry entry of /etc/hosts and the registry credentials on all nodes, which may be lost after a node reboot.
+Nothing is changed unless --repair is set, which re-applies the broken items.`,
+	Args: cobra.NoArgs,
+	Example: `sealer registry check
+sealer registry check -c my-cluster --repair`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, path, err := getRegistryCluster()
+		if err != nil {
+			return err
+		}
+		results, err := runtime.CheckRegistry(cluster, path, registryRepair)
+		if err != nil {
+			return err
+		}
+
+		broken := 0
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{"HOST", "ITEM", "STATUS", "MESSAGE"})
+		for _, r := range results {
+			status := "OK"
+			switch {
+			case r.Repaired:
+				status = "Repaired"
+			case !r.Healthy:
+				status = "Broken"
+				broken++
+			}
+			table.Append([]string{r.Host, r.Item, status, r.Message})
+		}
+		table.Render()
+		if broken > 0 {
+			if !registryRepair {
+				return fmt.Errorf("%d items of registry are broken, run with --repair to re-apply them", broken)
+			}
+			return fmt.Errorf("failed to repair %d items of registry", broken)
+		}
+		return nil
+	},
+}
+
+var registryUserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "manage the users of the registry of cluster",
+	Long: `The htpasswd of the registry keeps exactly the user of registry.yml and the users added by "sealer registry user add",
+the users added are kept in the cluster work dir with the hashes of their passwords only, and re-applied on every apply.
+The credentials of the user of registry.yml are distributed to the docker configs of all nodes after the users changed.`,
+}
+
+var registryUserAddCmd = &cobra.Command{
+	Use:   "add USER",
+	Short: "add a user to the registry of cluster, or change its password",
+	Args:  cobra.ExactArgs(1),
+	Example: `sealer registry user add alice -p [password]
+cat password.txt | sealer registry user add alice --passwd-stdin`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passwd := registryUserPasswd
+		if registryUserPasswdStdin {
+			data, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			passwd = strings.TrimRight(string(data), "\r\n")
+		}
+		if passwd == "" {
+			return fmt.Errorf("password is required, set it by --passwd or --passwd-stdin")
+		}
+		cluster, path, err := getRegistryCluster()
+		if err != nil {
+			return err
+		}
+		return runtime.AddRegistryUser(cluster, path, args[0], passwd)
+	},
+}
+
+var registryUserDelCmd = &cobra.Command{
+	Use:     "del USER",
+	Short:   "delete a user added by \"sealer registry user add\" from the registry of cluster",
+	Args:    cobra.ExactArgs(1),
+	Example: `sealer registry user del alice`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, path, err := getRegistryCluster()
+		if err != nil {
+			return err
+		}
+		return runtime.DeleteRegistryUser(cluster, path, args[0])
+	},
+}
+
+func getRegistryCluster() (*v2.Cluster, string, error) {
+	if clusterName == "" {
+		cn, err := utils.GetDefaultClusterName()
+		if err != nil {
+			return nil, "", err
+		}
+		clusterName = cn
+	}
+	path := common.GetClusterWorkClusterfile(clusterName)
+	cluster, err := utils.GetClusterFromFile(path)
+	return cluster, path, err
+}
+
+func init() {
+	rootCmd.AddCommand(registryCmd)
+	registryCmd.AddCommand(registryCheckCmd)
+	registryCmd.AddCommand(registryUserCmd)
+	registryUserCmd.AddCommand(registryUserAddCmd)
+	registryUserCmd.AddCommand(registryUserDelCmd)
+	registryCmd.PersistentFlags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	registryCheckCmd.Flags().BoolVar(&registryRepair, "repair", false, "re-apply the broken items of registry")
+	registryUserAddCmd.Flags().StringVarP(&registryUserPasswd, "passwd", "p", "", "password of the user")
+	registryUserAddCmd.Flags().BoolVar(&registryUserPasswdStdin, "passwd-stdin", false, "read the password of the user from stdin, to keep it out of the shell history")
+}
@@ -0,0 +1,68 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/apply/v2"
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/timeout"
+	"github.com/alibaba/sealer/utils"
+)
+
+var replaceNode string
+
+var replaceCmd = &cobra.Command{
+	Use:   "replace",
+	Short: "replace a node of the cluster with a new host",
+	Long: `replace joins the new host from the same cluster image with the same roles, labels and taints of
+the old host, drains and removes the old host, and updates the Clusterfile.
+If any step fails, the cluster is rolled back: the new host is removed and the old one is uncordoned.`,
+	Args: cobra.NoArgs,
+	Example: `sealer replace --node 10.0.0.5=10.0.0.8
+sealer replace --node 10.0.0.5=10.0.0.8 -c my-cluster`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
+			return err
+		}
+		oldIP, newIP, err := apply.ParseReplaceArg(replaceNode)
+		if err != nil {
+			return err
+		}
+		if clusterName == "" {
+			cn, err := utils.GetDefaultClusterName()
+			if err != nil {
+				return err
+			}
+			clusterName = cn
+		}
+		return apply.Replace(signalContext(), common.GetClusterWorkClusterfile(clusterName), oldIP, newIP)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replaceCmd)
+	replaceCmd.Flags().StringVar(&replaceNode, "node", "", "the old and new host ip, in the form OLD_IP=NEW_IP")
+	replaceCmd.Flags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	replaceCmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
+	if err := replaceCmd.MarkFlagRequired("node"); err != nil {
+		logger.Error("failed to init flag: %v", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/apply/v2"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/timeout"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	restoreFile    string
+	restoreMasters []string
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "rebuild the cluster from an archive of sealer backup",
+	Long: `restore resets the hosts in the Clusterfile of the backup, initializes master0 with the CAs of the backup,
+restores etcd from its snapshot and the images of the registry, then joins the other masters and nodes.
+The lost masters can be replaced by new hosts with --masters, the nodes are kept.`,
+	Args: cobra.NoArgs,
+	Example: `sealer restore -f my-cluster-20211201120000.tar.gz
+sealer restore -f my-cluster-20211201120000.tar.gz --masters 10.0.0.8,10.0.0.9,10.0.0.10`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
+			return err
+		}
+		for _, ip := range restoreMasters {
+			if !utils.CheckIP(ip) {
+				return fmt.Errorf("invalid master ip %s", ip)
+			}
+		}
+		return apply.Restore(signalContext(), restoreFile, restoreMasters)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().StringVarP(&restoreFile, "file", "f", "", "the archive of sealer backup")
+	restoreCmd.Flags().StringSliceVar(&restoreMasters, "masters", nil, "the new masters replacing the ones in the backup")
+	restoreCmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
+	if err := restoreCmd.MarkFlagRequired("file"); err != nil {
+		logger.Error("failed to init flag: %v", err)
+		os.Exit(1)
+	}
+}
@@ -15,25 +15,57 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/hostlog"
+	"github.com/alibaba/sealer/pkg/metrics"
+	"github.com/alibaba/sealer/pkg/result"
+	"github.com/alibaba/sealer/pkg/timeout"
+	"github.com/alibaba/sealer/pkg/tracing"
 	"github.com/alibaba/sealer/utils/ssh"
 )
 
 type rootOpts struct {
 	cfgFile     string
 	debugModeOn bool
+	verbose     bool
+	noLocalExec bool
+	resultFile  string
+	// metricsAddr serves /metrics while sealer runs, metricsPushgateway receives the metrics after it finished.
+	metricsAddr        string
+	metricsPushgateway string
 }
 
 var rootOpt rootOpts
 
+// timeoutFlags is shared by the commands which apply clusters.
+var timeoutFlags []string
+
+var timeoutUsage = "timeouts overriding the ones in Clusterfile, like: init=20m,join=40m, names are " + strings.Join(timeout.Kinds(), ", ")
+
+// resultCommands, keyed by the command path not to match the subcommands of the same name, write a
+// machine-readable result file, and exit with the code of the failure category.
+var resultCommands = map[string]bool{
+	"sealer apply":   true,
+	"sealer run":     true,
+	"sealer join":    true,
+	"sealer delete":  true,
+	"sealer upgrade": true,
+	"sealer replace": true,
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "sealer",
@@ -44,16 +76,97 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	startTime := time.Now()
+	registerClusterCompletion(rootCmd)
+	if err := tracing.Init(); err != nil {
+		logger.Warn("tracing is disabled: %v", err)
+	}
+	var end func(error)
+	if c, _, err := rootCmd.Find(os.Args[1:]); err == nil {
+		rootContext, end = tracing.Start(rootContext, c.CommandPath())
+	}
+	cmd, err := rootCmd.ExecuteC()
+	ssh.ClosePool()
+	if end != nil {
+		end(err)
+	}
+	if serr := tracing.Shutdown(); serr != nil {
+		logger.Warn("%v", serr)
+	}
+	if cmd != nil && resultCommands[cmd.CommandPath()] {
+		if werr := result.Write(rootOpt.resultFile, result.New(os.Args, startTime, err)); werr != nil {
+			logger.Warn("failed to write result file %s: %v", rootOpt.resultFile, werr)
+		}
+		if rootOpt.metricsPushgateway != "" {
+			pushMetrics()
+		}
+	}
+	if stopMetrics != nil {
+		stopMetrics()
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
-		os.Exit(1)
+		os.Exit(result.ExitCode(err))
+	}
+}
+
+var stopMetrics func()
+
+// rootContext carries the span of the running command.
+var rootContext = context.Background()
+
+func startMetrics() {
+	if rootOpt.metricsAddr == "" && rootOpt.metricsPushgateway == "" {
+		return
+	}
+	metrics.Enable()
+	if rootOpt.metricsAddr == "" {
+		return
+	}
+	stop, err := metrics.Serve(rootOpt.metricsAddr)
+	if err != nil {
+		logger.Warn("%v", err)
+		return
+	}
+	stopMetrics = stop
+}
+
+// pushMetrics pushes the metrics to the pushgateway as the instance of the local hostname.
+func pushMetrics() {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+	if err = metrics.Push(rootOpt.metricsPushgateway, instance); err != nil {
+		logger.Warn("%v", err)
 	}
 }
 
+// signalContext returns the context canceled on the first SIGINT or SIGTERM, which aborts the running phase
+// and records a checkpoint of the cluster, the second one exits at once.
+func signalContext() context.Context {
+	ctx, cancel := context.WithCancel(rootContext)
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		logger.Warn("received interrupt, aborting the running phase, interrupt again to exit immediately")
+		cancel()
+		<-c
+		os.Exit(130)
+	}()
+	return ctx
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&rootOpt.cfgFile, "config", "", "config file (default is $HOME/.sealer.json)")
 	rootCmd.PersistentFlags().BoolVarP(&rootOpt.debugModeOn, "debug", "d", false, "turn on debug mode")
+	rootCmd.PersistentFlags().BoolVar(&rootOpt.verbose, "verbose", false, "stream the output of remote commands to the console, which is only logged to "+common.DefaultHostLogDir+"/CLUSTER/HOST/PHASE.log by default")
+	rootCmd.PersistentFlags().BoolVar(&rootOpt.noLocalExec, "no-local-exec", false, "run the commands and file copies to the current machine by ssh as well, instead of natively")
+	rootCmd.PersistentFlags().StringVar(&rootOpt.resultFile, "result-file", result.DefaultResultFile, "file to write the result of apply, run, join, delete, upgrade and replace")
+	rootCmd.PersistentFlags().StringVar(&rootOpt.metricsAddr, "metrics-addr", "", "address to serve prometheus metrics on /metrics while sealer runs, like :9091")
+	rootCmd.PersistentFlags().StringVar(&rootOpt.metricsPushgateway, "metrics-pushgateway", "", "prometheus pushgateway url to push the metrics of apply, run, join, delete, upgrade and replace to")
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.DisableAutoGenTag = true
 }
@@ -78,4 +191,9 @@ func initConfig() {
 	logger.Cfg(rootOpt.debugModeOn)
 
 	ssh.DebugMode = rootOpt.debugModeOn
+	ssh.Verbose = rootOpt.verbose
+	ssh.DisableLocalExec = rootOpt.noLocalExec
+	hostlog.Enable("")
+
+	startMetrics()
 }
@@ -17,6 +17,7 @@ package cmd
 import (
 	"os"
 
+	imageutils "github.com/alibaba/sealer/image/utils"
 	"github.com/alibaba/sealer/logger"
 	"github.com/alibaba/sealer/utils"
 	"github.com/spf13/cobra"
@@ -24,6 +25,9 @@ import (
 	"github.com/alibaba/sealer/apply/v2"
 	"github.com/alibaba/sealer/cert"
 	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/deprecation"
+	"github.com/alibaba/sealer/pkg/sign"
+	"github.com/alibaba/sealer/pkg/timeout"
 )
 
 var runArgs *common.RunArgs
@@ -47,14 +51,34 @@ create cluster to your baremetal server, appoint the iplist:
 		--nodes 192.168.0.5,192.168.0.6,192.168.0.7
 create a cluster with custom environment variables:
 	sealer run -e DashBoardPort=8443 mydashboard:latest registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8 --masters 3 --nodes 3
+
+install only the dashboard and monitoring app bundles listed in etc/apps.yaml of image instead of its CMD:
+	sealer run my-platform:latest --masters 192.168.0.2 --apps dashboard,monitoring
+
+create a single-node cluster on the current machine, without ssh or Clusterfile:
+	sealer run registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8 --single
+
+show the progress of each host in the terminal instead of the log:
+	sealer run registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8 --masters 192.168.0.2 --nodes 192.168.0.3 --tui
+
+override the CMD of image:
+	sealer run my-platform:latest --masters 192.168.0.2 --cmd "kubectl apply -f manifests/dashboard.yaml"
 `,
-	Args: cobra.ExactArgs(1),
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: imageutils.ImageListFuncForCompletion,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
+			return err
+		}
+		sign.SetInsecureSkipVerify(insecureSkipVerify)
+		deprecation.SetStrict(strictDeprecation)
 		applier, err := apply.NewApplierFromArgs(args[0], runArgs)
 		if err != nil {
 			return err
 		}
-		return applier.Apply()
+		return withProgress(func() error {
+			return applier.Apply(signalContext())
+		})
 	},
 }
 
@@ -71,6 +95,13 @@ func init() {
 	runCmd.Flags().StringVarP(&runArgs.PodCidr, "podcidr", "", "", "set default pod CIDR network. example '10.233.0.0/18'")
 	runCmd.Flags().StringVarP(&runArgs.SvcCidr, "svccidr", "", "", "set default service CIDR network. example '10.233.64.0/18'")
 	runCmd.Flags().StringSliceVarP(&runArgs.CustomEnv, "env", "e", []string{}, "set custom environment variables")
+	runCmd.Flags().BoolVar(&runArgs.Single, "single", false, "run a single-node cluster on the current machine, whose master runs the workloads")
+	runCmd.Flags().StringArrayVar(&runArgs.Cmd, "cmd", nil, "override the CMD of image, repeat it to run more commands in order")
+	runCmd.Flags().StringSliceVar(&runArgs.Apps, "apps", nil, "install the app bundles of image instead of its CMD, like: dashboard,monitoring")
+	runCmd.Flags().BoolVar(&showTUI, "tui", false, tuiUsage)
+	runCmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, insecureSkipVerifyUsage)
+	runCmd.Flags().BoolVar(&strictDeprecation, "strict", false, strictUsage)
+	runCmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
 	err := runCmd.RegisterFlagCompletionFunc("provider", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return utils.ContainList([]string{common.BAREMETAL, common.AliCloud, common.CONTAINER}, toComplete), cobra.ShellCompDirectiveNoFileComp
 	})
@@ -21,6 +21,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/utils"
 	"github.com/alibaba/sealer/logger"
 )
 
@@ -35,7 +36,8 @@ var saveCmd = &cobra.Command{
 sealer save -o [output file name] [image name]
 save kubernetes:v1.19.8 image to kubernetes.tar file:
 sealer save -o kubernetes.tar kubernetes:v1.19.8`,
-	Args: cobra.ExactArgs(1),
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: utils.ImageListFuncForCompletion,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ifs, err := image.NewImageFileService()
 		if err != nil {
@@ -0,0 +1,148 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/sbom"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+const sbomFormatTable = "table"
+
+type SBOMFlag struct {
+	Format   string
+	File     string
+	Platform string
+}
+
+var sbomConfig *SBOMFlag
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom IMAGE",
+	Short: "display or export the SBOM of a cloud image",
+	Long: `the SBOM (software bill of materials) lists the binaries, helm charts and container images
+bundled in the cloud image and how it was built, it is generated by sealer build.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: utils.ImageListFuncForCompletion,
+	Example: `display the SBOM:
+	sealer sbom kubernetes:v1.19.8
+
+export the SBOM in SPDX or CycloneDX:
+	sealer sbom kubernetes:v1.19.8 -o spdx-json --file sbom.spdx.json
+	sealer sbom kubernetes:v1.19.8 -o cyclonedx-json --file sbom.cdx.json
+
+the SBOM of a platform of multi-platform image:
+	sealer sbom kubernetes:v1.19.8 --platform linux/arm64
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		img, err := getSBOMImage(args[0], sbomConfig.Platform)
+		if err != nil {
+			return err
+		}
+		s, err := sbom.LoadFromImage(img)
+		if err != nil {
+			return err
+		}
+
+		if sbomConfig.Format == sbomFormatTable {
+			if sbomConfig.File != "" {
+				return fmt.Errorf("table format can not be exported to file")
+			}
+			printSBOM(s)
+			return nil
+		}
+		data, err := sbom.Encode(s, sbomConfig.Format)
+		if err != nil {
+			return err
+		}
+		if sbomConfig.File == "" {
+			fmt.Println(string(data))
+			return nil
+		}
+		if err = ioutil.WriteFile(sbomConfig.File, data, common.FileMode0644); err != nil {
+			return err
+		}
+		logger.Info("export SBOM of %s to %s", args[0], sbomConfig.File)
+		return nil
+	},
+}
+
+func getSBOMImage(name, platformStr string) (*v1.Image, error) {
+	named, err := reference.ParseToNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	is, err := store.NewDefaultImageStore()
+	if err != nil {
+		return nil, err
+	}
+	img, err := is.GetByName(named.Raw())
+	if err != nil {
+		return nil, err
+	}
+	if platformStr == "" {
+		return platform.ResolveDefault(is, img)
+	}
+	p, err := platform.Parse(platformStr)
+	if err != nil {
+		return nil, err
+	}
+	return platform.Resolve(is, img, p)
+}
+
+func printSBOM(s *sbom.SBOM) {
+	p := s.Provenance
+	fmt.Printf("Image:      %s\n", s.Image)
+	fmt.Printf("Builder:    sealer %s\n", p.BuilderVersion)
+	fmt.Printf("Build time: %s\n", p.BuildTime.Format(timeDefaultFormat))
+	fmt.Printf("Base image: %s\n", p.BaseImage)
+	fmt.Printf("Kubefile:   %s\n", p.KubefileDigest)
+	if p.Platform != "" {
+		fmt.Printf("Platform:   %s\n", p.Platform)
+	}
+	if len(p.BuildArgs) > 0 {
+		fmt.Printf("Build args: %s\n", strings.Join(p.BuildArgs, ","))
+	}
+	fmt.Println()
+
+	table := tablewriter.NewWriter(common.StdOut)
+	table.SetHeader([]string{"TYPE", "NAME", "VERSION", "PATH", "DIGEST"})
+	for _, c := range s.Components {
+		table.Append([]string{string(c.Type), c.Name, c.Version, c.Path, c.Digest})
+	}
+	table.Render()
+}
+
+func init() {
+	sbomConfig = &SBOMFlag{}
+	rootCmd.AddCommand(sbomCmd)
+	sbomCmd.Flags().StringVarP(&sbomConfig.Format, "output", "o", sbomFormatTable,
+		fmt.Sprintf("output format, one of %s|%s|%s|%s", sbomFormatTable, sbom.FormatJSON, sbom.FormatSPDX, sbom.FormatCycloneDX))
+	sbomCmd.Flags().StringVar(&sbomConfig.File, "file", "", "export the SBOM to file instead of stdout")
+	sbomCmd.Flags().StringVar(&sbomConfig.Platform, "platform", "", "the platform of multi-platform image, default is the host platform")
+}
@@ -0,0 +1,187 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/platform"
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/image/store"
+	"github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/scan"
+	v1 "github.com/alibaba/sealer/types/api/v1"
+)
+
+const (
+	scanFormatTable = "table"
+	scanFormatJSON  = "json"
+)
+
+type ScanFlag struct {
+	Severity string
+	Format   string
+	File     string
+	Platform string
+	Scanner  string
+	ExitCode int
+}
+
+var scanConfig *ScanFlag
+
+var scanCmd = &cobra.Command{
+	Use:   "scan IMAGE",
+	Short: "scan the vulnerabilities of a cloud image",
+	Long: `scan the container images cached in the registry and the binaries under bin of the cloud image
+by trivy or a scanner compatible with trivy command line, the scanner should be installed on the host.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: utils.ImageListFuncForCompletion,
+	Example: `scan all vulnerabilities:
+	sealer scan kubernetes:v1.19.8
+
+only report HIGH and CRITICAL vulnerabilities, exit with 1 if any found:
+	sealer scan kubernetes:v1.19.8 --severity HIGH,CRITICAL --exit-code 1
+
+export the report:
+	sealer scan kubernetes:v1.19.8 -o json --file report.json
+
+scan by a trivy compatible scanner:
+	sealer scan kubernetes:v1.19.8 --scanner /usr/local/bin/trivy
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		severities, err := scan.ParseSeverities(scanConfig.Severity)
+		if err != nil {
+			return err
+		}
+		report, err := scanCloudImage(args[0], scanConfig.Platform, &scan.Options{
+			Scanner:    scanConfig.Scanner,
+			Severities: severities,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch scanConfig.Format {
+		case scanFormatTable:
+			if scanConfig.File != "" {
+				return fmt.Errorf("table format can not be exported to file")
+			}
+			printScanReport(report)
+		case scanFormatJSON:
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			if scanConfig.File == "" {
+				fmt.Println(string(data))
+				break
+			}
+			if err = ioutil.WriteFile(scanConfig.File, data, common.FileMode0644); err != nil {
+				return err
+			}
+			logger.Info("export scan report of %s to %s", args[0], scanConfig.File)
+		default:
+			return fmt.Errorf("unsupported output format %s", scanConfig.Format)
+		}
+
+		if scanConfig.ExitCode != 0 && report.Total() > 0 {
+			logger.Error("%d vulnerabilities found in %s", report.Total(), args[0])
+			os.Exit(scanConfig.ExitCode)
+		}
+		return nil
+	},
+}
+
+// scanCloudImage pulls the image if not exist and scans it, all platforms of
+// multi-platform image are scanned if p is empty.
+func scanCloudImage(name, p string, opts *scan.Options) (*scan.Report, error) {
+	is, img, err := getCloudImage(name, p)
+	if err != nil {
+		return nil, err
+	}
+	return scan.ScanImage(is, img, opts)
+}
+
+// getCloudImage pulls the image if not exist, and returns the platform p of it, or itself if p is empty.
+func getCloudImage(name, p string) (store.ImageStore, *v1.Image, error) {
+	named, err := reference.ParseToNamed(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	imgSvc, err := image.NewImageService()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = imgSvc.PullIfNotExist(named.Raw()); err != nil {
+		return nil, nil, err
+	}
+	is, err := store.NewDefaultImageStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err := is.GetByName(named.Raw())
+	if err != nil {
+		return nil, nil, err
+	}
+	if p != "" {
+		pl, err := platform.Parse(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		if img, err = platform.Resolve(is, img, pl); err != nil {
+			return nil, nil, err
+		}
+	}
+	return is, img, nil
+}
+
+func printScanReport(report *scan.Report) {
+	table := tablewriter.NewWriter(common.StdOut)
+	table.SetHeader([]string{"TARGET", "TYPE", "ID", "PACKAGE", "INSTALLED", "FIXED", "SEVERITY"})
+	for _, t := range report.Targets {
+		for _, v := range t.Vulnerabilities {
+			table.Append([]string{t.Name, t.Type, v.VulnerabilityID, v.PkgName, v.InstalledVersion, v.FixedVersion, v.Severity})
+		}
+	}
+	table.Render()
+
+	summary := report.Summary()
+	var counts []string
+	for i := len(scan.Severities) - 1; i >= 0; i-- {
+		counts = append(counts, fmt.Sprintf("%s: %d", scan.Severities[i], summary[scan.Severities[i]]))
+	}
+	fmt.Printf("Total: %d (%s)\n", report.Total(), strings.Join(counts, ", "))
+}
+
+func init() {
+	scanConfig = &ScanFlag{}
+	rootCmd.AddCommand(scanCmd)
+	scanCmd.Flags().StringVar(&scanConfig.Severity, "severity", "", "severities of vulnerabilities to be reported, like HIGH,CRITICAL, default is all")
+	scanCmd.Flags().StringVarP(&scanConfig.Format, "output", "o", scanFormatTable, "output format, one of table|json")
+	scanCmd.Flags().StringVar(&scanConfig.File, "file", "", "export the report to file instead of stdout")
+	scanCmd.Flags().StringVar(&scanConfig.Platform, "platform", "", "only scan the platform of multi-platform image, default is all platforms")
+	scanCmd.Flags().StringVar(&scanConfig.Scanner, "scanner", scan.DefaultScanner, "the name or path of trivy compatible scanner")
+	scanCmd.Flags().IntVar(&scanConfig.ExitCode, "exit-code", 0, "exit code when vulnerabilities were found")
+}
@@ -0,0 +1,94 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/image/distributionutil"
+	"github.com/alibaba/sealer/image/reference"
+	"github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/pkg/sign"
+)
+
+type SignFlag struct {
+	Key         string
+	GenerateKey bool
+}
+
+var (
+	signConfig *SignFlag
+	// insecureSkipVerify is shared by the commands which pull or apply images.
+	insecureSkipVerify bool
+)
+
+const insecureSkipVerifyUsage = "skip verifying the signature of cloud image against the trusted keys"
+
+var signCmd = &cobra.Command{
+	Use:   "sign IMAGE",
+	Short: "sign a cloud image in registry",
+	Long: `sign the manifest of a pushed cloud image and push the signature to the same repository,
+sealer pull, run and apply verify the signature if public keys are put in ` + sign.DefaultTrustedKeysDir,
+	Example: `generate a key pair sealer.key and sealer.pub in current dir:
+	sealer sign --generate-key
+
+sign the image with the private key:
+	sealer sign registry.cn-qingdao.aliyuncs.com/sealer-io/kubernetes:v1.19.8 --key sealer.key
+`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: utils.ImageListFuncForCompletion,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if signConfig.GenerateKey {
+			dir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			if err = sign.GenerateKeyPair(dir); err != nil {
+				return err
+			}
+			logger.Info("generate %s and %s success, keep the private key safe", sign.PrivateKeyFile, sign.PublicKeyFile)
+			return nil
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("image is required to sign")
+		}
+		if signConfig.Key == "" {
+			return fmt.Errorf("--key is required to sign image")
+		}
+
+		named, err := reference.ParseToNamed(args[0])
+		if err != nil {
+			return err
+		}
+		manifestDigest, err := distributionutil.Sign(context.Background(), named, signConfig.Key)
+		if err != nil {
+			return fmt.Errorf("failed to sign image %s: %v", args[0], err)
+		}
+		logger.Info("sign image %s@%s success", named.CompleteName(), manifestDigest)
+		return nil
+	},
+}
+
+func init() {
+	signConfig = &SignFlag{}
+	rootCmd.AddCommand(signCmd)
+	signCmd.Flags().StringVar(&signConfig.Key, "key", "", "the ECDSA private key to sign image")
+	signCmd.Flags().BoolVar(&signConfig.GenerateKey, "generate-key", false, "generate a key pair in current dir")
+}
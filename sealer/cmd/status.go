@@ -0,0 +1,67 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/job"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [JOB ID]",
+	Short: "show the status of background jobs started by apply --async",
+	Example: `# list all jobs
+sealer status
+# show the status of a job
+sealer status 0d3fa2c3b9e1`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var jobs []*job.Job
+		if len(args) == 1 {
+			j, err := job.Get(args[0])
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, j)
+		} else {
+			var err error
+			if jobs, err = job.List(); err != nil {
+				return err
+			}
+		}
+
+		table := tablewriter.NewWriter(common.StdOut)
+		table.SetHeader([]string{"JOB ID", "COMMAND", "STATUS", "START", "END", "MESSAGE"})
+		for _, j := range jobs {
+			end := ""
+			if !j.EndTime.IsZero() {
+				end = j.EndTime.Format(timeDefaultFormat)
+			}
+			table.Append([]string{j.ID, strings.Join(j.Args, " "), string(j.Status),
+				j.StartTime.Format(timeDefaultFormat), end, j.Message})
+		}
+		table.Render()
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
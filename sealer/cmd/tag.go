@@ -18,6 +18,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/image"
+	"github.com/alibaba/sealer/image/utils"
 )
 
 var tagCmd = &cobra.Command{
@@ -33,6 +34,12 @@ var tagCmd = &cobra.Command{
 
 		return ims.Tag(args[0], args[1])
 	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return utils.ImageListFuncForCompletion(cmd, args, toComplete)
+	},
 }
 
 func init() {
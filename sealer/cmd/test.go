@@ -0,0 +1,93 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	imageutils "github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/pkg/imagetest"
+)
+
+var (
+	testOptions imagetest.Options
+	testFormat  string
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "test cloud images on a local cluster of docker containers",
+}
+
+var testRunCmd = &cobra.Command{
+	Use:   "run IMAGE",
+	Short: "apply a cloud image to docker containers and check the cluster",
+	Long: `run privileged docker containers on this host as the masters and nodes, apply the cloud image to them,
+check the apiserver is ready, all of them joined as ready nodes and the pods of all namespaces are ready, then
+remove the containers. It fails if the image can not be applied or any check fails, so that the cloud images
+can be tested in CI without cloud resources. The checks are retried until they pass or --wait passes.
+
+docker with the overlay2 storage driver is required, the containers are created like the ones of provider CONTAINER.`,
+	Example: `sealer test run kubernetes:v1.19.8
+# test on 3 masters and 1 node, and keep the containers to debug the cluster after the test
+sealer test run my-platform:latest --masters 3 --nodes 1 --keep
+# print the results in json
+sealer test run my-platform:latest -o json`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: imageutils.ImageListFuncForCompletion,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if testFormat != scanFormatTable && testFormat != scanFormatJSON {
+			return fmt.Errorf("unsupported output format %s", testFormat)
+		}
+		testOptions.Image = args[0]
+		report, err := imagetest.Run(signalContext(), testOptions)
+		if err != nil {
+			return err
+		}
+		if testFormat == scanFormatJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+		} else {
+			table := tablewriter.NewWriter(common.StdOut)
+			table.SetHeader([]string{"CHECK", "RESULT", "MESSAGE"})
+			for _, r := range report.Results {
+				table.Append([]string{r.Check, string(r.Level), r.Message})
+			}
+			table.Render()
+		}
+		if report.Failed() {
+			return fmt.Errorf("cloud image %s failed the test on cluster %s", args[0], report.Cluster)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	testCmd.AddCommand(testRunCmd)
+	testRunCmd.Flags().IntVar(&testOptions.Masters, "masters", 1, "the number of masters")
+	testRunCmd.Flags().IntVar(&testOptions.Nodes, "nodes", 0, "the number of nodes")
+	testRunCmd.Flags().DurationVar(&testOptions.Wait, "wait", imagetest.DefaultWait, "how long the checks are retried until they pass after the image is applied")
+	testRunCmd.Flags().BoolVar(&testOptions.Keep, "keep", false, "keep the containers after the test to debug the cluster")
+	testRunCmd.Flags().StringVarP(&testFormat, "output", "o", scanFormatTable, "output format, one of table|json")
+}
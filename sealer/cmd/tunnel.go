@@ -0,0 +1,102 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/tunnel"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+	"github.com/alibaba/sealer/utils"
+)
+
+var (
+	tunnelListen     string
+	tunnelKubeconfig string
+)
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "forward a local port to the registry or apiserver of cluster through SSH",
+}
+
+var tunnelRegistryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "forward a local port to the registry of cluster",
+	Long: `registry forwards the local port to the registry through SSH, docker trusts the registry on localhost
+without editing /etc/hosts or installing the cert of sea.hub. It runs until interrupted.`,
+	Args: cobra.NoArgs,
+	Example: `sealer tunnel registry
+docker tag nginx:latest 127.0.0.1:5000/nginx:latest && docker push 127.0.0.1:5000/nginx:latest`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, err := getTunnelCluster()
+		if err != nil {
+			return err
+		}
+		if tunnelListen == "" {
+			tunnelListen = tunnel.DefaultRegistryListen
+		}
+		return tunnel.NewRegistryTunnel(cluster, tunnelListen).Run()
+	},
+}
+
+var tunnelAPIServerCmd = &cobra.Command{
+	Use:   "apiserver",
+	Short: "forward a local port to the apiserver of master0",
+	Long: `apiserver forwards the local port to the apiserver of master0 through SSH, and writes a kubeconfig
+pointed to the local port, so kubectl works without editing /etc/hosts. It runs until interrupted.`,
+	Args: cobra.NoArgs,
+	Example: `sealer tunnel apiserver -c my-cluster
+kubectl --kubeconfig /root/.sealer/my-cluster/tunnel.kubeconfig get nodes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cluster, err := getTunnelCluster()
+		if err != nil {
+			return err
+		}
+		if tunnelListen == "" {
+			tunnelListen = tunnel.DefaultAPIServerListen
+		}
+		t := tunnel.NewAPIServerTunnel(cluster, tunnelListen)
+		path, err := t.WriteKubeconfig(tunnelKubeconfig)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("kubeconfig is written to %s, run: export KUBECONFIG=%s\n", path, path)
+		return t.Run()
+	},
+}
+
+func getTunnelCluster() (*v2.Cluster, error) {
+	if clusterName == "" {
+		cn, err := utils.GetDefaultClusterName()
+		if err != nil {
+			return nil, err
+		}
+		clusterName = cn
+	}
+	return utils.GetClusterFromFile(common.GetClusterWorkClusterfile(clusterName))
+}
+
+func init() {
+	rootCmd.AddCommand(tunnelCmd)
+	tunnelCmd.AddCommand(tunnelRegistryCmd)
+	tunnelCmd.AddCommand(tunnelAPIServerCmd)
+	tunnelCmd.PersistentFlags().StringVarP(&clusterName, "cluster-name", "c", "", "submit one cluster name")
+	tunnelCmd.PersistentFlags().StringVarP(&tunnelListen, "listen", "l", "", "the local address to listen, 127.0.0.1:5000 for registry and 127.0.0.1:6443 for apiserver by default")
+	tunnelAPIServerCmd.Flags().StringVar(&tunnelKubeconfig, "kubeconfig", "", "the path to write the kubeconfig, default is tunnel.kubeconfig in the cluster dir")
+}
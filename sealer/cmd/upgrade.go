@@ -21,6 +21,8 @@ import (
 	"os"
 
 	"github.com/alibaba/sealer/apply/v2"
+	imageutils "github.com/alibaba/sealer/image/utils"
+	"github.com/alibaba/sealer/pkg/timeout"
 	"github.com/alibaba/sealer/utils"
 
 	"github.com/spf13/cobra"
@@ -40,6 +42,9 @@ var upgradeCmd = &cobra.Command{
 	Example: `sealer upgrade kubernetes:v1.19.9 --cluster my-cluster`,
 	Args:    cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := timeout.SetOverrides(timeoutFlags); err != nil {
+			return err
+		}
 		var err error
 		//get clustername
 		if upgradeClusterName == "" {
@@ -63,8 +68,9 @@ var upgradeCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		return applier.Apply()
+		return applier.Apply(signalContext())
 	},
+	ValidArgsFunction: imageutils.ImageListFuncForCompletion,
 }
 
 func init() {
@@ -72,6 +78,7 @@ func init() {
 
 	// Here you will define your flags and configuration settings.
 	upgradeCmd.Flags().StringVarP(&upgradeClusterName, "cluster", "c", "", "The name of your cluster to upgrade")
+	upgradeCmd.Flags().StringSliceVar(&timeoutFlags, "timeout", nil, timeoutUsage)
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
 	// upgradeCmd.PersistentFlags().String("foo", "", "A help for foo")
@@ -0,0 +1,144 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/pkg/conformance"
+	"github.com/alibaba/sealer/pkg/smoke"
+)
+
+var (
+	conformanceOptions conformance.Options
+	smokeOptions       smoke.Options
+	verifySmoke        bool
+	verifyFormat       string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "verify the cluster applied from a cloud image",
+	Long: `verify runs the conformance tests of kubernetes by sonobuoy on master0 of the cluster and summarizes
+the results of each sonobuoy plugin. The sonobuoy of bin of the cloud image is used, or the one in PATH of master0
+if the image does not bundle it. The images of sonobuoy and the tests are pulled by the cluster, so cache them in the
+registry of the image for the clusters without internet access.
+
+--conformance quick runs a single test to check the cluster works in a few minutes, full runs all the tests of the
+kubernetes certification, which takes one or two hours. It fails if any plugin does not pass, so that it can be a
+quality gate of the image publishers.
+
+--smoke deploys a DaemonSet of busybox pulled from the registry of sealer to all the nodes in namespace sealer-smoke,
+and checks the image pull, pod-to-pod traffic across the nodes, pod-to-service traffic and DNS resolution of the
+service by them, and with --pvc that a PVC can be provisioned, mounted and written. It takes a minute, and the
+namespace is deleted after the checks. The conformance tests are not run if the smoke test fails.`,
+	Example: `sealer verify --conformance quick
+# run all the conformance tests of cluster my-cluster and save the results tarball of sonobuoy
+sealer verify -c my-cluster --conformance full --results my-cluster-conformance.tar.gz
+# print the summary in json
+sealer verify --conformance quick -o json
+# run the smoke test with a PVC of storage class local-path
+sealer verify --smoke --pvc --storage-class local-path`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if verifyFormat != scanFormatTable && verifyFormat != scanFormatJSON {
+			return fmt.Errorf("unsupported output format %s", verifyFormat)
+		}
+		if !verifySmoke && conformanceOptions.Mode == "" {
+			return fmt.Errorf("nothing to verify, --smoke or --conformance is required")
+		}
+		if verifySmoke {
+			smokeOptions.ClusterName = conformanceOptions.ClusterName
+			report, err := smoke.Run(signalContext(), smokeOptions)
+			if err != nil {
+				return err
+			}
+			if err = printVerifyReport(report, func() {
+				table := tablewriter.NewWriter(common.StdOut)
+				table.SetHeader([]string{"CHECK", "RESULT", "MESSAGE"})
+				for _, r := range report.Results {
+					table.Append([]string{r.Check, string(r.Status), r.Message})
+				}
+				table.Render()
+			}); err != nil {
+				return err
+			}
+			if !report.Passed() {
+				return fmt.Errorf("cluster %s failed the smoke test", report.Cluster)
+			}
+		}
+		if conformanceOptions.Mode == "" {
+			return nil
+		}
+		report, err := conformance.Run(signalContext(), conformanceOptions)
+		if err != nil {
+			return err
+		}
+		if err = printVerifyReport(report, func() {
+			table := tablewriter.NewWriter(common.StdOut)
+			table.SetHeader([]string{"PLUGIN", "STATUS", "TOTAL", "PASSED", "FAILED", "SKIPPED"})
+			for _, p := range report.Plugins {
+				table.Append([]string{p.Plugin, p.Status, strconv.Itoa(p.Total), strconv.Itoa(p.Passed), strconv.Itoa(p.Failed), strconv.Itoa(p.Skipped)})
+			}
+			table.Render()
+			for _, p := range report.Plugins {
+				for _, t := range p.FailedTests {
+					fmt.Fprintf(common.StdOut, "%s failed: %s\n", p.Plugin, t)
+				}
+			}
+		}); err != nil {
+			return err
+		}
+		if !report.Passed() {
+			return fmt.Errorf("cluster %s failed the %s conformance tests", report.Cluster, report.Mode)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringVarP(&conformanceOptions.ClusterName, "cluster-name", "c", "", "the name of the cluster, the only cluster in $HOME/.sealer if empty")
+	verifyCmd.Flags().StringVar(&conformanceOptions.Mode, "conformance", "", "run the conformance tests by sonobuoy, one of quick|full")
+	verifyCmd.Flags().DurationVar(&conformanceOptions.Wait, "wait", 0, "how long the conformance tests are waited for, 30m for quick and 3h for full if zero")
+	verifyCmd.Flags().StringVar(&conformanceOptions.Output, "results", "", "the local path to save the results tarball of sonobuoy to")
+	verifyCmd.Flags().BoolVar(&verifySmoke, "smoke", false, "run the smoke test of image pull, networking, DNS and the optional PVC")
+	verifyCmd.Flags().StringVar(&smokeOptions.Image, "smoke-image", smoke.DefaultImage, "the busybox image of the smoke pods, push it to the registry of the cloud image")
+	verifyCmd.Flags().DurationVar(&smokeOptions.Wait, "smoke-wait", smoke.DefaultWait, "how long the smoke pods are waited for to be ready")
+	verifyCmd.Flags().StringVar(&smokeOptions.ClusterDomain, "cluster-domain", smoke.DefaultClusterDomain, "the dns domain of the cluster the smoke service is resolved in")
+	verifyCmd.Flags().BoolVar(&smokeOptions.PVC, "pvc", false, "check a PVC can be provisioned and mounted in the smoke test")
+	verifyCmd.Flags().StringVar(&smokeOptions.StorageClass, "storage-class", "", "the storage class of the smoke PVC, the default one of the cluster if empty")
+	verifyCmd.Flags().StringVarP(&verifyFormat, "output", "o", scanFormatTable, "output format, one of table|json")
+}
+
+// printVerifyReport prints report in json, or as a table by printTable.
+func printVerifyReport(report interface{}, printTable func()) error {
+	if verifyFormat != scanFormatJSON {
+		printTable()
+		return nil
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
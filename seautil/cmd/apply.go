@@ -16,14 +16,19 @@ package cmd
 
 import (
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/alibaba/sealer/apply"
 	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils/ssh"
 )
 
-var clusterFile string
+var (
+	clusterFile  string
+	readyTimeout time.Duration
+)
 
 // applyCmd represents the apply command
 var applyCmd = &cobra.Command{
@@ -31,6 +36,7 @@ var applyCmd = &cobra.Command{
 	Short: "apply a kubernetes cluster",
 	Long:  `seautil apply -f cluster.yaml`,
 	Run: func(cmd *cobra.Command, args []string) {
+		ssh.ReadyTimeout = readyTimeout
 		applier, err := apply.NewApplierFromFile(clusterFile)
 		if err != nil {
 			logger.Error(err)
@@ -46,4 +52,5 @@ var applyCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(applyCmd)
 	applyCmd.Flags().StringVarP(&clusterFile, "clusterfile", "f", "", "cluster file filepath")
+	applyCmd.Flags().DurationVar(&readyTimeout, "ready-timeout", ssh.DefaultReadyTimeout, "the time to wait for the cloud instances running, reachable by ssh and cloud-init done")
 }
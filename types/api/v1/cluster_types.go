@@ -24,6 +24,16 @@ type SSH struct {
 	Pk       string `json:"pk,omitempty"`
 	PkPasswd string `json:"pkPasswd,omitempty"`
 	Port     string `json:"port,omitempty"`
+	// run remote commands by sudo if user is not root, password of sudo is SudoPasswd or Passwd,
+	// sudo must be NOPASSWD if both of them are empty.
+	Sudo       bool   `json:"sudo,omitempty"`
+	SudoPasswd string `json:"sudoPasswd,omitempty"`
+	// MaxSessions is the max number of sessions multiplexed on a connection to the host, more connections are
+	// dialed beyond it. It should not be larger than the MaxSessions of sshd, 10 by default.
+	MaxSessions int `json:"maxSessions,omitempty"`
+	// Edge marks the hosts behind NAT, which are not reachable by ssh from sealer. They are reached through the edge
+	// broker on master0, which the edge agents on them connect to.
+	Edge bool `json:"edge,omitempty"`
 }
 
 type Network struct {
@@ -37,7 +47,27 @@ type Hosts struct {
 	Count      string   `json:"count,omitempty"`
 	SystemDisk string   `json:"systemDisk,omitempty"`
 	DataDisks  []string `json:"dataDisks,omitempty"`
-	IPList     []string `json:"ipList,omitempty"`
+	// Disks are attached to the instances after DataDisks, and the ones with path are mounted to it when installing
+	Disks  []DataDisk `json:"disks,omitempty"`
+	IPList []string   `json:"ipList,omitempty"`
+	// IPPool are the baremetal hosts of the role beside the Count instances the cloud provider provisions,
+	// they are in IPList as well and not counted in Count
+	IPPool []string `json:"ipPool,omitempty"`
+}
+
+// DataDisk is a data disk the provider attaches to each instance of the role.
+type DataDisk struct {
+	// Size in GiB
+	Size string `json:"size,omitempty"`
+	// Category of the disk on the cloud, like cloud_essd, cloud_essd by default on ALI_CLOUD
+	Category string `json:"category,omitempty"`
+	// Path is the data dir the disk is mounted to, like /var/lib/etcd, or registry for the storage of the registry,
+	// the disk is left unformatted without it
+	Path string `json:"path,omitempty"`
+	// FSType is xfs or ext4, xfs by default
+	FSType string `json:"fsType,omitempty"`
+	// Device is the name of the disk on the instances, set by the provider
+	Device string `json:"device,omitempty"`
 }
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -39,6 +39,16 @@ type ImageSpec struct {
 	Layers        []Layer  `json:"layers,omitempty"`
 	SealerVersion string   `json:"sealer_version,omitempty"`
 	Platform      Platform `json:"platform"`
+	// Manifests is set on a multi-platform image, which holds no layers itself
+	// but points to one image per platform.
+	Manifests []ImageManifest `json:"manifests,omitempty"`
+}
+
+// ImageManifest references the image built for a single platform.
+type ImageManifest struct {
+	Platform Platform `json:"platform"`
+	Name     string   `json:"name"`
+	ID       string   `json:"id,omitempty"`
 }
 
 // ImageStatus defines the observed state of Image
@@ -74,6 +84,7 @@ type Platform struct {
 	Architecture string `json:"architecture,omitempty"`
 	OS           string `json:"os,omitempty"`
 	OSVersion    string `json:"os_version,omitempty"`
+	Variant      string `json:"variant,omitempty"`
 }
 
 func init() {
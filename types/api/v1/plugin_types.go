@@ -31,6 +31,7 @@ type PluginSpec struct {
 		LabelPlugin    = "LABEL"
 		ShellPlugin    = "SHELL"
 		HostNamePlugin = "HOSTNAME"
+		LoadBalancerPlugin = "LOADBALANCER"
 	*/
 	Type   string `json:"type,omitempty"`
 	Data   string `json:"data,omitempty"`
@@ -222,6 +222,22 @@ func (in *ConfigStatus) DeepCopy() *ConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataDisk) DeepCopyInto(out *DataDisk) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataDisk.
+func (in *DataDisk) DeepCopy() *DataDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(DataDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Hosts) DeepCopyInto(out *Hosts) {
 	*out = *in
@@ -230,11 +246,21 @@ func (in *Hosts) DeepCopyInto(out *Hosts) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]DataDisk, len(*in))
+		copy(*out, *in)
+	}
 	if in.IPList != nil {
 		in, out := &in.IPList, &out.IPList
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.IPPool != nil {
+		in, out := &in.IPPool, &out.IPPool
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -309,6 +335,23 @@ func (in *ImageList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageManifest) DeepCopyInto(out *ImageManifest) {
+	*out = *in
+	out.Platform = in.Platform
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageManifest.
+func (in *ImageManifest) DeepCopy() *ImageManifest {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageManifest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
 	*out = *in
@@ -318,6 +361,11 @@ func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
 		copy(*out, *in)
 	}
 	out.Platform = in.Platform
+	if in.Manifests != nil {
+		in, out := &in.Manifests, &out.Manifests
+		*out = make([]ImageManifest, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
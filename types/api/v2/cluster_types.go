@@ -19,6 +19,7 @@ package v2
 import (
 	"github.com/alibaba/sealer/common"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	v1 "github.com/alibaba/sealer/types/api/v1"
 )
@@ -37,6 +38,343 @@ type ClusterSpec struct {
 	Env   []string `json:"env,omitempty"`
 	Hosts []Host   `json:"hosts,omitempty"`
 	SSH   v1.SSH   `json:"ssh,omitempty"`
+	// Kubernetes overrides the generated kubeadm configs, without writing a full KubeadmConfig
+	Kubernetes KubernetesSpec `json:"kubernetes,omitempty"`
+	TimeSync   TimeSyncSpec   `json:"timeSync,omitempty"`
+	HostPrep   HostPrepSpec   `json:"hostPrep,omitempty"`
+	// Firewall opens the ports kubernetes needs on each host by its role, and closes them when the host is deleted
+	Firewall   FirewallSpec   `json:"firewall,omitempty"`
+	Proxy      ProxySpec      `json:"proxy,omitempty"`
+	Autoscaler AutoscalerSpec `json:"autoscaler,omitempty"`
+	Kubeconfig KubeconfigSpec `json:"kubeconfig,omitempty"`
+	// ImagePreload imports image tarballs shipped with rootfs on every host instead of pulling them from the registry
+	ImagePreload ImagePreloadSpec `json:"imagePreload,omitempty"`
+	// P2P deploys the P2P image distribution of CloudImage and pulls images through it on all hosts
+	P2P P2PSpec `json:"p2p,omitempty"`
+	// Timeouts bound the phases and remote commands which may hang on a broken host, zero means the default
+	Timeouts TimeoutsSpec `json:"timeouts,omitempty"`
+	// Webhooks receive the events of the cluster lifecycle, like apply started, succeeded and failed
+	Webhooks []WebhookSpec `json:"webhooks,omitempty"`
+	// Guest is what CMD runs to install the apps of CloudImage and where, the CMD of CloudImage on master0 by default
+	Guest GuestSpec `json:"guest,omitempty"`
+	// Units override the restart policy of the systemd units in systemd/ of CloudImage
+	Units []UnitSpec `json:"units,omitempty"`
+	// Registries are the private registries the hosts pull images from besides the sealer registry
+	Registries []RegistrySpec `json:"registries,omitempty"`
+}
+
+// RegistrySpec is a private registry with its credentials and mirrors, which is configured to the container runtime
+// of all hosts, by the hosts.toml in /etc/containerd/certs.d for containerd and mirror-registries for docker.
+type RegistrySpec struct {
+	// Domain of the images of the registry, with the port if it is not 443, like: harbor.example.com:8443
+	Domain string `json:"domain"`
+	// Server is the URL of the registry, https://<domain> by default
+	Server   string `json:"server,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Mirrors are the URLs the images are pulled from in order before the registry itself, like: https://mirror.example.com
+	Mirrors []string `json:"mirrors,omitempty"`
+	// SkipVerify skips verifying the TLS certs of the registry and its mirrors, containerd only
+	SkipVerify bool `json:"skipVerify,omitempty"`
+}
+
+// UnitSpec is the restart policy of a systemd unit sealer installs from CloudImage, like sealer-registry.service.
+type UnitSpec struct {
+	Name string `json:"name"`
+	// Restart is the Restart= of the unit, like on-failure, always by default
+	Restart string `json:"restart,omitempty"`
+	// RestartSec is the delay before restarting the unit, 5s by default
+	RestartSec metav1.Duration `json:"restartSec,omitempty"`
+}
+
+// GuestSpec selects the hosts the CMD of CloudImage runs on, the env of each host and the facts of cluster
+// like MASTER0_IP, REGISTRY_DOMAIN and POD_CIDR are exported to it.
+type GuestSpec struct {
+	// Roles run the CMD on all hosts of them, like: master, node. Empty means master0 only
+	Roles []string `json:"roles,omitempty"`
+	// Cmd overrides the CMD of CloudImage, the commands run in order in rootfs
+	Cmd []string `json:"cmd,omitempty"`
+	// Apps are the names of the app bundles in etc/apps.yaml of CloudImage to install, their commands run
+	// instead of the CMD of CloudImage, before Cmd
+	Apps []string `json:"apps,omitempty"`
+}
+
+// WebhookSpec is a Slack, DingTalk or generic HTTP webhook the events of the cluster lifecycle are posted to.
+type WebhookSpec struct {
+	// Type is one of generic, slack and dingtalk, generic by default which posts the JSON event itself
+	Type string `json:"type,omitempty"`
+	URL  string `json:"url"`
+	// Events are the types of the events sent, all if empty, like: apply-failed, nodes-joined
+	Events []string `json:"events,omitempty"`
+	// Headers are added to the requests, like: Authorization: Bearer xxx
+	Headers map[string]string `json:"headers,omitempty"`
+	// Secret signs the requests, as the X-Sealer-Signature-256 header of generic webhooks, or the sign of DingTalk robots
+	Secret string `json:"secret,omitempty"`
+}
+
+// TimeoutsSpec are the deadlines of the phases of apply, like: init: 20m, they are overridden by the --timeout flag.
+type TimeoutsSpec struct {
+	// SSHConnect is the deadline to connect a host by ssh, 15s by default
+	SSHConnect metav1.Duration `json:"sshConnect,omitempty"`
+	// Init is the deadline to init master0, 30m by default
+	Init metav1.Duration `json:"init,omitempty"`
+	// Join is the deadline to join all masters and nodes, 30m by default
+	Join metav1.Duration `json:"join,omitempty"`
+	// ImageDistribution is the deadline to send rootfs and preload images to all hosts, 30m by default
+	ImageDistribution metav1.Duration `json:"imageDistribution,omitempty"`
+	// Drain is the deadline to evict the pods of a node before upgrading or replacing it, 10m by default
+	Drain metav1.Duration `json:"drain,omitempty"`
+	// HealthCheck is the deadline to wait for apiserver healthy, 5m by default
+	HealthCheck metav1.Duration `json:"healthCheck,omitempty"`
+}
+
+// P2PSpec is the P2P image distribution layer like Dragonfly or Spegel, its agent runs on every host
+// as a registry mirror of the sealer registry.
+type P2PSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Port the agent serves the registry mirror on every host, 65001 by default
+	Port int `json:"port,omitempty"`
+	// Manifest of the P2P distribution in CloudImage, manifests/p2p.yaml by default
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// ImagePreloadSpec imports the image tarballs in images/preload of rootfs into the container runtime of all hosts
+// in parallel, the images not in them are still pulled from the registry.
+type ImagePreloadSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// KubeconfigSpec is how the local host running sealer accesses the apiserver.
+type KubeconfigSpec struct {
+	// EtcHosts adds the apiserver domain of master0 to the local /etc/hosts, by default the server of
+	// local kubeconfig is rewritten to master0 instead, which needs no root and leaves nothing behind
+	EtcHosts bool `json:"etcHosts,omitempty"`
+	// NoMerge writes the admin kubeconfig to ~/.kube/config if it does not exist, instead of merging it
+	// into ~/.kube/config as the context named after the cluster
+	NoMerge bool `json:"noMerge,omitempty"`
+}
+
+// AutoscalerSpec is the node group served to the cluster-autoscaler, nodes are joined from and returned to the host pool.
+type AutoscalerSpec struct {
+	MinNodes int `json:"minNodes,omitempty"`
+	MaxNodes int `json:"maxNodes,omitempty"`
+	// HostPool are the spare hosts which can be joined as nodes, they share the SSH of cluster
+	HostPool []string `json:"hostPool,omitempty"`
+}
+
+// ProxySpec is propagated to the container runtime, kubeadm and the outbound HTTP calls of sealer.
+type ProxySpec struct {
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	// NoProxy is appended to the generated one, which includes host IPs, service and pod CIDRs
+	NoProxy []string `json:"noProxy,omitempty"`
+}
+
+// HostPrepSpec is the desired OS state of all hosts, applied before installing and only the drifted items are changed.
+type HostPrepSpec struct {
+	// Sysctls are persisted in /etc/sysctl.d, like: net.ipv4.ip_forward: "1"
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+	// KernelModules are loaded and persisted in /etc/modules-load.d, like: br_netfilter, ip_vs
+	KernelModules []string `json:"kernelModules,omitempty"`
+	DisableSwap   bool     `json:"disableSwap,omitempty"`
+	// SELinux is one of enforcing, permissive and disabled, empty means unchanged
+	SELinux   string        `json:"selinux,omitempty"`
+	Firewalld FirewalldSpec `json:"firewalld,omitempty"`
+	// Disks are the dedicated devices mounted to the data dirs, like /var/lib/etcd and /var/lib/containerd
+	Disks []DiskSpec `json:"disks,omitempty"`
+	// EtcdMaxSyncLatency is the max latency of the synced writes to /var/lib/etcd on masters, which is checked
+	// before installing if it is set or a disk is mounted to /var/lib/etcd, 10ms by default
+	EtcdMaxSyncLatency metav1.Duration `json:"etcdMaxSyncLatency,omitempty"`
+}
+
+// DiskSpec is a block device formatted and mounted to a data dir, it is formatted only if it has no filesystem,
+// and the data dir must be empty before mounting.
+type DiskSpec struct {
+	// Device is the block device, like /dev/vdb
+	Device string `json:"device"`
+	// Path is the data dir, like /var/lib/etcd, or registry for the storage of the registry in rootfs
+	Path string `json:"path"`
+	// FSType formats the device if it has no filesystem, one of xfs and ext4, xfs by default
+	FSType string `json:"fsType,omitempty"`
+	// Options of mount in /etc/fstab, like noatime, defaults by default
+	Options []string `json:"options,omitempty"`
+	// Roles of the hosts to mount it, by default masters for /var/lib/etcd, the registry host for registry, and all hosts else
+	Roles []string `json:"roles,omitempty"`
+}
+
+// FirewallSpec is the ports of apiserver, etcd, kubelet, NodePort services and the CNI opened in the firewall of each host.
+type FirewallSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Backend is one of firewalld, ufw and iptables, empty means the running one of firewalld and ufw,
+	// the hosts running neither are skipped
+	Backend string `json:"backend,omitempty"`
+	// CNI opens the overlay and BGP ports of the CNI, one of calico, flannel, cilium and weave
+	CNI string `json:"cni,omitempty"`
+	// Ports are opened on all hosts besides the ones of kubernetes, like: 8080/tcp, 9100/tcp
+	Ports []string `json:"ports,omitempty"`
+	// KeepOnDelete leaves the ports open when the hosts are deleted
+	KeepOnDelete bool `json:"keepOnDelete,omitempty"`
+}
+
+type FirewalldSpec struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// KubernetesPorts opens the ports kubernetes needs by the role of host, if firewalld is running
+	KubernetesPorts bool `json:"kubernetesPorts,omitempty"`
+	// Ports are opened on all hosts, like: 8080/tcp, 30000-32767/tcp
+	Ports []string `json:"ports,omitempty"`
+}
+
+// TimeSyncSpec configs chrony on all hosts before installing, time skew breaks TLS and etcd.
+type TimeSyncSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Servers are the NTP servers, if empty master0 is the local time source of the other hosts, for air-gapped environment
+	Servers []string `json:"servers,omitempty"`
+}
+
+type KubernetesSpec struct {
+	// ImageRepository overrides the image repository of the control plane images, default is the registry of sealer
+	ImageRepository   string        `json:"imageRepository,omitempty"`
+	APIServer         ComponentSpec `json:"apiServer,omitempty"`
+	ControllerManager ComponentSpec `json:"controllerManager,omitempty"`
+	Scheduler         ComponentSpec `json:"scheduler,omitempty"`
+	// only extraArgs and image are used for the local etcd
+	Etcd    ComponentSpec `json:"etcd,omitempty"`
+	Kubelet ComponentSpec `json:"kubelet,omitempty"`
+	// KubeletOverrides patch the KubeletConfiguration of the hosts by their roles, in order
+	KubeletOverrides []KubeletOverride `json:"kubeletOverrides,omitempty"`
+	Audit            AuditSpec         `json:"audit,omitempty"`
+	EncryptionAtRest EncryptionSpec    `json:"encryptionAtRest,omitempty"`
+	DNS              DNSSpec           `json:"dns,omitempty"`
+	KubeProxy        KubeProxySpec     `json:"kubeProxy,omitempty"`
+	Networking       NetworkingSpec    `json:"networking,omitempty"`
+	// FeatureGates are set to apiserver, controller-manager, scheduler, kubelet and kube-proxy, checked against the
+	// kubernetes version
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+	// CRISocket is the CRI socket kubeadm uses, like unix:///run/containerd/containerd.sock, it overrides the one
+	// in the metadata of CloudImage
+	CRISocket string `json:"criSocket,omitempty"`
+}
+
+// NetworkingSpec overrides the networking of the kubeadm ClusterConfiguration, sealer run --podcidr and --svccidr set it.
+type NetworkingSpec struct {
+	PodSubnet     string `json:"podSubnet,omitempty"`
+	ServiceSubnet string `json:"serviceSubnet,omitempty"`
+}
+
+// KubeletOverride patches the KubeletConfiguration of the hosts with any of Roles, like the evictionHard, maxPods and
+// reservedCPUs of the edge or GPU nodes.
+type KubeletOverride struct {
+	Roles []string `json:"roles,omitempty"`
+	// Config is merged into the KubeletConfiguration as a JSON merge patch, a field set to null removes it
+	Config runtime.RawExtension `json:"config,omitempty"`
+}
+
+// KubeProxySpec configs kube-proxy, rendered into the KubeProxyConfiguration.
+type KubeProxySpec struct {
+	// Mode is iptables, ipvs or nftables, default is the one in the kubeadm config of CloudImage, usually ipvs
+	Mode      string        `json:"mode,omitempty"`
+	IPVS      IPVSSpec      `json:"ipvs,omitempty"`
+	Conntrack ConntrackSpec `json:"conntrack,omitempty"`
+}
+
+// IPVSSpec is only used in ipvs mode.
+type IPVSSpec struct {
+	// Scheduler is the ipvs scheduler, like rr, wrr, lc and sh, default is rr
+	Scheduler string `json:"scheduler,omitempty"`
+	// StrictARP is required by the load balancers announcing the service IPs by ARP, like MetalLB
+	StrictARP bool `json:"strictARP,omitempty"`
+	// the timeouts of the ipvs connections, the same as ipvsadm --set
+	TCPTimeout    metav1.Duration `json:"tcpTimeout,omitempty"`
+	TCPFinTimeout metav1.Duration `json:"tcpFinTimeout,omitempty"`
+	UDPTimeout    metav1.Duration `json:"udpTimeout,omitempty"`
+}
+
+// ConntrackSpec configs the conntrack table kube-proxy sets on the host.
+type ConntrackSpec struct {
+	// MaxPerCore is the max connections tracked per CPU core, the table size is the larger of it and Min
+	MaxPerCore            int32           `json:"maxPerCore,omitempty"`
+	Min                   int32           `json:"min,omitempty"`
+	TCPEstablishedTimeout metav1.Duration `json:"tcpEstablishedTimeout,omitempty"`
+	TCPCloseWaitTimeout   metav1.Duration `json:"tcpCloseWaitTimeout,omitempty"`
+}
+
+// DNSSpec configs the cluster DNS, rendered into the kubelet config and the CoreDNS ConfigMap.
+type DNSSpec struct {
+	// ClusterDNS is the IP of the kube-dns service, default is the 10th IP of the service subnet
+	ClusterDNS string `json:"clusterDNS,omitempty"`
+	// Upstreams are the resolvers CoreDNS forwards to, default is /etc/resolv.conf of the host
+	Upstreams []string `json:"upstreams,omitempty"`
+	// CorefileSnippets are server blocks appended to the Corefile, like stub domains
+	CorefileSnippets []string         `json:"corefileSnippets,omitempty"`
+	NodeLocalDNS     NodeLocalDNSSpec `json:"nodeLocalDNS,omitempty"`
+	// Provider resolves the apiserver and registry domains by a DNS instead of /etc/hosts of the hosts
+	Provider DNSProviderSpec `json:"provider,omitempty"`
+}
+
+// DNSProviderSpec selects where the apiserver and registry domains are registered.
+type DNSProviderSpec struct {
+	// Name is hosts, coredns, alidns, route53 or shell, default is hosts which writes them to /etc/hosts of the hosts.
+	// coredns adds them to the hosts plugin of CoreDNS for pods, the hosts still resolve them by /etc/hosts
+	Name string `json:"name,omitempty"`
+	// Zone is the zone the records are in, like cluster.local, the domains out of it are still written to /etc/hosts,
+	// required by alidns and route53, all domains are registered by shell if empty
+	Zone string `json:"zone,omitempty"`
+	// ZoneID is the ID of the hosted zone of route53
+	ZoneID string `json:"zoneID,omitempty"`
+	// TTL of the records in seconds, default is 60
+	TTL int `json:"ttl,omitempty"`
+	// APIServerIPs are the IPs of the apiserver domain, like the IPs of a load balancer, default is the IPs of masters
+	APIServerIPs []string `json:"apiServerIPs,omitempty"`
+	// Register and Deregister are the commands of shell, run on the local host once per domain with
+	// SEALER_DNS_DOMAIN, SEALER_DNS_IPS (comma separated) and SEALER_DNS_TTL
+	Register   string `json:"register,omitempty"`
+	Deregister string `json:"deregister,omitempty"`
+}
+
+// NodeLocalDNSSpec deploys the node-local dns cache, kubelet uses LocalIP as the cluster DNS if enabled.
+type NodeLocalDNSSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// LocalIP is the link local IP the cache listens on, default is 169.254.20.10
+	LocalIP string `json:"localIP,omitempty"`
+	// Image default is k8s-dns-node-cache in the image repository of the cluster
+	Image string `json:"image,omitempty"`
+}
+
+// AuditSpec configs the apiserver audit log, which is enabled by default.
+type AuditSpec struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// PolicyFile is the audit policy file path in rootfs, ship it using Config, default is statics/audit-policy.yml
+	PolicyFile string `json:"policyFile,omitempty"`
+	LogPath    string `json:"logPath,omitempty"`
+	MaxAge     int    `json:"maxAge,omitempty"`
+	MaxBackup  int    `json:"maxBackup,omitempty"`
+	MaxSize    int    `json:"maxSize,omitempty"`
+}
+
+// EncryptionSpec configs the encryption of resources in etcd.
+type EncryptionSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Provider is one of aescbc, aesgcm and secretbox, default is aescbc
+	Provider string `json:"provider,omitempty"`
+	// Resources to encrypt, default is secrets
+	Resources []string `json:"resources,omitempty"`
+}
+
+type ComponentSpec struct {
+	// flag name without leading dashes, like: audit-log-maxage: "30"
+	ExtraArgs    map[string]string `json:"extraArgs,omitempty"`
+	ExtraVolumes []HostPathMount   `json:"extraVolumes,omitempty"`
+	// Image overrides the image of the control plane component, like a patched kube-apiserver, it is pushed to the
+	// image repository of the cluster
+	Image string `json:"image,omitempty"`
+}
+
+type HostPathMount struct {
+	Name      string `json:"name"`
+	HostPath  string `json:"hostPath"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+	PathType  string `json:"pathType,omitempty"`
 }
 
 type Host struct {
@@ -46,12 +384,23 @@ type Host struct {
 	SSH v1.SSH `json:"ssh,omitempty"`
 	//overwrite env
 	Env []string `json:"env,omitempty"`
+	// Labels are set to the nodes of the hosts, like: node-role.kubernetes.io/ingress: ""
+	Labels map[string]string `json:"labels,omitempty"`
+	// Taints are set to the nodes of the hosts, like: dedicated=ingress:NoSchedule
+	Taints []string `json:"taints,omitempty"`
+	// Disks overwrite the ones of spec.hostPrep.disks with the same path, like a different device
+	Disks []DiskSpec `json:"disks,omitempty"`
+	// Kubelet patches the KubeletConfiguration of the hosts over the kubeletOverrides of their roles
+	Kubelet *runtime.RawExtension `json:"kubelet,omitempty"`
 }
 
 // ClusterStatus defines the observed state of Cluster
 type ClusterStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// AppliedImages are the CloudImages applied to the cluster in order, like the base image and the applications.
+	AppliedImages []string `json:"appliedImages,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -36,7 +36,9 @@ import (
 const compressionBufSize = 32768
 
 type Options struct {
-	Compress    bool
+	Compress bool
+	// Compression of Decompress, it is detected from the stream if empty.
+	Compression Compression
 	KeepRootDir bool
 	ToStream    bool
 }
@@ -66,20 +68,16 @@ func Untar(src io.Reader, dst string) (int64, error) {
 	return Decompress(src, dst, Options{Compress: false})
 }
 
-// GzipCompress make the tar stream to be gzip stream.
+// GzipCompress make the tar stream to be gzip stream, the stream is compressed in parallel.
 func GzipCompress(in io.Reader) (io.ReadCloser, chan struct{}) {
 	compressionDone := make(chan struct{})
 
 	pipeReader, pipeWriter := io.Pipe()
 	// Use a bufio.Writer to avoid excessive chunking in HTTP request.
 	bufWriter := bufio.NewWriterSize(pipeWriter, compressionBufSize)
-	compressor := gzip.NewWriter(bufWriter)
 
 	go func() {
-		_, err := io.Copy(compressor, in)
-		if err == nil {
-			err = compressor.Close()
-		}
+		err := parallelGzip(in, bufWriter)
 		if err == nil {
 			err = bufWriter.Flush()
 		}
@@ -316,10 +314,12 @@ func Decompress(src io.Reader, dst string, options Options) (int64, error) {
 
 	reader := src
 	if options.Compress {
-		reader, err = gzip.NewReader(src)
+		decompressed, err := DecompressStream(src, options.Compression)
 		if err != nil {
 			return 0, err
 		}
+		defer decompressed.Close()
+		reader = decompressed
 	}
 
 	var (
@@ -0,0 +1,181 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+)
+
+type Compression string
+
+const (
+	Gzip Compression = "gzip"
+	// Zstd compresses and decompresses by the zstd binary, which should be installed on the host.
+	Zstd Compression = "zstd"
+
+	zstdBinary = "zstd"
+	// each block is compressed as a gzip member in parallel, the members
+	// are concatenated in order which is still a valid gzip stream.
+	gzipBlockSize = 1024 * 1024
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseCompression returns gzip if s is empty.
+func ParseCompression(s string) (Compression, error) {
+	switch Compression(s) {
+	case "", Gzip:
+		return Gzip, nil
+	case Zstd:
+		return Zstd, nil
+	}
+	return "", fmt.Errorf("unsupported compression %s, should be one of %s|%s", s, Gzip, Zstd)
+}
+
+// CompressStream compresses in by c, the returned reader should be closed by caller.
+func CompressStream(in io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case "", Gzip:
+		stream, _ := GzipCompress(in)
+		return stream, nil
+	case Zstd:
+		return runZstd(in, "-q", "-c", "-T0", "-3")
+	}
+	return nil, fmt.Errorf("unsupported compression %s", c)
+}
+
+// DecompressStream decompresses in by c, the compression is detected by the
+// magic number of in if c is empty.
+func DecompressStream(in io.Reader, c Compression) (io.ReadCloser, error) {
+	if c == "" {
+		br := bufio.NewReader(in)
+		magic, err := br.Peek(len(zstdMagic))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		c = Gzip
+		if bytes.HasPrefix(magic, zstdMagic) {
+			c = Zstd
+		} else if !bytes.HasPrefix(magic, gzipMagic) {
+			return nil, fmt.Errorf("unknown compression of layer stream")
+		}
+		in = br
+	}
+
+	switch c {
+	case Gzip:
+		return gzip.NewReader(in)
+	case Zstd:
+		return runZstd(in, "-q", "-d", "-c")
+	}
+	return nil, fmt.Errorf("unsupported compression %s", c)
+}
+
+func runZstd(in io.Reader, args ...string) (io.ReadCloser, error) {
+	if _, err := exec.LookPath(zstdBinary); err != nil {
+		return nil, fmt.Errorf("zstd is required for zstd compressed layers: %v", err)
+	}
+	var stderr bytes.Buffer
+	pr, pw := io.Pipe()
+	cmd := exec.Command(zstdBinary, args...) // #nosec
+	cmd.Stdin = in
+	cmd.Stdout = pw
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			_ = pw.CloseWithError(fmt.Errorf("failed to run zstd: %v, %s", err, stderr.String()))
+			return
+		}
+		_ = pw.Close()
+	}()
+	return pr, nil
+}
+
+// parallelGzip compresses the blocks of in by all cpus and writes them to w in order.
+func parallelGzip(in io.Reader, w io.Writer) error {
+	var (
+		workers = runtime.NumCPU()
+		// the results of blocks in order, its capacity limits the blocks in memory.
+		results = make(chan chan []byte, workers)
+		readErr = make(chan error, 1)
+	)
+	go func() {
+		defer close(results)
+		for {
+			buf := make([]byte, gzipBlockSize)
+			n, err := io.ReadFull(in, buf)
+			if n > 0 {
+				res := make(chan []byte, 1)
+				results <- res
+				go func(block []byte) {
+					res <- gzipBlock(block)
+				}(buf[:n])
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				readErr <- nil
+				return
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	var (
+		written bool
+		err     error
+	)
+	for res := range results {
+		if err != nil {
+			// drain the results to release the reader goroutine.
+			<-res
+			continue
+		}
+		_, err = w.Write(<-res)
+		written = true
+	}
+	if err != nil {
+		return err
+	}
+	if err = <-readErr; err != nil {
+		return err
+	}
+	if !written {
+		_, err = w.Write(gzipBlock(nil))
+	}
+	return err
+}
+
+func gzipBlock(block []byte) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	// writing to bytes.Buffer never fails.
+	_, _ = gw.Write(block)
+	_ = gw.Close()
+	return buf.Bytes()
+}
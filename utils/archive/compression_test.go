@@ -0,0 +1,62 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os/exec"
+	"testing"
+)
+
+func TestCompressStream(t *testing.T) {
+	compressions := []Compression{Gzip}
+	if _, err := exec.LookPath(zstdBinary); err == nil {
+		compressions = append(compressions, Zstd)
+	}
+	for _, size := range []int{0, 100, 3*gzipBlockSize + 7} {
+		data := make([]byte, size)
+		rand.Read(data[:size/2])
+		for _, c := range compressions {
+			stream, err := CompressStream(bytes.NewReader(data), c)
+			if err != nil {
+				t.Fatalf("CompressStream(%s) error: %v", c, err)
+			}
+			compressed, err := ioutil.ReadAll(stream)
+			if err != nil {
+				t.Fatalf("CompressStream(%s) error: %v", c, err)
+			}
+			// the compression is detected if empty.
+			for _, dc := range []Compression{c, ""} {
+				decompressed, err := DecompressStream(bytes.NewReader(compressed), dc)
+				if err != nil {
+					t.Fatalf("DecompressStream(%s) error: %v", dc, err)
+				}
+				got, err := ioutil.ReadAll(decompressed)
+				if err != nil {
+					t.Fatalf("DecompressStream(%s) error: %v", dc, err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("DecompressStream(%s) of %d bytes mismatch", dc, size)
+				}
+			}
+		}
+	}
+
+	if _, err := DecompressStream(bytes.NewReader([]byte("plain text")), ""); err == nil {
+		t.Errorf("DecompressStream() should fail for unknown compression")
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"io/ioutil"
 	"strings"
 
+	"github.com/spf13/cobra"
+
 	v2 "github.com/alibaba/sealer/types/api/v2"
 
 	"github.com/alibaba/sealer/cert"
@@ -49,6 +51,21 @@ func GetDefaultClusterName() (string, error) {
 	return "", ErrClusterNotExist
 }
 
+// ClusterListFuncForCompletion completes the names of the clusters whose Clusterfiles are in $HOME/.sealer.
+func ClusterListFuncForCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	files, err := ioutil.ReadDir(fmt.Sprintf("%s/.sealer", cert.GetUserHomeDir()))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var clusters []string
+	for _, f := range files {
+		if f.IsDir() && strings.HasPrefix(f.Name(), toComplete) && IsFileExist(common.GetClusterWorkClusterfile(f.Name())) {
+			clusters = append(clusters, f.Name())
+		}
+	}
+	return clusters, cobra.ShellCompDirectiveNoFileComp
+}
+
 func GetClusterFromFile(filepath string) (cluster *v2.Cluster, err error) {
 	cluster = &v2.Cluster{}
 	if err = UnmarshalYamlFile(filepath, cluster); err != nil {
@@ -0,0 +1,45 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClusterListFuncForCompletion(t *testing.T) {
+	home := t.TempDir()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	for _, dir := range []string{"my-cluster", "my-test", "other", "no-clusterfile"} {
+		if err := os.MkdirAll(filepath.Join(home, ".sealer", dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if dir == "no-clusterfile" {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(home, ".sealer", dir, "Clusterfile"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, _ := ClusterListFuncForCompletion(nil, nil, "my-")
+	if want := []string{"my-cluster", "my-test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ClusterListFuncForCompletion() = %v, want %v", got, want)
+	}
+}
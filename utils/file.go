@@ -460,6 +460,20 @@ func GetFileSize(path string) (size int64, err error) {
 	return size, err
 }
 
+// FormatSize returns the size in B, KB, MB or GB.
+func FormatSize(size int64) (Size string) {
+	if size < 1024 {
+		Size = fmt.Sprintf("%.2fB", float64(size)/float64(1))
+	} else if size < (1024 * 1024) {
+		Size = fmt.Sprintf("%.2fKB", float64(size)/float64(1024))
+	} else if size < (1024 * 1024 * 1024) {
+		Size = fmt.Sprintf("%.2fMB", float64(size)/float64(1024*1024))
+	} else {
+		Size = fmt.Sprintf("%.2fGB", float64(size)/float64(1024*1024*1024))
+	}
+	return
+}
+
 func GetFilesSize(paths []string) (int64, error) {
 	var size int64
 	for i := range paths {
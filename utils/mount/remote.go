@@ -0,0 +1,36 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mount
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/sealer/utils/ssh"
+)
+
+const remoteMountFSType = "findmnt -rn -o FSTYPE --mountpoint %s || true"
+
+// GetRemoteMountFSType returns the fs type of the mount at target on ip, like overlay or fuse.fuse-overlayfs, and
+// empty if target is not a mount point. Unlike GetRemoteMountDetails, the mounts under target are not taken as it.
+func GetRemoteMountFSType(s ssh.Interface, ip, target string) (string, error) {
+	out, err := s.Cmd(ip, fmt.Sprintf(remoteMountFSType, target))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the mount of %s on %s: %v", target, ip, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	// the last one is visible if several are mounted at target.
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
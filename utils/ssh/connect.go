@@ -34,7 +34,8 @@ import (
 /**
   SSH connection operation
 */
-func (s *SSH) connect(host string) (*ssh.Client, error) {
+// dial creates a new connection to host, the commands and file transfers share the pooled ones returned by connect.
+func (s *SSH) dial(host string) (*ssh.Client, error) {
 	auth := s.sshAuthMethod(s.Password, s.PkFile, s.PkPassword)
 	config := ssh.Config{
 		Ciphers: []string{"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-gcm@openssh.com", "arcfour256", "arcfour128", "aes128-cbc", "3des-cbc", "aes192-cbc", "aes256-cbc"},
@@ -52,21 +53,44 @@ func (s *SSH) connect(host string) (*ssh.Client, error) {
 			return nil
 		},
 	}
-	ip, port := utils.GetSSHHostIPAndPort(host)
-	addr := s.addrReformat(ip, port)
-	return ssh.Dial("tcp", addr, clientConfig)
-}
-
-func (s *SSH) Connect(host string) (*ssh.Client, *ssh.Session, error) {
-	client, err := s.connect(host)
+	var (
+		conn net.Conn
+		err  error
+	)
+	switch {
+	case s.Gateway != nil:
+		conn, err = s.Gateway.dial(host, *s.Timeout)
+	case s.Jump != nil:
+		conn, err = s.Jump.dial(s.addr(host), *s.Timeout)
+	default:
+		return ssh.Dial("tcp", s.addr(host), clientConfig)
+	}
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, s.addr(host), clientConfig)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
 
-	session, err := client.NewSession()
+func (s *SSH) addr(host string) string {
+	ip, port := utils.GetSSHHostIPAndPort(host)
+	return s.addrReformat(ip, port)
+}
+
+// Connect opens a session on a pooled connection to host, closing the returned Conn returns its slot to the pool.
+func (s *SSH) Connect(host string) (*Conn, *ssh.Session, error) {
+	client, session, err := s.newSession(host)
 	if err != nil {
-		_ = client.Close()
-		return nil, nil, err
+		// the pooled connection may be broken, or sshd allows less sessions on it, retry on a new one.
+		client, session, err = s.newSession(host)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	modes := ssh.TerminalModes{
@@ -84,6 +108,20 @@ func (s *SSH) Connect(host string) (*ssh.Client, *ssh.Session, error) {
 	return client, session, nil
 }
 
+// newSession discards the pooled connection failing to open a session, so the next one is on another connection.
+func (s *SSH) newSession(host string) (*Conn, *ssh.Session, error) {
+	client, err := s.connect(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		client.discard()
+		return nil, nil, err
+	}
+	return client, session, nil
+}
+
 func (s *SSH) sshAuthMethod(password, pkFile, pkPasswd string) (auth []ssh.AuthMethod) {
 	if fileExist(pkFile) {
 		am, err := s.sshPrivateKeyMethod(pkFile, pkPasswd)
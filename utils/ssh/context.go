@@ -0,0 +1,220 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DirectionUpload   = "upload"
+	DirectionDownload = "download"
+)
+
+// Observer is notified of the remote commands and file transfers with the context they run, like to collect metrics.
+type Observer interface {
+	ObserveCommand(ctx context.Context, host string, duration time.Duration)
+	ObserveTransfer(ctx context.Context, host, direction string, bytes int64, duration time.Duration)
+}
+
+var observer Observer
+
+// SetObserver makes o observe the remote commands and file transfers of all ssh clients.
+func SetObserver(o Observer) {
+	observer = o
+}
+
+func observeCommand(ctx context.Context, host string, start time.Time) {
+	if observer != nil {
+		observer.ObserveCommand(ctx, host, time.Since(start))
+	}
+}
+
+func observeTransfer(ctx context.Context, host, direction string, bytes int64, start time.Time) {
+	if observer != nil {
+		observer.ObserveTransfer(ctx, host, direction, bytes, time.Since(start))
+	}
+}
+
+// Tracer starts a span for each remote command and file transfer as the child of the span in ctx,
+// the returned func ends it with the result.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, attributes map[string]string) func(err error)
+}
+
+var tracer Tracer
+
+// SetTracer makes t trace the remote commands and file transfers of all ssh clients.
+func SetTracer(t Tracer) {
+	tracer = t
+}
+
+// traceCommand only records the program of cmd, the rest of it may carry tokens or passwords.
+func traceCommand(ctx context.Context, host, cmd string) func(err error) {
+	if tracer == nil {
+		return func(error) {}
+	}
+	program := cmd
+	if fields := strings.Fields(cmd); len(fields) > 0 {
+		program = fields[0]
+	}
+	return tracer.StartSpan(ctx, "ssh "+program, map[string]string{"host": host, "ssh.command": program})
+}
+
+func traceTransfer(ctx context.Context, host, direction, localPath, remotePath string) func(err error) {
+	if tracer == nil {
+		return func(error) {}
+	}
+	return tracer.StartSpan(ctx, "ssh "+direction, map[string]string{"host": host, "local.path": localPath, "remote.path": remotePath})
+}
+
+// OutputLogger opens the writer which the output of the remote commands run with ctx on host is appended to, like
+// the log file of the host in the running phase, path is where the output is kept. It returns a nil writer if the
+// output with ctx is not logged.
+type OutputLogger interface {
+	Open(ctx context.Context, host string) (w io.WriteCloser, path string, err error)
+}
+
+var outputLogger OutputLogger
+
+// SetOutputLogger makes l log the output of the remote commands of all ssh clients.
+func SetOutputLogger(l OutputLogger) {
+	outputLogger = l
+}
+
+// openOutput returns the writer of the output of cmd on host with ctx, nil if it is not logged, a line with the
+// program of cmd is written first.
+func openOutput(ctx context.Context, host, cmd string) (io.WriteCloser, string) {
+	if outputLogger == nil {
+		return nil, ""
+	}
+	w, path, err := outputLogger.Open(ctx, host)
+	if err != nil || w == nil {
+		return nil, ""
+	}
+	program := cmd
+	if fields := strings.Fields(cmd); len(fields) > 0 {
+		program = fields[0]
+	}
+	_, _ = fmt.Fprintf(w, "### %s %s\n", time.Now().Format(time.RFC3339), program)
+	return w, path
+}
+
+var (
+	contextsLock sync.RWMutex
+	contexts     = map[string]context.Context{}
+)
+
+// BindContext makes the ssh clients returned by GetHostSSHClient for the hosts in cluster clusterName
+// stop their remote commands and file transfers once ctx is done, until the returned func is called,
+// which restores the context bound before.
+func BindContext(clusterName string, ctx context.Context) func() {
+	contextsLock.Lock()
+	defer contextsLock.Unlock()
+	prev, bound := contexts[clusterName]
+	contexts[clusterName] = ctx
+	return func() {
+		contextsLock.Lock()
+		defer contextsLock.Unlock()
+		if contexts[clusterName] != ctx {
+			return
+		}
+		if bound {
+			contexts[clusterName] = prev
+		} else {
+			delete(contexts, clusterName)
+		}
+	}
+}
+
+func boundContext(clusterName string) (context.Context, bool) {
+	contextsLock.RLock()
+	defer contextsLock.RUnlock()
+	ctx, ok := contexts[clusterName]
+	return ctx, ok
+}
+
+// Context returns the context bound to cluster clusterName, or context.Background if none is bound.
+func Context(clusterName string) context.Context {
+	if ctx, ok := boundContext(clusterName); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns the client running CmdAsync, Cmd, Copy and Fetch of s with ctx.
+func WithContext(ctx context.Context, s Interface) Interface {
+	return &contextClient{Interface: s, ctx: ctx}
+}
+
+type contextClient struct {
+	Interface
+	ctx context.Context
+}
+
+func (c *contextClient) CmdAsync(host string, cmd ...string) error {
+	return c.CmdAsyncContext(c.ctx, host, cmd...)
+}
+
+func (c *contextClient) Cmd(host, cmd string) ([]byte, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	end := traceCommand(c.ctx, host, cmd)
+	out, err := c.Interface.Cmd(host, cmd)
+	end(err)
+	return out, err
+}
+
+func (c *contextClient) Copy(host, srcFilePath, dstFilePath string) error {
+	return c.CopyContext(c.ctx, host, srcFilePath, dstFilePath)
+}
+
+func (c *contextClient) Fetch(host, srcFilePath, dstFilePath string) error {
+	return c.FetchContext(c.ctx, host, srcFilePath, dstFilePath)
+}
+
+func (c *contextClient) CopyDir(host, localDir, remoteDir string, opts CopyOptions) error {
+	return c.CopyDirContext(c.ctx, host, localDir, remoteDir, opts)
+}
+
+func (c *contextClient) FetchDir(host, localDir, remoteDir string, opts CopyOptions) error {
+	return c.FetchDirContext(c.ctx, host, localDir, remoteDir, opts)
+}
+
+// closeOnDone calls abort then closes closers once ctx is done, until the returned func is called.
+func closeOnDone(ctx context.Context, abort func(), closers ...io.Closer) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if abort != nil {
+				abort()
+			}
+			for _, c := range closers {
+				_ = c.Close()
+			}
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/alibaba/sealer/types/api/v1"
+	v2 "github.com/alibaba/sealer/types/api/v2"
+)
+
+func TestBindContext(t *testing.T) {
+	cluster := &v2.Cluster{}
+	cluster.Name = "bind-context"
+	cluster.Spec.Hosts = []v2.Host{{IPS: []string{"192.168.0.2"}}}
+	cluster.Spec.SSH = v1.SSH{User: "root"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	unbind := BindContext(cluster.Name, ctx)
+	client, err := GetHostSSHClient("192.168.0.2", cluster)
+	if err != nil {
+		t.Fatalf("failed to get ssh client: %v", err)
+	}
+	cancel()
+	if err = client.CmdAsync("192.168.0.2", "hostname"); err != context.Canceled {
+		t.Errorf("CmdAsync() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err = client.Cmd("192.168.0.2", "hostname"); err != context.Canceled {
+		t.Errorf("Cmd() error = %v, want %v", err, context.Canceled)
+	}
+	if err = client.Copy("192.168.0.2", "/tmp/a", "/tmp/b"); err != context.Canceled {
+		t.Errorf("Copy() error = %v, want %v", err, context.Canceled)
+	}
+
+	unbind()
+	client, err = GetHostSSHClient("192.168.0.2", cluster)
+	if err != nil {
+		t.Fatalf("failed to get ssh client: %v", err)
+	}
+	if _, ok := client.(*SSH); !ok {
+		t.Errorf("GetHostSSHClient() = %T after unbinding, want *SSH", client)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// DefaultEdgeControlAddr is the address on master0 the edge broker accepts the connections to edge hosts on,
+	// it is reached through the ssh connection to master0.
+	DefaultEdgeControlAddr = "127.0.0.1:7001"
+	// EdgeChannelType is the type of the channels the edge broker opens to the agents, one for each connection.
+	EdgeChannelType = "sealer-edge"
+	// EdgeConnect asks the broker for a connection to the sshd of the edge host, which replies with EdgeOK or
+	// an error line, then the connection carries the ssh protocol of the edge host.
+	EdgeConnect = "CONNECT %s\n"
+	EdgeOK      = "OK"
+)
+
+// Gateway reaches the hosts behind NAT through the edge broker on Host, which the edge agents on them connect to.
+type Gateway struct {
+	// SSH is the client of Host
+	SSH  *SSH
+	Host string
+	// Control is the control address of the broker on Host, DefaultEdgeControlAddr if empty
+	Control string
+}
+
+// gatewayConn releases the session slot of the connection to the gateway once it is closed.
+type gatewayConn struct {
+	net.Conn
+	release *Conn
+}
+
+func (c *gatewayConn) Close() error {
+	err := c.Conn.Close()
+	if c.release != nil {
+		_ = c.release.Close()
+	}
+	return err
+}
+
+// dial returns the connection to the sshd of the edge host, which the ssh handshake is done on.
+func (g *Gateway) dial(host string, timeout time.Duration) (net.Conn, error) {
+	control := g.Control
+	if control == "" {
+		control = DefaultEdgeControlAddr
+	}
+	var conn net.Conn
+	if g.SSH.isLocal(g.Host) {
+		c, err := net.DialTimeout("tcp", control, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect the edge broker %s: %v", control, err)
+		}
+		conn = c
+	} else {
+		client, err := g.SSH.connect(g.Host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect the edge gateway %s: %v", g.Host, err)
+		}
+		c, err := client.Dial("tcp", control)
+		if err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("failed to connect the edge broker %s on %s: %v", control, g.Host, err)
+		}
+		conn = &gatewayConn{Conn: c, release: client}
+	}
+
+	ip, _ := utils.GetSSHHostIPAndPort(host)
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if err := edgeHandshake(conn, ip); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to connect edge host %s through %s: %v", ip, g.Host, err)
+	}
+	return conn, nil
+}
+
+// edgeHandshake asks the broker for the edge host, the reply is read byte by byte, so that the ssh banner sent
+// after it is not consumed.
+func edgeHandshake(conn net.Conn, host string) error {
+	if _, err := fmt.Fprintf(conn, EdgeConnect, host); err != nil {
+		return err
+	}
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := conn.Read(b); err != nil {
+			return err
+		}
+		if b[0] == '\n' {
+			break
+		}
+		line = append(line, b[0])
+	}
+	if reply := strings.TrimSpace(string(line)); reply != EdgeOK {
+		return fmt.Errorf("%s", reply)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+// startBroker answers the requests for edgeHost by connecting to sshd, and refuses the others.
+func startBroker(t *testing.T, edgeHost, sshd string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(io.LimitReader(conn, 256)).ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line != fmt.Sprintf(EdgeConnect, edgeHost) {
+					_, _ = fmt.Fprintln(conn, "the edge agent is not connected")
+					return
+				}
+				remote, err := net.Dial("tcp", sshd)
+				if err != nil {
+					return
+				}
+				defer remote.Close()
+				_, _ = fmt.Fprintln(conn, EdgeOK)
+				pipeConns(conn, remote)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestGateway(t *testing.T) {
+	defer ClosePool()
+	sshd, _ := startServer(t)
+	// the gateway is the current machine, which dials the broker directly.
+	DisableLocalExec = false
+	control := startBroker(t, "10.0.0.5", sshd)
+	s := &SSH{User: "root", Password: "passwd", Gateway: &Gateway{SSH: &SSH{}, Host: "127.0.0.1", Control: control}}
+
+	if out, err := s.Cmd("10.0.0.5", "hostname"); err != nil || string(out) != "ok\n" {
+		t.Errorf("Cmd() through gateway = %q, %v", out, err)
+	}
+	if err := s.Ping("10.0.0.6"); err == nil {
+		t.Errorf("Ping() to the host without agent error = nil")
+	}
+}
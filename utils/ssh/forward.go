@@ -0,0 +1,49 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+func (s *SSH) Forward(host string, listener net.Listener, remoteAddr string) error {
+	if s.isLocal(host) {
+		return localForward(listener, remoteAddr)
+	}
+	client, err := s.connect(host)
+	if err != nil {
+		return fmt.Errorf("[ssh %s]create ssh connection failed, %v", host, err)
+	}
+	defer client.Close()
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func(local net.Conn) {
+			defer local.Close()
+			remote, err := client.Dial("tcp", remoteAddr)
+			if err != nil {
+				logger.Warn("[ssh %s]failed to dial %s: %v", host, remoteAddr, err)
+				return
+			}
+			defer remote.Close()
+			pipeConns(local, remote)
+		}(local)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Jump reaches the hosts in a private network through the ssh connection to Host, like "ssh -J", it is used for
+// the hosts created by the cloud provider, which are reached through the EIP of master0.
+type Jump struct {
+	// SSH is the client of Host
+	SSH  *SSH
+	Host string
+}
+
+// dial returns the connection to addr dialed from the jump host, which the ssh handshake is done on.
+func (j *Jump) dial(addr string, timeout time.Duration) (net.Conn, error) {
+	client, err := j.SSH.connect(j.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect the jump host %s: %v", j.Host, err)
+	}
+	c, err := client.Dial("tcp", addr)
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect %s through the jump host %s: %v", addr, j.Host, err)
+	}
+	conn := &gatewayConn{Conn: c, release: client}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	return conn, nil
+}
@@ -0,0 +1,44 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestJump(t *testing.T) {
+	defer ClosePool()
+	jumpHost, jumpAccepted := startServer(t)
+	host, accepted := startServer(t)
+	s := &SSH{User: "root", Password: "passwd", Jump: &Jump{SSH: &SSH{User: "root", Password: "passwd"}, Host: jumpHost}}
+
+	if out, err := s.Cmd(host, "hostname"); err != nil || string(out) != "ok\n" {
+		t.Fatalf("Cmd() through jump host = %q, %v", out, err)
+	}
+	if err := s.Ping(host); err != nil {
+		t.Errorf("Ping() through jump host error = %v", err)
+	}
+	if n := atomic.LoadInt32(accepted); n != 2 {
+		t.Errorf("host accepted %d connections, want 2", n)
+	}
+	// the connections to the host share the pooled one to the jump host.
+	if n := atomic.LoadInt32(jumpAccepted); n != 1 {
+		t.Errorf("jump host accepted %d connections, want 1", n)
+	}
+	if err := s.Ping("127.0.0.1:1"); err == nil {
+		t.Errorf("Ping() to the unreachable host through jump host error = nil")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"syscall"
+
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+// DisableLocalExec makes the commands and file copies to the current machine go through ssh as well, instead of
+// running them natively.
+var DisableLocalExec bool
+
+// localUser returns the euid and the name of the user sealer runs as.
+var localUser = func() (int, string) {
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return os.Geteuid(), name
+}
+
+// isLocal tells whether host is the current machine, the commands and file copies to it run natively without sshd.
+func (s *SSH) isLocal(host string) bool {
+	// the hosts behind a jump host are never the current machine, even if their private IPs are the same.
+	if DisableLocalExec || s.Jump != nil || !s.runsAsLocalUser() {
+		return false
+	}
+	// a non-default port may be forwarded to another machine, like 127.0.0.1:2222 to a VM.
+	host, port := utils.GetSSHHostIPAndPort(host)
+	if port != "22" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	return s.LocalAddress != nil && utils.IsLocalIP(host, s.LocalAddress)
+}
+
+// runsAsLocalUser tells whether the current user has the privilege of the ssh user, so that the commands and
+// file copies run natively the same as by ssh. Root has it, another user has it only if it is the ssh user without sudo,
+// otherwise they go through ssh, instead of running with less privilege, or copying the files without sudo.
+func (s *SSH) runsAsLocalUser() bool {
+	euid, name := localUser()
+	if euid == 0 {
+		return true
+	}
+	return name != "" && name == s.User && !s.isSudo()
+}
+
+// localCommand runs cmd by bash as the current user, which has the privilege of the ssh user.
+func (s *SSH) localCommand(ctx context.Context, cmd string) *exec.Cmd {
+	return exec.CommandContext(ctx, "/bin/bash", "-c", cmd) // #nosec
+}
+
+func (s *SSH) localCmd(host, cmd string) ([]byte, error) {
+	b, err := s.localCommand(context.Background(), cmd).CombinedOutput()
+	if err != nil {
+		return b, fmt.Errorf("[ssh][%s]run command failed [%s]", host, cmd)
+	}
+	return b, nil
+}
+
+// localCmdAsync sends SIGTERM to cmd once ctx is done, its output is streamed and logged the same as a remote one.
+func (s *SSH) localCmdAsync(ctx context.Context, host, cmd string) error {
+	c := s.localCommand(context.Background(), cmd)
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe for %s: %v", host, err)
+	}
+	stderr, err := c.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe for %s: %v", host, err)
+	}
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("failed to start command %s on %s: %v", cmd, host, err)
+	}
+	stop := closeOnDone(ctx, func() {
+		_ = c.Process.Signal(syscall.SIGTERM)
+	})
+	defer stop()
+
+	out := &output{host: host}
+	logFile, logPath := openOutput(ctx, host, cmd)
+	if logFile != nil {
+		defer logFile.Close()
+		out.log = logFile
+	}
+	doneout := make(chan error, 1)
+	doneerr := make(chan error, 1)
+	go func() {
+		doneerr <- readPipe(stderr, out)
+	}()
+	go func() {
+		doneout <- readPipe(stdout, out)
+	}()
+	<-doneerr
+	<-doneout
+
+	err = c.Wait()
+	if ctx.Err() != nil {
+		return fmt.Errorf("command %s on %s is canceled: %w", cmd, host, ctx.Err())
+	}
+	if err != nil {
+		return out.wrapError(cmd, logPath, err)
+	}
+	return nil
+}
+
+// localForward forwards the connections accepted by listener to remoteAddr dialed from the current machine.
+func localForward(listener net.Listener, remoteAddr string) error {
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func(local net.Conn) {
+			defer local.Close()
+			remote, err := net.Dial("tcp", remoteAddr)
+			if err != nil {
+				logger.Warn("failed to dial %s: %v", remoteAddr, err)
+				return
+			}
+			defer remote.Close()
+			pipeConns(local, remote)
+		}(local)
+	}
+}
+
+// pipeConns copies between a and b until either side is closed.
+func pipeConns(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,122 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSSH_isLocal(t *testing.T) {
+	addrs := []net.Addr{&net.IPNet{IP: net.ParseIP("192.168.0.2").To4(), Mask: net.CIDRMask(24, 32)}}
+	s := &SSH{User: "root", LocalAddress: &addrs}
+	setLocalUser(t, 0, "root")
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"192.168.0.2", true},
+		{"192.168.0.2:22", true},
+		// a forwarded port may go to another machine.
+		{"192.168.0.2:2222", false},
+		{"127.0.0.1:2222", false},
+		{"127.0.0.1", true},
+		{"localhost", true},
+		{"192.168.0.3", false},
+	}
+	for _, tt := range tests {
+		if got := s.isLocal(tt.host); got != tt.want {
+			t.Errorf("isLocal(%s) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+	if (&SSH{User: "root"}).isLocal("192.168.0.2") {
+		t.Errorf("isLocal() without local address = true, want false")
+	}
+
+	DisableLocalExec = true
+	defer func() {
+		DisableLocalExec = false
+	}()
+	if s.isLocal("192.168.0.2") {
+		t.Errorf("isLocal() with DisableLocalExec = true, want false")
+	}
+}
+
+func setLocalUser(t *testing.T, euid int, name string) {
+	origin := localUser
+	localUser = func() (int, string) {
+		return euid, name
+	}
+	t.Cleanup(func() {
+		localUser = origin
+	})
+}
+
+func TestSSH_runsAsLocalUser(t *testing.T) {
+	tests := []struct {
+		name string
+		euid int
+		user string
+		ssh  *SSH
+		want bool
+	}{
+		{"root", 0, "root", &SSH{User: "root"}, true},
+		{"root for the sudo user", 0, "root", &SSH{User: "admin", Sudo: true}, true},
+		// the commands would run without the privilege of root.
+		{"not root for root", 1000, "admin", &SSH{User: "root"}, false},
+		{"not root for the sudo user", 1000, "admin", &SSH{User: "admin", Sudo: true}, false},
+		{"not root for another user", 1000, "admin", &SSH{User: "deploy"}, false},
+		{"the same user", 1000, "admin", &SSH{User: "admin"}, true},
+		{"unknown user", 1000, "", &SSH{User: ""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLocalUser(t, tt.euid, tt.user)
+			if got := tt.ssh.runsAsLocalUser(); got != tt.want {
+				t.Errorf("runsAsLocalUser() = %v, want %v", got, tt.want)
+			}
+			if got := tt.ssh.isLocal("127.0.0.1"); got != tt.want {
+				t.Errorf("isLocal(127.0.0.1) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSH_LocalCmd(t *testing.T) {
+	_, name := localUser()
+	s := &SSH{User: name}
+	out, err := s.Cmd("127.0.0.1", "echo hello")
+	if err != nil || string(out) != "hello\n" {
+		t.Errorf("Cmd() = %q, %v, want hello", out, err)
+	}
+	if err = s.CmdAsync("127.0.0.1", "echo a", "echo b"); err != nil {
+		t.Errorf("CmdAsync() error = %v", err)
+	}
+	err = s.CmdAsync("127.0.0.1", "echo failed && exit 3")
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("CmdAsync() error = %v, want the output of the failed command", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = s.CmdAsyncContext(ctx, "127.0.0.1", "sleep 10"); err == nil {
+		t.Errorf("CmdAsyncContext() with canceled ctx error = nil")
+	}
+	if err = s.Ping("127.0.0.1"); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+}
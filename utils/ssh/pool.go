@@ -0,0 +1,234 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+// DefaultMaxSessions is the number of sessions multiplexed on a connection by default, the same as the MaxSessions
+// of sshd by default.
+const DefaultMaxSessions = 10
+
+var errKeepaliveTimeout = errors.New("keepalive timed out")
+
+var (
+	// KeepaliveInterval is the interval of the keepalive requests on the pooled connections, the connection failing
+	// one is closed, and the next command to the host dials a new one.
+	KeepaliveInterval = 30 * time.Second
+	// IdleTimeout closes the pooled connection which has no session for it.
+	IdleTimeout = 5 * time.Minute
+)
+
+// poolKey tells apart the connections of the same host dialed with different users or credentials.
+type poolKey struct {
+	user, addr, password, pkFile, pkPassword string
+}
+
+type pooledConn struct {
+	key      poolKey
+	client   *ssh.Client
+	sessions int
+	lastUsed time.Time
+	// closed is set once the connection is removed from the pool, it is closed with its last session.
+	closed bool
+}
+
+type pool struct {
+	sync.Mutex
+	conns map[poolKey][]*pooledConn
+	// dialing makes the commands to a host wait for the connection being dialed, instead of dialing their own.
+	dialing map[poolKey]*sync.Mutex
+}
+
+var connPool = &pool{
+	conns:   map[poolKey][]*pooledConn{},
+	dialing: map[poolKey]*sync.Mutex{},
+}
+
+// Conn is a session slot of a pooled connection, Close returns the slot to the pool instead of closing the connection.
+type Conn struct {
+	*ssh.Client
+	pc   *pooledConn
+	once sync.Once
+}
+
+func (c *Conn) Close() error {
+	c.once.Do(func() {
+		connPool.release(c.pc, false)
+	})
+	return nil
+}
+
+// discard returns the slot and removes the connection from the pool, like after it fails to open a session.
+func (c *Conn) discard() {
+	c.once.Do(func() {
+		connPool.release(c.pc, true)
+	})
+}
+
+// ClosePool closes all the pooled connections, the sessions running on them are aborted.
+func ClosePool() {
+	var clients []*ssh.Client
+	connPool.Lock()
+	for key, conns := range connPool.conns {
+		for _, pc := range conns {
+			pc.closed = true
+			clients = append(clients, pc.client)
+		}
+		delete(connPool.conns, key)
+	}
+	connPool.Unlock()
+	// the connections are closed without the lock, as closing the one through a jump host or gateway returns its
+	// slot of the pooled connection to them.
+	for _, c := range clients {
+		_ = c.Close()
+	}
+}
+
+// connect returns a session slot of a pooled connection to host with less than MaxSessions sessions, a new connection
+// is dialed if there is none.
+func (s *SSH) connect(host string) (*Conn, error) {
+	key := s.poolKey(host)
+	max := s.MaxSessions
+	if max <= 0 {
+		max = DefaultMaxSessions
+	}
+	if c := connPool.acquire(key, max); c != nil {
+		return c, nil
+	}
+
+	connPool.Lock()
+	dialing, ok := connPool.dialing[key]
+	if !ok {
+		dialing = &sync.Mutex{}
+		connPool.dialing[key] = dialing
+	}
+	connPool.Unlock()
+	dialing.Lock()
+	defer dialing.Unlock()
+	// the connection dialed by the one holding the lock before may have a slot.
+	if c := connPool.acquire(key, max); c != nil {
+		return c, nil
+	}
+
+	client, err := s.dial(host)
+	if err != nil {
+		return nil, err
+	}
+	pc := &pooledConn{key: key, client: client, sessions: 1, lastUsed: time.Now()}
+	connPool.Lock()
+	connPool.conns[key] = append(connPool.conns[key], pc)
+	connPool.Unlock()
+	go connPool.keepalive(pc)
+	return &Conn{Client: client, pc: pc}, nil
+}
+
+func (s *SSH) poolKey(host string) poolKey {
+	return poolKey{user: s.User, addr: s.addr(host), password: s.Password, pkFile: s.PkFile, pkPassword: s.PkPassword}
+}
+
+func (p *pool) acquire(key poolKey, max int) *Conn {
+	p.Lock()
+	defer p.Unlock()
+	for _, pc := range p.conns[key] {
+		if pc.sessions < max {
+			pc.sessions++
+			return &Conn{Client: pc.client, pc: pc}
+		}
+	}
+	return nil
+}
+
+func (p *pool) release(pc *pooledConn, discard bool) {
+	p.Lock()
+	pc.sessions--
+	pc.lastUsed = time.Now()
+	if discard {
+		p.remove(pc)
+	}
+	closed := pc.closed && pc.sessions == 0
+	p.Unlock()
+	if closed {
+		_ = pc.client.Close()
+	}
+}
+
+// remove removes pc from the pool, the caller must hold the lock.
+func (p *pool) remove(pc *pooledConn) {
+	if pc.closed {
+		return
+	}
+	pc.closed = true
+	conns := p.conns[pc.key]
+	for i := range conns {
+		if conns[i] == pc {
+			p.conns[pc.key] = append(conns[:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(p.conns[pc.key]) == 0 {
+		delete(p.conns, pc.key)
+	}
+}
+
+// keepalive sends the keepalive requests on pc until it is closed, it closes pc once it is idle for IdleTimeout,
+// or a request fails or gets no reply within KeepaliveInterval.
+func (p *pool) keepalive(pc *pooledConn) {
+	ticker := time.NewTicker(KeepaliveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.Lock()
+		closed := pc.closed
+		idle := !closed && pc.sessions == 0 && time.Since(pc.lastUsed) > IdleTimeout
+		if idle {
+			p.remove(pc)
+		}
+		p.Unlock()
+		if idle {
+			_ = pc.client.Close()
+		}
+		if closed || idle {
+			return
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			_, _, err := pc.client.SendRequest("keepalive@openssh.com", true, nil)
+			done <- err
+		}()
+		var err error
+		select {
+		case err = <-done:
+		case <-time.After(KeepaliveInterval):
+			err = errKeepaliveTimeout
+		}
+		if err != nil {
+			logger.Debug("[ssh %s]close the connection failing keepalive: %v", pc.key.addr, err)
+			p.Lock()
+			p.remove(pc)
+			p.Unlock()
+			// the sessions on it are broken as well, closing it makes them fail instead of hanging.
+			_ = pc.client.Close()
+			return
+		}
+	}
+}
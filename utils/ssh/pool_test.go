@@ -0,0 +1,190 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startServer starts a sshd on localhost which runs any command successfully, and counts the connections accepted.
+func startServer(t *testing.T) (string, *int32) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server is on the current machine, which is not connected by ssh otherwise.
+	DisableLocalExec = true
+	t.Cleanup(func() {
+		_ = listener.Close()
+		DisableLocalExec = false
+	})
+
+	var accepted int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			go serve(conn, config)
+		}
+	}()
+	return listener.Addr().String(), &accepted
+}
+
+func serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			go forwardChannel(newChannel)
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				switch req.Type {
+				case "pty-req":
+					_ = req.Reply(true, nil)
+				case "exec":
+					_ = req.Reply(true, nil)
+					_, _ = channel.Write([]byte("ok\n"))
+					_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+					_ = channel.Close()
+				default:
+					_ = req.Reply(false, nil)
+				}
+			}
+		}()
+	}
+}
+
+// forwardChannel connects the channel of "ssh -J" to the address it asks for.
+func forwardChannel(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		_, _ = io.Copy(channel, conn)
+		_ = channel.CloseWrite()
+	}()
+	_, _ = io.Copy(conn, channel)
+	_ = conn.Close()
+}
+
+func TestPool_Reuse(t *testing.T) {
+	defer ClosePool()
+	host, accepted := startServer(t)
+	s := &SSH{User: "root", Password: "passwd"}
+
+	for i := 0; i < 5; i++ {
+		if err := s.CmdAsync(host, "hostname"); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := s.Cmd(host, "hostname"); err != nil || string(out) != "ok\n" {
+			t.Fatalf("Cmd() = %q, %v", out, err)
+		}
+	}
+	if n := atomic.LoadInt32(accepted); n != 1 {
+		t.Errorf("dialed %d connections for the commands in sequence, want 1", n)
+	}
+}
+
+func TestPool_MaxSessions(t *testing.T) {
+	defer ClosePool()
+	host, accepted := startServer(t)
+	s := &SSH{User: "root", Password: "passwd", MaxSessions: 2}
+
+	var conns []*Conn
+	for i := 0; i < 3; i++ {
+		c, err := s.connect(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	if n := atomic.LoadInt32(accepted); n != 2 {
+		t.Errorf("dialed %d connections for 3 sessions, want 2", n)
+	}
+
+	// the slot returned is taken by the next one, instead of dialing.
+	_ = conns[0].Close()
+	c, err := s.connect(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(accepted); n != 2 {
+		t.Errorf("dialed %d connections after a slot is returned, want 2", n)
+	}
+
+	// the discarded connection is closed with its last session, and not used anymore.
+	c.discard()
+	_ = conns[1].Close()
+	if got := len(connPool.conns[s.poolKey(host)]); got != 1 {
+		t.Errorf("got %d pooled connections after one is discarded, want 1", got)
+	}
+}
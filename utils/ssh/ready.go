@@ -0,0 +1,189 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/sealer/logger"
+)
+
+// DefaultReadyTimeout is the deadline of WaitHostsReady for all hosts, long enough for a cloud instance to boot.
+const DefaultReadyTimeout = 10 * time.Minute
+
+// RemoteCloudInitStatus prints the status of cloud-init like "status: running", nothing if it is not installed.
+// cloud-init older than 18.1 has no status subcommand, it writes boot-finished once it is done.
+const RemoteCloudInitStatus = `if command -v cloud-init >/dev/null 2>&1; then cloud-init status 2>/dev/null || ` +
+	`{ [ -f /var/lib/cloud/instance/boot-finished ] && echo "status: done" || echo "status: running"; }; fi`
+
+// ReadyTimeout overrides DefaultReadyTimeout of WaitHostsReady, if not zero.
+var ReadyTimeout time.Duration
+
+var (
+	readyInterval         = 5 * time.Second
+	readyProgressInterval = 30 * time.Second
+)
+
+// ReadyOptions are the probes of WaitHostsReady besides ssh.
+type ReadyOptions struct {
+	// Timeout is the deadline for all hosts, ReadyTimeout or DefaultReadyTimeout if zero.
+	Timeout time.Duration
+	// InstanceStatus returns the status of the cloud instance of host and if it is running, the host is not
+	// connected by ssh until it is running.
+	InstanceStatus func(host string) (status string, running bool, err error)
+	// WaitCloudInit waits for cloud-init done on the hosts, which sets the password and keys of ssh and the disks.
+	WaitCloudInit bool
+}
+
+// WaitHostsReady waits for the hosts running, reachable by ssh and cloud-init done until the timeout of opts,
+// unlike WaitSSHReady it keeps retrying the hosts still booting and logs the hosts waited for periodically.
+func WaitHostsReady(s Interface, opts ReadyOptions, hosts ...string) error {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = ReadyTimeout
+	}
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+	start := time.Now()
+	deadline := start.Add(timeout)
+	progress := &readyProgress{states: map[string]string{}}
+	for _, h := range hosts {
+		progress.states[h] = "waiting"
+	}
+
+	done := make(chan struct{})
+	go progress.report(done, start)
+	var wg sync.WaitGroup
+	for _, h := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			for {
+				state, ready := probeHost(s, opts, host)
+				if ready {
+					progress.ready(host)
+					logger.Info("host %s is ready after %s", host, time.Since(start).Round(time.Second))
+					return
+				}
+				progress.set(host, state)
+				remaining := time.Until(deadline)
+				if remaining <= 0 {
+					return
+				}
+				if remaining > readyInterval {
+					remaining = readyInterval
+				}
+				time.Sleep(remaining)
+			}
+		}(h)
+	}
+	wg.Wait()
+	close(done)
+
+	if pending := progress.pending(); pending != "" {
+		return fmt.Errorf("hosts are not ready in %s, ensure that the IP address or password is correct: %s", timeout, pending)
+	}
+	return nil
+}
+
+// probeHost returns the state of host and if it is ready.
+func probeHost(s Interface, opts ReadyOptions, host string) (string, bool) {
+	if opts.InstanceStatus != nil {
+		status, running, err := opts.InstanceStatus(host)
+		if err != nil {
+			return fmt.Sprintf("failed to get the instance status: %v", err), false
+		}
+		if !running {
+			return "instance " + status, false
+		}
+	}
+	if err := s.Ping(host); err != nil {
+		return fmt.Sprintf("ssh is not ready: %v", err), false
+	}
+	if !opts.WaitCloudInit {
+		return "", true
+	}
+	out, err := s.CmdToString(host, RemoteCloudInitStatus, "\n")
+	if err != nil {
+		return fmt.Sprintf("failed to get the cloud-init status: %v", err), false
+	}
+	switch status := cloudInitStatus(out); status {
+	case "running", "not run":
+		return "cloud-init " + status, false
+	case "error", "degraded":
+		logger.Warn("cloud-init of host %s finished with %s, run cloud-init status --long on it for details", host, status)
+	}
+	return "", true
+}
+
+// cloudInitStatus returns the status in the output of RemoteCloudInitStatus, "" if cloud-init is not installed.
+func cloudInitStatus(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if s := strings.TrimSpace(line); strings.HasPrefix(s, "status:") {
+			return strings.TrimSpace(strings.TrimPrefix(s, "status:"))
+		}
+	}
+	return ""
+}
+
+// readyProgress is the state of the hosts not ready yet, which is logged periodically.
+type readyProgress struct {
+	sync.Mutex
+	states map[string]string
+}
+
+func (p *readyProgress) set(host, state string) {
+	p.Lock()
+	defer p.Unlock()
+	p.states[host] = state
+}
+
+func (p *readyProgress) ready(host string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.states, host)
+}
+
+// pending returns the hosts not ready yet with their states, like: 172.16.0.2 (instance Starting).
+func (p *readyProgress) pending() string {
+	p.Lock()
+	defer p.Unlock()
+	var hosts []string
+	for h, state := range p.states {
+		hosts = append(hosts, fmt.Sprintf("%s (%s)", h, state))
+	}
+	sort.Strings(hosts)
+	return strings.Join(hosts, ", ")
+}
+
+func (p *readyProgress) report(done <-chan struct{}, start time.Time) {
+	ticker := time.NewTicker(readyProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if pending := p.pending(); pending != "" {
+				logger.Info("waiting for hosts ready, %s elapsed: %s", time.Since(start).Round(time.Second), pending)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bootingHost is a host which becomes running, reachable by ssh and cloud-init done after some probes.
+type bootingHost struct {
+	Interface
+	sync.Mutex
+	probes int
+}
+
+func (b *bootingHost) probe() int {
+	b.Lock()
+	defer b.Unlock()
+	b.probes++
+	return b.probes
+}
+
+func (b *bootingHost) status(host string) (string, bool, error) {
+	if b.probe() < 2 {
+		return "Starting", false, nil
+	}
+	return "Running", true, nil
+}
+
+func (b *bootingHost) Ping(host string) error {
+	if b.probe() < 4 {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+func (b *bootingHost) CmdToString(host, cmd, spilt string) (string, error) {
+	if b.probe() < 7 {
+		return "status: running", nil
+	}
+	return "status: done", nil
+}
+
+func TestWaitHostsReady(t *testing.T) {
+	readyInterval = time.Millisecond
+	defer func() {
+		readyInterval = 5 * time.Second
+	}()
+
+	b := &bootingHost{}
+	opts := ReadyOptions{Timeout: time.Minute, InstanceStatus: b.status, WaitCloudInit: true}
+	if err := WaitHostsReady(b, opts, "172.16.0.2"); err != nil {
+		t.Fatalf("WaitHostsReady() error = %v", err)
+	}
+
+	b = &bootingHost{}
+	opts.Timeout = 10 * time.Millisecond
+	opts.InstanceStatus = func(host string) (string, bool, error) {
+		return "Pending", false, nil
+	}
+	err := WaitHostsReady(b, opts, "172.16.0.2")
+	if err == nil || !strings.Contains(err.Error(), "172.16.0.2 (instance Pending)") {
+		t.Errorf("WaitHostsReady() error = %v, want 172.16.0.2 (instance Pending)", err)
+	}
+}
+
+func TestCloudInitStatus(t *testing.T) {
+	tests := []struct {
+		out  string
+		want string
+	}{
+		{"", ""},
+		{"status: running", "running"},
+		{"\nstatus: done\n", "done"},
+		{"status: error\nstatus: done", "error"},
+	}
+	for _, tt := range tests {
+		if got := cloudInitStatus(tt.out); got != tt.want {
+			t.Errorf("cloudInitStatus(%q) = %q, want %q", tt.out, got, tt.want)
+		}
+	}
+}
@@ -15,13 +15,15 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/alibaba/sealer/common"
 	"github.com/alibaba/sealer/logger"
@@ -32,7 +34,6 @@ import (
 	"github.com/docker/docker/pkg/progress"
 	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
 )
 
 const KByte = 1024
@@ -47,6 +48,8 @@ type easyProgressUtil struct {
 	copyID         string
 	completeNumber int
 	total          int
+	// bytes of the files sent, the ones already on the remote host are not counted
+	bytes int64
 }
 
 func (epu *easyProgressUtil) increment() {
@@ -87,20 +90,39 @@ func (s *SSH) CmdToString(host, cmd, spilt string) (string, error) {
 }
 
 //SftpConnect  is
-func (s *SSH) sftpConnect(host string) (*ssh.Client, *sftp.Client, error) {
+func (s *SSH) sftpConnect(host string) (*Conn, *sftp.Client, error) {
 	sshClient, err := s.connect(host)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// create sftp client
-	sftpClient, err := sftp.NewClient(sshClient)
-	return sshClient, sftpClient, err
+	sftpClient, err := sftp.NewClient(sshClient.Client)
+	if err != nil {
+		sshClient.discard()
+		return nil, nil, err
+	}
+	return sshClient, sftpClient, nil
 }
 
 // CopyRemoteFileToLocal is scp remote file to local
 func (s *SSH) Fetch(host, localFilePath, remoteFilePath string) error {
-	if utils.IsLocalIP(host, s.LocalAddress) {
+	return s.FetchContext(context.Background(), host, localFilePath, remoteFilePath)
+}
+
+// FetchContext closes the sftp connection once ctx is done, it leaves the partial local file.
+func (s *SSH) FetchContext(ctx context.Context, host, localFilePath, remoteFilePath string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.isRemoteDir(host, remoteFilePath) {
+		return s.FetchDirContext(ctx, host, localFilePath, remoteFilePath, CopyOptions{})
+	}
+	end := traceTransfer(ctx, host, DirectionDownload, localFilePath, remoteFilePath)
+	defer func() {
+		end(err)
+	}()
+	if s.isLocal(host) {
 		if remoteFilePath != localFilePath {
 			logger.Debug("local copy files src %s to dst %s", remoteFilePath, localFilePath)
 			return utils.RecursionCopy(remoteFilePath, localFilePath)
@@ -115,6 +137,18 @@ func (s *SSH) Fetch(host, localFilePath, remoteFilePath string) error {
 		_ = sftpClient.Close()
 		_ = sshClient.Close()
 	}()
+	stop := closeOnDone(ctx, nil, sftpClient, sshClient)
+	defer stop()
+	if s.isSudo() {
+		dir, err := s.makeStagingDir(ctx, host)
+		if err != nil {
+			return err
+		}
+		defer s.removeStagingDir(host, dir)
+		if remoteFilePath, err = s.stageRemoteFile(ctx, host, dir, remoteFilePath); err != nil {
+			return err
+		}
+	}
 	// open remote source file
 	srcFile, err := sftpClient.Open(remoteFilePath)
 	if err != nil {
@@ -133,13 +167,57 @@ func (s *SSH) Fetch(host, localFilePath, remoteFilePath string) error {
 	}
 	defer dstFile.Close()
 	// copy to local file
-	_, err = srcFile.WriteTo(dstFile)
-	return err
+	start := time.Now()
+	n, err := srcFile.WriteTo(dstFile)
+	observeTransfer(ctx, host, DirectionDownload, n, start)
+	if ctx.Err() != nil {
+		return fmt.Errorf("fetching %s from %s is canceled: %w", remoteFilePath, host, ctx.Err())
+	}
+	if err != nil {
+		return err
+	}
+	if stat, err := srcFile.Stat(); err == nil {
+		return dstFile.Chmod(stat.Mode().Perm())
+	}
+	return nil
+}
+
+// isRemoteDir tells whether remotePath of host is a dir, false if it can not be checked.
+func (s *SSH) isRemoteDir(host, remotePath string) bool {
+	if s.isLocal(host) {
+		f, err := os.Stat(remotePath)
+		return err == nil && f.IsDir()
+	}
+	ok, err := s.RemoteDirExist(host, remotePath)
+	return err == nil && ok
 }
 
 // CopyLocalToRemote is copy file or dir to remotePath, add md5 validate
 func (s *SSH) Copy(host, localPath, remotePath string) error {
-	if utils.IsLocalIP(host, s.LocalAddress) {
+	return s.CopyContext(context.Background(), host, localPath, remotePath)
+}
+
+// CopyContext closes the sftp connection once ctx is done, a dir is streamed by CopyDirContext.
+func (s *SSH) CopyContext(ctx context.Context, host, localPath, remotePath string) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := os.Lstat(localPath)
+	if err != nil {
+		return fmt.Errorf("get file stat failed %s", err)
+	}
+	if f.IsDir() {
+		return s.CopyDirContext(ctx, host, localPath, remotePath, CopyOptions{})
+	}
+	end := traceTransfer(ctx, host, DirectionUpload, localPath, remotePath)
+	defer func() {
+		end(err)
+	}()
+	if s.isLocal(host) {
+		// copying a file to itself truncates it.
+		if filepath.Clean(localPath) == filepath.Clean(remotePath) {
+			return nil
+		}
 		logger.Debug("local copy files src %s to dst %s", localPath, remotePath)
 		return utils.RecursionCopy(localPath, remotePath)
 	}
@@ -152,26 +230,18 @@ func (s *SSH) Copy(host, localPath, remotePath string) error {
 		_ = sftpClient.Close()
 		_ = sshClient.Close()
 	}()
-
-	f, err := os.Stat(localPath)
-	if err != nil {
-		return fmt.Errorf("get file stat failed %s", err)
-	}
+	stop := closeOnDone(ctx, nil, sftpClient, sshClient)
+	defer stop()
 
 	baseRemoteFilePath := filepath.Dir(remotePath)
 	_, err = sftpClient.ReadDir(baseRemoteFilePath)
 	if err != nil {
-		if err = sftpClient.MkdirAll(baseRemoteFilePath); err != nil {
+		if err = s.mkdirAll(host, sftpClient, baseRemoteFilePath); err != nil {
 			return err
 		}
 	}
-	number := 1
-	if f.IsDir() {
-		number = utils.CountDirFiles(localPath)
-	}
-	// no file in dir, do need to send
-	if number == 0 {
-		return nil
+	if f.Mode()&os.ModeSymlink != 0 {
+		return s.copySymlink(host, sftpClient, localPath, remotePath)
 	}
 	var (
 		reader, writer  = io.Pipe()
@@ -181,7 +251,7 @@ func (s *SSH) Copy(host, localPath, remotePath string) error {
 		epu             = &easyProgressUtil{
 			output:         progressChanOut,
 			completeNumber: 0,
-			total:          number,
+			total:          1,
 			copyID:         "copying files to " + host,
 		}
 	)
@@ -198,53 +268,43 @@ func (s *SSH) Copy(host, localPath, remotePath string) error {
 	}()
 
 	epu.startMessage()
-	if f.IsDir() {
-		s.copyLocalDirToRemote(host, sftpClient, localPath, remotePath, epu)
-	} else {
-		err = s.copyLocalFileToRemote(host, sftpClient, localPath, remotePath)
-		if err != nil {
-			epu.fail(err)
-		}
-		epu.increment()
+	start := time.Now()
+	n, err := s.copyLocalFileToRemote(host, sftpClient, localPath, remotePath)
+	if err != nil {
+		epu.fail(err)
 	}
-	return nil
+	epu.bytes += n
+	epu.increment()
+	observeTransfer(ctx, host, DirectionUpload, epu.bytes, start)
+	if ctx.Err() != nil {
+		return fmt.Errorf("copying %s to %s is canceled: %w", localPath, host, ctx.Err())
+	}
+	return err
 }
 
-func (s *SSH) copyLocalDirToRemote(host string, sftpClient *sftp.Client, localPath, remotePath string, epu *easyProgressUtil) {
-	localFiles, err := ioutil.ReadDir(localPath)
+// copySymlink creates the symlink of localPath at remotePath, pointing to the same target.
+func (s *SSH) copySymlink(host string, sftpClient *sftp.Client, localPath, remotePath string) error {
+	target, err := os.Readlink(localPath)
 	if err != nil {
-		logger.Error("read local path dir failed %s %s", host, localPath)
-		return
-	}
-	if err = sftpClient.MkdirAll(remotePath); err != nil {
-		logger.Error("failed to create remote path %s:%v", remotePath, err)
-		return
-	}
-	for _, file := range localFiles {
-		lfp := path.Join(localPath, file.Name())
-		rfp := path.Join(remotePath, file.Name())
-		if file.IsDir() {
-			if err = sftpClient.MkdirAll(rfp); err != nil {
-				logger.Error("failed to create remote path %s:%v", rfp, err)
-				return
-			}
-			s.copyLocalDirToRemote(host, sftpClient, lfp, rfp, epu)
-		} else {
-			err := s.copyLocalFileToRemote(host, sftpClient, lfp, rfp)
-			if err != nil {
-				errMsg := fmt.Sprintf("copy local file to remote failed %v %s %s %s", err, host, lfp, rfp)
-				epu.fail(err)
-				logger.Error(errMsg)
-				return
-			}
-			epu.increment()
+		return err
+	}
+	if s.isSudo() {
+		if _, err := s.Cmd(host, fmt.Sprintf("ln -sfn %s %s", utils.ShellQuote(target), utils.ShellQuote(remotePath))); err != nil {
+			return fmt.Errorf("failed to create symlink %s on %s: %v", remotePath, host, err)
 		}
+		return nil
+	}
+	// sftp does not replace the existing one.
+	_ = sftpClient.Remove(remotePath)
+	if err := sftpClient.Symlink(target, remotePath); err != nil {
+		return fmt.Errorf("failed to create symlink %s on %s: %v", remotePath, host, err)
 	}
+	return nil
 }
 
 // check the remote file existence before copying
 // solve the sesion
-func (s *SSH) copyLocalFileToRemote(host string, sftpClient *sftp.Client, localPath, remotePath string) error {
+func (s *SSH) copyLocalFileToRemote(host string, sftpClient *sftp.Client, localPath, remotePath string) (int64, error) {
 	var (
 		srcMd5, dstMd5 string
 	)
@@ -253,36 +313,58 @@ func (s *SSH) copyLocalFileToRemote(host string, sftpClient *sftp.Client, localP
 		dstMd5 = s.RemoteMd5Sum(host, remotePath)
 		if srcMd5 == dstMd5 {
 			logger.Debug("remote dst %s already exists and is the latest version , skip copying process", remotePath)
-			return nil
+			return 0, nil
 		}
 	}
 	srcFile, err := os.Open(filepath.Clean(localPath))
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer srcFile.Close()
-	dstFile, err := sftpClient.Create(remotePath)
+	fileStat, err := srcFile.Stat()
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("get file stat failed %v", err)
 	}
-	fileStat, err := srcFile.Stat()
+	// the user may not write to remotePath, upload it to the staging dir then install it by sudo.
+	uploadPath := remotePath
+	if s.isSudo() {
+		dir, err := s.makeStagingDir(context.Background(), host)
+		if err != nil {
+			return 0, err
+		}
+		defer s.removeStagingDir(host, dir)
+		uploadPath = path.Join(dir, path.Base(remotePath))
+	}
+	dstFile, err := sftpClient.Create(uploadPath)
 	if err != nil {
-		return fmt.Errorf("get file stat failed %v", err)
+		return 0, err
 	}
-	// TODO seems not work
-	if err := dstFile.Chmod(fileStat.Mode()); err != nil {
-		return fmt.Errorf("chmod remote file failed %v", err)
+	n, err := io.Copy(dstFile, srcFile)
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
 	}
-	defer dstFile.Close()
-	_, err = io.Copy(dstFile, srcFile)
 	if err != nil {
-		return err
+		return n, err
+	}
+	// the mode set on creating is masked by the umask of sftp server, set it after the file is written.
+	if err := sftpClient.Chmod(uploadPath, fileStat.Mode().Perm()); err != nil {
+		return n, fmt.Errorf("chmod remote file failed %v", err)
+	}
+	if st, ok := fileStat.Sys().(*syscall.Stat_t); ok && s.User == common.ROOT {
+		if err := sftpClient.Chown(uploadPath, int(st.Uid), int(st.Gid)); err != nil {
+			return n, fmt.Errorf("chown remote file failed %v", err)
+		}
+	}
+	if s.isSudo() {
+		if err := s.installStaged(host, uploadPath, remotePath, fileStat.Mode()); err != nil {
+			return n, err
+		}
 	}
 	dstMd5 = s.RemoteMd5Sum(host, remotePath)
 	if srcMd5 != dstMd5 {
-		return fmt.Errorf("[ssh][%s] validate md5sum failed %s != %s", host, srcMd5, dstMd5)
+		return n, fmt.Errorf("[ssh][%s] validate md5sum failed %s != %s", host, srcMd5, dstMd5)
 	}
-	return nil
+	return n, nil
 }
 
 func LocalMd5Sum(localPath string) string {
@@ -296,6 +378,23 @@ func LocalMd5Sum(localPath string) string {
 
 //if remote file not exist return false and nil
 func (s *SSH) RemoteDirExist(host, remoteDirpath string) (bool, error) {
+	if s.isLocal(host) {
+		f, err := os.Stat(remoteDirpath)
+		if err != nil {
+			return false, err
+		}
+		if !f.IsDir() {
+			return false, fmt.Errorf("%s is not a dir", remoteDirpath)
+		}
+		return true, nil
+	}
+	if s.isSudo() {
+		// the dir may not be readable by the user.
+		if _, err := s.Cmd(host, fmt.Sprintf("test -d %s", remoteDirpath)); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
 	sshClient, sftpClient, err := s.sftpConnect(host)
 	if err != nil {
 		return false, err
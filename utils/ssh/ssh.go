@@ -15,6 +15,7 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sync"
@@ -47,6 +48,17 @@ type Interface interface {
 	// exec command on remote host, and return spilt standard output and standard error
 	CmdToString(host, cmd, spilt string) (string, error)
 	Ping(host string) error
+	// forward the connections accepted by listener to remoteAddr dialed from host, until listener is closed
+	Forward(host string, listener net.Listener, remoteAddr string) error
+	// CmdAsync, Copy and Fetch with ctx, they stop the remote command or file transfer once ctx is done
+	CmdAsyncContext(ctx context.Context, host string, cmd ...string) error
+	CopyContext(ctx context.Context, host, srcFilePath, dstFilePath string) error
+	FetchContext(ctx context.Context, host, srcFilePath, dstFilePath string) error
+	// copy or fetch a dir by tar, keeping the permissions, ownership, symlinks and xattrs, without the excluded files
+	CopyDir(host, localDir, remoteDir string, opts CopyOptions) error
+	FetchDir(host, localDir, remoteDir string, opts CopyOptions) error
+	CopyDirContext(ctx context.Context, host, localDir, remoteDir string, opts CopyOptions) error
+	FetchDirContext(ctx context.Context, host, localDir, remoteDir string, opts CopyOptions) error
 }
 
 type SSH struct {
@@ -56,6 +68,17 @@ type SSH struct {
 	PkPassword   string
 	Timeout      *time.Duration
 	LocalAddress *[]net.Addr
+	// Sudo escalates the remote commands and file copies of a non-root user by sudo.
+	Sudo         bool
+	SudoPassword string
+	// MaxSessions is the max number of sessions multiplexed on a pooled connection to a host, DefaultMaxSessions if zero.
+	MaxSessions int
+	// Edge is set for the host behind NAT, which is reached through the edge broker on master0.
+	Edge bool
+	// Gateway routes the connections to the edge host behind NAT, nil if the host is reachable directly.
+	Gateway *Gateway
+	// Jump routes the connections to the host in a private network through a jump host, nil if it is reachable directly.
+	Jump *Jump
 }
 
 func NewSSHByCluster(cluster *v1.Cluster) Interface {
@@ -66,13 +89,22 @@ func NewSSHByCluster(cluster *v1.Cluster) Interface {
 	if err != nil {
 		logger.Warn("failed to get local address, %v", err)
 	}
-	return &SSH{
+	s := &SSH{
 		User:         cluster.Spec.SSH.User,
 		Password:     cluster.Spec.SSH.Passwd,
 		PkFile:       cluster.Spec.SSH.Pk,
 		PkPassword:   cluster.Spec.SSH.PkPasswd,
 		LocalAddress: address,
+		Sudo:         cluster.Spec.SSH.Sudo,
+		SudoPassword: cluster.Spec.SSH.SudoPasswd,
+		MaxSessions:  cluster.Spec.SSH.MaxSessions,
+	}
+	// the hosts created by the cloud provider are applied as baremetal ones from here, through the EIP of master0.
+	if eip := cluster.GetAnnotationsByKey(common.Eip); eip != "" && cluster.Spec.Provider == common.BAREMETAL {
+		jump := *s
+		s.Jump = &Jump{SSH: &jump, Host: eip}
 	}
+	return s
 }
 
 func NewSSHClient(ssh *v1.SSH) Interface {
@@ -89,10 +121,71 @@ func NewSSHClient(ssh *v1.SSH) Interface {
 		PkFile:       ssh.Pk,
 		PkPassword:   ssh.PkPasswd,
 		LocalAddress: address,
+		Sudo:         ssh.Sudo,
+		SudoPassword: ssh.SudoPasswd,
+		MaxSessions:  ssh.MaxSessions,
+		Edge:         ssh.Edge,
 	}
 }
 
+// Provider returns the ssh client of host in cluster, it replaces the default ssh of the hosts in a cluster,
+// like a fake one of the embedders of sealer in tests.
+type Provider func(hostIP string, cluster *v2.Cluster) (Interface, error)
+
+// ConnectTimeout overrides the sshConnect timeout in Clusterfile of the ssh clients returned by GetHostSSHClient, if not zero.
+var ConnectTimeout time.Duration
+
+var (
+	providersLock sync.RWMutex
+	providers     = map[string]Provider{}
+)
+
+// RegisterProvider makes GetHostSSHClient return the client of p for the hosts in cluster clusterName.
+func RegisterProvider(clusterName string, p Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[clusterName] = p
+}
+
+func UnregisterProvider(clusterName string) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	delete(providers, clusterName)
+}
+
 func GetHostSSHClient(hostIP string, cluster *v2.Cluster) (Interface, error) {
+	providersLock.RLock()
+	p, ok := providers[cluster.Name]
+	providersLock.RUnlock()
+	client, err := func() (Interface, error) {
+		if ok {
+			return p(hostIP, cluster)
+		}
+		client, err := hostSSH(hostIP, cluster)
+		if err != nil {
+			return nil, err
+		}
+		// master0 is the gateway, which must be reachable directly.
+		master0 := cluster.GetMaster0Ip()
+		if client.Edge && hostIP != master0 {
+			gateway, err := hostSSH(master0, cluster)
+			if err != nil {
+				return nil, err
+			}
+			client.Gateway = &Gateway{SSH: gateway, Host: master0}
+		}
+		return client, nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if ctx, ok := boundContext(cluster.Name); ok {
+		return WithContext(ctx, client), nil
+	}
+	return client, nil
+}
+
+func hostSSH(hostIP string, cluster *v2.Cluster) (*SSH, error) {
 	for _, host := range cluster.Spec.Hosts {
 		for _, ip := range host.IPS {
 			if hostIP == ip {
@@ -100,13 +193,24 @@ func GetHostSSHClient(hostIP string, cluster *v2.Cluster) (Interface, error) {
 					return nil, err
 				}
 
-				return NewSSHClient(&host.SSH), nil
+				client := NewSSHClient(&host.SSH).(*SSH)
+				if timeout := connectTimeout(cluster); timeout > 0 {
+					client.Timeout = &timeout
+				}
+				return client, nil
 			}
 		}
 	}
 	return nil, fmt.Errorf("get host ssh client failed, host ip %s not in hosts ip list", hostIP)
 }
 
+func connectTimeout(cluster *v2.Cluster) time.Duration {
+	if ConnectTimeout > 0 {
+		return ConnectTimeout
+	}
+	return cluster.Spec.Timeouts.SSHConnect.Duration
+}
+
 type Client struct {
 	SSH  Interface
 	Host string
@@ -128,7 +232,13 @@ func NewSSHClientWithCluster(cluster *v1.Cluster) (*Client, error) {
 		host = cluster.Spec.Masters.IPList[0]
 		ipList = append(ipList, append(cluster.Spec.Masters.IPList, cluster.Spec.Nodes.IPList...)...)
 	}
-	err := WaitSSHReady(sshClient, 6, ipList...)
+	var err error
+	if cluster.Spec.Provider == common.AliCloud {
+		// the instances just created may be still booting.
+		err = WaitHostsReady(sshClient, ReadyOptions{WaitCloudInit: true}, ipList...)
+	} else {
+		err = WaitSSHReady(sshClient, 6, ipList...)
+	}
 	if err != nil {
 		return nil, err
 	}
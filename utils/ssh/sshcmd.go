@@ -16,18 +16,34 @@ package ssh
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"strings"
 	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
 
 	"github.com/alibaba/sealer/utils"
 )
 
 var DebugMode bool
 
+// Verbose streams the output of remote commands to the console as they run, prefixed with the host, it is logged
+// to the OutputLogger only by default.
+var Verbose bool
+
+// errorOutputLines is the number of the last lines of output in the error of a failed command, if the whole output
+// is logged to a file.
+const errorOutputLines = 20
+
+// Ping dials a new connection to host instead of using a pooled one, which may be stale until its next keepalive.
 func (s *SSH) Ping(host string) error {
-	client, _, err := s.Connect(host)
+	if s.isLocal(host) {
+		return nil
+	}
+	client, err := s.dial(host)
 	if err != nil {
 		return fmt.Errorf("[ssh %s]create ssh session failed, %v", host, err)
 	}
@@ -39,18 +55,41 @@ func (s *SSH) Ping(host string) error {
 }
 
 func (s *SSH) CmdAsync(host string, cmds ...string) error {
+	return s.CmdAsyncContext(context.Background(), host, cmds...)
+}
+
+// CmdAsyncContext sends SIGTERM to the running command and closes its session once ctx is done,
+// the commands not started yet are skipped.
+func (s *SSH) CmdAsyncContext(ctx context.Context, host string, cmds ...string) error {
+	defer observeCommand(ctx, host, time.Now())
 	for _, cmd := range cmds {
 		if cmd == "" {
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-		if err := func(cmd string) error {
+		end := traceCommand(ctx, host, cmd)
+		if s.isLocal(host) {
+			err := s.localCmdAsync(ctx, host, cmd)
+			end(err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := func(cmd string) error {
 			client, session, err := s.Connect(host)
 			if err != nil {
 				return fmt.Errorf("failed to create ssh session for %s: %v", host, err)
 			}
 			defer client.Close()
 			defer session.Close()
+			stop := closeOnDone(ctx, func() {
+				_ = session.Signal(ssh.SIGTERM)
+			}, session, client)
+			defer stop()
 			stdout, err := session.StdoutPipe()
 			if err != nil {
 				return fmt.Errorf("failed to create stdout pipe for %s: %v", host, err)
@@ -60,30 +99,39 @@ func (s *SSH) CmdAsync(host string, cmds ...string) error {
 				return fmt.Errorf("failed to create stderr pipe for %s: %v", host, err)
 			}
 
-			if err := session.Start(cmd); err != nil {
+			if err := session.Start(s.wrapCmd(session, cmd)); err != nil {
 				return fmt.Errorf("failed to start command %s on %s: %v", cmd, host, err)
 			}
 
-			var combineSlice []string
-			var combineLock sync.Mutex
+			out := &output{host: host}
+			logFile, logPath := openOutput(ctx, host, cmd)
+			if logFile != nil {
+				defer logFile.Close()
+				out.log = logFile
+			}
 			doneout := make(chan error, 1)
 			doneerr := make(chan error, 1)
 			go func() {
-				doneerr <- readPipe(stderr, &combineSlice, &combineLock)
+				doneerr <- readPipe(stderr, out)
 			}()
 			go func() {
-				doneout <- readPipe(stdout, &combineSlice, &combineLock)
+				doneout <- readPipe(stdout, out)
 			}()
 			<-doneerr
 			<-doneout
 
 			err = session.Wait()
+			if ctx.Err() != nil {
+				return fmt.Errorf("command %s on %s is canceled: %w", cmd, host, ctx.Err())
+			}
 			if err != nil {
-				return utils.WrapExecResult(host, cmd, []byte(strings.Join(combineSlice, "\n")), err)
+				return out.wrapError(cmd, logPath, err)
 			}
 
 			return nil
-		}(cmd); err != nil {
+		}(cmd)
+		end(err)
+		if err != nil {
 			return err
 		}
 	}
@@ -92,13 +140,16 @@ func (s *SSH) CmdAsync(host string, cmds ...string) error {
 }
 
 func (s *SSH) Cmd(host, cmd string) ([]byte, error) {
+	if s.isLocal(host) {
+		return s.localCmd(host, cmd)
+	}
 	client, session, err := s.Connect(host)
 	if err != nil {
 		return nil, fmt.Errorf("[ssh][%s] create ssh session failed, %s", host, err)
 	}
 	defer client.Close()
 	defer session.Close()
-	b, err := session.CombinedOutput(cmd)
+	b, err := session.CombinedOutput(s.wrapCmd(session, cmd))
 	if err != nil {
 		return b, fmt.Errorf("[ssh][%s]run command failed [%s]", host, cmd)
 	}
@@ -106,7 +157,24 @@ func (s *SSH) Cmd(host, cmd string) ([]byte, error) {
 	return b, nil
 }
 
-func readPipe(pipe io.Reader, combineSlice *[]string, combineLock *sync.Mutex) error {
+// output collects the stdout and stderr lines of a remote command on host, and writes them to its log.
+type output struct {
+	sync.Mutex
+	host  string
+	lines []string
+	log   io.Writer
+}
+
+// wrapError returns the error of the failed cmd with its last lines of output.
+func (o *output) wrapError(cmd, logPath string, err error) error {
+	lines := o.lines
+	if logPath != "" && len(lines) > errorOutputLines {
+		lines = append([]string{fmt.Sprintf("... the whole output is in %s", logPath)}, lines[len(lines)-errorOutputLines:]...)
+	}
+	return utils.WrapExecResult(o.host, cmd, []byte(strings.Join(lines, "\n")), err)
+}
+
+func readPipe(pipe io.Reader, out *output) error {
 	r := bufio.NewReader(pipe)
 	for {
 		line, _, err := r.ReadLine()
@@ -114,11 +182,14 @@ func readPipe(pipe io.Reader, combineSlice *[]string, combineLock *sync.Mutex) e
 			return err
 		}
 
-		combineLock.Lock()
-		*combineSlice = append(*combineSlice, string(line))
-		if DebugMode {
-			fmt.Println(string(line))
+		out.Lock()
+		out.lines = append(out.lines, string(line))
+		if out.log != nil {
+			_, _ = fmt.Fprintln(out.log, string(line))
+		}
+		if Verbose || DebugMode {
+			fmt.Printf("[%s] %s\n", out.host, line)
 		}
-		combineLock.Unlock()
+		out.Unlock()
 	}
 }
@@ -34,12 +34,9 @@ func TestSSH_Cmd(t *testing.T) {
 			name: "touch test.txt",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/touchTxt.sh",
@@ -51,12 +48,9 @@ func TestSSH_Cmd(t *testing.T) {
 			name: "ls /opt/test",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "ls /opt/test",
@@ -68,12 +62,9 @@ func TestSSH_Cmd(t *testing.T) {
 			name: "remove test.txt",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/removeTxt.sh",
@@ -85,12 +76,9 @@ func TestSSH_Cmd(t *testing.T) {
 			name: "exist 1",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/exit1.sh",
@@ -129,12 +117,9 @@ func TestSSH_CmdAsync(t *testing.T) {
 			name: "touch test.txt",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/touchTxt.sh",
@@ -145,12 +130,9 @@ func TestSSH_CmdAsync(t *testing.T) {
 			name: "ls /opt/test",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "ls /opt/test",
@@ -161,12 +143,9 @@ func TestSSH_CmdAsync(t *testing.T) {
 			name: "remove test.txt",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/removeTxt.sh",
@@ -177,12 +156,9 @@ func TestSSH_CmdAsync(t *testing.T) {
 			name: "exist 1",
 			args: args{
 				ssh: SSH{
-					"root",
-					"huaijiahui.com",
-					"",
-					"",
-					nil,
-					&[]net.Addr{},
+					User:         "root",
+					Password:     "huaijiahui.com",
+					LocalAddress: &[]net.Addr{},
 				},
 				host: "192.168.56.103",
 				cmd:  "bash /opt/exit1.sh",
@@ -0,0 +1,126 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+const (
+	// sudo reads the password from stdin without prompt, or fails instead of waiting for a password.
+	sudoWithPasswd    = "sudo -S -p '' -- bash -c %s"
+	sudoWithoutPasswd = "sudo -n -- bash -c %s"
+	// files to privileged paths are uploaded to a staging dir created by the user, then installed by sudo,
+	// mktemp makes the dir private to the user, so no other user can replace the files in it.
+	mkStagingDirCmd  = "mktemp -d /tmp/.sealer-staging.XXXXXXXXXX"
+	stagingDirPrefix = "/tmp/.sealer-staging."
+	installFileCmd   = "mkdir -p %s && install -m %o %s %s"
+)
+
+func (s *SSH) isSudo() bool {
+	return s.Sudo && s.User != "" && s.User != common.ROOT
+}
+
+func (s *SSH) sudoPassword() string {
+	if s.SudoPassword != "" {
+		return s.SudoPassword
+	}
+	return s.Password
+}
+
+// wrapCmd wraps cmd by sudo for a non-root user, and feeds the password of sudo to the session.
+func (s *SSH) wrapCmd(session *ssh.Session, cmd string) string {
+	cmd, stdin := s.sudoCmd(cmd)
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+	return cmd
+}
+
+// sudoCmd wraps cmd by sudo for a non-root user, and returns the stdin feeding the password of sudo, nil if none.
+func (s *SSH) sudoCmd(cmd string) (string, io.Reader) {
+	if !s.isSudo() {
+		return cmd, nil
+	}
+	passwd := s.sudoPassword()
+	if passwd == "" {
+		return fmt.Sprintf(sudoWithoutPasswd, utils.ShellQuote(cmd)), nil
+	}
+	return fmt.Sprintf(sudoWithPasswd, utils.ShellQuote(cmd)), strings.NewReader(passwd + "\n")
+}
+
+// makeStagingDir creates a staging dir on host as the user, remove it by removeStagingDir after use.
+func (s *SSH) makeStagingDir(ctx context.Context, host string) (string, error) {
+	var out bytes.Buffer
+	if err := s.stream(ctx, host, mkStagingDirCmd, nil, &out); err != nil {
+		return "", fmt.Errorf("failed to create staging dir on %s: %v", host, err)
+	}
+	dir := strings.TrimSpace(out.String())
+	if !strings.HasPrefix(dir, stagingDirPrefix) || strings.Contains(strings.TrimPrefix(dir, stagingDirPrefix), "/") {
+		return "", fmt.Errorf("unexpected staging dir %q on %s", dir, host)
+	}
+	return dir, nil
+}
+
+// removeStagingDir removes the staging dir as the user, the files in it are owned by the user.
+func (s *SSH) removeStagingDir(host, dir string) {
+	if err := s.stream(context.Background(), host, "rm -rf "+utils.ShellQuote(dir), nil, nil); err != nil {
+		logger.Warn("failed to remove staging dir %s on %s: %v", dir, host, err)
+	}
+}
+
+// installStaged moves the staged file to remotePath by sudo.
+func (s *SSH) installStaged(host, staged, remotePath string, mode os.FileMode) error {
+	cmd := fmt.Sprintf(installFileCmd, utils.ShellQuote(path.Dir(remotePath)), mode.Perm(), utils.ShellQuote(staged), utils.ShellQuote(remotePath))
+	if _, err := s.Cmd(host, cmd); err != nil {
+		return fmt.Errorf("failed to install %s to %s on %s: %v", staged, remotePath, host, err)
+	}
+	return nil
+}
+
+// stageRemoteFile copies remotePath which may only be readable by root to the staging dir, it is read by sudo
+// and written by the shell of the user, so that it can be fetched by sftp as the user.
+func (s *SSH) stageRemoteFile(ctx context.Context, host, dir, remotePath string) (string, error) {
+	staged := path.Join(dir, path.Base(remotePath))
+	cmd, stdin := s.sudoCmd("cat -- " + utils.ShellQuote(remotePath))
+	if err := s.stream(ctx, host, cmd+" > "+utils.ShellQuote(staged), stdin, nil); err != nil {
+		return "", fmt.Errorf("failed to stage %s on %s: %v", remotePath, host, err)
+	}
+	return staged, nil
+}
+
+// mkdirAll creates remote dir by sudo if the user may not have the permission.
+func (s *SSH) mkdirAll(host string, sftpClient *sftp.Client, dir string) error {
+	if !s.isSudo() {
+		return sftpClient.MkdirAll(dir)
+	}
+	if _, err := s.Cmd(host, "mkdir -p "+utils.ShellQuote(dir)); err != nil {
+		return fmt.Errorf("failed to create remote dir %s on %s: %v", dir, host, err)
+	}
+	return nil
+}
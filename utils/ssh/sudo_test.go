@@ -0,0 +1,81 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_wrapCmd(t *testing.T) {
+	tests := []struct {
+		name      string
+		ssh       SSH
+		cmd       string
+		want      string
+		wantStdin string
+	}{
+		{
+			name: "root user",
+			ssh:  SSH{User: "root", Sudo: true, Password: "xxx"},
+			cmd:  "kubeadm reset -f",
+			want: "kubeadm reset -f",
+		},
+		{
+			name: "sudo disabled",
+			ssh:  SSH{User: "sealer", Password: "xxx"},
+			cmd:  "kubeadm reset -f",
+			want: "kubeadm reset -f",
+		},
+		{
+			name: "NOPASSWD sudo",
+			ssh:  SSH{User: "sealer", Sudo: true},
+			cmd:  "echo 'a' > /etc/b",
+			want: `sudo -n -- bash -c 'echo '\''a'\'' > /etc/b'`,
+		},
+		{
+			name:      "sudo by ssh password",
+			ssh:       SSH{User: "sealer", Sudo: true, Password: "xxx"},
+			cmd:       "kubeadm reset -f",
+			want:      "sudo -S -p '' -- bash -c 'kubeadm reset -f'",
+			wantStdin: "xxx\n",
+		},
+		{
+			name:      "sudo by sudo password",
+			ssh:       SSH{User: "sealer", Sudo: true, Password: "xxx", SudoPassword: "yyy"},
+			cmd:       "kubeadm reset -f",
+			want:      "sudo -S -p '' -- bash -c 'kubeadm reset -f'",
+			wantStdin: "yyy\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &ssh.Session{}
+			if got := tt.ssh.wrapCmd(session, tt.cmd); got != tt.want {
+				t.Errorf("wrapCmd() = %v, want %v", got, tt.want)
+			}
+			var stdin string
+			if session.Stdin != nil {
+				b, _ := ioutil.ReadAll(session.Stdin)
+				stdin = string(b)
+			}
+			if stdin != tt.wantStdin {
+				t.Errorf("wrapCmd() stdin = %q, want %q", stdin, tt.wantStdin)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/alibaba/sealer/common"
+	"github.com/alibaba/sealer/logger"
+	"github.com/alibaba/sealer/utils"
+)
+
+// CopyOptions are the options of CopyDir and FetchDir.
+type CopyOptions struct {
+	// Excludes are the patterns of tar --exclude matched against the paths in the dir, a pattern without / matches
+	// the files of the name at any depth, like: *.log, and ./registry only matches the one at the top.
+	Excludes []string
+}
+
+// tarCreateArgs stream dir with the permissions, ownership, symlinks, hard links, sparse files and xattrs.
+func tarCreateArgs(dir, archive string, excludes []string) []string {
+	args := []string{"-c", "-f", archive, "--sparse", "--xattrs", "--xattrs-include=*", "-C", dir}
+	for _, e := range excludes {
+		args = append(args, "--exclude="+e)
+	}
+	return append(args, ".")
+}
+
+// tarExtractArgs keep the ownership by the uid and gid in archive, which is the default of root only.
+func tarExtractArgs(dir, archive string) []string {
+	return []string{"-x", "-f", archive, "-p", "--numeric-owner", "--xattrs", "--xattrs-include=*", "-C", dir}
+}
+
+func remoteTar(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = utils.ShellQuote(a)
+	}
+	return "tar " + strings.Join(quoted, " ")
+}
+
+// CopyDir streams localDir to remoteDir of host by tar.
+func (s *SSH) CopyDir(host, localDir, remoteDir string, opts CopyOptions) error {
+	return s.CopyDirContext(context.Background(), host, localDir, remoteDir, opts)
+}
+
+// CopyDirContext stops the tar on both sides once ctx is done, it leaves the files extracted.
+func (s *SSH) CopyDirContext(ctx context.Context, host, localDir, remoteDir string, opts CopyOptions) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	end := traceTransfer(ctx, host, DirectionUpload, localDir, remoteDir)
+	defer func() {
+		end(err)
+	}()
+	if s.isLocal(host) {
+		return localTarCopy(ctx, localDir, remoteDir, opts.Excludes)
+	}
+
+	var stderr bytes.Buffer
+	create := exec.CommandContext(ctx, "tar", tarCreateArgs(localDir, "-", opts.Excludes)...) // #nosec
+	create.Stderr = &stderr
+	out, err := create.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := create.Start(); err != nil {
+		return fmt.Errorf("failed to tar %s: %v", localDir, err)
+	}
+	archive := &countingReader{r: out}
+	start := time.Now()
+	if s.isSudo() {
+		// the password of sudo can not be fed along with the archive, extract the one staged by the user by sudo.
+		var dir string
+		if dir, err = s.makeStagingDir(ctx, host); err == nil {
+			staged := path.Join(dir, "archive.tar")
+			err = s.stream(ctx, host, "cat > "+utils.ShellQuote(staged), archive, nil)
+			if err == nil {
+				_, err = s.Cmd(host, fmt.Sprintf("mkdir -p %s && %s", utils.ShellQuote(remoteDir), remoteTar(tarExtractArgs(remoteDir, staged))))
+			}
+			s.removeStagingDir(host, dir)
+		}
+	} else {
+		err = s.stream(ctx, host, fmt.Sprintf("mkdir -p %s && %s", utils.ShellQuote(remoteDir), remoteTar(tarExtractArgs(remoteDir, "-"))), archive, nil)
+	}
+	// unblock the tar writing to the pipe if the remote one exits early.
+	_ = out.Close()
+	werr := create.Wait()
+	observeTransfer(ctx, host, DirectionUpload, archive.n, start)
+	if ctx.Err() != nil {
+		return fmt.Errorf("copying %s to %s is canceled: %w", localDir, host, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s of %s: %v", localDir, remoteDir, host, err)
+	}
+	if werr != nil {
+		return fmt.Errorf("failed to tar %s: %v, %s", localDir, werr, stderr.String())
+	}
+	logger.Debug("copied %s of %s to %s of %s", utils.FormatSize(archive.n), localDir, remoteDir, host)
+	return nil
+}
+
+// FetchDir streams remoteDir of host to localDir by tar.
+func (s *SSH) FetchDir(host, localDir, remoteDir string, opts CopyOptions) error {
+	return s.FetchDirContext(context.Background(), host, localDir, remoteDir, opts)
+}
+
+// FetchDirContext stops the tar on both sides once ctx is done, it leaves the files extracted.
+func (s *SSH) FetchDirContext(ctx context.Context, host, localDir, remoteDir string, opts CopyOptions) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	end := traceTransfer(ctx, host, DirectionDownload, localDir, remoteDir)
+	defer func() {
+		end(err)
+	}()
+	if s.isLocal(host) {
+		return localTarCopy(ctx, remoteDir, localDir, opts.Excludes)
+	}
+
+	if err := os.MkdirAll(localDir, common.FileMode0755); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	extract := exec.CommandContext(ctx, "tar", tarExtractArgs(localDir, "-")...) // #nosec
+	extract.Stderr = &stderr
+	in, err := extract.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := extract.Start(); err != nil {
+		return fmt.Errorf("failed to untar to %s: %v", localDir, err)
+	}
+	archive := &countingWriter{w: in}
+	start := time.Now()
+	// the dir may not be readable by the user, archive it by sudo, whose stdin only feeds the password.
+	cmd, stdin := s.sudoCmd(remoteTar(tarCreateArgs(remoteDir, "-", opts.Excludes)))
+	err = s.stream(ctx, host, cmd, stdin, archive)
+	_ = in.Close()
+	werr := extract.Wait()
+	observeTransfer(ctx, host, DirectionDownload, archive.n, start)
+	if ctx.Err() != nil {
+		return fmt.Errorf("fetching %s from %s is canceled: %w", remoteDir, host, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s of %s to %s: %v", remoteDir, host, localDir, err)
+	}
+	if werr != nil {
+		return fmt.Errorf("failed to untar to %s: %v, %s", localDir, werr, stderr.String())
+	}
+	return nil
+}
+
+// stream runs cmd on host as the user without pty, so the binary stdin and stdout are not mangled by the terminal.
+func (s *SSH) stream(ctx context.Context, host, cmd string, stdin io.Reader, stdout io.Writer) error {
+	client, err := s.connect(host)
+	if err != nil {
+		return fmt.Errorf("failed to create ssh session for %s: %v", host, err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		client.discard()
+		return fmt.Errorf("failed to create ssh session for %s: %v", host, err)
+	}
+	defer session.Close()
+	stop := closeOnDone(ctx, func() {
+		_ = session.Signal(ssh.SIGTERM)
+	}, session)
+	defer stop()
+
+	var stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = &stderr
+	if err := session.Run(cmd); err != nil {
+		return utils.WrapExecResult(host, cmd, stderr.Bytes(), err)
+	}
+	return nil
+}
+
+// localTarCopy copies src to dst on the local host by tar, the same as to a remote one.
+func localTarCopy(ctx context.Context, src, dst string, excludes []string) error {
+	if filepath.Clean(src) == filepath.Clean(dst) {
+		return nil
+	}
+	logger.Debug("local copy files src %s to dst %s", src, dst)
+	if err := os.MkdirAll(dst, common.FileMode0755); err != nil {
+		return err
+	}
+	var createErr, extractErr bytes.Buffer
+	create := exec.CommandContext(ctx, "tar", tarCreateArgs(src, "-", excludes)...) // #nosec
+	extract := exec.CommandContext(ctx, "tar", tarExtractArgs(dst, "-")...)         // #nosec
+	create.Stderr = &createErr
+	extract.Stderr = &extractErr
+	out, err := create.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	extract.Stdin = out
+	if err := create.Start(); err != nil {
+		return err
+	}
+	if err := extract.Run(); err != nil {
+		_ = create.Process.Kill()
+		_ = create.Wait()
+		return fmt.Errorf("failed to copy %s to %s: %v, %s", src, dst, err, extractErr.String())
+	}
+	if err := create.Wait(); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %v, %s", src, dst, err, createErr.String())
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,88 @@
+// Copyright © 2021 Alibaba Group Holding Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoteTar(t *testing.T) {
+	got := remoteTar(tarCreateArgs("/var/lib/sealer/data/my cluster/rootfs", "-", []string{"./registry", "*.log"}))
+	want := "tar '-c' '-f' '-' '--sparse' '--xattrs' '--xattrs-include=*' '-C' '/var/lib/sealer/data/my cluster/rootfs' " +
+		"'--exclude=./registry' '--exclude=*.log' '.'"
+	if got != want {
+		t.Errorf("remoteTar() = %s, want %s", got, want)
+	}
+}
+
+func TestLocalTarCopy(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar is not installed")
+	}
+	src, err := ioutil.TempDir("", "sealer-tar-src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "sealer-tar-dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	if err = os.MkdirAll(filepath.Join(src, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(src, "registry", "docker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(src, "bin", "kubeadm"), []byte("#!/bin/sh"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(src, "bin", "debug.log"), []byte("log"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(src, "registry", "docker", "blob"), []byte("blob"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink("bin/kubeadm", filepath.Join(src, "kubeadm")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = localTarCopy(context.Background(), src, filepath.Join(dst, "rootfs"), []string{"./registry", "*.log"}); err != nil {
+		t.Fatalf("localTarCopy() error = %v", err)
+	}
+	f, err := os.Stat(filepath.Join(dst, "rootfs", "bin", "kubeadm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Mode().Perm() != 0750 {
+		t.Errorf("mode of kubeadm = %o, want 750", f.Mode().Perm())
+	}
+	target, err := os.Readlink(filepath.Join(dst, "rootfs", "kubeadm"))
+	if err != nil || target != "bin/kubeadm" {
+		t.Errorf("symlink kubeadm = %s, %v, want bin/kubeadm", target, err)
+	}
+	for _, excluded := range []string{"registry", "bin/debug.log"} {
+		if _, err := os.Lstat(filepath.Join(dst, "rootfs", excluded)); !os.IsNotExist(err) {
+			t.Errorf("%s is not excluded: %v", excluded, err)
+		}
+	}
+}
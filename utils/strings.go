@@ -117,3 +117,8 @@ func DedupeStrSlice(in []string) []string {
 	}
 	return res
 }
+
+// ShellQuote quotes s as a single word of the shell, the single quotes in it are kept.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -46,3 +46,20 @@ func TestAppendIPList(t *testing.T) {
 		})
 	}
 }
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", "''"},
+		{"/var/lib/sealer", "'/var/lib/sealer'"},
+		{"a b;$(rm -rf /)", "'a b;$(rm -rf /)'"},
+		{"it's", `'it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := ShellQuote(tt.s); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", tt.s, got, tt.want)
+		}
+	}
+}